@@ -0,0 +1,123 @@
+package generic
+
+import (
+	"go/constant"
+	"go/token"
+	"strings"
+)
+
+// APIReport returns the exported API of a checked package in Go syntax, one declaration per line:
+// types with their exported fields, generic types and functions with their constraints,
+// functions, variables, constants and the methods of each type.
+//
+// The lines are sorted by the name of the declaration, and the methods of a type follow
+// the type in the order of their names, so reports of two versions of a package can be diffed.
+func APIReport(env TypeEnv) []string {
+	var report []string
+	for _, name := range sortedKeys(env) {
+		if !token.IsExported(name) {
+			continue
+		}
+		report = append(report, apiDecls(name, env[name])...)
+	}
+	return report
+}
+
+// apiDecls returns the declaration of name, followed by its exported methods if it is a type.
+func apiDecls(name string, t Type) []string {
+	switch t := t.(type) {
+	case *StructType:
+		if t.Name != name {
+			break
+		}
+		decls := []string{"type " + name + " " + formatStruct(exportedFields(t.Fields), t.FieldOrder)}
+		return append(decls, apiMethods(name, t.Methods)...)
+	case *InterfaceType:
+		if t.Name != name {
+			break
+		}
+		return []string{"type " + name + " " + formatInterface(exportedMethods(t.Methods), t.Embedded)}
+	case *TypeAlias:
+		if t.Name != name {
+			break
+		}
+		return []string{"type " + name + " = " + FormatGo(t.AliasedTo)}
+	case *GenericType:
+		if t.Name != name {
+			break
+		}
+		params := t.TypeParamList()
+		if t.Signature != nil {
+			return []string{"func " + name + formatTypeParams(params) + strings.TrimPrefix(FormatGo(t.Signature), "func")}
+		}
+		decl := "type " + name + formatTypeParams(params) + " "
+		if t.IsInterface {
+			return []string{decl + formatInterface(exportedMethods(t.Methods), nil)}
+		}
+		decls := []string{decl + formatStruct(exportedFields(t.Fields), t.FieldOrder)}
+		if t.UnderlyingType != nil {
+			decls = []string{decl + FormatGo(t.UnderlyingType)}
+		}
+		return append(decls, apiMethods(name+"["+params.names()+"]", t.Methods)...)
+	case *NamedType:
+		if t.Name != name {
+			break
+		}
+		if iface, ok := underlying(t).(*InterfaceType); ok {
+			return []string{"type " + name + " " + formatInterface(exportedMethods(iface.Methods), iface.Embedded)}
+		}
+		decls := []string{"type " + name + " " + FormatGo(t.Underlying)}
+		return append(decls, apiMethods(name, t.Methods)...)
+	case *FunctionType:
+		return []string{"func " + name + strings.TrimPrefix(FormatGo(t), "func")}
+	case *TypeObj:
+		return apiDecls(name, t.Type)
+	case *FuncObj:
+		return apiDecls(name, t.Type)
+	case *VarObj:
+		return []string{"var " + name + " " + FormatGo(t.Type)}
+	case *ConstObj:
+		switch {
+		case t.Val == nil || t.Val.Kind() == constant.Unknown:
+			return []string{"const " + name + " " + FormatGo(t.Type)}
+		case t.Untyped:
+			return []string{"const " + name + " = " + t.Val.ExactString()}
+		}
+		return []string{"const " + name + " " + FormatGo(t.Type) + " = " + t.Val.ExactString()}
+	}
+	return []string{"var " + name + " " + FormatGo(t)}
+}
+
+// apiMethods returns the exported methods of the type named recv, like `func (*T) M()`.
+func apiMethods(recv string, methods MethodSet) []string {
+	var decls []string
+	for _, name := range sortedKeys(exportedMethods(methods)) {
+		m := methods[name]
+		r := recv
+		if m.IsPointer {
+			r = "*" + recv
+		}
+		decls = append(decls, "func ("+r+") "+FormatGo(m))
+	}
+	return decls
+}
+
+func exportedFields(fields map[string]Type) map[string]Type {
+	exported := make(map[string]Type, len(fields))
+	for name, t := range fields {
+		if token.IsExported(name) {
+			exported[name] = t
+		}
+	}
+	return exported
+}
+
+func exportedMethods(methods MethodSet) MethodSet {
+	exported := make(MethodSet, len(methods))
+	for name, m := range methods {
+		if token.IsExported(name) {
+			exported[name] = m
+		}
+	}
+	return exported
+}
@@ -0,0 +1,119 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAPIReport(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringType := &TypeConstant{Name: "string"}
+	tv := &TypeVariable{Name: "T"}
+
+	point := &StructType{Name: "Point", Fields: map[string]Type{"X": intType, "Y": intType, "label": stringType}}
+	point.Methods = MethodSet{
+		"String": {Name: "String", Receiver: point, Results: []Type{stringType}},
+		"Scale":  {Name: "Scale", Receiver: point, Params: []Type{intType}, IsPointer: true},
+		"norm":   {Name: "norm", Receiver: point, Results: []Type{intType}},
+	}
+	stringer := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{stringType}}}}
+	list := NewGenericType("List", TypeParamList{{Name: "T"}}, map[string]Type{"Items": &SliceType{ElementType: tv}}, nil)
+	list.Methods = MethodSet{"Len": {Name: "Len", Receiver: list, Results: []Type{intType}}}
+	max := NewGenericFunction("Max", TypeParamList{{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintOrdered}}},
+		&FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: tv})
+
+	env := TypeEnv{
+		"Point":    point,
+		"Stringer": stringer,
+		"List":     list,
+		"Max":      max,
+		"Label":    &TypeAlias{Name: "Label", AliasedTo: stringType},
+		"Parse":    &FunctionType{ParamTypes: []Type{stringType}, ReturnType: &TupleType{Types: []Type{intType, &TypeConstant{Name: "error"}}}},
+		"Origin":   point,
+		"helper":   &FunctionType{},
+		"int":      intType,
+	}
+
+	want := []string{
+		"type Label = string",
+		"type List[T any] struct{ Items []T }",
+		"func (List[T]) Len() int",
+		"func Max[T cmp.Ordered](T, T) T",
+		"var Origin Point",
+		"func Parse(string) (int, error)",
+		"type Point struct{ X int; Y int }",
+		"func (*Point) Scale(int)",
+		"func (Point) String() string",
+		"type Stringer interface{ String() string }",
+	}
+	if got := APIReport(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("APIReport() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestAPIReportBuildEnv(t *testing.T) {
+	const src = `package p
+
+type Age int
+
+func (a Age) String() string
+
+func (a *Age) Inc()
+
+func (a Age) valid() bool
+
+type Stringer interface {
+	String() string
+	hidden()
+}
+
+type Point struct {
+	X, Y int
+	label string
+}
+
+type Years = Age
+
+const Max Age = 3
+
+const Limit = 10
+
+const Name = "p"
+
+var Default Age
+
+func Parse(s string) (Age, error)
+
+func Largest[T ~int](xs ...T) T
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	want := []string{
+		"type Age int",
+		"func (*Age) Inc()",
+		"func (Age) String() string",
+		"var Default Age",
+		"func Largest[T ~int](...T) T",
+		"const Limit = 10",
+		"const Max Age = 3",
+		`const Name = "p"`,
+		"func Parse(string) (Age, error)",
+		"type Point struct{ X int; Y int }",
+		"type Stringer interface{ String() string }",
+		"type Years = Age",
+	}
+	if got := APIReport(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("APIReport() =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,131 @@
+package generic
+
+// arenaChunkSize is the number of nodes of a kind an Arena allocates at once.
+const arenaChunkSize = 128
+
+// Arena allocates the type nodes built by instantiation in chunks rather than one by one,
+// and reuses the chunks once freed. A check pass that instantiates many generic types
+// can allocate from an arena, see WithArena, and free all the nodes at once at the end.
+//
+// The types allocated from an arena must not be used after Free, since their memory
+// is reused by the next pass. An Arena is not safe for concurrent use.
+type Arena struct {
+	slices   slab[SliceType]
+	pointers slab[PointerType]
+	maps     slab[MapType]
+	arrays   slab[ArrayType]
+	funcs    slab[FunctionType]
+	tuples   slab[TupleType]
+	generics slab[GenericType]
+}
+
+// NewArena returns an empty arena.
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Free releases every node allocated from the arena at once, keeping the memory for reuse.
+func (a *Arena) Free() {
+	a.slices.free()
+	a.pointers.free()
+	a.maps.free()
+	a.arrays.free()
+	a.funcs.free()
+	a.tuples.free()
+	a.generics.free()
+}
+
+// slab hands out the elements of chunks of T in order.
+type slab[T any] struct {
+	chunks [][]T
+	chunk  int // index of the chunk being allocated from
+	next   int // index of the next free element in that chunk
+}
+
+func (s *slab[T]) alloc(v T) *T {
+	if s.chunk == len(s.chunks) {
+		s.chunks = append(s.chunks, make([]T, arenaChunkSize))
+	}
+	p := &s.chunks[s.chunk][s.next]
+	*p = v
+	if s.next++; s.next == arenaChunkSize {
+		s.chunk, s.next = s.chunk+1, 0
+	}
+	return p
+}
+
+func (s *slab[T]) free() {
+	// clear the nodes in use so they do not keep other types alive
+	for _, c := range s.chunks[:s.chunk] {
+		clear(c)
+	}
+	if s.chunk < len(s.chunks) {
+		clear(s.chunks[s.chunk][:s.next])
+	}
+	s.chunk, s.next = 0, 0
+}
+
+// The allocation methods are nil-safe: without an arena, nodes are allocated on the heap.
+
+func (a *Arena) slice(t SliceType) *SliceType {
+	if a == nil {
+		p := new(SliceType)
+		*p = t
+		return p
+	}
+	return a.slices.alloc(t)
+}
+
+func (a *Arena) pointer(t PointerType) *PointerType {
+	if a == nil {
+		p := new(PointerType)
+		*p = t
+		return p
+	}
+	return a.pointers.alloc(t)
+}
+
+func (a *Arena) mapType(t MapType) *MapType {
+	if a == nil {
+		p := new(MapType)
+		*p = t
+		return p
+	}
+	return a.maps.alloc(t)
+}
+
+func (a *Arena) array(t ArrayType) *ArrayType {
+	if a == nil {
+		p := new(ArrayType)
+		*p = t
+		return p
+	}
+	return a.arrays.alloc(t)
+}
+
+func (a *Arena) function(t FunctionType) *FunctionType {
+	if a == nil {
+		p := new(FunctionType)
+		*p = t
+		return p
+	}
+	return a.funcs.alloc(t)
+}
+
+func (a *Arena) tuple(t TupleType) *TupleType {
+	if a == nil {
+		p := new(TupleType)
+		*p = t
+		return p
+	}
+	return a.tuples.alloc(t)
+}
+
+func (a *Arena) generic(t GenericType) *GenericType {
+	if a == nil {
+		p := new(GenericType)
+		*p = t
+		return p
+	}
+	return a.generics.alloc(t)
+}
@@ -0,0 +1,40 @@
+package generic
+
+import "testing"
+
+func TestInstantiateWithArena(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	list := NewGenericType("List", TypeParamList{{Name: "T"}}, map[string]Type{
+		"items": &SliceType{ElementType: &PointerType{Base: tv}},
+		"index": &MapType{KeyType: &TypeConstant{Name: "string"}, ValueType: tv},
+		"next":  &FunctionType{ParamTypes: []Type{tv}, ReturnType: &TupleType{Types: []Type{tv, &TypeConstant{Name: "bool"}}}},
+	}, nil)
+	args := []interface{}{&TypeConstant{Name: "int"}}
+
+	want, err := InstantiateGenericType(list, args, TypeEnv{}, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+
+	arena := NewArena()
+	ctx := NewInferenceContext(WithArena(arena))
+	got, err := InstantiateGenericType(list, args, TypeEnv{}, ctx)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() with arena error = %v", err)
+	}
+	if !TypesEqual(got, want) || got.String() != want.String() {
+		t.Errorf("InstantiateGenericType() with arena = %v, want %v", got, want)
+	}
+
+	arena.Free()
+	again, err := InstantiateGenericType(list, args, TypeEnv{}, ctx)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() after Free error = %v", err)
+	}
+	if again != got {
+		t.Errorf("InstantiateGenericType() after Free did not reuse the arena")
+	}
+	if again.String() != want.String() {
+		t.Errorf("InstantiateGenericType() after Free = %v, want %v", again, want)
+	}
+}
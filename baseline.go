@@ -0,0 +1,138 @@
+package generic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Baseline is a snapshot of the diagnostics of a code base, so that adopting the checker
+// on legacy code only fails on new diagnostics. It counts each diagnostic by its file and
+// message, since the same message may be reported more than once.
+//
+// Diagnostics are matched by their file and message rather than their position, so that
+// they are not new once the code before them moves; a diagnostic whose message includes
+// a position still is.
+type Baseline map[BaselineEntry]int
+
+// BaselineEntry is a diagnostic of a Baseline. File is the base name of the file of the
+// diagnostic, since the checked files are those of a single directory, so that a baseline
+// applies to any checkout of it. It is empty for the diagnostics without a position.
+type BaselineEntry struct {
+	File    string
+	Message string
+}
+
+// NewBaseline returns a baseline holding the diagnostics diags, located in fset.
+func NewBaseline(fset *token.FileSet, diags []error) Baseline {
+	b := make(Baseline)
+	for _, d := range diags {
+		b[baselineEntry(fset, d)]++
+	}
+	return b
+}
+
+// baselineEntry returns the entry of the diagnostic err, located in fset.
+func baselineEntry(fset *token.FileSet, err error) BaselineEntry {
+	e := BaselineEntry{Message: err.Error()}
+	var te *TypeError
+	if fset != nil && errors.As(err, &te) && te.Pos.IsValid() {
+		e.File = filepath.Base(fset.Position(te.Pos).Filename)
+	}
+	return e
+}
+
+// New returns the diagnostics of diags, located in fset, that are not in the baseline,
+// in order. An entry in the baseline n times covers its first n occurrences.
+func (b Baseline) New(fset *token.FileSet, diags []error) []error {
+	seen := make(map[BaselineEntry]int)
+	var fresh []error
+	for _, d := range diags {
+		e := baselineEntry(fset, d)
+		seen[e]++
+		if seen[e] > b[e] {
+			fresh = append(fresh, d)
+		}
+	}
+	return fresh
+}
+
+// WriteTo writes the baseline to w, one entry per line, sorted, so that baseline files
+// can be diffed and reviewed. A line holds the quoted file and message of the entry, or
+// only its message if it has no file.
+func (b Baseline) WriteTo(w io.Writer) (int64, error) {
+	entries := make([]BaselineEntry, 0, len(b))
+	for e := range b {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+		return entries[i].Message < entries[j].Message
+	})
+
+	var sb strings.Builder
+	for _, e := range entries {
+		line := strconv.Quote(e.Message)
+		if e.File != "" {
+			line = strconv.Quote(e.File) + " " + line
+		}
+		for i := 0; i < b[e]; i++ {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// ReadBaseline reads a baseline written by Baseline.WriteTo.
+// Blank lines are ignored.
+func ReadBaseline(r io.Reader) (Baseline, error) {
+	b := make(Baseline)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		e, err := parseBaselineEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("baseline line %d: %v", line, err)
+		}
+		b[e]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// parseBaselineEntry parses a line of a baseline file, see Baseline.WriteTo.
+func parseBaselineEntry(text string) (BaselineEntry, error) {
+	first, err := strconv.QuotedPrefix(text)
+	if err != nil {
+		return BaselineEntry{}, err
+	}
+	rest := strings.TrimSpace(text[len(first):])
+	if rest == "" {
+		msg, err := strconv.Unquote(first)
+		return BaselineEntry{Message: msg}, err
+	}
+	file, err := strconv.Unquote(first)
+	if err != nil {
+		return BaselineEntry{}, err
+	}
+	msg, err := strconv.Unquote(rest)
+	if err != nil {
+		return BaselineEntry{}, err
+	}
+	return BaselineEntry{File: file, Message: msg}, nil
+}
@@ -0,0 +1,113 @@
+package generic
+
+import (
+	"errors"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	old := []error{
+		errors.New("unknown identifier: x"),
+		errors.New("unknown identifier: x"),
+		errors.New("invalid map key type []int"),
+	}
+	b := NewBaseline(nil, old)
+
+	var sb strings.Builder
+	if _, err := b.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := "\"invalid map key type []int\"\n\"unknown identifier: x\"\n\"unknown identifier: x\"\n"
+	if sb.String() != want {
+		t.Errorf("WriteTo() = %q, want %q", sb.String(), want)
+	}
+	read, err := ReadBaseline(strings.NewReader(sb.String() + "\n"))
+	if err != nil {
+		t.Fatalf("ReadBaseline() error = %v", err)
+	}
+	if !reflect.DeepEqual(read, b) {
+		t.Errorf("ReadBaseline() = %v, want %v", read, b)
+	}
+
+	tests := []struct {
+		name  string
+		diags []error
+		want  []string
+	}{
+		{name: "unchanged", diags: old, want: nil},
+		{name: "fixed", diags: old[:1], want: nil},
+		{
+			name:  "new diagnostic",
+			diags: append([]error{errors.New("too many errors")}, old...),
+			want:  []string{"too many errors"},
+		},
+		{
+			name:  "more occurrences",
+			diags: append(old, errors.New("unknown identifier: x")),
+			want:  []string{"unknown identifier: x"},
+		},
+		{
+			name:  "multi-line message",
+			diags: []error{errors.Join(errors.New("a"), errors.New("b"))},
+			want:  []string{"a\nb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range read.New(nil, tt.diags) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadBaseline(strings.NewReader("not quoted\n")); err == nil || !strings.HasPrefix(err.Error(), "baseline line 1:") {
+		t.Errorf("ReadBaseline() error = %v, want a line error", err)
+	}
+}
+
+func TestBaselineFiles(t *testing.T) {
+	fset := token.NewFileSet()
+	a := fset.AddFile("/src/p/a.go", -1, 100)
+	bfile := fset.AddFile("/src/p/b.go", -1, 100)
+	at := func(f *token.File, offset int, msg string) error {
+		return &TypeError{Pos: f.Pos(offset), Err: errors.New(msg)}
+	}
+
+	old := []error{at(a, 10, "unknown identifier: x"), at(bfile, 10, "unknown identifier: x"), errors.New("too many errors")}
+	b := NewBaseline(fset, old)
+
+	var sb strings.Builder
+	if _, err := b.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := "\"too many errors\"\n\"a.go\" \"unknown identifier: x\"\n\"b.go\" \"unknown identifier: x\"\n"
+	if sb.String() != want {
+		t.Errorf("WriteTo() = %q, want %q", sb.String(), want)
+	}
+	read, err := ReadBaseline(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("ReadBaseline() error = %v", err)
+	}
+	if !reflect.DeepEqual(read, b) {
+		t.Errorf("ReadBaseline() = %v, want %v", read, b)
+	}
+
+	// moved within its file, a diagnostic is not new, but it is in another file
+	moved := []error{at(a, 50, "unknown identifier: x"), at(a, 60, "unknown identifier: x")}
+	got := read.New(fset, moved)
+	if len(got) != 1 || got[0] != moved[1] {
+		t.Errorf("New() = %v, want %v", got, moved[1:])
+	}
+
+	if _, err := ReadBaseline(strings.NewReader("\"a.go\" not quoted\n")); err == nil || !strings.HasPrefix(err.Error(), "baseline line 1:") {
+		t.Errorf("ReadBaseline() error = %v, want a line error", err)
+	}
+}
@@ -2,21 +2,50 @@ package generic
 
 import (
 	"go/ast"
+	"go/token"
 	"testing"
 )
 
+// assertAllocs fails the benchmark if f allocates more than max times per run.
+func assertAllocs(b *testing.B, max float64, f func()) {
+	b.Helper()
+	if allocs := testing.AllocsPerRun(100, f); allocs > max {
+		b.Fatalf("%v allocations per run, want at most %v", allocs, max)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+}
+
 func BenchmarkInferTypeSimple(b *testing.B) {
 	env := TypeEnv{
 		"x": &TypeConstant{Name: "int"},
 	}
 	expr := &ast.Ident{Name: "x"}
 
-	b.ResetTimer()
+	assertAllocs(b, 0, func() { _, _ = InferType(expr, env, nil) })
 	for i := 0; i < b.N; i++ {
 		_, _ = InferType(expr, env, nil)
 	}
 }
 
+func BenchmarkInferTypeLiteral(b *testing.B) {
+	lits := []ast.Expr{
+		&ast.BasicLit{Kind: token.INT, Value: "42"},
+		&ast.BasicLit{Kind: token.FLOAT, Value: "4.2"},
+		&ast.BasicLit{Kind: token.STRING, Value: `"hello"`},
+		&ast.BasicLit{Kind: token.CHAR, Value: "'x'"},
+	}
+
+	assertAllocs(b, 0, func() {
+		for _, lit := range lits {
+			_, _ = InferType(lit, TypeEnv{}, nil)
+		}
+	})
+	for i := 0; i < b.N; i++ {
+		_, _ = InferType(lits[i%len(lits)], TypeEnv{}, nil)
+	}
+}
+
 func BenchmarkInferTypeFunction(b *testing.B) {
 	env := TypeEnv{
 		"f": &FunctionType{
@@ -87,3 +116,48 @@ func BenchmarkInferTypeComplex(b *testing.B) {
 		_, _ = InferType(expr, env, nil)
 	}
 }
+
+func BenchmarkInstantiateGenericType(b *testing.B) {
+	tv, uv := &TypeVariable{Name: "K"}, &TypeVariable{Name: "V"}
+	table := NewGenericType("Table", TypeParamList{{Name: "K"}, {Name: "V"}}, map[string]Type{
+		"rows":    &SliceType{ElementType: &MapType{KeyType: tv, ValueType: &PointerType{Base: uv}}},
+		"index":   &MapType{KeyType: tv, ValueType: &ArrayType{ElementType: uv, Len: 4}},
+		"lookup":  &FunctionType{ParamTypes: []Type{tv}, ReturnType: &TupleType{Types: []Type{uv, &TypeConstant{Name: "bool"}}}},
+		"history": &SliceType{ElementType: &SliceType{ElementType: &PointerType{Base: uv}}},
+	}, nil)
+	args := []interface{}{&TypeConstant{Name: "string"}, &SliceType{ElementType: &TypeConstant{Name: "int"}}}
+
+	b.Run("heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = InstantiateGenericType(table, args, TypeEnv{}, nil)
+		}
+	})
+	b.Run("arena", func(b *testing.B) {
+		b.ReportAllocs()
+		arena := NewArena()
+		ctx := NewInferenceContext(WithArena(arena))
+		for i := 0; i < b.N; i++ {
+			_, _ = InstantiateGenericType(table, args, TypeEnv{}, ctx)
+			arena.Free()
+		}
+	})
+}
+
+func BenchmarkCheckConstraint(b *testing.B) {
+	tc := &TypeConstraint{Types: []Type{&ApproxType{Base: Int}, &ApproxType{Base: Int64}, &ApproxType{Base: Float64}, String}, Union: true}
+
+	b.Run("walk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = checkConstraint(Float64, *tc)
+		}
+	})
+	b.Run("compiled", func(b *testing.B) {
+		m := compileConstraint(tc)
+		assertAllocs(b, 0, func() { _ = m.match(Float64) })
+		for i := 0; i < b.N; i++ {
+			_ = m.match(Float64)
+		}
+	})
+}
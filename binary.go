@@ -0,0 +1,428 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+var ErrInvalidOperation = gerrors.ErrInvalidOperation
+
+// operatorDefined maps the arithmetic and logical operators to the predeclared types they are defined on.
+// Comparison operators are checked separately, see inferBinaryExpr.
+var operatorDefined = map[token.Token]func(name string) bool{
+	token.ADD:     func(name string) bool { return isNumericName(name) || name == TypeString },
+	token.SUB:     isNumericName,
+	token.MUL:     isNumericName,
+	token.QUO:     isNumericName,
+	token.REM:     isIntegerName,
+	token.AND:     isIntegerName,
+	token.OR:      isIntegerName,
+	token.XOR:     isIntegerName,
+	token.AND_NOT: isIntegerName,
+	token.SHL:     isIntegerName,
+	token.SHR:     isIntegerName,
+	token.LAND:    isBoolName,
+	token.LOR:     isBoolName,
+}
+
+// unaryOperatorDefined maps the unary operators, but for the address `&x` and the receive
+// `<-ch`, to the predeclared types they are defined on.
+var unaryOperatorDefined = map[token.Token]func(name string) bool{
+	token.ADD: isNumericName,
+	token.SUB: isNumericName,
+	token.XOR: isIntegerName,
+	token.NOT: isBoolName,
+}
+
+func isIntegerName(name string) bool { return signedIntegers[name] || unsignedIntegers[name] }
+func isNumericName(name string) bool { return isIntegerName(name) || floats[name] || complexes[name] }
+func isBoolName(name string) bool    { return name == TypeBool }
+
+// operand is an operand of a binary expression.
+type operand struct {
+	expr ast.Expr
+	typ  Type
+	val  constant.Value // the value of an untyped constant, like `1` or `"a"`, unknown otherwise
+}
+
+func (x *operand) untyped() bool {
+	return x.val.Kind() != constant.Unknown
+}
+
+// kind names the kind of an untyped constant, like "untyped float".
+func (x *operand) kind() string {
+	switch {
+	case x.typ == Type(Rune):
+		return "untyped rune"
+	case x.val.Kind() == constant.Float:
+		return "untyped float"
+	}
+	return "untyped " + FormatGo(x.typ)
+}
+
+// inferBinaryExpr infers the type of a binary expression, like `x + y`, `a == b` or `p && q`.
+//
+// Both operands must have identical types, except for untyped constants, which are converted
+// to the type of the other operand. If both operands are untyped constants, the result takes
+// the kind appearing later in int, rune, float64. Comparisons yield bool, and shifts the type
+// of the left operand.
+//
+// Operands of type parameter type are checked against their constraint: the operator must be
+// defined on every type in its type set. A type variable bound to a constraint in env, like
+// `T: interface{ ~int | ~float64 }`, uses that constraint; unbound type variables allow any type.
+func inferBinaryExpr(expr *ast.BinaryExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	x, err := inferOperand(expr.X, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	y, err := inferOperand(expr.Y, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if isNil(x.typ) || isNil(y.typ) {
+		return inferNilComparison(expr, x, y, env)
+	}
+	if expr.Op == token.SHL || expr.Op == token.SHR {
+		return inferShift(expr, x, y, env, ctx)
+	}
+	// a shift of an untyped constant takes the type of the other operand, like `m & (1 << i)`
+	if untypedShift(expr.X, env) && !y.untyped() {
+		if x.typ, err = InferType(expr.X, env, ctx.Child(WithExpectedType(y.typ))); err != nil {
+			return nil, err
+		}
+	}
+	if untypedShift(expr.Y, env) && !x.untyped() {
+		if y.typ, err = InferType(expr.Y, env, ctx.Child(WithExpectedType(x.typ))); err != nil {
+			return nil, err
+		}
+	}
+
+	typ, err := matchOperands(expr, x, y, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expr.Op {
+	case token.EQL, token.NEQ:
+		if !isComparable(operandConstraint(typ, env)) {
+			return nil, fmt.Errorf("%w: %s (incomparable types in type set of %s)", ErrInvalidOperation, types.ExprString(expr), FormatGo(typ))
+		}
+		return Bool, nil
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if !isOrdered(operandConstraint(typ, env)) {
+			return nil, fmt.Errorf("%w: %s (operator %s not defined on %s)", ErrInvalidOperation, types.ExprString(expr), expr.Op, FormatGo(typ))
+		}
+		return Bool, nil
+	}
+
+	defined, ok := operatorDefined[expr.Op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported binary operator: %s", expr.Op)
+	}
+	if !underlyingIs(operandConstraint(typ, env), defined) {
+		return nil, fmt.Errorf("%w: operator %s not defined on %s (%s)", ErrInvalidOperation, expr.Op, types.ExprString(expr.X), FormatGo(typ))
+	}
+	if (expr.Op == token.QUO || expr.Op == token.REM) && y.untyped() && constant.Sign(y.val) == 0 && (x.untyped() || isInteger(typ)) {
+		return nil, fmt.Errorf("%w: division by zero", ErrInvalidOperation)
+	}
+
+	if x.untyped() && y.untyped() && ctx.ExpectedType != nil && representable(constantOf(expr, env), ctx.ExpectedType, env) {
+		// an untyped constant expression takes the type it is assigned to, like `var f float64 = 1 + 2`
+		return ctx.ExpectedType, nil
+	}
+	return typ, nil
+}
+
+// inferUnaryExpr infers the type of a unary expression, like `-x`, `!ok` or `^mask`, which
+// has the type of its operand. As for binary expressions, the operator must be defined on
+// every type in the type set of an operand of type parameter type, and an untyped
+// constant operand takes the type the expression is assigned to.
+func inferUnaryExpr(expr *ast.UnaryExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	defined, ok := unaryOperatorDefined[expr.Op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported unary operator: %s", expr.Op)
+	}
+	x, err := inferOperand(expr.X, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !underlyingIs(operandConstraint(x.typ, env), defined) {
+		return nil, fmt.Errorf("%w: operator %s not defined on %s (%s)", ErrInvalidOperation, expr.Op, types.ExprString(expr.X), FormatGo(x.typ))
+	}
+	if x.untyped() && ctx.ExpectedType != nil && representable(constantOf(expr, env), ctx.ExpectedType, env) {
+		return ctx.ExpectedType, nil
+	}
+	return x.typ, nil
+}
+
+// inferAddress infers the type of `&x`, a pointer to the type of x, which must be a
+// variable, a field selector, an element of a slice or array, or an indirection.
+// The addresses of composite literals are inferred by InferType.
+func inferAddress(expr *ast.UnaryExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	switch x := ast.Unparen(expr.X).(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.StarExpr:
+	case *ast.IndexExpr:
+		xt, err := InferType(x.X, env, ctx.Child())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := coreType(xt, env).(*MapType); ok {
+			return nil, fmt.Errorf("%w: cannot take address of %s (map index expression)", ErrInvalidOperation, types.ExprString(x))
+		}
+	default:
+		return nil, fmt.Errorf("%w: cannot take address of %s", ErrInvalidOperation, types.ExprString(expr.X))
+	}
+	if err := checkValueExpr(expr.X, env); err != nil {
+		return nil, err
+	}
+	t, err := InferType(expr.X, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	return &PointerType{Base: t}, nil
+}
+
+// inferOperand infers the type of a single operand of a binary expression.
+func inferOperand(expr ast.Expr, env TypeEnv, ctx *InferenceContext) (*operand, error) {
+	if err := checkValueExpr(expr, env); err != nil {
+		return nil, err
+	}
+	t, err := InferType(expr, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	if isNoValue(t) {
+		return nil, fmt.Errorf("operand %s: %w", types.ExprString(expr), ErrNoValueUsed)
+	}
+	if tuple, ok := t.(*TupleType); ok {
+		return nil, fmt.Errorf("multiple-value %s (%d values) in single-value context", types.ExprString(expr), len(tuple.Types))
+	}
+	return &operand{expr: expr, typ: unalias(t), val: constantOf(expr, env)}, nil
+}
+
+// matchOperands returns the type both operands of expr are converted to.
+func matchOperands(expr *ast.BinaryExpr, x, y *operand, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	switch {
+	case x.untyped() && y.untyped():
+		if (x.val.Kind() == constant.String) != (y.val.Kind() == constant.String) {
+			return nil, fmt.Errorf("%w: %s (mismatched types %s and %s)", ErrInvalidOperation, types.ExprString(expr), x.kind(), y.kind())
+		}
+		if untypedRank(y.typ) > untypedRank(x.typ) {
+			return y.typ, nil
+		}
+		return x.typ, nil
+	case x.untyped():
+		return convertUntyped(x, y.typ, env)
+	case y.untyped():
+		return convertUntyped(y, x.typ, env)
+	}
+	if TypesEqual(x.typ, y.typ) {
+		return x.typ, nil
+	}
+	if expr.Op == token.EQL || expr.Op == token.NEQ {
+		// a value compares with a value of an interface type it implements, like `err == ErrEOF`
+		if _, ok := interfaceOf(x.typ); ok && assignable(x.typ, y.typ, env, ctx) == nil {
+			return x.typ, nil
+		}
+		if _, ok := interfaceOf(y.typ); ok && assignable(y.typ, x.typ, env, ctx) == nil {
+			return y.typ, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s (mismatched types %s and %s)", ErrInvalidOperation, types.ExprString(expr), FormatGo(x.typ), FormatGo(y.typ))
+}
+
+// inferNilComparison infers a comparison with nil, like `p == nil` or `err != nil`. Nil can
+// only be compared for equality, with a value of a type nil is a value of, see isNilable,
+// or of a type parameter whose core type is one, like `m == nil` for `M ~map[K]V`.
+func inferNilComparison(expr *ast.BinaryExpr, x, y *operand, env TypeEnv) (Type, error) {
+	if expr.Op != token.EQL && expr.Op != token.NEQ {
+		return nil, fmt.Errorf("%w: operator %s not defined on nil", ErrInvalidOperation, expr.Op)
+	}
+	if isNil(x.typ) && isNil(y.typ) {
+		return nil, fmt.Errorf("%w: %s (operator %s not defined on nil)", ErrInvalidOperation, types.ExprString(expr), expr.Op)
+	}
+	nilable := func(t Type) bool {
+		if _, ok := t.(*TypeVariable); ok {
+			core := coreType(t, env)
+			return core != nil && isNilable(core)
+		}
+		return isNilable(t)
+	}
+	if !nilable(x.typ) || !nilable(y.typ) {
+		return nil, fmt.Errorf("%w: %s (mismatched types %s and %s)", ErrInvalidOperation, types.ExprString(expr), FormatGo(x.typ), FormatGo(y.typ))
+	}
+	return Bool, nil
+}
+
+// untypedRank orders the default types of untyped numeric constants.
+func untypedRank(t Type) int {
+	switch t {
+	case Rune:
+		return 1
+	case Float64:
+		return 2
+	}
+	return 0
+}
+
+// convertUntyped converts the untyped constant x to the type t of the other operand, or of
+// the target of an assignment. Converted to an interface, like in `var v any = 1`, the
+// constant takes its default type, which must implement the interface.
+func convertUntyped(x *operand, t Type, env TypeEnv) (Type, error) {
+	if _, ok := interfaceOf(unalias(resolve(t, env))); ok {
+		if err := assignable(t, x.typ, env, nil); err != nil {
+			return nil, fmt.Errorf("cannot use %s (%s constant) as %s value: %w", types.ExprString(x.expr), x.kind(), FormatGo(t), err)
+		}
+		return t, nil
+	}
+	if !representable(x.val, t, env) {
+		return nil, fmt.Errorf("cannot convert %s (%s constant) to type %s", types.ExprString(x.expr), x.kind(), FormatGo(t))
+	}
+	return t, nil
+}
+
+// representable reports whether the constant val can be represented by a value of type t.
+// For a type parameter, the constant must be representable by every type in its type set.
+func representable(val constant.Value, t Type, env TypeEnv) bool {
+	var accept func(name string) bool
+	switch val.Kind() {
+	case constant.String:
+		accept = func(name string) bool { return name == TypeString }
+	case constant.Bool:
+		accept = isBoolName
+	case constant.Int:
+		accept = isNumericName
+	case constant.Float:
+		// floats with an integral value, like `2.0`, can be integers too
+		integral := constant.ToInt(val).Kind() == constant.Int
+		accept = func(name string) bool { return floats[name] || complexes[name] || integral && isIntegerName(name) }
+	case constant.Complex:
+		accept = func(name string) bool { return complexes[name] }
+	default:
+		return false
+	}
+	return underlyingIs(operandConstraint(t, env), accept)
+}
+
+// inferShift infers the type of a shift `x << y` or `x >> y`.
+// The shift count must be an integer; the result has the type of the shifted operand.
+func inferShift(expr *ast.BinaryExpr, x, y *operand, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if y.untyped() {
+		count := constant.ToInt(y.val)
+		if count.Kind() != constant.Int || constant.Sign(count) < 0 {
+			return nil, fmt.Errorf("%w: invalid shift count %s", ErrInvalidOperation, types.ExprString(expr.Y))
+		}
+	} else if !underlyingIs(operandConstraint(y.typ, env), isIntegerName) {
+		return nil, fmt.Errorf("%w: shift count %s must be integer", ErrInvalidOperation, types.ExprString(expr.Y))
+	}
+
+	if x.untyped() {
+		if constant.ToInt(x.val).Kind() != constant.Int {
+			return nil, fmt.Errorf("%w: shifted operand %s must be integer", ErrInvalidOperation, types.ExprString(expr.X))
+		}
+		// shifted by a variable count, the constant takes the type it is assigned to, like
+		// uint64 in `var mask uint64 = 1 << n`
+		if !y.untyped() && ctx.ExpectedType != nil && underlyingIs(operandConstraint(ctx.ExpectedType, env), isIntegerName) {
+			return ctx.ExpectedType, nil
+		}
+		return Int, nil
+	}
+	if !underlyingIs(operandConstraint(x.typ, env), isIntegerName) {
+		return nil, fmt.Errorf("%w: shifted operand %s (%s) must be integer", ErrInvalidOperation, types.ExprString(expr.X), FormatGo(x.typ))
+	}
+	return x.typ, nil
+}
+
+// untypedShift reports whether expr is a shift of an untyped constant by a count that is
+// not constant, like `1 << i`, whose type is the one it is used as.
+func untypedShift(expr ast.Expr, env TypeEnv) bool {
+	shift, ok := ast.Unparen(expr).(*ast.BinaryExpr)
+	if !ok || shift.Op != token.SHL && shift.Op != token.SHR {
+		return false
+	}
+	return constantOf(shift.X, env).Kind() != constant.Unknown && constantOf(shift.Y, env).Kind() == constant.Unknown
+}
+
+// operandConstraint returns the constraint a type variable t is bound to in env, that of
+// a type parameter in scope, see constraintKey, `any` if it has none, or t itself if it is
+// not a type variable.
+func operandConstraint(t Type, env TypeEnv) Type {
+	tv, ok := t.(*TypeVariable)
+	if !ok {
+		return t
+	}
+	if tc, ok := env[tv.Name].(*TypeConstraint); ok {
+		return tc
+	}
+	if tc, ok := env[constraintKey(tv.Name)].(*TypeConstraint); ok && isRigid(tv, env) {
+		return tc
+	}
+	return Any
+}
+
+// constraintKey returns the name the constraint of the type parameter name is bound to in
+// the scope of a function body, where the name itself denotes the type parameter, see
+// funcScope. Like the shadowed names of short variable declarations, it is not a valid
+// identifier, so that it cannot clash with a declaration.
+func constraintKey(name string) string {
+	return name + "·constraint"
+}
+
+// constantBinaryOp evaluates the binary operation on two constants,
+// or returns an unknown value if the operation is not defined on them.
+func constantBinaryOp(op token.Token, x, y constant.Value) constant.Value {
+	unknown := constant.MakeUnknown()
+	if x.Kind() == constant.Unknown || y.Kind() == constant.Unknown {
+		return unknown
+	}
+	numeric := func(v constant.Value) bool {
+		return v.Kind() == constant.Int || v.Kind() == constant.Float || v.Kind() == constant.Complex
+	}
+
+	switch op {
+	case token.SHL, token.SHR:
+		s, ok := constant.Uint64Val(constant.ToInt(y))
+		if x = constant.ToInt(x); !ok || x.Kind() != constant.Int {
+			return unknown
+		}
+		return constant.Shift(x, op, uint(s))
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if x.Kind() != y.Kind() && !(numeric(x) && numeric(y)) {
+			return unknown
+		}
+		return constant.MakeBool(constant.Compare(x, op, y))
+	case token.LAND, token.LOR:
+		if x.Kind() != constant.Bool || y.Kind() != constant.Bool {
+			return unknown
+		}
+	case token.ADD:
+		if x.Kind() == constant.String && y.Kind() == constant.String {
+			break
+		}
+		fallthrough
+	case token.SUB, token.MUL:
+		if !numeric(x) || !numeric(y) {
+			return unknown
+		}
+	case token.QUO:
+		if !numeric(x) || !numeric(y) || constant.Sign(y) == 0 {
+			return unknown
+		}
+		if x.Kind() == constant.Int && y.Kind() == constant.Int {
+			op = token.QUO_ASSIGN // integer division
+		}
+	case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+		if x.Kind() != constant.Int || y.Kind() != constant.Int || op == token.REM && constant.Sign(y) == 0 {
+			return unknown
+		}
+	default:
+		return unknown
+	}
+	return constant.BinaryOp(x, op, y)
+}
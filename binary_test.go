@@ -0,0 +1,163 @@
+package generic
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInferBinaryExpr(t *testing.T) {
+	age := &NamedType{Name: "Age", Underlying: Int}
+	number := &TypeConstraint{Types: []Type{&ApproxType{Base: Int}, &ApproxType{Base: Float64}}}
+	env := TypeEnv{
+		"i":    Int,
+		"j":    Int,
+		"u":    Uint8,
+		"f":    Float64,
+		"s":    String,
+		"b":    Bool,
+		"a":    age,
+		"p":    &PointerType{Base: Int},
+		"xs":   &SliceType{ElementType: Int},
+		"m":    &MapType{KeyType: String, ValueType: Int},
+		"t":    &TypeVariable{Name: "T"},
+		"T":    number,
+		"k":    &TypeVariable{Name: "K"},
+		"n":    number,
+		"none": &FunctionType{ReturnType: &NoValueType{}},
+	}
+
+	tests := []struct {
+		src     string
+		want    Type
+		wantErr bool
+	}{
+		// arithmetic
+		{src: "i + j", want: Int},
+		{src: "i - 1", want: Int},
+		{src: "2 * f", want: Float64},
+		{src: "f / 2.5", want: Float64},
+		{src: "i % j", want: Int},
+		{src: "u &^ 1", want: Uint8},
+		{src: "a + 1", want: age},
+		{src: "(i + j) * 2", want: Int},
+		{src: "i + f", wantErr: true},
+		{src: "a + i", wantErr: true},
+		{src: "f % 2", wantErr: true},
+		{src: "i + 1.5", wantErr: true},
+		{src: "i + 2.0", want: Int},
+		{src: "i / 0", wantErr: true},
+		{src: "p + 1", wantErr: true},
+
+		// untyped constants
+		{src: "1 + 2", want: Int},
+		{src: "1 + 2.5", want: Float64},
+		{src: "'a' + 1", want: Rune},
+		{src: `"a" + "b"`, want: String},
+		{src: `"a" + 1`, wantErr: true},
+		{src: "1 / 0", wantErr: true},
+
+		// string concatenation
+		{src: `s + "!"`, want: String},
+		{src: "s + s", want: String},
+		{src: "s - s", wantErr: true},
+
+		// comparison
+		{src: "i == j", want: Bool},
+		{src: "i < 10", want: Bool},
+		{src: `s >= "a"`, want: Bool},
+		{src: "p == p", want: Bool},
+		{src: "b != b", want: Bool},
+		{src: "b < b", wantErr: true},
+		{src: "p < p", wantErr: true},
+		{src: "xs == xs", wantErr: true},
+		{src: "m == m", wantErr: true},
+		{src: "i == s", wantErr: true},
+
+		// logical
+		{src: "b && b", want: Bool},
+		{src: "i < j || b", want: Bool},
+		{src: "i && b", wantErr: true},
+
+		// shifts
+		{src: "i << 2", want: Int},
+		{src: "u >> i", want: Uint8},
+		{src: "1 << i", want: Int},
+		{src: "f << 1", wantErr: true},
+		{src: "i << f", wantErr: true},
+		{src: "i << -1", wantErr: true},
+
+		// type parameters
+		{src: "t + t", want: &TypeVariable{Name: "T"}},
+		{src: "t * 2", want: &TypeVariable{Name: "T"}},
+		{src: "t < t", want: Bool},
+		{src: "t == t", want: Bool},
+		{src: "t % t", wantErr: true},
+		{src: "t + i", wantErr: true},
+		{src: "t + 1.5", wantErr: true},
+		{src: "n - n", want: number},
+		{src: "k + k", wantErr: true},
+		{src: "k == k", wantErr: true},
+
+		// operands without a single value
+		{src: "none() + 1", wantErr: true},
+		{src: "x + 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("InferType() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if !TypesEqual(got, tt.want) {
+				t.Errorf("InferType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferBinaryExprExpectedType(t *testing.T) {
+	ctx := NewInferenceContext(WithExpectedType(Float64))
+	got, err := InferType(mustParseExpr(t, "1 + 2"), TypeEnv{}, ctx)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	if got != Type(Float64) {
+		t.Errorf("InferType() = %v, want %v", got, Float64)
+	}
+}
+
+func TestInferBinaryExprErrors(t *testing.T) {
+	env := TypeEnv{"i": Int, "s": String}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"i + s", "invalid operation: i + s (mismatched types int and string)"},
+		{"s % s", "invalid operation: operator % not defined on s (string)"},
+		{"i / 0", "invalid operation: division by zero"},
+		{`"a" + 1`, `invalid operation: "a" + 1 (mismatched types untyped string and untyped int)`},
+		{"i + 1.5", "cannot convert 1.5 (untyped float constant) to type int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if err == nil {
+				t.Fatalf("InferType() error = nil, want %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("InferType() error = %q, want %q", err, tt.want)
+			}
+			if tt.src != "i + 1.5" && !errors.Is(err, ErrInvalidOperation) {
+				t.Errorf("InferType() error = %v, want ErrInvalidOperation", err)
+			}
+		})
+	}
+}
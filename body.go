@@ -0,0 +1,834 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+var (
+	// ErrMissingReturn is reported for a function with results whose body can end
+	// without a return statement.
+	ErrMissingReturn = errors.New("missing return")
+
+	// ErrUnreachableCode is the `go vet` warning for a statement following a terminating
+	// statement in the same block, like code after a return.
+	ErrUnreachableCode = errors.New("unreachable code")
+)
+
+// CheckFuncBody checks the body of the function declaration fn in env, which holds the
+// declarations the body may use. The parameters, named results and receiver are bound in
+// a scope of their own, along with the type parameters, and every statement of the body
+// is checked in turn, each block in a scope nested in the enclosing one: the values of
+// return statements must match the results of fn, and a function with results must end
+// in a terminating statement, like a return or a call to panic. The bodies of the
+// function literals are checked too, when they are inferred.
+//
+// Statements following a terminating statement are reported as ErrUnreachableCode warnings,
+// see IsWarning. Statements the checker does not handle, like goto, are skipped. With
+// WithErrorLimit, all the diagnostics of the body are returned, joined.
+func CheckFuncBody(fn *ast.FuncDecl, env TypeEnv, ctx *InferenceContext) error {
+	if fn.Body == nil {
+		return nil
+	}
+	scope := funcScope(fn, env)
+	sig, err := buildSignature(fn.Type, scope, ctx.Child())
+	if err != nil {
+		return fmt.Errorf("function %s: %v", fn.Name.Name, err)
+	}
+
+	c := &bodyChecker{sig: sig.functionType(), errs: ctx.errorList()}
+	b := newBlock(scope)
+	if fn.Recv != nil {
+		for _, field := range fn.Recv.List {
+			recv, err := InferType(field.Type, scope, ctx.Child())
+			if err != nil {
+				return err
+			}
+			b.declareField(field, recv)
+		}
+	}
+	c.funcBody(fn.Type, sig, fn.Body, b, ctx)
+	return c.errs.err()
+}
+
+// checkFuncLit checks the body of the function literal lit of signature sig, in a scope
+// nested in env, like CheckFuncBody.
+func checkFuncLit(lit *ast.FuncLit, sig *signature, env TypeEnv, ctx *InferenceContext) error {
+	if lit.Body == nil {
+		return nil
+	}
+	c := &bodyChecker{sig: sig.functionType(), errs: ctx.errorList()}
+	b := newBlock(env)
+	defer b.end()
+	c.funcBody(lit.Type, sig, lit.Body, b, ctx)
+	return c.errs.err()
+}
+
+// funcBody checks the body of a function of type ft and signature sig in scope b, in
+// which its parameters and results are declared.
+func (c *bodyChecker) funcBody(ft *ast.FuncType, sig *signature, body *ast.BlockStmt, b *block, ctx *InferenceContext) {
+	var fields []*ast.Field
+	if ft.Params != nil {
+		fields = ft.Params.List
+	}
+	fieldTypes := sig.Params
+	if ft.Results != nil {
+		fields = append(fields[:len(fields):len(fields)], ft.Results.List...)
+		fieldTypes = append(fieldTypes[:len(fieldTypes):len(fieldTypes)], sig.Results...)
+		for _, field := range ft.Results.List {
+			c.namedResults = c.namedResults || len(field.Names) > 0
+		}
+	}
+	for _, field := range fields {
+		b.declareField(field, fieldTypes[0])
+		fieldTypes = fieldTypes[max(len(field.Names), 1):]
+	}
+
+	c.stmts(body.List, b, ctx)
+	if !c.stopped && len(sig.Results) > 0 && !isTerminating(body) {
+		c.errs.add(typeError(body, ctx, &TypeError{Pos: body.Rbrace, Err: ErrMissingReturn}))
+	}
+}
+
+// funcScope returns the scope of the body of fn: a copy of env in which the type parameters
+// of fn, and those of its receiver, denote themselves, see typeParamScope, and their
+// constraints are bound, see operandConstraint.
+func funcScope(fn *ast.FuncDecl, env TypeEnv) TypeEnv {
+	scope := make(TypeEnv, len(env))
+	for name, t := range env {
+		scope[name] = t
+	}
+	names := declTypeParams(fn)
+	if len(names) == 0 {
+		return scope
+	}
+	for _, name := range names {
+		scope[name.Name] = &TypeVariable{Name: name.Name}
+	}
+
+	var params TypeParamList
+	var vars []Type
+	if fn.Recv != nil && len(fn.Recv.List) == 1 {
+		// the type parameters of the receiver may be renamed, like `func (s *Stack[E]) Push(v E)`
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		base, recvNames := receiverBase(recv)
+		if gt, ok := env[base.Name].(*GenericType); ok && len(gt.Params) == len(recvNames) {
+			for _, name := range recvNames {
+				vars = append(vars, &TypeVariable{Name: name.Name})
+			}
+			declared := gt.Params.Vars()
+			for i, p := range gt.Params {
+				p.Name = recvNames[i].Name
+				if p.Constraint != nil {
+					p.Constraint, _ = substituteTypeParams(p.Constraint, declared, vars, NewTypeVisitor()).(*TypeConstraint)
+				}
+				params = append(params, p)
+			}
+		}
+	}
+	if fn.Type.TypeParams != nil {
+		// their errors are reported with the signature
+		if list, err := typeParamList(fn.Type.TypeParams, env); err == nil {
+			params = append(params, list...)
+		}
+	}
+	for _, p := range params {
+		if p.Constraint != nil {
+			scope[constraintKey(p.Name)] = p.Constraint
+		}
+	}
+	return scope
+}
+
+// inferStmt checks a block or if statement in a scope nested in env. The return
+// statements take their results from the function type expected by ctx, if any.
+func inferStmt(stmt ast.Stmt, env TypeEnv, ctx *InferenceContext) error {
+	sig, _ := ctx.ExpectedType.(*FunctionType)
+	c := &bodyChecker{sig: sig, errs: ctx.errorList()}
+	b := newBlock(env)
+	defer b.end()
+	c.stmts([]ast.Stmt{stmt}, b, ctx)
+	return c.errs.err()
+}
+
+// bodyChecker checks the statements of a function body, collecting their errors.
+type bodyChecker struct {
+	sig          *FunctionType // nil outside of a function
+	namedResults bool
+	errs         *errorList
+	stopped      bool // the error limit was reached
+}
+
+// block is the scope of a block. The blocks of a function share the environment of its
+// body, env, in which each block binds the names it declares until it ends, see end, so
+// that the environment is not copied for every block.
+type block struct {
+	env TypeEnv
+
+	// declared holds the names declared by the block itself, which a short variable
+	// declaration redeclares rather than shadows, along with the bindings of the
+	// enclosing blocks they shadow, nil if none.
+	declared map[string]Type
+}
+
+// newBlock returns the scope of a block nested in env, which holds the names of the
+// enclosing blocks. The names b declares are bound in env until b ends.
+func newBlock(env TypeEnv) *block {
+	return &block{env: env, declared: make(map[string]Type)}
+}
+
+// nested returns the scope of a block nested in b.
+func (b *block) nested() *block {
+	return newBlock(b.env)
+}
+
+// isDeclared reports whether name is declared by b itself.
+func (b *block) isDeclared(name string) bool {
+	_, ok := b.declared[name]
+	return ok
+}
+
+// declare records that b declares name, which the caller binds in b.env next.
+func (b *block) declare(name string) {
+	if !b.isDeclared(name) {
+		b.declared[name] = b.env[name]
+	}
+}
+
+// bind declares the variable name of type t.
+func (b *block) bind(name string, t Type) {
+	if name == "_" {
+		return
+	}
+	b.declare(name)
+	b.env[name] = &VarObj{Name: name, Type: t}
+}
+
+// end ends the scope of b: the names it declares are no longer bound, and those of the
+// enclosing blocks they shadow are bound again.
+func (b *block) end() {
+	for name, outer := range b.declared {
+		if outer == nil {
+			delete(b.env, name)
+		} else {
+			b.env[name] = outer
+		}
+	}
+}
+
+// declareField declares the names of a parameter, result or receiver field of type t.
+func (b *block) declareField(field *ast.Field, t Type) {
+	for _, name := range field.Names {
+		b.bind(name.Name, t)
+	}
+}
+
+// block checks the statements of a block in a scope nested in b.
+func (c *bodyChecker) block(list []ast.Stmt, b *block, ctx *InferenceContext) {
+	scope := b.nested()
+	defer scope.end()
+	c.stmts(list, scope, ctx)
+}
+
+// stmts checks the statements of a block in scope b, until the error limit is reached.
+// The first statement following a terminating statement is unreachable; a labeled one
+// may be the target of a goto.
+func (c *bodyChecker) stmts(list []ast.Stmt, b *block, ctx *InferenceContext) {
+	terminated := false
+	for _, stmt := range list {
+		if _, labeled := stmt.(*ast.LabeledStmt); labeled {
+			terminated = false
+		} else if terminated {
+			if !c.report(stmt, ctx, ErrUnreachableCode) {
+				return
+			}
+			terminated = false
+		}
+		c.check(stmt, b, ctx)
+		if c.stopped {
+			return
+		}
+		terminated = terminated || isTerminating(stmt)
+	}
+}
+
+// check checks the statement stmt in scope b, reporting its error.
+func (c *bodyChecker) check(stmt ast.Stmt, b *block, ctx *InferenceContext) {
+	stmtCtx := ctx.Child(WithExpectedType(nil))
+	c.errs.nest(stmtCtx)
+	if err := c.stmt(stmt, b, stmtCtx); err != nil {
+		c.report(stmt, stmtCtx, err)
+	}
+}
+
+// stmt checks a single statement in scope b. The statements made of others, like blocks
+// and loops, report the errors of the nested statements themselves.
+func (c *bodyChecker) stmt(stmt ast.Stmt, b *block, ctx *InferenceContext) error {
+	switch s := stmt.(type) {
+	case *ast.LabeledStmt:
+		return c.stmt(s.Stmt, b, ctx)
+	case *ast.BlockStmt:
+		c.block(s.List, b, ctx)
+	case *ast.IfStmt:
+		c.ifStmt(s, b, ctx)
+	case *ast.ForStmt:
+		c.forStmt(s, b, ctx)
+	case *ast.RangeStmt:
+		c.rangeStmt(s, b, ctx)
+	case *ast.SwitchStmt:
+		c.switchStmt(s, b, ctx)
+	case *ast.TypeSwitchStmt:
+		c.typeSwitchStmt(s, b, ctx)
+	case *ast.SelectStmt:
+		for _, clause := range s.Body.List {
+			clause := clause.(*ast.CommClause)
+			scope := b.nested()
+			if clause.Comm != nil {
+				c.check(clause.Comm, scope, ctx)
+			}
+			if !c.stopped {
+				c.stmts(clause.Body, scope, ctx)
+			}
+			scope.end()
+			if c.stopped {
+				break
+			}
+		}
+	case *ast.ReturnStmt:
+		if len(s.Results) == 0 && c.sig != nil && (c.sig.ReturnType == nil || c.namedResults) {
+			return nil // a bare return of the named results
+		}
+		_, err := InferType(s, b.env, ctx.Child(WithExpectedType(c.sig)))
+		return err
+	case *ast.ExprStmt:
+		return inferExprStmt(s, b.env, ctx)
+	case *ast.GoStmt:
+		return inferCallStmt(s.Call, "go", b.env, ctx)
+	case *ast.DeferStmt:
+		return inferCallStmt(s.Call, "defer", b.env, ctx)
+	case *ast.IncDecStmt:
+		return inferIncDecStmt(s, b.env, ctx)
+	case *ast.AssignStmt:
+		return c.assign(s, b, ctx)
+	case *ast.SendStmt:
+		_, err := InferType(s, b.env, ctx)
+		return err
+	case *ast.DeclStmt:
+		if gen, ok := s.Decl.(*ast.GenDecl); ok {
+			for _, spec := range gen.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						b.declare(name.Name)
+					}
+				case *ast.TypeSpec:
+					b.declare(spec.Name.Name)
+				}
+			}
+		}
+		_, err := InferType(s, b.env, ctx)
+		return err
+	}
+	// the empty and branch statements have nothing to check, and the others are not
+	// handled yet
+	return nil
+}
+
+// ifStmt checks an if statement in scope b. The init statement declares its names in
+// a scope of the statement, which the condition and both branches see, and the condition
+// must be a boolean. The branches are checked even if the condition is not.
+func (c *bodyChecker) ifStmt(stmt *ast.IfStmt, b *block, ctx *InferenceContext) {
+	scope := b.nested()
+	defer scope.end()
+	if stmt.Init != nil {
+		if err := c.stmt(stmt.Init, scope, ctx); err != nil && !c.report(stmt.Init, ctx, err) {
+			return
+		}
+	}
+	if err := checkCondition(stmt.Cond, scope.env, ctx, "if"); err != nil && !c.report(stmt.Cond, ctx, err) {
+		return
+	}
+	c.block(stmt.Body.List, scope, ctx)
+	if stmt.Else != nil && !c.stopped {
+		if err := c.stmt(stmt.Else, scope, ctx); err != nil {
+			c.report(stmt.Else, ctx, err)
+		}
+	}
+}
+
+// forStmt checks a for statement in scope b, whose init statement declares its names in
+// a scope of the statement, like that of an if statement. The condition, if any, must be
+// a boolean.
+func (c *bodyChecker) forStmt(stmt *ast.ForStmt, b *block, ctx *InferenceContext) {
+	scope := b.nested()
+	defer scope.end()
+	if stmt.Init != nil {
+		if err := c.stmt(stmt.Init, scope, ctx); err != nil && !c.report(stmt.Init, ctx, err) {
+			return
+		}
+	}
+	if stmt.Cond != nil {
+		if err := checkCondition(stmt.Cond, scope.env, ctx, "for"); err != nil && !c.report(stmt.Cond, ctx, err) {
+			return
+		}
+	}
+	if stmt.Post != nil {
+		if err := c.stmt(stmt.Post, scope, ctx); err != nil && !c.report(stmt.Post, ctx, err) {
+			return
+		}
+	}
+	c.block(stmt.Body.List, scope, ctx)
+}
+
+// rangeStmt checks a range loop in scope b. The iteration variables take the types of the
+// values produced by the range expression, see rangeTypes: a short variable declaration
+// declares them in a scope of the statement, and an assignment must be assignable to
+// them. The body is not checked if the range expression is invalid, since the types of
+// the variables are not known.
+func (c *bodyChecker) rangeStmt(stmt *ast.RangeStmt, b *block, ctx *InferenceContext) {
+	scope := b.nested()
+	defer scope.end()
+	key, value, err := rangeTypes(stmt.X, b.env, ctx)
+	if err != nil {
+		c.report(stmt.X, ctx, err)
+		return
+	}
+	vars := []ast.Expr{stmt.Key, stmt.Value}
+	varTypes := []Type{key, value}
+	for i, v := range vars {
+		if v == nil {
+			continue
+		}
+		if varTypes[i] == nil {
+			err := fmt.Errorf("range over %s permits only one iteration variable", types.ExprString(stmt.X))
+			if !c.report(v, ctx, err) {
+				return
+			}
+			continue
+		}
+		if stmt.Tok == token.DEFINE {
+			if ident, ok := v.(*ast.Ident); ok {
+				scope.bind(ident.Name, varTypes[i])
+			}
+			continue
+		}
+		target, err := inferAssignTarget(v, scope.env, ctx)
+		if err == nil && target != nil {
+			if err = assignable(target, varTypes[i], scope.env, ctx); err != nil {
+				err = fmt.Errorf("cannot assign %s value to %s (type %s) in range: %w", FormatGo(varTypes[i]), types.ExprString(v), FormatGo(target), err)
+			}
+		}
+		if err != nil && !c.report(v, ctx, err) {
+			return
+		}
+	}
+	c.block(stmt.Body.List, scope, ctx)
+}
+
+// rangeTypes returns the types of the iteration variables of a range loop over x: the
+// indices and elements of a slice, an array or a pointer to an array, the byte offsets
+// and runes of a string, the keys and values of a map, the elements of a channel, the
+// integers up to an integer, or the values a function iterator yields, like
+// `func(yield func(K, V) bool)`. The second type is nil if there is none.
+func rangeTypes(x ast.Expr, env TypeEnv, ctx *InferenceContext) (key, value Type, err error) {
+	if err := checkValueExpr(x, env); err != nil {
+		return nil, nil, err
+	}
+	t, err := InferType(x, env, ctx.Child())
+	if err != nil {
+		return nil, nil, err
+	}
+	switch core := coreType(t, env).(type) {
+	case *SliceType:
+		return Int, core.ElementType, nil
+	case *ArrayType:
+		return Int, core.ElementType, nil
+	case *PointerType:
+		if at, ok := underlying(core.Base).(*ArrayType); ok {
+			return Int, at.ElementType, nil
+		}
+	case *MapType:
+		return core.KeyType, core.ValueType, nil
+	case *ChanType:
+		if core.Dir == SendOnly {
+			return nil, nil, fmt.Errorf("%w: range over send-only channel %s", ErrInvalidOperation, types.ExprString(x))
+		}
+		return core.ElementType, nil, nil
+	case *TypeConstant:
+		switch {
+		case core.Name == TypeString:
+			return Int, Rune, nil
+		case isIntegerName(core.Name):
+			return t, nil, nil
+		}
+	case *FunctionType:
+		if yield, ok := iteratorYield(core); ok {
+			switch len(yield.ParamTypes) {
+			case 0:
+				return nil, nil, nil
+			case 1:
+				return yield.ParamTypes[0], nil, nil
+			}
+			return yield.ParamTypes[0], yield.ParamTypes[1], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("cannot range over %s (variable of type %s)", types.ExprString(x), FormatGo(t))
+}
+
+// iteratorYield returns the yield function of the function iterator ft, which takes a
+// single function of up to two parameters returning a bool, and has no results.
+func iteratorYield(ft *FunctionType) (*FunctionType, bool) {
+	if len(ft.ParamTypes) != 1 || ft.ReturnType != nil {
+		return nil, false
+	}
+	yield, ok := underlying(ft.ParamTypes[0]).(*FunctionType)
+	if !ok || len(yield.ParamTypes) > 2 || !TypesEqual(yield.ReturnType, Bool) {
+		return nil, false
+	}
+	return yield, true
+}
+
+// switchStmt checks an expression switch in scope b. The init statement declares its
+// names in a scope of the statement, and each clause has a scope of its own. The values
+// of the cases must be comparable to the tag, or be booleans if there is no tag.
+func (c *bodyChecker) switchStmt(stmt *ast.SwitchStmt, b *block, ctx *InferenceContext) {
+	scope := b.nested()
+	defer scope.end()
+	if stmt.Init != nil {
+		if err := c.stmt(stmt.Init, scope, ctx); err != nil && !c.report(stmt.Init, ctx, err) {
+			return
+		}
+	}
+	tagOK := true
+	if stmt.Tag != nil {
+		err := checkValueExpr(stmt.Tag, scope.env)
+		if err == nil {
+			_, err = InferType(stmt.Tag, scope.env, ctx.Child())
+		}
+		// the cases are not compared to an invalid tag
+		if tagOK = err == nil; !tagOK && !c.report(stmt.Tag, ctx, err) {
+			return
+		}
+	}
+	for _, clause := range stmt.Body.List {
+		clause := clause.(*ast.CaseClause)
+		for _, value := range clause.List {
+			var err error
+			switch {
+			case stmt.Tag == nil:
+				err = checkCondition(value, scope.env, ctx, "switch")
+			case tagOK:
+				// the case matches if it is equal to the tag
+				_, err = InferType(&ast.BinaryExpr{X: stmt.Tag, OpPos: value.Pos(), Op: token.EQL, Y: value}, scope.env, ctx.Child())
+			}
+			if err != nil && !c.report(value, ctx, err) {
+				return
+			}
+		}
+		c.block(clause.Body, scope, ctx)
+		if c.stopped {
+			return
+		}
+	}
+}
+
+// typeSwitchStmt checks a type switch in scope b. The guard, `x.(type)`, must be of an
+// interface type, and the types of the cases must implement it if they are not interfaces.
+// The variable declared by the guard, like v in `switch v := x.(type)`, has the type of
+// the case in the clauses listing a single type, and that of x otherwise.
+func (c *bodyChecker) typeSwitchStmt(stmt *ast.TypeSwitchStmt, b *block, ctx *InferenceContext) {
+	scope := b.nested()
+	defer scope.end()
+	if stmt.Init != nil {
+		if err := c.stmt(stmt.Init, scope, ctx); err != nil && !c.report(stmt.Init, ctx, err) {
+			return
+		}
+	}
+	var guard ast.Expr
+	var name string
+	switch s := stmt.Assign.(type) {
+	case *ast.AssignStmt:
+		guard = s.Rhs[0]
+		name = s.Lhs[0].(*ast.Ident).Name
+	case *ast.ExprStmt:
+		guard = s.X
+	}
+	xt, err := InferType(guard, scope.env, ctx.Child())
+	if err != nil {
+		c.report(guard, ctx, err)
+		return
+	}
+	for _, clause := range stmt.Body.List {
+		clause := clause.(*ast.CaseClause)
+		vt := xt
+		for _, expr := range clause.List {
+			if ident, ok := expr.(*ast.Ident); ok && ident.Name == "nil" {
+				continue
+			}
+			t, err := typeSwitchCase(expr, xt, scope.env, ctx)
+			if err != nil {
+				if !c.report(expr, ctx, err) {
+					return
+				}
+				continue
+			}
+			if len(clause.List) == 1 {
+				vt = t
+			}
+		}
+		body := scope.nested()
+		if name != "" {
+			body.bind(name, vt)
+		}
+		c.stmts(clause.Body, body, ctx)
+		body.end()
+		if c.stopped {
+			return
+		}
+	}
+}
+
+// typeSwitchCase infers the type of a case of a type switch on a value of type xt, which
+// the type must implement unless it is an interface.
+func typeSwitchCase(expr ast.Expr, xt Type, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if err := checkTypeExpr(expr, env); err != nil {
+		return nil, err
+	}
+	t, err := InferType(expr, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := interfaceOf(t); ok {
+		return t, nil
+	}
+	if err := assignable(xt, t, env, ctx); err != nil {
+		return nil, fmt.Errorf("impossible type switch case: %s cannot have dynamic type %s: %w", FormatGo(xt), FormatGo(t), err)
+	}
+	return t, nil
+}
+
+// report records the error err of node, reporting whether the check goes on.
+func (c *bodyChecker) report(node ast.Node, ctx *InferenceContext, err error) bool {
+	c.stopped = !c.errs.add(typeError(node, ctx, err))
+	return !c.stopped
+}
+
+// checkCondition checks that the condition cond of the statement kind, like "if",
+// is a boolean.
+func checkCondition(cond ast.Expr, env TypeEnv, ctx *InferenceContext, kind string) error {
+	if err := checkValueExpr(cond, env); err != nil {
+		return err
+	}
+	t, err := InferType(cond, env, ctx)
+	if err != nil {
+		return err
+	}
+	if !underlyingIs(operandConstraint(t, env), isBoolName) {
+		return mismatchError(cond, Bool, t, fmt.Errorf("non-boolean condition in %s statement: %s (type %s)", kind, types.ExprString(cond), FormatGo(t)))
+	}
+	return nil
+}
+
+// assign checks an assignment in scope b. A short variable declaration declares the names
+// on its left that are not declared by b yet, shadowing those of the enclosing blocks,
+// like `x := x + 1`, and must declare at least one.
+func (c *bodyChecker) assign(stmt *ast.AssignStmt, b *block, ctx *InferenceContext) error {
+	if stmt.Tok != token.DEFINE {
+		_, err := InferType(stmt, b.env, ctx)
+		return err
+	}
+
+	// the shadowing names are declared under a fresh name, so that the values on the
+	// right still see those of the enclosing blocks
+	lhs := make([]ast.Expr, len(stmt.Lhs))
+	shadowed := make(map[string]string)
+	isNew := false
+	for i, expr := range stmt.Lhs {
+		lhs[i] = expr
+		ident, ok := expr.(*ast.Ident)
+		if !ok || ident.Name == "_" || b.isDeclared(ident.Name) {
+			continue
+		}
+		isNew = true
+		name := ident.Name
+		if _, outer := b.env[name]; outer {
+			name += "·shadowed"
+			shadowed[name] = ident.Name
+			lhs[i] = &ast.Ident{NamePos: ident.NamePos, Name: name}
+		}
+		b.declare(name)
+	}
+	if !isNew {
+		return errors.New("no new variables on left side of :=")
+	}
+
+	s := *stmt
+	s.Lhs = lhs
+	if _, err := InferType(&s, b.env, ctx); err != nil {
+		return err
+	}
+	for fresh, name := range shadowed {
+		b.declare(name)
+		b.env[name] = b.env[fresh]
+		delete(b.env, fresh)
+	}
+	return nil
+}
+
+// inferCallStmt checks the call of a go or defer statement, named by kind, which must
+// not be a conversion.
+func inferCallStmt(call *ast.CallExpr, kind string, env TypeEnv, ctx *InferenceContext) error {
+	if _, ok := conversionType(call.Fun, env); ok {
+		return fmt.Errorf("%s requires function call, not conversion", kind)
+	}
+	_, err := InferType(call, env, ctx)
+	return err
+}
+
+// inferExprStmt checks an expression statement, which must be a call or a receive
+// operation; other values would be unused.
+func inferExprStmt(stmt *ast.ExprStmt, env TypeEnv, ctx *InferenceContext) error {
+	t, err := InferType(stmt.X, env, ctx)
+	if err != nil {
+		return err
+	}
+	switch x := ast.Unparen(stmt.X).(type) {
+	case *ast.CallExpr:
+		if _, ok := conversionType(x.Fun, env); !ok {
+			return nil
+		}
+	case *ast.UnaryExpr:
+		if x.Op == token.ARROW {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s (value of type %s) is not used", types.ExprString(stmt.X), FormatGo(t))
+}
+
+// inferIncDecStmt checks `x++` and `x--`, whose operand must be an assignable number.
+func inferIncDecStmt(stmt *ast.IncDecStmt, env TypeEnv, ctx *InferenceContext) error {
+	t, err := inferAssignTarget(stmt.X, env, ctx)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return errors.New("cannot use _ as value")
+	}
+	if !underlyingIs(operandConstraint(t, env), isNumericName) {
+		return fmt.Errorf("%w: %s%s (non-numeric type %s)", ErrInvalidOperation, types.ExprString(stmt.X), stmt.Tok, FormatGo(t))
+	}
+	return nil
+}
+
+// isTerminating reports whether stmt is a terminating statement, which ends the execution
+// of its block: a return, a goto, a call to panic, a block ending in a terminating
+// statement, an if statement whose branches both terminate, a loop without a condition
+// or a break, or a switch or select statement with a default case and no break whose
+// cases all terminate.
+func isTerminating(stmt ast.Stmt) bool {
+	return terminates(stmt, nil)
+}
+
+// terminates is isTerminating for stmt labeled lbl, if not nil, which a break may refer to.
+func terminates(stmt ast.Stmt, lbl *ast.Ident) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.GOTO
+	case *ast.ExprStmt:
+		call, ok := ast.Unparen(s.X).(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := ast.Unparen(call.Fun).(*ast.Ident)
+		return ok && ident.Name == "panic"
+	case *ast.BlockStmt:
+		return len(s.List) > 0 && isTerminating(s.List[len(s.List)-1])
+	case *ast.LabeledStmt:
+		return terminates(s.Stmt, s.Label)
+	case *ast.IfStmt:
+		return s.Else != nil && isTerminating(s.Body) && isTerminating(s.Else)
+	case *ast.ForStmt:
+		return s.Cond == nil && !hasBreak(s.Body, lbl)
+	case *ast.SwitchStmt:
+		return clausesTerminate(s.Body, lbl, false)
+	case *ast.TypeSwitchStmt:
+		return clausesTerminate(s.Body, lbl, false)
+	case *ast.SelectStmt:
+		return clausesTerminate(s.Body, lbl, true)
+	}
+	return false
+}
+
+// clausesTerminate reports whether a switch, or a select if isSelect is set, with the
+// clauses of body and labeled lbl terminates: a switch needs a default case, and each
+// clause must end in a terminating statement, or a fallthrough, without breaking out of
+// the statement. A select waits for one of its cases, forever if it has none.
+func clausesTerminate(body *ast.BlockStmt, lbl *ast.Ident, isSelect bool) bool {
+	hasDefault := isSelect
+	for _, clause := range body.List {
+		var list []ast.Stmt
+		switch cl := clause.(type) {
+		case *ast.CaseClause:
+			hasDefault = hasDefault || cl.List == nil
+			list = cl.Body
+		case *ast.CommClause:
+			list = cl.Body
+		}
+		if len(list) == 0 {
+			return false
+		}
+		last := list[len(list)-1]
+		if branch, ok := last.(*ast.BranchStmt); !(ok && branch.Tok == token.FALLTHROUGH) && !isTerminating(last) {
+			return false
+		}
+		for _, stmt := range list {
+			if hasBreak(stmt, lbl) {
+				return false
+			}
+		}
+	}
+	return hasDefault
+}
+
+// hasBreak reports whether n holds a break out of the enclosing statement labeled lbl,
+// if not nil: an unlabeled break outside of nested loops, switches and selects, which
+// it would break out of instead, or a break to lbl.
+func hasBreak(n ast.Node, lbl *ast.Ident) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BranchStmt:
+			if n.Tok == token.BREAK && (n.Label == nil || lbl != nil && n.Label.Name == lbl.Name) {
+				found = true
+			}
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			found = found || lbl != nil && hasLabeledBreak(n, lbl)
+			return false
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// hasLabeledBreak reports whether n holds a break to lbl.
+func hasLabeledBreak(n ast.Node, lbl *ast.Ident) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if b, ok := n.(*ast.BranchStmt); ok && b.Tok == token.BREAK && b.Label != nil && b.Label.Name == lbl.Name {
+			found = true
+		}
+		_, lit := n.(*ast.FuncLit)
+		return !found && !lit
+	})
+	return found
+}
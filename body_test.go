@@ -0,0 +1,374 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestCheckFuncBody(t *testing.T) {
+	const decls = `package p
+
+type Stack[E any] struct{ items []E }
+
+func itoa(n int) string { return "" }
+
+var count int
+`
+	tests := []struct {
+		name    string
+		src     string
+		wantErr error
+		want    []string // the messages of the diagnostics, in order
+	}{
+		{name: "return params", src: "func f(a, b int) (int, int) { return b, a }"},
+		{name: "named results", src: "func f(n int) (s string, ok bool) { s = itoa(n); return }"},
+		{name: "no results", src: "func f(n int) { count = n; return }"},
+		{name: "variadic", src: "func f(xs ...int) []int { return xs }"},
+		{name: "shadowing", src: "func f(n int) string { { n := itoa(n); return n } }"},
+		{name: "shadowing package var", src: "func f() string { count := itoa(count); return count }"},
+		{name: "redeclaration", src: "func f() (int, string) { n, s := 1, `a`; n, t := 2, s; return n, t }"},
+		{name: "declaration", src: "func f() float64 { var x = 1.5; x++; return x }"},
+		{name: "receiver", src: "func (s *Stack[E]) Self() *Stack[E] { return s }"},
+		{name: "panic", src: "func f() int { panic(`todo`) }"},
+		{name: "labeled return", src: "func f() int { done: return 1 }"},
+		{name: "blank params", src: "func f(_ int, _ string) {}"},
+		{name: "if else", src: "func f(n int) string { if n > 0 { return itoa(n) } else { return `-` } }"},
+		{name: "else if", src: "func f(n int) int { if n > 0 { return 1 } else if n < 0 { return 2 } else { return 0 } }"},
+		{name: "if init", src: "func f(n int) string { if s := itoa(n); s != `` { return s }; return `0` }"},
+		{name: "if init shadowing", src: "func f(n int) int { if n := itoa(n); n == `` { return 0 }; return n }"},
+		{name: "if bool var", src: "func f(ok bool) (n int) { if ok { n = 1 }; return }"},
+		{name: "if constant", src: "func f() int { if true { return 1 }; return 0 }"},
+		{name: "if without else", src: "func f(n int) int { if n > 0 { return 1 } }", wantErr: ErrMissingReturn},
+		{name: "non-boolean condition", src: "func f(n int) { if n { } }", want: []string{"non-boolean condition in if statement: n (type int)"}},
+		{name: "init scope ends with if", src: "func f(n int) string { if s := itoa(n); s == `` { }; return s }", wantErr: ErrUnknownIdent},
+		{name: "branch scope", src: "func f() int { if true { n := 1; _ = n } else { _ = n }; return 0 }", wantErr: ErrUnknownIdent},
+		{
+			name: "diagnostics of condition and branches",
+			src:  "func f(n int) { if n { count = `a` } else if x := `b`; x { count = x } }",
+			want: []string{
+				"non-boolean condition in if statement: n (type int)",
+				"assignment type mismatch for count: cannot convert `a` (untyped string constant) to type int",
+				"non-boolean condition in if statement: x (type string)",
+				"assignment type mismatch for count: type mismatch",
+			},
+		},
+		{name: "missing return", src: "func f(n int) int { n++ }", wantErr: ErrMissingReturn},
+		{name: "empty body", src: "func f() string {}", wantErr: ErrMissingReturn},
+		{name: "loop with break", src: "func f() int { for { break } }", wantErr: ErrMissingReturn},
+		{name: "return mismatch", src: "func f(n int) string { return n }", wantErr: ErrTypeMismatch},
+		{name: "return count", src: "func f() (int, int) { return 1 }", want: []string{"expected 2 return values, got 1"}},
+		{name: "bare return", src: "func f() int { return }", want: []string{"expected 1 return values, got 0"}},
+		{name: "value in function without results", src: "func f() { return 1 }", want: []string{"expected 0 return values, got 1"}},
+		{name: "no new variables", src: "func f(n int) { n := 2 }", want: []string{"no new variables on left side of :="}},
+		{name: "unused value", src: "func f(n int) { n + 1 }", want: []string{"n + 1 (value of type int) is not used"}},
+		{name: "unused conversion", src: "func f(n int) { float64(n) }", want: []string{"float64(n) (value of type float64) is not used"}},
+		{name: "non-numeric increment", src: "func f(s string) { s++ }", wantErr: ErrInvalidOperation},
+		{name: "scope ends with block", src: "func f() int { { n := 1; _ = n }; return n }", wantErr: ErrUnknownIdent},
+		{name: "index", src: "func f(xs []int, m map[string]int, s string) (int, int, byte) { return xs[0], m[`a`], s[len(s)-1] }"},
+		{name: "index assignment", src: "func f(xs []string, m map[int]string) { xs[0] = itoa(1); m[1] = xs[0] }"},
+		{name: "comma ok", src: "func f(m map[string]int, x any) (int, bool) { n, ok := m[`a`]; _, ok = x.(int); return n, ok }"},
+		{name: "slice", src: "func f(xs []int, s string) ([]int, string) { return xs[1:], s[:len(s)-1] }"},
+		{name: "unary", src: "func f(n int, ok bool) (int, bool, int) { return -n, !ok, ^n }"},
+		{name: "address and indirection", src: "func f(n int) int { p := &n; *p = 2; return *p }"},
+		{name: "for", src: "func f(n int) int { s := 0; for i := 0; i < n; i++ { s += i }; return s }"},
+		{name: "infinite for", src: "func f() int { for { } }"},
+		{name: "range", src: "func f(xs []string, m map[string]int) int { n := 0; for i, x := range xs { n += i + m[x] }; return n }"},
+		{name: "range string", src: "func f(s string) rune { var last rune; for _, r := range s { last = r }; return last }"},
+		{name: "range int", src: "func f(n int) int { s := 0; for i := range n { s += i }; return s }"},
+		{name: "range func", src: "func f(seq func(func(int) bool)) int { s := 0; for x := range seq { s += x }; return s }"},
+		{name: "switch", src: "func f(n int) string { switch n { case 0, 1: return `small`; default: return itoa(n) } }"},
+		{name: "tagless switch", src: "func f(n int) int { switch { case n < 0: return -n }; return n }"},
+		{name: "type switch", src: "func f(x any) int { switch v := x.(type) { case int: return v; case string: return len(v); default: return 0 } }"},
+		{name: "min max clear", src: "func f(xs []int, m map[string]int) int { clear(m); return max(min(len(xs), 3), 1) }"},
+		{name: "closure", src: "func f(n int) func() int { return func() int { n++; return n } }"},
+		{name: "generic body", src: "func Last[E any](xs []E) (E, bool) { var zero E; if len(xs) == 0 { return zero, false }; return xs[len(xs)-1], true }"},
+		{name: "generic call in generic body", src: "func Id[U any](x U) U { return x }\nfunc F[T any](x T) T { y := Id(x); return y }"},
+		{name: "explicit instantiation with type parameter", src: "func Id[U any](x U) U { return x }\nfunc F[T any](x T) T { return Id[T](x) }"},
+		{name: "partial instantiation with type parameter", src: "func Map[F, R any](s []F, f func(F) R) []R { return nil }\nfunc F[T any](xs []T) []string { return Map[T](xs, func(x T) string { return `` }) }"},
+		{name: "generic function argument in generic body", src: "func Id[U any](x U) U { return x }\nfunc Map[F, R any](s []F, f func(F) R) []R { return nil }\nfunc F[T any](xs []T) ([]T, []T) { return Map(xs, Id[T]), Map(xs, Id) }"},
+		{name: "constrained generic call in generic body", src: "func Max[N ~int | ~float64](a, b N) N { if a > b { return a }; return b }\nfunc MaxOf[T ~int | ~float64](xs []T) T { m := xs[0]; for _, x := range xs { m = Max(m, x) }; return m }"},
+		{name: "generic call with mismatched type parameter", src: "func Id[U any](x U) U { return x }\nfunc F[T, V any](x T) V { return Id(x) }", want: []string{"result 1 at p.go:9:34: return type mismatch: type mismatch"}},
+		{name: "bidirectional channel as receive-only", src: "func recv(c <-chan int) int { return <-c }\nfunc f(c chan int) int { var r <-chan int = c; return recv(c) + <-r }"},
+		{name: "receive-only channel as bidirectional", src: "func recvOnly() <-chan int { return nil }\nfunc takesBidi(c chan int) {}\nfunc f() { takesBidi(recvOnly()); var x chan int = recvOnly() }", want: []string{
+			"return type mismatch: type mismatch",
+			"declaration of x: return type mismatch: type mismatch",
+		}},
+		{name: "function field call", src: "type S struct{ F func(int) string }\nfunc f(s S, p *S) string { return s.F(1) + p.F(2) }"},
+		{name: "promoted function field call", src: "type Base struct{ Usage func() }\ntype Flags struct{ Base; n int }\nfunc f(flags *Flags) { flags.Usage(); flags.Base.Usage() }"},
+		{name: "function field call mismatch", src: "type S struct{ F func(int) string }\nfunc f(s S) int { return s.F(1) }", want: []string{"result 1 at p.go:9:26: return type mismatch: type mismatch"}},
+		{name: "call of non-function field", src: "type S struct{ F int }\nfunc f(s S) { s.F() }", wantErr: ErrNotAFunction},
+		{name: "constraint method", src: "type Stringer interface{ String() string }\nfunc F[X Stringer](x X) string { return x.String() }"},
+		{name: "constraint literal method", src: "func Size[S interface{ Len() int }](s S) int { return s.Len() + 1 }"},
+		{name: "method not in constraint", src: "func F[X any](x X) string { return x.String() }", want: []string{"result 1 at p.go:8:36: method String not found in type X"}},
+		{name: "index of non-indexable", src: "func f(n int) int { return n[0] }", want: []string{"result 1 at p.go:8:28: cannot index n (type int)"}},
+		{name: "non-integer index", src: "func f(xs []int, s string) int { return xs[s] }", want: []string{"result 1 at p.go:8:41: invalid argument: index s (type string) must be integer"}},
+		{name: "non-boolean for condition", src: "func f(n int) { for n { } }", want: []string{"non-boolean condition in for statement: n (type int)"}},
+		{name: "range over non-iterable", src: "func f(b bool) { for range b { } }", want: []string{"cannot range over b (variable of type bool)"}},
+		{name: "mismatched switch case", src: "func f(n int) { switch n { case `a`: } }", want: []string{"cannot convert `a` (untyped string constant) to type int"}},
+		{name: "closure mismatch", src: "func f() func() int { return func() int { return `a` } }", wantErr: ErrTypeMismatch},
+		{name: "unsupported statement", src: "func f() int { goto end; end: return 1 }"},
+		{name: "byte alias", src: "func f(b byte) uint8 { var x []byte = []uint8{b}; return x[0] }"},
+		{name: "rune alias", src: "func f(r rune) int32 { var x []int32 = []rune{r, 'a'}; return x[1] }"},
+		{name: "reachable after label", src: "func f(n int) int { if n > 0 { goto pos }; return 0; pos: count = n; return n }"},
+		{
+			name: "unreachable",
+			src:  "func f() int { return 1; count++; return 2 }",
+			want: []string{"unreachable code"},
+		},
+		{
+			name: "diagnostics of every statement",
+			src:  "func f(s string) int { s++; var b bool = 1; count = s; return 0 }",
+			want: []string{
+				"invalid operation: s++ (non-numeric type string)",
+				"declaration of b: cannot convert 1 (untyped int constant) to type bool",
+				"assignment type mismatch for count: type mismatch",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", decls+tt.src, 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			env, err := BuildEnv([]*ast.File{file})
+			if err != nil {
+				t.Fatalf("BuildEnv() error = %v", err)
+			}
+			fn := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
+			err = CheckFuncBody(fn, env, NewInferenceContext(WithFileSet(fset), WithErrorLimit(0)))
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CheckFuncBody() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.want != nil:
+				var got []string
+				for _, e := range unjoin(err) {
+					got = append(got, e.Error())
+				}
+				if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+					t.Fatalf("CheckFuncBody() errors = %q, want %q", got, tt.want)
+				}
+			case err != nil:
+				t.Fatalf("CheckFuncBody() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckFuncBodyAssignability(t *testing.T) {
+	const decls = `package p
+
+type Stringer interface{ String() string }
+
+type Named interface {
+	Stringer
+	Name() string
+}
+
+type object struct{ name string }
+
+func (o *object) Name() string   { return o.name }
+func (o *object) String() string { return o.name }
+
+type Func struct {
+	*object
+	recv *Func
+}
+
+type Pool struct{ funcs slab[Func] }
+
+type slab[T any] struct{ items []T }
+
+func (s *slab[T]) alloc() *T { var t T; s.items = append(s.items, t); return &s.items[len(s.items)-1] }
+
+type Lookup func(name string) (Named, error)
+
+func pair() (*Func, error) { return nil, nil }
+
+type count struct{}
+
+func (count) String() int { return 0 }
+
+func Show[X Stringer](x X) string { return x.String() }
+
+type Sorter interface {
+	Len() int
+	Less(i, j int) bool
+}
+
+type reverse struct{ Sorter }
+
+func (r reverse) Less(i, j int) bool { return r.Sorter.Less(j, i) }
+
+type Counter int
+
+func (c *Counter) Inc() {}
+
+type Tally struct{ Counter }
+
+type Incer interface{ Inc() }
+`
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string // a substring of the error
+	}{
+		{name: "append to interface slice", src: "func f(fn *Func) []Named { var ns []Named; return append(ns, fn) }"},
+		{name: "compare with interface", src: "func f(n Named, fn *Func) bool { return n == fn || fn != n }"},
+		{name: "convert to defined interface", src: "func f(fn *Func) Stringer { return Stringer(fn) }"},
+		{name: "embedded interface", src: "func f(n Named) Stringer { return n }"},
+		{name: "promoted pointer method", src: "func f(fn Func) string { return fn.Name() + fn.recv.String() }"},
+		{name: "promoted interface methods", src: "func f(s Sorter) Sorter { return &reverse{s} }"},
+		{name: "promoted interface method call", src: "func f(r reverse) int { return r.Len() }"},
+		{name: "promoted pointer method of defined type", src: "func f(t *Tally) Incer { return t }"},
+		{name: "method of instance field", src: "func f(p *Pool) *Func { return p.funcs.alloc() }"},
+		{name: "call of defined function type", src: "func f(lookup Lookup) (Named, error) { return lookup(`a`) }"},
+		{name: "return tuple", src: "func f() (Named, error) { return pair() }"},
+		{name: "untyped shift", src: "func f(mask uint64, i int) bool { mask |= 1 << i; return mask&(1<<i) != 0 }"},
+		{name: "untyped map values", src: "func f() map[string]int64 { return map[string]int64{`a`: 1, `b`: 2} }"},
+		{name: "struct literal", src: "func f() []Func { return []Func{{recv: nil}, {&object{`a`}, nil}} }"},
+		{name: "missing method", src: "func f(o object) Stringer { return o }", wantErr: "does not implement Stringer"},
+		{name: "wrong method signature", src: "func f() Stringer { var s Stringer = count{}; return s }", wantErr: "count does not implement Stringer (wrong type for method String)"},
+		{name: "wrong method signature in constraint", src: "func f() string { return Show[count](count{}) }", wantErr: "type argument count does not satisfy constraint Stringer for X"},
+		{name: "mismatched comparison", src: "func f(n Named, o object) bool { return n == o }", wantErr: "mismatched types Named and object"},
+		{name: "unknown field", src: "func f() Func { return Func{name: `a`} }", wantErr: "unknown field: name"},
+		{name: "pointer method of embedded defined type", src: "func f(t Tally) Incer { return t }", wantErr: "Tally does not implement Incer (missing method Inc)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", decls+tt.src, 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			env, err := BuildEnv([]*ast.File{file})
+			if err != nil {
+				t.Fatalf("BuildEnv() error = %v", err)
+			}
+			fn := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
+			err = CheckFuncBody(fn, env, NewInferenceContext(WithFileSet(fset)))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckFuncBody() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckFuncBody() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckFuncBodyPositions(t *testing.T) {
+	src := `package p
+
+func f(n int) int {
+	if n > 0 {
+		return n
+	} else {
+		panic(n)
+	}
+	n++
+	return n
+}
+
+func g() int {
+	n := 1
+	_ = n
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env := universe()
+	tests := []struct {
+		fn      int
+		want    string
+		warning bool
+	}{
+		{fn: 0, want: "p.go:9:2: unreachable code", warning: true},
+		{fn: 1, want: "p.go:16:1: missing return"},
+	}
+	for _, tt := range tests {
+		fn := file.Decls[tt.fn].(*ast.FuncDecl)
+		err := CheckFuncBody(fn, env, NewInferenceContext(WithFileSet(fset)))
+		var te *TypeError
+		if !errors.As(err, &te) {
+			t.Fatalf("CheckFuncBody(%s) error = %v, want a TypeError", fn.Name.Name, err)
+		}
+		if got := te.Position.String() + ": " + te.Error(); got != tt.want {
+			t.Errorf("CheckFuncBody(%s) = %s, want %s", fn.Name.Name, got, tt.want)
+		}
+		if IsWarning(err) != tt.warning {
+			t.Errorf("IsWarning(%v) = %v, want %v", err, IsWarning(err), tt.warning)
+		}
+	}
+}
+
+func TestInferIfStmt(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\nfunc f() {\nif x := n; x > 0 { n = x } else { n = `a` }\n}", 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	stmt := file.Decls[0].(*ast.FuncDecl).Body.List[0]
+	env := TypeEnv{"n": &VarObj{Name: "n", Type: Int}}
+	want := "assignment type mismatch for n: cannot convert `a` (untyped string constant) to type int"
+	if _, err := InferType(stmt, env, nil); err == nil || err.Error() != want {
+		t.Errorf("InferType() error = %v, want %s", err, want)
+	}
+	if _, ok := env["x"]; ok {
+		t.Errorf("InferType() declared x in the enclosing scope")
+	}
+}
+
+func TestIsTerminating(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "return", want: true},
+		{src: "panic(1)", want: true},
+		{src: "goto L", want: true},
+		{src: "{ f(); return }", want: true},
+		{src: "{ return; f() }", want: false},
+		{src: "f()", want: false},
+		{src: "if x { return } else { panic(1) }", want: true},
+		{src: "if x { return }", want: false},
+		{src: "if x { return } else if y { return } else { return }", want: true},
+		{src: "for {}", want: true},
+		{src: "for x {}", want: false},
+		{src: "for { break }", want: false},
+		{src: "for { for { break } }", want: true},
+		{src: "for { switch { default: break } }", want: true},
+		{src: "L: for { for { break L } }", want: false},
+		{src: "for range xs {}", want: false},
+		{src: "switch x { case 1: return; default: panic(1) }", want: true},
+		{src: "switch x { case 1: fallthrough; default: return }", want: true},
+		{src: "switch x { case 1: return }", want: false},
+		{src: "switch x { case 1: if y { break }; return; default: return }", want: false},
+		{src: "switch x.(type) { default: return }", want: true},
+		{src: "select {}", want: true},
+		{src: "select { case <-ch: return }", want: true},
+		{src: "select { case <-ch: }", want: false},
+	}
+	for _, tt := range tests {
+		file, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\nfunc f() {\n"+tt.src+"\nL:\n}", 0)
+		if err != nil {
+			t.Fatalf("ParseFile(%s) error = %v", tt.src, err)
+		}
+		stmt := file.Decls[0].(*ast.FuncDecl).Body.List[0]
+		if got := isTerminating(stmt); got != tt.want {
+			t.Errorf("isTerminating(%s) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
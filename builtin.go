@@ -0,0 +1,393 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+)
+
+// builtinArity returns the minimum and maximum number of arguments of the builtin function
+// name, where a maximum of -1 allows any number, and reports whether name is a builtin.
+func builtinArity(name string) (minArgs, maxArgs int, ok bool) {
+	switch name {
+	case "cap", "clear", "close", "len", "new", "panic":
+		return 1, 1, true
+	case "copy", "delete":
+		return 2, 2, true
+	case "make":
+		return 1, 3, true
+	case "append", "max", "min":
+		return 1, -1, true
+	case "recover":
+		return 0, 0, true
+	}
+	return 0, 0, false
+}
+
+// builtinName returns the name of the builtin function called by call, if its function is
+// the identifier of a builtin that is not shadowed by a declaration in env.
+func builtinName(call *ast.CallExpr, env TypeEnv) (string, bool) {
+	ident, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	if _, _, ok := builtinArity(ident.Name); !ok {
+		return "", false
+	}
+	if _, shadowed := env[ident.Name]; shadowed {
+		return "", false
+	}
+	return ident.Name, true
+}
+
+// inferBuiltinCall infers a call of the builtin function name. The builtins are generic in
+// a way that Go's type parameters cannot express, so each is checked on its own:
+// `len(xs)` and `cap(xs)` are int, `append(s, x...)` has the type of s, `make(T, n)` the
+// type T, `new(T)` the type *T, `copy(dst, src)` is int and `min(x, y...)` and
+// `max(x, y...)` have the type of their operands, `recover()` is any, while `delete(m, k)`,
+// `clear(m)`, `close(ch)` and `panic(v)` have no value.
+//
+// Operands of type parameter type are checked against their constraint, like binary
+// operands: `len(s)` is valid for `S ~[]E | ~string`, and `append(s, e)` for `S ~[]E`.
+func inferBuiltinCall(name string, call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	minArgs, maxArgs, _ := builtinArity(name)
+	if n := len(call.Args); n < minArgs || (maxArgs >= 0 && n > maxArgs) {
+		want := fmt.Sprint(minArgs)
+		switch {
+		case maxArgs < 0:
+			want = fmt.Sprintf("at least %d", minArgs)
+		case maxArgs != minArgs:
+			want = fmt.Sprintf("%d to %d", minArgs, maxArgs)
+		}
+		return nil, fmt.Errorf("%w: %s expects %s arguments, got %d", ErrArityMismatch, name, want, n)
+	}
+	if call.Ellipsis.IsValid() && name != "append" {
+		return nil, fmt.Errorf("%w: invalid use of ... with built-in %s", ErrInvalidOperation, name)
+	}
+
+	switch name {
+	case "len", "cap":
+		x, err := inferBuiltinArg(call.Args[0], nil, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !hasLength(operandConstraint(x, env), name == "cap") {
+			return nil, invalidBuiltinArg(call.Args[0], x, name)
+		}
+		return Int, nil
+	case "new":
+		t, err := inferBuiltinTypeArg(call.Args[0], env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &PointerType{Base: t}, nil
+	case "make":
+		return inferMake(call, env, ctx)
+	case "append":
+		return inferAppend(call, env, ctx)
+	case "copy":
+		dst, err := inferBuiltinArg(call.Args[0], nil, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		st, ok := coreSlice(dst, env)
+		if !ok {
+			return nil, invalidBuiltinArg(call.Args[0], dst, name)
+		}
+		if err := checkSliceArg(call.Args[1], st, env, ctx, name); err != nil {
+			return nil, err
+		}
+		return Int, nil
+	case "delete":
+		m, err := inferBuiltinArg(call.Args[0], nil, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		mt, ok := underlying(m).(*MapType)
+		if !ok {
+			return nil, invalidBuiltinArg(call.Args[0], m, name)
+		}
+		if err := checkBuiltinArg(call.Args[1], mt.KeyType, env, ctx, name); err != nil {
+			return nil, err
+		}
+		return noValueResult(ctx)
+	case "clear":
+		x, err := inferBuiltinArg(call.Args[0], nil, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch coreType(x, env).(type) {
+		case *MapType, *SliceType:
+			return noValueResult(ctx)
+		}
+		return nil, invalidBuiltinArg(call.Args[0], x, name)
+	case "min", "max":
+		return inferMinMax(call, env, ctx)
+	case "close":
+		ch, err := inferBuiltinArg(call.Args[0], nil, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		ct, ok := underlying(ch).(*ChanType)
+		if !ok {
+			return nil, invalidBuiltinArg(call.Args[0], ch, name)
+		}
+		if ct.Dir == RecvOnly {
+			return nil, fmt.Errorf("%w: cannot close receive-only channel %s (%s)", ErrInvalidOperation, types.ExprString(call.Args[0]), FormatGo(ch))
+		}
+		return noValueResult(ctx)
+	case "panic":
+		// the value may be of any type, including nil
+		if _, err := inferBuiltinArg(call.Args[0], nil, env, ctx); err != nil {
+			return nil, err
+		}
+		return noValueResult(ctx)
+	case "recover":
+		return &InterfaceType{Name: ConstraintAny, IsEmpty: true}, nil
+	}
+	return nil, fmt.Errorf("unsupported built-in %s", name)
+}
+
+// inferMake infers `make(T, size...)`, which has the type T, a slice, map or channel type,
+// or a type parameter with one as its core type, like `S ~[]E`. Slices need a length and
+// may have a capacity; maps and channels may have a size.
+func inferMake(call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	t, err := inferBuiltinTypeArg(call.Args[0], env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	sizes := call.Args[1:]
+	switch coreType(t, env).(type) {
+	case *SliceType:
+		if len(sizes) == 0 {
+			return nil, fmt.Errorf("%w: make(%s) expects 2 or 3 arguments, got 1", ErrArityMismatch, FormatGo(t))
+		}
+	case *MapType, *ChanType:
+		if len(sizes) > 1 {
+			return nil, fmt.Errorf("%w: make(%s) expects 1 or 2 arguments, got %d", ErrArityMismatch, FormatGo(t), len(call.Args))
+		}
+	default:
+		return nil, fmt.Errorf("%w: cannot make %s; type must be slice, map, or channel", ErrInvalidOperation, types.ExprString(call.Args[0]))
+	}
+	for _, size := range sizes {
+		st, err := inferBuiltinArg(size, nil, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !underlyingIs(operandConstraint(st, env), isIntegerName) {
+			return nil, fmt.Errorf("%w: make: size %s (type %s) must be integer", ErrInvalidOperation, types.ExprString(size), FormatGo(st))
+		}
+	}
+	return t, nil
+}
+
+// inferAppend infers `append(s, x...)`, which has the type of the slice s. The values must
+// have the element type of s, or, with `...`, the single value must be a slice of it,
+// or a string if s is a byte slice.
+func inferAppend(call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	s, err := inferBuiltinArg(call.Args[0], ctx.ExpectedType, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := coreSlice(s, env)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (type %s) is not a slice", ErrInvalidOperation, types.ExprString(call.Args[0]), FormatGo(s))
+	}
+	values := call.Args[1:]
+	if call.Ellipsis.IsValid() {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("%w: append with ... expects 2 arguments, got %d", ErrArityMismatch, len(call.Args))
+		}
+		return s, checkSliceArg(values[0], st, env, ctx, "append")
+	}
+	for _, value := range values {
+		if err := checkBuiltinArg(value, st.ElementType, env, ctx, "append"); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// inferMinMax infers `min(x, y...)` or `max(x, y...)`, which have the type of their
+// operands, an ordered type. As for binary expressions, untyped constants are converted to
+// the type of the other operands, and if all of them are untyped, the result takes the kind
+// appearing later in int, rune, float64, or the type it is assigned to.
+func inferMinMax(call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	var typed *operand
+	var untyped []*operand
+	for _, arg := range call.Args {
+		x, err := inferOperand(arg, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case x.untyped():
+			untyped = append(untyped, x)
+		case typed != nil && !TypesEqual(typed.typ, x.typ):
+			return nil, fmt.Errorf("%w: %s (mismatched types %s and %s)", ErrInvalidOperation, types.ExprString(call), FormatGo(typed.typ), FormatGo(x.typ))
+		case typed == nil:
+			typed = x
+		}
+	}
+
+	var t Type
+	if typed != nil {
+		for _, x := range untyped {
+			if _, err := convertUntyped(x, typed.typ, env); err != nil {
+				return nil, err
+			}
+		}
+		t = typed.typ
+	} else {
+		x := untyped[0]
+		for _, y := range untyped[1:] {
+			if (x.val.Kind() == constant.String) != (y.val.Kind() == constant.String) {
+				return nil, fmt.Errorf("%w: %s (mismatched types %s and %s)", ErrInvalidOperation, types.ExprString(call), x.kind(), y.kind())
+			}
+			if untypedRank(y.typ) > untypedRank(x.typ) {
+				x = y
+			}
+		}
+		t = x.typ
+	}
+	if !isOrdered(operandConstraint(t, env)) {
+		return nil, fmt.Errorf("%w: %s (operator < not defined on %s)", ErrInvalidOperation, types.ExprString(call), FormatGo(t))
+	}
+	if typed == nil && ctx.ExpectedType != nil {
+		for _, x := range untyped {
+			if !representable(x.val, ctx.ExpectedType, env) {
+				return t, nil
+			}
+		}
+		return ctx.ExpectedType, nil
+	}
+	return t, nil
+}
+
+// inferBuiltinArg infers a value argument of a builtin, with the expected type, if not nil.
+func inferBuiltinArg(arg ast.Expr, expected Type, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if err := checkValueExpr(arg, env); err != nil {
+		return nil, err
+	}
+	t, err := InferType(arg, env, ctx.Child(WithExpectedType(expected), WithFunctionArg()))
+	if err != nil {
+		return nil, err
+	}
+	if isNoValue(t) {
+		return nil, fmt.Errorf("argument %s: %w", types.ExprString(arg), ErrNoValueUsed)
+	}
+	return t, nil
+}
+
+// inferBuiltinTypeArg infers the type argument of make and new.
+func inferBuiltinTypeArg(arg ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if err := checkTypeExpr(arg, env); err != nil {
+		return nil, err
+	}
+	return InferType(arg, env, ctx.Child())
+}
+
+// checkBuiltinArg checks that the argument of the builtin name can be assigned to want.
+// An untyped constant, like `'a'` appended to a byte slice, must be representable by want.
+func checkBuiltinArg(arg ast.Expr, want Type, env TypeEnv, ctx *InferenceContext, name string) error {
+	t, err := inferBuiltinArg(arg, want, env, ctx)
+	if err != nil {
+		return err
+	}
+	if val := constantOf(arg, env); val.Kind() != constant.Unknown && representable(val, want, env) {
+		return nil
+	}
+	if err := assignable(want, t, env, ctx); err != nil {
+		return fmt.Errorf("cannot use %s (type %s) as %s value in argument to %s: %w", types.ExprString(arg), FormatGo(t), FormatGo(want), name, err)
+	}
+	return nil
+}
+
+// checkSliceArg checks the source of copy and the spread argument of append: a slice with
+// the element type of st, or a string if st is a byte slice.
+func checkSliceArg(arg ast.Expr, st *SliceType, env TypeEnv, ctx *InferenceContext, name string) error {
+	t, err := inferBuiltinArg(arg, nil, env, ctx)
+	if err != nil {
+		return err
+	}
+	if isByte(st.ElementType) && isString(t) {
+		return nil
+	}
+	src, ok := coreSlice(t, env)
+	if !ok {
+		return invalidBuiltinArg(arg, t, name)
+	}
+	if err := unifyInto(st.ElementType, src.ElementType, env, ctx); err != nil {
+		return fmt.Errorf("%w: arguments to %s have different element types %s and %s", ErrInvalidOperation, name, FormatGo(st.ElementType), FormatGo(src.ElementType))
+	}
+	return nil
+}
+
+func invalidBuiltinArg(arg ast.Expr, t Type, name string) error {
+	return fmt.Errorf("%w: invalid argument %s (type %s) for built-in %s", ErrInvalidOperation, types.ExprString(arg), FormatGo(t), name)
+}
+
+// hasLength reports whether len, or cap if capacity is set, applies to t: strings (for len
+// only), arrays, pointers to arrays, slices, maps (for len only) and channels. A type
+// parameter, represented by its constraint, qualifies if every type in its type set does.
+// Unconstrained type variables are not known yet, so they qualify.
+func hasLength(t Type, capacity bool) bool {
+	switch u := underlying(t).(type) {
+	case *TypeConstant:
+		return !capacity && u.Name == TypeString
+	case *PointerType:
+		_, ok := underlying(u.Base).(*ArrayType)
+		return ok
+	case *ArrayType, *SliceType, *ChanType:
+		return true
+	case *MapType:
+		return !capacity
+	case *TypeConstraint:
+		if u.BuiltinConstraint == ConstraintAny && len(u.Types) == 0 && len(u.Interfaces) == 0 {
+			return true
+		}
+		if len(u.Types) == 0 {
+			return false
+		}
+		for _, term := range u.Types {
+			if approx, ok := term.(*ApproxType); ok {
+				term = approx.Base
+			}
+			if !hasLength(term, capacity) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// coreSlice returns the slice type underlying t, see coreType.
+func coreSlice(t Type, env TypeEnv) (*SliceType, bool) {
+	st, ok := coreType(t, env).(*SliceType)
+	return st, ok
+}
+
+// coreType returns the underlying type of t. A type parameter, represented by its
+// constraint in env, has one if every type in its type set has the same underlying
+// type, like the slice of `S ~[]E`; coreType returns nil otherwise.
+func coreType(t Type, env TypeEnv) Type {
+	u := underlying(operandConstraint(t, env))
+	tc, ok := u.(*TypeConstraint)
+	if !ok {
+		return u
+	}
+	var core Type
+	for _, term := range tc.Types {
+		if approx, ok := term.(*ApproxType); ok {
+			term = approx.Base
+		}
+		if u := underlying(term); core == nil {
+			core = u
+		} else if !TypesEqual(core, u) {
+			return nil
+		}
+	}
+	return core
+}
@@ -0,0 +1,184 @@
+package generic
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInferBuiltinCall(t *testing.T) {
+	ints := &SliceType{ElementType: Int}
+	bytes := &NamedType{Name: "Bytes", Underlying: &SliceType{ElementType: Byte}}
+	env := TypeEnv{
+		"xs":     &VarObj{Name: "xs", Type: ints},
+		"ys":     ints,
+		"b":      bytes,
+		"arr":    &ArrayType{ElementType: String, Len: 3},
+		"parr":   &PointerType{Base: &ArrayType{ElementType: String, Len: 3}},
+		"m":      &MapType{KeyType: String, ValueType: Int},
+		"ch":     &ChanType{Dir: SendRecv, ElementType: Int},
+		"in":     &ChanType{Dir: RecvOnly, ElementType: Int},
+		"s":      String,
+		"n":      &VarObj{Name: "n", Type: Int},
+		"f":      Float64,
+		"int":    Int,
+		"string": String,
+		"byte":   Byte,
+		"Bytes":  bytes,
+	}
+
+	tests := []struct {
+		src     string
+		want    string
+		wantErr error
+	}{
+		{src: "len(xs)", want: "int"},
+		{src: "len(s)", want: "int"},
+		{src: "len(m)", want: "int"},
+		{src: "len(parr)", want: "int"},
+		{src: "cap(arr)", want: "int"},
+		{src: "cap(ch)", want: "int"},
+		{src: "len(xs) + 1", want: "int"},
+		{src: "len(n)", wantErr: ErrInvalidOperation},
+		{src: "cap(s)", wantErr: ErrInvalidOperation},
+		{src: "cap(m)", wantErr: ErrInvalidOperation},
+		{src: "len(xs, ys)", wantErr: ErrArityMismatch},
+		{src: "len()", wantErr: ErrArityMismatch},
+
+		{src: "append(xs, 1, n)", want: "[]int"},
+		{src: "append(xs)", want: "[]int"},
+		{src: "append(xs, ys...)", want: "[]int"},
+		{src: "append(b, s...)", want: "Bytes"},
+		{src: "append(b, 'a')", want: "Bytes"},
+		{src: "append(xs, s)", wantErr: ErrTypeMismatch},
+		{src: "append(s, 1)", wantErr: ErrInvalidOperation},
+		{src: "append(xs, s...)", wantErr: ErrInvalidOperation},
+		{src: "append(xs, 1, ys...)", wantErr: ErrArityMismatch},
+
+		{src: "make([]int, 3)", want: "[]int"},
+		{src: "make([]int, 3, n)", want: "[]int"},
+		{src: "make(Bytes, 8)", want: "Bytes"},
+		{src: "make(map[string][]int)", want: "map[string][]int"},
+		{src: "make(map[string]int, 10)", want: "map[string]int"},
+		{src: "make(chan int, 1)", want: "chan int"},
+		{src: "make([]int)", wantErr: ErrArityMismatch},
+		{src: "make(map[string]int, 1, 2)", wantErr: ErrArityMismatch},
+		{src: "make([]int, f)", wantErr: ErrInvalidOperation},
+		{src: "make(int)", wantErr: ErrInvalidOperation},
+		{src: "make(xs, 1)", wantErr: ErrNotAType},
+
+		{src: "new(int)", want: "*int"},
+		{src: "new([]string)", want: "*[]string"},
+		{src: "new(n)", wantErr: ErrNotAType},
+
+		{src: "copy(xs, ys)", want: "int"},
+		{src: "copy(b, s)", want: "int"},
+		{src: "copy(xs, s)", wantErr: ErrInvalidOperation},
+		{src: "copy(xs, b)", wantErr: ErrInvalidOperation},
+
+		{src: "delete(m, s)", want: ""},
+		{src: "delete(m, n)", wantErr: ErrTypeMismatch},
+		{src: "delete(xs, 1)", wantErr: ErrInvalidOperation},
+		{src: "close(ch)", want: ""},
+		{src: "close(in)", wantErr: ErrInvalidOperation},
+		{src: "close(ch...)", wantErr: ErrInvalidOperation},
+		{src: "len(close(ch))", wantErr: ErrNoValueUsed},
+
+		{src: "panic(s)", want: ""},
+		{src: "panic(nil)", want: ""},
+		{src: "panic()", wantErr: ErrArityMismatch},
+		{src: "len(panic(s))", wantErr: ErrNoValueUsed},
+
+		{src: "recover()", want: "any"},
+		{src: "recover(s)", wantErr: ErrArityMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("InferType() = %v, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+// TestInferBuiltinCallTypeParams checks the builtins on operands of type parameter type,
+// whose constraints are bound in the environment.
+func TestInferBuiltinCallTypeParams(t *testing.T) {
+	e := &TypeVariable{Name: "E"}
+	env := TypeEnv{
+		"S": &TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: e}}}},
+		"B": &TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: Byte}}, &ApproxType{Base: String}}},
+		"N": &TypeConstraint{Types: []Type{Int, Float64}},
+		"M": &TypeConstraint{Types: []Type{&ApproxType{Base: &MapType{KeyType: String, ValueType: e}}}},
+		"s": &TypeVariable{Name: "S"},
+		"b": &TypeVariable{Name: "B"},
+		"n": &TypeVariable{Name: "N"},
+		"m": &TypeVariable{Name: "M"},
+		"x": e,
+		// P and Q are in scope as types, like in the body of a generic function
+		"P":                &TypeVariable{Name: "P"},
+		"Q":                &TypeVariable{Name: "Q"},
+		"R":                &TypeVariable{Name: "R"},
+		constraintKey("P"): &TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: e}}}},
+		constraintKey("Q"): &TypeConstraint{Types: []Type{&ApproxType{Base: &MapType{KeyType: String, ValueType: e}}}},
+		constraintKey("R"): &TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: Int}}, &ApproxType{Base: &MapType{KeyType: Int, ValueType: Int}}}},
+	}
+
+	tests := []struct {
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{src: "len(s)", want: "int"},
+		{src: "len(b)", want: "int"},
+		{src: "append(s, x)", want: "S"},
+		{src: "cap(b)", wantErr: true},
+		{src: "len(n)", wantErr: true},
+		{src: "append(b, x)", wantErr: true},
+		{src: "make(P, 3)", want: "P"},
+		{src: "make(Q)", want: "Q"},
+		{src: "make(P)", wantErr: true},
+		{src: "make(R, 3)", wantErr: true},
+		{src: "s == nil", want: "bool"},
+		{src: "nil != m", want: "bool"},
+		{src: "b == nil", wantErr: true},
+		{src: "n == nil", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("InferType() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestShadowedBuiltin(t *testing.T) {
+	env := TypeEnv{"len": &FunctionType{ParamTypes: []Type{Int}, ReturnType: String}}
+	got, err := InferType(mustParseExpr(t, "len(1)"), env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	if got != Type(String) {
+		t.Errorf("InferType() = %v, want the result of the declared len", got)
+	}
+}
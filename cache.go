@@ -0,0 +1,263 @@
+package generic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/token"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// cacheVersion is part of every cache key, so that changing the format of the cached
+// results or the rules of inference invalidates the results cached before.
+const cacheVersion = "3"
+
+// Cache stores the results of CheckSource, CheckSources and Config.Check on disk, so that
+// checking an unchanged file or package again, like in repeated CI runs, skips the
+// inference entirely.
+//
+// Results are addressed by a hash of the sources and of the declarations they may use.
+// The packages they import are hashed by their exported API, see APIReport, since the
+// rest of a dependency cannot affect them. The other declarations of the environment,
+// those of the same package, are hashed in the form of EncodeEnv, so that a change to the
+// unexported fields and methods of its types is a cache miss too.
+type Cache struct {
+	Dir string
+}
+
+// CheckSource returns the result of CheckSource(src, env), from the cache if it is there.
+// Unreadable entries are checked again and replaced. The error reports a result that could
+// not be written to the cache; the result is valid even then.
+func (c *Cache) CheckSource(src string, env TypeEnv) (*SourceResult, error) {
+	return c.check([]sourceFile{{src: src}}, env, func() *SourceResult { return CheckSource(src, env) })
+}
+
+// CheckSources returns the result of CheckSources(srcs, env), from the cache if it is there,
+// like CheckSource.
+func (c *Cache) CheckSources(srcs map[string]string, env TypeEnv) (*SourceResult, error) {
+	files := make([]sourceFile, 0, len(srcs))
+	for _, name := range sortedKeys(srcs) {
+		files = append(files, sourceFile{name: name, src: srcs[name]})
+	}
+	return c.check(files, env, func() *SourceResult { return CheckSources(srcs, env) })
+}
+
+// check returns the result of checking srcs against env, from the cache if it is there,
+// and else from check, whose result is cached.
+func (c *Cache) check(srcs []sourceFile, env TypeEnv, check func() *SourceResult) (*SourceResult, error) {
+	path := filepath.Join(c.Dir, cacheKey(srcs, env)+".json")
+	if data, err := os.ReadFile(path); err == nil {
+		var result SourceResult
+		if json.Unmarshal(data, &result) == nil {
+			return &result, nil
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return check(), err
+	}
+
+	result := check()
+	return result, c.write(path, result)
+}
+
+// Check returns the diagnostics of config.Check(fset, files, imp), from the cache if they
+// are there. The files are those of a package parsed in fset, and are read again from
+// disk to address the result by their contents, along with the configuration and the
+// exported API of the packages they import. Like CheckSource, the error reports a result
+// that could not be cached; the diagnostics are valid even then.
+func (c *Cache) Check(config *Config, fset *token.FileSet, files []*ast.File, imp Importer) (errs, warns []error, err error) {
+	imp = memoImporter(imp)
+	key, err := checkKey(config, fset, files, imp)
+	if err != nil {
+		errs, warns = config.Check(fset, files, imp)
+		return errs, warns, err
+	}
+	path := filepath.Join(c.Dir, key+".json")
+	if data, err := os.ReadFile(path); err == nil {
+		var result SourceResult
+		if json.Unmarshal(data, &result) == nil {
+			errs, warns = cachedDiagnostics(fset, files, result.Diagnostics)
+			return errs, warns, nil
+		}
+	}
+
+	errs, warns = config.Check(fset, files, imp)
+	// a warning reported as an error with werror is cached as an error
+	result := &SourceResult{}
+	add := func(diags []error, warning bool) {
+		for _, err := range diags {
+			d := sourceDiagnostic(fset, token.NoPos, err)
+			d.Warning = warning
+			result.Diagnostics = append(result.Diagnostics, d)
+		}
+	}
+	add(errs, false)
+	add(warns, true)
+	return errs, warns, c.write(path, result)
+}
+
+// cachedDiagnostics returns the diagnostics of a result of Cache.Check as errors and
+// warnings, located again in the files of fset.
+func cachedDiagnostics(fset *token.FileSet, files []*ast.File, diags []SourceDiagnostic) (errs, warns []error) {
+	byName := make(map[string]*token.File)
+	for _, file := range files {
+		if tf := fset.File(file.Pos()); tf != nil {
+			byName[tf.Name()] = tf
+		}
+	}
+	for _, d := range diags {
+		err := errors.New(d.Message)
+		if tf, ok := byName[d.File]; ok && d.Line > 0 && d.Line <= tf.LineCount() && d.Column > 0 {
+			if offset := tf.Offset(tf.LineStart(d.Line)) + d.Column - 1; offset <= tf.Size() {
+				err = &TypeError{Pos: tf.Pos(offset), Err: err}
+			}
+		}
+		if d.Warning {
+			warns = append(warns, err)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	return errs, warns
+}
+
+// write stores result at path. The result is written to a temporary file first,
+// so that concurrent runs never read a partially written entry.
+func (c *Cache) write(path string, result *SourceResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// CacheKey returns the content address of the result of checking src against env, see Cache.
+func CacheKey(src string, env TypeEnv) string {
+	return cacheKey([]sourceFile{{src: src}}, env)
+}
+
+func cacheKey(srcs []sourceFile, env TypeEnv) string {
+	h := sha256.New()
+	h.Write([]byte(cacheVersion + "\x00"))
+	for _, src := range srcs {
+		h.Write([]byte(src.name + "\x00" + src.src + "\x00"))
+	}
+	decls := make(TypeEnv, len(env))
+	for _, name := range sortedKeys(env) {
+		if pkg, ok := env[name].(*PackageType); ok {
+			hashPackage(h, name, pkg)
+			continue
+		}
+		decls[name] = env[name]
+	}
+	if data, err := EncodeEnv(decls); err == nil {
+		h.Write(data)
+	} else {
+		// a type EncodeEnv does not know, which can only be declared by another package
+		for _, name := range sortedKeys(decls) {
+			h.Write([]byte(name + " " + typeString(decls[name]) + "\n"))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkKey returns the content address of the result of config.Check for files, see
+// Cache.Check.
+func checkKey(config *Config, fset *token.FileSet, files []*ast.File, imp Importer) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(cacheVersion + "\x00check\x00"))
+	opts := config.Options
+	opts.Profile, opts.Fset = nil, nil
+	data, err := json.Marshal(struct {
+		Dir              string
+		Options          Options
+		Analyses         []string
+		Suppress         []ErrorCode
+		Include, Exclude []string
+	}{config.Dir, opts, config.Analyses, config.Suppress, config.Include, config.Exclude})
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+
+	imports := make(map[string]bool)
+	for _, file := range files {
+		name := fset.File(file.Pos()).Name()
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte("\x00" + name + "\x00"))
+		h.Write(src)
+		for _, spec := range file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports[path] = true
+			}
+		}
+	}
+	for _, path := range sortedKeys(imports) {
+		if imp == nil {
+			h.Write([]byte("\x00import " + path))
+			continue
+		}
+		pkg, err := imp(path)
+		if err != nil {
+			// the import is reported by the check, which the error is part of
+			h.Write([]byte("\x00import " + path + ": " + err.Error()))
+			continue
+		}
+		hashPackage(h, path, pkg)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPackage writes the exported API of the package pkg, imported as name, to h.
+func hashPackage(h hash.Hash, name string, pkg *PackageType) {
+	h.Write([]byte("\x00import " + name + " " + pkg.Path + "\n"))
+	for _, decl := range APIReport(pkg.Members) {
+		h.Write([]byte(decl + "\n"))
+	}
+}
+
+// memoImporter returns imp, importing each package once, so that the packages imported
+// for the key of Cache.Check are not imported again by the check.
+func memoImporter(imp Importer) Importer {
+	if imp == nil {
+		return nil
+	}
+	type result struct {
+		pkg *PackageType
+		err error
+	}
+	imported := make(map[string]result)
+	return func(path string) (*PackageType, error) {
+		if r, ok := imported[path]; ok {
+			return r.pkg, r.err
+		}
+		pkg, err := imp(path)
+		imported[path] = result{pkg, err}
+		return pkg, err
+	}
+}
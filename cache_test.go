@@ -0,0 +1,225 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	box := NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"Value": &TypeVariable{Name: "T"}}, nil)
+	env := TypeEnv{"Box": box}
+	src := "package p\n\nvar b = Box[string]{}\n"
+	cache := &Cache{Dir: filepath.Join(t.TempDir(), "cache")}
+
+	got, err := cache.CheckSource(src, env)
+	if err != nil {
+		t.Fatalf("CheckSource() error = %v", err)
+	}
+	if want := CheckSource(src, env); !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckSource() = %+v, want %+v", got, want)
+	}
+
+	// a hit is served from the cache without checking the source again
+	path := filepath.Join(cache.Dir, CacheKey(src, env)+".json")
+	if err := os.WriteFile(path, []byte(`{"diagnostics":[{"line":1,"column":1,"message":"cached"}],"types":null}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = cache.CheckSource(src, env)
+	if err != nil {
+		t.Fatalf("CheckSource() error = %v", err)
+	}
+	if len(got.Diagnostics) != 1 || got.Diagnostics[0].Message != "cached" {
+		t.Errorf("CheckSource() = %+v, want the cached result", got)
+	}
+
+	// unreadable entries are checked again and replaced
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = cache.CheckSource(src, env)
+	if err != nil {
+		t.Fatalf("CheckSource() error = %v", err)
+	}
+	if want := CheckSource(src, env); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckSource() = %+v, want %+v", got, want)
+	}
+	entries, err := os.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cache holds %d entries, want 1", len(entries))
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	point := &StructType{Name: "Point", Fields: map[string]Type{"X": Int, "y": Int}}
+	geo := NewPackageType("example.com/geo", TypeEnv{"Dist": &FunctionType{ParamTypes: []Type{Int}, ReturnType: Int}, "scale": Int})
+	env := TypeEnv{"Point": point, "Origin": point, "geo": geo}
+	src := "package p\n\nvar x = Origin\n"
+	key := CacheKey(src, env)
+
+	tests := []struct {
+		name    string
+		src     string
+		env     TypeEnv
+		wantHit bool
+	}{
+		{name: "unchanged", src: src, env: TypeEnv{"Origin": point, "Point": point, "geo": geo}, wantHit: true},
+		{name: "source changed", src: src + "\nvar y = 1\n", env: env},
+		{name: "exported field changed", src: src, env: TypeEnv{"Point": &StructType{Name: "Point", Fields: map[string]Type{"X": String, "y": Int}}, "Origin": point, "geo": geo}},
+		{name: "unexported field changed", src: src, env: TypeEnv{"Point": &StructType{Name: "Point", Fields: map[string]Type{"X": Int, "y": String}}, "Origin": point, "geo": geo}},
+		{
+			name: "unexported method added",
+			src:  src,
+			env:  TypeEnv{"Point": &StructType{Name: "Point", Fields: point.Fields, Methods: MethodSet{"norm": {Name: "norm", Results: []Type{Int}}}}, "Origin": point, "geo": geo},
+		},
+		{name: "declaration added", src: src, env: TypeEnv{"Point": point, "Origin": point, "geo": geo, "Zero": Int}},
+		{name: "declaration removed", src: src, env: TypeEnv{"Point": point, "geo": geo}},
+		{
+			name:    "unexported member of a dependency changed",
+			src:     src,
+			env:     TypeEnv{"Point": point, "Origin": point, "geo": NewPackageType("example.com/geo", TypeEnv{"Dist": geo.Members["Dist"], "scale": Float64})},
+			wantHit: true,
+		},
+		{
+			name: "exported member of a dependency changed",
+			src:  src,
+			env:  TypeEnv{"Point": point, "Origin": point, "geo": NewPackageType("example.com/geo", TypeEnv{"Dist": &FunctionType{ParamTypes: []Type{Float64}, ReturnType: Int}, "scale": Int})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hit := CacheKey(tt.src, tt.env) == key; hit != tt.wantHit {
+				t.Errorf("CacheKey() hit = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestCacheCheckSources(t *testing.T) {
+	srcs := map[string]string{
+		"a.go": "package p\n\nfunc double(x int) int\n",
+		"b.go": "package p\n\nvar n = double(1)\n",
+	}
+	cache := &Cache{Dir: t.TempDir()}
+	got, err := cache.CheckSources(srcs, nil)
+	if err != nil {
+		t.Fatalf("CheckSources() error = %v", err)
+	}
+	if want := CheckSources(srcs, nil); !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckSources() = %+v, want %+v", got, want)
+	}
+
+	// the key covers the names of the files, and each file
+	files := []sourceFile{{name: "a.go", src: srcs["a.go"]}, {name: "b.go", src: srcs["b.go"]}}
+	path := filepath.Join(cache.Dir, cacheKey(files, nil)+".json")
+	if err := os.WriteFile(path, []byte(`{"diagnostics":[{"file":"a.go","line":1,"column":1,"message":"cached"}],"types":null}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = cache.CheckSources(srcs, nil)
+	if err != nil {
+		t.Fatalf("CheckSources() error = %v", err)
+	}
+	if len(got.Diagnostics) != 1 || got.Diagnostics[0].Message != "cached" {
+		t.Errorf("CheckSources() = %+v, want the cached result", got)
+	}
+	renamed := []sourceFile{{name: "a.go", src: srcs["a.go"]}, {name: "c.go", src: srcs["b.go"]}}
+	if cacheKey(renamed, nil) == cacheKey(files, nil) {
+		t.Errorf("cacheKey() of a renamed file is a hit")
+	}
+	if cacheKey(files[:1], nil) == cacheKey(files, nil) {
+		t.Errorf("cacheKey() of a removed file is a hit")
+	}
+}
+
+func TestCacheCheck(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.go")
+	src := "package p\n\nimport \"example.com/geo\"\n\nvar d int = geo.Dist(1)\n\nvar s string = 1\n"
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dist := &FunctionType{ParamTypes: []Type{Int}, ReturnType: Int}
+	importer := func(members TypeEnv) Importer {
+		return MapImporter(map[string]*PackageType{"example.com/geo": NewPackageType("example.com/geo", members)})
+	}
+	imp := importer(TypeEnv{"Dist": dist, "scale": Int})
+	config := &Config{Dir: dir}
+	parse := func() (*token.FileSet, []*ast.File) {
+		fset := token.NewFileSet()
+		files, diags := ParsePackageDir(fset, dir, Options{})
+		if len(diags) > 0 {
+			t.Fatalf("ParsePackageDir() = %v", diags)
+		}
+		return fset, files
+	}
+
+	cache := &Cache{Dir: filepath.Join(t.TempDir(), "cache")}
+	fset, files := parse()
+	errs, warns, err := cache.Check(config, fset, files, imp)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	wantErrs, wantWarns := config.Check(fset, files, imp)
+	if fmt.Sprint(errs, warns) != fmt.Sprint(wantErrs, wantWarns) || len(errs) != 1 {
+		t.Fatalf("Check() = %v, %v, want %v, %v", errs, warns, wantErrs, wantWarns)
+	}
+
+	// a hit is served from the cache, located in the files parsed again
+	key, err := checkKey(config, fset, files, imp)
+	if err != nil {
+		t.Fatalf("checkKey() error = %v", err)
+	}
+	entry := `{"diagnostics":[{"file":` + strconv.Quote(file) + `,"line":7,"column":5,"message":"cached"}],"types":null}`
+	if err := os.WriteFile(filepath.Join(cache.Dir, key+".json"), []byte(entry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset, files = parse()
+	errs, _, err = cache.Check(config, fset, files, imp)
+	var te *TypeError
+	if err != nil || len(errs) != 1 || !errors.As(errs[0], &te) || errs[0].Error() != "cached" {
+		t.Fatalf("Check() = %v, %v, want the cached error", errs, err)
+	}
+	if got := fset.Position(te.Pos).String(); got != file+":7:5" {
+		t.Errorf("cached error at %s, want %s:7:5", got, file)
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		imp     Importer
+		wantHit bool
+	}{
+		{name: "unchanged", config: config, imp: imp, wantHit: true},
+		{name: "unexported member of a dependency changed", config: config, imp: importer(TypeEnv{"Dist": dist, "scale": String}), wantHit: true},
+		{name: "exported member of a dependency changed", config: config, imp: importer(TypeEnv{"Dist": &FunctionType{ParamTypes: []Type{String}, ReturnType: Int}, "scale": Int})},
+		{name: "configuration changed", config: &Config{Dir: dir, Options: Options{WError: true}}, imp: imp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkKey(tt.config, fset, files, tt.imp)
+			if err != nil {
+				t.Fatalf("checkKey() error = %v", err)
+			}
+			if hit := got == key; hit != tt.wantHit {
+				t.Errorf("checkKey() hit = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+
+	if err := os.WriteFile(file, []byte(src+"\nvar t = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := checkKey(config, fset, files, imp); err != nil || got == key {
+		t.Errorf("checkKey() of a changed file = %v, %v, want a miss", got == key, err)
+	}
+}
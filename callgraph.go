@@ -0,0 +1,163 @@
+package generic
+
+import (
+	"go/ast"
+	"sort"
+)
+
+// CallEdge is a call from a function of the package to a function, declared in the
+// package or bound in the environment. Calls through generic functions carry the
+// type arguments they are instantiated with, whether explicit, like
+// `Map[int, string](xs, f)`, or inferred from the arguments, like `Map(xs, itoa)`.
+type CallEdge struct {
+	Caller   string // the calling function, or "" for package-level initializers
+	Callee   string
+	Call     *ast.CallExpr
+	TypeArgs []Type // nil if the callee is not generic
+}
+
+// CallGraph is the static call graph of a package, built by InferPackage.
+// Only calls to named functions are tracked; calls through function values,
+// methods and function literals are not, nor calls of the parameters and local
+// variables that shadow a function of the package.
+type CallGraph struct {
+	Funcs []string // the functions declared in the package, in source order
+	Edges []CallEdge
+}
+
+// buildCallGraph collects the calls made by the declarations of files. The type arguments
+// of generic callees are those recorded in info for the calls in function bodies, and
+// otherwise those of the instances, like `Map[int, string]`.
+func buildCallGraph(files []*ast.File, info *Info) *CallGraph {
+	cg := &CallGraph{}
+	declared := make(map[string]bool)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				cg.Funcs = append(cg.Funcs, fn.Name.Name)
+				declared[fn.Name.Name] = true
+			}
+		}
+	}
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			caller := ""
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				if fn.Recv != nil {
+					continue
+				}
+				caller = fn.Name.Name
+			}
+			locals := localIdents(decl)
+			ast.Inspect(decl, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if edge, ok := callEdge(call, caller, declared, locals, info); ok {
+					cg.Edges = append(cg.Edges, edge)
+				}
+				return true
+			})
+		}
+	}
+	return cg
+}
+
+// callEdge returns the edge for call if it calls a named function.
+func callEdge(call *ast.CallExpr, caller string, declared map[string]bool, locals map[*ast.Ident]bool, info *Info) (CallEdge, bool) {
+	fun, x := call.Fun, call.Fun
+	for {
+		paren, ok := x.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		fun, x = paren.X, paren.X
+	}
+	switch index := x.(type) {
+	case *ast.IndexExpr:
+		x = index.X
+	case *ast.IndexListExpr:
+		x = index.X
+	}
+	ident, ok := x.(*ast.Ident)
+	if !ok || locals[ident] {
+		return CallEdge{}, false
+	}
+
+	edge := CallEdge{Caller: caller, Callee: ident.Name, Call: call}
+	switch t := info.Uses[ident].(type) {
+	case *FunctionType:
+	case *GenericType:
+		if t.Signature == nil {
+			return CallEdge{}, false
+		}
+		if args, ok := info.typeArgs[call]; ok {
+			edge.TypeArgs = args
+		} else if instance, ok := info.Instances[fun]; ok && instance.Origin() != nil {
+			edge.TypeArgs = instance.Origin().Args
+		}
+	default:
+		if !declared[ident.Name] {
+			return CallEdge{}, false
+		}
+	}
+	return edge, true
+}
+
+// Callees returns the calls made by the function caller, in source order.
+func (cg *CallGraph) Callees(caller string) []CallEdge {
+	var edges []CallEdge
+	for _, edge := range cg.Edges {
+		if edge.Caller == caller {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// Reachable returns the calls reachable from the given functions, including the calls
+// of package-level initializers. Each instantiation of a generic function is a separate
+// call, so the result tells which instantiations can happen at run time.
+func (cg *CallGraph) Reachable(roots ...string) []CallEdge {
+	visited := map[string]bool{"": true}
+	queue := append([]string{""}, roots...)
+	for _, root := range roots {
+		visited[root] = true
+	}
+
+	var reached []CallEdge
+	for len(queue) > 0 {
+		caller := queue[0]
+		queue = queue[1:]
+		for _, edge := range cg.Callees(caller) {
+			reached = append(reached, edge)
+			if !visited[edge.Callee] {
+				visited[edge.Callee] = true
+				queue = append(queue, edge.Callee)
+			}
+		}
+	}
+	sort.SliceStable(reached, func(i, j int) bool { return reached[i].Call.Pos() < reached[j].Call.Pos() })
+	return reached
+}
+
+// Unreachable returns the functions declared in the package that are never called,
+// directly or indirectly, from the given functions or package-level initializers.
+func (cg *CallGraph) Unreachable(roots ...string) []string {
+	live := make(map[string]bool)
+	for _, root := range roots {
+		live[root] = true
+	}
+	for _, edge := range cg.Reachable(roots...) {
+		live[edge.Callee] = true
+	}
+	var dead []string
+	for _, fn := range cg.Funcs {
+		if !live[fn] {
+			dead = append(dead, fn)
+		}
+	}
+	return dead
+}
@@ -0,0 +1,137 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const callGraphSrc = `package p
+
+var initial = setup()
+
+func setup() int { return 0 }
+
+func main() {
+	run()
+	_ = Map[int, string](xs, itoa)
+}
+
+func run() {
+	_ = Map[string, int](ss, atoi)
+	_ = len(xs)
+}
+
+func unused() {
+	helper()
+	_ = Map[int, int](xs, double)
+}
+
+func helper() {}
+`
+
+func TestCallGraph(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	tv, uv := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}
+	mapFunc := NewGenericFunction("Map", TypeParamList{{Name: "T"}, {Name: "U"}}, &FunctionType{
+		ParamTypes: []Type{&SliceType{ElementType: tv}, &FunctionType{ParamTypes: []Type{tv}, ReturnType: uv}},
+		ReturnType: &SliceType{ElementType: uv},
+	})
+	env := TypeEnv{
+		"int":    intType,
+		"string": strType,
+		"Map":    mapFunc,
+		"xs":     &SliceType{ElementType: intType},
+		"ss":     &SliceType{ElementType: strType},
+		"itoa":   &FunctionType{ParamTypes: []Type{intType}, ReturnType: strType},
+		"atoi":   &FunctionType{ParamTypes: []Type{strType}, ReturnType: intType},
+		"double": &FunctionType{ParamTypes: []Type{intType}, ReturnType: intType},
+		"setup":  &FunctionType{ReturnType: intType},
+		"run":    &FunctionType{},
+		"helper": &FunctionType{},
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", callGraphSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	info, err := InferPackage([]*ast.File{file}, env)
+	if err != nil {
+		t.Fatalf("InferPackage() error = %v", err)
+	}
+	cg := info.CallGraph
+
+	callees := cg.Callees("main")
+	if len(callees) != 2 || callees[0].Callee != "run" || callees[1].Callee != "Map" {
+		t.Fatalf("Callees(main) = %v, want run and Map", callees)
+	}
+	if callees[0].TypeArgs != nil {
+		t.Errorf("call to run has type arguments %v", callees[0].TypeArgs)
+	}
+	if args := callees[1].TypeArgs; !typeListsEqual(args, []Type{intType, strType}) {
+		t.Errorf("call to Map type arguments = %v, want [int string]", args)
+	}
+
+	// reachable instantiations of Map from main
+	var instantiations [][]Type
+	for _, edge := range cg.Reachable("main") {
+		if edge.Callee == "Map" {
+			instantiations = append(instantiations, edge.TypeArgs)
+		}
+	}
+	want := [][]Type{{intType, strType}, {strType, intType}}
+	if len(instantiations) != len(want) {
+		t.Fatalf("reachable Map instantiations = %v, want %v", instantiations, want)
+	}
+	for i := range want {
+		if !typeListsEqual(instantiations[i], want[i]) {
+			t.Errorf("reachable Map instantiation %d = %v, want %v", i, instantiations[i], want[i])
+		}
+	}
+
+	dead := cg.Unreachable("main")
+	if len(dead) != 2 || dead[0] != "unused" || dead[1] != "helper" {
+		t.Errorf("Unreachable(main) = %v, want [unused helper]", dead)
+	}
+}
+
+func TestCallGraphInferredAndShadowed(t *testing.T) {
+	const src = `package p
+
+func Map[T, U any](s []T, f func(T) U) []U { return nil }
+
+func itoa(n int) string { return "" }
+
+func main() {
+	xs := []int{1, 2}
+	_ = Map(xs, itoa)
+	itoa := func(n int) string { return "" }
+	_ = itoa(1)
+}
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	info, err := InferPackage([]*ast.File{file}, env)
+	if err != nil {
+		t.Fatalf("InferPackage() error = %v", err)
+	}
+
+	callees := info.CallGraph.Callees("main")
+	if len(callees) != 1 || callees[0].Callee != "Map" {
+		t.Fatalf("Callees(main) = %v, want Map only", callees)
+	}
+	if args := callees[0].TypeArgs; !typeListsEqual(args, []Type{Int, String}) {
+		t.Errorf("call to Map type arguments = %v, want [int string]", args)
+	}
+	if dead := info.CallGraph.Unreachable("main"); len(dead) != 1 || dead[0] != "itoa" {
+		t.Errorf("Unreachable(main) = %v, want [itoa]", dead)
+	}
+}
@@ -0,0 +1,68 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// chanDirs maps the directions of channel type expressions to those of ChanType.
+var chanDirs = map[ast.ChanDir]ChanDir{
+	ast.SEND | ast.RECV: SendRecv,
+	ast.SEND:            SendOnly,
+	ast.RECV:            RecvOnly,
+}
+
+// inferChanType infers the channel type written as expr, like `chan int` or `<-chan T`.
+func inferChanType(expr *ast.ChanType, env TypeEnv, ctx *InferenceContext) (*ChanType, error) {
+	elem, err := InferType(expr.Value, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ChanType{Dir: chanDirs[expr.Dir], ElementType: elem}, nil
+}
+
+// inferReceive infers the type of a receive `<-ch`, the element type of the channel.
+func inferReceive(expr *ast.UnaryExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	ct, err := inferChanOperand(expr.X, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ct.Dir == SendOnly {
+		return nil, fmt.Errorf("%w: cannot receive from send-only channel %s (%s)", ErrInvalidOperation, types.ExprString(expr.X), FormatGo(ct))
+	}
+	return ct.ElementType, nil
+}
+
+// inferSendStmt checks a send statement `ch <- v`: the channel must allow sending,
+// and the value must have its element type.
+func inferSendStmt(stmt *ast.SendStmt, env TypeEnv, ctx *InferenceContext) error {
+	ct, err := inferChanOperand(stmt.Chan, env, ctx)
+	if err != nil {
+		return err
+	}
+	if ct.Dir == RecvOnly {
+		return fmt.Errorf("%w: cannot send to receive-only channel %s (%s)", ErrInvalidOperation, types.ExprString(stmt.Chan), FormatGo(ct))
+	}
+	vt, err := InferType(stmt.Value, env, ctx.Child(WithExpectedType(ct.ElementType)))
+	if err != nil {
+		return err
+	}
+	if err := unifyInto(ct.ElementType, vt, env, ctx); err != nil {
+		return fmt.Errorf("cannot use %s (type %s) as %s in send: %w", types.ExprString(stmt.Value), FormatGo(vt), FormatGo(ct.ElementType), err)
+	}
+	return nil
+}
+
+// inferChanOperand infers the type of the channel operand of a send or receive.
+func inferChanOperand(expr ast.Expr, env TypeEnv, ctx *InferenceContext) (*ChanType, error) {
+	t, err := InferType(expr, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	ct, ok := underlying(t).(*ChanType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (type %s) is not a channel", ErrInvalidOperation, types.ExprString(expr), FormatGo(t))
+	}
+	return ct, nil
+}
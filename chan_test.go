@@ -0,0 +1,147 @@
+package generic
+
+import (
+	"testing"
+)
+
+func TestInferChan(t *testing.T) {
+	env := TypeEnv{
+		"ch":     &ChanType{Dir: SendRecv, ElementType: Int},
+		"in":     &ChanType{Dir: RecvOnly, ElementType: String},
+		"out":    &ChanType{Dir: SendOnly, ElementType: String},
+		"done":   &ChanType{Dir: SendRecv, ElementType: &StructType{Fields: map[string]Type{}}},
+		"n":      Int,
+		"s":      String,
+		"int":    Int,
+		"string": String,
+	}
+
+	tests := []struct {
+		src     string
+		want    Type
+		wantErr bool
+	}{
+		{src: "<-ch", want: Int},
+		{src: "<-in", want: String},
+		{src: "<-done", want: &StructType{Fields: map[string]Type{}}},
+		{src: "<-ch + 1", want: Int},
+		{src: "<-out", wantErr: true},
+		{src: "<-n", wantErr: true},
+		{src: "make(chan int)", want: &ChanType{Dir: SendRecv, ElementType: Int}},
+		{src: "make(<-chan []string)", want: &ChanType{Dir: RecvOnly, ElementType: &SliceType{ElementType: String}}},
+		{src: "ch == ch", want: Bool},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("InferType() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if !TypesEqual(got, tt.want) {
+				t.Errorf("InferType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferChanStmt(t *testing.T) {
+	tests := []struct {
+		src     string
+		wantErr bool
+	}{
+		{src: "ch <- 1"},
+		{src: "out <- s"},
+		{src: "ch <- s", wantErr: true},
+		{src: "in <- s", wantErr: true},
+		{src: "n <- 1", wantErr: true},
+		{src: "v := <-ch"},
+		{src: "v, ok := <-in"},
+		{src: "v, ok, x := <-in", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			env := TypeEnv{
+				"ch":  &ChanType{Dir: SendRecv, ElementType: Int},
+				"in":  &ChanType{Dir: RecvOnly, ElementType: String},
+				"out": &ChanType{Dir: SendOnly, ElementType: String},
+				"n":   Int,
+				"s":   String,
+			}
+			_, err := InferType(parseStmt(t, tt.src), env, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InferType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	env := TypeEnv{"in": &ChanType{Dir: RecvOnly, ElementType: String}}
+	if _, err := InferType(parseStmt(t, "v, ok := <-in"), env, nil); err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	if !TypesEqual(unwrapObject(env["v"]), String) || !TypesEqual(unwrapObject(env["ok"]), Bool) {
+		t.Errorf("v, ok = %v, %v, want string, bool", env["v"], env["ok"])
+	}
+}
+
+func TestUnifyChanDirection(t *testing.T) {
+	bidi := &ChanType{Dir: SendRecv, ElementType: Int}
+	recv := &ChanType{Dir: RecvOnly, ElementType: Int}
+	send := &ChanType{Dir: SendOnly, ElementType: Int}
+
+	tests := []struct {
+		name    string
+		t1, t2  Type
+		wantErr bool
+	}{
+		{name: "same direction", t1: recv, t2: &ChanType{Dir: RecvOnly, ElementType: Int}},
+		{name: "bidirectional as receive-only", t1: recv, t2: bidi},
+		{name: "bidirectional as send-only", t1: send, t2: bidi},
+		{name: "receive-only as bidirectional", t1: bidi, t2: recv, wantErr: true},
+		{name: "send-only as bidirectional", t1: bidi, t2: send, wantErr: true},
+		{name: "receive-only as send-only", t1: send, t2: recv, wantErr: true},
+		{name: "element mismatch", t1: bidi, t2: &ChanType{Dir: SendRecv, ElementType: String}, wantErr: true},
+		{name: "not a channel", t1: bidi, t2: &SliceType{ElementType: Int}, wantErr: true},
+		{name: "element type variable", t1: &ChanType{Dir: RecvOnly, ElementType: &TypeVariable{Name: "T"}}, t2: bidi},
+		{name: "bidirectional element as receive-only", t1: &SliceType{ElementType: recv}, t2: &SliceType{ElementType: bidi}, wantErr: true},
+		{name: "bidirectional value as send-only", t1: &MapType{KeyType: String, ValueType: send}, t2: &MapType{KeyType: String, ValueType: bidi}, wantErr: true},
+		{name: "pointer to bidirectional as receive-only", t1: &PointerType{Base: recv}, t2: &PointerType{Base: bidi}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unify(tt.t1, tt.t2, TypeEnv{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatChan(t *testing.T) {
+	tests := []struct {
+		typ  Type
+		want string
+	}{
+		{&ChanType{Dir: SendRecv, ElementType: Int}, "chan int"},
+		{&ChanType{Dir: SendOnly, ElementType: Int}, "chan<- int"},
+		{&ChanType{Dir: RecvOnly, ElementType: Int}, "<-chan int"},
+		{&ChanType{Dir: SendRecv, ElementType: &ChanType{Dir: RecvOnly, ElementType: Int}}, "chan (<-chan int)"},
+		{&ChanType{Dir: SendOnly, ElementType: &ChanType{Dir: RecvOnly, ElementType: Int}}, "chan<- <-chan int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatGo(tt.typ); got != tt.want {
+				t.Errorf("FormatGo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+// Command gencheck checks the Go package in a directory, the current one by default:
+//
+//	gencheck [-config file] [-cache dir] [-profile-inference n] [-baseline generate|compare] [-baseline-file file] [dir]
+//
+// Its settings are those of the .gencheck.yaml file of the directory or of its closest
+// parent, or of the file given with -config; see generic.Config for the format. The
+// diagnostics are printed one per line, prefixed by their position, and gencheck exits
+// with status 1 if any of them is an error.
+//
+// With -cache, the diagnostics are stored in the directory given, and a package whose
+// files, configuration and imported APIs are unchanged is not inferred again; see
+// generic.Cache.
+//
+// With -profile-inference, the n declarations and instantiations slowest to infer and
+// needing the most unifications are printed after the diagnostics, see generic.Profile.
+// The package is inferred even if its diagnostics are cached.
+//
+// With -baseline generate, the errors are written to the baseline file instead, and with
+// -baseline compare, only the errors not in it are reported, so that adopting gencheck on
+// existing code only fails on new errors; see generic.Baseline. The baseline file is
+// .gencheck.baseline, next to the configuration file, unless -baseline-file is given.
+//
+// The stub subcommand prints stubs of the methods a type of the package is missing to
+// implement an interface, like `gencheck stub List Container[int]`, see generic.GenerateStubs:
+//
+//	gencheck stub [-config file] [-dir dir] type interface
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+
+	"github.com/notJoon/generic"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "stub" {
+		stub(os.Args[2:])
+		return
+	}
+	check(os.Args[1:])
+}
+
+// check checks the package of the directory given in args.
+func check(args []string) {
+	flags := flag.NewFlagSet("gencheck", flag.ExitOnError)
+	configFile := flags.String("config", "", "read the configuration from `file` rather than "+generic.ConfigFile)
+	cacheDir := flags.String("cache", "", "store the diagnostics in the cache `dir`ectory, and reuse them while the package is unchanged")
+	profileTop := flags.Int("profile-inference", 0, "print the `n` declarations and instantiations that are the most costly to infer")
+	baseline := flags.String("baseline", "", "generate the baseline file, or compare the errors with it (`mode`: generate or compare)")
+	baselinePath := flags.String("baseline-file", "", "the baseline `file`, "+baselineFile+" next to the configuration by default")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gencheck [-config file] [-cache dir] [-profile-inference n] [-baseline generate|compare] [-baseline-file file] [dir]\n"+
+			"       gencheck stub [-config file] [-dir dir] type interface\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	dir := "."
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		dir = flags.Arg(0)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *baseline != "" && *baseline != "generate" && *baseline != "compare" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config, err := loadConfig(dir, *configFile)
+	if err != nil {
+		fatal(err)
+	}
+	fset := token.NewFileSet()
+	files, diags := generic.ParsePackageDir(fset, dir, config.Options)
+	parseErrs, parseWarns := config.Options.Report(diags)
+	var errs, warns []error
+	if *profileTop > 0 {
+		config.Options.Profile = &generic.Profile{}
+	}
+	if *cacheDir != "" && *profileTop == 0 {
+		cache := &generic.Cache{Dir: *cacheDir}
+		if errs, warns, err = cache.Check(config, fset, files, goImporter(fset)); err != nil {
+			fmt.Fprintf(os.Stderr, "gencheck: cache: %v\n", err)
+		}
+	} else {
+		errs, warns = config.Check(fset, files, goImporter(fset))
+	}
+	errs = append(parseErrs, errs...)
+
+	if *baselinePath == "" {
+		*baselinePath = filepath.Join(config.Dir, baselineFile)
+	}
+	switch *baseline {
+	case "generate":
+		if err := writeBaseline(*baselinePath, generic.NewBaseline(fset, errs)); err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "gencheck: %d errors written to %s\n", len(errs), *baselinePath)
+		return
+	case "compare":
+		b, err := readBaseline(*baselinePath)
+		if err != nil {
+			fatal(err)
+		}
+		errs = b.New(fset, errs)
+	}
+
+	for _, w := range append(parseWarns, warns...) {
+		fmt.Fprintf(os.Stderr, "%swarning: %v\n", position(fset, w), w)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s%v\n", position(fset, err), err)
+	}
+	if p := config.Options.Profile; p != nil {
+		fmt.Fprint(os.Stderr, p.Report(fset, *profileTop))
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// baselineFile is the name of the baseline file of a repository, next to its configuration.
+const baselineFile = ".gencheck.baseline"
+
+func writeBaseline(file string, b generic.Baseline) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := b.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readBaseline(file string) (generic.Baseline, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := generic.ReadBaseline(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return b, nil
+}
+
+// stub prints the stubs of the methods the type named by args[0] is missing to implement
+// the interface args[1], which may be qualified or instantiated, like `io.Reader` or
+// `Container[int]`.
+func stub(args []string) {
+	flags := flag.NewFlagSet("gencheck stub", flag.ExitOnError)
+	configFile := flags.String("config", "", "read the configuration from `file` rather than "+generic.ConfigFile)
+	dir := flags.String("dir", ".", "the `dir`ectory of the package declaring the type")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gencheck stub [-config file] [-dir dir] type interface\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config, err := loadConfig(*dir, *configFile)
+	if err != nil {
+		fatal(err)
+	}
+	fset := token.NewFileSet()
+	files, _ := generic.ParsePackageDir(fset, *dir, config.Options)
+	// the stubs only need the declarations that could be inferred
+	env, _ := generic.BuildEnvWithImporter(files, goImporter(fset))
+
+	recv, ok := env[flags.Arg(0)]
+	if !ok {
+		fatal(fmt.Errorf("type %s not declared in %s", flags.Arg(0), *dir))
+	}
+	expr, err := parser.ParseExpr(flags.Arg(1))
+	if err != nil {
+		fatal(fmt.Errorf("interface %s: %v", flags.Arg(1), err))
+	}
+	// the interface may use the type parameters of a generic type, like `Container[T]`
+	scope := make(generic.TypeEnv, len(env))
+	for name, t := range env {
+		scope[name] = t
+	}
+	if gt, ok := recv.(*generic.GenericType); ok {
+		for _, p := range gt.TypeParamList() {
+			scope[p.Name] = &generic.TypeVariable{Name: p.Name}
+		}
+	}
+	iface, err := generic.InferType(expr, scope, nil)
+	if err != nil {
+		fatal(fmt.Errorf("interface %s: %v", flags.Arg(1), err))
+	}
+	stubs, err := generic.GenerateStubs(recv, iface)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Print(stubs)
+}
+
+func goImporter(fset *token.FileSet) generic.Importer {
+	return generic.GoImporter(importer.ForCompiler(fset, "source", nil))
+}
+
+// loadConfig returns the configuration of the package in dir, read from file if set.
+func loadConfig(dir, file string) (*generic.Config, error) {
+	if file == "" {
+		return generic.LoadConfig(dir)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	config, err := generic.ReadConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if config.Dir, err = filepath.Abs(filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// position returns the position of the diagnostic err followed by ": ", if it has one.
+func position(fset *token.FileSet, err error) string {
+	var te *generic.TypeError
+	if errors.As(err, &te) && te.Pos.IsValid() {
+		return fset.Position(te.Pos).String() + ": "
+	}
+	return ""
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gencheck: %v\n", err)
+	os.Exit(2)
+}
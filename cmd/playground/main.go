@@ -0,0 +1,34 @@
+//go:build js && wasm
+
+// Command playground exposes the checker to JavaScript for a web playground.
+// Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o playground.wasm ./cmd/playground
+//
+// and load it with the wasm_exec.js shipped with Go. It defines a global function
+// checkSource(src) returning the JSON encoding of a generic.SourceResult:
+// the diagnostics of the source and the types of its identifiers and instantiations.
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/notJoon/generic"
+)
+
+func main() {
+	js.Global().Set("checkSource", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) != 1 || args[0].Type() != js.TypeString {
+			return js.Global().Get("Error").New("checkSource: expected a source string")
+		}
+		result := generic.CheckSource(args[0].String(), nil)
+		out, err := json.Marshal(result)
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return string(out)
+	}))
+	// keep the exported function alive
+	select {}
+}
@@ -0,0 +1,160 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrBreakingChange = errors.New("breaking change")
+
+// Compatibility classifies the change of a function signature between two versions of an API.
+// The kinds are ordered by severity.
+type Compatibility int
+
+const (
+	// Identical signatures, up to the names of the type parameters.
+	Identical Compatibility = iota
+	// WidenedConstraint signatures accept more type arguments than before.
+	WidenedConstraint
+	// AddedVariadic signatures have a new trailing variadic parameter,
+	// so every existing call still compiles.
+	AddedVariadic
+	// Breaking changes make some existing calls fail to compile.
+	Breaking
+)
+
+func (c Compatibility) String() string {
+	switch c {
+	case Identical:
+		return "identical"
+	case WidenedConstraint:
+		return "widened constraint"
+	case AddedVariadic:
+		return "added variadic"
+	case Breaking:
+		return "breaking"
+	}
+	return fmt.Sprintf("Compatibility(%d)", int(c))
+}
+
+// CompatibleSignatures classifies the change from the function old to the function new.
+// Both are either function types or generic functions, see NewGenericFunction.
+// For breaking changes, the error wraps ErrBreakingChange and tells what breaks.
+//
+// Type parameters are matched by position, so renaming them is not a change.
+// Constraints are compared on the predeclared types, the types they list and a few
+// composite types; method requirements must be the same or dropped.
+func CompatibleSignatures(old, new Type) (Compatibility, error) {
+	oldParams, oldSig, err := genericSignature(old)
+	if err != nil {
+		return Breaking, err
+	}
+	newParams, newSig, err := genericSignature(new)
+	if err != nil {
+		return Breaking, err
+	}
+	if len(oldParams) != len(newParams) {
+		return Breaking, fmt.Errorf("%w: %d type parameters, was %d", ErrBreakingChange, len(newParams), len(oldParams))
+	}
+
+	// refer to the type parameters of new by the names they have in old
+	from, to := newParams.Vars(), oldParams.Vars()
+	newSig = substituteTypeParams(newSig, from, to, NewTypeVisitor()).(*FunctionType)
+
+	result := Identical
+	for i := range oldParams {
+		oldConstraint := oldParams[i].Constraint
+		newConstraint := newParams[i].Constraint
+		if newConstraint != nil {
+			newConstraint = substituteTypeParams(newConstraint, from, to, NewTypeVisitor()).(*TypeConstraint)
+		}
+		if (oldConstraint == nil && newConstraint == nil) ||
+			(oldConstraint != nil && newConstraint != nil && TypesEqual(oldConstraint, newConstraint)) {
+			continue
+		}
+		if !constraintImplies(oldConstraint, newConstraint) {
+			return Breaking, fmt.Errorf("%w: constraint of type parameter %s narrowed from %s to %s",
+				ErrBreakingChange, oldParams.name(i), FormatGo(oldConstraint), FormatGo(newConstraint))
+		}
+		result = WidenedConstraint
+	}
+
+	if !TypesEqual(oldSig.ReturnType, newSig.ReturnType) {
+		return Breaking, fmt.Errorf("%w: results changed from %s to %s", ErrBreakingChange, FormatGo(oldSig.ReturnType), FormatGo(newSig.ReturnType))
+	}
+	params := newSig.ParamTypes
+	if !oldSig.IsVariadic && newSig.IsVariadic && len(params) == len(oldSig.ParamTypes)+1 {
+		params = params[:len(oldSig.ParamTypes)]
+		result = AddedVariadic
+	} else if oldSig.IsVariadic != newSig.IsVariadic {
+		return Breaking, fmt.Errorf("%w: variadic parameter changed", ErrBreakingChange)
+	}
+	if len(params) != len(oldSig.ParamTypes) {
+		return Breaking, fmt.Errorf("%w: %d parameters, was %d", ErrBreakingChange, len(newSig.ParamTypes), len(oldSig.ParamTypes))
+	}
+	for i := range params {
+		if !TypesEqual(oldSig.ParamTypes[i], params[i]) {
+			return Breaking, fmt.Errorf("%w: parameter %d changed from %s to %s", ErrBreakingChange, i+1, FormatGo(oldSig.ParamTypes[i]), FormatGo(params[i]))
+		}
+	}
+	return result, nil
+}
+
+// genericSignature returns the type parameters and the signature of a function or generic function.
+func genericSignature(t Type) (TypeParamList, *FunctionType, error) {
+	switch t := t.(type) {
+	case *FunctionType:
+		return nil, t, nil
+	case *GenericType:
+		if t.Signature != nil {
+			return t.TypeParamList(), t.Signature, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s is not a function", FormatGo(t))
+}
+
+// compositeSamples stand for the types that are neither predeclared nor listed by a constraint.
+var compositeSamples = []Type{
+	&SliceType{ElementType: Int},
+	&PointerType{Base: Int},
+	&FunctionType{},
+}
+
+// constraintImplies reports whether every type satisfying old also satisfies new.
+// A nil constraint is satisfied by any type.
+func constraintImplies(old, new *TypeConstraint) bool {
+	if new == nil {
+		return true
+	}
+	// the methods required by new must already be required by old
+	for _, iface := range new.Interfaces {
+		found := false
+		if old != nil {
+			for _, oldIface := range old.Interfaces {
+				found = found || (oldIface.Name == iface.Name && methodSetsEqual(oldIface.Methods, iface.Methods))
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	candidates := append(append([]Type{}, predeclaredTypes...), compositeSamples...)
+	var oldTerms TypeConstraint
+	if old != nil {
+		oldTerms = *old
+		for _, term := range old.Types {
+			candidates = append(candidates, termCandidates(term)...)
+		}
+	}
+	oldTerms.Interfaces = nil
+	newTerms := *new
+	newTerms.Interfaces = nil
+
+	for _, t := range candidates {
+		if checkConstraint(t, oldTerms) && !checkConstraint(t, newTerms) {
+			return false
+		}
+	}
+	return true
+}
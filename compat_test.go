@@ -0,0 +1,110 @@
+package generic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompatibleSignatures(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	tv, uv := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}
+	constraint := func(builtin string, types ...Type) *TypeConstraint {
+		return &TypeConstraint{BuiltinConstraint: builtin, Types: types, Union: len(types) > 0}
+	}
+	fn := func(params ...Type) *FunctionType {
+		return &FunctionType{ParamTypes: params, ReturnType: intType}
+	}
+	generic := func(name string, c *TypeConstraint, sig *FunctionType) *GenericType {
+		return NewGenericFunction("Sum", TypeParamList{{Name: name, Constraint: c}}, sig)
+	}
+	stringer := Interface{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{strType}}}}
+
+	tests := []struct {
+		name      string
+		old, new  Type
+		want      Compatibility
+		wantError string
+	}{
+		{name: "identical functions", old: fn(intType), new: fn(intType), want: Identical},
+		{
+			name: "renamed type parameter",
+			old:  generic("T", constraint(ConstraintInteger), fn(&SliceType{ElementType: tv})),
+			new:  generic("U", constraint(ConstraintInteger), fn(&SliceType{ElementType: uv})),
+			want: Identical,
+		},
+		{
+			name: "integer to ordered",
+			old:  generic("T", constraint(ConstraintInteger), fn(tv)),
+			new:  generic("T", constraint(ConstraintOrdered), fn(tv)),
+			want: WidenedConstraint,
+		},
+		{
+			name: "union gains a term",
+			old:  generic("T", constraint("", intType), fn(tv)),
+			new:  generic("T", constraint("", intType, strType), fn(tv)),
+			want: WidenedConstraint,
+		},
+		{
+			name: "constraint dropped",
+			old:  generic("T", constraint(ConstraintComparable), fn(tv)),
+			new:  generic("T", nil, fn(tv)),
+			want: WidenedConstraint,
+		},
+		{
+			name: "method requirement dropped",
+			old:  generic("T", &TypeConstraint{Interfaces: []Interface{stringer}}, fn(tv)),
+			new:  generic("T", constraint(ConstraintAny), fn(tv)),
+			want: WidenedConstraint,
+		},
+		{name: "added variadic", old: fn(intType), new: &FunctionType{ParamTypes: []Type{intType, &SliceType{ElementType: strType}}, ReturnType: intType, IsVariadic: true}, want: AddedVariadic},
+		{
+			name:      "ordered to integer",
+			old:       generic("T", constraint(ConstraintOrdered), fn(tv)),
+			new:       generic("T", constraint(ConstraintInteger), fn(tv)),
+			want:      Breaking,
+			wantError: "breaking change: constraint of type parameter T narrowed",
+		},
+		{
+			name:      "any to comparable",
+			old:       generic("T", nil, fn(tv)),
+			new:       generic("T", constraint(ConstraintComparable), fn(tv)),
+			want:      Breaking,
+			wantError: "constraint of type parameter T narrowed",
+		},
+		{
+			name:      "method requirement added",
+			old:       generic("T", nil, fn(tv)),
+			new:       generic("T", &TypeConstraint{Interfaces: []Interface{stringer}}, fn(tv)),
+			want:      Breaking,
+			wantError: "constraint of type parameter T narrowed",
+		},
+		{name: "parameter changed", old: fn(intType), new: fn(strType), want: Breaking, wantError: "breaking change: parameter 1 changed from int to string"},
+		{name: "parameter added", old: fn(intType), new: fn(intType, intType), want: Breaking, wantError: "breaking change: 2 parameters, was 1"},
+		{name: "result changed", old: fn(), new: &FunctionType{ReturnType: strType}, want: Breaking, wantError: "breaking change: results changed from int to string"},
+		{name: "type parameter added", old: fn(intType), new: generic("T", nil, fn(intType)), want: Breaking, wantError: "breaking change: 1 type parameters, was 0"},
+		{name: "not a function", old: fn(), new: intType, want: Breaking, wantError: "int is not a function"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompatibleSignatures(tt.old, tt.new)
+			if got != tt.want {
+				t.Errorf("CompatibleSignatures() = %v, want %v (error %v)", got, tt.want, err)
+			}
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("CompatibleSignatures() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("CompatibleSignatures() error = %v, want %q", err, tt.wantError)
+			}
+			if tt.want == Breaking && tt.name != "not a function" && !errors.Is(err, ErrBreakingChange) {
+				t.Errorf("CompatibleSignatures() error = %v, want %v", err, ErrBreakingChange)
+			}
+		})
+	}
+}
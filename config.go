@@ -0,0 +1,409 @@
+package generic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/version"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfigFile is the name of the configuration file of a repository, see LoadConfig.
+const ConfigFile = ".gencheck.yaml"
+
+// Analyses are the names of the analyses a Config can enable, beyond the inference of
+// the files: "fieldorder" suggests field orders, see CheckFieldOrder, and "sealed" checks
+// the type switches over sealed interfaces, see CheckSealedSwitches.
+var Analyses = []string{"fieldorder", "sealed"}
+
+// Config is the configuration of a check, shared by the users of a repository so that
+// they need not repeat the same flags. It is read from a ConfigFile like
+//
+//	go: go1.21
+//	max-errors: 50
+//	werror: true
+//	tags: [integration]
+//	analyses: [fieldorder]
+//	suppress:
+//	  - UnsatisfiedConstraint
+//	exclude: ["*_gen.go", "internal/legacy/*"]
+//
+// The keys are go, max-errors, werror, parallel, goos, goarch, tags, analyses, suppress,
+// include and exclude. The values are scalars or lists of scalars, in brackets or one per
+// line after a dash; only this subset of YAML is read.
+type Config struct {
+	// Dir is the directory of the configuration file, which the globs are relative to.
+	Dir string
+
+	// Options are the options of the check: go, max-errors, werror, parallel, goos, goarch
+	// and tags.
+	Options Options
+
+	// Analyses are the names of the analyses enabled, see Analyses.
+	Analyses []string
+
+	// Suppress are the codes of the errors not reported, by name, like "MismatchedTypes".
+	Suppress []ErrorCode
+
+	// Include and Exclude select the files checked by glob, see Config.Includes.
+	Include, Exclude []string
+}
+
+// LoadConfig reads the ConfigFile of dir or of its closest parent directory holding one,
+// so that a check of any package of a repository uses the configuration at its root.
+// Without one, the configuration is empty, with Dir set to dir.
+func LoadConfig(dir string) (*Config, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for d := abs; ; {
+		f, err := os.Open(filepath.Join(d, ConfigFile))
+		if err == nil {
+			defer f.Close()
+			c, err := ReadConfig(f)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Join(d, ConfigFile), err)
+			}
+			c.Dir = d
+			return c, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return &Config{Dir: abs}, nil
+		}
+		d = parent
+	}
+}
+
+// ReadConfig reads a configuration in the format of ConfigFile. Its Dir is left empty.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := &Config{}
+	var key string // the key of the list being read one item per line
+	var keyLine int
+	var list []string
+	flush := func() error {
+		if key == "" {
+			return nil
+		}
+		err := c.setList(key, list)
+		key, list = "", nil
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(text, "-"); ok {
+			if key == "" {
+				return nil, fmt.Errorf("config line %d: list item outside of a list", line)
+			}
+			v, err := unquoteValue(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("config line %d: %v", line, err)
+			}
+			list = append(list, v)
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, fmt.Errorf("config line %d: %v", keyLine, err)
+		}
+
+		name, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("config line %d: expected key: value", line)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		var err error
+		switch {
+		case value == "":
+			key, keyLine = name, line // the items follow
+			if list, known := configKeys[name]; !known {
+				err = fmt.Errorf("unknown key %s", name)
+			} else if !list {
+				err = fmt.Errorf("missing value for %s", name)
+			}
+		case strings.HasPrefix(value, "["):
+			var items []string
+			if items, err = splitFlowList(value); err == nil {
+				err = c.setList(name, items)
+			}
+		default:
+			if value, err = unquoteValue(value); err == nil {
+				err = c.set(name, value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("config line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, fmt.Errorf("config line %d: %v", keyLine, err)
+	}
+	return c, nil
+}
+
+// set sets the scalar of the key name to value.
+func (c *Config) set(name, value string) error {
+	var err error
+	switch name {
+	case "go":
+		if !version.IsValid(value) {
+			return fmt.Errorf("invalid go version %q, like go1.21", value)
+		}
+		c.Options.GoVersion = value
+	case "max-errors":
+		c.Options.MaxErrors, err = strconv.Atoi(value)
+	case "werror":
+		c.Options.WError, err = strconv.ParseBool(value)
+	case "parallel":
+		c.Options.Parallel, err = strconv.ParseBool(value)
+	case "goos":
+		c.Options.GOOS = value
+	case "goarch":
+		c.Options.GOARCH = value
+	default:
+		if configKeys[name] {
+			return c.setList(name, []string{value})
+		}
+		return fmt.Errorf("unknown key %s", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return nil
+}
+
+// setList sets the list of the key name to items.
+func (c *Config) setList(name string, items []string) error {
+	switch name {
+	case "tags":
+		c.Options.BuildTags = items
+	case "analyses":
+		for _, a := range items {
+			if !isAnalysis(a) {
+				return fmt.Errorf("unknown analysis %s, want one of %s", a, strings.Join(Analyses, ", "))
+			}
+		}
+		c.Analyses = items
+	case "suppress":
+		c.Suppress = nil
+		for _, name := range items {
+			code, ok := errorCodeNamed(name)
+			if !ok {
+				return fmt.Errorf("unknown error code %s", name)
+			}
+			c.Suppress = append(c.Suppress, code)
+		}
+	case "include", "exclude":
+		for _, pattern := range items {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid %s pattern %q: %v", name, pattern, err)
+			}
+		}
+		if name == "include" {
+			c.Include = items
+		} else {
+			c.Exclude = items
+		}
+	default:
+		if _, known := configKeys[name]; known {
+			return fmt.Errorf("%s is not a list", name)
+		}
+		return fmt.Errorf("unknown key %s", name)
+	}
+	return nil
+}
+
+// configKeys are the keys of a configuration, mapped to whether they hold a list.
+var configKeys = map[string]bool{
+	"go": false, "max-errors": false, "werror": false, "parallel": false, "goos": false, "goarch": false,
+	"tags": true, "analyses": true, "suppress": true, "include": true, "exclude": true,
+}
+
+// stripComment removes the comment of a line, from a # at its start or after a blank,
+// outside of quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitFlowList returns the items of a list in brackets, like `[a, "b c"]`.
+func splitFlowList(value string) ([]string, error) {
+	inner, ok := strings.CutSuffix(strings.TrimPrefix(value, "["), "]")
+	if !ok {
+		return nil, fmt.Errorf("missing ] in %s", value)
+	}
+	if strings.TrimSpace(inner) == "" {
+		return nil, nil
+	}
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		v, err := unquoteValue(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// unquoteValue returns the scalar value, unquoted if it is in double or single quotes.
+func unquoteValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("unterminated quote in %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case value == "":
+		return "", fmt.Errorf("empty value")
+	}
+	return value, nil
+}
+
+func isAnalysis(name string) bool {
+	for _, a := range Analyses {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCodeNamed returns the error code whose String is name.
+func errorCodeNamed(name string) (ErrorCode, bool) {
+	for c := CodeUnknown; c <= CodeCircularReference; c++ {
+		if c.String() == name {
+			return c, true
+		}
+	}
+	return CodeUnknown, false
+}
+
+// Includes reports whether the file name is checked: it must match one of the Include
+// globs, if any, and none of the Exclude globs. name is relative to Dir, or made so if it
+// is absolute. A glob with a slash matches the whole slash-separated name, like
+// "internal/*/gen.go", and one without a slash its last element, like "*_gen.go".
+func (c *Config) Includes(name string) bool {
+	if filepath.IsAbs(name) && c.Dir != "" {
+		if rel, err := filepath.Rel(c.Dir, name); err == nil {
+			name = rel
+		}
+	}
+	name = filepath.ToSlash(name)
+	matches := func(patterns []string) bool {
+		for _, p := range patterns {
+			target := name
+			if !strings.Contains(p, "/") {
+				target = path.Base(name)
+			}
+			if ok, _ := path.Match(p, target); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(c.Include) > 0 && !matches(c.Include) {
+		return false
+	}
+	return !matches(c.Exclude)
+}
+
+// Suppressed reports whether the diagnostic err has one of the codes of Suppress.
+func (c *Config) Suppressed(err error) bool {
+	code := codeOf(err)
+	var te *TypeError
+	if errors.As(err, &te) {
+		code = te.Code
+	}
+	for _, s := range c.Suppress {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Check checks the files of a package, parsed in fset, with their imports resolved by imp,
+// like BuildEnvWithImporter. The files Includes leaves out are not checked, but their
+// declarations are still in the environment, since the others may use them. The files are
+// inferred with Options, like InferPackageWithOptions, and the enabled analyses run after
+// the inference. The diagnostics not suppressed are split by Options.Report.
+func (c *Config) Check(fset *token.FileSet, files []*ast.File, imp Importer) (errs, warns []error) {
+	env, err := BuildEnvWithImporter(files, imp)
+	diags := unjoin(err)
+	var checked []*ast.File
+	for _, file := range files {
+		if c.Includes(fset.Position(file.Package).Filename) {
+			checked = append(checked, file)
+		}
+	}
+	// the error limit and werror apply to the diagnostics reported, once the others are
+	// left out, see Options.Report
+	opts := c.Options
+	opts.MaxErrors, opts.WError = 0, false
+	opts.Fset = fset
+	info, err := InferPackageWithOptions(checked, env, opts)
+	diags = append(diags, unjoin(err)...)
+	diags = append(diags, info.Warnings...)
+	if c.enabled("sealed") {
+		for _, file := range checked {
+			diags = append(diags, CheckSealedSwitches(file, env)...)
+		}
+	}
+	if c.enabled("fieldorder") {
+		diags = append(diags, CheckFieldOrder(env, DefaultSizes)...)
+	}
+
+	var reported []error
+	for _, d := range diags {
+		var te *TypeError
+		if errors.As(d, &te) && te.Pos.IsValid() && !c.Includes(fset.Position(te.Pos).Filename) {
+			continue
+		}
+		if !c.Suppressed(d) {
+			reported = append(reported, d)
+		}
+	}
+	return c.Options.Report(reported)
+}
+
+func (c *Config) enabled(analysis string) bool {
+	for _, a := range c.Analyses {
+		if a == analysis {
+			return true
+		}
+	}
+	return false
+}
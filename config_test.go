@@ -0,0 +1,184 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	const src = `# shared settings
+go: go1.21
+max-errors: 50
+werror: true
+goos: linux
+tags: [integration, "e2e"]
+analyses: [fieldorder]
+suppress:
+  - UnsatisfiedConstraint
+  - MismatchedTypes # noisy in legacy code
+exclude: ["*_gen.go", 'internal/legacy/*']
+`
+	got, err := ReadConfig(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+	want := &Config{
+		Options:  Options{MaxErrors: 50, WError: true, GOOS: "linux", BuildTags: []string{"integration", "e2e"}, GoVersion: "go1.21"},
+		Analyses: []string{"fieldorder"},
+		Suppress: []ErrorCode{CodeUnsatisfiedConstraint, CodeMismatchedTypes},
+		Exclude:  []string{"*_gen.go", "internal/legacy/*"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadConfig() = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		src     string
+		wantErr string
+	}{
+		{src: "color: red\n", wantErr: "config line 1: unknown key color"},
+		{src: "go: 1.21\n", wantErr: `config line 1: invalid go version "1.21"`},
+		{src: "max-errors: many\n", wantErr: "config line 1: invalid max-errors"},
+		{src: "go:\n", wantErr: "config line 1: missing value for go"},
+		{src: "go: [go1.21]\n", wantErr: "config line 1: go is not a list"},
+		{src: "analyses: [escape]\n", wantErr: "config line 1: unknown analysis escape"},
+		{src: "suppress:\n  - Typo\n\nwerror: true\n", wantErr: "config line 1: unknown error code Typo"},
+		{src: "exclude: [\"[\"]\n", wantErr: "config line 1: invalid exclude pattern"},
+		{src: "- a\n", wantErr: "config line 1: list item outside of a list"},
+		{src: "werror true\n", wantErr: "config line 1: expected key: value"},
+	}
+	for _, tt := range tests {
+		if _, err := ReadConfig(strings.NewReader(tt.src)); err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+			t.Errorf("ReadConfig(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "internal", "pkg")
+	if err := os.MkdirAll(pkg, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ConfigFile), []byte("werror: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(pkg)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if c.Dir != root || !c.Options.WError {
+		t.Errorf("LoadConfig() = %+v, want the configuration of %s", c, root)
+	}
+
+	if err := os.WriteFile(filepath.Join(pkg, ConfigFile), []byte("werror: maybe\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(pkg); err == nil || !strings.Contains(err.Error(), filepath.Join(pkg, ConfigFile)) {
+		t.Errorf("LoadConfig() error = %v, want an error naming the file", err)
+	}
+}
+
+func TestConfigIncludes(t *testing.T) {
+	c := &Config{
+		Dir:     "/repo",
+		Include: []string{"*.go"},
+		Exclude: []string{"*_gen.go", "internal/legacy/*"},
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "main.go", want: true},
+		{name: "/repo/pkg/types.go", want: true},
+		{name: "pkg/types_gen.go", want: false},
+		{name: "/repo/internal/legacy/old.go", want: false},
+		{name: "internal/legacy/sub/old.go", want: true},
+		{name: "README.md", want: false},
+	}
+	for _, tt := range tests {
+		if got := c.Includes(tt.name); got != tt.want {
+			t.Errorf("Includes(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	srcs := map[string]string{
+		"a.go":     "package p\n\nfunc f() {\n\tvar x int\n\tx = \"a\"\n}\n\nvar u = missing\n",
+		"b.go":     "package p\n\nvar d = double(2)\n\nfunc s(n int) string {\n\treturn string(n)\n}\n",
+		"b_gen.go": "package p\n\nfunc double(n int) int {\n\tvar y bool\n\ty = 1\n\treturn n * 2\n}\n",
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"a.go", "b.go", "b_gen.go"} {
+		file, err := parser.ParseFile(fset, name, srcs[name], 0)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		files = append(files, file)
+	}
+
+	tests := []struct {
+		name      string
+		config    string
+		wantErrs  []string
+		wantWarns []string
+	}{
+		{
+			name:      "defaults",
+			wantErrs:  []string{"unknown identifier: missing", "assignment type mismatch for x", "assignment type mismatch for y"},
+			wantWarns: []string{"conversion from integer to string"},
+		},
+		{
+			name:      "excluded file",
+			config:    "exclude: [\"*_gen.go\"]\n",
+			wantErrs:  []string{"unknown identifier: missing", "assignment type mismatch for x"},
+			wantWarns: []string{"conversion from integer to string"},
+		},
+		{
+			name:     "suppressed code",
+			config:   "suppress: [UndeclaredName]\nwerror: true\n",
+			wantErrs: []string{"assignment type mismatch for x", "assignment type mismatch for y", "conversion from integer to string"},
+		},
+		{
+			name:      "parallel",
+			config:    "parallel: true\n",
+			wantErrs:  []string{"unknown identifier: missing", "assignment type mismatch for x", "assignment type mismatch for y"},
+			wantWarns: []string{"conversion from integer to string"},
+		},
+		{
+			name:     "go version",
+			config:   "go: go1.14\nmax-errors: 2\n",
+			wantErrs: []string{"unknown identifier: missing", "assignment type mismatch for x", "too many errors"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ReadConfig(strings.NewReader(tt.config))
+			if err != nil {
+				t.Fatalf("ReadConfig() error = %v", err)
+			}
+			errs, warns := c.Check(fset, files, nil)
+			check := func(what string, got []error, want []string) {
+				if len(got) != len(want) {
+					t.Fatalf("Check() %s = %v, want %q", what, got, want)
+				}
+				for i, err := range got {
+					if !strings.Contains(err.Error(), want[i]) {
+						t.Errorf("Check() %s %d = %v, want %q", what, i, err, want[i])
+					}
+				}
+			}
+			check("errors", errs, tt.wantErrs)
+			check("warnings", warns, tt.wantWarns)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ConformanceCase is a single expectation of a conformance corpus: the type of a
+// package-level variable, or an error inferring it.
+type ConformanceCase struct {
+	File string
+	Line int
+	Name string // the name of the variable
+
+	Want    string // the type in Go syntax, or a substring of the error if WantErr
+	WantErr bool
+	Got     string // the inferred type in Go syntax, or the error
+	Pass    bool
+}
+
+// Conformance is the result of checking a conformance corpus, see ConformanceReport.
+type Conformance struct {
+	Cases []ConformanceCase
+}
+
+// Passed returns the number of cases that passed.
+func (c *Conformance) Passed() int {
+	n := 0
+	for _, cc := range c.Cases {
+		if cc.Pass {
+			n++
+		}
+	}
+	return n
+}
+
+// String formats the scoreboard: a line per case, then the number of cases passed
+// by each file and in total.
+func (c *Conformance) String() string {
+	var sb strings.Builder
+	var files []string
+	passed := make(map[string]int)
+	total := make(map[string]int)
+	for _, cc := range c.Cases {
+		status := "FAIL"
+		if cc.Pass {
+			status = "ok"
+			passed[cc.File]++
+		}
+		if total[cc.File] == 0 {
+			files = append(files, cc.File)
+		}
+		total[cc.File]++
+		fmt.Fprintf(&sb, "%-4s %s:%d %s: %s\n", status, cc.File, cc.Line, cc.Name, cc.Got)
+	}
+	for _, file := range files {
+		fmt.Fprintf(&sb, "%s: %d/%d\n", file, passed[file], total[file])
+	}
+	fmt.Fprintf(&sb, "total: %d/%d\n", c.Passed(), len(c.Cases))
+	return sb.String()
+}
+
+// ConformanceReport checks the conformance corpus in the root directory of fsys, like the
+// examples of the generics sections of the Go specification. Each .go file is a package of
+// its own, whose annotated package-level variables are the cases, one per spec:
+//
+//	var x = Sum([]int{1, 2}) // want int
+//	var y = Sum([]string{}) // error does not satisfy
+//
+// A case passes if the variable has the wanted type, in Go syntax, or if inferring it
+// fails with an error containing the given text. The other declarations of the file only
+// set up the environment of the cases, with BuildEnv.
+func ConformanceReport(fsys fs.FS) (*Conformance, error) {
+	names, err := fs.Glob(fsys, "*.go")
+	if err != nil {
+		return nil, err
+	}
+	report := &Conformance{}
+	for _, name := range names {
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		report.Cases = append(report.Cases, conformanceCases(fset, file)...)
+	}
+	return report, nil
+}
+
+// conformanceCases checks the annotated variables of file.
+func conformanceCases(fset *token.FileSet, file *ast.File) []ConformanceCase {
+	// the cases are inferred one at a time below, so that an error is attributed to its case
+	env, _ := BuildEnv([]*ast.File{file})
+	var cases []ConformanceCase
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if vs.Comment == nil {
+				continue
+			}
+			cc := ConformanceCase{
+				File: path.Base(fset.Position(vs.Pos()).Filename),
+				Line: fset.Position(vs.Pos()).Line,
+				Name: vs.Names[0].Name,
+			}
+			text := strings.TrimSpace(vs.Comment.Text())
+			switch {
+			case strings.HasPrefix(text, "want "):
+				cc.Want = strings.TrimPrefix(text, "want ")
+			case strings.HasPrefix(text, "error "):
+				cc.Want, cc.WantErr = strings.TrimPrefix(text, "error "), true
+			default:
+				continue
+			}
+
+			scope := make(TypeEnv, len(env))
+			for name, t := range env {
+				scope[name] = t
+			}
+			one := &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{&ast.ValueSpec{Names: vs.Names[:1], Type: vs.Type, Values: vs.Values[:min(1, len(vs.Values))]}}}
+			if err := inferGenDecl(one, scope, NewInferenceContext(WithFileSet(fset))); err != nil {
+				cc.Got = "error: " + err.Error()
+				cc.Pass = cc.WantErr && strings.Contains(err.Error(), cc.Want)
+			} else {
+				cc.Got = FormatGo(scope[cc.Name])
+				cc.Pass = !cc.WantErr && cc.Got == cc.Want
+			}
+			cases = append(cases, cc)
+		}
+	}
+	return cases
+}
@@ -0,0 +1,79 @@
+package generic
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// TestConformance checks the conformance corpus against its scoreboard, so that changes
+// to the score are reviewed. Run `go test -run Conformance -update` to update it.
+func TestConformance(t *testing.T) {
+	report, err := ConformanceReport(os.DirFS(filepath.Join("testdata", "conformance")))
+	if err != nil {
+		t.Fatalf("ConformanceReport() error = %v", err)
+	}
+	got := report.String()
+	t.Logf("conformance: %d of %d cases pass", report.Passed(), len(report.Cases))
+
+	golden := filepath.Join("testdata", "conformance.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("ConformanceReport() =\n%s\nwant\n%s\nrun with -update if the change is expected", got, want)
+	}
+}
+
+func TestConformanceReport(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.go": {Data: []byte(`package a
+
+func Id[T any](x T) T { return x }
+
+var (
+	typed   = Id[int](1)    // want int
+	wrong   = Id[int](1)    // want string
+	failing = Id[int]("a")  // error mismatch
+	passing = Id[int]       // error mismatch
+	plain   = 1
+)
+`)},
+		"notes.txt": {Data: []byte("not a corpus file")},
+	}
+	report, err := ConformanceReport(fsys)
+	if err != nil {
+		t.Fatalf("ConformanceReport() error = %v", err)
+	}
+
+	want := []struct {
+		name string
+		pass bool
+	}{{"typed", true}, {"wrong", false}, {"failing", true}, {"passing", false}}
+	if len(report.Cases) != len(want) {
+		t.Fatalf("ConformanceReport() = %d cases, want %d:\n%s", len(report.Cases), len(want), report)
+	}
+	for i, cc := range report.Cases {
+		if cc.Name != want[i].name || cc.Pass != want[i].pass {
+			t.Errorf("case %d = %s (pass %v), want %s (pass %v)", i, cc.Name, cc.Pass, want[i].name, want[i].pass)
+		}
+	}
+	if report.Passed() != 2 {
+		t.Errorf("Passed() = %d, want 2", report.Passed())
+	}
+
+	if _, err := ConformanceReport(fstest.MapFS{"bad.go": {Data: []byte("package")}}); err == nil {
+		t.Error("ConformanceReport() error = nil, want a syntax error")
+	}
+}
@@ -1,5 +1,14 @@
 package generic
 
+import (
+	"fmt"
+	"sort"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+var ErrEmptyTypeSet = gerrors.ErrEmptyTypeSet
+
 // checkConstraint checks if a type t satisfies the given `TypeConstraint`.
 //
 // ## Process
@@ -18,12 +27,24 @@ package generic
 //	(∀i ∈ constraint.Interfaces. implementsInterface(t, i)) ∧
 //	(constraint.Types ≠ ∅ ⇒ ∃type ∈ constraint.Types. TypesEqual(t, type))
 func checkConstraint(t Type, constraint TypeConstraint) bool {
+	// an alias satisfies the constraints of the type it stands for
+	t = unalias(t)
 	if _, ok := t.(*TypeVariable); ok {
 		return true
 	}
-	// handle built-in constraints (e.g., "any", "comparable", etc.)
+	if isExcluded(t, constraint.Excluded) {
+		return false
+	}
+	// handle built-in constraints (e.g., "any", "comparable", etc.).
+	// combined with a type list, like `interface{ comparable; int | string }`,
+	// the type must satisfy both.
 	if constraint.BuiltinConstraint != "" {
-		return checkBuiltinConstraint(t, constraint.BuiltinConstraint)
+		if !checkBuiltinConstraint(t, constraint.BuiltinConstraint) {
+			return false
+		}
+		if len(constraint.Types) == 0 && len(constraint.Interfaces) == 0 {
+			return true
+		}
 	}
 
 	// pointer type is a special case, we need to check the base type
@@ -35,7 +56,16 @@ func checkConstraint(t Type, constraint TypeConstraint) bool {
 				}
 			}
 		}
-		return checkConstraint(ptr.Base, constraint)
+		// the method set of a pointer also contains the pointer receiver methods,
+		// so interfaces must be checked against the pointer itself
+		for _, iface := range constraint.Interfaces {
+			if !implInterface(ptr, iface) {
+				return false
+			}
+		}
+		baseConstraint := constraint
+		baseConstraint.Interfaces = nil
+		return checkConstraint(ptr.Base, baseConstraint)
 	}
 
 	// check if the type implements all the interfaces in the constraint
@@ -48,6 +78,20 @@ func checkConstraint(t Type, constraint TypeConstraint) bool {
 	// check if the type satisfies the type constraints
 	if len(constraint.Types) > 0 {
 		for _, allowedType := range constraint.Types {
+			allowedType = unalias(allowedType)
+			switch allowed := allowedType.(type) {
+			case *ApproxType:
+				if isUnderlyingType(t, unalias(allowed.Base)) {
+					return true
+				}
+				continue
+			case *TypeConstraint:
+				// nested constraint, like an embedded union
+				if checkConstraint(t, *allowed) {
+					return true
+				}
+				continue
+			}
 			if constraint.IsUnderlying {
 				if isUnderlyingType(t, allowedType) {
 					return true
@@ -72,9 +116,10 @@ func implInterface(t Type, iface Interface) bool {
 	case *TypeConstant:
 		return checkPrimitiveTypeInterface(concreteType.Name, iface)
 	case *GenericType:
-		// generic type may be different due to the type parameters
-		// but for simplicity, we assume it's the same
-		return true
+		if concreteType.UnderlyingType != nil {
+			return implInterface(concreteType.named(), iface)
+		}
+		return implInterface(concreteType.Underlying(), iface)
 	case *FunctionType:
 		// function type can't implement an interface
 		return false
@@ -84,6 +129,23 @@ func implInterface(t Type, iface Interface) bool {
 	case *StructType:
 		// check each method of the interface is implemented by the struct
 		return structImplsInterface(concreteType, iface)
+	case *NamedType:
+		return methodSetContainsAll(namedMethodSet(concreteType, false), iface)
+	case *PointerType:
+		// pointer receiver methods are only in the method set of the pointer
+		if gt, ok := concreteType.Base.(*GenericType); ok {
+			if gt.UnderlyingType != nil {
+				return implInterface(&PointerType{Base: gt.named()}, iface)
+			}
+			return implInterface(&PointerType{Base: gt.Underlying()}, iface)
+		}
+		switch base := concreteType.Base.(type) {
+		case *StructType:
+			return methodSetContainsAll(calculateStructMethodSet(base, true), iface)
+		case *NamedType:
+			return methodSetContainsAll(namedMethodSet(base, true), iface)
+		}
+		return implInterface(concreteType.Base, iface)
 	default:
 		return false
 	}
@@ -108,23 +170,33 @@ func checkPrimitiveTypeInterface(tName string, iface Interface) bool {
 }
 
 func interfaceContainsAll(t *InterfaceType, iface Interface) bool {
-	for name := range iface.Methods {
-		if _, ok := t.Methods[name]; !ok {
-			return false
-		}
-	}
-	return true
+	return methodSetContainsAll(interfaceMethods(t), iface)
 }
 
+// structImplsInterface checks the method set of the struct value, which does not
+// include methods declared on the pointer receiver.
 func structImplsInterface(t *StructType, iface Interface) bool {
-	for name := range iface.Methods {
-		if _, ok := t.Methods[name]; !ok {
+	return methodSetContainsAll(calculateStructMethodSet(t, false), iface)
+}
+
+// methodSetContainsAll reports whether ms has the methods of iface, with the same
+// signatures, like checkInterfaceCompatibility.
+func methodSetContainsAll(ms MethodSet, iface Interface) bool {
+	for name, want := range iface.Methods {
+		if m, ok := ms[name]; !ok || !sameSignature(m, want) {
 			return false
 		}
 	}
 	return true
 }
 
+// sameSignature reports whether the method m of a method set has the signature of the
+// interface method want; the receiver is settled by the method set.
+func sameSignature(m, want Method) bool {
+	m.Name, m.IsPointer = want.Name, want.IsPointer
+	return MethodsEqual(m, want)
+}
+
 // TypesEqual is a helper function to compare two Types
 func TypesEqual(t1, t2 Type) bool {
 	if t1 == nil || t2 == nil {
@@ -133,10 +205,13 @@ func TypesEqual(t1, t2 Type) bool {
 	switch t1 := t1.(type) {
 	case *TypeConstant:
 		t2, ok := t2.(*TypeConstant)
-		return ok && t1.Name == t2.Name
+		return ok && (t1 == t2 || t1.Name == t2.Name)
 	case *TypeVariable:
 		t2, ok := t2.(*TypeVariable)
 		return ok && t1.Name == t2.Name
+	case *NamedType:
+		t2, ok := t2.(*NamedType)
+		return ok && t1.Name == t2.Name
 	case *FunctionType:
 		t2Func, ok := t2.(*FunctionType)
 		if !ok {
@@ -145,7 +220,7 @@ func TypesEqual(t1, t2 Type) bool {
 		if len(t1.ParamTypes) != len(t2Func.ParamTypes) {
 			return false
 		}
-		if t1.IsVariadic != t2Func.IsVariadic {
+		if t1.IsVariadic != t2Func.IsVariadic || t1.Effects != t2Func.Effects {
 			return false
 		}
 		for i := range t1.ParamTypes {
@@ -182,6 +257,9 @@ func TypesEqual(t1, t2 Type) bool {
 	case *ArrayType:
 		t2, ok := t2.(*ArrayType)
 		return ok && t1.Len == t2.Len && TypesEqual(t1.ElementType, t2.ElementType)
+	case *ChanType:
+		t2, ok := t2.(*ChanType)
+		return ok && t1.Dir == t2.Dir && TypesEqual(t1.ElementType, t2.ElementType)
 	case *InterfaceType:
 		t2, ok := t2.(*InterfaceType)
 		if !ok {
@@ -190,33 +268,34 @@ func TypesEqual(t1, t2 Type) bool {
 		if t1.IsEmpty && t2.IsEmpty {
 			return true
 		}
+		if !typeListsEqual(t1.Variants, t2.Variants) {
+			return false
+		}
 		for name := range t1.Methods {
 			if _, ok := t2.Methods[name]; !ok {
 				return false
 			}
 		}
 		return true
-	case *StructType:
-		t2, ok := t2.(*StructType)
-		if !ok || t1.Name != t2.Name {
-			return false
-		}
-		if len(t1.Fields) != len(t2.Fields) || len(t1.Methods) != len(t2.Methods) {
+	case *ExtensibleStruct:
+		t2, ok := t2.(*ExtensibleStruct)
+		if !ok || !TypesEqual(t1.Rest, t2.Rest) || len(t1.Fields) != len(t2.Fields) {
 			return false
 		}
 		for name, fld1 := range t1.Fields {
-			fld2, ok := t2.Fields[name]
-			if !ok || !TypesEqual(fld1, fld2) {
-				return false
-			}
-		}
-		for name, m1 := range t1.Methods {
-			m2, ok := t2.Methods[name]
-			if !ok || !MethodsEqual(m1, m2) {
+			if !TypesEqual(fld1, t2.Fields[name]) {
 				return false
 			}
 		}
 		return true
+	case *StructType:
+		t2, ok := t2.(*StructType)
+		return ok && identicalStructs(t1, t2, func(fld1, fld2 Type) error {
+			if !TypesEqual(fld1, fld2) {
+				return ErrTypeMismatch
+			}
+			return nil
+		}) == nil
 	case *MapType:
 		t2, ok := t2.(*MapType)
 		return ok && TypesEqual(t1.KeyType, t2.KeyType) && TypesEqual(t1.ValueType, t2.ValueType)
@@ -226,14 +305,91 @@ func TypesEqual(t1, t2 Type) bool {
 	case *TypeAlias:
 		t2, ok := t2.(*TypeAlias)
 		return ok && t1.Name == t2.Name && TypesEqual(t1.AliasedTo, t2.AliasedTo)
+	case *NoValueType:
+		_, ok := t2.(*NoValueType)
+		return ok
+	case *NilType:
+		return isNil(t2)
+	case *PackageType:
+		t2, ok := t2.(*PackageType)
+		return ok && t1.Path == t2.Path
+	case *VarObj:
+		t2, ok := t2.(*VarObj)
+		return ok && t1.Name == t2.Name && TypesEqual(t1.Type, t2.Type)
+	case *ConstObj:
+		t2, ok := t2.(*ConstObj)
+		return ok && t1.Name == t2.Name && TypesEqual(t1.Type, t2.Type)
+	case *TypeObj:
+		t2, ok := t2.(*TypeObj)
+		return ok && t1.Name == t2.Name && TypesEqual(t1.Type, t2.Type)
+	case *FuncObj:
+		t2, ok := t2.(*FuncObj)
+		return ok && t1.Name == t2.Name && TypesEqual(t1.Type, t2.Type)
+	case *ApproxType:
+		t2, ok := t2.(*ApproxType)
+		return ok && TypesEqual(t1.Base, t2.Base)
+	case *Interface:
+		t2, ok := t2.(*Interface)
+		return ok && t1.Name == t2.Name && methodSetsEqual(t1.Methods, t2.Methods)
+	case Method:
+		t2, ok := t2.(Method)
+		return ok && MethodsEqual(t1, t2)
+	case *GenericMethod:
+		t2, ok := t2.(*GenericMethod)
+		return ok && t1.Name == t2.Name && typeListsEqual(t1.TypeParams, t2.TypeParams) && MethodsEqual(t1.Method, t2.Method)
+	case *TypeConstraint:
+		t2, ok := t2.(*TypeConstraint)
+		if !ok || t1.Union != t2.Union || t1.IsComparable != t2.IsComparable ||
+			t1.IsUnderlying != t2.IsUnderlying || t1.BuiltinConstraint != t2.BuiltinConstraint {
+			return false
+		}
+		if len(t1.Interfaces) != len(t2.Interfaces) {
+			return false
+		}
+		for i := range t1.Interfaces {
+			if t1.Interfaces[i].Name != t2.Interfaces[i].Name {
+				return false
+			}
+		}
+		return typeListsEqual(t1.Types, t2.Types) && typeListsEqual(t1.Excluded, t2.Excluded)
 	default:
 		return false
 	}
 }
 
+// identicalStructs reports whether the structs s1 and s2 are identical, matching the types
+// of their fields with match, through which Unify binds type variables. Named structs
+// are identical by name, like defined types, whatever their fields and methods. Anonymous
+// structs are identical if they have the same field names, in the same order when both
+// know it, see FieldOrder, and fields of matching types.
+func identicalStructs(s1, s2 *StructType, match func(fld1, fld2 Type) error) error {
+	if s1.Name != s2.Name {
+		return ErrTypeMismatch
+	}
+	if s1.Name != "" {
+		return nil
+	}
+	if len(s1.Fields) != len(s2.Fields) {
+		return ErrTypeMismatch
+	}
+	names1, names2 := s1.FieldNames(), s2.FieldNames()
+	if len(s1.FieldOrder) == 0 || len(s2.FieldOrder) == 0 {
+		names1, names2 = sortedKeys(s1.Fields), sortedKeys(s2.Fields)
+	}
+	for i, name := range names1 {
+		if names2[i] != name {
+			return ErrTypeMismatch
+		}
+		if err := match(s1.Fields[name], s2.Fields[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // MethodsEqual compares two Method types for equality.
 func MethodsEqual(m1, m2 Method) bool {
-	if m1.Name != m2.Name || m1.IsPointer != m2.IsPointer {
+	if m1.Name != m2.Name || m1.IsPointer != m2.IsPointer || m1.IsVariadic != m2.IsVariadic {
 		return false
 	}
 	if len(m1.Params) != len(m2.Params) || len(m1.Results) != len(m2.Results) {
@@ -252,6 +408,33 @@ func MethodsEqual(m1, m2 Method) bool {
 	return true
 }
 
+// methodSetsEqual reports whether two method sets contain equal methods under the same names.
+func methodSetsEqual(ms1, ms2 MethodSet) bool {
+	if len(ms1) != len(ms2) {
+		return false
+	}
+	for name, m1 := range ms1 {
+		m2, ok := ms2[name]
+		if !ok || !MethodsEqual(m1, m2) {
+			return false
+		}
+	}
+	return true
+}
+
+// typeListsEqual reports whether two type lists are pairwise equal.
+func typeListsEqual(ts1, ts2 []Type) bool {
+	if len(ts1) != len(ts2) {
+		return false
+	}
+	for i := range ts1 {
+		if !TypesEqual(ts1[i], ts2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // checkBuiltinConstraint checks if a the given type satisfies the specified built-in constraint.
 func checkBuiltinConstraint(t Type, constraint string) bool {
 	switch constraint {
@@ -276,27 +459,69 @@ func checkBuiltinConstraint(t Type, constraint string) bool {
 	}
 }
 
-// isComparable determines if the given type is comparable.
+// isComparable determines if the given type is comparable, i.e. its values can be compared
+// with == and used as map keys. Interfaces are comparable, though comparing two interface
+// values panics if their dynamic type is not; see StrictlyComparable.
 func isComparable(t Type) bool {
+	return comparableType(t, false)
+}
+
+// StrictlyComparable reports whether t is strictly comparable: comparing its values
+// never panics. Unlike comparable types, strictly comparable types contain no interfaces.
+func StrictlyComparable(t Type) bool {
+	return comparableType(t, true)
+}
+
+func comparableType(t Type, strict bool) bool {
 	switch t := t.(type) {
 	case *TypeConstant:
+		if t.Name == "error" {
+			return !strict
+		}
 		// premitive types are comparable
 		return t.Name == TypeBool || isNumeric(t) || t.Name == TypeString
-	case *PointerType:
-		return true // all pointer types are comparable
-	case *InterfaceType:
-		return true // all interface types are comparable
+	case *TypeAlias:
+		return comparableType(t.AliasedTo, strict)
+	case *NamedType:
+		return comparableType(t.Underlying, strict)
+	case *PointerType, *ChanType:
+		return true // all pointer and channel types are comparable
+	case *InterfaceType, *Interface:
+		// the comparison panics if the dynamic types are not comparable
+		return !strict
 	case *StructType:
 		// every field of the struct should be comparable
 		for _, field := range t.Fields {
-			if !isComparable(field) {
+			if !comparableType(field, strict) {
 				return false
 			}
 		}
 		return true
+	case *GenericType:
+		if t.Signature != nil {
+			return false
+		}
+		return comparableType(t.Underlying(), strict)
 	case *ArrayType:
-		// array is comparable if element type is comparable and length is the same
-		return isComparable(t.ElementType)
+		// arrays of any length, even zero, are comparable if their elements are
+		return comparableType(t.ElementType, strict)
+	case *TypeConstraint:
+		// a type parameter is comparable if every type in its type set is
+		if t.BuiltinConstraint == ConstraintComparable {
+			return true
+		}
+		if len(t.Types) == 0 {
+			return builtinTypeSet(t.BuiltinConstraint) != nil
+		}
+		for _, term := range t.Types {
+			if approx, ok := term.(*ApproxType); ok {
+				term = approx.Base
+			}
+			if !comparableType(term, strict) {
+				return false
+			}
+		}
+		return true
 	default:
 		return false
 	}
@@ -304,10 +529,10 @@ func isComparable(t Type) bool {
 
 var (
 	signedIntegers = map[string]bool{
-		TypeInt: true, TypeInt8: true, TypeInt16: true, TypeInt32: true, TypeInt64: true,
+		TypeInt: true, TypeInt8: true, TypeInt16: true, TypeInt32: true, TypeInt64: true, TypeRune: true,
 	}
 	unsignedIntegers = map[string]bool{
-		TypeUint: true, TypeUint8: true, TypeUint16: true, TypeUint32: true, TypeUint64: true, TypeUintptr: true,
+		TypeUint: true, TypeUint8: true, TypeUint16: true, TypeUint32: true, TypeUint64: true, TypeUintptr: true, TypeByte: true,
 	}
 	floats = map[string]bool{
 		TypeFloat32: true, TypeFloat64: true,
@@ -323,62 +548,115 @@ var (
 // isOrdered checks if the given type is ordered (can be used with comparison operators)
 // (e.g., <, <=, >, >=)
 func isOrdered(t Type) bool {
-	if tc, ok := t.(*TypeConstant); ok {
-		return signedIntegers[tc.Name] ||
-			unsignedIntegers[tc.Name] ||
-			floats[tc.Name] ||
-			orderedTypes[tc.Name]
-	}
-	return false
+	return underlyingIs(t, func(name string) bool {
+		return signedIntegers[name] || unsignedIntegers[name] || floats[name] || orderedTypes[name]
+	})
 }
 
 // isComplex checks if the given type is a complex number type.
 func isComplex(t Type) bool {
-	if tc, ok := t.(*TypeConstant); ok {
-		return complexes[tc.Name]
-	}
-	return false
+	return underlyingIs(t, func(name string) bool { return complexes[name] })
 }
 
 // isFloat checks if the given type is a floating-point number type.
 func isFloat(t Type) bool {
-	if tc, ok := t.(*TypeConstant); ok {
-		return floats[tc.Name]
-	}
-	return false
+	return underlyingIs(t, func(name string) bool { return floats[name] })
 }
 
 // isInteger checks if the given type is an integer type.
 func isInteger(t Type) bool {
-	if tc, ok := t.(*TypeConstant); ok {
-		return signedIntegers[tc.Name] || unsignedIntegers[tc.Name]
-	}
-	return false
+	return underlyingIs(t, func(name string) bool { return signedIntegers[name] || unsignedIntegers[name] })
 }
 
 // isSigned checks if the given type is a signed integer type.
 func isSigned(t Type) bool {
-	if tc, ok := t.(*TypeConstant); ok {
-		return signedIntegers[tc.Name]
-	}
-	return false
+	return underlyingIs(t, func(name string) bool { return signedIntegers[name] })
 }
 
 // isUnsigned checks if the given type is an unsigned integer type.
 func isUnsigned(t Type) bool {
-	if tc, ok := t.(*TypeConstant); ok {
-		return unsignedIntegers[tc.Name]
+	return underlyingIs(t, func(name string) bool { return unsignedIntegers[name] })
+}
+
+// underlyingIs reports whether the underlying type of t is a predeclared type accepted by is,
+// so that defined types like `type Age int` count as integers.
+//
+// A type parameter, represented by its constraint, qualifies if every type in its
+// type set does, like `~int | ~int64` or `constraints.Signed` for integers.
+func underlyingIs(t Type, is func(name string) bool) bool {
+	switch u := underlying(t).(type) {
+	case *TypeConstant:
+		return is(u.Name)
+	case *TypeConstraint:
+		terms := u.Types
+		if len(terms) == 0 {
+			for _, name := range sortedKeys(builtinTypeSet(u.BuiltinConstraint)) {
+				terms = append(terms, typeConstant(name))
+			}
+		}
+		if len(terms) == 0 {
+			return false
+		}
+		for _, term := range terms {
+			if approx, ok := term.(*ApproxType); ok {
+				term = approx.Base
+			}
+			if !underlyingIs(term, is) {
+				return false
+			}
+		}
+		return true
 	}
 	return false
 }
 
+// builtinTypeSet returns the predeclared types making up the type set of a builtin constraint,
+// or nil for constraints like any and comparable, whose type sets are not made of basic types.
+func builtinTypeSet(constraint string) map[string]bool {
+	set := make(map[string]bool)
+	add := func(names map[string]bool) {
+		for name := range names {
+			set[name] = true
+		}
+	}
+	switch constraint {
+	case ConstraintOrdered:
+		add(signedIntegers)
+		add(unsignedIntegers)
+		add(floats)
+		add(orderedTypes)
+	case ConstraintInteger:
+		add(signedIntegers)
+		add(unsignedIntegers)
+	case ConstraintSigned:
+		add(signedIntegers)
+	case ConstraintUnsigned:
+		add(unsignedIntegers)
+	case ConstraintFloat:
+		add(floats)
+	case ConstraintComplex:
+		add(complexes)
+	default:
+		return nil
+	}
+	return set
+}
+
 // isNumeric checks if the given type is a numeric type.
 func isNumeric(t Type) bool {
 	return isInteger(t) || isFloat(t) || isComplex(t)
 }
 
-// isUnderlyingType checks if the given type has the specified underlying type.
+// isUnderlyingType checks if the given type has the specified underlying type,
+// like a defined type `type Age int` has the underlying type int.
 func isUnderlyingType(t Type, underlyingType Type) bool {
+	return sameStructure(underlying(t), underlyingType)
+}
+
+// sameStructure checks if t is structurally the type underlyingType. The types it
+// contains must be the same, so a defined type inside t only matches itself:
+// `[]Age` has the underlying type `[]Age`, not `[]int`.
+func sameStructure(t Type, underlyingType Type) bool {
 	for {
 		if alias, ok := t.(*TypeAlias); ok {
 			t = alias.AliasedTo
@@ -388,19 +666,26 @@ func isUnderlyingType(t Type, underlyingType Type) bool {
 	}
 
 	switch concrete := t.(type) {
+	case *NamedType:
+		return TypesEqual(concrete, underlyingType)
 	case *TypeConstant:
 		underlyingConst, ok := underlyingType.(*TypeConstant)
 		return ok && concrete.Name == underlyingConst.Name
 
 	case *SliceType:
 		underlyingSlice, ok := underlyingType.(*SliceType)
-		return ok && isUnderlyingType(concrete.ElementType, underlyingSlice.ElementType)
+		return ok && sameStructure(concrete.ElementType, underlyingSlice.ElementType)
 
 	case *MapType:
 		underlyingMap, ok := underlyingType.(*MapType)
 		return ok &&
-			isUnderlyingType(concrete.KeyType, underlyingMap.KeyType) &&
-			isUnderlyingType(concrete.ValueType, underlyingMap.ValueType)
+			sameStructure(concrete.KeyType, underlyingMap.KeyType) &&
+			sameStructure(concrete.ValueType, underlyingMap.ValueType)
+
+	case *ChanType:
+		underlyingChan, ok := underlyingType.(*ChanType)
+		return ok && concrete.Dir == underlyingChan.Dir &&
+			sameStructure(concrete.ElementType, underlyingChan.ElementType)
 
 	case *StructType:
 		underlyingStruct, ok := underlyingType.(*StructType)
@@ -409,7 +694,7 @@ func isUnderlyingType(t Type, underlyingType Type) bool {
 		}
 		for name, field := range concrete.Fields {
 			underlyingField, ok := underlyingStruct.Fields[name]
-			if !ok || !isUnderlyingType(field, underlyingField) {
+			if !ok || !sameStructure(field, underlyingField) {
 				return false
 			}
 		}
@@ -421,13 +706,268 @@ func isUnderlyingType(t Type, underlyingType Type) bool {
 			return false
 		}
 		for i, param := range concrete.ParamTypes {
-			if !isUnderlyingType(param, underlyingFunc.ParamTypes[i]) {
+			if !sameStructure(param, underlyingFunc.ParamTypes[i]) {
 				return false
 			}
 		}
-		return isUnderlyingType(concrete.ReturnType, underlyingFunc.ReturnType)
+		return sameStructure(concrete.ReturnType, underlyingFunc.ReturnType)
 
 	default:
 		return false
 	}
 }
+
+// NormalizeConstraint returns the canonical form of a constraint, so that equivalent
+// constraints compare and print the same.
+//
+//  1. nested type-set constraints (like embedded unions) are flattened into the type list
+//  2. `IsUnderlying` is pushed down into the individual terms as `~T`
+//  3. an exact term T is absorbed by `~U` if the underlying type of T is U
+//  4. duplicate terms and interfaces are removed
+//  5. terms and interfaces are sorted by their string representation
+func NormalizeConstraint(tc TypeConstraint) TypeConstraint {
+	result := tc
+	result.IsUnderlying = false
+
+	var terms []Type
+	var collect func(types []Type, approx bool)
+	collect = func(types []Type, approx bool) {
+		for _, t := range types {
+			if nested, ok := t.(*TypeConstraint); ok && isTypeSetConstraint(nested) {
+				if nested.Union {
+					result.Union = true
+				}
+				collect(nested.Types, approx || nested.IsUnderlying)
+				continue
+			}
+			if _, ok := t.(*ApproxType); approx && !ok {
+				t = &ApproxType{Base: t}
+			}
+			terms = append(terms, t)
+		}
+	}
+	collect(tc.Types, tc.IsUnderlying)
+
+	var types []Type
+	for _, term := range terms {
+		if containsType(types, term) || isAbsorbed(term, terms) {
+			continue
+		}
+		types = append(types, term)
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		return typeString(types[i]) < typeString(types[j])
+	})
+	result.Types = types
+
+	var interfaces []Interface
+	seen := make(map[string]bool)
+	for _, iface := range tc.Interfaces {
+		if seen[iface.Name] {
+			continue
+		}
+		seen[iface.Name] = true
+		interfaces = append(interfaces, iface)
+	}
+	sort.SliceStable(interfaces, func(i, j int) bool {
+		return interfaces[i].Name < interfaces[j].Name
+	})
+	result.Interfaces = interfaces
+
+	var excluded []Type
+	for _, ex := range tc.Excluded {
+		if !containsType(excluded, ex) {
+			excluded = append(excluded, ex)
+		}
+	}
+	sort.SliceStable(excluded, func(i, j int) bool {
+		return typeString(excluded[i]) < typeString(excluded[j])
+	})
+	result.Excluded = excluded
+
+	return result
+}
+
+// predeclaredTypes are the candidate types used to decide whether a constraint
+// without an explicit type list, like `integer`, has an empty type set.
+var predeclaredTypes = []Type{
+	Bool, String,
+	Int, Int8, Int16, Int32, Int64,
+	Uint, Uint8, Uint16, Uint32, Uint64, Uintptr,
+	Float32, Float64,
+	Complex64, Complex128,
+}
+
+// checkSatisfiable reports an error wrapping ErrEmptyTypeSet if no type can satisfy tc,
+// like `interface{ integer; string }` or `int | string except [int, string]`.
+//
+// Only the type list, the builtin constraint and the excluded types are considered.
+// Method requirements can always be met by some defined type, so they are ignored.
+func checkSatisfiable(tc TypeConstraint) error {
+	check := tc
+	check.Interfaces = nil
+
+	terms := NormalizeConstraint(check).Types
+	if len(terms) == 0 {
+		if check.BuiltinConstraint == "" || check.BuiltinConstraint == ConstraintAny || check.BuiltinConstraint == ConstraintComparable {
+			return nil
+		}
+		// a builtin constraint alone, like `integer`, is satisfied by some predeclared type
+		terms = predeclaredTypes
+	}
+
+	for _, term := range terms {
+		for _, candidate := range termCandidates(term) {
+			if checkConstraint(candidate, check) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: no type satisfies %s", ErrEmptyTypeSet, FormatGo(&tc))
+}
+
+// termCandidates returns the types that may satisfy a single term of a type list.
+func termCandidates(term Type) []Type {
+	switch term := term.(type) {
+	case *ApproxType:
+		return []Type{term.Base}
+	case *TypeConstraint:
+		// a nested constraint, like an embedded `integer`, stands for the predeclared types it allows
+		var candidates []Type
+		for _, t := range predeclaredTypes {
+			if checkConstraint(t, *term) {
+				candidates = append(candidates, t)
+			}
+		}
+		return candidates
+	}
+	return []Type{term}
+}
+
+// isExcluded reports whether t matches one of the excluded types of a constraint.
+// An excluded `~T` term excludes every type whose underlying type is T.
+func isExcluded(t Type, excluded []Type) bool {
+	for _, ex := range excluded {
+		if approx, ok := ex.(*ApproxType); ok {
+			if isUnderlyingType(t, approx.Base) {
+				return true
+			}
+			continue
+		}
+		if TypesEqual(t, ex) {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectTerms returns the terms of the intersection of the unions a and b, like `int`
+// for the lines of `interface{ ~int | ~string; int | float64 }`. The embedded constraints
+// that are type lists are flattened into their terms first. Those with methods are kept as
+// they are if both sides have one, since their intersection has no terms.
+func intersectTerms(a, b []Type) []Type {
+	a, b = flattenTerms(a), flattenTerms(b)
+	var terms []Type
+	for _, x := range a {
+		for _, y := range b {
+			if t := intersectTerm(x, y); t != nil && !containsType(terms, t) {
+				terms = append(terms, t)
+			}
+		}
+	}
+	return terms
+}
+
+// intersectTerm returns the intersection of the terms x and y, or nil if it is empty.
+func intersectTerm(x, y Type) Type {
+	switch x := x.(type) {
+	case *ApproxType:
+		switch y := y.(type) {
+		case *ApproxType:
+			if TypesEqual(x.Base, y.Base) {
+				return x
+			}
+		case *TypeConstraint:
+			if checkConstraint(x.Base, *y) {
+				return x
+			}
+		default:
+			if isUnderlyingType(y, x.Base) {
+				return y
+			}
+		}
+		return nil
+	case *TypeConstraint:
+		switch y := y.(type) {
+		case *TypeConstraint:
+			return x
+		case *ApproxType:
+			return intersectTerm(y, x)
+		default:
+			if checkConstraint(y, *x) {
+				return y
+			}
+		}
+		return nil
+	}
+	switch y.(type) {
+	case *ApproxType, *TypeConstraint:
+		return intersectTerm(y, x)
+	}
+	if TypesEqual(x, y) {
+		return x
+	}
+	return nil
+}
+
+// flattenTerms replaces the embedded constraints of terms that are type lists by their
+// terms, and the builtin constraints, like `integer`, by the predeclared types they allow.
+func flattenTerms(terms []Type) []Type {
+	var flat []Type
+	for _, t := range terms {
+		tc, ok := t.(*TypeConstraint)
+		switch {
+		case !ok:
+			flat = append(flat, t)
+		case isTypeSetConstraint(tc):
+			flat = append(flat, flattenTerms(tc.Types)...)
+		case len(tc.Interfaces) == 0 && len(tc.Types) == 0 && tc.BuiltinConstraint != "" && tc.BuiltinConstraint != ConstraintComparable:
+			for _, p := range predeclaredTypes {
+				if checkConstraint(p, *tc) {
+					flat = append(flat, &ApproxType{Base: p})
+				}
+			}
+		default:
+			flat = append(flat, t)
+		}
+	}
+	return flat
+}
+
+// isTypeSetConstraint reports whether the constraint only consists of a type list,
+// so that it can be merged into an enclosing union.
+func isTypeSetConstraint(tc *TypeConstraint) bool {
+	return len(tc.Interfaces) == 0 && tc.BuiltinConstraint == "" && len(tc.Types) > 0
+}
+
+// isAbsorbed reports whether the exact term is already covered by an `~T` term.
+func isAbsorbed(term Type, terms []Type) bool {
+	if _, ok := term.(*ApproxType); ok {
+		return false
+	}
+	for _, other := range terms {
+		if approx, ok := other.(*ApproxType); ok && isUnderlyingType(term, approx.Base) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(types []Type, t Type) bool {
+	for _, other := range types {
+		if TypesEqual(other, t) {
+			return true
+		}
+	}
+	return false
+}
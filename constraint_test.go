@@ -1,6 +1,8 @@
 package generic
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -73,6 +75,24 @@ func TestCheckConstraint(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "Alias satisfies exact type constraint",
+			t:    &TypeAlias{Name: "MyInt", AliasedTo: &TypeConstant{Name: "int"}},
+			constraint: TypeConstraint{
+				Types: []Type{&TypeConstant{Name: "int"}, &TypeConstant{Name: "string"}},
+				Union: true,
+			},
+			want: true,
+		},
+		{
+			name: "Type satisfies constraint term naming an alias",
+			t:    &TypeConstant{Name: "int"},
+			constraint: TypeConstraint{
+				Types: []Type{&TypeAlias{Name: "MyInt", AliasedTo: &TypeConstant{Name: "int"}}, &TypeConstant{Name: "string"}},
+				Union: true,
+			},
+			want: true,
+		},
 		{
 			name: "Pointer type satisfies constraint",
 			t:    &PointerType{Base: &TypeConstant{Name: "int"}},
@@ -296,6 +316,45 @@ func TestIsComparable(t *testing.T) {
 	}
 }
 
+func TestStrictlyComparable(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringer := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": {Name: "String"}}}
+	list := NewGenericType("List", TypeParamList{{Name: "T"}}, map[string]Type{"Items": &SliceType{ElementType: &TypeVariable{Name: "T"}}}, nil)
+	box := NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"Value": &TypeVariable{Name: "T"}}, nil)
+
+	tests := []struct {
+		name       string
+		t          Type
+		comparable bool
+		strict     bool
+	}{
+		{name: "int", t: intType, comparable: true, strict: true},
+		{name: "pointer to interface", t: &PointerType{Base: stringer}, comparable: true, strict: true},
+		{name: "interface", t: stringer, comparable: true, strict: false},
+		{name: "error", t: &TypeConstant{Name: "error"}, comparable: true, strict: false},
+		{name: "struct with interface field", t: &StructType{Fields: map[string]Type{"S": stringer}}, comparable: true, strict: false},
+		{name: "array of ints", t: &ArrayType{ElementType: intType, Len: 3}, comparable: true, strict: true},
+		{name: "empty array of ints", t: &ArrayType{ElementType: intType, Len: 0}, comparable: true, strict: true},
+		{name: "array of interfaces", t: &ArrayType{ElementType: &InterfaceType{IsEmpty: true}, Len: 2}, comparable: true, strict: false},
+		{name: "array of slices", t: &ArrayType{ElementType: &SliceType{ElementType: intType}, Len: 2}, comparable: false, strict: false},
+		{name: "alias", t: &TypeAlias{Name: "S", AliasedTo: stringer}, comparable: true, strict: false},
+		{name: "generic instance", t: mustInstantiate(t, box, intType), comparable: true, strict: true},
+		{name: "generic instance with slice field", t: mustInstantiate(t, list, intType), comparable: false, strict: false},
+		{name: "map", t: &MapType{KeyType: intType, ValueType: intType}, comparable: false, strict: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isComparable(tt.t); got != tt.comparable {
+				t.Errorf("isComparable() = %v, want %v", got, tt.comparable)
+			}
+			if got := StrictlyComparable(tt.t); got != tt.strict {
+				t.Errorf("StrictlyComparable() = %v, want %v", got, tt.strict)
+			}
+		})
+	}
+}
+
 func TestIsOrdered(t *testing.T) {
 	tests := []struct {
 		name string
@@ -532,3 +591,393 @@ func TestIsUnderlyingType(t *testing.T) {
 		})
 	}
 }
+
+func TestImplInterfacePointerReceiver(t *testing.T) {
+	st := &StructType{Name: "Buffer"}
+	st.Methods = MethodSet{
+		"Len":   {Name: "Len", Receiver: st, Results: []Type{&TypeConstant{Name: "int"}}},
+		"Write": {Name: "Write", Receiver: st, Params: []Type{&TypeConstant{Name: "string"}}, IsPointer: true},
+	}
+	writer := Interface{
+		Name:    "Writer",
+		Methods: MethodSet{"Write": {Name: "Write", Params: []Type{&TypeConstant{Name: "string"}}}},
+	}
+	lener := Interface{
+		Name:    "Lener",
+		Methods: MethodSet{"Len": {Name: "Len", Results: []Type{&TypeConstant{Name: "int"}}}},
+	}
+
+	tests := []struct {
+		name  string
+		t     Type
+		iface Interface
+		want  bool
+	}{
+		{"value does not have pointer method", st, writer, false},
+		{"pointer has pointer method", &PointerType{Base: st}, writer, true},
+		{"value has value method", st, lener, true},
+		{"pointer has value method", &PointerType{Base: st}, lener, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := implInterface(tt.t, tt.iface); got != tt.want {
+				t.Errorf("implInterface(%v, %v) = %v, want %v", tt.t, tt.iface.Name, got, tt.want)
+			}
+			if got := checkConstraint(tt.t, TypeConstraint{Interfaces: []Interface{tt.iface}}); got != tt.want {
+				t.Errorf("checkConstraint(%v, %v) = %v, want %v", tt.t, tt.iface.Name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeConstraint(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	floatType := &TypeConstant{Name: "float64"}
+	myInt := &TypeAlias{Name: "MyInt", AliasedTo: intType}
+
+	tests := []struct {
+		name       string
+		constraint TypeConstraint
+		expected   string
+	}{
+		{
+			name:       "dedupe terms",
+			constraint: TypeConstraint{Types: []Type{intType, strType, intType}, Union: true},
+			expected:   "Union([], [TypeConst(int) | TypeConst(string)])",
+		},
+		{
+			name:       "canonical ordering",
+			constraint: TypeConstraint{Types: []Type{strType, floatType, intType}, Union: true},
+			expected:   "Union([], [TypeConst(float64) | TypeConst(int) | TypeConst(string)])",
+		},
+		{
+			name:       "approximation absorbs exact term",
+			constraint: TypeConstraint{Types: []Type{intType, &ApproxType{Base: intType}, myInt}, Union: true},
+			expected:   "Union([], [~TypeConst(int)])",
+		},
+		{
+			name:       "underlying flag pushed into terms",
+			constraint: TypeConstraint{Types: []Type{strType, intType}, Union: true, IsUnderlying: true},
+			expected:   "Union([], [~TypeConst(int) | ~TypeConst(string)])",
+		},
+		{
+			name: "nested unions flattened",
+			constraint: TypeConstraint{
+				Types: []Type{
+					strType,
+					&TypeConstraint{Types: []Type{intType, floatType}, Union: true, IsUnderlying: true},
+					&TypeConstraint{Types: []Type{strType, intType}, Union: true},
+				},
+			},
+			expected: "Union([], [TypeConst(string) | ~TypeConst(float64) | ~TypeConst(int)])",
+		},
+		{
+			name: "interfaces deduped and sorted",
+			constraint: TypeConstraint{
+				Interfaces: []Interface{{Name: "Stringer"}, {Name: "Comparable"}, {Name: "Stringer"}},
+			},
+			expected: "Constraint([Comparable, Stringer], [])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized := NormalizeConstraint(tt.constraint)
+			if got := normalized.String(); got != tt.expected {
+				t.Errorf("NormalizeConstraint() = %q, want %q", got, tt.expected)
+			}
+			again := NormalizeConstraint(normalized)
+			if again.String() != normalized.String() {
+				t.Errorf("NormalizeConstraint() is not idempotent: %q != %q", again.String(), normalized.String())
+			}
+		})
+	}
+}
+
+func TestCheckConstraintNormalized(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	constraint := TypeConstraint{
+		Types: []Type{
+			&TypeConstraint{Types: []Type{intType}, Union: true, IsUnderlying: true},
+			strType,
+		},
+		Union: true,
+	}
+	myInt := &TypeAlias{Name: "MyInt", AliasedTo: intType}
+	floatType := &TypeConstant{Name: "float64"}
+
+	for _, c := range []TypeConstraint{constraint, NormalizeConstraint(constraint)} {
+		if !checkConstraint(myInt, c) {
+			t.Errorf("checkConstraint(%v, %v) = false, want true", myInt, &c)
+		}
+		if !checkConstraint(strType, c) {
+			t.Errorf("checkConstraint(%v, %v) = false, want true", strType, &c)
+		}
+		if checkConstraint(floatType, c) {
+			t.Errorf("checkConstraint(%v, %v) = true, want false", floatType, &c)
+		}
+	}
+}
+
+func TestCheckConstraintExcluded(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	myString := &NamedType{Name: "MyString", Underlying: strType}
+
+	comparableExceptString := TypeConstraint{
+		BuiltinConstraint: ConstraintComparable,
+		Excluded:          []Type{strType},
+	}
+	comparableExceptStrings := TypeConstraint{
+		BuiltinConstraint: ConstraintComparable,
+		Excluded:          []Type{&ApproxType{Base: strType}},
+	}
+	stringsExceptString := TypeConstraint{
+		Types:    []Type{&ApproxType{Base: strType}},
+		Excluded: []Type{strType},
+	}
+	unionExceptInt := TypeConstraint{
+		Types:    []Type{intType, strType},
+		Union:    true,
+		Excluded: []Type{intType},
+	}
+
+	tests := []struct {
+		name       string
+		t          Type
+		constraint TypeConstraint
+		want       bool
+	}{
+		{"int is comparable except string", intType, comparableExceptString, true},
+		{"string is excluded", strType, comparableExceptString, false},
+		{"defined string type is not excluded exactly", myString, stringsExceptString, true},
+		{"exact type is excluded from approximation", strType, stringsExceptString, false},
+		{"defined string type is excluded by approximation", myString, comparableExceptStrings, false},
+		{"excluded union member", intType, unionExceptInt, false},
+		{"remaining union member", strType, unionExceptInt, true},
+		{"type variable is deferred", &TypeVariable{Name: "T"}, comparableExceptString, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkConstraint(tt.t, tt.constraint); got != tt.want {
+				t.Errorf("checkConstraint(%v, %v) = %v, want %v", tt.t, &tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstantiateGenericTypeExcluded(t *testing.T) {
+	gt := &GenericType{
+		Name:       "Set",
+		TypeParams: []Type{&TypeVariable{Name: "T"}},
+		Constraints: map[string]TypeConstraint{
+			"T": {
+				BuiltinConstraint: ConstraintComparable,
+				Excluded:          []Type{&TypeConstant{Name: "string"}},
+			},
+		},
+	}
+
+	if _, err := InstantiateGenericType(gt, []interface{}{&TypeConstant{Name: "int"}}, TypeEnv{}, nil); err != nil {
+		t.Errorf("InstantiateGenericType(int) error = %v", err)
+	}
+	if _, err := InstantiateGenericType(gt, []interface{}{&TypeConstant{Name: "string"}}, TypeEnv{}, nil); err == nil {
+		t.Errorf("InstantiateGenericType(string) expected error")
+	}
+	c := gt.Constraints["T"]
+	if got, want := c.String(), "Constraint([], []) except [TypeConst(string)]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckSatisfiable(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	integer := &TypeConstraint{BuiltinConstraint: ConstraintInteger}
+
+	tests := []struct {
+		name       string
+		constraint TypeConstraint
+		wantErr    bool
+	}{
+		{name: "any", constraint: TypeConstraint{BuiltinConstraint: ConstraintAny}},
+		{name: "builtin alone", constraint: TypeConstraint{BuiltinConstraint: ConstraintFloat}},
+		{name: "union", constraint: TypeConstraint{Types: []Type{intType, strType}, Union: true}},
+		{name: "comparable and union", constraint: TypeConstraint{BuiltinConstraint: ConstraintComparable, Types: []Type{intType, strType}, Union: true}},
+		{name: "integer and int", constraint: TypeConstraint{BuiltinConstraint: ConstraintInteger, Types: []Type{intType}}},
+		{name: "integer and approx int", constraint: TypeConstraint{BuiltinConstraint: ConstraintInteger, Types: []Type{&ApproxType{Base: intType}}}},
+		{name: "methods only", constraint: TypeConstraint{Interfaces: []Interface{{Name: "Stringer"}}}},
+		{name: "integer and string", constraint: TypeConstraint{BuiltinConstraint: ConstraintInteger, Types: []Type{strType}}, wantErr: true},
+		{name: "float and embedded integer", constraint: TypeConstraint{BuiltinConstraint: ConstraintFloat, Types: []Type{integer}}, wantErr: true},
+		{name: "ordered and slice", constraint: TypeConstraint{BuiltinConstraint: ConstraintOrdered, Types: []Type{&SliceType{ElementType: intType}}}, wantErr: true},
+		{name: "everything excluded", constraint: TypeConstraint{Types: []Type{intType, strType}, Union: true, Excluded: []Type{intType, strType}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSatisfiable(tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSatisfiable(%v) error = %v, wantErr %v", &tt.constraint, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrEmptyTypeSet) {
+				t.Errorf("checkSatisfiable() error = %v, want %v", err, ErrEmptyTypeSet)
+			}
+		})
+	}
+}
+
+func TestTypeParamListValidate(t *testing.T) {
+	empty := &TypeConstraint{BuiltinConstraint: ConstraintInteger, Types: []Type{&TypeConstant{Name: "string"}}}
+	params := TypeParamList{
+		{Name: "K", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}},
+		{Name: "V", Constraint: empty},
+	}
+
+	err := params.Validate()
+	if err == nil || !errors.Is(err, ErrEmptyTypeSet) || !strings.HasPrefix(err.Error(), "type parameter V: ") {
+		t.Fatalf("Validate() error = %v, want empty type set of V", err)
+	}
+	if err := params[:1].Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// defaults can only be given to trailing parameters
+	defaults := TypeParamList{{Name: "A", Default: &TypeConstant{Name: "int"}}, {Name: "B"}}
+	if err := defaults.Validate(); err == nil || err.Error() != "type parameter B without default follows type parameter A with default" {
+		t.Errorf("Validate() error = %v, want misplaced default", err)
+	}
+	if err := defaults[:1].Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestIntersectTerms(t *testing.T) {
+	intType, strType := &TypeConstant{Name: "int"}, &TypeConstant{Name: "string"}
+	age := &NamedType{Name: "Age", Underlying: intType}
+	tests := []struct {
+		name string
+		a, b []Type
+		want []Type
+	}{
+		{name: "exact", a: []Type{intType, strType}, b: []Type{strType}, want: []Type{strType}},
+		{name: "disjoint", a: []Type{intType}, b: []Type{strType}, want: nil},
+		{name: "approximate and exact", a: []Type{&ApproxType{Base: intType}}, b: []Type{age, strType}, want: []Type{age}},
+		{name: "approximate", a: []Type{&ApproxType{Base: intType}}, b: []Type{&ApproxType{Base: intType}}, want: []Type{&ApproxType{Base: intType}}},
+		{
+			name: "embedded type list",
+			a:    []Type{&TypeConstraint{Types: []Type{intType, strType}, Union: true}},
+			b:    []Type{strType},
+			want: []Type{strType},
+		},
+		{name: "builtin", a: []Type{&TypeConstraint{BuiltinConstraint: ConstraintInteger}}, b: []Type{age, strType}, want: []Type{age}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersectTerms(tt.a, tt.b); !typeListsEqual(got, tt.want) {
+				t.Errorf("intersectTerms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinConstraintUnderlying(t *testing.T) {
+	age := &NamedType{Name: "Age", Underlying: &TypeConstant{Name: "int"}}
+	celsius := &NamedType{Name: "Celsius", Underlying: &TypeConstant{Name: "float64"}}
+	name := &NamedType{Name: "Name", Underlying: &TypeConstant{Name: "string"}}
+	ids := &NamedType{Name: "IDs", Underlying: &SliceType{ElementType: age}}
+	intLike := &TypeConstraint{Types: []Type{&ApproxType{Base: &TypeConstant{Name: "int"}}, &ApproxType{Base: &TypeConstant{Name: "int64"}}}, Union: true}
+	numeric := &TypeConstraint{Types: []Type{&TypeConstant{Name: "int"}, &TypeConstant{Name: "float64"}}, Union: true}
+
+	tests := []struct {
+		name       string
+		t          Type
+		constraint string
+		want       bool
+	}{
+		{name: "defined integer is integer", t: age, constraint: ConstraintInteger, want: true},
+		{name: "defined integer is signed", t: age, constraint: ConstraintSigned, want: true},
+		{name: "defined integer is not unsigned", t: age, constraint: ConstraintUnsigned, want: false},
+		{name: "defined integer is ordered", t: age, constraint: ConstraintOrdered, want: true},
+		{name: "defined integer is comparable", t: age, constraint: ConstraintComparable, want: true},
+		{name: "defined float is float", t: celsius, constraint: ConstraintFloat, want: true},
+		{name: "defined float is not integer", t: celsius, constraint: ConstraintInteger, want: false},
+		{name: "defined string is ordered", t: name, constraint: ConstraintOrdered, want: true},
+		{name: "alias of defined type", t: &TypeAlias{Name: "Years", AliasedTo: age}, constraint: ConstraintInteger, want: true},
+		{name: "defined slice is not ordered", t: ids, constraint: ConstraintOrdered, want: false},
+		{name: "defined slice is not comparable", t: ids, constraint: ConstraintComparable, want: false},
+		{name: "type parameter with integer core type", t: intLike, constraint: ConstraintInteger, want: true},
+		{name: "type parameter with integer core type is ordered", t: intLike, constraint: ConstraintOrdered, want: true},
+		{name: "type parameter with mixed type set", t: numeric, constraint: ConstraintInteger, want: false},
+		{name: "type parameter constrained by Signed", t: &TypeConstraint{BuiltinConstraint: ConstraintSigned}, constraint: ConstraintOrdered, want: true},
+		{name: "type parameter constrained by Ordered", t: &TypeConstraint{BuiltinConstraint: ConstraintOrdered}, constraint: ConstraintInteger, want: false},
+		{name: "type parameter constrained by any", t: &TypeConstraint{BuiltinConstraint: ConstraintAny}, constraint: ConstraintInteger, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkBuiltinConstraint(tt.t, tt.constraint); got != tt.want {
+				t.Errorf("checkBuiltinConstraint(%v, %s) = %v, want %v", tt.t, tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApproxConstraintDefinedTypes(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	age := &NamedType{Name: "Age", Underlying: intType}
+	ids := &NamedType{Name: "IDs", Underlying: &SliceType{ElementType: intType}}
+	approxInt := &ApproxType{Base: intType}
+
+	tests := []struct {
+		name       string
+		t          Type
+		constraint TypeConstraint
+		want       bool
+	}{
+		{name: "~int accepts defined int", t: age, constraint: TypeConstraint{Types: []Type{approxInt}}, want: true},
+		{name: "~int accepts alias of defined int", t: &TypeAlias{Name: "Years", AliasedTo: age}, constraint: TypeConstraint{Types: []Type{approxInt}}, want: true},
+		{name: "int rejects defined int", t: age, constraint: TypeConstraint{Types: []Type{intType}}, want: false},
+		{name: "underlying flag accepts defined int", t: age, constraint: TypeConstraint{Types: []Type{intType}, IsUnderlying: true}, want: true},
+		{name: "~string rejects defined int", t: age, constraint: TypeConstraint{Types: []Type{&ApproxType{Base: &TypeConstant{Name: "string"}}}}, want: false},
+		{name: "~[]int accepts defined slice", t: ids, constraint: TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: intType}}}}, want: true},
+		{
+			name:       "~[]int rejects slice of defined ints",
+			t:          &SliceType{ElementType: age},
+			constraint: TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: intType}}}},
+			want:       false,
+		},
+		{
+			name:       "~[]Age accepts slice of defined ints",
+			t:          &SliceType{ElementType: age},
+			constraint: TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: age}}}},
+			want:       true,
+		},
+		{name: "excluded defined type", t: age, constraint: TypeConstraint{Types: []Type{approxInt}, Excluded: []Type{age}}, want: false},
+		{name: "excluded ~int", t: age, constraint: TypeConstraint{Types: []Type{approxInt, age}, Excluded: []Type{approxInt}}, want: false},
+		{name: "~int with integer builtin", t: age, constraint: TypeConstraint{Types: []Type{approxInt}, BuiltinConstraint: ConstraintInteger}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkConstraint(tt.t, tt.constraint); got != tt.want {
+				t.Errorf("checkConstraint(%v, %v) = %v, want %v", tt.t, &tt.constraint, got, tt.want)
+			}
+		})
+	}
+
+	// a defined type is absorbed by the ~T term for its underlying type
+	normalized := NormalizeConstraint(TypeConstraint{Types: []Type{age, approxInt}, Union: true})
+	if !typeListsEqual(normalized.Types, []Type{approxInt}) {
+		t.Errorf("NormalizeConstraint() = %v, want [~int]", normalized.Types)
+	}
+	if err := checkSatisfiable(TypeConstraint{Types: []Type{age}, BuiltinConstraint: ConstraintSigned}); err != nil {
+		t.Errorf("checkSatisfiable() error = %v", err)
+	}
+	if err := checkSatisfiable(TypeConstraint{Types: []Type{age}, BuiltinConstraint: ConstraintFloat}); !errors.Is(err, ErrEmptyTypeSet) {
+		t.Errorf("checkSatisfiable() error = %v, want %v", err, ErrEmptyTypeSet)
+	}
+}
@@ -1,7 +1,10 @@
 package generic
 
 import (
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/token"
 )
 
 type InferenceContext struct {
@@ -9,6 +12,144 @@ type InferenceContext struct {
 	IsAssignment  bool
 	IsFunctionArg bool
 	IsReturnValue bool
+
+	// GoVersion is the language version being checked, like "go1.21".
+	// It only enables diagnostics that depend on the version, and the latest rules apply if empty.
+	GoVersion string
+
+	// Extensions enables experimental behaviour beyond Go's type system.
+	Extensions Extension
+
+	// Arena, if set, allocates the type nodes built by instantiation. See Arena.
+	Arena *Arena
+
+	// Fset, if set, is the file set of the nodes, used to resolve the positions of
+	// TypeErrors.
+	Fset *token.FileSet
+
+	// CollectErrors enables collecting errors: the declarations, return values and call
+	// arguments of a node are all inferred, rather than stopping at the first failure, and
+	// their errors returned joined, up to ErrorLimit errors followed by ErrTooManyErrors.
+	CollectErrors bool
+
+	// ErrorLimit is the number of errors collected before the inference stops, see
+	// CollectErrors. Zero means no limit, like Options.MaxErrors.
+	ErrorLimit int
+
+	// Profile, if set, records the cost of inferring each declaration of InferFile.
+	Profile *Profile
+
+	// collected is the number of errors collected by the enclosing nodes, which count
+	// towards ErrorLimit.
+	collected int
+
+	// typeArgs, if set, records the type arguments of the calls of generic functions,
+	// whether explicit or inferred, see Info.
+	typeArgs map[*ast.CallExpr][]Type
+}
+
+// Extension is a set of experimental features that embedders, like DSLs built on top of
+// the inference engine, can opt into. None of them are enabled by default.
+type Extension uint
+
+const (
+	// ExtTypeParamDefaults fills in the trailing type arguments an instantiation omits
+	// with the defaults of their type parameters, like `Map[string]` for `Map[K any, V any = K]`.
+	ExtTypeParamDefaults Extension = 1 << iota
+
+	// ExtRowPolymorphism lets selecting a field of a value whose type is not known yet
+	// constrain it to a struct with at least that field, see ExtensibleStruct and HasField.
+	ExtRowPolymorphism
+)
+
+// defaultContext is the context used when none is given. It must not be modified.
+var defaultContext InferenceContext
+
+// fileSet returns the file set of the context, or nil if it has none.
+func (ctx *InferenceContext) fileSet() *token.FileSet {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Fset
+}
+
+// errorList returns a new list collecting the errors of a node inferred in ctx.
+func (ctx *InferenceContext) errorList() *errorList {
+	if ctx == nil {
+		return &errorList{}
+	}
+	return &errorList{collect: ctx.CollectErrors, limit: ctx.ErrorLimit, offset: ctx.collected}
+}
+
+// errorList collects errors up to a limit, see InferenceContext.ErrorLimit.
+type errorList struct {
+	collect bool
+	limit   int
+	offset  int // the errors collected by the enclosing lists
+	errs    []error
+}
+
+// add records err, or the errors joined in it, reporting whether the inference goes on.
+func (l *errorList) add(err error) bool {
+	l.errs = append(l.errs, flattenErrors(err)...)
+	switch {
+	case !l.collect:
+		return false
+	case errors.Is(err, ErrTooManyErrors):
+		return false // the limit was reached by a nested list
+	case l.limit > 0 && l.offset+len(l.errs) >= l.limit:
+		l.errs = append(l.errs[:l.limit-l.offset], fmt.Errorf("%w: stopped after %d", ErrTooManyErrors, l.limit))
+		return false
+	}
+	return true
+}
+
+// nest sets the error limit of ctx, the context of a node inferred next, so that the errors
+// it collects count towards the limit of l.
+func (l *errorList) nest(ctx *InferenceContext) {
+	ctx.CollectErrors, ctx.ErrorLimit = l.collect, l.limit
+	ctx.collected = l.offset + len(l.errs)
+}
+
+// err returns the errors collected, joined, or the only one as is.
+func (l *errorList) err() error {
+	if len(l.errs) == 1 {
+		return l.errs[0]
+	}
+	return errors.Join(l.errs...)
+}
+
+// flattenErrors returns the errors joined in err, recursively, or err itself.
+func flattenErrors(err error) []error {
+	var errs []error
+	for _, e := range unjoin(err) {
+		if _, ok := e.(interface{ Unwrap() []error }); ok {
+			errs = append(errs, flattenErrors(e)...)
+		} else {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// recordTypeArgs records the type arguments of call, a call of a generic function.
+func (ctx *InferenceContext) recordTypeArgs(call *ast.CallExpr, args []Type) {
+	if ctx != nil && ctx.typeArgs != nil {
+		ctx.typeArgs[call] = append([]Type(nil), args...)
+	}
+}
+
+// arena returns the arena of the context, or nil if it has none.
+func (ctx *InferenceContext) arena() *Arena {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Arena
+}
+
+// Enabled reports whether all the given extensions are enabled.
+func (ctx *InferenceContext) Enabled(ext Extension) bool {
+	return ctx != nil && ctx.Extensions&ext == ext
 }
 
 func NewInferenceContext(options ...func(*InferenceContext)) *InferenceContext {
@@ -19,6 +160,23 @@ func NewInferenceContext(options ...func(*InferenceContext)) *InferenceContext {
 	return ctx
 }
 
+// Child returns the context of a node inferred as part of the node of ctx, like an element
+// of a composite literal. It inherits the settings of ctx, like its extensions, arena and
+// error limit, and its IsAssignment, IsFunctionArg and IsReturnValue flags, but not its
+// expected type, which is that of the node of ctx. The options then apply as in
+// NewInferenceContext. The child of a nil context inherits nothing.
+func (ctx *InferenceContext) Child(options ...func(*InferenceContext)) *InferenceContext {
+	child := &InferenceContext{}
+	if ctx != nil {
+		*child = *ctx
+		child.ExpectedType = nil
+	}
+	for _, opt := range options {
+		opt(child)
+	}
+	return child
+}
+
 func WithExpectedType(t Type) func(*InferenceContext) {
 	return func(ctx *InferenceContext) {
 		ctx.ExpectedType = t
@@ -43,9 +201,22 @@ func WithReturnValue() func(*InferenceContext) {
 	}
 }
 
+func WithGoVersion(version string) func(*InferenceContext) {
+	return func(ctx *InferenceContext) {
+		ctx.GoVersion = version
+	}
+}
+
+func WithExtensions(ext Extension) func(*InferenceContext) {
+	return func(ctx *InferenceContext) {
+		ctx.Extensions |= ext
+	}
+}
+
 // InferType infers the type of an expression.
 func checkInterfaceCompatibility(iface, expected *InterfaceType) error {
-	for name, method := range expected.Methods {
+	for _, name := range sortedKeys(expected.Methods) {
+		method := expected.Methods[name]
 		ifaceMethod, ok := iface.Methods[name]
 		if !ok {
 			return fmt.Errorf("missing method %s", name)
@@ -77,15 +248,28 @@ func checkFunctionCompatibility(func1, func2 *FunctionType) error {
 	return nil
 }
 
-// checkTupleCompatibility checks if two tuple types are compatible.
-func checkTupleCompatibility(tuple1, tuple2 *TupleType) error {
-	if len(tuple1.Types) != len(tuple2.Types) {
-		return fmt.Errorf("tuple length mismatch. expected %d elements, got %d", len(tuple2.Types), len(tuple1.Types))
+func WithArena(a *Arena) func(*InferenceContext) {
+	return func(ctx *InferenceContext) {
+		ctx.Arena = a
 	}
-	for i, type1 := range tuple1.Types {
-		if !TypesEqual(type1, tuple2.Types[i]) {
-			return fmt.Errorf("element type mismatch at position %d", i)
-		}
+}
+
+func WithFileSet(fset *token.FileSet) func(*InferenceContext) {
+	return func(ctx *InferenceContext) {
+		ctx.Fset = fset
+	}
+}
+
+// WithErrorLimit collects the errors of the inference, up to n of them, see
+// InferenceContext.CollectErrors. Zero means no limit, like Options.MaxErrors.
+func WithErrorLimit(n int) func(*InferenceContext) {
+	return func(ctx *InferenceContext) {
+		ctx.CollectErrors, ctx.ErrorLimit = true, n
+	}
+}
+
+func WithProfile(p *Profile) func(*InferenceContext) {
+	return func(ctx *InferenceContext) {
+		ctx.Profile = p
 	}
-	return nil
 }
@@ -0,0 +1,76 @@
+package generic
+
+import (
+	"go/parser"
+	"testing"
+)
+
+func TestInferenceContextChild(t *testing.T) {
+	arena := &Arena{}
+	parent := NewInferenceContext(
+		WithExpectedType(Int),
+		WithAssignment(),
+		WithGoVersion("go1.21"),
+		WithExtensions(ExtRowPolymorphism),
+		WithArena(arena),
+		WithErrorLimit(3),
+	)
+
+	child := parent.Child(WithExpectedType(String), WithFunctionArg())
+	if child.ExpectedType != Type(String) || !child.IsFunctionArg {
+		t.Errorf("Child() = %+v, want the options applied", child)
+	}
+	if !child.IsAssignment || child.GoVersion != "go1.21" || !child.Enabled(ExtRowPolymorphism) || child.Arena != arena || !child.CollectErrors || child.ErrorLimit != 3 {
+		t.Errorf("Child() = %+v, want the flags and settings of the parent", child)
+	}
+	if got := parent.Child(); got.ExpectedType != nil {
+		t.Errorf("Child().ExpectedType = %v, want nil", got.ExpectedType)
+	}
+	if parent.ExpectedType != Type(Int) || parent.IsFunctionArg {
+		t.Errorf("parent = %+v, want it unchanged", parent)
+	}
+
+	var none *InferenceContext
+	if got := none.Child(WithAssignment()); got == nil || !got.IsAssignment || got.Extensions != 0 {
+		t.Errorf("nil Child() = %+v, want a new context with the options", got)
+	}
+}
+
+// TestContextPropagation checks that the nested expressions are inferred with the
+// settings of the context of the expression, here an extension.
+func TestContextPropagation(t *testing.T) {
+	k := &TypeVariable{Name: "K"}
+	cache := NewGenericType("Cache", TypeParamList{
+		{Name: "K"},
+		{Name: "V", Default: String},
+	}, map[string]Type{"key": k, "value": &TypeVariable{Name: "V"}}, nil)
+	env := TypeEnv{"int": Int, "string": String, "Cache": cache}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"slice literal element type", "[]Cache[int]{}", "[]Cache[int, string]"},
+		{"array literal element type", "[2]Cache[int]{}", "[2]Cache[int, string]"},
+		{"pointer", "*Cache[int]", "*Cache[int, string]"},
+		{"address of literal", "&Cache[int]{}", "*Cache[int, string]"},
+		{"function parameter", "func(Cache[int]) {}", "func(Cache[int, string])"},
+		{"map literal value", "map[string][]Cache[int]{\"a\": []Cache[int]{}}", "map[string][]Cache[int, string]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, NewInferenceContext(WithExtensions(ExtTypeParamDefaults)))
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"go/version"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+var (
+	ErrInvalidConversion   = gerrors.ErrInvalidConversion
+	ErrStringIntConversion = errors.New("conversion from integer to string yields a string of one rune, not a string of digits")
+)
+
+// stringIntConvVersion is the first version in which `go vet` reports
+// conversions like `string(i)` from integers that are not runes or bytes.
+const stringIntConvVersion = "go1.15"
+
+// The first versions allowing the conversions of slices to array pointers, like
+// `(*[4]byte)(b)`, and to arrays, like `[4]byte(b)`.
+const (
+	sliceToArrayPointerVersion = "go1.17"
+	sliceToArrayVersion        = "go1.20"
+)
+
+// conversionType resolves the function of a call expression to the target type of a conversion,
+// like `string` in `string(b)` or `[]byte` in `[]byte(s)`.
+// It reports false if fun does not denote a type, in which case the call is a regular function call.
+//
+// Since the environment binds both values and types, an identifier denotes a type only if
+// it is bound to a named type of the same name. Predeclared types need not be in the environment.
+func conversionType(fun ast.Expr, env TypeEnv) (Type, bool) {
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		t, ok := env[fun.Name]
+		if !ok {
+			if isPredeclaredType(fun.Name) {
+				return typeConstant(fun.Name), true
+			}
+			return nil, false
+		}
+		switch t := t.(type) {
+		case *TypeConstant:
+			return t, t.Name == fun.Name || t == predeclared[fun.Name] // byte and rune name uint8 and int32
+		case *StructType:
+			return t, t.Name == fun.Name
+		case *InterfaceType:
+			return t, t.Name == fun.Name
+		case *NamedType:
+			return t, t.Name == fun.Name
+		case *TypeAlias:
+			return t.AliasedTo, t.Name == fun.Name
+		}
+	case *ast.ParenExpr:
+		return conversionType(fun.X, env)
+	case *ast.StarExpr:
+		if base, ok := conversionType(fun.X, env); ok {
+			return &PointerType{Base: base}, true
+		}
+	case *ast.ArrayType:
+		elem, ok := conversionType(fun.Elt, env)
+		if !ok {
+			return nil, false
+		}
+		if fun.Len == nil {
+			return &SliceType{ElementType: elem}, true
+		}
+		length, err := constantIndex(fun.Len, env)
+		if err != nil {
+			return nil, false
+		}
+		return &ArrayType{ElementType: elem, Len: length}, true
+	case *ast.InterfaceType:
+		if t, err := InferType(fun, env, nil); err == nil {
+			return t, true
+		}
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		// an instance of a generic type, like `List[int]`
+		x, _ := instanceParts(fun)
+		ident, ok := x.(*ast.Ident)
+		if !ok {
+			return nil, false
+		}
+		if gt, ok := env[ident.Name].(*GenericType); !ok || gt.Signature != nil || gt.Name != ident.Name {
+			return nil, false
+		}
+		if t, err := InferType(fun, env, nil); err == nil {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// isPredeclaredType reports whether name is one of Go's predeclared basic types.
+func isPredeclaredType(name string) bool {
+	return signedIntegers[name] || unsignedIntegers[name] || floats[name] || complexes[name] ||
+		name == TypeString || name == TypeBool
+}
+
+// inferConversion infers the type of a conversion `T(x)` of the single argument to target.
+func inferConversion(target Type, args []ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing argument in conversion to %s", FormatGo(target))
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("too many arguments in conversion to %s", FormatGo(target))
+	}
+
+	arg := args[0]
+	argType, err := InferType(arg, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	if isNoValue(argType) {
+		return nil, fmt.Errorf("conversion of %s: %w", types.ExprString(arg), ErrNoValueUsed)
+	}
+
+	if !convertible(argType, target) {
+		return nil, fmt.Errorf("%w %s (type %s) to %s", ErrInvalidConversion, types.ExprString(arg), FormatGo(argType), FormatGo(target))
+	}
+	if v := sliceConversionVersion(argType, target); v != "" && ctx.GoVersion != "" && version.Compare(ctx.GoVersion, v) < 0 {
+		return nil, fmt.Errorf("%w %s (type %s) to %s: requires %s or later", ErrInvalidConversion, types.ExprString(arg), FormatGo(argType), FormatGo(target), v)
+	}
+	if isStringIntConversion(argType, target) && (ctx.GoVersion == "" || version.Compare(ctx.GoVersion, stringIntConvVersion) >= 0) {
+		return nil, fmt.Errorf("%s: %w (did you mean fmt.Sprint(x)?)", types.ExprString(arg), ErrStringIntConversion)
+	}
+	return target, nil
+}
+
+// convertible reports whether a value of type from can be converted to type to.
+//
+// Besides identical types, this covers conversions between numeric types, between pointers
+// to identical types, to interfaces the type implements, and the string conversions:
+// `string(r)` from integers, `string(b)` and `string(rs)` from byte and rune slices,
+// and `[]byte(s)` and `[]rune(s)` from strings. Slices convert to arrays and pointers to
+// arrays of their element type, like `[4]byte(b)` and `(*[4]byte)(b)`.
+func convertible(from, to Type) bool {
+	from, to = unalias(from), unalias(to)
+	if isNil(from) {
+		return isNilable(to)
+	}
+	// a defined type converts to and from its underlying type
+	if TypesEqual(from, to) || TypesEqual(underlying(from), underlying(to)) {
+		return true
+	}
+	if st, ok := underlying(from).(*SliceType); ok {
+		if at, _ := sliceConversionTarget(to); at != nil {
+			return TypesEqual(unalias(st.ElementType), unalias(at.ElementType))
+		}
+	}
+
+	// a value converts to the interfaces it implements, defined ones like `Stringer` included
+	if iface, ok := underlying(to).(*InterfaceType); ok {
+		if iface.Variants != nil {
+			return IsVariant(iface, from)
+		}
+		return iface.IsEmpty || implInterface(from, Interface{Name: FormatGo(to), Methods: interfaceMethods(iface)})
+	}
+	switch to := to.(type) {
+	case *PointerType:
+		fromPtr, ok := from.(*PointerType)
+		return ok && TypesEqual(unalias(fromPtr.Base), unalias(to.Base))
+	}
+
+	switch {
+	case (isInteger(from) || isFloat(from)) && (isInteger(to) || isFloat(to)):
+		return true
+	case isComplex(from) && isComplex(to):
+		return true
+	case isString(to):
+		return isInteger(from) || isByteSlice(from) || isRuneSlice(from)
+	case isString(from):
+		return isByteSlice(to) || isRuneSlice(to)
+	}
+	return false
+}
+
+// assignable checks that a value of type v can be assigned to a variable of type t, like
+// in `var x T = v`. Besides the types that unify, this covers the types implementing
+// an interface t, and the types with the same underlying type as t when either of them
+// is a type literal, like `[]int` for `type Ints []int`, or a value of a named struct type
+// for the anonymous struct with the same fields. Untyped constants are converted by the
+// callers, see convertUntyped.
+func assignable(t, v Type, env TypeEnv, ctx *InferenceContext) error {
+	err := unifyInto(t, v, env, ctx)
+	if err == nil {
+		return nil
+	}
+	t, v = unalias(resolve(unwrapObject(t), env)), unalias(resolve(unwrapObject(v), env))
+	if want, ok := interfaceOf(t); ok {
+		if _, ok := v.(*TypeVariable); ok {
+			return err
+		}
+		if len(want.Methods) == 0 || implInterface(v, want) {
+			return nil
+		}
+		proof := ExplainSatisfaction(v, TypeConstraint{Interfaces: []Interface{want}})
+		return fmt.Errorf("%w: %s does not implement %s (%s)", ErrTypeMismatch, FormatGo(v), FormatGo(t), proof.Reason)
+	}
+	if (!isNamed(t) || !isNamed(v)) && TypesEqual(underlyingOf(underlying(t)), underlyingOf(underlying(v))) {
+		return nil
+	}
+	return err
+}
+
+// interfaceOf returns the interface a value of type t must implement, if t is a
+// non-sum interface type, the predeclared error, whose method is Error, included.
+func interfaceOf(t Type) (Interface, bool) {
+	switch u := underlying(t).(type) {
+	case *InterfaceType:
+		if u.Variants == nil {
+			return Interface{Name: FormatGo(t), Methods: interfaceMethods(u)}, true
+		}
+	case *TypeConstant:
+		if u.Name == Error.Name {
+			return Interface{Name: Error.Name, Methods: MethodSet{"Error": {Name: "Error", Results: []Type{String}}}}, true
+		}
+	}
+	return Interface{}, false
+}
+
+// isNamed reports whether t is a named type: a predeclared, defined or generic type,
+// rather than a type literal like `[]int` or `struct{ X int }`.
+func isNamed(t Type) bool {
+	switch t := t.(type) {
+	case *TypeConstant, *NamedType, *GenericType, *TypeVariable:
+		return true
+	case *StructType:
+		return t.Name != ""
+	}
+	return false
+}
+
+// sliceConversionTarget returns the array type a slice converted to t is copied to, or
+// pointed to if pointer is set, or nil if t is neither an array nor a pointer to one.
+func sliceConversionTarget(t Type) (at *ArrayType, pointer bool) {
+	switch u := underlying(t).(type) {
+	case *ArrayType:
+		return u, false
+	case *PointerType:
+		at, _ := underlying(unalias(u.Base)).(*ArrayType)
+		return at, true
+	}
+	return nil, false
+}
+
+// sliceConversionVersion returns the first version allowing the conversion from a slice
+// to an array or a pointer to an array, or "" if from and to are not such types.
+func sliceConversionVersion(from, to Type) string {
+	if _, ok := underlying(unalias(from)).(*SliceType); !ok {
+		return ""
+	}
+	switch at, pointer := sliceConversionTarget(unalias(to)); {
+	case at == nil:
+		return ""
+	case pointer:
+		return sliceToArrayPointerVersion
+	}
+	return sliceToArrayVersion
+}
+
+// isStringIntConversion reports whether converting from to to is a conversion from
+// an integer other than a byte or rune to a string, which `go vet` reports.
+func isStringIntConversion(from, to Type) bool {
+	from, to = unalias(from), unalias(to)
+	return isString(to) && isInteger(from) && !isByte(from) && !isRune(from)
+}
+
+func unalias(t Type) Type {
+	for {
+		alias, ok := t.(*TypeAlias)
+		if !ok {
+			return t
+		}
+		t = alias.AliasedTo
+	}
+}
+
+func isString(t Type) bool {
+	tc, ok := underlying(t).(*TypeConstant)
+	return ok && tc.Name == TypeString
+}
+
+func isByte(t Type) bool {
+	tc, ok := underlying(t).(*TypeConstant)
+	return ok && (tc.Name == TypeByte || tc.Name == TypeUint8)
+}
+
+func isRune(t Type) bool {
+	tc, ok := underlying(t).(*TypeConstant)
+	return ok && (tc.Name == TypeRune || tc.Name == TypeInt32)
+}
+
+func isByteSlice(t Type) bool {
+	st, ok := underlying(t).(*SliceType)
+	return ok && isByte(st.ElementType)
+}
+
+func isRuneSlice(t Type) bool {
+	st, ok := underlying(t).(*SliceType)
+	return ok && isRune(st.ElementType)
+}
@@ -0,0 +1,137 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"strings"
+	"testing"
+)
+
+func TestInferConversion(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	byteType := &TypeConstant{Name: "byte"}
+	runeType := &TypeConstant{Name: "rune"}
+	env := TypeEnv{
+		"s":     strType,
+		"b":     &SliceType{ElementType: byteType},
+		"u":     &SliceType{ElementType: &TypeConstant{Name: "uint8"}},
+		"rs":    &SliceType{ElementType: runeType},
+		"r":     runeType,
+		"c":     byteType,
+		"i":     intType,
+		"i64":   &TypeConstant{Name: "int64"},
+		"f":     &TypeConstant{Name: "float64"},
+		"xs":    &SliceType{ElementType: intType},
+		"arr":   &ArrayType{ElementType: intType, Len: 4},
+		"Key":   &NamedType{Name: "Key", Underlying: &ArrayType{ElementType: byteType, Len: 4}},
+		"byte":  byteType,
+		"rune":  runeType,
+		"Bytes": &TypeAlias{Name: "Bytes", AliasedTo: &SliceType{ElementType: byteType}},
+		"Age":   &NamedType{Name: "Age", Underlying: intType},
+		"Name":  &NamedType{Name: "Name", Underlying: strType},
+		"a":     &NamedType{Name: "Age", Underlying: intType},
+		"n":     &NamedType{Name: "Name", Underlying: strType},
+		"bytes": &FunctionType{ParamTypes: []Type{strType}, ReturnType: &SliceType{ElementType: byteType}},
+	}
+
+	tests := []struct {
+		name      string
+		src       string
+		version   string
+		wantType  Type
+		wantErr   string
+		wantErrIs error
+	}{
+		{name: "string from bytes", src: "string(b)", wantType: strType},
+		{name: "defined type from literal", src: "Age(5)", wantType: env["Age"]},
+		{name: "defined type from underlying type", src: "Age(i)", wantType: env["Age"]},
+		{name: "underlying type from defined type", src: "int(a)", wantType: intType},
+		{name: "defined type from another numeric type", src: "Age(f)", wantType: env["Age"]},
+		{name: "bytes from defined string", src: "[]byte(n)", wantType: &SliceType{ElementType: byteType}},
+		{name: "defined string from bytes", src: "Name(b)", wantType: env["Name"]},
+		{name: "defined int from string", src: "Age(s)", wantErr: "cannot convert"},
+		{name: "string from uint8 slice", src: "string(u)", wantType: strType},
+		{name: "string from runes", src: "string(rs)", wantType: strType},
+		{name: "string from rune", src: "string(r)", wantType: strType},
+		{name: "string from byte", src: "string(c)", wantType: strType},
+		{name: "string from rune literal", src: "string('a')", wantType: strType},
+		{name: "bytes from string", src: "[]byte(s)", wantType: &SliceType{ElementType: byteType}},
+		{name: "runes from string", src: "[]rune(s)", wantType: &SliceType{ElementType: runeType}},
+		{name: "bytes from string literal", src: `[]byte("abc")`, wantType: &SliceType{ElementType: byteType}},
+		{name: "bytes from call result", src: `string(bytes("abc"))`, wantType: strType},
+		{name: "alias of byte slice", src: "Bytes(s)", wantType: &SliceType{ElementType: byteType}},
+		{name: "numeric", src: "float64(i)", wantType: &TypeConstant{Name: "float64"}},
+		{name: "numeric from float", src: "int64(f)", wantType: &TypeConstant{Name: "int64"}},
+		{name: "identical", src: "string(s)", wantType: strType},
+		{name: "parenthesized type", src: "(string)(b)", wantType: strType},
+		{name: "string from int before vet check", src: "string(i)", version: "go1.14", wantType: strType},
+		{
+			name:      "string from int",
+			src:       "string(i)",
+			wantErr:   "i: conversion from integer to string yields a string of one rune, not a string of digits (did you mean fmt.Sprint(x)?)",
+			wantErrIs: ErrStringIntConversion,
+		},
+		{name: "string from int64 with version", src: "string(i64)", version: "go1.22", wantErrIs: ErrStringIntConversion},
+		{name: "string from int slice", src: "string(xs)", wantErr: "cannot convert xs (type []int) to string", wantErrIs: ErrInvalidConversion},
+		{name: "int slice from string", src: "[]int(s)", wantErrIs: ErrInvalidConversion},
+		{name: "int from string", src: "int(s)", wantErrIs: ErrInvalidConversion},
+		{name: "array from slice", src: "[4]int(xs)", wantType: &ArrayType{ElementType: intType, Len: 4}},
+		{name: "array pointer from slice", src: "(*[4]int)(xs)", wantType: &PointerType{Base: &ArrayType{ElementType: intType, Len: 4}}},
+		{name: "defined array from slice", src: "Key(b)", wantType: env["Key"]},
+		{name: "identical array", src: "[4]int(arr)", wantType: env["arr"]},
+		{name: "array from slice in go1.20", src: "[4]int(xs)", version: "go1.20", wantType: &ArrayType{ElementType: intType, Len: 4}},
+		{name: "array from slice before go1.20", src: "[4]int(xs)", version: "go1.19", wantErr: "requires go1.20 or later", wantErrIs: ErrInvalidConversion},
+		{name: "array pointer from slice before go1.17", src: "(*[4]int)(xs)", version: "go1.16", wantErr: "requires go1.17 or later", wantErrIs: ErrInvalidConversion},
+		{name: "array from slice of other element type", src: "[4]int(b)", wantErrIs: ErrInvalidConversion},
+		{name: "array of other length", src: "[2]int(arr)", wantErrIs: ErrInvalidConversion},
+		{name: "slice from array", src: "[]int(arr)", wantErrIs: ErrInvalidConversion},
+		{name: "missing argument", src: "string()", wantErr: "missing argument in conversion to string"},
+		{name: "too many arguments", src: "string(b, b)", wantErr: "too many arguments in conversion to string"},
+		{name: "unknown argument", src: "string(x)", wantErr: "unknown identifier: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, NewInferenceContext(WithGoVersion(tt.version)))
+			if tt.wantErr != "" || tt.wantErrIs != nil {
+				if err == nil {
+					t.Fatalf("InferType() = %v, want error", got)
+				}
+				if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %q, want %q", err, tt.wantErr)
+				}
+				if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+					t.Errorf("InferType() error = %v, want %v", err, tt.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestConversionTypeIgnoresValues(t *testing.T) {
+	// `n` is a value of type int, so `n(1)` is a call and not a conversion
+	env := TypeEnv{"n": &TypeConstant{Name: "int"}}
+	expr, err := parser.ParseExpr("n(1)")
+	if err != nil {
+		t.Fatalf("ParseExpr() error = %v", err)
+	}
+	if _, ok := conversionType(expr.(*ast.CallExpr).Fun, env); ok {
+		t.Errorf("conversionType() treated a value as a type")
+	}
+	if _, err := InferType(expr, env, nil); err == nil {
+		t.Errorf("InferType() expected error for calling a non-function")
+	}
+}
@@ -0,0 +1,273 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// inferGenDecl binds the names declared by a var, const or type declaration in env,
+// like `var x int = 5`, `const s = "hi"` or `var a, b = 1, 2`.
+//
+// The initializers must be assignable to the declared type, if any; without one, the names
+// take the types of their initializers, with untyped constants taking their default type.
+// Within a constant declaration, a spec without initializers repeats the previous one,
+// and `iota` is the untyped integer constant of the index of the spec.
+//
+// It stops at the first spec that fails, unless ctx collects errors; the names of a failed
+// spec are still bound, see declareInvalid.
+func inferGenDecl(decl *ast.GenDecl, env TypeEnv, ctx *InferenceContext) error {
+	errs := ctx.errorList()
+	switch decl.Tok {
+	case token.IMPORT:
+		return nil
+	case token.TYPE:
+		for _, spec := range decl.Specs {
+			if err := inferTypeSpec(spec.(*ast.TypeSpec), env); err != nil {
+				if !errs.add(typeError(spec, ctx, fmt.Errorf("type %s: %w", spec.(*ast.TypeSpec).Name.Name, err))) {
+					break
+				}
+			}
+		}
+		return errs.err()
+	}
+
+	var last *ast.ValueSpec
+	for index, spec := range decl.Specs {
+		orig := spec
+		spec := spec.(*ast.ValueSpec)
+		if decl.Tok == token.CONST {
+			if spec.Type == nil && len(spec.Values) == 0 && last != nil {
+				// `const ( a = iota; b; c )` repeats the type and initializers of a
+				spec = &ast.ValueSpec{Names: spec.Names, Type: last.Type, Values: last.Values}
+			}
+			last = spec
+			values := make([]ast.Expr, len(spec.Values))
+			for i, value := range spec.Values {
+				values[i] = replaceIota(value, index)
+			}
+			spec = &ast.ValueSpec{Names: spec.Names, Type: spec.Type, Values: values}
+		}
+
+		specTypes, err := valueSpecTypes(spec, decl.Tok, env, ctx)
+		if err != nil {
+			declareInvalid(spec, decl.Tok, env)
+			names := make([]string, len(spec.Names))
+			for i, name := range spec.Names {
+				names[i] = name.Name
+			}
+			if !errs.add(typeError(orig, ctx, fmt.Errorf("declaration of %s: %w", strings.Join(names, ", "), err))) {
+				break
+			}
+			continue
+		}
+		for i, name := range spec.Names {
+			if name.Name == "_" {
+				continue
+			}
+			if decl.Tok == token.CONST {
+				val := constantOf(spec.Values[i], env)
+				env[name.Name] = &ConstObj{Name: name.Name, Type: specTypes[i], Val: val, Untyped: spec.Type == nil && val.Kind() != constant.Unknown}
+			} else {
+				env[name.Name] = &VarObj{Name: name.Name, Type: specTypes[i]}
+			}
+		}
+	}
+	return errs.err()
+}
+
+// invalidType is the type of the names of a declaration that failed, like `x` after
+// `var x = missing`, when it declares none, see declareInvalid.
+var invalidType Type = &TypeConstant{Name: "invalid type"}
+
+// errInvalidOperand is the error of a use of a name of invalidType. Its cause, the
+// declaration that failed, is reported already, so the errors that wrap it are not, see
+// followsInvalid.
+var errInvalidOperand = errors.New("invalid operand")
+
+// declareInvalid binds the names of spec, whose declaration failed, so that their uses do
+// not report them as unknown identifiers. Like go/types, they take the declared type of spec,
+// if any, or else invalidType.
+func declareInvalid(spec *ast.ValueSpec, tok token.Token, env TypeEnv) {
+	t := invalidType
+	if spec.Type != nil && checkTypeExpr(spec.Type, env) == nil {
+		if declared, err := InferType(spec.Type, env, nil); err == nil {
+			t = declared
+		}
+	}
+	for _, name := range spec.Names {
+		if name.Name == "_" {
+			continue
+		}
+		if tok == token.CONST {
+			env[name.Name] = &ConstObj{Name: name.Name, Type: t, Val: constant.MakeUnknown()}
+		} else {
+			env[name.Name] = &VarObj{Name: name.Name, Type: t}
+		}
+	}
+}
+
+// followsInvalid reports whether the errors of err all follow from the use of names of
+// invalidType, and so are not reported.
+func followsInvalid(err error) bool {
+	for _, e := range flattenErrors(err) {
+		if !errors.Is(e, errInvalidOperand) {
+			return false
+		}
+	}
+	return err != nil
+}
+
+// inferTypeSpec binds a type declared in a function body, like `type pair struct{ a, b int }`.
+func inferTypeSpec(spec *ast.TypeSpec, env TypeEnv) error {
+	if iface, ok := spec.Type.(*ast.InterfaceType); ok && hasTypeElements(iface) {
+		t, err := declareConstraint(spec, env)
+		if t != nil {
+			env[spec.Name.Name] = t
+		}
+		return err
+	}
+	t, err := declareType(spec, env)
+	if err != nil {
+		return err
+	}
+	// bound before it is defined, so that it can refer to itself, like `type node struct{ next *node }`
+	env[spec.Name.Name] = t
+	return defineType(spec, t, env)
+}
+
+// valueSpecTypes returns the types of the names declared by spec.
+func valueSpecTypes(spec *ast.ValueSpec, tok token.Token, env TypeEnv, ctx *InferenceContext) ([]Type, error) {
+	var declared Type
+	if spec.Type != nil {
+		if err := checkTypeExpr(spec.Type, env); err != nil {
+			return nil, err
+		}
+		t, err := InferType(spec.Type, env, NewInferenceContext(WithFileSet(ctx.fileSet())))
+		if err != nil {
+			return nil, err
+		}
+		declared = t
+	}
+
+	names := len(spec.Names)
+	result := make([]Type, names)
+	switch {
+	case len(spec.Values) == 0:
+		if tok == token.CONST {
+			return nil, errors.New("missing init expr for const declaration")
+		}
+		if declared == nil {
+			return nil, errors.New("missing type or init expr")
+		}
+		for i := range result {
+			result[i] = declared
+		}
+		return result, nil
+	case names > 1 && len(spec.Values) == 1 && tok == token.VAR:
+		t, err := InferType(spec.Values[0], env, ctx.Child(WithAssignment()))
+		if err != nil {
+			return nil, err
+		}
+		if names == 2 && isCommaOk(spec.Values[0], env) {
+			t = &TupleType{Types: []Type{t, Bool}}
+		}
+		tuple, ok := t.(*TupleType)
+		if !ok || len(tuple.Types) != names {
+			return nil, fmt.Errorf("assignment mismatch: %d variables but %s", names, valueCount(t))
+		}
+		for i, t := range tuple.Types {
+			if declared != nil {
+				if err := assignable(declared, t, env, ctx); err != nil {
+					return nil, fmt.Errorf("cannot use %s value as %s value: %w", FormatGo(t), FormatGo(declared), err)
+				}
+				t = declared
+			}
+			result[i] = t
+		}
+		return result, nil
+	case names != len(spec.Values):
+		return nil, fmt.Errorf("assignment mismatch: %d variables but %d values", names, len(spec.Values))
+	}
+
+	for i, value := range spec.Values {
+		t, err := initializerType(value, declared, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = t
+	}
+	return result, nil
+}
+
+// initializerType infers the type of the initializer value of a declaration, checking
+// that it is assignable to the declared type, if not nil.
+func initializerType(value ast.Expr, declared Type, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if err := checkValueExpr(value, env); err != nil {
+		return nil, err
+	}
+	t, err := InferType(value, env, ctx.Child(WithAssignment(), WithExpectedType(declared)))
+	if err != nil {
+		return nil, err
+	}
+	if isNoValue(t) {
+		return nil, fmt.Errorf("%s: %w", types.ExprString(value), ErrNoValueUsed)
+	}
+	if tuple, ok := t.(*TupleType); ok {
+		return nil, fmt.Errorf("multiple-value %s (%d values) in single-value context", types.ExprString(value), len(tuple.Types))
+	}
+	if declared == nil {
+		if isNil(t) {
+			return nil, fmt.Errorf("use of untyped nil in variable declaration")
+		}
+		return t, nil
+	}
+
+	if val := constantOf(value, env); val.Kind() != constant.Unknown {
+		// untyped constants only need to be representable by the declared type
+		return convertUntyped(&operand{expr: value, typ: t, val: val}, declared, env)
+	}
+	if err := assignable(declared, t, env, ctx); err != nil {
+		return nil, mismatchError(value, declared, t, fmt.Errorf("cannot use %s (value of type %s) as %s value: %w", types.ExprString(value), FormatGo(t), FormatGo(declared), err))
+	}
+	return declared, nil
+}
+
+// replaceIota returns a copy of the constant expression expr in which `iota` is replaced
+// by the integer literal index, so that it is evaluated like any other constant.
+func replaceIota(expr ast.Expr, index int) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if e.Name == "iota" {
+			return &ast.BasicLit{ValuePos: e.Pos(), Kind: token.INT, Value: strconv.Itoa(index)}
+		}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{Lparen: e.Lparen, X: replaceIota(e.X, index), Rparen: e.Rparen}
+	case *ast.UnaryExpr:
+		return &ast.UnaryExpr{OpPos: e.OpPos, Op: e.Op, X: replaceIota(e.X, index)}
+	case *ast.BinaryExpr:
+		return &ast.BinaryExpr{X: replaceIota(e.X, index), OpPos: e.OpPos, Op: e.Op, Y: replaceIota(e.Y, index)}
+	case *ast.CallExpr:
+		// conversions, like `Weekday(iota)`
+		call := *e
+		call.Args = make([]ast.Expr, len(e.Args))
+		for i, arg := range e.Args {
+			call.Args[i] = replaceIota(arg, index)
+		}
+		return &call
+	}
+	return expr
+}
+
+// valueCount describes the number of values of type t, for assignment mismatches.
+func valueCount(t Type) string {
+	if tuple, ok := t.(*TupleType); ok {
+		return fmt.Sprintf("%d values", len(tuple.Types))
+	}
+	return "1 value"
+}
@@ -0,0 +1,203 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestInferDeclStmt(t *testing.T) {
+	pair := &FunctionType{ReturnType: &TupleType{Types: []Type{Int, String}}}
+	dir := &NamedType{Name: "Dir", Underlying: Int}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    map[string]Type
+		wantErr string
+	}{
+		{name: "typed var", src: "var x int = 5", want: map[string]Type{"x": Int}},
+		{name: "untyped const", src: `const s = "hi"`, want: map[string]Type{"s": String}},
+		{name: "multiple names", src: "var a, b = 1, 2.5", want: map[string]Type{"a": Int, "b": Float64}},
+		{name: "zero value", src: "var a, b string", want: map[string]Type{"a": String, "b": String}},
+		{name: "untyped constant converted", src: "var f float64 = 1", want: map[string]Type{"f": Float64}},
+		{name: "constant expression", src: "const k int64 = 1 << 10", want: map[string]Type{"k": Int64}},
+		{name: "multiple results", src: "var n, s = pair()", want: map[string]Type{"n": Int, "s": String}},
+		{name: "blank", src: "var _, s = pair()", want: map[string]Type{"s": String}},
+		{name: "value of a variable", src: "var y = i", want: map[string]Type{"y": Int}},
+		{
+			name: "iota repeated",
+			src:  "const (\n\tNorth Dir = iota\n\tEast\n\tSouth\n)",
+			want: map[string]Type{"North": dir, "East": dir, "South": dir},
+		},
+		{name: "local type", src: "type point struct{ x, y int }", want: map[string]Type{"point": &StructType{Name: "point", Fields: map[string]Type{"x": Int, "y": Int}}}},
+
+		{name: "mismatched type", src: `var x int = "a"`, wantErr: `declaration of x: cannot convert "a" (untyped string constant) to type int`},
+		{name: "not representable", src: "var x int = 1.5", wantErr: "declaration of x: cannot convert 1.5 (untyped float constant) to type int"},
+		{name: "mismatched variable", src: "var x string = i", wantErr: "declaration of x: cannot use i (value of type int) as string value: type mismatch"},
+		{name: "too few values", src: "var a, b = 1", wantErr: "declaration of a, b: assignment mismatch: 2 variables but 1 value"},
+		{name: "too many values", src: "var a = 1, 2", wantErr: "declaration of a: assignment mismatch: 1 variables but 2 values"},
+		{name: "too few results", src: "var a, b, c = pair()", wantErr: "declaration of a, b, c: assignment mismatch: 3 variables but 2 values"},
+		{name: "multiple-value in single-value context", src: "var a = pair()", wantErr: "declaration of a: multiple-value pair() (2 values) in single-value context"},
+		{name: "missing constant value", src: "const a int", wantErr: "declaration of a: missing init expr for const declaration"},
+		{name: "unknown type", src: "var a missing", wantErr: "declaration of a: unknown identifier: missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := TypeEnv{"int": Int, "int64": Int64, "float64": Float64, "string": String, "Dir": dir, "i": Int, "pair": pair}
+			got, err := InferType(parseStmt(t, tt.src), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if got != nil {
+				t.Errorf("InferType() = %v, want no type", got)
+			}
+			for name, want := range tt.want {
+				if !TypesEqual(unwrapObject(env[name]), want) {
+					t.Errorf("env[%s] = %v, want %v", name, env[name], want)
+				}
+			}
+			if _, ok := env["_"]; ok {
+				t.Error("env[_] is bound")
+			}
+		})
+	}
+}
+
+func TestInferDeclFailedBindings(t *testing.T) {
+	tests := []struct {
+		src  string
+		want map[string]Type
+	}{
+		{src: `var x int = "a"`, want: map[string]Type{"x": Int}},
+		{src: "var a, b = missing", want: map[string]Type{"a": invalidType, "b": invalidType}},
+		{src: "const c int64 = 1.5", want: map[string]Type{"c": Int64}},
+		{src: "var d missing = 1", want: map[string]Type{"d": invalidType}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			env := TypeEnv{"int": Int, "int64": Int64}
+			if _, err := InferType(parseStmt(t, tt.src), env, nil); err == nil {
+				t.Fatal("InferType() error = nil")
+			}
+			for name, want := range tt.want {
+				if got := unwrapObject(env[name]); got != want && !TypesEqual(got, want) {
+					t.Errorf("env[%s] = %v, want %v", name, env[name], want)
+				}
+			}
+		})
+	}
+
+	// the uses of the names report no errors of their own
+	src := `package p
+
+var b = a + 1
+
+var a = missing
+
+var s string = 1
+
+func f() int {
+	var n int = "n"
+	m := n + 1
+	var v = undefined
+	return v + m + len(s)
+}
+`
+	var got []string
+	for _, d := range CheckSource(src, nil).Diagnostics {
+		got = append(got, d.Message)
+	}
+	want := []string{
+		"declaration of a: unknown identifier: missing",
+		"declaration of s: cannot convert 1 (untyped int constant) to type string",
+		`declaration of n: cannot convert "n" (untyped string constant) to type int`,
+		"declaration of v: unknown identifier: undefined",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckSource() diagnostics = %q, want %q", got, want)
+	}
+}
+
+func TestInferDeclAssignable(t *testing.T) {
+	const src = `package p
+
+type Shape interface{ Area() float64 }
+
+type Sq struct{ n float64 }
+
+func (s *Sq) Area() float64 { return s.n * s.n }
+
+type Circle struct{ r float64 }
+
+type Ints []int
+
+type Namer interface{ Name() string }
+
+type Label string
+
+func (l Label) Name() string { return string(l) }
+
+var (
+	sq Sq
+	ns Ints
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		src     string
+		wantErr string // a substring of the error
+	}{
+		{src: "var _ Shape = &Sq{}"},
+		{src: "var _ Shape = (*Sq)(nil)"},
+		{src: "var _ []int = ns"},
+		{src: "var _ interface{} = sq"},
+		{src: "var xs Ints = []int{1, 2}"},
+		{src: "var _ Shape = sq", wantErr: "Sq does not implement Shape (method Area has pointer receiver)"},
+		{src: "var _ Shape = Circle{}", wantErr: "Circle does not implement Shape (missing method Area)"},
+		{src: "var _ []string = ns", wantErr: "type mismatch"},
+		{src: "var _ <-chan int = make(chan int)"},
+		{src: "var _ []<-chan int = []chan int{}", wantErr: "type mismatch"},
+		{src: "var _ map[string]chan<- int = map[string]chan int{}", wantErr: "type mismatch"},
+		{src: "var _ any = 1"},
+		{src: "var _ interface{} = 'a'"},
+		{src: `var _ Namer = Label("a")`},
+		{src: "var _ error = 3", wantErr: "cannot use 3 (untyped int constant) as error value: type mismatch: int does not implement error (missing method Error)"},
+		{src: `var _ Namer = "a"`, wantErr: `cannot use "a" (untyped string constant) as Namer value: type mismatch: string does not implement Namer (missing method Name)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			scope := TypeEnv{}
+			for name, typ := range env {
+				scope[name] = typ
+			}
+			_, err := InferType(parseStmt(t, tt.src), scope, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("InferType() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// largePackage generates a package of n files, each declaring functions that instantiate
+// generic types with several type arguments, call each other, and refer to undefined names.
+func largePackage(t *testing.T, fset *token.FileSet, n int) []*ast.File {
+	t.Helper()
+	args := []string{"int", "string", "bool", "float64"}
+	var files []*ast.File
+	for i := 0; i < n; i++ {
+		var src strings.Builder
+		fmt.Fprintf(&src, "package p\n\nvar v%d = Pair[%s, %s]{}\n", i, args[i%4], args[(i+1)%4])
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&src, "\nfunc f%d_%d() {\n", i, j)
+			fmt.Fprintf(&src, "\t_ = Box[%s]{}\n", args[(i+j)%4])
+			fmt.Fprintf(&src, "\t_ = Pair[%s, Box[%s]]{}\n", args[j%4], args[i%4])
+			if j > 0 {
+				fmt.Fprintf(&src, "\tf%d_%d()\n", i, j-1)
+			}
+			if (i+j)%3 == 0 {
+				fmt.Fprintf(&src, "\t_ = Box[undefined%d_%d]{}\n", i, j)
+			}
+			if (i+j)%5 == 0 {
+				fmt.Fprintf(&src, "\t_ = Pair[int]{}\n")
+			}
+			src.WriteString("}\n")
+		}
+		file, err := parser.ParseFile(fset, fmt.Sprintf("p%d.go", i), src.String(), 0)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// report renders everything InferPackage found, with the uses and instances in source order.
+func report(fset *token.FileSet, info *Info, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "errors:\n%v\n", err)
+	fmt.Fprintf(&b, "warnings:\n%v\n", errors.Join(info.Warnings...))
+
+	var lines []string
+	for ident, t := range info.Uses {
+		lines = append(lines, fmt.Sprintf("%s use %s: %s", fset.Position(ident.Pos()), ident.Name, FormatGo(t)))
+	}
+	for expr, instance := range info.Instances {
+		lines = append(lines, fmt.Sprintf("%s instance: %s", fset.Position(expr.Pos()), FormatGo(instance)))
+	}
+	sort.Strings(lines)
+	b.WriteString(strings.Join(lines, "\n"))
+
+	b.WriteString("\ncalls:\n")
+	for _, edge := range info.CallGraph.Edges {
+		fmt.Fprintf(&b, "%s -> %s %s\n", edge.Caller, edge.Callee, typeListString(edge.TypeArgs))
+	}
+	return b.String()
+}
+
+func TestInferPackageDeterministic(t *testing.T) {
+	newEnv := func() TypeEnv {
+		return TypeEnv{
+			"int":     Int,
+			"string":  String,
+			"bool":    Bool,
+			"float64": Float64,
+			"Pair": NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}}, map[string]Type{
+				"key":   &TypeVariable{Name: "K"},
+				"value": &TypeVariable{Name: "V"},
+				"next":  &PointerType{Base: &TypeVariable{Name: "V"}},
+			}, nil),
+			"Box": NewGenericType("Box", TypeParamList{{Name: "T", Constraint: Any}}, map[string]Type{
+				"value": &TypeVariable{Name: "T"},
+				"items": &SliceType{ElementType: &TypeVariable{Name: "T"}},
+			}, nil),
+		}
+	}
+
+	fset := token.NewFileSet()
+	files := largePackage(t, fset, 40)
+
+	info, err := InferPackage(files, newEnv())
+	if err == nil {
+		t.Fatal("InferPackage() error = nil, want the undefined names reported")
+	}
+	want := report(fset, info, err)
+
+	for run := 0; run < 3; run++ {
+		info, err := InferPackage(files, newEnv())
+		if got := report(fset, info, err); got != want {
+			t.Fatalf("run %d: InferPackage() report differs:\n%s\nwant:\n%s", run, got, want)
+		}
+		info, err = InferPackageWithOptions(files, newEnv(), Options{Parallel: true})
+		if got := report(fset, info, err); got != want {
+			t.Fatalf("run %d: parallel InferPackage() report differs:\n%s\nwant:\n%s", run, got, want)
+		}
+	}
+}
+
+func TestInferPackageParallelMaxErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	files := largePackage(t, fset, 8)
+	env := TypeEnv{
+		"int": Int, "string": String, "bool": Bool, "float64": Float64,
+		"Pair": NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}}, map[string]Type{"key": &TypeVariable{Name: "K"}}, nil),
+		"Box":  NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"value": &TypeVariable{Name: "T"}}, nil),
+	}
+
+	_, want := InferPackageWithOptions(files, env, Options{MaxErrors: 5})
+	_, got := InferPackageWithOptions(files, env, Options{MaxErrors: 5, Parallel: true})
+	if !errors.Is(got, ErrTooManyErrors) {
+		t.Fatalf("InferPackageWithOptions() error = %v, want %v", got, ErrTooManyErrors)
+	}
+	if got.Error() != want.Error() {
+		t.Errorf("parallel InferPackageWithOptions() error =\n%v\nwant\n%v", got, want)
+	}
+}
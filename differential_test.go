@@ -0,0 +1,148 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDifferential checks the conformance corpus with both this package and go/types, and
+// compares the type of every expression of its variable declarations: the type inferred by
+// this package, converted with ToGoType, must be identical to the one go/types records.
+//
+// As go/types records them, a generic function called without its type arguments has the
+// type of the instance the call infers, and an untyped constant the type of its context,
+// which it must be representable by. A generic function instantiated explicitly, like Sum
+// in `Sum[int]`, is compared through its instance: the constraints of its type parameters
+// are named in go/types, like Number, but not in the type of the declaration here.
+//
+// The declarations go/types rejects, like the cases of the corpus expecting an error, are
+// left out, since their types are recorded partially. The expressions this package cannot
+// infer, like the instances of generic slice types, are only logged: the conformance
+// scoreboard tracks them, and this test the types inferred differently.
+func TestDifferential(t *testing.T) {
+	fsys := os.DirFS(filepath.Join("testdata", "conformance"))
+	names, err := fs.Glob(fsys, "*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total, unsupported int
+	for _, name := range names {
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatalf("ParseFile(%s) error = %v", name, err)
+		}
+		var rejected []token.Pos
+		info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		conf := &types.Config{Error: func(err error) { rejected = append(rejected, err.(types.Error).Pos) }}
+		pkg, _ := conf.Check(file.Name.Name, fset, []*ast.File{file}, info)
+		// the declarations that cannot be inferred are those go/types rejects, left out below
+		env, _ := BuildEnv([]*ast.File{file})
+
+		for _, expr := range varExprs(file, rejected) {
+			tv := info.Types[expr]
+			if tv.Type == nil || tv.IsBuiltin() || instantiated(expr, file) {
+				continue
+			}
+			total++
+			pos := fset.Position(expr.Pos())
+			if tv.Value != nil {
+				if val := constantOf(expr, env); !representable(val, FromGoType(tv.Type), env) {
+					t.Errorf("%s: %s: %s is not representable by %s", pos, types.ExprString(expr), val, tv.Type)
+				}
+				continue
+			}
+			ours, err := inferExpr(expr, file, env)
+			if err != nil {
+				unsupported++
+				t.Logf("%s: %s: %v, go/types %s", pos, types.ExprString(expr), err, tv.Type)
+				continue
+			}
+			got, err := ToGoType(ours, pkg)
+			if err != nil {
+				t.Errorf("%s: %s: ToGoType() error = %v, go/types %s", pos, types.ExprString(expr), err, tv.Type)
+				continue
+			}
+			if !types.Identical(got, tv.Type) {
+				t.Errorf("%s: %s: %s, go/types %s", pos, types.ExprString(expr), got, tv.Type)
+			}
+		}
+	}
+	t.Logf("%d expressions compared with go/types, %d not inferred", total, unsupported)
+}
+
+// varExprs returns the expressions of the package-level variable declarations of file,
+// in source order. The specs holding one of the rejected positions are left out.
+func varExprs(file *ast.File, rejected []token.Pos) []ast.Expr {
+	var exprs []ast.Expr
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		ast.Inspect(gen, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				for _, pos := range rejected {
+					if n.Pos() <= pos && pos < n.End() {
+						return false
+					}
+				}
+			case ast.Expr:
+				exprs = append(exprs, n)
+			}
+			return true
+		})
+	}
+	return exprs
+}
+
+// instantiated reports whether expr is the generic function of an instantiation in file,
+// like Sum in `Sum[int]`.
+func instantiated(expr ast.Expr, file *ast.File) bool {
+	var found bool
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			found = found || n.X == expr
+		case *ast.IndexListExpr:
+			found = found || n.X == expr
+		}
+		return !found
+	})
+	return found
+}
+
+// inferExpr infers the type of expr, in file. A generic function called without all of its
+// type arguments, like `Map` in `Map(xs, f)`, is the instance the call infers.
+func inferExpr(expr ast.Expr, file *ast.File, env TypeEnv) (Type, error) {
+	t, err := InferType(expr, env, nil)
+	if err != nil {
+		return nil, err
+	}
+	gt, ok := t.(*GenericType)
+	if !ok || gt.Signature == nil || !needsTypeArgs(gt) {
+		return t, nil
+	}
+	var call *ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok && c.Fun == expr {
+			call = c
+		}
+		return call == nil
+	})
+	if call == nil {
+		return t, nil
+	}
+	return inferTypeArgs(gt, call, env, NewInferenceContext())
+}
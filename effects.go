@@ -0,0 +1,151 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+var ErrEffectNotAllowed = errors.New("effect not allowed")
+
+// Effects is the set of side effects a function may have when it is called.
+// The zero value means the function is not annotated, so its effects are unknown.
+//
+// Effect tracking is experimental and opt-in: it only restricts functions whose
+// types are annotated, and is otherwise invisible to inference.
+type Effects uint8
+
+const (
+	// EffectPure marks an annotated function. On its own, the function has no effects.
+	EffectPure Effects = 1 << iota
+	// EffectIO marks a function that performs input or output.
+	EffectIO
+	// EffectPanics marks a function that may panic.
+	EffectPanics
+)
+
+// Known reports whether the effects are annotated.
+func (e Effects) Known() bool {
+	return e != 0
+}
+
+// Allows reports whether a function with effects e may call, or be replaced by,
+// a function with the effects other. Unannotated functions allow anything,
+// while annotated ones only allow annotated functions with a subset of their effects.
+func (e Effects) Allows(other Effects) bool {
+	if !e.Known() {
+		return true
+	}
+	if !other.Known() {
+		return false
+	}
+	return other&^EffectPure&^e == 0
+}
+
+// union returns the effects of doing both e and other, which are unknown if either is.
+func (e Effects) union(other Effects) Effects {
+	if !e.Known() || !other.Known() {
+		return 0
+	}
+	return e | other | EffectPure
+}
+
+func (e Effects) String() string {
+	if !e.Known() {
+		return "unknown"
+	}
+	var names []string
+	if e&EffectIO != 0 {
+		names = append(names, "io")
+	}
+	if e&EffectPanics != 0 {
+		names = append(names, "panics")
+	}
+	if len(names) == 0 {
+		return "pure"
+	}
+	return strings.Join(names, ", ")
+}
+
+// InferEffects returns the effects of evaluating node: the union of the effects of
+// every function it calls. Calls to unannotated functions make the effects unknown.
+// Function literals are not entered, since their body runs when they are called.
+//
+// The calls are found by a walk of node rather than during inference, which enters
+// function literals and stops at the first type error, while the effects of the callees
+// are those of their inferred types, so the two never disagree.
+func InferEffects(node ast.Node, env TypeEnv) (Effects, error) {
+	effects := EffectPure
+	err := inspectCalls(node, func(call *ast.CallExpr) error {
+		callee, err := callEffects(call, env)
+		effects = effects.union(callee)
+		return err
+	})
+	return effects, err
+}
+
+// CheckEffects reports the first call in node whose effects are not allowed,
+// like a call to a function doing IO in a function annotated as pure. It is reported as
+// a TypeError located at the call.
+func CheckEffects(node ast.Node, allowed Effects, env TypeEnv) error {
+	return inspectCalls(node, func(call *ast.CallExpr) error {
+		callee, err := callEffects(call, env)
+		if err != nil {
+			return err
+		}
+		if !allowed.Allows(callee) {
+			return typeError(call, nil, fmt.Errorf("%w: call to %s has %v effects, only %v allowed",
+				ErrEffectNotAllowed, types.ExprString(call.Fun), callee, allowed))
+		}
+		return nil
+	})
+}
+
+// inspectCalls calls fn for every call expression in node outside of function literals,
+// stopping at the first error.
+func inspectCalls(node ast.Node, fn func(*ast.CallExpr) error) error {
+	var err error
+	ast.Inspect(node, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			err = fn(n)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// callEffects returns the effects of the function called by call, not including its arguments.
+// The builtin functions, like len and append, are pure, except panic.
+func callEffects(call *ast.CallExpr, env TypeEnv) (Effects, error) {
+	if _, ok := conversionType(call.Fun, env); ok {
+		return EffectPure, nil
+	}
+	if name, ok := builtinName(call, env); ok {
+		if name == "panic" {
+			return EffectPure | EffectPanics, nil
+		}
+		return EffectPure, nil
+	}
+
+	fnType, err := InferType(call.Fun, env, nil)
+	if err != nil {
+		return 0, err
+	}
+	switch fn := fnType.(type) {
+	case *FunctionType:
+		return fn.Effects, nil
+	case *GenericType:
+		if fn.Signature != nil {
+			return fn.Signature.Effects, nil
+		}
+	}
+	return 0, nil
+}
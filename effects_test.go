@@ -0,0 +1,155 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"strings"
+	"testing"
+)
+
+func TestEffectsAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		e       Effects
+		other   Effects
+		allowed bool
+	}{
+		{name: "unannotated allows unknown", e: 0, other: 0, allowed: true},
+		{name: "unannotated allows io", e: 0, other: EffectPure | EffectIO, allowed: true},
+		{name: "pure allows pure", e: EffectPure, other: EffectPure, allowed: true},
+		{name: "pure rejects io", e: EffectPure, other: EffectPure | EffectIO, allowed: false},
+		{name: "pure rejects unknown", e: EffectPure, other: 0, allowed: false},
+		{name: "io allows pure", e: EffectPure | EffectIO, other: EffectPure, allowed: true},
+		{name: "io rejects panics", e: EffectPure | EffectIO, other: EffectPure | EffectIO | EffectPanics, allowed: false},
+		{name: "all allow subset", e: EffectPure | EffectIO | EffectPanics, other: EffectPure | EffectPanics, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.Allows(tt.other); got != tt.allowed {
+				t.Errorf("%v.Allows(%v) = %v, want %v", tt.e, tt.other, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestUnifyFunctionEffects(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	pureFn := &FunctionType{ParamTypes: []Type{intType}, ReturnType: intType, Effects: EffectPure}
+	ioFn := &FunctionType{ParamTypes: []Type{intType}, ReturnType: intType, Effects: EffectPure | EffectIO}
+	plainFn := &FunctionType{ParamTypes: []Type{intType}, ReturnType: intType}
+
+	if _, err := Unify(ioFn, pureFn, make(TypeEnv)); err != nil {
+		t.Errorf("Unify(io, pure) error = %v", err)
+	}
+	if _, err := Unify(plainFn, ioFn, make(TypeEnv)); err != nil {
+		t.Errorf("Unify(unannotated, io) error = %v", err)
+	}
+	if _, err := Unify(pureFn, ioFn, make(TypeEnv)); !errors.Is(err, ErrEffectNotAllowed) {
+		t.Errorf("Unify(pure, io) error = %v, want %v", err, ErrEffectNotAllowed)
+	}
+	if pureFn.String() != "func(TypeConst(int)) TypeConst(int) effects(pure)" {
+		t.Errorf("String() = %q", pureFn.String())
+	}
+
+	// passing a function that does IO where a pure one is expected
+	env := TypeEnv{
+		"apply": &FunctionType{ParamTypes: []Type{pureFn, intType}, ReturnType: intType},
+		"log":   ioFn,
+		"inc":   pureFn,
+	}
+	for src, wantErr := range map[string]bool{"apply(inc, 1)": false, "apply(log, 1)": true} {
+		expr, err := parser.ParseExpr(src)
+		if err != nil {
+			t.Fatalf("ParseExpr() error = %v", err)
+		}
+		_, err = InferType(expr, env, nil)
+		if (err != nil) != wantErr {
+			t.Errorf("InferType(%s) error = %v, wantErr %v", src, err, wantErr)
+		}
+	}
+}
+
+func TestInferEffects(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	tv := &TypeVariable{Name: "T"}
+	identity := NewGenericFunction("Identity", TypeParamList{{Name: "T"}}, &FunctionType{
+		ParamTypes: []Type{tv}, ReturnType: tv, Effects: EffectPure,
+	})
+	env := TypeEnv{
+		"n":        intType,
+		"xs":       &SliceType{ElementType: intType},
+		"int":      intType,
+		"inc":      &FunctionType{ParamTypes: []Type{intType}, ReturnType: intType, Effects: EffectPure},
+		"read":     &FunctionType{ReturnType: intType, Effects: EffectPure | EffectIO},
+		"must":     &FunctionType{ParamTypes: []Type{intType}, ReturnType: intType, Effects: EffectPure | EffectPanics},
+		"plain":    &FunctionType{ReturnType: intType},
+		"Identity": identity,
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    Effects
+		allowed Effects
+		wantErr string
+	}{
+		{name: "no calls", src: "n + 1", want: EffectPure, allowed: EffectPure},
+		{name: "pure call", src: "inc(n)", want: EffectPure, allowed: EffectPure},
+		{name: "conversion", src: "int(n)", want: EffectPure, allowed: EffectPure},
+		{name: "nested calls", src: "inc(read())", want: EffectPure | EffectIO, allowed: EffectPure | EffectIO},
+		{name: "union", src: "must(read())", want: EffectPure | EffectIO | EffectPanics, allowed: EffectPure | EffectIO | EffectPanics},
+		{name: "builtin panic", src: "panic(n)", want: EffectPure | EffectPanics, allowed: EffectPure | EffectPanics},
+		{name: "builtins", src: "inc(len(append(xs, n)) + copy(xs, xs))", want: EffectPure, allowed: EffectPure},
+		{name: "builtin with effects of arguments", src: "len(append(xs, read()))", want: EffectPure | EffectIO, allowed: EffectPure | EffectIO},
+		{name: "instantiated generic function", src: "Identity[int](n)", want: EffectPure, allowed: EffectPure},
+		{name: "function literals are not called", src: "func() int { return read() }", want: EffectPure, allowed: EffectPure},
+		{
+			name:    "unannotated call",
+			src:     "inc(plain())",
+			want:    0,
+			allowed: EffectPure,
+			wantErr: "call to plain has unknown effects, only pure allowed",
+		},
+		{
+			name:    "io in pure context",
+			src:     "inc(read())",
+			want:    EffectPure | EffectIO,
+			allowed: EffectPure | EffectPanics,
+			wantErr: "call to read has io effects, only panics allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferEffects(expr, env)
+			if err != nil {
+				t.Fatalf("InferEffects() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("InferEffects() = %v, want %v", got, tt.want)
+			}
+
+			err = CheckEffects(expr, tt.allowed, env)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckEffects() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrEffectNotAllowed) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckEffects() error = %v, want %q", err, tt.wantErr)
+			}
+			// the call of the function, nested in the argument
+			var te *TypeError
+			if !errors.As(err, &te) || te.Pos != expr.(*ast.CallExpr).Args[0].Pos() {
+				t.Errorf("CheckEffects() error = %#v, want a TypeError at the inner call", err)
+			}
+		})
+	}
+}
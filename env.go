@@ -0,0 +1,661 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// BuildEnv builds the environment of the package-level declarations of files: the type
+// declarations, including generic types and constraint interfaces like
+// `type Number interface{ ~int | ~float64 }`, the functions, the methods, which are
+// added to the method set of their receiver type, and the variables and constants, bound to
+// their VarObj and ConstObj. The predeclared types, `error` and `any` are in the environment
+// too, so that it can be passed to InferPackage as is.
+//
+// Declarations that cannot be inferred are left out; their errors, TypeErrors located at
+// the declaration, are returned joined, along with the environment of the others. Imports are ignored, see BuildEnvWithImporter.
+func BuildEnv(files []*ast.File) (TypeEnv, error) {
+	return BuildEnvWithImporter(files, nil)
+}
+
+// BuildEnvWithImporter is like BuildEnv, but also binds the packages imported by files,
+// as returned by importer, so that qualified identifiers like `m.Sqrt` after
+// `import m "math"` resolve, as well as the members of dot imports. A nil importer
+// ignores imports.
+func BuildEnvWithImporter(files []*ast.File, importer Importer) (TypeEnv, error) {
+	env := universe()
+	var errs []error
+	if importer != nil {
+		imported, importErrs := declareImports(files, importer, env)
+		errs = append(errs, importErrs...)
+		errs = append(errs, checkImportConflicts(files, imported)...)
+	}
+	errs = append(errs, declareFiles(files, env, nil)...)
+	return env, errors.Join(errs...)
+}
+
+// declareFiles binds the package-level declarations of files in env, like BuildEnv,
+// and returns the errors of those left out, located in fset if it is not nil.
+func declareFiles(files []*ast.File, env TypeEnv, fset *token.FileSet) []error {
+	ctx := NewInferenceContext(WithFileSet(fset))
+	var errs []error
+	// the errors are located at the declaration, unless they hold a TypeError of a nested node
+	declare := func(decl ast.Node, name string, err error) {
+		if err != nil {
+			errs = append(errs, typeError(decl, ctx, fmt.Errorf("%s: %w", name, err)))
+		}
+	}
+
+	var specs []*ast.TypeSpec
+	var values []*ast.GenDecl
+	var funcs []*ast.FuncDecl
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				switch decl.Tok {
+				case token.TYPE:
+					for _, spec := range decl.Specs {
+						specs = append(specs, spec.(*ast.TypeSpec))
+					}
+				case token.VAR, token.CONST:
+					values = append(values, decl)
+				}
+			case *ast.FuncDecl:
+				funcs = append(funcs, decl)
+			}
+		}
+	}
+
+	// constants that do not depend on the declarations below first, since array lengths
+	// like `[N]int` may use them. The others are declared with the variables.
+	var consts, vars []*ast.GenDecl
+	for _, decl := range values {
+		if decl.Tok == token.CONST {
+			consts = append(consts, decl)
+		} else {
+			vars = append(vars, decl)
+		}
+	}
+	failed, _ := declareValues(consts, env, ctx)
+	values = append(failed, vars...)
+
+	// constraints first, since the type parameters of the other declarations refer to them,
+	// then the types, so that fields, signatures and methods can refer to any of them.
+	var declared []*ast.TypeSpec
+	for _, spec := range specs {
+		if iface, ok := spec.Type.(*ast.InterfaceType); ok && hasTypeElements(iface) {
+			// a constraint with an empty type set is still bound, so that the declarations
+			// using it do not report it again
+			t, err := declareConstraint(spec, env)
+			declare(spec, spec.Name.Name, err)
+			if t != nil {
+				env[spec.Name.Name] = t
+			}
+			continue
+		}
+		t, err := declareType(spec, env)
+		declare(spec, spec.Name.Name, err)
+		if err == nil {
+			env[spec.Name.Name] = t
+			declared = append(declared, spec)
+		}
+	}
+	for _, spec := range declared {
+		declare(spec, spec.Name.Name, defineType(spec, env[spec.Name.Name], env))
+	}
+
+	for _, fn := range funcs {
+		if fn.Recv != nil {
+			declare(fn, receiverName(fn)+"."+fn.Name.Name, declareMethod(fn, env))
+			continue
+		}
+		if fn.Name.Name == "_" || fn.Name.Name == "init" {
+			continue
+		}
+		t, err := declareFunc(fn, env)
+		declare(fn, fn.Name.Name, err)
+		if err == nil {
+			env[fn.Name.Name] = t
+		}
+	}
+
+	// variables and constants last, since their initializers may call the functions.
+	_, failures := declareValues(values, env, ctx)
+	errs = append(errs, failures...)
+	return errs
+}
+
+// declareValues binds the variables and constants of decls in env. They may refer to each
+// other in any order, so the declarations that fail are retried as long as others succeed,
+// or bind names for the first time, see declareInvalid; those that still fail are returned
+// along with their errors, but those following from the others, see followsInvalid.
+func declareValues(decls []*ast.GenDecl, env TypeEnv, ctx *InferenceContext) ([]*ast.GenDecl, []error) {
+	for len(decls) > 0 {
+		bound := len(env)
+		var failed []*ast.GenDecl
+		var failures []error
+		for _, decl := range decls {
+			if err := inferGenDecl(decl, env, ctx); err != nil {
+				failed = append(failed, decl)
+				if !followsInvalid(err) {
+					failures = append(failures, err)
+				}
+			}
+		}
+		if len(failed) == len(decls) && len(env) == bound {
+			return failed, failures
+		}
+		decls = failed
+	}
+	return nil, nil
+}
+
+// BuildTestEnv builds the environments of a package with its tests, as parsed by
+// ParseTestPackageDir: env, of the files of the package along with its _test.go files,
+// and xenv, of the external test package xtests, which imports the package under test as
+// path, like `import "example.com/foo"` in `package foo_test`. The other imports of
+// xtests, and those of files, are resolved by importer, or ignored if it is nil.
+//
+// The errors of both environments are returned joined.
+func BuildTestEnv(files, xtests []*ast.File, path string, importer Importer) (env, xenv TypeEnv, err error) {
+	env, err = BuildEnvWithImporter(files, importer)
+	if len(xtests) == 0 {
+		return env, nil, err
+	}
+
+	pkg := NewPackageType(path, exportedMembers(env))
+	if len(files) > 0 {
+		pkg.Name = files[0].Name.Name
+	}
+	xenv, xerr := BuildEnvWithImporter(xtests, func(p string) (*PackageType, error) {
+		if p == path {
+			return pkg, nil
+		}
+		if importer == nil {
+			return nil, errSkipImport
+		}
+		return importer(p)
+	})
+	return env, xenv, errors.Join(err, xerr)
+}
+
+// exportedMembers returns the exported names of env, which other packages can refer to.
+func exportedMembers(env TypeEnv) TypeEnv {
+	members := make(TypeEnv)
+	for name, t := range env {
+		if token.IsExported(name) {
+			members[name] = t
+		}
+	}
+	return members
+}
+
+// universe returns a new environment holding the predeclared types, including `byte`
+// and `rune`, `error`, `any`,
+// and the constants `true` and `false`.
+func universe() TypeEnv {
+	env := make(TypeEnv, len(predeclaredTypes)+6)
+	for _, t := range predeclaredTypes {
+		env[t.(*TypeConstant).Name] = t
+	}
+	env[TypeByte], env[TypeRune] = Byte, Rune
+	env[Error.Name] = Error
+	for _, name := range []string{"true", "false"} {
+		env[name] = &ConstObj{Name: name, Type: Bool, Val: constant.MakeBool(name == "true"), Untyped: true}
+	}
+	env[ConstraintAny] = &InterfaceType{Name: ConstraintAny, IsEmpty: true}
+	env["nil"] = Nil
+	return env
+}
+
+// declareType creates the type declared by spec, without its fields or underlying type,
+// which may refer to types declared later. See defineType.
+func declareType(spec *ast.TypeSpec, env TypeEnv) (Type, error) {
+	name := spec.Name.Name
+	if spec.TypeParams != nil {
+		// the interfaces with type elements are constraints, see declareConstraint
+		_, isInterface := spec.Type.(*ast.InterfaceType)
+		params, err := typeParamList(spec.TypeParams, env)
+		if err != nil {
+			return nil, err
+		}
+		gt := NewGenericType(name, params, map[string]Type{}, MethodSet{})
+		gt.IsInterface = isInterface
+		gt.Pos = spec.Pos()
+		return gt, nil
+	}
+	if spec.Assign.IsValid() {
+		return &TypeAlias{Name: name}, nil
+	}
+	if _, ok := spec.Type.(*ast.StructType); ok {
+		return &StructType{Name: name, Fields: map[string]Type{}, Methods: MethodSet{}}, nil
+	}
+	return &NamedType{Name: name, Methods: MethodSet{}}, nil
+}
+
+// defineType completes the type t declared by spec with its fields or underlying type.
+func defineType(spec *ast.TypeSpec, t Type, env TypeEnv) error {
+	switch t := t.(type) {
+	case *GenericType:
+		scope := typeParamScope(typeParamNames(spec.TypeParams), env)
+		if t.IsInterface {
+			iface, err := InferType(spec.Type, scope, nil)
+			if err != nil {
+				return err
+			}
+			t.Methods = iface.(*InterfaceType).Methods
+			return nil
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			// a generic type defined by another type, like `type List[T any] []T`
+			if err := checkTypeExpr(spec.Type, scope); err != nil {
+				return err
+			}
+			underlying, err := InferType(spec.Type, scope, nil)
+			if err != nil {
+				return err
+			}
+			t.UnderlyingType = underlying
+			return nil
+		}
+		fields, order, err := declaredFields(st, scope)
+		if err != nil {
+			return err
+		}
+		t.Fields, t.FieldOrder = fields, order
+	case *StructType:
+		fields, order, err := declaredFields(spec.Type.(*ast.StructType), env)
+		if err != nil {
+			return err
+		}
+		t.Fields, t.FieldOrder = fields, order
+	case *NamedType:
+		if err := checkTypeExpr(spec.Type, env); err != nil {
+			return err
+		}
+		underlying, err := InferType(spec.Type, env, nil)
+		if err != nil {
+			return err
+		}
+		if iface, ok := underlying.(*InterfaceType); ok {
+			iface.Name = t.Name
+		}
+		t.Underlying = underlying
+	case *TypeAlias:
+		if err := checkTypeExpr(spec.Type, env); err != nil {
+			return err
+		}
+		aliased, err := InferType(spec.Type, env, nil)
+		if err != nil {
+			return err
+		}
+		t.AliasedTo = aliased
+	}
+	return nil
+}
+
+// declaredFields infers the fields of a struct type, in declaration order.
+func declaredFields(st *ast.StructType, env TypeEnv) (map[string]Type, []string, error) {
+	fields := make(map[string]Type)
+	var order []string
+	for _, field := range st.Fields.List {
+		t, err := InferType(field.Type, env, nil)
+		if err == nil {
+			err = checkTypeExpr(field.Type, env)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", types.ExprString(field.Type), err)
+		}
+		if len(field.Names) == 0 {
+			// an embedded field is named after its type, unqualified, like Ident for `*ast.Ident`
+			name := embeddedName(field.Type)
+			fields[name] = t
+			order = append(order, name)
+			continue
+		}
+		for _, name := range field.Names {
+			fields[name.Name] = t
+			order = append(order, name.Name)
+		}
+	}
+	return fields, order, nil
+}
+
+// embeddedName returns the name of an embedded field of type expr, the name of its type
+// without pointer, package or type arguments.
+func embeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(e.X)
+	case *ast.IndexListExpr:
+		return embeddedName(e.X)
+	}
+	return types.ExprString(expr)
+}
+
+// declareFunc infers the type of a function declaration, which is a GenericType with
+// a Signature if the function has type parameters.
+func declareFunc(fn *ast.FuncDecl, env TypeEnv) (Type, error) {
+	if fn.Type.TypeParams == nil {
+		return InferType(fn, env, nil)
+	}
+	params, err := typeParamList(fn.Type.TypeParams, env)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := buildSignature(fn.Type, typeParamScope(typeParamNames(fn.Type.TypeParams), env), NewInferenceContext())
+	if err != nil {
+		return nil, err
+	}
+	gt := NewGenericFunction(fn.Name.Name, params, sig.functionType())
+	gt.Pos = fn.Pos()
+	return gt, nil
+}
+
+// declareMethod adds the method fn to the method set of its receiver type.
+// The type parameters of a generic receiver may be renamed, like `func (s *Stack[E]) Push(v E)`;
+// they denote the type parameters of the type declaration.
+func declareMethod(fn *ast.FuncDecl, env TypeEnv) error {
+	recv := fn.Recv.List[0].Type
+	star, isPointer := recv.(*ast.StarExpr)
+	if isPointer {
+		recv = star.X
+	}
+	base, names := receiverBase(recv)
+	if base == nil {
+		return fmt.Errorf("invalid receiver type %s", types.ExprString(recv))
+	}
+
+	scope := env
+	var methods MethodSet
+	switch t := env[base.Name].(type) {
+	case *GenericType:
+		if len(names) != len(t.TypeParams) {
+			return fmt.Errorf("receiver %s has %d type parameters, want %d", types.ExprString(recv), len(names), len(t.TypeParams))
+		}
+		scope = make(TypeEnv, len(env)+len(names))
+		for name, t := range env {
+			scope[name] = t
+		}
+		for i, name := range names {
+			scope[name.Name] = t.TypeParams[i]
+		}
+		methods = t.Methods
+	case *StructType:
+		methods = t.Methods
+	case *NamedType:
+		methods = t.Methods
+	default:
+		return fmt.Errorf("undefined receiver type %s", base.Name)
+	}
+
+	sig, err := buildSignature(fn.Type, scope, NewInferenceContext())
+	if err != nil {
+		return err
+	}
+	methods[fn.Name.Name] = Method{
+		Name:       fn.Name.Name,
+		Receiver:   env[base.Name],
+		Params:     sig.Params,
+		Results:    sig.Results,
+		IsPointer:  isPointer,
+		IsVariadic: sig.IsVariadic,
+	}
+	return nil
+}
+
+// receiverBase splits a receiver type like `Pair[K, V]` into its base type name
+// and the names of its type parameters.
+func receiverBase(recv ast.Expr) (*ast.Ident, []*ast.Ident) {
+	var indices []ast.Expr
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		recv, indices = r.X, []ast.Expr{r.Index}
+	case *ast.IndexListExpr:
+		recv, indices = r.X, r.Indices
+	}
+	base, ok := recv.(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	names := make([]*ast.Ident, len(indices))
+	for i, index := range indices {
+		if names[i], ok = index.(*ast.Ident); !ok {
+			return nil, nil
+		}
+	}
+	return base, names
+}
+
+// receiverName returns the name of the receiver type of the method fn, for diagnostics.
+func receiverName(fn *ast.FuncDecl) string {
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if base, _ := receiverBase(recv); base != nil {
+		return base.Name
+	}
+	return types.ExprString(recv)
+}
+
+// typeParamList converts a type parameter list like `[K comparable, V any]`.
+// The constraints are resolved in the scope of the type parameters, so that they can
+// refer to each other, like `[S ~[]E, E any]`. The list is rejected if it can never be
+// instantiated, see TypeParamList.Validate.
+func typeParamList(fields *ast.FieldList, env TypeEnv) (TypeParamList, error) {
+	scope := typeParamScope(typeParamNames(fields), env)
+	// the empty type set of a named constraint, like `Bad` in `[T Bad]`, is reported where
+	// it is declared, so it is left out of the list validated
+	var params, validated TypeParamList
+	for _, field := range fields.List {
+		c, err := constraintOf(field.Type, scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range field.Names {
+			params = append(params, TypeParam{Name: name.Name, Constraint: c})
+			switch ast.Unparen(field.Type).(type) {
+			case *ast.Ident, *ast.SelectorExpr:
+				validated = append(validated, TypeParam{Name: name.Name})
+			default:
+				validated = append(validated, TypeParam{Name: name.Name, Constraint: c})
+			}
+		}
+	}
+	if err := validated.Validate(); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// typeParamNames returns the names declared by a type parameter list, which may be nil.
+func typeParamNames(list *ast.FieldList) []*ast.Ident {
+	if list == nil {
+		return nil
+	}
+	var names []*ast.Ident
+	for _, field := range list.List {
+		names = append(names, field.Names...)
+	}
+	return names
+}
+
+// typeParamScope returns a copy of env in which the type parameter names denote
+// type variables, or env itself if there are none.
+func typeParamScope(names []*ast.Ident, env TypeEnv) TypeEnv {
+	if len(names) == 0 {
+		return env
+	}
+	scope := make(TypeEnv, len(env)+len(names))
+	for name, t := range env {
+		scope[name] = t
+	}
+	for _, name := range names {
+		scope[name.Name] = &TypeVariable{Name: name.Name}
+	}
+	return scope
+}
+
+// constraintOf converts the constraint of a type parameter, like `any`, `comparable`,
+// the name of a constraint or interface declaration, an instance of a generic one, like
+// `Set[K]`, an interface literal, like `interface{ ~int | ~string }`, or the shorthand for
+// an interface with a single type element, like `~int | ~string` or `~[]E`.
+func constraintOf(expr ast.Expr, env TypeEnv) (*TypeConstraint, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return namedConstraint(e, env)
+	case *ast.ParenExpr:
+		return constraintOf(e.X, env)
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return instanceConstraint(e, env)
+	case *ast.InterfaceType:
+		tc, err := interfaceConstraint(e, env)
+		if err != nil {
+			return nil, err
+		}
+		if tc.BuiltinConstraint == "" && len(tc.Types) == 0 && len(tc.Interfaces) == 0 {
+			return Any, nil // interface{}
+		}
+		return tc, nil
+	case *ast.BinaryExpr, *ast.UnaryExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType:
+		terms, err := unionTerms(e, env)
+		if err != nil {
+			return nil, err
+		}
+		return &TypeConstraint{Types: terms, Union: len(terms) > 1}, nil
+	}
+	return nil, fmt.Errorf("unsupported constraint %s", types.ExprString(expr))
+}
+
+// namedConstraint converts a constraint referred to by name: a builtin constraint,
+// or a constraint or interface declaration.
+func namedConstraint(ident *ast.Ident, env TypeEnv) (*TypeConstraint, error) {
+	switch ident.Name {
+	case ConstraintAny:
+		return Any, nil
+	case ConstraintComparable:
+		return &TypeConstraint{BuiltinConstraint: ConstraintComparable}, nil
+	}
+	switch t := unwrapObject(env[ident.Name]).(type) {
+	case *TypeConstraint:
+		return t, nil
+	case nil:
+		return nil, fmt.Errorf("undefined constraint %s", ident.Name)
+	default:
+		// any interface, like error or a type defined by an interface
+		if iface, ok := interfaceOf(t); ok {
+			return &TypeConstraint{Interfaces: []Interface{iface}}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a constraint", ident.Name)
+}
+
+// hasTypeElements reports whether the interface has elements other than methods and
+// embedded interfaces, like `~int | ~float64`, so that it can only be used as a constraint.
+func hasTypeElements(iface *ast.InterfaceType) bool {
+	for _, field := range iface.Methods.List {
+		if len(field.Names) > 0 {
+			continue
+		}
+		switch e := field.Type.(type) {
+		case *ast.BinaryExpr, *ast.UnaryExpr:
+			return true
+		case *ast.Ident:
+			if e.Name == ConstraintComparable || predeclared[e.Name] != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// interfaceConstraint converts a constraint interface into a TypeConstraint: its methods
+// become an Interface, `comparable` the builtin constraint, and the union of the other
+// elements, like `~int | ~float64`, its Types.
+//
+// An empty type set, like that of `interface{ int; string }`, is reported along with a
+// constraint that no type satisfies, so that a declaration of it can still be bound.
+func interfaceConstraint(iface *ast.InterfaceType, env TypeEnv) (*TypeConstraint, error) {
+	tc := &TypeConstraint{}
+	methods := MethodSet{}
+	var hasTerms bool
+	for _, field := range iface.Methods.List {
+		if len(field.Names) > 0 {
+			sig, err := buildSignature(field.Type.(*ast.FuncType), env, NewInferenceContext())
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range field.Names {
+				methods[name.Name] = Method{Name: name.Name, Params: sig.Params, Results: sig.Results, IsVariadic: sig.IsVariadic}
+			}
+			continue
+		}
+		if ident, ok := field.Type.(*ast.Ident); ok && ident.Name == ConstraintComparable {
+			tc.BuiltinConstraint = ConstraintComparable
+			continue
+		}
+		terms, err := unionTerms(field.Type, env)
+		if err != nil {
+			return nil, err
+		}
+		// the type set is the intersection of the lines, like `interface{ ~int; int | string }`
+		if hasTerms {
+			terms = intersectTerms(tc.Types, terms)
+			if len(terms) == 0 {
+				tc.Excluded = tc.Types
+				return tc, fmt.Errorf("%w: no type satisfies %s", ErrEmptyTypeSet, types.ExprString(iface))
+			}
+		}
+		tc.Types, hasTerms = terms, true
+	}
+	if len(methods) > 0 {
+		tc.Interfaces = []Interface{{Methods: methods}}
+	}
+	return tc, nil
+}
+
+// unionTerms returns the terms of a union like `~int | string`.
+func unionTerms(expr ast.Expr, env TypeEnv) ([]Type, error) {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		if e.Op != token.OR {
+			break
+		}
+		x, err := unionTerms(e.X, env)
+		if err != nil {
+			return nil, err
+		}
+		y, err := unionTerms(e.Y, env)
+		if err != nil {
+			return nil, err
+		}
+		return append(x, y...), nil
+	case *ast.UnaryExpr:
+		if e.Op != token.TILDE {
+			break
+		}
+		base, err := InferType(e.X, env, nil)
+		if err != nil {
+			return nil, err
+		}
+		return []Type{&ApproxType{Base: base}}, nil
+	case *ast.ParenExpr:
+		return unionTerms(e.X, env)
+	}
+	t, err := InferType(expr, env, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []Type{t}, nil
+}
@@ -0,0 +1,583 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const envSrc = `package p
+
+type Ordered interface {
+	~int | ~string
+}
+
+type Stringer interface {
+	String() string
+}
+
+type Point struct {
+	X, Y int
+}
+
+func (p Point) Norm() int
+
+type List[T any] struct {
+	items []T
+	next  *Point
+}
+
+func (l *List[E]) Push(v E)
+
+func Max[T Ordered](a, b T) T
+
+func Join[S Stringer](xs []S) string
+
+func double(x int) int
+`
+
+func TestBuildEnv(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", envSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Ordered", "~int | ~string"},
+		{"Point", "Point"},
+		{"List", "List[T]"},
+		{"Max", "Max[T]"},
+		{"double", "func(int) int"},
+		{"any", "any"},
+		{"int", "int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatGo(env[tt.name]); got != tt.want {
+				t.Errorf("env[%s] = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	point := env["Point"].(*StructType)
+	if strings.Join(point.FieldOrder, ",") != "X,Y" || point.Methods["Norm"].Results[0] != Type(Int) {
+		t.Errorf("Point = %v, want fields X, Y and method Norm", point)
+	}
+
+	list := env["List"].(*GenericType)
+	push, ok := list.Methods["Push"]
+	if !ok || !push.IsPointer || !TypesEqual(push.Params[0], &TypeVariable{Name: "T"}) {
+		t.Errorf("List.Push = %+v, want a pointer method taking T", push)
+	}
+	if got := FormatGo(list.Fields["next"]); got != "*Point" {
+		t.Errorf("List.next = %s, want *Point", got)
+	}
+
+	max := env["Max"].(*GenericType)
+	if c := max.Params[0].Constraint; c == nil || len(c.Types) != 2 {
+		t.Errorf("Max constraint = %v, want Ordered", c)
+	}
+	join := env["Join"].(*GenericType)
+	if c := join.Params[0].Constraint; c == nil || len(c.Interfaces) != 1 || c.Interfaces[0].Name != "Stringer" {
+		t.Errorf("Join constraint = %v, want Stringer", c)
+	}
+}
+
+func TestBuildEnvInlineConstraints(t *testing.T) {
+	const src = `package p
+
+type Stringer interface {
+	String() string
+}
+
+func Sum[T interface{ ~int | ~float64 }](xs []T) T
+
+func Key[K ~int | ~string](k K) K
+
+func First[S ~[]E, E any](s S) E
+
+func Show[T interface{ comparable; String() string }](x T) string
+
+func Any[T interface{}](x T) T
+
+func Small[T interface{ ~int | ~string; int | float64 }](x T) T
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string // the type parameter list
+	}{
+		{"Sum", "[T ~int | ~float64]"},
+		{"Key", "[K ~int | ~string]"},
+		{"First", "[S ~[]E, E any]"},
+		{"Show", "[T interface{ comparable; String() string }]"},
+		{"Any", "[T any]"},
+		{"Small", "[T int]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gt := env[tt.name].(*GenericType)
+			if got := formatTypeParams(gt.TypeParamList()); got != tt.want {
+				t.Errorf("%s type parameters = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	show := env["Show"].(*GenericType).Params[0].Constraint
+	if len(show.Interfaces) != 1 || show.Interfaces[0].Methods["String"].Name != "String" {
+		t.Errorf("Show constraint = %v, want the String method", show)
+	}
+	first := env["First"].(*GenericType).Params[0].Constraint
+	if approx, ok := first.Types[0].(*ApproxType); !ok || !TypesEqual(approx.Base, &SliceType{ElementType: &TypeVariable{Name: "E"}}) {
+		t.Errorf("First constraint = %v, want ~[]E of the type parameter E", first)
+	}
+}
+
+func TestBuildEnvDefinedTypes(t *testing.T) {
+	const src = `package p
+
+type Stringer interface {
+	String() string
+}
+
+type Celsius float64
+
+func (c Celsius) String() string
+
+func (c *Celsius) Set(v float64)
+
+type Degrees = Celsius
+
+func Join[S Stringer](xs []S) string
+
+var (
+	c     Celsius
+	temps []Celsius
+	s     Stringer
+	d     Degrees
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "c.String()", want: "string"},
+		{expr: "s.String()", want: "string"},
+		{expr: "Join(temps)", want: "string"},
+		{expr: "d.String()", want: "string"},
+		{expr: "Celsius(d)", want: "Celsius"},
+		{expr: "c.Missing()", wantErr: "method Missing not found in type Celsius"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+
+	celsius := env["Celsius"]
+	if ms := CalculateMethodSet(celsius); len(ms) != 1 {
+		t.Errorf("method set of Celsius = %v, want String", ms)
+	}
+	if ms := CalculateMethodSet(&PointerType{Base: celsius}); len(ms) != 2 {
+		t.Errorf("method set of *Celsius = %v, want Set and String", ms)
+	}
+	if alias, ok := env["Degrees"].(*TypeAlias); !ok || alias.AliasedTo != celsius {
+		t.Errorf("Degrees = %v, want an alias of Celsius", env["Degrees"])
+	}
+	stringer := underlying(env["Stringer"]).(*InterfaceType)
+	if !implInterface(celsius, Interface{Name: stringer.Name, Methods: stringer.Methods}) {
+		t.Errorf("Celsius does not implement Stringer")
+	}
+}
+
+func TestBuildEnvArrayLengthConstants(t *testing.T) {
+	const src = `package p
+
+type Grid [N]int
+
+var (
+	g = [N]int{1, 2, 3}
+	h Grid = g
+	m = [M]string{"a", "b"}
+)
+
+const N = 3
+
+const M int = N - 1
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	for name, want := range map[string]string{"g": "[3]int", "h": "Grid", "m": "[2]string"} {
+		if got := FormatGo(env[name].(*VarObj).Type); got != want {
+			t.Errorf("type of %s = %s, want %s", name, got, want)
+		}
+	}
+	if got := FormatGo(underlying(env["Grid"])); got != "[3]int" {
+		t.Errorf("underlying type of Grid = %s, want [3]int", got)
+	}
+}
+
+func TestBuildEnvGenericInterface(t *testing.T) {
+	const src = `package p
+
+type Getter[T any] interface {
+	Get() T
+}
+
+type Box struct {
+	v    int
+	tags struct{ Name string }
+}
+
+func (b Box) Get() int
+
+func Read[G Getter[int]](g G) int
+
+var (
+	box Box
+	g   Getter[string]
+	pt  struct{ A, B int }
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "g.Get()", want: "string"},
+		{expr: "Read(box)", want: "int"},
+		{expr: "Read(pt)", wantErr: "does not satisfy"},
+		{expr: "box.tags.Name", want: "string"},
+		{expr: "pt.B", want: "int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+	if getter := env["Getter"].(*GenericType); !getter.IsInterface || len(getter.Methods) != 1 {
+		t.Errorf("Getter = %v, want a generic interface with the method Get", getter)
+	}
+}
+
+func TestBuildEnvInterfaceConstraints(t *testing.T) {
+	const src = `package p
+
+type Namer interface{ Name() string }
+
+type Named Namer
+
+type Label string
+
+func (l Label) Name() string
+
+type Fault struct{}
+
+func (f Fault) Error() string
+
+func Wrap[E error](e E) E
+
+func Show[T Namer](v T) string
+
+func Print[T Named](v T) T
+
+var (
+	l Label
+	f Fault
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "Wrap(f)", want: "Fault"},
+		{expr: "Show(l)", want: "string"},
+		{expr: "Print(l)", want: "Label"},
+		{expr: "Print(f)", wantErr: "does not satisfy"},
+		{expr: "Wrap(l)", wantErr: "does not satisfy"},
+		{expr: "Show(f)", wantErr: "does not satisfy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.expr), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildEnvGenericDefinedTypes(t *testing.T) {
+	const src = `package p
+
+type List[T any] []T
+
+func (l List[T]) Len() int
+
+type Set[K comparable] map[K]struct{}
+
+type Seq[V any] func(yield func(V) bool)
+
+type Ref[T any] *T
+
+type Queue[T any] chan T
+
+type ID[T any] int
+
+func Size[T interface{ Len() int }](v T) int
+
+var (
+	l List[string]
+	s Set[int]
+	q Queue[bool]
+	r Ref[float64]
+	n ID[string]
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "l[0]", want: "string"},
+		{expr: "l.Len()", want: "int"},
+		{expr: "len(l)", want: "int"},
+		{expr: `append(l, "a")`, want: "List[string]"},
+		{expr: "List[int]{1, 2}", want: "List[int]"},
+		{expr: `List[int]{"a"}`, wantErr: "cannot use"},
+		{expr: "s[1]", want: "struct{}"},
+		{expr: "Set[string]{}", want: "Set[string]"},
+		{expr: "Set[[]int]{}", wantErr: "does not satisfy"},
+		{expr: "<-q", want: "bool"},
+		{expr: "*r", want: "float64"},
+		{expr: "r == nil", want: "bool"},
+		{expr: "n + 1", want: "ID[string]"},
+		{expr: "ID[int](3)", want: "ID[int]"},
+		{expr: "Seq[int](nil)", want: "Seq[int]"},
+		{expr: "Size(l)", want: "int"},
+		{expr: "Size(s)", wantErr: "does not satisfy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.expr), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildEnvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+		pos  string // of the TypeError, in the file set of src
+	}{
+		{name: "undefined field type", src: "type S struct{ x Missing }", want: "S: field Missing: unknown identifier: Missing", pos: "p.go:3:18"},
+		{name: "undefined constraint", src: "func F[T Missing](x T)", want: "F: undefined constraint Missing", pos: "p.go:3:1"},
+		{name: "type as constraint", src: "type S struct{}\nfunc F[T S](x T)", want: "F: S is not a constraint", pos: "p.go:4:1"},
+		{name: "undefined receiver", src: "func (m Missing) M()", want: "Missing.M: undefined receiver type Missing", pos: "p.go:3:1"},
+		{name: "empty type set of type", src: "type Bad[T interface{ int; string }] struct{ v T }", want: "Bad: empty type set: no type satisfies interface{int; string}", pos: "p.go:3:6"},
+		{name: "empty type set of function", src: "func F[T interface{ ~int; ~string }](x T)", want: "F: empty type set: no type satisfies interface{~int; ~string}", pos: "p.go:3:1"},
+		{name: "empty type set of parameter", src: "func F[K any, T interface{ comparable; []int }](x T)", want: "F: type parameter T: empty type set: no type satisfies interface{ comparable; []int }", pos: "p.go:3:1"},
+		{name: "receiver type parameters", src: "type B[T any] struct{}\nfunc (b B[K, V]) M()", want: "B.M: receiver B[K, V] has 2 type parameters, want 1", pos: "p.go:4:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", "package p\n\n"+tt.src+"\n", 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			_, err = BuildEnv([]*ast.File{file})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("BuildEnv() error = %v, want %q", err, tt.want)
+			}
+			var te *TypeError
+			if !errors.As(err, &te) {
+				t.Fatalf("BuildEnv() error = %v, want a TypeError", err)
+			}
+			if pos := fset.Position(te.Pos).String(); pos != tt.pos {
+				t.Errorf("BuildEnv() error at %s, want %s", pos, tt.pos)
+			}
+		})
+	}
+}
+
+func TestBuildEnvEmptyTypeSet(t *testing.T) {
+	const src = `package p
+
+type Bad interface{ int; string }
+
+func F[T Bad](x T) {}
+
+type S[T Bad] struct{ v T }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	const want = "Bad: empty type set: no type satisfies interface{int; string}"
+	if err == nil || err.Error() != want {
+		t.Fatalf("BuildEnv() error = %v, want %q", err, want)
+	}
+	var te *TypeError
+	if !errors.As(err, &te) || fset.Position(te.Pos).String() != "p.go:3:6" {
+		t.Errorf("BuildEnv() error = %v, want it at p.go:3:6", err)
+	}
+	// the declarations using it are bound, but cannot be instantiated
+	for _, name := range []string{"Bad", "F", "S"} {
+		if env[name] == nil {
+			t.Errorf("env[%s] is not bound", name)
+		}
+	}
+	if _, err := InferType(mustParseExpr(t, "F(1)"), env, nil); !errors.Is(err, ErrConstraintNotSatisfied) {
+		t.Errorf("InferType(F(1)) error = %v, want %v", err, ErrConstraintNotSatisfied)
+	}
+}
+
+func TestInferPackageTypeParamScope(t *testing.T) {
+	const src = `package p
+
+type Box[T any] struct{ value T }
+
+func (b *Box[E]) Set(v E) { _ = Box[E]{} }
+
+func Wrap[T any](v T) Box[T] { return Box[T]{} }
+
+var _ = Box[T]{}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	// T is only in scope in the declarations of Box and Wrap
+	env, err := BuildEnv([]*ast.File{file})
+	if err == nil || err.Error() != "declaration of _: unknown identifier: T" {
+		t.Errorf("BuildEnv() error = %v, want unknown identifier: T", err)
+	}
+	info, err := InferPackage([]*ast.File{file}, env)
+	if err == nil || err.Error() != "unknown identifier: T" {
+		t.Errorf("InferPackage() error = %v, want unknown identifier: T", err)
+	}
+	if got := len(info.Instantiations(env["Box"].(*GenericType))); got != 4 {
+		t.Errorf("Instantiations(Box) = %d instances, want 4", got)
+	}
+}
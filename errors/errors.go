@@ -0,0 +1,105 @@
+// Package errors declares the errors reported by the generic type checker, so that
+// downstream code can handle them with errors.Is and errors.As rather than matching
+// their text:
+//
+//	var ce *gerrors.ConstraintError
+//	if errors.As(err, &ce) {
+//		fmt.Printf("%s does not satisfy %s\n", ce.Arg, ce.Constraint)
+//	}
+//
+// The typed errors carry the details of the failure, and match the sentinel error of
+// their kind with errors.Is, like ConstraintError and ErrConstraintNotSatisfied. The
+// sentinels are also exported by package generic.
+//
+// The package shares its name with the standard errors package, so it is usually
+// imported under another name, like gerrors.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+)
+
+// Unification
+var (
+	ErrTypeMismatch      = stderrors.New("type mismatch")
+	ErrArityMismatch     = stderrors.New("number of parameters do not match")
+	ErrUnknownType       = stderrors.New("unknown type")
+	ErrCircularReference = stderrors.New("circular reference detected")
+	ErrAmbiguous         = stderrors.New("ambiguous unification")
+)
+
+// Inference
+var (
+	ErrUnknownIdent           = stderrors.New("unknown identifier")
+	ErrNotAFunction           = stderrors.New("not a function")
+	ErrUnknownExpr            = stderrors.New("unknown expression")
+	ErrNotAGenericType        = stderrors.New("not a generic type")
+	ErrTypeParamsNotMatch     = stderrors.New("type parameters do not match")
+	ErrConstraintNotSatisfied = stderrors.New("type does not satisfy constraint")
+	ErrNoValueUsed            = stderrors.New("no value used as value")
+	ErrNotAnExpression        = stderrors.New("is not an expression")
+	ErrNotAType               = stderrors.New("is not a type")
+	ErrInvalidOperation       = stderrors.New("invalid operation")
+	ErrInvalidConversion      = stderrors.New("cannot convert")
+	ErrMissingField           = stderrors.New("missing field")
+	ErrEmptyTypeSet           = stderrors.New("empty type set")
+)
+
+// UnknownIdentError is reported for an identifier that is not declared, like `x` in
+// `y := x + 1`. It matches ErrUnknownIdent.
+type UnknownIdentError struct {
+	Name string
+}
+
+func (e *UnknownIdentError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnknownIdent, e.Name)
+}
+
+func (e *UnknownIdentError) Unwrap() error { return ErrUnknownIdent }
+
+// ArityError is reported for a call with the wrong number of arguments, like `f(1, 2)`
+// for `func f(x int)`. It matches ErrArityMismatch.
+type ArityError struct {
+	Want, Got int
+}
+
+func (e *ArityError) Error() string {
+	return fmt.Sprintf("expected %d arguments, got %d", e.Want, e.Got)
+}
+
+func (e *ArityError) Unwrap() error { return ErrArityMismatch }
+
+// ConstraintError is reported for a type argument that does not satisfy the constraint
+// of its type parameter, like `string` for `T` in `Sum[T ~int | ~float64]`. The types are
+// in Go syntax. It matches ErrConstraintNotSatisfied.
+type ConstraintError struct {
+	Param      string // the name of the type parameter, like "T"
+	Arg        string // the type argument, like "string"
+	Constraint string // the constraint of Param, like "~int | ~float64"
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("type argument %s does not satisfy constraint %s for %s", e.Arg, e.Constraint, e.Param)
+}
+
+func (e *ConstraintError) Unwrap() error { return ErrConstraintNotSatisfied }
+
+// InferenceConflictError is reported for a call of a generic function whose arguments
+// infer different types for the same type parameter, like `Equal(x, "a")` with an int x
+// for `func Equal[T comparable](a, b T) bool`. The arguments are in source form and the
+// types in Go syntax, untyped constants by their kind. The arguments are counted
+// from 1 in the message. It matches ErrTypeMismatch.
+type InferenceConflictError struct {
+	Param string    // the name of the type parameter, like "T"
+	Args  [2]int    // the indices of the arguments, the one inferring Param first
+	Exprs [2]string // the arguments, like "x" and `"a"`
+	Types [2]string // the types they infer for Param, like "int" and "untyped string"
+}
+
+func (e *InferenceConflictError) Error() string {
+	return fmt.Sprintf("type parameter %s inferred as %s from argument %d (%s) but as %s from argument %d (%s)",
+		e.Param, e.Types[0], e.Args[0]+1, e.Exprs[0], e.Types[1], e.Args[1]+1, e.Exprs[1])
+}
+
+func (e *InferenceConflictError) Unwrap() error { return ErrTypeMismatch }
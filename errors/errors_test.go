@@ -0,0 +1,51 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		want     string
+		sentinel error
+	}{
+		{name: "unknown ident", err: &UnknownIdentError{Name: "x"}, want: "unknown identifier: x", sentinel: ErrUnknownIdent},
+		{name: "arity", err: &ArityError{Want: 1, Got: 2}, want: "expected 1 arguments, got 2", sentinel: ErrArityMismatch},
+		{
+			name:     "constraint",
+			err:      &ConstraintError{Param: "T", Arg: "string", Constraint: "~int | ~float64"},
+			want:     "type argument string does not satisfy constraint ~int | ~float64 for T",
+			sentinel: ErrConstraintNotSatisfied,
+		},
+		{
+			name:     "inference conflict",
+			err:      &InferenceConflictError{Param: "T", Args: [2]int{0, 1}, Exprs: [2]string{"x", `"a"`}, Types: [2]string{"int", "untyped string"}},
+			want:     `type parameter T inferred as int from argument 1 (x) but as untyped string from argument 2 ("a")`,
+			sentinel: ErrTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+			wrapped := fmt.Errorf("declaration of y: %w", tt.err)
+			if !stderrors.Is(wrapped, tt.sentinel) {
+				t.Errorf("errors.Is(%v, %v) = false", wrapped, tt.sentinel)
+			}
+			if tt.sentinel != ErrTypeMismatch && stderrors.Is(wrapped, ErrTypeMismatch) {
+				t.Errorf("errors.Is(%v, ErrTypeMismatch) = true", wrapped)
+			}
+		})
+	}
+
+	var ce *ConstraintError
+	err := fmt.Errorf("declaration of y: %w", &ConstraintError{Param: "T", Arg: "string"})
+	if !stderrors.As(err, &ce) || ce.Param != "T" || ce.Arg != "string" {
+		t.Errorf("errors.As(%v) = %v, want the ConstraintError", err, ce)
+	}
+}
@@ -0,0 +1,118 @@
+package containers
+
+import (
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/notJoon/generic"
+)
+
+// parseFiles parses the source of the package along with src, if it is not empty.
+func parseFiles(t *testing.T, fset *token.FileSet, src string) []*ast.File {
+	t.Helper()
+	var files []*ast.File
+	for _, name := range []string{"containers.go", "example_test.go"} {
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("ParseFile(%s) error = %v", name, err)
+		}
+		files = append(files, file)
+	}
+	if src != "" {
+		file, err := parser.ParseFile(fset, "extra.go", src, 0)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func TestCheckContainers(t *testing.T) {
+	fset := token.NewFileSet()
+	files := parseFiles(t, fset, "")
+	env, err := generic.BuildEnvWithImporter(files, generic.GoImporter(importer.Default()))
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	decls := map[string]string{
+		"Number": "~int | ~int64 | ~float64",
+		"Stack":  "Stack[T]",
+		"Queue":  "Queue[T]",
+		"Pair":   "Pair[K, V]",
+		"Map":    "Map[T, U]",
+		"Filter": "Filter[T]",
+		"Reduce": "Reduce[T, A]",
+		"Sum":    "Sum[T]",
+		"Zip":    "Zip[K, V]",
+	}
+	for name, want := range decls {
+		if got := generic.FormatGo(env[name]); got != want {
+			t.Errorf("env[%s] = %s, want %s", name, got, want)
+		}
+	}
+
+	// the declarations and the bodies of the functions and examples, which use fmt
+	// and strconv, are all checked
+	info, err := generic.InferPackage(files, env)
+	if err != nil {
+		t.Fatalf("InferPackage() error = %v", err)
+	}
+
+	// the instantiations made by the examples
+	var got []string
+	for expr, instance := range info.Instances {
+		if fset.Position(expr.Pos()).Filename == "example_test.go" {
+			got = append(got, generic.FormatGo(instance))
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"Filter[int]",
+		"Map[int, string]",
+		"Queue[int]",
+		"Reduce[int, string]",
+		"Stack[string]",
+		"Sum[float64]",
+		"Zip[string, int]",
+	}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("instances = %v, want %v", got, want)
+	}
+}
+
+func TestCheckContainersMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "constraint not satisfied", src: "var _ = Sum[string]", want: "does not satisfy constraint"},
+		{name: "comparable key", src: "var _ = Pair[[]int, int]{}", want: "does not satisfy"},
+		{name: "too many type arguments", src: "var _ = Stack[int, string]{}", want: "type arguments"},
+		{name: "undefined type argument", src: "var _ = Queue[Missing]{}", want: "unknown identifier: Missing"},
+		{name: "result in body", src: "func total(xs []int) string { return Sum(xs) }", want: "result 1: return type mismatch"},
+		{name: "method in body", src: "func push(s *Stack[int]) { s.Push(`a`) }", want: "argument type mismatch for arg 1"},
+		{name: "range in body", src: "func last(q Queue[int]) (v string) { for _, v = range q.items {}; return }", want: "cannot assign int value to v (type string) in range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := parseFiles(t, token.NewFileSet(), "package containers\n\n"+tt.src+"\n")
+			// the misuse is reported by BuildEnv, inferring the variable, or InferPackage,
+			// checking the declarations and the function bodies
+			env, buildErr := generic.BuildEnvWithImporter(files, generic.GoImporter(importer.Default()))
+			_, err := generic.InferPackage(files, env)
+			if err = errors.Join(buildErr, err); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("BuildEnv() and InferPackage() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
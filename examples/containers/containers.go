@@ -0,0 +1,119 @@
+// Package containers is a small library of generic containers and functions.
+//
+// Besides being usable on its own, it documents the subset of Go the checker handles:
+// its source, and that of its examples, is parsed and checked by the generic package
+// in its tests, so every change to either must still pass the checker.
+package containers
+
+// Number is the constraint of the types Sum can add up.
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// Stack is a last-in, first-out collection.
+type Stack[T any] struct {
+	items []T
+}
+
+// Push adds v to the top of the stack.
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+// Pop removes and returns the value at the top of the stack.
+// It reports false if the stack is empty.
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+// Len returns the number of values in the stack.
+func (s *Stack[T]) Len() int {
+	return len(s.items)
+}
+
+// Queue is a first-in, first-out collection.
+type Queue[T any] struct {
+	items []T
+}
+
+// Enqueue adds v to the back of the queue.
+func (q *Queue[T]) Enqueue(v T) {
+	q.items = append(q.items, v)
+}
+
+// Dequeue removes and returns the value at the front of the queue.
+// It reports false if the queue is empty.
+func (q *Queue[T]) Dequeue() (T, bool) {
+	var zero T
+	if len(q.items) == 0 {
+		return zero, false
+	}
+	v := q.items[0]
+	q.items = q.items[1:]
+	return v, true
+}
+
+// Len returns the number of values in the queue.
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+// Pair holds two values of possibly different types.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Map returns the results of applying f to each element of xs.
+func Map[T, U any](xs []T, f func(T) U) []U {
+	ys := make([]U, 0, len(xs))
+	for _, x := range xs {
+		ys = append(ys, f(x))
+	}
+	return ys
+}
+
+// Filter returns the elements of xs for which keep returns true.
+func Filter[T any](xs []T, keep func(T) bool) []T {
+	var ys []T
+	for _, x := range xs {
+		if keep(x) {
+			ys = append(ys, x)
+		}
+	}
+	return ys
+}
+
+// Reduce combines the elements of xs from left to right with f, starting from init.
+func Reduce[T, A any](xs []T, init A, f func(A, T) A) A {
+	acc := init
+	for _, x := range xs {
+		acc = f(acc, x)
+	}
+	return acc
+}
+
+// Sum adds up the elements of xs.
+func Sum[T Number](xs []T) T {
+	var sum T
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+
+// Zip pairs the elements of keys and values, up to the length of the shorter one.
+func Zip[K comparable, V any](keys []K, values []V) []Pair[K, V] {
+	n := min(len(keys), len(values))
+	pairs := make([]Pair[K, V], n)
+	for i := range pairs {
+		pairs[i] = Pair[K, V]{Key: keys[i], Value: values[i]}
+	}
+	return pairs
+}
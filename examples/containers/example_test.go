@@ -0,0 +1,55 @@
+package containers
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func ExampleStack() {
+	s := &Stack[string]{}
+	s.Push("a")
+	s.Push("b")
+	v, _ := s.Pop()
+	fmt.Println(v, s.Len())
+	// Output: b 1
+}
+
+func ExampleQueue() {
+	q := &Queue[int]{}
+	q.Enqueue(1)
+	q.Enqueue(2)
+	v, _ := q.Dequeue()
+	fmt.Println(v, q.Len())
+	// Output: 1 1
+}
+
+func ExampleMap() {
+	fmt.Println(Map[int, string]([]int{1, 2, 3}, strconv.Itoa))
+	// Output: [1 2 3]
+}
+
+func ExampleFilter() {
+	even := func(x int) bool { return x%2 == 0 }
+	fmt.Println(Filter[int]([]int{1, 2, 3, 4}, even))
+	// Output: [2 4]
+}
+
+func ExampleReduce() {
+	concat := func(acc string, x int) string { return acc + strconv.Itoa(x) }
+	fmt.Println(Reduce[int, string]([]int{1, 2, 3}, "", concat))
+	// Output: 123
+}
+
+func ExampleSum() {
+	fmt.Println(Sum[float64]([]float64{1.5, 2.5}))
+	// Output: 4
+}
+
+func ExampleZip() {
+	for _, p := range Zip[string, int]([]string{"a", "b"}, []int{1, 2, 3}) {
+		fmt.Println(p.Key, p.Value)
+	}
+	// Output:
+	// a 1
+	// b 2
+}
@@ -0,0 +1,231 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+var ErrNonExhaustiveSwitch = errors.New("non-exhaustive switch")
+
+// EnumSet maps the name of a named type to the constants declared with that type,
+// in declaration order. It describes enum-like types such as:
+//
+//	type Color int
+//
+//	const (
+//		Red Color = iota
+//		Green
+//		Blue
+//	)
+type EnumSet map[string][]string
+
+// CollectEnums collects the typed constants declared in the const blocks of decls.
+// Constants without an explicit type take the type of the previous constant
+// if they repeat its expression implicitly, like `Green` and `Blue` above.
+func CollectEnums(decls []ast.Decl) EnumSet {
+	enums := make(EnumSet)
+	for _, decl := range decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		var typeName string
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			switch {
+			case vs.Type != nil:
+				typeName = ""
+				if ident, ok := vs.Type.(*ast.Ident); ok {
+					typeName = ident.Name
+				}
+			case len(vs.Values) > 0:
+				// untyped constant, like `Max = 10`
+				typeName = ""
+			}
+			if typeName == "" {
+				continue
+			}
+			for _, name := range vs.Names {
+				if name.Name != "_" {
+					enums[typeName] = append(enums[typeName], name.Name)
+				}
+			}
+		}
+	}
+	return enums
+}
+
+// CheckSwitchExhaustive reports whether a switch over a value of an enum-like type
+// lists every constant declared for that type. A switch with a default clause is exhaustive.
+// The constants are taken from enums, or else from the constants of env, as declared by
+// BuildEnv, which are of the defined type of the tag.
+// Switches over other types, and switches without a tag, are not checked.
+// A non-exhaustive switch is reported as a TypeError located at the switch statement.
+//
+// The check is opt-in, since Go itself does not require switches to be exhaustive.
+func CheckSwitchExhaustive(stmt *ast.SwitchStmt, env TypeEnv, enums EnumSet) error {
+	if stmt.Tag == nil {
+		return nil
+	}
+	tagType, err := InferType(stmt.Tag, env, nil)
+	if err != nil {
+		return err
+	}
+	typeName, ok := enumTypeName(tagType)
+	if !ok {
+		return nil
+	}
+	constants, ok := enums[typeName]
+	if !ok {
+		constants = enumConstants(unalias(unwrapObject(tagType)), env)
+	}
+	if len(constants) == 0 {
+		return nil
+	}
+
+	covered := make(map[string]bool)
+	for _, s := range stmt.Body.List {
+		clause := s.(*ast.CaseClause)
+		if clause.List == nil {
+			return nil
+		}
+		for _, expr := range clause.List {
+			for {
+				paren, ok := expr.(*ast.ParenExpr)
+				if !ok {
+					break
+				}
+				expr = paren.X
+			}
+			if ident, ok := expr.(*ast.Ident); ok {
+				covered[ident.Name] = true
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range constants {
+		if !covered[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return typeError(stmt, nil, fmt.Errorf("%w of type %s: missing cases %s", ErrNonExhaustiveSwitch, typeName, strings.Join(missing, ", ")))
+	}
+	return nil
+}
+
+// enumTypeName returns the name of the type of a switch tag, if it is a named type.
+func enumTypeName(t Type) (string, bool) {
+	switch t := unalias(unwrapObject(t)).(type) {
+	case *TypeConstant:
+		return t.Name, true
+	case *NamedType:
+		return t.Name, true
+	}
+	return "", false
+}
+
+// enumConstants returns the names of the constants of env of the defined type t, in the
+// order of their values, which is the declaration order of the constants declared with iota.
+func enumConstants(t Type, env TypeEnv) []string {
+	nt, ok := t.(*NamedType)
+	if !ok {
+		return nil
+	}
+	var consts []*ConstObj
+	for _, name := range sortedKeys(env) {
+		if c, ok := env[name].(*ConstObj); ok && c.Type == Type(nt) && c.Name == name {
+			consts = append(consts, c)
+		}
+	}
+	sort.SliceStable(consts, func(i, j int) bool {
+		vi, vj := consts[i].Val, consts[j].Val
+		if vi == nil || vj == nil || vi.Kind() != vj.Kind() || vi.Kind() == constant.Unknown || vi.Kind() == constant.Bool {
+			return false
+		}
+		return constant.Compare(vi, token.LSS, vj)
+	})
+	names := make([]string, len(consts))
+	for i, c := range consts {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// CheckTypeSwitchExhaustive reports whether a type switch over a value of a sum type
+// has a case for every variant of it, see NewSumType. A switch with a default clause
+// is exhaustive. Cases for types that are not variants can never match, and are reported too.
+// Type switches over other types are not checked.
+func CheckTypeSwitchExhaustive(stmt *ast.TypeSwitchStmt, env TypeEnv) error {
+	subject, err := typeSwitchSubject(stmt, env)
+	if err != nil || subject == nil {
+		return err
+	}
+	sum, ok := unalias(subject).(*InterfaceType)
+	if !ok || sum.Variants == nil {
+		return nil
+	}
+	return checkTypeSwitchCases(stmt, sum, env)
+}
+
+// typeSwitchSubject returns the type of the value switched on by a type switch,
+// like the type of x in `switch v := x.(type)`, or nil if the switch is malformed.
+func typeSwitchSubject(stmt *ast.TypeSwitchStmt, env TypeEnv) (Type, error) {
+	var assert *ast.TypeAssertExpr
+	switch s := stmt.Assign.(type) {
+	case *ast.ExprStmt:
+		assert, _ = s.X.(*ast.TypeAssertExpr)
+	case *ast.AssignStmt:
+		if len(s.Rhs) == 1 {
+			assert, _ = s.Rhs[0].(*ast.TypeAssertExpr)
+		}
+	}
+	if assert == nil {
+		return nil, nil
+	}
+	return InferType(assert.X, env, nil)
+}
+
+// checkTypeSwitchCases checks that the cases of a type switch cover every variant of sum.
+func checkTypeSwitchCases(stmt *ast.TypeSwitchStmt, sum *InterfaceType, env TypeEnv) error {
+	covered := make([]bool, len(sum.Variants))
+	for _, s := range stmt.Body.List {
+		clause := s.(*ast.CaseClause)
+		if clause.List == nil {
+			return nil
+		}
+		for _, expr := range clause.List {
+			if ident, ok := expr.(*ast.Ident); ok && ident.Name == "nil" {
+				continue
+			}
+			caseType, err := InferType(expr, env, nil)
+			if err != nil {
+				return err
+			}
+			i := variantIndex(sum, caseType)
+			if i < 0 {
+				return typeError(expr, nil, fmt.Errorf("impossible type switch case: %s (type %s) is not a variant of %s", types.ExprString(expr), FormatGo(caseType), sum.Name))
+			}
+			covered[i] = true
+		}
+	}
+
+	var missing []string
+	for i, variant := range sum.Variants {
+		if !covered[i] {
+			missing = append(missing, FormatGo(variant))
+		}
+	}
+	if len(missing) > 0 {
+		return typeError(stmt, nil, fmt.Errorf("%w of type %s: missing cases %s", ErrNonExhaustiveSwitch, sum.Name, strings.Join(missing, ", ")))
+	}
+	return nil
+}
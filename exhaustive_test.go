@@ -0,0 +1,255 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const enumSrc = `package p
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	_
+	Blue
+)
+
+const (
+	Max = 10
+	Min
+)
+
+const Small, Large Size = 1, 2
+
+func _() {
+	switch c {
+	case Red, Green:
+	case (Blue):
+	}
+	switch c {
+	case Red:
+	case Blue:
+	}
+	switch c {
+	case Red:
+	default:
+	}
+	switch n {
+	case Max:
+	}
+	switch s {
+	case Small:
+	}
+	switch {
+	case true:
+	}
+}
+`
+
+func TestCollectEnums(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", enumSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	got := CollectEnums(file.Decls)
+	want := EnumSet{
+		"Color": {"Red", "Green", "Blue"},
+		"Size":  {"Small", "Large"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CollectEnums() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckSwitchExhaustive(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", enumSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	enums := CollectEnums(file.Decls)
+	env := TypeEnv{
+		"c": &TypeConstant{Name: "Color"},
+		"n": &TypeConstant{Name: "int"},
+		"s": &TypeConstant{Name: "Size"},
+	}
+
+	var switches []*ast.SwitchStmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sw, ok := n.(*ast.SwitchStmt); ok {
+			switches = append(switches, sw)
+		}
+		return true
+	})
+
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{name: "all constants"},
+		{name: "missing constant", wantErr: "missing cases Green"},
+		{name: "default clause"},
+		{name: "not an enum"},
+		{name: "missing constant of multi-name spec", wantErr: "non-exhaustive switch of type Size: missing cases Large"},
+		{name: "no tag"},
+	}
+	if len(switches) != len(tests) {
+		t.Fatalf("found %d switches, want %d", len(switches), len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSwitchExhaustive(switches[i], env, enums)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckSwitchExhaustive() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckSwitchExhaustive() error = %v, want %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, ErrNonExhaustiveSwitch) {
+				t.Errorf("CheckSwitchExhaustive() error = %v, want %v", err, ErrNonExhaustiveSwitch)
+			}
+			if te := (*TypeError)(nil); !errors.As(err, &te) || te.Pos != switches[i].Pos() {
+				t.Errorf("CheckSwitchExhaustive() error = %#v, want a TypeError at the switch", err)
+			}
+		})
+	}
+}
+
+func TestCheckSwitchExhaustiveBuildEnv(t *testing.T) {
+	const src = `package p
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+type Alias = Color
+
+var (
+	c Color
+	a Alias
+)
+
+func _() {
+	switch c {
+	case Red, Green, Blue:
+	}
+	switch c {
+	case Blue, Red:
+	}
+	switch a {
+	case Red:
+	default:
+	}
+	switch a {
+	case Green:
+	}
+}
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	var switches []*ast.SwitchStmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sw, ok := n.(*ast.SwitchStmt); ok {
+			switches = append(switches, sw)
+		}
+		return true
+	})
+
+	wantErrs := []string{
+		"",
+		"non-exhaustive switch of type Color: missing cases Green",
+		"",
+		"non-exhaustive switch of type Color: missing cases Red, Blue",
+	}
+	for _, enums := range []EnumSet{CollectEnums(file.Decls), nil} {
+		for i, want := range wantErrs {
+			err := CheckSwitchExhaustive(switches[i], env, enums)
+			if want == "" {
+				if err != nil {
+					t.Errorf("CheckSwitchExhaustive(switch %d, %v) error = %v", i, enums, err)
+				}
+				continue
+			}
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("CheckSwitchExhaustive(switch %d, %v) error = %v, want %q", i, enums, err, want)
+			}
+		}
+	}
+}
+
+func TestCheckTypeSwitchExhaustive(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	opt := OptionType(intType)
+	env := TypeEnv{
+		"opt":   opt,
+		"n":     intType,
+		"Some":  opt.Variants[0],
+		"None":  opt.Variants[1],
+		"Other": &StructType{Name: "Other"},
+		"int":   intType,
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{name: "all variants", src: "switch opt.(type) {\ncase Some:\ncase None:\n}"},
+		{name: "variants in one clause", src: "switch v := opt.(type) {\ncase Some, None, nil:\n_ = v\n}"},
+		{name: "missing variant", src: "switch v := opt.(type) {\ncase Some:\n_ = v\n}", wantErr: "non-exhaustive switch of type Option[TypeConst(int)]: missing cases None[TypeConst(int)]"},
+		{name: "default clause", src: "switch opt.(type) {\ncase Some:\ndefault:\n}"},
+		{name: "impossible case", src: "switch opt.(type) {\ncase Some, Other:\ncase None:\n}", wantErr: "Other (type Other) is not a variant of Option[TypeConst(int)]"},
+		{name: "not a sum type", src: "var x interface{}\nswitch x.(type) {\ncase int:\n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+tt.src+"\n}", 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			var stmt *ast.TypeSwitchStmt
+			ast.Inspect(file, func(n ast.Node) bool {
+				if sw, ok := n.(*ast.TypeSwitchStmt); ok {
+					stmt = sw
+				}
+				return stmt == nil
+			})
+			caseEnv := TypeEnv{"x": &InterfaceType{Name: "interface{}", IsEmpty: true}}
+			for k, v := range env {
+				caseEnv[k] = v
+			}
+
+			err = CheckTypeSwitchExhaustive(stmt, caseEnv)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckTypeSwitchExhaustive() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckTypeSwitchExhaustive() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
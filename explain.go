@@ -0,0 +1,273 @@
+package generic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Satisfaction is the proof built by ExplainSatisfaction: the checks that decide whether
+// a type argument satisfies a constraint, in the order they are made.
+type Satisfaction struct {
+	Type       Type // the type argument
+	Constraint TypeConstraint
+	Satisfied  bool
+
+	// Reason tells why the constraint is not satisfied, like "missing method String"
+	// or "string missing in ~int | ~float64". It is empty if it is.
+	Reason string
+
+	Excluded  Type   // the excluded term the type falls in, if any
+	Builtin   string // the builtin constraint, like "comparable", if any
+	BuiltinOK bool   // whether the type satisfies Builtin
+
+	Methods []MethodProof // the methods of the interfaces, by interface and method name
+	Terms   []TermProof   // the type terms tried, up to the one that matched
+}
+
+// MethodProof tells where a method required by an interface of the constraint was found.
+type MethodProof struct {
+	Interface string
+	Method    string
+	Found     bool
+
+	// Via is the type declaring the method: the type argument, or the type of the field
+	// it is promoted from, whose names are in Path, like ["Base"].
+	Via  Type
+	Path []string
+
+	// PointerReceiver is true for a method missing from a value type argument because it
+	// is declared with a pointer receiver, so that only the pointer type has it.
+	PointerReceiver bool
+}
+
+// TermProof is the check of a type term of the constraint against the type argument.
+// A `~T` term matches by underlying type, see Underlying.
+type TermProof struct {
+	Term       Type
+	Matched    bool
+	Underlying bool // true if the type matched by its underlying type
+}
+
+// ExplainSatisfaction explains whether t satisfies the constraint c, for diagnostics that
+// go beyond "does not satisfy", like which union term of `~int | ~float64` matched a
+// defined type, or where the methods of an interface were found on a struct.
+//
+// The verdict is the one of the instantiation checks, and the proof follows them: once
+// a check fails, the later ones are not recorded. A type parameter satisfies any
+// constraint until it is bound, so its proof is empty.
+func ExplainSatisfaction(t Type, c TypeConstraint) *Satisfaction {
+	s := &Satisfaction{Type: t, Constraint: c, Satisfied: checkConstraint(t, c)}
+	t = unalias(t)
+	if _, ok := t.(*TypeVariable); ok {
+		return s
+	}
+	s.explain(t, c)
+	return s
+}
+
+// explain records the checks of checkConstraint for t and c, stopping at the one that fails.
+func (s *Satisfaction) explain(t Type, c TypeConstraint) {
+	for _, ex := range c.Excluded {
+		if isExcluded(t, []Type{ex}) {
+			s.Excluded = ex
+			s.fail("%s is excluded by %s", FormatGo(t), FormatGo(ex))
+			return
+		}
+	}
+	if c.BuiltinConstraint != "" {
+		s.Builtin, s.BuiltinOK = c.BuiltinConstraint, checkBuiltinConstraint(t, c.BuiltinConstraint)
+		if !s.BuiltinOK {
+			s.fail("%s does not satisfy %s", FormatGo(t), c.BuiltinConstraint)
+			return
+		}
+		if len(c.Types) == 0 && len(c.Interfaces) == 0 {
+			return
+		}
+	}
+
+	// a pointer has the methods of the pointer receivers, and its base type is checked
+	// against the rest of the constraint unless a pointer term matches
+	if ptr, ok := t.(*PointerType); ok {
+		if !s.explainInterfaces(ptr, c.Interfaces) {
+			return
+		}
+		for _, term := range c.Types {
+			if base, ok := term.(*PointerType); ok && TypesEqual(ptr.Base, base.Base) {
+				s.Terms = append(s.Terms, TermProof{Term: term, Matched: true})
+				return
+			}
+		}
+		base := c
+		base.Interfaces = nil
+		s.explain(unalias(ptr.Base), base)
+		return
+	}
+
+	if !s.explainInterfaces(t, c.Interfaces) || len(c.Types) == 0 {
+		return
+	}
+	for _, term := range c.Types {
+		proof := TermProof{Term: term}
+		switch allowed := unalias(term).(type) {
+		case *ApproxType:
+			proof.Matched = isUnderlyingType(t, unalias(allowed.Base))
+			proof.Underlying = proof.Matched
+		case *TypeConstraint:
+			proof.Matched = checkConstraint(t, *allowed)
+		default:
+			if c.IsUnderlying {
+				proof.Matched = isUnderlyingType(t, allowed)
+				proof.Underlying = proof.Matched
+			} else {
+				proof.Matched = TypesEqual(t, allowed)
+			}
+		}
+		s.Terms = append(s.Terms, proof)
+		if proof.Matched {
+			return
+		}
+	}
+	terms := make([]string, len(c.Types))
+	for i, term := range c.Types {
+		terms[i] = FormatGo(term)
+	}
+	s.fail("%s missing in %s", FormatGo(t), strings.Join(terms, " | "))
+}
+
+// explainInterfaces records where the methods of ifaces are found on t, and reports
+// whether t implements all of them.
+func (s *Satisfaction) explainInterfaces(t Type, ifaces []Interface) bool {
+	for _, iface := range ifaces {
+		for _, name := range sortedKeys(iface.Methods) {
+			proof := locateMethod(t, name)
+			proof.Interface, proof.Method = iface.Name, name
+			s.Methods = append(s.Methods, proof)
+		}
+		proofs := s.Methods[len(s.Methods)-len(iface.Methods):]
+		if implInterface(t, iface) {
+			// the predeclared types have the methods of a few interfaces, which are
+			// not declared, see checkPrimitiveTypeInterface
+			for i := range proofs {
+				proofs[i].Found = true
+			}
+			continue
+		}
+		ms := CalculateMethodSet(t)
+		for _, proof := range proofs {
+			switch {
+			case proof.PointerReceiver:
+				s.fail("method %s has pointer receiver", proof.Method)
+				return false
+			case !proof.Found:
+				s.fail("missing method %s", proof.Method)
+				return false
+			case !sameSignature(ms[proof.Method], iface.Methods[proof.Method]):
+				s.fail("wrong type for method %s", proof.Method)
+				return false
+			}
+		}
+		s.fail("%s does not implement %s", FormatGo(t), iface.Name)
+		return false
+	}
+	return true
+}
+
+// locateMethod finds the method name in the method set of t, like calculateStructMethodSet:
+// the methods declared on a struct come before those promoted from its fields, which are
+// taken in the order of their names.
+func locateMethod(t Type, name string) MethodProof {
+	isPtr := false
+	if ptr, ok := t.(*PointerType); ok {
+		t, isPtr = unalias(ptr.Base), true
+	}
+	if gt, ok := t.(*GenericType); ok {
+		t = gt.Underlying()
+	}
+	switch t := t.(type) {
+	case *InterfaceType:
+		_, found := t.Methods[name]
+		return MethodProof{Found: found, Via: t}
+	case *TypeConstant:
+		// predeclared types declare no methods
+		return MethodProof{Via: t}
+	case *StructType:
+		var pointerReceiver bool
+		if m, ok := t.Methods[name]; ok {
+			if isPtr || !m.IsPointer {
+				return MethodProof{Found: true, Via: t}
+			}
+			pointerReceiver = true
+		}
+		for _, fld := range sortedKeys(t.Fields) {
+			ft := t.Fields[fld]
+			if gt, ok := ft.(*GenericType); ok {
+				ft = gt.Underlying()
+			}
+			if _, ok := ft.(*StructType); !ok {
+				continue
+			}
+			if proof := locateMethod(ft, name); proof.Found {
+				proof.Path = append([]string{fld}, proof.Path...)
+				return proof
+			}
+		}
+		return MethodProof{Via: t, PointerReceiver: pointerReceiver}
+	}
+	return MethodProof{}
+}
+
+func (s *Satisfaction) fail(format string, args ...interface{}) {
+	s.Reason = fmt.Sprintf(format, args...)
+}
+
+// String describes the proof, one check per line after the verdict, like
+//
+//	Celsius satisfies ~int | ~float64
+//		term ~int: no match
+//		term ~float64: matches the underlying type
+func (s *Satisfaction) String() string {
+	var b strings.Builder
+	if s.Satisfied {
+		fmt.Fprintf(&b, "%s satisfies %s", FormatGo(s.Type), FormatGo(&s.Constraint))
+	} else {
+		fmt.Fprintf(&b, "%s does not satisfy %s", FormatGo(s.Type), FormatGo(&s.Constraint))
+		if s.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", s.Reason)
+		}
+	}
+	if s.Excluded != nil {
+		fmt.Fprintf(&b, "\n\texcluded by %s", FormatGo(s.Excluded))
+	}
+	if s.Builtin != "" {
+		verdict := "yes"
+		if !s.BuiltinOK {
+			verdict = "no"
+		}
+		fmt.Fprintf(&b, "\n\t%s: %s", s.Builtin, verdict)
+	}
+	for _, m := range s.Methods {
+		fmt.Fprintf(&b, "\n\tmethod %s of %s: ", m.Method, m.Interface)
+		switch {
+		case m.Found && len(m.Path) > 0:
+			fmt.Fprintf(&b, "promoted from %s (%s)", strings.Join(m.Path, "."), FormatGo(m.Via))
+		case m.Found:
+			fmt.Fprintf(&b, "declared by %s", FormatGo(m.Via))
+		case m.PointerReceiver:
+			fmt.Fprintf(&b, "missing, declared with pointer receiver by %s", FormatGo(m.Via))
+		default:
+			b.WriteString("missing")
+		}
+	}
+	for _, term := range s.Terms {
+		fmt.Fprintf(&b, "\n\tterm %s: ", FormatGo(term.Term))
+		switch {
+		case term.Underlying:
+			b.WriteString("matches the underlying type")
+		case term.Matched:
+			b.WriteString("matches")
+		default:
+			b.WriteString("no match")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package generic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplainSatisfaction(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	floatType := &TypeConstant{Name: "float64"}
+	celsius := &NamedType{Name: "Celsius", Underlying: floatType}
+	number := TypeConstraint{Types: []Type{&ApproxType{Base: intType}, &ApproxType{Base: floatType}}, Union: true}
+
+	base := &StructType{Name: "Base"}
+	base.Methods = MethodSet{"String": {Name: "String", Receiver: base, Results: []Type{strType}}}
+	buffer := &StructType{Name: "Buffer", Fields: map[string]Type{"Base": base}}
+	buffer.Methods = MethodSet{"Write": {Name: "Write", Receiver: buffer, Params: []Type{strType}, IsPointer: true}}
+	stringer := Interface{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{strType}}}}
+	writer := Interface{Name: "Writer", Methods: MethodSet{"Write": {Name: "Write", Params: []Type{strType}}}}
+
+	tests := []struct {
+		name        string
+		t           Type
+		constraint  TypeConstraint
+		want        bool
+		wantReason  string
+		wantTerms   []TermProof
+		wantMethods []MethodProof
+	}{
+		{
+			name:       "exact term",
+			t:          strType,
+			constraint: TypeConstraint{Types: []Type{intType, strType}, Union: true},
+			want:       true,
+			wantTerms:  []TermProof{{Term: intType}, {Term: strType, Matched: true}},
+		},
+		{
+			name:       "approximate term",
+			t:          celsius,
+			constraint: number,
+			want:       true,
+			wantTerms:  []TermProof{{Term: number.Types[0]}, {Term: number.Types[1], Matched: true, Underlying: true}},
+		},
+		{
+			name:       "no term",
+			t:          strType,
+			constraint: number,
+			wantReason: "string missing in ~int | ~float64",
+			wantTerms:  []TermProof{{Term: number.Types[0]}, {Term: number.Types[1]}},
+		},
+		{
+			name:        "promoted method",
+			t:           buffer,
+			constraint:  TypeConstraint{Interfaces: []Interface{stringer}},
+			want:        true,
+			wantMethods: []MethodProof{{Interface: "Stringer", Method: "String", Found: true, Via: base, Path: []string{"Base"}}},
+		},
+		{
+			name:        "pointer receiver",
+			t:           buffer,
+			constraint:  TypeConstraint{Interfaces: []Interface{writer}},
+			wantReason:  "method Write has pointer receiver",
+			wantMethods: []MethodProof{{Interface: "Writer", Method: "Write", Via: buffer, PointerReceiver: true}},
+		},
+		{
+			name:        "pointer has pointer receiver method",
+			t:           &PointerType{Base: buffer},
+			constraint:  TypeConstraint{Interfaces: []Interface{writer}},
+			want:        true,
+			wantMethods: []MethodProof{{Interface: "Writer", Method: "Write", Found: true, Via: buffer}},
+		},
+		{
+			name:        "missing method",
+			t:           base,
+			constraint:  TypeConstraint{Interfaces: []Interface{stringer, writer}},
+			wantReason:  "missing method Write",
+			wantMethods: []MethodProof{{Interface: "Stringer", Method: "String", Found: true, Via: base}, {Interface: "Writer", Method: "Write", Via: base}},
+		},
+		{
+			name:        "methods before terms",
+			t:           intType,
+			constraint:  TypeConstraint{Interfaces: []Interface{writer}, Types: []Type{intType}},
+			wantReason:  "missing method Write",
+			wantMethods: []MethodProof{{Interface: "Writer", Method: "Write", Via: intType}},
+		},
+		{
+			name:       "builtin",
+			t:          &SliceType{ElementType: intType},
+			constraint: TypeConstraint{BuiltinConstraint: ConstraintComparable},
+			wantReason: "[]int does not satisfy comparable",
+		},
+		{
+			name:       "excluded",
+			t:          celsius,
+			constraint: TypeConstraint{Types: number.Types, Excluded: []Type{celsius}},
+			wantReason: "Celsius is excluded by Celsius",
+		},
+		{name: "type parameter", t: &TypeVariable{Name: "T"}, constraint: number, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExplainSatisfaction(tt.t, tt.constraint)
+			if got.Satisfied != tt.want || got.Satisfied != checkConstraint(tt.t, tt.constraint) {
+				t.Errorf("ExplainSatisfaction().Satisfied = %v, want %v", got.Satisfied, tt.want)
+			}
+			if got.Reason != tt.wantReason {
+				t.Errorf("ExplainSatisfaction().Reason = %q, want %q", got.Reason, tt.wantReason)
+			}
+			if !reflect.DeepEqual(got.Terms, tt.wantTerms) {
+				t.Errorf("ExplainSatisfaction().Terms = %+v, want %+v", got.Terms, tt.wantTerms)
+			}
+			if !reflect.DeepEqual(got.Methods, tt.wantMethods) {
+				t.Errorf("ExplainSatisfaction().Methods = %+v, want %+v", got.Methods, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestSatisfactionString(t *testing.T) {
+	celsius := &NamedType{Name: "Celsius", Underlying: &TypeConstant{Name: "float64"}}
+	number := TypeConstraint{Types: []Type{&ApproxType{Base: &TypeConstant{Name: "int"}}, &ApproxType{Base: &TypeConstant{Name: "float64"}}}, Union: true}
+	tests := []struct {
+		t    Type
+		want string
+	}{
+		{
+			t:    celsius,
+			want: "Celsius satisfies ~int | ~float64\n\tterm ~int: no match\n\tterm ~float64: matches the underlying type",
+		},
+		{
+			t:    &TypeConstant{Name: "string"},
+			want: "string does not satisfy ~int | ~float64 (string missing in ~int | ~float64)\n\tterm ~int: no match\n\tterm ~float64: no match",
+		},
+	}
+	for _, tt := range tests {
+		if got := ExplainSatisfaction(tt.t, number).String(); got != tt.want {
+			t.Errorf("ExplainSatisfaction(%s).String() =\n%s\nwant\n%s", FormatGo(tt.t), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package generic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExtractConstraint synthesizes the minimal constraint satisfied by every type of types,
+// such as the type arguments found at the instantiation sites of an `interface{}`-based API
+// being migrated to generics. The constraint requires the methods common to all the types,
+// collected in an interface named name, and allows the union of their underlying types,
+// like `~int | ~string`.
+//
+// Interfaces cannot be union terms, so if any of the types is an interface,
+// the constraint only requires the common methods. It returns nil if types is empty.
+func ExtractConstraint(name string, types []Type) *TypeConstraint {
+	if len(types) == 0 {
+		return nil
+	}
+
+	tc := &TypeConstraint{}
+	var common MethodSet
+	union := true
+	for i, t := range types {
+		t = unalias(t)
+		methods := valueMethods(CalculateMethodSet(t))
+		if i == 0 {
+			common = methods
+		} else {
+			common = commonMethods(common, methods)
+		}
+
+		switch u := underlyingOf(t).(type) {
+		case *InterfaceType, *Interface:
+			union = false
+		default:
+			if !containsType(tc.Types, &ApproxType{Base: u}) {
+				tc.Types = append(tc.Types, &ApproxType{Base: u})
+			}
+		}
+	}
+
+	if !union {
+		tc.Types = nil
+	}
+	tc.Union = len(tc.Types) > 1
+	if len(common) > 0 {
+		tc.Interfaces = []Interface{{Name: name, Methods: common}}
+	}
+	return tc
+}
+
+// underlyingOf returns the underlying type of t: the fields of a named struct
+// or of a generic type's instance, and t itself for other types.
+func underlyingOf(t Type) Type {
+	switch t := t.(type) {
+	case *StructType:
+		return &StructType{Fields: t.Fields, FieldOrder: t.FieldOrder}
+	case *GenericType:
+		return underlyingOf(t.Underlying())
+	}
+	return t
+}
+
+// valueMethods returns a copy of methods with the receiver kinds cleared,
+// so that methods of different types can be compared by signature.
+func valueMethods(methods MethodSet) MethodSet {
+	ms := make(MethodSet, len(methods))
+	for name, m := range methods {
+		m.Receiver, m.IsPointer = nil, false
+		ms[name] = m
+	}
+	return ms
+}
+
+func commonMethods(a, b MethodSet) MethodSet {
+	common := make(MethodSet)
+	for name, m := range a {
+		if other, ok := b[name]; ok && MethodsEqual(m, other) {
+			common[name] = m
+		}
+	}
+	return common
+}
+
+// ConstraintDecl returns the Go source declaring tc as the constraint interface name,
+// with its elements on separate lines, like
+//
+//	type Number interface {
+//		~int | ~float64
+//		String() string
+//	}
+func ConstraintDecl(name string, tc *TypeConstraint) string {
+	var elems []string
+	if tc.BuiltinConstraint != "" {
+		elems = append(elems, builtinConstraintSyntax[tc.BuiltinConstraint])
+	}
+	if len(tc.Types) > 0 {
+		terms := make([]string, len(tc.Types))
+		for i, t := range tc.Types {
+			terms[i] = FormatGo(t)
+		}
+		elems = append(elems, strings.Join(terms, " | "))
+	}
+	for _, iface := range tc.Interfaces {
+		for _, m := range sortedKeys(iface.Methods) {
+			elems = append(elems, FormatGo(iface.Methods[m]))
+		}
+	}
+
+	if len(elems) == 0 {
+		return fmt.Sprintf("type %s interface{}\n", name)
+	}
+	return fmt.Sprintf("type %s interface {\n\t%s\n}\n", name, strings.Join(elems, "\n\t"))
+}
@@ -0,0 +1,71 @@
+package generic
+
+import "testing"
+
+func TestExtractConstraint(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringType := &TypeConstant{Name: "string"}
+	stringMethod := Method{Name: "String", Results: []Type{stringType}}
+
+	celsius := &StructType{Name: "Celsius", Fields: map[string]Type{"Degrees": intType}}
+	celsius.Methods = MethodSet{
+		"String": {Name: "String", Receiver: celsius, Results: []Type{stringType}},
+		"Kelvin": {Name: "Kelvin", Receiver: celsius, Results: []Type{intType}},
+	}
+	label := &StructType{Name: "Label", Fields: map[string]Type{"Text": stringType}}
+	label.Methods = MethodSet{
+		"String": {Name: "String", Receiver: label, Results: []Type{stringType}},
+		"Kelvin": {Name: "Kelvin", Receiver: label, Results: []Type{stringType}},
+	}
+	stringer := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": stringMethod}}
+
+	tests := []struct {
+		name  string
+		types []Type
+		want  string
+	}{
+		{
+			name:  "basic types",
+			types: []Type{intType, stringType, intType},
+			want:  "type Value interface {\n\t~int | ~string\n}\n",
+		},
+		{
+			name:  "common methods",
+			types: []Type{celsius, label},
+			want:  "type Value interface {\n\t~struct{ Degrees int } | ~struct{ Text string }\n\tString() string\n}\n",
+		},
+		{
+			name:  "alias",
+			types: []Type{&TypeAlias{Name: "ID", AliasedTo: intType}},
+			want:  "type Value interface {\n\t~int\n}\n",
+		},
+		{
+			name:  "interface",
+			types: []Type{celsius, stringer},
+			want:  "type Value interface {\n\tString() string\n}\n",
+		},
+		{
+			name:  "nothing in common",
+			types: []Type{stringer, &InterfaceType{Name: "Closer", Methods: MethodSet{"Close": {Name: "Close"}}}},
+			want:  "type Value interface{}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := ExtractConstraint("Value", tt.types)
+			if got := ConstraintDecl("Value", tc); got != tt.want {
+				t.Errorf("ConstraintDecl() =\n%s\nwant\n%s", got, tt.want)
+			}
+			for _, typ := range tt.types {
+				if !checkConstraint(typ, *tc) {
+					t.Errorf("%v does not satisfy the extracted constraint %v", typ, tc)
+				}
+			}
+		})
+	}
+
+	if tc := ExtractConstraint("Value", nil); tc != nil {
+		t.Errorf("ExtractConstraint(nil) = %v, want nil", tc)
+	}
+}
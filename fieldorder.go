@@ -0,0 +1,74 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrFieldOrder = errors.New("suboptimal field order")
+
+// OptimalFieldOrder returns the names of the fields of the struct type t in an order
+// that minimizes padding: fields are sorted by decreasing alignment, keeping the
+// declaration order of fields with the same alignment. Zero-size fields go first,
+// since they need no alignment padding there.
+func (s Sizes) OptimalFieldOrder(t Type) ([]string, error) {
+	l, err := s.Layout(t)
+	if err != nil {
+		return nil, err
+	}
+	fields := append([]FieldLayout(nil), l.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		if (fields[i].Size == 0) != (fields[j].Size == 0) {
+			return fields[i].Size == 0
+		}
+		return fields[i].Align > fields[j].Align
+	})
+	order := make([]string, len(fields))
+	for i, f := range fields {
+		order[i] = f.Name
+	}
+	return order, nil
+}
+
+// SuggestFieldOrder reports a diagnostic wrapping ErrFieldOrder if reordering the fields of
+// the struct type t, a struct or an instance of a generic struct, would make it smaller,
+// like "Pair[bool, int64, bool] is 24 bytes, 16 with fields ordered B, A, C".
+// It returns nil if the fields are already ordered optimally.
+func (s Sizes) SuggestFieldOrder(t Type) error {
+	l, err := s.Layout(t)
+	if err != nil {
+		return err
+	}
+	order, err := s.OptimalFieldOrder(t)
+	if err != nil {
+		return err
+	}
+	fields, _, _ := structFields(t)
+	optimal, err := s.structLayout(fields, order, NewTypeVisitor())
+	if err != nil {
+		return err
+	}
+	if optimal.Size >= l.Size {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is %d bytes, %d with fields ordered %s",
+		ErrFieldOrder, FormatGo(t), l.Size, optimal.Size, strings.Join(order, ", "))
+}
+
+// CheckFieldOrder suggests field orders, see SuggestFieldOrder, for the struct types declared
+// in env, in the order of their names. Structs whose size is unknown are skipped.
+func CheckFieldOrder(env TypeEnv, sizes Sizes) []error {
+	var errs []error
+	for _, name := range sortedKeys(env) {
+		st, ok := env[name].(*StructType)
+		if !ok || st.Name != name {
+			continue
+		}
+		if err := sizes.SuggestFieldOrder(st); errors.Is(err, ErrFieldOrder) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,120 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestSuggestFieldOrder(t *testing.T) {
+	boolType := &TypeConstant{Name: "bool"}
+	int64Type := &TypeConstant{Name: "int64"}
+	triple := NewGenericType("Triple", TypeParamList{{Name: "A"}, {Name: "B"}, {Name: "C"}},
+		map[string]Type{"X": &TypeVariable{Name: "A"}, "Y": &TypeVariable{Name: "B"}, "Z": &TypeVariable{Name: "C"}}, nil)
+	triple.FieldOrder = []string{"X", "Y", "Z"}
+
+	tests := []struct {
+		name      string
+		typ       Type
+		wantOrder []string
+		wantMsg   string
+	}{
+		{
+			name: "padded struct",
+			typ: &StructType{
+				Name:       "S",
+				Fields:     map[string]Type{"A": boolType, "B": int64Type, "C": boolType},
+				FieldOrder: []string{"A", "B", "C"},
+			},
+			wantOrder: []string{"B", "A", "C"},
+			wantMsg:   "suboptimal field order: S is 24 bytes, 16 with fields ordered B, A, C",
+		},
+		{
+			name: "zero-size field",
+			typ: &StructType{
+				Name:       "Z",
+				Fields:     map[string]Type{"A": int64Type, "B": &StructType{Fields: map[string]Type{}}},
+				FieldOrder: []string{"A", "B"},
+			},
+			wantOrder: []string{"B", "A"},
+		},
+		{
+			name:      "generic instance",
+			typ:       mustInstantiate(t, triple, boolType, int64Type, boolType),
+			wantOrder: []string{"Y", "X", "Z"},
+			wantMsg:   "suboptimal field order: Triple[bool, int64, bool] is 24 bytes, 16 with fields ordered Y, X, Z",
+		},
+		{
+			name:      "optimal instance",
+			typ:       mustInstantiate(t, triple, int64Type, boolType, boolType),
+			wantOrder: []string{"X", "Y", "Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := DefaultSizes.OptimalFieldOrder(tt.typ)
+			if err != nil {
+				t.Fatalf("OptimalFieldOrder() error = %v", err)
+			}
+			if !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("OptimalFieldOrder() = %v, want %v", order, tt.wantOrder)
+			}
+			err = DefaultSizes.SuggestFieldOrder(tt.typ)
+			switch {
+			case tt.wantMsg == "" && err != nil:
+				t.Errorf("SuggestFieldOrder() error = %v, want nil", err)
+			case tt.wantMsg != "" && (err == nil || err.Error() != tt.wantMsg):
+				t.Errorf("SuggestFieldOrder() error = %v, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheckFieldOrder(t *testing.T) {
+	boolType := &TypeConstant{Name: "bool"}
+	int64Type := &TypeConstant{Name: "int64"}
+	padded := &StructType{Name: "Padded", Fields: map[string]Type{"A": boolType, "B": int64Type, "C": boolType}, FieldOrder: []string{"A", "B", "C"}}
+	env := TypeEnv{
+		"Padded":  padded,
+		"Packed":  &StructType{Name: "Packed", Fields: map[string]Type{"B": int64Type, "A": boolType}, FieldOrder: []string{"B", "A"}},
+		"Unknown": &StructType{Name: "Unknown", Fields: map[string]Type{"T": &TypeVariable{Name: "T"}}},
+		"p":       padded,
+	}
+
+	errs := CheckFieldOrder(env, DefaultSizes)
+	if len(errs) != 1 || errs[0].Error() != "suboptimal field order: Padded is 24 bytes, 16 with fields ordered B, A, C" {
+		t.Errorf("CheckFieldOrder() = %v", errs)
+	}
+}
+
+func TestCheckFieldOrderDefinedTypes(t *testing.T) {
+	const src = `package p
+
+type Age int64
+
+type ID [2]Age
+
+type Person struct {
+	Alive bool
+	Age   Age
+	Admin bool
+	ID    ID
+}
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	errs := CheckFieldOrder(env, DefaultSizes)
+	if len(errs) != 1 || errs[0].Error() != "suboptimal field order: Person is 40 bytes, 32 with fields ordered Age, ID, Alive, Admin" {
+		t.Errorf("CheckFieldOrder() = %v", errs)
+	}
+}
@@ -0,0 +1,237 @@
+package generic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// builtinConstraintSyntax spells the builtin constraints the way Go code refers to them.
+var builtinConstraintSyntax = map[string]string{
+	ConstraintAny:        "any",
+	ConstraintComparable: "comparable",
+	ConstraintOrdered:    "cmp.Ordered",
+	ConstraintComplex:    "constraints.Complex",
+	ConstraintFloat:      "constraints.Float",
+	ConstraintInteger:    "constraints.Integer",
+	ConstraintSigned:     "constraints.Signed",
+	ConstraintUnsigned:   "constraints.Unsigned",
+}
+
+// FormatGo formats t in Go syntax, like `Stack[T]`, `map[string][]*Foo` or
+// `func(int, ...string) bool`, unlike String, which describes the structure of the type.
+// Named types are referred to by name; anonymous structs and interfaces are spelled out.
+func FormatGo(t Type) string {
+	switch t := t.(type) {
+	case nil:
+		return ""
+	case *TypeConstant:
+		return t.Name
+	case *TypeVariable:
+		return baseName(t.Name)
+	case *TypeAlias:
+		return t.Name
+	case *NamedType:
+		return t.Name
+	case *PointerType:
+		return "*" + FormatGo(t.Base)
+	case *SliceType:
+		return "[]" + FormatGo(t.ElementType)
+	case *ArrayType:
+		return fmt.Sprintf("[%d]%s", t.Len, FormatGo(t.ElementType))
+	case *MapType:
+		return fmt.Sprintf("map[%s]%s", FormatGo(t.KeyType), FormatGo(t.ValueType))
+	case *ChanType:
+		return formatChan(t)
+	case *TupleType:
+		return "(" + formatTypes(t.Types) + ")"
+	case *NoValueType:
+		return ""
+	case *NilType:
+		return "untyped nil"
+	case *PackageType:
+		return "package " + t.Name
+	case *VarObj, *ConstObj, *TypeObj, *FuncObj:
+		return FormatGo(unwrapObject(t))
+	case *FunctionType:
+		var results []Type
+		switch rt := t.ReturnType.(type) {
+		case nil:
+		case *TupleType:
+			results = rt.Types
+		default:
+			results = []Type{rt}
+		}
+		return "func" + formatSignature(t.ParamTypes, results, t.IsVariadic)
+	case Method:
+		return t.Name + formatSignature(t.Params, t.Results, t.IsVariadic)
+	case *GenericMethod:
+		return t.Name + formatSignature(t.Method.Params, t.Method.Results, t.Method.IsVariadic)
+	case *StructType:
+		if t.Name != "" {
+			return t.Name
+		}
+		return formatStruct(t.Fields, t.FieldOrder)
+	case *ExtensibleStruct:
+		return formatStruct(t.Fields, nil)
+	case *Interface:
+		return t.Name
+	case *InterfaceType:
+		switch {
+		case t.IsEmpty:
+			return "any"
+		case t.Name != "":
+			return t.Name
+		}
+		return formatInterface(t.Methods, t.Embedded)
+	case *GenericType:
+		return fmt.Sprintf("%s[%s]", t.Name, formatTypes(t.TypeParams))
+	case *ApproxType:
+		return "~" + FormatGo(t.Base)
+	case *TypeConstraint:
+		if t == nil {
+			return "any" // an unconstrained type parameter
+		}
+		return formatConstraint(t)
+	}
+	return t.String()
+}
+
+func formatTypes(types []Type) string {
+	ts := make([]string, len(types))
+	for i, t := range types {
+		ts[i] = FormatGo(t)
+	}
+	return strings.Join(ts, ", ")
+}
+
+// formatSignature formats the parameters and results of a function, like `(int, ...string) error`.
+// The last parameter of a variadic function is a slice, and is printed as `...T`.
+func formatSignature(params, results []Type, isVariadic bool) string {
+	ps := make([]string, len(params))
+	for i, p := range params {
+		ps[i] = FormatGo(p)
+		if slice, ok := p.(*SliceType); ok && isVariadic && i == len(params)-1 {
+			ps[i] = "..." + FormatGo(slice.ElementType)
+		}
+	}
+	return "(" + strings.Join(ps, ", ") + ")" + formatResults(results)
+}
+
+// formatResults formats the results of a function with a leading space, like ` (int, error)`.
+func formatResults(results []Type) string {
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		return " " + FormatGo(results[0])
+	}
+	return " (" + formatTypes(results) + ")"
+}
+
+func formatStruct(fields map[string]Type, order []string) string {
+	if len(fields) == 0 {
+		return "struct{}"
+	}
+	fs := make([]string, 0, len(fields))
+	if len(order) < len(fields) {
+		order = sortedKeys(fields)
+	}
+	// order may list fields that were left out, like unexported ones
+	for _, name := range order {
+		if t, ok := fields[name]; ok {
+			fs = append(fs, name+" "+FormatGo(t))
+		}
+	}
+	return "struct{ " + strings.Join(fs, "; ") + " }"
+}
+
+func formatInterface(methods MethodSet, embedded []Type) string {
+	var elems []string
+	for _, t := range embedded {
+		elems = append(elems, FormatGo(t))
+	}
+	for _, name := range sortedKeys(methods) {
+		elems = append(elems, FormatGo(methods[name]))
+	}
+	if len(elems) == 0 {
+		return "interface{}"
+	}
+	return "interface{ " + strings.Join(elems, "; ") + " }"
+}
+
+// formatConstraint formats a constraint the way it is written in a type parameter list,
+// like `comparable` or `~int | ~string`. Constraints combining several kinds of
+// requirements are spelled out as an interface.
+func formatConstraint(tc *TypeConstraint) string {
+	var elems []string
+	if tc.BuiltinConstraint != "" {
+		elems = append(elems, builtinConstraintSyntax[tc.BuiltinConstraint])
+	}
+	for _, iface := range tc.Interfaces {
+		if iface.Name != "" {
+			elems = append(elems, iface.Name)
+			continue
+		}
+		// the methods of an interface literal, like `interface{ String() string }`
+		for _, name := range sortedKeys(iface.Methods) {
+			elems = append(elems, FormatGo(iface.Methods[name]))
+		}
+	}
+	if len(tc.Types) > 0 {
+		terms := make([]string, len(tc.Types))
+		for i, t := range tc.Types {
+			terms[i] = FormatGo(t)
+		}
+		elems = append(elems, strings.Join(terms, " | "))
+	}
+	if tc.IsComparable && tc.BuiltinConstraint != ConstraintComparable {
+		elems = append(elems, "comparable")
+	}
+
+	var s string
+	switch len(elems) {
+	case 0:
+		s = "any"
+	case 1:
+		s = elems[0]
+	default:
+		s = "interface{ " + strings.Join(elems, "; ") + " }"
+	}
+	// excluded types have no Go syntax
+	if len(tc.Excluded) > 0 {
+		s += " /* except " + formatTypes(tc.Excluded) + " */"
+	}
+	return s
+}
+
+// formatTypeParams formats a type parameter list, like `[K comparable, V any]`.
+func formatTypeParams(params TypeParamList) string {
+	ps := make([]string, len(params))
+	for i, p := range params {
+		constraint := "any"
+		if p.Constraint != nil {
+			constraint = formatConstraint(p.Constraint)
+		}
+		ps[i] = params.name(i) + " " + constraint
+		if p.Default != nil {
+			ps[i] += " = " + FormatGo(p.Default)
+		}
+	}
+	return "[" + strings.Join(ps, ", ") + "]"
+}
+
+// formatChan formats a channel type. A receive-only element of a bidirectional channel
+// is parenthesized, since `chan <-chan int` would read as `chan<- chan int`.
+func formatChan(t *ChanType) string {
+	elem := FormatGo(t.ElementType)
+	switch t.Dir {
+	case SendOnly:
+		return "chan<- " + elem
+	case RecvOnly:
+		return "<-chan " + elem
+	}
+	if inner, ok := t.ElementType.(*ChanType); ok && inner.Dir == RecvOnly {
+		return "chan (" + elem + ")"
+	}
+	return "chan " + elem
+}
@@ -0,0 +1,71 @@
+package generic
+
+import "testing"
+
+func TestFormatType(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringType := &TypeConstant{Name: "string"}
+	errorType := &TypeConstant{Name: "error"}
+
+	tests := []struct {
+		name string
+		typ  Type
+		want string
+	}{
+		{name: "constant", typ: intType, want: "int"},
+		{name: "type variable", typ: &TypeVariable{Name: "T"}, want: "T"},
+		{name: "composite", typ: &MapType{KeyType: stringType, ValueType: &SliceType{ElementType: &PointerType{Base: intType}}}, want: "map[string][]*int"},
+		{name: "array", typ: &ArrayType{ElementType: intType, Len: 4}, want: "[4]int"},
+		{name: "function", typ: &FunctionType{ParamTypes: []Type{intType}, ReturnType: stringType}, want: "func(int) string"},
+		{
+			name: "variadic function with results",
+			typ: &FunctionType{
+				ParamTypes: []Type{stringType, &SliceType{ElementType: intType}},
+				ReturnType: &TupleType{Types: []Type{intType, errorType}},
+				IsVariadic: true,
+			},
+			want: "func(string, ...int) (int, error)",
+		},
+		{name: "function without results", typ: &FunctionType{}, want: "func()"},
+		{name: "named struct", typ: &StructType{Name: "Point", Fields: map[string]Type{"X": intType}}, want: "Point"},
+		{name: "anonymous struct", typ: &StructType{Fields: map[string]Type{"Y": intType, "X": intType}}, want: "struct{ X int; Y int }"},
+		{name: "empty interface", typ: &InterfaceType{IsEmpty: true}, want: "any"},
+		{
+			name: "anonymous interface",
+			typ:  &InterfaceType{Methods: MethodSet{"String": {Name: "String", Results: []Type{stringType}}}},
+			want: "interface{ String() string }",
+		},
+		{name: "generic instance", typ: &GenericType{Name: "Pair", TypeParams: []Type{stringType, intType}}, want: "Pair[string, int]"},
+		{name: "builtin constraint", typ: &TypeConstraint{BuiltinConstraint: ConstraintOrdered}, want: "cmp.Ordered"},
+		{
+			name: "union constraint",
+			typ:  &TypeConstraint{Types: []Type{&ApproxType{Base: intType}, stringType}, Union: true},
+			want: "~int | string",
+		},
+		{
+			name: "combined constraint",
+			typ:  &TypeConstraint{Types: []Type{intType, stringType}, IsComparable: true},
+			want: "interface{ int | string; comparable }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatGo(tt.typ); got != tt.want {
+				t.Errorf("FormatGo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTypeParams(t *testing.T) {
+	params := TypeParamList{
+		{Name: "K", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable, IsComparable: true}},
+		{Name: "V"},
+		{Name: "E", Default: &TypeConstant{Name: "error"}},
+	}
+	want := "[K comparable, V any, E any = error]"
+	if got := formatTypeParams(params); got != want {
+		t.Errorf("formatTypeParams() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loadMode is what LoadEnvFromPackage needs of the packages: their checked declarations.
+const loadMode = packages.NeedName | packages.NeedTypes | packages.NeedDeps | packages.NeedImports
+
+// LoadEnvFromPackage loads the packages matching pattern with go/packages, like "./..."
+// or "example.com/foo", so that expressions can be inferred against real code.
+//
+// The exported declarations of the packages, including generic types and functions and
+// constraints, are converted from go/types with FromGoObject and bound in the returned
+// environment along with the predeclared types. Each package is also bound to its name
+// as a PackageType, so that its members can be selected, like `containers.Map`.
+// A name declared by several packages is bound to the declaration of the first of them,
+// in the order of their import paths; the qualified names tell them apart.
+//
+// The errors of the packages, like syntax or type errors, are returned joined,
+// and no environment is built.
+func LoadEnvFromPackage(pattern string) (TypeEnv, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: loadMode}, pattern)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages match %s", pattern)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].PkgPath < pkgs[j].PkgPath })
+
+	env := universe()
+	for _, pkg := range pkgs {
+		members := goPackageMembers(pkg.Types)
+		for name, t := range members {
+			if _, ok := env[name]; !ok {
+				env[name] = t
+			}
+		}
+		if _, ok := env[pkg.Name]; !ok {
+			env[pkg.Name] = &PackageType{Path: pkg.PkgPath, Name: pkg.Name, Members: members}
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,40 @@
+package generic
+
+import (
+	"testing"
+)
+
+func TestLoadEnvFromPackage(t *testing.T) {
+	env, err := LoadEnvFromPackage("./examples/containers")
+	if err != nil {
+		t.Fatalf("LoadEnvFromPackage() error = %v", err)
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"Sum[int]([]int{1, 2})", "int"},
+		{"Map[int, string]", "Map[int, string]"},
+		{"containers.Reduce[int, float64]([]int{1}, 0.5, func(a float64, x int) float64 { return a })", "float64"},
+		{"&Stack[int]{}", "*Stack[int]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+
+	if _, ok := env["Number"].(*TypeObj); !ok {
+		t.Errorf("env[Number] = %T, want the constraint", env["Number"])
+	}
+	if _, err := LoadEnvFromPackage("./testdata/does-not-exist"); err == nil {
+		t.Errorf("LoadEnvFromPackage() of a missing directory = nil error")
+	}
+}
@@ -0,0 +1,960 @@
+package generic
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+)
+
+// FromGoType converts a type of the standard type checker, go/types, so that the
+// declarations of real packages, checked by go/types, can seed a TypeEnv.
+//
+// Types are converted like the declarations of BuildEnv: defined struct types become
+// StructType, defined interfaces a NamedType of an InterfaceType, and generic types and
+// functions GenericType, along with their methods. Interfaces with type elements, like
+// `~int | ~string`, become TypeConstraint, and type parameters TypeVariable. Names are
+// not qualified by their package. Types without a counterpart, like unsafe.Pointer or
+// generic types other than structs and interfaces, become a TypeConstant named after them.
+func FromGoType(t types.Type) Type {
+	c := &goTypeConverter{named: make(map[*types.Named]Type)}
+	return c.convert(t)
+}
+
+// FromGoObject converts a package-level object of go/types into the entry of a TypeEnv,
+// like BuildEnv declares it: a generic function is named after obj, constraints are
+// wrapped in a TypeObj, and variables and constants are VarObj and ConstObj.
+// It returns nil for the objects that are not declarations, like imported package names.
+func FromGoObject(obj types.Object) Type {
+	c := &goTypeConverter{named: make(map[*types.Named]Type)}
+	name := obj.Name()
+	switch obj := obj.(type) {
+	case *types.TypeName:
+		if obj.IsAlias() {
+			return &TypeAlias{Name: name, AliasedTo: c.convert(types.Unalias(obj.Type()))}
+		}
+		t := c.convert(obj.Type())
+		if tc, ok := t.(*TypeConstraint); ok {
+			return &TypeObj{Name: name, Type: tc}
+		}
+		return t
+	case *types.Func:
+		return c.signature(name, obj.Type().(*types.Signature))
+	case *types.Var:
+		return &VarObj{Name: name, Type: c.convert(obj.Type())}
+	case *types.Const:
+		basic, ok := obj.Type().(*types.Basic)
+		untyped := ok && basic.Info()&types.IsUntyped != 0
+		return &ConstObj{Name: name, Type: c.convert(obj.Type()), Val: obj.Val(), Untyped: untyped}
+	}
+	return nil
+}
+
+// GoImporter returns an Importer of the packages imported by imp, like the one of
+// go/importer.Default, converting their exported declarations with FromGoObject, so
+// that BuildEnvWithImporter can resolve the imports of real code, like "fmt".
+func GoImporter(imp types.Importer) Importer {
+	return func(path string) (*PackageType, error) {
+		pkg, err := imp.Import(path)
+		if err != nil {
+			return nil, err
+		}
+		return &PackageType{Path: pkg.Path(), Name: pkg.Name(), Members: goPackageMembers(pkg)}, nil
+	}
+}
+
+// goPackageMembers converts the exported package-level declarations of pkg.
+func goPackageMembers(pkg *types.Package) TypeEnv {
+	members := make(TypeEnv)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		if t := FromGoObject(obj); t != nil {
+			members[name] = t
+		}
+	}
+	return members
+}
+
+// goTypeConverter converts go/types types. Defined types are converted once, so that
+// recursive types, like a struct with a pointer to itself, refer to the same Type.
+type goTypeConverter struct {
+	named map[*types.Named]Type
+}
+
+func (c *goTypeConverter) convert(t types.Type) Type {
+	switch t := t.(type) {
+	case nil:
+		return nil
+	case *types.Basic:
+		return c.basic(t)
+	case *types.Alias:
+		return &TypeAlias{Name: t.Obj().Name(), AliasedTo: c.convert(types.Unalias(t))}
+	case *types.Named:
+		return c.namedType(t)
+	case *types.TypeParam:
+		return &TypeVariable{Name: t.Obj().Name()}
+	case *types.Pointer:
+		return &PointerType{Base: c.convert(t.Elem())}
+	case *types.Slice:
+		return &SliceType{ElementType: c.convert(t.Elem())}
+	case *types.Array:
+		return &ArrayType{ElementType: c.convert(t.Elem()), Len: int(t.Len())}
+	case *types.Map:
+		return &MapType{KeyType: c.convert(t.Key()), ValueType: c.convert(t.Elem())}
+	case *types.Chan:
+		return &ChanType{Dir: goChanDir(t.Dir()), ElementType: c.convert(t.Elem())}
+	case *types.Tuple:
+		if t.Len() == 0 {
+			return &NoValueType{} // the type of a call to a function without results
+		}
+		return &TupleType{Types: c.tuple(t)}
+	case *types.Signature:
+		return c.signature("", t)
+	case *types.Struct:
+		fields, order := c.fields(t)
+		return &StructType{Fields: fields, Methods: MethodSet{}, FieldOrder: order}
+	case *types.Interface:
+		if !t.IsMethodSet() {
+			return c.typeSet(t)
+		}
+		return c.iface("", t)
+	case *types.Union:
+		return &TypeConstraint{Types: c.terms(t)}
+	}
+	return &TypeConstant{Name: t.String()}
+}
+
+// basic converts a basic type to the predeclared type of the same name.
+// Untyped types are converted to their default type, like `untyped int` to int.
+func (c *goTypeConverter) basic(t *types.Basic) Type {
+	if t.Kind() == types.UntypedNil {
+		return Nil
+	}
+	if t.Info()&types.IsUntyped != 0 {
+		if def, ok := types.Default(t).(*types.Basic); ok {
+			t = def
+		}
+	}
+	if tc, ok := predeclared[t.Name()]; ok {
+		return tc
+	}
+	return &TypeConstant{Name: t.Name()}
+}
+
+func goChanDir(dir types.ChanDir) ChanDir {
+	switch dir {
+	case types.SendOnly:
+		return SendOnly
+	case types.RecvOnly:
+		return RecvOnly
+	}
+	return SendRecv
+}
+
+// namedType converts a defined type. The type is recorded before its underlying type and
+// methods are converted, since they may refer to it.
+func (c *goTypeConverter) namedType(t *types.Named) Type {
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		switch obj.Name() {
+		case "error":
+			return Error
+		case ConstraintComparable:
+			return &TypeConstraint{BuiltinConstraint: ConstraintComparable}
+		}
+	}
+	if converted, ok := c.named[t]; ok {
+		return converted
+	}
+	if t.TypeArgs().Len() > 0 {
+		return c.instance(t)
+	}
+
+	name := obj.Name()
+	if iface, ok := t.Underlying().(*types.Interface); ok && !iface.IsMethodSet() {
+		tc := c.typeSet(iface)
+		c.named[t] = tc
+		return tc
+	}
+	if t.TypeParams().Len() > 0 {
+		return c.genericType(t)
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		st := &StructType{Name: name, Methods: MethodSet{}}
+		c.named[t] = st
+		st.Fields, st.FieldOrder = c.fields(u)
+		c.methods(t, st, st.Methods)
+		return st
+	case *types.Interface:
+		nt := &NamedType{Name: name, Methods: MethodSet{}}
+		c.named[t] = nt
+		nt.Underlying = c.iface(name, u)
+		return nt
+	}
+	nt := &NamedType{Name: name, Methods: MethodSet{}}
+	c.named[t] = nt
+	nt.Underlying = c.convert(t.Underlying())
+	c.methods(t, nt, nt.Methods)
+	return nt
+}
+
+// genericType converts a generic type declaration: a struct, an interface, or a type
+// defined by another type, like `type Set[K comparable] map[K]struct{}`.
+func (c *goTypeConverter) genericType(t *types.Named) Type {
+	gt := NewGenericType(t.Obj().Name(), nil, map[string]Type{}, MethodSet{})
+	c.named[t] = gt
+	params := c.typeParams(t.TypeParams())
+	gt.TypeParams, gt.Params = params.Vars(), params
+	gt.compileConstraints()
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		gt.Fields, gt.FieldOrder = c.fields(u)
+		c.methods(t, gt, gt.Methods)
+	case *types.Interface:
+		gt.IsInterface = true
+		gt.Methods = c.iface(gt.Name, u).Methods
+	default:
+		gt.UnderlyingType = c.convert(u)
+		c.methods(t, gt, gt.Methods)
+	}
+	return gt
+}
+
+// instance converts an instantiated generic type, like `List[int]`. Within the declaration
+// of the generic type, its instance with its own type parameters, like the receiver
+// `List[T]`, denotes the declaration itself.
+func (c *goTypeConverter) instance(t *types.Named) Type {
+	origin := c.convert(t.Origin())
+	gt, ok := origin.(*GenericType)
+	if !ok {
+		return origin
+	}
+	args := make([]interface{}, t.TypeArgs().Len())
+	own := len(args) == len(gt.TypeParams)
+	for i := range args {
+		arg := c.convert(t.TypeArgs().At(i))
+		args[i] = arg
+		if own && !TypesEqual(arg, gt.TypeParams[i]) {
+			own = false
+		}
+	}
+	if own {
+		return gt
+	}
+	instance, err := InstantiateGenericType(gt, args, TypeEnv{}, nil)
+	if err != nil {
+		// the type arguments are checked by go/types, but not all constraints are modeled,
+		// so the instance is left a defined type with the underlying type of go/types
+		nt := &NamedType{Name: types.TypeString(t, func(*types.Package) string { return "" }), Methods: MethodSet{}}
+		c.named[t] = nt
+		nt.Underlying = c.convert(t.Underlying())
+		return nt
+	}
+	c.named[t] = instance
+	return instance
+}
+
+// fields converts the fields of a struct type, in declaration order.
+// Embedded fields are named after their type, like in declaredFields.
+func (c *goTypeConverter) fields(st *types.Struct) (map[string]Type, []string) {
+	fields := make(map[string]Type, st.NumFields())
+	order := make([]string, 0, st.NumFields())
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		fields[f.Name()] = c.convert(f.Type())
+		order = append(order, f.Name())
+	}
+	return fields, order
+}
+
+// methods adds the declared methods of t to ms, with recv as their receiver.
+func (c *goTypeConverter) methods(t *types.Named, recv Type, ms MethodSet) {
+	for i := 0; i < t.NumMethods(); i++ {
+		fn := t.Method(i)
+		sig := fn.Type().(*types.Signature)
+		m := c.method(fn.Name(), sig)
+		m.Receiver = recv
+		if sig.Recv() != nil {
+			_, m.IsPointer = sig.Recv().Type().(*types.Pointer)
+		}
+		ms[fn.Name()] = m
+	}
+}
+
+func (c *goTypeConverter) method(name string, sig *types.Signature) Method {
+	return Method{
+		Name:       name,
+		Params:     c.tuple(sig.Params()),
+		Results:    c.tuple(sig.Results()),
+		IsVariadic: sig.Variadic(),
+	}
+}
+
+// iface converts an interface that is a method set. Embedded interfaces are flattened:
+// Methods holds the whole method set.
+func (c *goTypeConverter) iface(name string, it *types.Interface) *InterfaceType {
+	conv := &InterfaceType{Name: name, Methods: MethodSet{}, IsEmpty: it.Empty()}
+	for i := 0; i < it.NumMethods(); i++ {
+		fn := it.Method(i)
+		conv.Methods[fn.Name()] = c.method(fn.Name(), fn.Type().(*types.Signature))
+	}
+	return conv
+}
+
+func (c *goTypeConverter) tuple(t *types.Tuple) []Type {
+	if t.Len() == 0 {
+		return nil
+	}
+	ts := make([]Type, t.Len())
+	for i := range ts {
+		ts[i] = c.convert(t.At(i).Type())
+	}
+	return ts
+}
+
+// signature converts a function signature, which is a generic function named name
+// if it has type parameters.
+func (c *goTypeConverter) signature(name string, sig *types.Signature) Type {
+	m := c.method(name, sig)
+	ft := &FunctionType{ParamTypes: m.Params, IsVariadic: m.IsVariadic}
+	if len(m.Results) > 0 {
+		ft.ReturnType = resultsType(m.Results)
+	}
+	if sig.TypeParams().Len() == 0 {
+		return ft
+	}
+	return NewGenericFunction(name, c.typeParams(sig.TypeParams()), ft)
+}
+
+func (c *goTypeConverter) typeParams(list *types.TypeParamList) TypeParamList {
+	params := make(TypeParamList, list.Len())
+	for i := range params {
+		tp := list.At(i)
+		params[i] = TypeParam{Name: tp.Obj().Name(), Constraint: c.constraint(tp.Constraint())}
+	}
+	return params
+}
+
+// constraint converts the constraint of a type parameter, like constraintOf:
+// `any`, `comparable`, an interface that is a method set, or a type set.
+func (c *goTypeConverter) constraint(t types.Type) *TypeConstraint {
+	if named, ok := types.Unalias(t).(*types.Named); ok {
+		if tc, ok := c.namedType(named).(*TypeConstraint); ok {
+			return tc
+		}
+		if iface, ok := named.Underlying().(*types.Interface); ok && !iface.Empty() {
+			return &TypeConstraint{Interfaces: []Interface{{Name: named.Obj().Name(), Methods: c.iface(named.Obj().Name(), iface).Methods}}}
+		}
+	}
+	iface, ok := t.Underlying().(*types.Interface)
+	switch {
+	case !ok:
+		return &TypeConstraint{Types: []Type{c.convert(t)}}
+	case iface.Empty():
+		return Any
+	case iface.IsMethodSet():
+		return &TypeConstraint{Interfaces: []Interface{{Methods: c.iface("", iface).Methods}}}
+	}
+	return c.typeSet(iface)
+}
+
+// typeSet converts an interface with type elements, like interfaceConstraint: its methods
+// become an Interface, `comparable` the builtin constraint, and the union of the other
+// elements its Types. Embedded constraints, like `constraints.Integer`, are flattened.
+func (c *goTypeConverter) typeSet(it *types.Interface) *TypeConstraint {
+	tc := &TypeConstraint{}
+	if it.NumMethods() > 0 {
+		tc.Interfaces = []Interface{{Methods: c.iface("", it).Methods}}
+	}
+	for i := 0; i < it.NumEmbeddeds(); i++ {
+		switch e := c.convert(it.EmbeddedType(i)).(type) {
+		case *TypeConstraint:
+			if e.BuiltinConstraint != "" {
+				tc.BuiltinConstraint = e.BuiltinConstraint
+			}
+			tc.Types = append(tc.Types, e.Types...)
+		case *NamedType:
+			if _, ok := e.Underlying.(*InterfaceType); !ok {
+				tc.Types = append(tc.Types, e)
+			}
+		case *InterfaceType:
+			// its methods are part of the method set of it
+		default:
+			tc.Types = append(tc.Types, e)
+		}
+	}
+	return tc
+}
+
+// terms converts the terms of a union, like `~int | string`.
+func (c *goTypeConverter) terms(u *types.Union) []Type {
+	terms := make([]Type, u.Len())
+	for i := range terms {
+		term := u.Term(i)
+		terms[i] = c.convert(term.Type())
+		if term.Tilde() {
+			terms[i] = &ApproxType{Base: terms[i]}
+		}
+	}
+	return terms
+}
+
+// ToGoType converts t to a type of go/types, the reverse of FromGoType, so that the types
+// inferred by this package can be passed to tools built on go/types.
+//
+// Defined types, like NamedType, named StructType and InterfaceType and generic declarations,
+// become types.Named declared in pkg, which may be nil. They are not inserted in its scope,
+// but those already declared in it, like in a package checked by go/types, are looked up,
+// so that the converted types are identical to those of go/types.
+// Instantiated generic types are instances of their declaration, constraints are interfaces
+// with a type set, and type variables outside of a generic declaration are type parameters
+// constrained by any. Objects are converted to their type, like in FormatGo.
+// The effects of functions and the variants of sum types have no Go equivalent and are
+// dropped; types without one, like ExtensibleStruct, generic methods, constraints with
+// excluded types and packages, are reported as errors.
+func ToGoType(t Type, pkg *types.Package) (types.Type, error) {
+	e := &goTypeExporter{
+		pkg:   pkg,
+		named: make(map[Type]*types.Named),
+		open:  make(map[*GenericType]bool),
+		scope: make(map[string]*types.TypeParam),
+		free:  make(map[string]*types.TypeParam),
+	}
+	gt, err := e.export(t)
+	if err != nil {
+		return nil, err
+	}
+	// interfaces are completed once the types they embed are defined
+	for _, iface := range e.ifaces {
+		iface.Complete()
+	}
+	return gt, nil
+}
+
+// goTypeExporter converts types to go/types. Defined types are converted once, so that
+// recursive types refer to the same types.Named. Type variables are resolved in scope,
+// the type parameters of the generic declaration being converted.
+type goTypeExporter struct {
+	pkg    *types.Package
+	named  map[Type]*types.Named
+	open   map[*GenericType]bool // generic declarations whose body is being converted
+	scope  map[string]*types.TypeParam
+	free   map[string]*types.TypeParam // type variables outside of any declaration
+	ifaces []*types.Interface
+}
+
+func (e *goTypeExporter) export(t Type) (types.Type, error) {
+	switch t := t.(type) {
+	case nil:
+		return nil, fmt.Errorf("to go type: missing type")
+	case *TypeConstant:
+		return goBasic(t.Name)
+	case *TypeVariable:
+		return e.typeVar(t.Name), nil
+	case *TypeAlias:
+		aliased, err := e.export(t.AliasedTo)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewAlias(e.typeName(t.Name), aliased), nil
+	case *NamedType:
+		return e.namedType(t)
+	case *PointerType:
+		base, err := e.export(t.Base)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(base), nil
+	case *SliceType:
+		elem, err := e.export(t.ElementType)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewSlice(elem), nil
+	case *ArrayType:
+		elem, err := e.export(t.ElementType)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewArray(elem, int64(t.Len)), nil
+	case *MapType:
+		key, err := e.export(t.KeyType)
+		if err != nil {
+			return nil, err
+		}
+		value, err := e.export(t.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewMap(key, value), nil
+	case *ChanType:
+		elem, err := e.export(t.ElementType)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewChan(goTypesChanDir(t.Dir), elem), nil
+	case *TupleType:
+		return e.tuple(t.Types)
+	case *NoValueType:
+		return types.NewTuple(), nil
+	case *NilType:
+		return types.Typ[types.UntypedNil], nil
+	case *FunctionType:
+		return e.signature(nil, nil, nil, t.ParamTypes, funcResults(t), t.IsVariadic)
+	case Method:
+		return e.signature(nil, nil, nil, t.Params, t.Results, t.IsVariadic)
+	case *StructType:
+		return e.structType(t)
+	case *InterfaceType:
+		return e.ifaceType(t)
+	case *Interface:
+		return e.ifaceType(&InterfaceType{Name: t.Name, Methods: t.Methods})
+	case *TypeConstraint:
+		return e.constraint(t)
+	case *ApproxType:
+		// on its own, `~T` is the constraint of the types whose underlying type is T
+		return e.constraint(&TypeConstraint{Types: []Type{t}})
+	case *GenericType:
+		if t.origin != nil {
+			return e.instance(t.origin)
+		}
+		return e.genericType(t)
+	case *VarObj, *ConstObj, *TypeObj, *FuncObj:
+		return e.export(unwrapObject(t))
+	}
+	return nil, fmt.Errorf("to go type: %s has no go/types equivalent", FormatGo(t))
+}
+
+// goBasic returns the predeclared type named name, like int or error.
+func goBasic(name string) (types.Type, error) {
+	if name == "unsafe.Pointer" {
+		return types.Typ[types.UnsafePointer], nil
+	}
+	if obj, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return obj.Type(), nil
+	}
+	return nil, fmt.Errorf("to go type: unknown type %s", name)
+}
+
+func goTypesChanDir(dir ChanDir) types.ChanDir {
+	switch dir {
+	case SendOnly:
+		return types.SendOnly
+	case RecvOnly:
+		return types.RecvOnly
+	}
+	return types.SendRecv
+}
+
+// funcResults returns the result types of ft, whose ReturnType is a TupleType if it has several.
+func funcResults(ft *FunctionType) []Type {
+	switch rt := ft.ReturnType.(type) {
+	case nil, *NoValueType:
+		return nil
+	case *TupleType:
+		return rt.Types
+	}
+	return []Type{ft.ReturnType}
+}
+
+func (e *goTypeExporter) typeName(name string) *types.TypeName {
+	return types.NewTypeName(token.NoPos, e.pkg, name, nil)
+}
+
+// typeVar returns the type parameter named name in scope, or the type parameter of the free
+// type variable name. Free type variables are shared by name, so that `func(T) T` has one.
+func (e *goTypeExporter) typeVar(name string) types.Type {
+	if tp, ok := e.scope[name]; ok {
+		return tp
+	}
+	if tp, ok := e.free[name]; ok {
+		return tp
+	}
+	tp := types.NewTypeParam(e.typeName(baseName(name)), e.emptyInterface())
+	e.free[name] = tp
+	return tp
+}
+
+func (e *goTypeExporter) emptyInterface() *types.Interface {
+	return e.newInterface(nil, nil)
+}
+
+func (e *goTypeExporter) newInterface(methods []*types.Func, embedded []types.Type) *types.Interface {
+	iface := types.NewInterfaceType(methods, embedded)
+	e.ifaces = append(e.ifaces, iface)
+	return iface
+}
+
+// declare returns the types.Named of the defined type t, and whether it is converted
+// already. The underlying type and methods of a new one are set by the caller.
+// A type declared in the scope of the package is that declaration.
+func (e *goTypeExporter) declare(t Type, name string) (*types.Named, bool) {
+	if named, ok := e.named[t]; ok {
+		return named, true
+	}
+	if e.pkg != nil {
+		if obj, ok := e.pkg.Scope().Lookup(name).(*types.TypeName); ok {
+			if named, ok := obj.Type().(*types.Named); ok {
+				e.named[t] = named
+				return named, true
+			}
+		}
+	}
+	named := types.NewNamed(e.typeName(name), nil, nil)
+	e.named[t] = named
+	return named, false
+}
+
+func (e *goTypeExporter) namedType(t *NamedType) (types.Type, error) {
+	named, done := e.declare(t, t.Name)
+	if done {
+		return named, nil
+	}
+	underlying, err := e.export(t.Underlying)
+	if err != nil {
+		return nil, err
+	}
+	named.SetUnderlying(underlying.Underlying())
+	return named, e.methods(named, nil, t.Methods)
+}
+
+func (e *goTypeExporter) structType(t *StructType) (types.Type, error) {
+	if len(t.GenericMethods) > 0 {
+		return nil, fmt.Errorf("to go type: struct %s has generic methods", FormatGo(t))
+	}
+	if t.Name == "" {
+		return e.fields(t.Fields, t.FieldOrder)
+	}
+	named, done := e.declare(t, t.Name)
+	if done {
+		return named, nil
+	}
+	st, err := e.fields(t.Fields, t.FieldOrder)
+	if err != nil {
+		return nil, err
+	}
+	named.SetUnderlying(st)
+	return named, e.methods(named, nil, t.Methods)
+}
+
+// fields converts the fields of a struct in declaration order. None of them is embedded.
+func (e *goTypeExporter) fields(fields map[string]Type, order []string) (*types.Struct, error) {
+	names := fieldNames(fields, order)
+	vars := make([]*types.Var, len(names))
+	for i, name := range names {
+		ft, err := e.export(fields[name])
+		if err != nil {
+			return nil, err
+		}
+		vars[i] = types.NewField(token.NoPos, e.pkg, name, ft, false)
+	}
+	return types.NewStruct(vars, nil), nil
+}
+
+// methods declares the methods in ms on named. The methods of a generic type have their own
+// type parameters, recvParams, like `T` in `func (l *List[T]) Push(v T)`.
+func (e *goTypeExporter) methods(named *types.Named, recvParams TypeParamList, ms MethodSet) error {
+	for _, name := range sortedKeys(ms) {
+		m := ms[name]
+		var recvType types.Type = named
+		var tparams []*types.TypeParam
+		restore := func() {}
+		if len(recvParams) > 0 {
+			var err error
+			tparams, restore, err = e.typeParams(recvParams)
+			if err != nil {
+				return err
+			}
+			args := make([]types.Type, len(tparams))
+			for i, tp := range tparams {
+				args[i] = tp
+			}
+			if recvType, err = types.Instantiate(nil, named, args, false); err != nil {
+				restore()
+				return err
+			}
+		}
+		if m.IsPointer {
+			recvType = types.NewPointer(recvType)
+		}
+		recv := types.NewParam(token.NoPos, e.pkg, "", recvType)
+		sig, err := e.signature(recv, tparams, nil, m.Params, m.Results, m.IsVariadic)
+		restore()
+		if err != nil {
+			return err
+		}
+		named.AddMethod(types.NewFunc(token.NoPos, e.pkg, name, sig))
+	}
+	return nil
+}
+
+func (e *goTypeExporter) ifaceType(t *InterfaceType) (types.Type, error) {
+	if t.IsEmpty {
+		return e.emptyInterface(), nil
+	}
+	if len(t.GenericMethods) > 0 {
+		return nil, fmt.Errorf("to go type: interface %s has generic methods", FormatGo(t))
+	}
+	if t.Name == "" {
+		return e.iface(t.Methods, t.Embedded)
+	}
+	named, done := e.declare(t, t.Name)
+	if done {
+		return named, nil
+	}
+	iface, err := e.iface(t.Methods, t.Embedded)
+	if err != nil {
+		return nil, err
+	}
+	named.SetUnderlying(iface)
+	return named, nil
+}
+
+func (e *goTypeExporter) iface(ms MethodSet, embedded []Type) (*types.Interface, error) {
+	methods, err := e.ifaceMethods(ms)
+	if err != nil {
+		return nil, err
+	}
+	embeddeds := make([]types.Type, len(embedded))
+	for i, t := range embedded {
+		if embeddeds[i], err = e.export(t); err != nil {
+			return nil, err
+		}
+	}
+	return e.newInterface(methods, embeddeds), nil
+}
+
+func (e *goTypeExporter) ifaceMethods(ms MethodSet) ([]*types.Func, error) {
+	methods := make([]*types.Func, 0, len(ms))
+	for _, name := range sortedKeys(ms) {
+		m := ms[name]
+		sig, err := e.signature(nil, nil, nil, m.Params, m.Results, m.IsVariadic)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, types.NewFunc(token.NoPos, e.pkg, name, sig))
+	}
+	return methods, nil
+}
+
+func (e *goTypeExporter) tuple(ts []Type) (*types.Tuple, error) {
+	vars := make([]*types.Var, len(ts))
+	for i, t := range ts {
+		vt, err := e.export(t)
+		if err != nil {
+			return nil, err
+		}
+		vars[i] = types.NewParam(token.NoPos, e.pkg, "", vt)
+	}
+	return types.NewTuple(vars...), nil
+}
+
+func (e *goTypeExporter) signature(recv *types.Var, recvParams, typeParams []*types.TypeParam, params, results []Type, variadic bool) (*types.Signature, error) {
+	ps, err := e.tuple(params)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := e.tuple(results)
+	if err != nil {
+		return nil, err
+	}
+	if variadic {
+		if ps.Len() == 0 {
+			return nil, fmt.Errorf("to go type: variadic function without parameters")
+		}
+		if _, ok := ps.At(ps.Len() - 1).Type().Underlying().(*types.Slice); !ok {
+			return nil, fmt.Errorf("to go type: variadic parameter %s is not a slice", FormatGo(params[len(params)-1]))
+		}
+	}
+	return types.NewSignatureType(recv, recvParams, typeParams, ps, rs, variadic), nil
+}
+
+// typeParams creates the type parameters of params and brings them into scope, so that
+// their constraints, and the declaration they belong to, can refer to them.
+// The returned function restores the enclosing scope.
+func (e *goTypeExporter) typeParams(params TypeParamList) ([]*types.TypeParam, func(), error) {
+	saved := e.scope
+	e.scope = make(map[string]*types.TypeParam, len(saved)+len(params))
+	for name, tp := range saved {
+		e.scope[name] = tp
+	}
+	restore := func() { e.scope = saved }
+
+	tparams := make([]*types.TypeParam, len(params))
+	for i := range params {
+		tparams[i] = types.NewTypeParam(e.typeName(params.name(i)), nil)
+		e.scope[params.name(i)] = tparams[i]
+	}
+	for i, p := range params {
+		constraint := types.Universe.Lookup(ConstraintAny).Type()
+		if p.Constraint != nil {
+			var err error
+			if constraint, err = e.constraint(p.Constraint); err != nil {
+				restore()
+				return nil, nil, err
+			}
+		}
+		tparams[i].SetConstraint(constraint)
+	}
+	return tparams, restore, nil
+}
+
+// constraint converts a constraint to an interface, like `interface{ comparable; ~int | ~string }`.
+// A constraint that is only a named interface, any or comparable, is that interface.
+// Builtin constraints other than any and comparable are spelled out as their type set.
+func (e *goTypeExporter) constraint(tc *TypeConstraint) (types.Type, error) {
+	if len(tc.Excluded) > 0 {
+		return nil, fmt.Errorf("to go type: constraint %s has excluded types", FormatGo(tc))
+	}
+	norm := NormalizeConstraint(*tc)
+
+	var embeddeds []types.Type
+	var methods []*types.Func
+	comparable := norm.IsComparable
+	switch norm.BuiltinConstraint {
+	case "", ConstraintAny:
+	case ConstraintComparable:
+		comparable = true
+	default:
+		set := builtinTypeSet(norm.BuiltinConstraint)
+		if set == nil {
+			return nil, fmt.Errorf("to go type: unknown constraint %s", norm.BuiltinConstraint)
+		}
+		terms := make([]*types.Term, 0, len(set))
+		for _, name := range sortedKeys(set) {
+			basic, err := goBasic(name)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, types.NewTerm(true, basic))
+		}
+		embeddeds = append(embeddeds, types.NewUnion(terms))
+	}
+	if comparable {
+		embeddeds = append(embeddeds, types.Universe.Lookup(ConstraintComparable).Type())
+	}
+	for i := range norm.Interfaces {
+		iface := &norm.Interfaces[i]
+		if iface.Name == "" {
+			ms, err := e.ifaceMethods(iface.Methods)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, ms...)
+			continue
+		}
+		it, err := e.ifaceType(&InterfaceType{Name: iface.Name, Methods: iface.Methods})
+		if err != nil {
+			return nil, err
+		}
+		embeddeds = append(embeddeds, it)
+	}
+	if len(norm.Types) > 0 {
+		terms := make([]*types.Term, len(norm.Types))
+		for i, t := range norm.Types {
+			tilde := false
+			if at, ok := t.(*ApproxType); ok {
+				t, tilde = at.Base, true
+			}
+			term, err := e.export(t)
+			if err != nil {
+				return nil, err
+			}
+			terms[i] = types.NewTerm(tilde, term)
+		}
+		embeddeds = append(embeddeds, types.NewUnion(terms))
+	}
+
+	if len(methods) == 0 {
+		switch len(embeddeds) {
+		case 0:
+			return types.Universe.Lookup(ConstraintAny).Type(), nil
+		case 1:
+			if named, ok := embeddeds[0].(*types.Named); ok {
+				return named, nil
+			}
+		}
+	}
+	return e.newInterface(methods, embeddeds), nil
+}
+
+// genericType converts a generic declaration: a generic function to a signature with type
+// parameters, and a generic struct or interface to a types.Named with type parameters.
+// Within its declaration, the generic type refers to its instance with its own type
+// parameters, like `*List[T]` in the fields of `List[T]`.
+func (e *goTypeExporter) genericType(gt *GenericType) (types.Type, error) {
+	params := gt.TypeParamList()
+	if gt.Signature != nil {
+		tparams, restore, err := e.typeParams(params)
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+		sig := gt.Signature
+		return e.signature(nil, nil, tparams, sig.ParamTypes, funcResults(sig), sig.IsVariadic)
+	}
+
+	named, done := e.declare(gt, gt.Name)
+	if done {
+		if !e.open[gt] {
+			return named, nil
+		}
+		args := make([]types.Type, len(params))
+		for i := range params {
+			args[i] = e.typeVar(params.name(i))
+		}
+		return types.Instantiate(nil, named, args, false)
+	}
+	tparams, restore, err := e.typeParams(params)
+	if err != nil {
+		return nil, err
+	}
+	named.SetTypeParams(tparams)
+	e.open[gt] = true
+	defer delete(e.open, gt)
+
+	var underlying types.Type
+	switch {
+	case gt.IsInterface:
+		underlying, err = e.iface(gt.Methods, nil)
+	case gt.UnderlyingType != nil:
+		if underlying, err = e.export(gt.UnderlyingType); err == nil {
+			underlying = underlying.Underlying()
+		}
+	default:
+		underlying, err = e.fields(gt.Fields, gt.FieldOrder)
+	}
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	named.SetUnderlying(underlying)
+	if gt.IsInterface {
+		return named, nil
+	}
+	return named, e.methods(named, params, gt.Methods)
+}
+
+// instance converts an instantiated generic type to the instance of its declaration.
+func (e *goTypeExporter) instance(in *Instantiation) (types.Type, error) {
+	var decl types.Type
+	var err error
+	if named, ok := e.named[in.Decl]; ok && e.open[in.Decl] {
+		decl = named // within the declaration
+	} else if decl, err = e.export(in.Decl); err != nil {
+		return nil, err
+	}
+	args := make([]types.Type, len(in.Args))
+	for i, arg := range in.Args {
+		if args[i], err = e.export(arg); err != nil {
+			return nil, err
+		}
+	}
+	return types.Instantiate(nil, decl, args, false)
+}
@@ -0,0 +1,359 @@
+package generic
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const goTypesSrc = `package p
+
+type Ordered interface {
+	~int | ~string
+}
+
+type Number interface {
+	Ordered
+	comparable
+}
+
+type Stringer interface {
+	String() string
+}
+
+type Age int
+
+func (a Age) Years() int
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Scale(k int)
+
+type Node struct {
+	Value int
+	Next  *Node
+}
+
+type List[T any] struct {
+	items []T
+	next  *List[T]
+}
+
+func (l *List[T]) Push(v T) { l.items = append(l.items, v) }
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+type Set[K comparable] map[K]struct{}
+
+func (s Set[K]) Has(k K) bool { _, ok := s[k]; return ok }
+
+func Max[T Ordered](a, b T) T { return a }
+
+func Join[S Stringer](xs []S, sep string) string { return sep }
+
+func Split(s string, n int) ([]string, error)
+
+func Sum(xs ...int) int
+
+func Send(ch chan<- int, m map[string][4]byte)
+
+var Ints List[int]
+
+var Names Set[string]
+
+const Pi = 3.14
+`
+
+func checkGoTypes(t *testing.T, src string) (*types.Package, TypeEnv) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	pkg, err := (&types.Config{}).Check("p", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	return pkg, env
+}
+
+func TestFromGoType(t *testing.T) {
+	pkg, env := checkGoTypes(t, goTypesSrc)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Ordered", "~int | ~string"},
+		{"Number", "interface{ comparable; ~int | ~string }"},
+		{"Stringer", "Stringer"},
+		{"Age", "Age"},
+		{"Point", "Point"},
+		{"Node", "Node"},
+		{"List", "List[T]"},
+		{"Pair", "Pair[K, V]"},
+		{"Set", "Set[K]"},
+		{"Names", "Set[string]"},
+		{"Split", "func(string, int) ([]string, error)"},
+		{"Sum", "func(...int) int"},
+		{"Send", "func(chan<- int, map[string][4]uint8)"},
+		{"Ints", "List[int]"},
+		{"Pi", "float64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromGoType(pkg.Scope().Lookup(tt.name).Type())
+			if FormatGo(got) != tt.want {
+				t.Errorf("FromGoType(%s) = %s, want %s", tt.name, FormatGo(got), tt.want)
+			}
+		})
+	}
+
+	// the declarations match those of BuildEnv
+	for _, name := range pkg.Scope().Names() {
+		t.Run(name+" like BuildEnv", func(t *testing.T) {
+			obj := FromGoObject(pkg.Scope().Lookup(name))
+			if reflect.TypeOf(obj) != reflect.TypeOf(env[name]) {
+				t.Errorf("FromGoObject(%s) = %T, want %T", name, obj, env[name])
+			}
+			got := strings.Join(apiDecls(name, unwrapObject(obj)), "\n")
+			want := strings.Join(apiDecls(name, unwrapObject(env[name])), "\n")
+			if got != want {
+				t.Errorf("FromGoType(%s) declares\n%s\nwant\n%s", name, got, want)
+			}
+		})
+	}
+}
+
+func TestFromGoObjectSeedsEnv(t *testing.T) {
+	pkg, _ := checkGoTypes(t, goTypesSrc)
+	env := universe()
+	for _, name := range pkg.Scope().Names() {
+		env[name] = FromGoObject(pkg.Scope().Lookup(name))
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"Max[int](1, 2)", "int"},
+		{`Split("a,b", 2)`, "([]string, error)"},
+		{"Ints", "List[int]"},
+		{`Names["a"]`, "struct{}"},
+		{`Names.Has("a")`, "bool"},
+		{"Pi * 2", "float64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+
+	node := FromGoType(pkg.Scope().Lookup("Node").Type()).(*StructType)
+	if next := node.Fields["Next"].(*PointerType).Base; next != Type(node) {
+		t.Errorf("Node.Next = *%p, want a pointer to Node %p", next, node)
+	}
+}
+
+func TestGoImporter(t *testing.T) {
+	src := `package p
+
+import (
+	"fmt"
+	str "strconv"
+	"unicode/utf8"
+)
+
+var s = str.Itoa(1)
+
+var (
+	buf [utf8.UTFMax]byte
+	r   rune
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnvWithImporter([]*ast.File{file}, GoImporter(importer.Default()))
+	if err != nil {
+		t.Fatalf("BuildEnvWithImporter() error = %v", err)
+	}
+	if got := FormatGo(env["s"]); got != "string" {
+		t.Errorf("env[s] = %s, want string", got)
+	}
+	got, err := InferType(mustParseExpr(t, `fmt.Sprintf("%d: %s", 1, s)`), env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	if FormatGo(got) != "string" {
+		t.Errorf("InferType() = %s, want string", FormatGo(got))
+	}
+	if got := FormatGo(env["buf"]); got != "[4]uint8" {
+		t.Errorf("env[buf] = %s, want [4]uint8", got)
+	}
+	if _, err := InferType(mustParseExpr(t, "r < utf8.RuneSelf"), env, nil); err != nil {
+		t.Errorf("InferType() error = %v", err)
+	}
+
+	if _, err := GoImporter(importer.Default())("example.com/missing"); err == nil {
+		t.Error("GoImporter() of a missing package succeeded")
+	}
+}
+
+func TestToGoType(t *testing.T) {
+	pkg := types.NewPackage("example.com/p", "p")
+	point := &StructType{Name: "Point", Fields: map[string]Type{"X": Int, "Y": Int}, FieldOrder: []string{"X", "Y"}}
+	age := &NamedType{Name: "Age", Underlying: Int, Methods: MethodSet{"Years": {Name: "Years", Results: []Type{Int}}}}
+	stringer := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{String}}}}
+	tv := &TypeVariable{Name: "T"}
+	list := NewGenericType("List", TypeParamList{{Name: "T", Constraint: Any}}, map[string]Type{"items": &SliceType{ElementType: tv}}, MethodSet{})
+	listOfInt, err := InstantiateGenericType(list, []interface{}{Int}, TypeEnv{}, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+	mapFn := NewGenericFunction("Map", TypeParamList{{Name: "T"}, {Name: "U", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}}}, &FunctionType{
+		ParamTypes: []Type{&SliceType{ElementType: tv}, &FunctionType{ParamTypes: []Type{tv}, ReturnType: &TypeVariable{Name: "U"}}},
+		ReturnType: &SliceType{ElementType: &TypeVariable{Name: "U"}},
+	})
+
+	tests := []struct {
+		name string
+		typ  Type
+		want string // in Go syntax, relative to pkg
+	}{
+		{"basic", Int, "int"},
+		{"byte", Byte, "uint8"},
+		{"error", Error, "error"},
+		{"type variable", tv, "T"},
+		{"alias", &TypeAlias{Name: "IntSlice", AliasedTo: &SliceType{ElementType: Int}}, "IntSlice"},
+		{"named", age, "Age"},
+		{"pointer", &PointerType{Base: String}, "*string"},
+		{"slice", &SliceType{ElementType: Float64}, "[]float64"},
+		{"array", &ArrayType{ElementType: Byte, Len: 4}, "[4]uint8"},
+		{"map", &MapType{KeyType: String, ValueType: Bool}, "map[string]bool"},
+		{"send channel", &ChanType{Dir: SendOnly, ElementType: Int}, "chan<- int"},
+		{"receive channel", &ChanType{Dir: RecvOnly, ElementType: Int}, "<-chan int"},
+		{"tuple", &TupleType{Types: []Type{Int, Error}}, "(int, error)"},
+		{"no value", &NoValueType{}, "()"},
+		{"function", &FunctionType{ParamTypes: []Type{String, &SliceType{ElementType: Int}}, ReturnType: &TupleType{Types: []Type{Int, Error}}, IsVariadic: true}, "func(string, ...int) (int, error)"},
+		{"anonymous struct", &StructType{Fields: map[string]Type{"A": Int, "B": String}, FieldOrder: []string{"B", "A"}}, "struct{B string; A int}"},
+		{"struct", point, "Point"},
+		{"empty interface", &InterfaceType{Name: "interface{}", IsEmpty: true}, "interface{}"},
+		{"interface", stringer, "Stringer"},
+		{"constraint", &TypeConstraint{Types: []Type{String, &ApproxType{Base: Int}}, Union: true}, "interface{string | ~int}"},
+		{"comparable", &TypeConstraint{BuiltinConstraint: ConstraintComparable}, "comparable"},
+		{"approximation", &ApproxType{Base: Float64}, "interface{~float64}"},
+		{"generic type", list, "List[T any]"},
+		{"instance", listOfInt, "List[int]"},
+		{"generic function", mapFn, "func[T any, U comparable]([]T, func(T) U) []U"},
+		{"variable", &VarObj{Name: "p", Type: point}, "Point"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToGoType(tt.typ, pkg)
+			if err != nil {
+				t.Fatalf("ToGoType() error = %v", err)
+			}
+			if s := types.TypeString(got, types.RelativeTo(pkg)); s != tt.want {
+				t.Errorf("ToGoType() = %s, want %s", s, tt.want)
+			}
+			back := FromGoType(got)
+			if gt, ok := back.(*GenericType); ok && gt.Signature != nil {
+				gt.Name = "Map" // signatures are not named
+			}
+			if FormatGo(back) != FormatGo(unwrapObject(tt.typ)) {
+				t.Errorf("FromGoType(ToGoType()) = %s, want %s", FormatGo(back), FormatGo(unwrapObject(tt.typ)))
+			}
+		})
+	}
+
+	named, err := ToGoType(age, pkg)
+	if err != nil {
+		t.Fatalf("ToGoType() error = %v", err)
+	}
+	if m := named.(*types.Named); m.NumMethods() != 1 || m.Method(0).Name() != "Years" || m.Obj().Pkg() != pkg {
+		t.Errorf("ToGoType(Age) = %v, want the named type of pkg with its method Years", named)
+	}
+
+	celsius := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "Celsius", nil), types.Typ[types.Float64], nil)
+	pkg.Scope().Insert(celsius.Obj())
+	if got, err := ToGoType(&NamedType{Name: "Celsius", Underlying: Float64}, pkg); err != nil || got != celsius {
+		t.Errorf("ToGoType(Celsius) = %v, %v, want the type declared in the scope of pkg", got, err)
+	}
+}
+
+// TestToGoTypeRoundTrip converts the declarations of BuildEnv to go/types and back.
+func TestToGoTypeRoundTrip(t *testing.T) {
+	gopkg, env := checkGoTypes(t, goTypesSrc)
+	pkg := types.NewPackage("p", "p")
+	for _, name := range gopkg.Scope().Names() {
+		t.Run(name, func(t *testing.T) {
+			want := unwrapObject(env[name])
+			got, err := ToGoType(want, pkg)
+			if err != nil {
+				t.Fatalf("ToGoType() error = %v", err)
+			}
+			back := FromGoType(got)
+			if gt, ok := back.(*GenericType); ok && gt.Signature != nil {
+				gt.Name = name
+			}
+			if FormatGo(back) != FormatGo(want) {
+				t.Errorf("FromGoType(ToGoType()) = %s, want %s", FormatGo(back), FormatGo(want))
+			}
+			if got, want := strings.Join(apiDecls(name, back), "\n"), strings.Join(apiDecls(name, want), "\n"); got != want {
+				t.Errorf("FromGoType(ToGoType()) declares\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+
+	node, err := ToGoType(env["Node"], pkg)
+	if err != nil {
+		t.Fatalf("ToGoType() error = %v", err)
+	}
+	next := node.Underlying().(*types.Struct).Field(1).Type().(*types.Pointer).Elem()
+	if next != node {
+		t.Errorf("Node.Next = *%v, want a pointer to Node", next)
+	}
+}
+
+func TestToGoTypeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  Type
+	}{
+		{"extensible struct", &ExtensibleStruct{Fields: map[string]Type{"name": String}, Rest: &TypeVariable{Name: "R"}}},
+		{"excluded types", &TypeConstraint{BuiltinConstraint: ConstraintComparable, Excluded: []Type{String}}},
+		{"package", NewPackageType("go/ast", TypeEnv{})},
+		{"unknown type", &TypeConstant{Name: "Foo"}},
+		{"variadic non-slice", &FunctionType{ParamTypes: []Type{Int}, IsVariadic: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ToGoType(tt.typ, nil); err == nil {
+				t.Errorf("ToGoType() = %v, want an error", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package generic
+
+// Implementations returns the types declared in env whose method sets satisfy the interface iface,
+// for queries like "go to implementations". The candidates are the struct types and the other
+// defined types of env, like `type Celsius float64`, but not the interfaces. The interface
+// may be given as its declaration, or as the type of an object of env.
+//
+// Value and pointer receivers are distinguished: a type whose value has all the methods
+// is returned as it is, like `Circle`, while a type that needs pointer receiver methods
+// is returned as a pointer, like `*Square`. The types are sorted by name.
+// It returns nil if iface is not an interface.
+func Implementations(iface Type, env TypeEnv) []Type {
+	var want Interface
+	switch it := underlying(unwrapObject(iface)).(type) {
+	case *InterfaceType:
+		want = Interface{Name: it.Name, Methods: it.Methods}
+	case *Interface:
+		want = *it
+	default:
+		return nil
+	}
+
+	var impls []Type
+	for _, name := range sortedKeys(env) {
+		var t Type
+		switch candidate := env[name].(type) {
+		case *StructType:
+			if candidate.Name == name {
+				t = candidate
+			}
+		case *NamedType:
+			if _, ok := underlying(candidate).(*InterfaceType); !ok && candidate.Name == name {
+				t = candidate
+			}
+		}
+		switch {
+		case t == nil:
+		case implInterface(t, want):
+			impls = append(impls, t)
+		case implInterface(&PointerType{Base: t}, want):
+			impls = append(impls, &PointerType{Base: t})
+		}
+	}
+	return impls
+}
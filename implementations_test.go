@@ -0,0 +1,100 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestImplementations(t *testing.T) {
+	env, shape := sealedEnv()
+	stringType := &TypeConstant{Name: "string"}
+	named := &StructType{Name: "Named", Fields: map[string]Type{}}
+	named.Methods = MethodSet{
+		"String": {Name: "String", Receiver: named, Results: []Type{stringType}},
+		"area":   {Name: "area", Receiver: named, Results: []Type{&TypeConstant{Name: "float64"}}, IsPointer: true},
+	}
+	env["Named"] = named
+	stringer := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{stringType}}}}
+
+	tests := []struct {
+		name  string
+		iface Type
+		want  []Type
+	}{
+		{
+			name:  "value and pointer receivers",
+			iface: shape,
+			want:  []Type{env["Circle"], &PointerType{Base: named}, &PointerType{Base: env["Square"]}},
+		},
+		{name: "exported interface", iface: stringer, want: []Type{named}},
+		{name: "alias of interface", iface: &TypeAlias{Name: "S", AliasedTo: stringer}, want: []Type{named}},
+		{name: "interface value", iface: &Interface{Name: "Stringer", Methods: stringer.Methods}, want: []Type{named}},
+		{name: "empty interface", iface: &InterfaceType{Name: "Any", Methods: MethodSet{}}, want: []Type{env["Circle"], named, env["Point"], env["Square"]}},
+		{name: "not an interface", iface: stringType, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Implementations(tt.iface, env); !typeListsEqual(got, tt.want) {
+				t.Errorf("Implementations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImplementationsBuildEnv(t *testing.T) {
+	const src = `package p
+
+type Stringer interface {
+	String() string
+}
+
+type Setter interface {
+	Set(v float64)
+}
+
+type Celsius float64
+
+func (c Celsius) String() string
+
+func (c *Celsius) Set(v float64)
+
+type Point struct{ X, Y int }
+
+func (p Point) String() string
+
+type Count int
+
+type Named = Stringer
+
+var s Stringer
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		iface string
+		want  []Type
+	}{
+		{iface: "Stringer", want: []Type{env["Celsius"], env["Point"]}},
+		{iface: "Setter", want: []Type{&PointerType{Base: env["Celsius"]}}},
+		{iface: "Named", want: []Type{env["Celsius"], env["Point"]}},
+		{iface: "s", want: []Type{env["Celsius"], env["Point"]}},
+		{iface: "Count", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.iface, func(t *testing.T) {
+			if got := Implementations(env[tt.iface], env); !typeListsEqual(got, tt.want) {
+				t.Errorf("Implementations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// Importer returns the package of an import path, like a PackageType created with
+// NewPackageType from the BuildEnv of the files of the package.
+type Importer func(path string) (*PackageType, error)
+
+// MapImporter returns an Importer of the given packages, keyed by import path.
+func MapImporter(pkgs map[string]*PackageType) Importer {
+	return func(path string) (*PackageType, error) {
+		pkg, ok := pkgs[path]
+		if !ok {
+			return nil, fmt.Errorf("package %s is not in the importer", path)
+		}
+		return pkg, nil
+	}
+}
+
+// errSkipImport is returned by an internal importer for the imports to leave unbound,
+// like BuildEnv ignores all of them.
+var errSkipImport = errors.New("import skipped")
+
+// importedName is a name bound by an import declaration.
+type importedName struct {
+	path string
+	dot  bool // bound by a dot import, like `Pi` after `import . "math"`
+}
+
+// declareImports binds the packages imported by files in env, under their name or alias,
+// like `m` for `import m "math"`. A dot import binds the exported members of the package
+// themselves, and a blank import binds nothing.
+//
+// The environment is that of the whole package, so a name must be imported from the same
+// package by all files; importing different packages under the same name is reported as a
+// conflict, like two imports of the same name within a file.
+func declareImports(files []*ast.File, importer Importer, env TypeEnv) (map[string]importedName, []error) {
+	imported := make(map[string]importedName)
+	var errs []error
+	bind := func(name string, imp importedName, t Type) {
+		if prev, ok := imported[name]; ok {
+			if prev == imp {
+				return
+			}
+			errs = append(errs, fmt.Errorf("%s redeclared: imported from both %s and %s", name, describeImport(prev), describeImport(imp)))
+			return
+		}
+		imported[name] = imp
+		env[name] = t
+	}
+
+	for _, file := range files {
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid import path %s", spec.Path.Value))
+				continue
+			}
+			pkg, err := importer(path)
+			if errors.Is(err, errSkipImport) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not import %s: %w", path, err))
+				continue
+			}
+
+			name := pkg.Name
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			switch name {
+			case "_":
+				continue
+			case ".":
+				members := make([]string, 0, len(pkg.Members))
+				for member := range pkg.Members {
+					if token.IsExported(member) {
+						members = append(members, member)
+					}
+				}
+				sort.Strings(members)
+				for _, member := range members {
+					bind(member, importedName{path: path, dot: true}, pkg.Members[member])
+				}
+			default:
+				bind(name, importedName{path: path}, pkg)
+			}
+		}
+	}
+	return imported, errs
+}
+
+// describeImport describes the import binding a name, for conflicts.
+func describeImport(imp importedName) string {
+	if imp.dot {
+		return fmt.Sprintf("package %s (dot import)", imp.path)
+	}
+	return "package " + imp.path
+}
+
+// checkImportConflicts reports the package-level declarations of files named like an import.
+func checkImportConflicts(files []*ast.File, imported map[string]importedName) []error {
+	var errs []error
+	check := func(name *ast.Ident) {
+		if imp, ok := imported[name.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s already declared through import of %s", name.Name, describeImport(imp)))
+		}
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					check(decl.Name)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						check(spec.Name)
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							check(name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
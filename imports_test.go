@@ -0,0 +1,196 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// testImporter returns an importer of small math and strings packages built from source.
+func testImporter(t *testing.T) Importer {
+	t.Helper()
+	pkgs := make(map[string]*PackageType)
+	for path, src := range map[string]string{
+		"math":    "package math\n\nconst Pi = 3.14159\n\nfunc Sqrt(x float64) float64\n\nfunc abs(x float64) float64\n",
+		"strings": "package strings\n\ntype Builder struct{}\n\nfunc ToUpper(s string) string\n",
+	} {
+		file, err := parser.ParseFile(token.NewFileSet(), path+".go", src, 0)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		env, err := BuildEnv([]*ast.File{file})
+		if err != nil {
+			t.Fatalf("BuildEnv(%s) error = %v", path, err)
+		}
+		pkgs[path] = NewPackageType(path, env)
+	}
+	return MapImporter(pkgs)
+}
+
+func TestBuildEnvWithImporter(t *testing.T) {
+	const src = `package p
+
+import (
+	m "math"
+	. "strings"
+	_ "math"
+)
+
+var root = m.Sqrt(m.Pi)
+
+var area = m.Pi * 2
+
+var upper = ToUpper("a")
+
+var b Builder
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnvWithImporter([]*ast.File{file}, testImporter(t))
+	if err != nil {
+		t.Fatalf("BuildEnvWithImporter() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"m", "package math"},
+		{"root", "float64"},
+		{"area", "float64"},
+		{"upper", "string"},
+		{"b", "Builder"},
+		{"ToUpper", "func(string) string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatGo(env[tt.name]); got != tt.want {
+				t.Errorf("env[%s] = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+	for _, name := range []string{"math", "strings", "_", "."} {
+		if _, ok := env[name]; ok {
+			t.Errorf("env[%s] is bound", name)
+		}
+	}
+
+	if _, err := InferPackage([]*ast.File{file}, env); err != nil {
+		t.Errorf("InferPackage() error = %v", err)
+	}
+}
+
+func TestBuildEnvImportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		srcs []string
+		want string
+	}{
+		{
+			name: "unknown package",
+			srcs: []string{`import "missing"`},
+			want: "could not import missing: package missing is not in the importer",
+		},
+		{
+			name: "same name",
+			srcs: []string{"import (\n\tm \"math\"\n\tm \"strings\"\n)"},
+			want: "m redeclared: imported from both package math and package strings",
+		},
+		{
+			name: "same name in different files",
+			srcs: []string{`import s "math"`, `import s "strings"`},
+			want: "s redeclared: imported from both package math and package strings",
+		},
+		{
+			name: "declaration named like an import",
+			srcs: []string{"import \"math\"\n\nfunc math()"},
+			want: "math already declared through import of package math",
+		},
+		{
+			name: "declaration named like a dot-imported member",
+			srcs: []string{"import . \"math\"\n\nvar Sqrt = 1"},
+			want: "Sqrt already declared through import of package math (dot import)",
+		},
+		{
+			name: "blank import",
+			srcs: []string{"import _ \"math\"\n\nvar x = math.Sqrt(2)"},
+			want: "declaration of x: unknown identifier: math",
+		},
+		{
+			name: "unexported member of a dot import",
+			srcs: []string{"import . \"math\"\n\nvar x = abs(2)"},
+			want: "declaration of x: unknown identifier: abs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []*ast.File
+			for i, src := range tt.srcs {
+				file, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\n\n"+src+"\n", 0)
+				if err != nil {
+					t.Fatalf("ParseFile(%d) error = %v", i, err)
+				}
+				files = append(files, file)
+			}
+			_, err := BuildEnvWithImporter(files, testImporter(t))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("BuildEnvWithImporter() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTestEnv(t *testing.T) {
+	parse := func(name, src string) *ast.File {
+		file, err := parser.ParseFile(token.NewFileSet(), name, src, 0)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		return file
+	}
+	files := []*ast.File{
+		parse("p.go", "package p\n\ntype Number interface{ ~int | ~float64 }\n\nfunc Max[T Number](a, b T) T\n\nfunc half(x float64) float64\n\nvar Limit = 10\n"),
+		parse("p_test.go", "package p\n\nimport \"testing\"\n\nvar x = 1.5\n\nvar h = half(Max[float64](x, x))\n"),
+	}
+	xtests := []*ast.File{
+		parse("x_test.go", "package p_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/p\"\n)\n\nvar m = p.Max[int](p.Limit, p.Limit)\n"),
+	}
+
+	env, xenv, err := BuildTestEnv(files, xtests, "example.com/p", nil)
+	if err != nil {
+		t.Fatalf("BuildTestEnv() error = %v", err)
+	}
+	if got := FormatGo(unwrapObject(env["h"])); got != "float64" {
+		t.Errorf("h = %s, want float64", got)
+	}
+	if got := FormatGo(unwrapObject(xenv["m"])); got != "int" {
+		t.Errorf("m = %s, want int", got)
+	}
+	if _, ok := xenv["Max"]; ok {
+		t.Error("xenv binds Max, want it only through p")
+	}
+
+	t.Run("unexported", func(t *testing.T) {
+		xtests := []*ast.File{parse("x_test.go", "package p_test\n\nimport \"example.com/p\"\n\nvar h = p.half(1)\n")}
+		_, _, err := BuildTestEnv(files, xtests, "example.com/p", nil)
+		if err == nil || !strings.Contains(err.Error(), "half") {
+			t.Errorf("BuildTestEnv() error = %v, want p.half undefined", err)
+		}
+	})
+
+	t.Run("importer", func(t *testing.T) {
+		xtests := []*ast.File{parse("x_test.go", "package p_test\n\nimport (\n\t\"math\"\n\n\t\"example.com/p\"\n)\n\nvar r = p.Max[float64](math.Sqrt(math.Pi), math.Pi)\n")}
+		_, xenv, err := BuildTestEnv(files[:1], xtests, "example.com/p", testImporter(t))
+		if err != nil {
+			t.Fatalf("BuildTestEnv() error = %v", err)
+		}
+		if got := FormatGo(unwrapObject(xenv["r"])); got != "float64" {
+			t.Errorf("r = %s, want float64", got)
+		}
+	})
+}
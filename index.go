@@ -0,0 +1,146 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+)
+
+// inferIndex infers the type of the element `x[i]` of the value x of type xt: the element
+// of a slice, an array or a pointer to an array, the byte of a string, with an integer
+// index, or the value of a map, with a key of its key type. A type parameter is indexed
+// like the core type of its constraint, like `S ~[]E`.
+func inferIndex(expr *ast.IndexExpr, xt Type, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	switch core := coreType(xt, env).(type) {
+	case *MapType:
+		kt, err := InferType(expr.Index, env, ctx.Child(WithExpectedType(core.KeyType)))
+		if err != nil {
+			return nil, err
+		}
+		if val := constantOf(expr.Index, env); val.Kind() != constant.Unknown && representable(val, core.KeyType, env) {
+			return core.ValueType, nil
+		}
+		if err := assignable(core.KeyType, kt, env, ctx); err != nil {
+			return nil, fmt.Errorf("cannot use %s (type %s) as %s in map index", types.ExprString(expr.Index), FormatGo(kt), FormatGo(core.KeyType))
+		}
+		return core.ValueType, nil
+	case *PointerType:
+		if at, ok := underlying(core.Base).(*ArrayType); ok {
+			return at.ElementType, checkIndex(expr.Index, env, ctx)
+		}
+	case *SliceType:
+		return core.ElementType, checkIndex(expr.Index, env, ctx)
+	case *ArrayType:
+		return core.ElementType, checkIndex(expr.Index, env, ctx)
+	case *TypeConstant:
+		if core.Name == TypeString {
+			return Byte, checkIndex(expr.Index, env, ctx)
+		}
+	}
+	return nil, fmt.Errorf("cannot index %s (type %s)", types.ExprString(expr.X), FormatGo(xt))
+}
+
+// checkIndex checks an index of a slice, array or string, which must be an integer, and
+// not negative if it is a constant.
+func checkIndex(index ast.Expr, env TypeEnv, ctx *InferenceContext) error {
+	if err := checkValueExpr(index, env); err != nil {
+		return err
+	}
+	it, err := InferType(index, env, ctx.Child())
+	if err != nil {
+		return err
+	}
+	if val := constantOf(index, env); val.Kind() != constant.Unknown && constant.ToInt(val).Kind() == constant.Int {
+		if constant.Sign(val) < 0 {
+			return fmt.Errorf("invalid argument: index %s (constant of type int) must not be negative", types.ExprString(index))
+		}
+		return nil
+	}
+	if !underlyingIs(operandConstraint(it, env), isIntegerName) {
+		return fmt.Errorf("invalid argument: index %s (type %s) must be integer", types.ExprString(index), FormatGo(it))
+	}
+	return nil
+}
+
+// inferSliceExpr infers the type of the slice expression `x[lo:hi]` or `x[lo:hi:max]`:
+// a slice of a slice, an array or a pointer to an array is a slice of its element type,
+// and a slice of a string is a string. A string has no capacity to slice to.
+func inferSliceExpr(expr *ast.SliceExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if err := checkValueExpr(expr.X, env); err != nil {
+		return nil, err
+	}
+	xt, err := InferType(expr.X, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range []ast.Expr{expr.Low, expr.High, expr.Max} {
+		if index == nil {
+			continue
+		}
+		if err := checkIndex(index, env, ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var result Type
+	switch core := coreType(xt, env).(type) {
+	case *SliceType:
+		result = xt
+	case *ArrayType:
+		result = &SliceType{ElementType: core.ElementType}
+	case *PointerType:
+		if at, ok := underlying(core.Base).(*ArrayType); ok {
+			result = &SliceType{ElementType: at.ElementType}
+		}
+	case *TypeConstant:
+		if core.Name == TypeString {
+			if expr.Slice3 {
+				return nil, fmt.Errorf("%w: 3-index slice of string", ErrInvalidOperation)
+			}
+			result = xt
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%w: cannot slice %s (type %s)", ErrInvalidOperation, types.ExprString(expr.X), FormatGo(xt))
+	}
+	return result, nil
+}
+
+// inferTypeAssert infers the type of the type assertion `x.(T)`, the type T, where x
+// must be of interface type. The type of a type switch guard, `x.(type)`, is that of x.
+func inferTypeAssert(expr *ast.TypeAssertExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if err := checkValueExpr(expr.X, env); err != nil {
+		return nil, err
+	}
+	xt, err := InferType(expr.X, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := interfaceOf(xt); !ok {
+		return nil, fmt.Errorf("%w: %s (type %s) is not an interface", ErrInvalidOperation, types.ExprString(expr.X), FormatGo(xt))
+	}
+	if expr.Type == nil {
+		return xt, nil
+	}
+	if err := checkTypeExpr(expr.Type, env); err != nil {
+		return nil, err
+	}
+	return InferType(expr.Type, env, ctx.Child())
+}
+
+// inferDeref infers the type of the indirection `*p`, the base type of the pointer p.
+func inferDeref(expr *ast.StarExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	pt, err := InferType(expr.X, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	if isNil(pt) {
+		return nil, fmt.Errorf("%w: invalid indirect of nil", ErrInvalidOperation)
+	}
+	ptr, ok := coreType(pt, env).(*PointerType)
+	if !ok {
+		return nil, fmt.Errorf("invalid indirect of %s (type %s)", types.ExprString(expr.X), FormatGo(pt))
+	}
+	return ptr.Base, nil
+}
@@ -4,18 +4,22 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/token"
-	"strconv"
+	"go/types"
 	"strings"
+
+	gerrors "github.com/notJoon/generic/errors"
 )
 
 var (
-	ErrUnknownIdent           = errors.New("unknown identifier")
-	ErrNotAFunction           = errors.New("not a function")
-	ErrUnknownExpr            = errors.New("unknown expression")
-	ErrNotAGenericType        = errors.New("not a generic type")
-	ErrTypeParamsNotMatch     = errors.New("type parameters do not match")
-	ErrConstraintNotSatisfied = errors.New("type does not satisfy constraint")
+	ErrUnknownIdent           = gerrors.ErrUnknownIdent
+	ErrNotAFunction           = gerrors.ErrNotAFunction
+	ErrUnknownExpr            = gerrors.ErrUnknownExpr
+	ErrNotAGenericType        = gerrors.ErrNotAGenericType
+	ErrTypeParamsNotMatch     = gerrors.ErrTypeParamsNotMatch
+	ErrConstraintNotSatisfied = gerrors.ErrConstraintNotSatisfied
+	ErrNoValueUsed            = gerrors.ErrNoValueUsed
 )
 
 // InferType infers the type of an AST expression in the given type environment.
@@ -45,9 +49,23 @@ var (
 //	                             (zip funcType.ParamTypes c.Args)
 //	                  in funcType.ReturnType
 //	_ → error
+//
+// Errors are TypeErrors, located at the innermost node that could not be inferred, in the
+// file set of ctx if it has one, see WithFileSet.
 func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	t, err := inferType(node, env, ctx)
+	if err != nil {
+		return nil, typeError(node, ctx, err)
+	}
+	return t, nil
+}
+
+// inferType is InferType, without locating the errors.
+func inferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, error) {
 	if ctx == nil {
-		ctx = NewInferenceContext()
+		// share a zero context rather than allocating one, so that inferring
+		// identifiers and literals does not allocate. It must never be modified.
+		ctx = &defaultContext
 	}
 
 	// [2024.06.24 @notJoon] Since the `ast.AssignStmt` and `ast.ReturnStmt` are dynamically typed,
@@ -57,35 +75,42 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 	switch expr := node.(type) {
 	case *ast.Ident:
 		if typ, ok := env[expr.Name]; ok {
+			typ = unwrapObject(typ)
+			if typ == invalidType {
+				return nil, fmt.Errorf("%w %s", errInvalidOperand, expr.Name)
+			}
 			if alias, ok := typ.(*TypeAlias); ok {
 				return alias.AliasedTo, nil
 			}
 			return typ, nil
 		}
-		return nil, fmt.Errorf("unknown identifier: %s", expr.Name)
+		// like the predeclared types, nil need not be in the environment
+		if expr.Name == "nil" {
+			return Nil, nil
+		}
+		return nil, &gerrors.UnknownIdentError{Name: expr.Name}
 	case *ast.AssignStmt:
-		for i, rhs := range expr.Rhs {
-			var expected Type
-			if i < len(expr.Lhs) {
-				expected, _ = InferType(expr.Lhs[i], env, ctx)
-			}
-			rhsCtx := NewInferenceContext(
-				WithExpectedType(ctx.ExpectedType),
-				WithAssignment(),
-			)
-			rhsType, err := InferType(rhs, env, rhsCtx)
-			if err != nil {
-				return nil, err
-			}
-
-			// check type compatibility
-			if expected != nil {
-				if err := Unify(expected, rhsType, env); err != nil {
-					return nil, fmt.Errorf("assignment type mismatch for %s: %v", expr.Lhs[i], err)
-				}
-			}
+		if err := inferAssignStmt(expr, env, ctx); err != nil {
+			return nil, err
 		}
 		return nil, nil // assignment statement does not have a type
+	case *ast.DeclStmt:
+		return InferType(expr.Decl, env, ctx)
+	case *ast.BlockStmt:
+		if err := inferStmt(expr, env, ctx); err != nil {
+			return nil, err
+		}
+		return nil, nil // blocks do not have a type
+	case *ast.IfStmt:
+		if err := inferStmt(expr, env, ctx); err != nil {
+			return nil, err
+		}
+		return nil, nil // if statements do not have a type
+	case *ast.GenDecl:
+		if err := inferGenDecl(expr, env, ctx); err != nil {
+			return nil, err
+		}
+		return nil, nil // declarations do not have a type
 	case *ast.ReturnStmt:
 		if ctx.ExpectedType == nil {
 			return nil, fmt.Errorf("return statement outside of function context")
@@ -97,27 +122,39 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 
 		var expectedType []Type
 		switch rt := funcType.ReturnType.(type) {
+		case nil:
+			// a function without results
 		case *TupleType:
 			expectedType = rt.Types
 		default:
 			expectedType = []Type{funcType.ReturnType}
 		}
 
+		// the results of a call returning as many, like `return f()`
+		if call, ok := singleCall(expr.Results); ok && len(expectedType) > 1 {
+			return nil, checkReturnTuple(call, expectedType, env, ctx)
+		}
 		if len(expr.Results) != len(expectedType) {
 			return nil, fmt.Errorf("expected %d return values, got %d", len(expectedType), len(expr.Results))
 		}
 
+		errs := ctx.errorList()
 		for i, result := range expr.Results {
-			if err := checkReturnType(result, expectedType[i], env); err != nil {
-				return nil, fmt.Errorf("return type mismatch for result %d: %v", i, err)
+			if err := checkReturnType(result, expectedType[i], env, ctx); err != nil {
+				if !errs.add(fmt.Errorf("result %d%s: %w", i+1, at(ctx, result.Pos()), err)) {
+					break
+				}
 			}
 		}
+		if len(errs.errs) > 0 {
+			return nil, errs.err()
+		}
 
 		return nil, nil // return statement does not have a type
 	case *ast.CallExpr:
-		if selExpr, ok := expr.Fun.(*ast.SelectorExpr); ok {
+		if selExpr, ok := expr.Fun.(*ast.SelectorExpr); ok && !isQualifiedIdent(selExpr, env) {
 			// might be a method call
-			recvType, err := InferType(selExpr.X, env, ctx)
+			recvType, err := InferType(selExpr.X, env, ctx.Child())
 			if err != nil {
 				return nil, err
 			}
@@ -151,7 +188,7 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 
 				var typeArgTypes []Type
 				for _, elt := range typeArgs.Elts {
-					typeArg, err := InferType(elt, env, NewInferenceContext())
+					typeArg, err := InferType(elt, env, ctx.Child())
 					if err != nil {
 						return nil, err
 					}
@@ -164,35 +201,69 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 				return inferGenericMethod(genericMethod, typeArgTypes, args, env, ctx)
 			}
 
-			method, err := findMethod(recvType, mthdName)
+			// a field of function type, like `flags.Usage()`, is called like any function value
+			if fieldType, ok := findField(recvType, mthdName); ok {
+				return inferValueCall(fieldType, expr, env, ctx)
+			}
+
+			method, err := findMethod(recvType, mthdName, env)
 			if err != nil {
 				return nil, err
 			}
 
+			method.Params = variadicParams(method.Params, method.IsVariadic, expr)
 			return inferMethodCall(method, expr.Args, env, ctx)
 		}
 
+		// builtins, like `len(xs)` or `make(T, n)`, unless they are shadowed
+		if name, ok := builtinName(expr, env); ok {
+			return inferBuiltinCall(name, expr, env, ctx)
+		}
+
+		// conversion, like `string(b)` or `[]byte(s)`
+		if target, ok := conversionType(expr.Fun, env); ok {
+			return inferConversion(target, expr.Args, env, ctx)
+		}
+
 		// regular function call
-		funcTyp, err := InferType(expr.Fun, env, ctx)
+		funcTyp, err := InferType(expr.Fun, env, ctx.Child())
 		if err != nil {
 			return nil, err
 		}
-		return inferFunctionCall(funcTyp, expr.Args, env, ctx)
+		// generic function, instantiated like `Map[int, string](xs, f)`, or with the type
+		// arguments left out inferred from the arguments, like `Map(xs, f)`
+		if gt, ok := funcTyp.(*GenericType); ok && gt.Signature != nil {
+			if needsTypeArgs(gt) {
+				if gt, err = inferTypeArgs(gt, expr, env, ctx); err != nil {
+					return nil, err
+				}
+			}
+			if funcTyp, err = genericFunctionSignature(gt); err != nil {
+				return nil, err
+			}
+			ctx.recordTypeArgs(expr, gt.TypeParams)
+		}
+		return inferValueCall(funcTyp, expr, env, ctx)
 	case *ast.IndexExpr:
 		baseType, err := InferType(expr.X, env, ctx)
 		if err != nil {
 			return nil, err
 		}
 		genericType, ok := baseType.(*GenericType)
-		if !ok {
-			return nil, ErrNotAGenericType
+		if !ok || genericType.Signature == nil && denotesValue(expr.X, env) {
+			if _, isType := conversionType(expr.X, env); isType {
+				return nil, ErrNotAGenericType
+			}
+			// an element of a slice, array, string or map, like `xs[i]` or `m[k]`
+			return inferIndex(expr, baseType, env, ctx)
 		}
 
-		typeArgs := make([]interface{}, len(genericType.TypeParams))
-		for i := range genericType.TypeParams {
-			typeArgs[i] = expr.Index
+		// generic functions can be partially instantiated, like `Map[int](xs, f)`
+		if genericType.Signature != nil {
+			return instantiatePartial(expr, genericType, []ast.Expr{expr.Index}, env, ctx)
 		}
-		return InstantiateGenericType(genericType, typeArgs, env, ctx)
+		// generic types cannot, like `Pair[int]`, unless the parameters left out have defaults
+		return instantiateAt(expr.Pos(), genericType, []interface{}{expr.Index}, env, ctx)
 	case *ast.IndexListExpr:
 		baseType, err := InferType(expr.X, env, ctx)
 		if err != nil {
@@ -202,37 +273,43 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 		if !ok {
 			return nil, ErrNotAGenericType
 		}
-		inferredParams, err := inferPartialTypeParams(genericType, expr.Indices, env, ctx)
-		if err != nil {
-			return nil, err
+		if genericType.Signature == nil {
+			typeArgs := make([]interface{}, len(expr.Indices))
+			for i, index := range expr.Indices {
+				typeArgs[i] = index
+			}
+			return instantiateAt(expr.Pos(), genericType, typeArgs, env, ctx)
 		}
-		return InstantiateGenericType(genericType, inferredParams, env, ctx)
+		return instantiatePartial(expr, genericType, expr.Indices, env, ctx)
 	case *ast.CompositeLit:
+		// the type of an element of a literal may be left out, like in `[]Pair{{1, 2}}`
+		if expr.Type == nil {
+			if ctx == nil || ctx.ExpectedType == nil {
+				return nil, fmt.Errorf("invalid composite literal type: missing type")
+			}
+			return inferLiteralOf(expr, ctx.ExpectedType, env, ctx)
+		}
 		switch typeExpr := expr.Type.(type) {
 		case *ast.MapType:
-			kt, err := InferType(typeExpr.Key, env, ctx)
-			if err != nil {
-				return nil, err
-			}
-			vt, err := InferType(typeExpr.Value, env, ctx)
+			mt, err := inferMapType(typeExpr, env, ctx)
 			if err != nil {
 				return nil, err
 			}
+			kt, vt := mt.KeyType, mt.ValueType
+			seen := make(map[string]token.Pos)
 			for _, elt := range expr.Elts {
 				if kv, ok := elt.(*ast.KeyValueExpr); ok {
-					k, err := InferType(kv.Key, env, ctx)
-					if err != nil {
-						return nil, err
+					if key, ok := constantKey(kv.Key); ok {
+						if prev, dup := seen[key]; dup {
+							return nil, fmt.Errorf("duplicate key %s in map literal%s", key, at(ctx, prev, kv.Key.Pos()))
+						}
+						seen[key] = kv.Key.Pos()
 					}
-					v, err := InferType(kv.Value, env, ctx)
-					if err != nil {
+					if err := checkElement(kv.Key, kt, "map key", env, ctx); err != nil {
 						return nil, err
 					}
-					if err := Unify(kt, k, env); err != nil {
-						return nil, fmt.Errorf("map key type mismatch: %v", err)
-					}
-					if err := Unify(vt, v, env); err != nil {
-						return nil, fmt.Errorf("map value type mismatch: %v", err)
+					if err := checkElement(kv.Value, vt, "map value", env, ctx); err != nil {
+						return nil, err
 					}
 				}
 			}
@@ -241,7 +318,7 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 			// handle slice literal
 			if typeExpr.Len == nil {
 				// inference the element type
-				etCtx := NewInferenceContext(WithExpectedType(ctx.ExpectedType))
+				etCtx := ctx.Child(WithExpectedType(ctx.ExpectedType))
 				et, err := InferType(typeExpr.Elt, env, etCtx)
 				if err != nil {
 					return nil, err
@@ -251,61 +328,82 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 					return &SliceType{ElementType: et}, nil
 				}
 
-				// check the types of the remaining elements and ensure they are consistent
-				//
-				// create a new context when checking the element types
-				eltCtx := NewInferenceContext(WithExpectedType(et))
-				for _, elt := range expr.Elts {
-					eltType, err := InferType(elt, env, eltCtx)
-					if err != nil {
-						return nil, err
-					}
-					if err := Unify(et, eltType, env); err != nil {
-						return nil, errors.New("inconsistent element types in slice literal")
-					}
+				if err := checkElements(expr, et, "slice element", env, ctx); err != nil {
+					return nil, err
 				}
 				return &SliceType{ElementType: et}, nil
 			}
-			// handle array literal
-			lenExpr, ok := typeExpr.Len.(*ast.BasicLit)
-			if !ok || lenExpr.Kind != token.INT {
-				return nil, errors.New("invalid array length expression")
-			}
-			length, err := strconv.Atoi(lenExpr.Value)
-			if err != nil {
-				return nil, fmt.Errorf("invalid array length: %v", err)
+			// handle array literal. `[...]T` takes its length from the literal itself
+			length := -1
+			if _, ok := typeExpr.Len.(*ast.Ellipsis); !ok {
+				l, err := constantIndex(typeExpr.Len, env)
+				if err != nil {
+					return nil, fmt.Errorf("invalid array length: %v", err)
+				}
+				length = l
 			}
 
-			etCtx := NewInferenceContext(WithExpectedType(ctx.ExpectedType))
+			etCtx := ctx.Child(WithExpectedType(ctx.ExpectedType))
 			elemType, err := InferType(typeExpr.Elt, env, etCtx)
 			if err != nil {
 				return nil, err
 			}
 
-			// check element types of the array literal
-			eltCtx := NewInferenceContext(WithExpectedType(elemType))
+			// check element types of the array literal.
+			// elements may be keyed by a constant index, like `[5]int{0: 1, 4: 9}`
+			seen := make(map[int]token.Pos)
+			index, maxIndex := 0, 0
 			for _, elt := range expr.Elts {
-				et, err := InferType(elt, env, eltCtx)
-				if err != nil {
+				value := elt
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					index, err = constantIndex(kv.Key, env)
+					if err != nil {
+						return nil, err
+					}
+					value = kv.Value
+				}
+				if length >= 0 && index >= length {
+					return nil, fmt.Errorf("index %d out of bounds [0:%d]", index, length)
+				}
+				if prev, dup := seen[index]; dup {
+					return nil, fmt.Errorf("duplicate index %d in array literal%s", index, at(ctx, prev, elt.Pos()))
+				}
+				seen[index] = elt.Pos()
+
+				if err := checkElement(value, elemType, "array element", env, ctx); err != nil {
 					return nil, err
 				}
-				if !TypesEqual(elemType, et) {
-					return nil, fmt.Errorf("element type mismatch: %v", err)
+
+				index++
+				if index > maxIndex {
+					maxIndex = index
 				}
 			}
+			if length < 0 {
+				length = maxIndex
+			}
 			return &ArrayType{ElementType: elemType, Len: length}, nil
 		case *ast.Ident:
 			structType, ok := env[typeExpr.Name].(*StructType)
 			if !ok {
+				if t, isType := conversionType(typeExpr, env); isType {
+					return inferLiteralOf(expr, t, env, ctx)
+				}
 				return nil, fmt.Errorf("unknown struct type: %s", typeExpr.Name)
 			}
 
-			newStruct := &StructType{
-				Name:   structType.Name,
-				Fields: make(map[string]Type),
+			// the values of all the fields, in order, like `Pair{1, 2}`
+			if len(expr.Elts) > 0 {
+				if _, keyed := expr.Elts[0].(*ast.KeyValueExpr); !keyed {
+					if err := checkStructLit(expr, structType, env, ctx); err != nil {
+						return nil, err
+					}
+					return structType, nil
+				}
 			}
 
 			// handle each field
+			seen := make(map[string]token.Pos)
 			for _, elt := range expr.Elts {
 				kv, ok := elt.(*ast.KeyValueExpr)
 				if !ok {
@@ -313,80 +411,82 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 				}
 
 				fieldName := kv.Key.(*ast.Ident).Name
+				if prev, dup := seen[fieldName]; dup {
+					return nil, fmt.Errorf("duplicate field name %s in struct literal%s", fieldName, at(ctx, prev, kv.Key.Pos()))
+				}
+				seen[fieldName] = kv.Key.Pos()
 				fieldType, ok := structType.Fields[fieldName]
 				if !ok {
 					return nil, fmt.Errorf("unknown field: %s", fieldName)
 				}
 
 				// create a new context for the field
-				fieldCtx := NewInferenceContext(WithExpectedType(fieldType))
-
-				// nested struct
-				if nestedCompLit, ok := kv.Value.(*ast.CompositeLit); ok {
-					nestedType, err := InferType(nestedCompLit, env, fieldCtx)
-					if err != nil {
-						return nil, err
-					}
-					if !TypesEqual(fieldType, nestedType) {
-						return nil, fmt.Errorf("type mismatch for field %s: %v. got %v", fieldName, fieldType, nestedType)
-					}
-					newStruct.Fields[fieldName] = nestedType
-				} else {
-					fieldValue, err := InferType(kv.Value, env, fieldCtx)
-					if err != nil {
-						return nil, err
-					}
-					if !TypesEqual(fieldType, fieldValue) {
-						return nil, fmt.Errorf("type mismatch for field %s: %v. got %v", fieldName, fieldType, fieldValue)
+				fieldCtx := ctx.Child(WithExpectedType(fieldType))
+				fieldValue, err := InferType(kv.Value, env, fieldCtx)
+				if err != nil {
+					return nil, err
+				}
+				if val := constantOf(kv.Value, env); val.Kind() == constant.Unknown || !representable(val, fieldType, env) {
+					if err := assignable(fieldType, fieldValue, env, ctx); err != nil {
+						return nil, fmt.Errorf("type mismatch for field %s: %s. got %s", fieldName, FormatGo(fieldType), FormatGo(fieldValue))
 					}
-					newStruct.Fields[fieldName] = fieldValue
 				}
 			}
-			return newStruct, nil
-		// genetic type instantiation
-		case *ast.IndexExpr:
-			genericType, err := resolveTypeByName(typeExpr.X.(*ast.Ident).Name, env)
+			return structType, nil
+		// genetic type instantiation, like `Box[int]{}` or `Pair[K, V]{}`
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			x, indices := instanceParts(typeExpr)
+			var genericType Type
+			var err error
+			switch x := x.(type) {
+			case *ast.Ident:
+				genericType, err = resolveTypeByName(x.Name, env)
+			case *ast.SelectorExpr:
+				// a generic type of an imported package, like `atomic.Pointer[int]{}`
+				pkg, ok := packageOf(x.X, env)
+				if !ok {
+					return nil, fmt.Errorf("invalid composite literal type %s", types.ExprString(typeExpr))
+				}
+				genericType, err = inferQualifiedIdent(pkg, x.Sel)
+			default:
+				return nil, fmt.Errorf("invalid composite literal type %s", types.ExprString(typeExpr))
+			}
 			if err != nil {
 				return nil, err
 			}
 			gt, ok := genericType.(*GenericType)
 			if !ok {
-				return nil, fmt.Errorf("not a generic type: %v", genericType)
+				return nil, fmt.Errorf("not a generic type: %s", FormatGo(genericType))
 			}
 
-			// infer the type argument
-			taCtx := NewInferenceContext(WithExpectedType(ctx.ExpectedType))
-			typeArg, err := InferType(typeExpr.Index, env, taCtx)
+			// infer the type arguments and instantiate the generic type with them
+			typeArgs := make([]interface{}, len(indices))
+			for i, index := range indices {
+				typeArgs[i] = index
+			}
+			taCtx := ctx.Child(WithExpectedType(ctx.ExpectedType))
+			instantiated, err := instantiateAt(typeExpr.Pos(), gt, typeArgs, env, taCtx)
 			if err != nil {
 				return nil, err
 			}
-
-			// check if the type argument satisfies the constraint
-			if constraint, ok := gt.Constraints[gt.TypeParams[0].(*TypeVariable).Name]; ok {
-				if !checkConstraint(typeArg, constraint) {
-					return nil, fmt.Errorf("type argument %v does not satisfy constraint %v", typeArg, constraint)
-				}
-			}
-
-			instantiatedType := &GenericType{
-				Name:       gt.Name,
-				TypeParams: []Type{typeArg},
-				Fields:     make(map[string]Type),
-			}
-
-			// type check the each struct fields
-			for fname, ftype := range gt.Fields {
-				instantiatedFieldType := substituteTypeParams(ftype, gt.TypeParams, []Type{typeArg}, NewTypeVisitor())
-				instantiatedType.Fields[fname] = instantiatedFieldType
+			instantiatedType := instantiated.(*GenericType)
+			if !instantiatedType.isStruct() {
+				// a literal of a generic type defined by another type, like `List[int]{1, 2}`
+				return inferLiteralOf(expr, instantiatedType, env, ctx)
 			}
 
 			// create a new context for the struct literal
-			structCtx := NewInferenceContext(WithExpectedType(instantiatedType))
+			structCtx := ctx.Child(WithExpectedType(instantiatedType))
 
 			// check struct literal's field values
+			seen := make(map[string]token.Pos)
 			for _, elt := range expr.Elts {
 				if kv, ok := elt.(*ast.KeyValueExpr); ok {
 					fname := kv.Key.(*ast.Ident).Name
+					if prev, dup := seen[fname]; dup {
+						return nil, fmt.Errorf("duplicate field name %s in struct literal%s", fname, at(ctx, prev, kv.Key.Pos()))
+					}
+					seen[fname] = kv.Key.Pos()
 					fType, ok := instantiatedType.Fields[fname]
 					if !ok {
 						return nil, fmt.Errorf("unknown field %s in generic type %s", fname, gt.Name)
@@ -395,74 +495,129 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 					if err != nil {
 						return nil, err
 					}
-					if err := Unify(fType, vt, env); err != nil {
-						return nil, fmt.Errorf("type mismatch for field %s: %v. got %v", fname, fType, vt)
+					if err := unifyInto(fType, vt, env, ctx); err != nil {
+						return nil, fmt.Errorf("type mismatch for field %s: %s. got %s", fname, FormatGo(fType), FormatGo(vt))
 					}
 				}
 			}
 			return instantiatedType, nil
+		default:
+			// a literal of a qualified or literal type, like `ast.Ident{}` or `struct{ X int }{}`
+			if err := checkTypeExpr(expr.Type, env); err != nil {
+				return nil, err
+			}
+			t, err := InferType(expr.Type, env, ctx.Child(WithExpectedType(nil)))
+			if err != nil {
+				return nil, err
+			}
+			return inferLiteralOf(expr, t, env, ctx)
 		}
 	case *ast.BasicLit:
 		switch expr.Kind {
 		case token.INT:
-			return &TypeConstant{Name: "int"}, nil
+			return Int, nil
 		case token.FLOAT:
-			if strings.Contains(expr.Value, ".") {
-				return &TypeConstant{Name: "float64"}, nil
+			// floating-point literals, with or without a dot like `1e9`, are untyped float constants.
+			// they take the expected floating-point or complex type, and default to float64.
+			if ctx.ExpectedType != nil && (isFloat(ctx.ExpectedType) || isComplex(ctx.ExpectedType)) {
+				return ctx.ExpectedType, nil
 			}
-			return &TypeConstant{Name: "float32"}, nil
+			return Float64, nil
 		case token.STRING:
-			return &TypeConstant{Name: "string"}, nil
+			return String, nil
 		case token.CHAR:
-			return &TypeConstant{Name: "rune"}, nil
+			return Rune, nil
 		default:
 			return nil, fmt.Errorf("unknown basic literal kind: %v", expr.Kind)
 		}
+	case *ast.SelectorExpr:
+		return inferSelector(expr, env, ctx)
+	case *ast.BinaryExpr:
+		return inferBinaryExpr(expr, env, ctx)
+	case *ast.ParenExpr:
+		return InferType(expr.X, env, ctx)
+	case *ast.SliceExpr:
+		return inferSliceExpr(expr, env, ctx)
+	case *ast.TypeAssertExpr:
+		return inferTypeAssert(expr, env, ctx)
+	case *ast.UnaryExpr:
+		if expr.Op == token.ARROW {
+			return inferReceive(expr, env, ctx)
+		}
+		if expr.Op != token.AND {
+			return inferUnaryExpr(expr, env, ctx)
+		}
+		lit, ok := ast.Unparen(expr.X).(*ast.CompositeLit)
+		if !ok {
+			// taking the address of a variable, like `&x`, `&p.X` or `&xs[i]`
+			return inferAddress(expr, env, ctx)
+		}
+		// taking the address of a composite literal, like `&Person{Name: "x"}`
+		litCtx := ctx.Child()
+		if ctx.ExpectedType != nil {
+			if pt, ok := ctx.ExpectedType.(*PointerType); ok {
+				litCtx.ExpectedType = pt.Base
+			}
+		}
+		bt, err := InferType(lit, env, litCtx)
+		if err != nil {
+			return nil, err
+		}
+		return &PointerType{Base: bt}, nil
+	case *ast.MapType:
+		return inferMapType(expr, env, ctx)
+	case *ast.ChanType:
+		return inferChanType(expr, env, ctx)
+	case *ast.SendStmt:
+		if err := inferSendStmt(expr, env, ctx); err != nil {
+			return nil, err
+		}
+		return nil, nil // send statement does not have a type
+	case *ast.ArrayType:
+		// slice or array type, like the element type of a map
+		et, err := InferType(expr.Elt, env, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if expr.Len == nil {
+			return &SliceType{ElementType: et}, nil
+		}
+		length, err := constantIndex(expr.Len, env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length: %v", err)
+		}
+		return &ArrayType{ElementType: et, Len: length}, nil
 	case *ast.StarExpr:
-		btCtx := NewInferenceContext(WithExpectedType(ctx.ExpectedType))
+		if denotesValue(expr.X, env) {
+			return inferDeref(expr, env, ctx)
+		}
+		btCtx := ctx.Child(WithExpectedType(ctx.ExpectedType))
 		bt, err := InferType(expr.X, env, btCtx)
 		if err != nil {
 			return nil, err
 		}
 		return &PointerType{Base: bt}, nil
 	case *ast.FuncType:
-		paramCtx := NewInferenceContext(WithFunctionArg())
-		ptypes, err := inferParams(expr.Params, env, paramCtx)
+		sig, err := buildSignature(expr, env, ctx)
 		if err != nil {
 			return nil, err
 		}
-
-		retCtx := NewInferenceContext(WithReturnValue())
-		retType, err := inferResult(expr.Results, env, retCtx)
+		return checkExpectedFunction(sig.functionType(), ctx)
+	case *ast.FuncLit:
+		sig, err := buildSignature(expr.Type, env, ctx)
 		if err != nil {
 			return nil, err
 		}
-
-		isVariadic := expr.Params.NumFields() > 0 && expr.Params.List[len(expr.Params.List)-1].Type.(*ast.Ellipsis) != nil
-		funcType := &FunctionType{
-			ParamTypes: ptypes,
-			ReturnType: retType,
-			IsVariadic: isVariadic,
-		}
-
-		// if ctx has expected type, check the function type compatibility
-		if ctx != nil && ctx.ExpectedType != nil {
-			if expected, ok := ctx.ExpectedType.(*FunctionType); ok {
-				if err := checkFunctionCompatibility(funcType, expected); err != nil {
-					return nil, fmt.Errorf("function type incompatible with expected type: %v", err)
-				}
-			}
+		if err := checkFuncLit(expr, sig, env, ctx.Child(WithExpectedType(nil))); err != nil {
+			return nil, err
 		}
-
-		return funcType, nil
-	case *ast.FuncLit:
-		funcCtx := NewInferenceContext()
-		if ctx != nil && ctx.ExpectedType != nil {
-			if ft, ok := ctx.ExpectedType.(*FunctionType); ok {
-				funcCtx.ExpectedType = ft
-			}
+		return checkExpectedFunction(sig.functionType(), ctx)
+	case *ast.FuncDecl:
+		sig, err := buildSignature(expr.Type, env, ctx.Child())
+		if err != nil {
+			return nil, fmt.Errorf("function %s: %v", expr.Name.Name, err)
 		}
-		return inferFunctionType(expr.Type, env, funcCtx)
+		return sig.functionType(), nil
 	case *ast.Ellipsis:
 		var expectedElemType Type
 		if ctx != nil && ctx.ExpectedType != nil {
@@ -475,17 +630,24 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 				ElementType: &InterfaceType{Name: "interface{}", IsEmpty: true},
 			}, nil
 		}
-		elemCtx := NewInferenceContext(WithExpectedType(expectedElemType))
+		elemCtx := ctx.Child(WithExpectedType(expectedElemType))
 		elemType, err := InferType(expr.Elt, env, elemCtx)
 		if err != nil {
 			return nil, err
 		}
 		return &SliceType{ElementType: elemType}, nil
+	case *ast.StructType:
+		// an anonymous struct, like the element of `map[K]struct{}`
+		fields, order, err := declaredFields(expr, env)
+		if err != nil {
+			return nil, err
+		}
+		return &StructType{Fields: fields, FieldOrder: order, Methods: MethodSet{}}, nil
 	case *ast.InterfaceType:
 		iface := &InterfaceType{Name: "", Methods: MethodSet{}, Embedded: []Type{}}
 		for _, field := range expr.Methods.List {
 			if len(field.Names) == 0 {
-				embeddedCtx := NewInferenceContext()
+				embeddedCtx := ctx.Child()
 				embeddedType, err := InferType(field.Type, env, embeddedCtx)
 				if err != nil {
 					return nil, err
@@ -498,23 +660,16 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 						return nil, fmt.Errorf("expected function type for method %s", name.Name)
 					}
 
-					paramCtx := NewInferenceContext(WithFunctionArg())
-					params, err := inferParams(mt.Params, env, paramCtx)
-					if err != nil {
-						return nil, fmt.Errorf("error inferring parameters for method %s: %v", name.Name, err)
-					}
-
-					// infer the method results
-					resultsCtx := NewInferenceContext(WithReturnValue())
-					results, err := inferParams(mt.Results, env, resultsCtx)
+					sig, err := buildSignature(mt, env, ctx.Child())
 					if err != nil {
-						return nil, fmt.Errorf("error inferring results for method %s: %v", name.Name, err)
+						return nil, fmt.Errorf("error inferring signature for method %s: %v", name.Name, err)
 					}
 
 					iface.Methods[name.Name] = Method{
-						Name:    name.Name,
-						Params:  params,
-						Results: results,
+						Name:       name.Name,
+						Params:     sig.Params,
+						Results:    sig.Results,
+						IsVariadic: sig.IsVariadic,
 					}
 				}
 			}
@@ -531,140 +686,578 @@ func InferType(node interface{}, env TypeEnv, ctx *InferenceContext) (Type, erro
 	default:
 		return nil, fmt.Errorf("unsupported node type: %T", node)
 	}
-	return nil, fmt.Errorf("unknown expression: %T", node)
 }
 
-func checkReturnType(result ast.Expr, expectedType Type, env TypeEnv) error {
-	resultCtx := NewInferenceContext(
-		WithExpectedType(expectedType),
-		WithReturnValue(),
-	)
-	resultType, err := InferType(result, env, resultCtx)
-	if err != nil {
-		return nil
+// constantKey returns a canonical representation of a constant composite literal key,
+// so that keys written differently (e.g. `0x10` and `16`) are detected as duplicates.
+// It reports false if the key is not a constant expression.
+func constantKey(key ast.Expr) (string, bool) {
+	val := constantValue(key)
+	if val.Kind() == constant.Unknown {
+		return "", false
 	}
-	return Unify(expectedType, resultType, env)
+	return val.ExactString(), true
 }
 
-func inferGenericMethod(method GenericMethod, typeArgs []Type, args []ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
-	if len(typeArgs) != len(method.TypeParams) {
-		return nil, fmt.Errorf("expected %d type arguments, got %d", len(method.TypeParams), len(typeArgs))
+// constantIndex evaluates a constant array index (or length) expression, which may use the
+// constants declared in env, like `[N]int` after `const N = 3` or `const N int = 3`.
+func constantIndex(expr ast.Expr, env TypeEnv) (int, error) {
+	val := constantOf(expr, env)
+	if c, ok := declaredConst(expr, env); ok && val.Kind() == constant.Unknown && c.Val != nil {
+		val = c.Val
 	}
-
-	// Create a new environment with type parameters bound to concrete types
-	newEnv := make(TypeEnv)
-	for k, v := range env {
-		newEnv[k] = v
+	val = constant.ToInt(val)
+	if val.Kind() != constant.Int {
+		return 0, fmt.Errorf("index %v must be integer constant", expr)
 	}
-	for i, param := range method.TypeParams {
-		newEnv[param.(*TypeVariable).Name] = typeArgs[i]
+	idx, ok := constant.Int64Val(val)
+	if !ok || idx < 0 {
+		return 0, fmt.Errorf("index %s must be non-negative integer constant", val.ExactString())
 	}
+	return int(idx), nil
+}
 
-	// Substitute type parameters in the method signature
-	substitutedMethod := substituteTypeParams(method.Method, method.TypeParams, typeArgs, NewTypeVisitor()).(Method)
-
-	// Check argument types
-	if len(args) != len(substitutedMethod.Params) {
-		return nil, fmt.Errorf("expected %d arguments, got %d", len(substitutedMethod.Params), len(args))
+// inferMapType infers the map type written as expr, like `map[string]int`.
+// The key type must be comparable; type parameters are accepted, since their
+// constraint is checked where they are declared.
+func inferMapType(expr *ast.MapType, env TypeEnv, ctx *InferenceContext) (*MapType, error) {
+	kt, err := InferType(expr.Key, env, ctx)
+	if err != nil {
+		return nil, err
 	}
-	for i, arg := range args {
-		argContext := NewInferenceContext(
-			WithExpectedType(substitutedMethod.Params[i]),
-			WithFunctionArg(),
-		)
-		argType, err := InferType(arg, env, argContext)
-		if err != nil {
-			return nil, err
-		}
-		if err := Unify(substitutedMethod.Params[i], argType, newEnv); err != nil {
-			return nil, fmt.Errorf("argument type mismatch for arg: %v", err)
-		}
+	vt, err := InferType(expr.Value, env, ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	// Substitute type parameters in the result type
-	if len(substitutedMethod.Results) == 0 {
-		return &TypeConstant{Name: "void"}, nil
+	key := unalias(kt)
+	if gt, ok := key.(*GenericType); ok {
+		key = gt.Underlying()
+	}
+	if !isComparable(key) && !undefinedKey(key) {
+		return nil, fmt.Errorf("invalid map key type %s", types.ExprString(expr.Key))
 	}
-	resultType := substituteTypeParams(substitutedMethod.Results[0], method.TypeParams, typeArgs, NewTypeVisitor())
+	return &MapType{KeyType: kt, ValueType: vt}, nil
+}
 
-	if ctx != nil && ctx.ExpectedType != nil {
-		if err := Unify(resultType, ctx.ExpectedType, newEnv); err != nil {
-			return nil, fmt.Errorf("return type mismatch: %v", err)
-		}
+// undefinedKey reports whether the map key type t is not known yet to be comparable: a type
+// parameter, or a defined type whose underlying type is still to be defined by BuildEnv,
+// like Type in a field `map[Type]bool` of a struct declared before Type.
+func undefinedKey(t Type) bool {
+	switch t := t.(type) {
+	case *TypeVariable:
+		return true
+	case *NamedType:
+		return t.Underlying == nil
 	}
+	return false
+}
 
-	return resultType, nil
+// constantValue evaluates constant expressions made of literals, like `-1` or `2 * (3 + 4)`.
+// It returns an unknown value for anything that is not a constant literal.
+func constantValue(expr ast.Expr) constant.Value {
+	return constantOf(expr, nil)
 }
 
-// substituteTypeParams substitutes type parameters in a type with concrete types.
-// It uses a TypeVisitor to detect and handle circular references in the type structure.
-func substituteTypeParams(t Type, from, to []Type, visitor *TypeVisitor) Type {
-	// circular reference check
-	if visitor.Visit(t) {
-		return t
+// constantOf is like constantValue, but also evaluates the untyped constants declared in env
+// or by imported packages, like `k * 2` after `const k = 10`, or `utf8.RuneSelf`.
+func constantOf(expr ast.Expr, env TypeEnv) constant.Value {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(e.Value, e.Kind, 0)
+	case *ast.Ident, *ast.SelectorExpr:
+		if c, ok := declaredConst(e, env); ok && c.Untyped {
+			return c.Val
+		}
+	case *ast.ParenExpr:
+		return constantOf(e.X, env)
+	case *ast.UnaryExpr:
+		val := constantOf(e.X, env)
+		numeric := val.Kind() == constant.Int || val.Kind() == constant.Float || val.Kind() == constant.Complex
+		switch {
+		case (e.Op == token.ADD || e.Op == token.SUB) && numeric,
+			e.Op == token.XOR && val.Kind() == constant.Int,
+			e.Op == token.NOT && val.Kind() == constant.Bool:
+			return constant.UnaryOp(e.Op, val, 0)
+		}
+	case *ast.BinaryExpr:
+		return constantBinaryOp(e.Op, constantOf(e.X, env), constantOf(e.Y, env))
 	}
-	switch t := t.(type) {
-	case *TypeVariable:
-		for i, param := range from {
-			if TypesEqual(t, param) {
-				return to[i]
-			}
-		}
-	case *GenericType:
-		newParams := make([]Type, len(t.TypeParams))
-		for i, param := range t.TypeParams {
-			newParams[i] = substituteTypeParams(param, from, to, visitor)
+	return constant.MakeUnknown()
+}
+
+// declaredConst returns the constant named by expr, declared in env, like `k`,
+// or by an imported package, like `utf8.RuneSelf`.
+func declaredConst(expr ast.Expr, env TypeEnv) (*ConstObj, bool) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		c, ok := env[e.Name].(*ConstObj)
+		return c, ok
+	case *ast.SelectorExpr:
+		if pkg, ok := packageOf(e.X, env); ok && e.Sel.IsExported() {
+			c, ok := pkg.Members[e.Sel.Name].(*ConstObj)
+			return c, ok
 		}
-		newFld := make(map[string]Type)
-		for name, typ := range t.Fields {
-			newFld[name] = substituteTypeParams(typ, from, to, visitor)
+	}
+	return nil, false
+}
+
+// inferAssignStmt checks an assignment or short variable declaration.
+//
+// Each value is checked against the type of its target. A single call with multiple results
+// can be assigned to as many targets, like `a, b := f()`. Short variable declarations
+// bind their new variables in env, so that they can be used by the following statements.
+func inferAssignStmt(stmt *ast.AssignStmt, env TypeEnv, ctx *InferenceContext) error {
+	var rhsTypes []Type
+	if len(stmt.Lhs) > 1 && len(stmt.Rhs) == 1 {
+		rhsType, err := InferType(stmt.Rhs[0], env, ctx.Child(WithAssignment()))
+		if err != nil {
+			return err
 		}
-		return &GenericType{
-			Name:       t.Name,
-			TypeParams: newParams,
-			Fields:     t.Fields,
+		if len(stmt.Lhs) == 2 && isCommaOk(stmt.Rhs[0], env) {
+			rhsType = &TupleType{Types: []Type{rhsType, Bool}}
 		}
-	case *SliceType:
-		return &SliceType{
-			ElementType: substituteTypeParams(t.ElementType, from, to, visitor),
+		tuple, ok := rhsType.(*TupleType)
+		if !ok {
+			return fmt.Errorf("assignment mismatch: %d variables but 1 value", len(stmt.Lhs))
 		}
-	case *MapType:
-		return &MapType{
-			KeyType:   substituteTypeParams(t.KeyType, from, to, visitor),
-			ValueType: substituteTypeParams(t.ValueType, from, to, visitor),
+		if len(tuple.Types) != len(stmt.Lhs) {
+			return fmt.Errorf("assignment mismatch: %d variables but %d values", len(stmt.Lhs), len(tuple.Types))
 		}
-	case *FunctionType:
-		newParams := make([]Type, len(t.ParamTypes))
-		for i, param := range t.ParamTypes {
-			newParams[i] = substituteTypeParams(param, from, to, visitor)
+		rhsTypes = tuple.Types
+	} else {
+		if len(stmt.Lhs) != len(stmt.Rhs) {
+			return fmt.Errorf("assignment mismatch: %d variables but %d values", len(stmt.Lhs), len(stmt.Rhs))
 		}
-		newReturn := substituteTypeParams(t.ReturnType, from, to, visitor)
-		return &FunctionType{
-			ParamTypes: newParams,
-			ReturnType: newReturn,
-			IsVariadic: t.IsVariadic,
+		for i, rhs := range stmt.Rhs {
+			if err := checkValueExpr(rhs, env); err != nil {
+				return err
+			}
+			// the type of the target is the expected type of the value, as for the
+			// initializer of a declaration, like for `f = nil` or `x = 1 << 40`
+			var expected Type
+			if stmt.Tok != token.DEFINE {
+				t, err := inferAssignTarget(stmt.Lhs[i], env, ctx)
+				if err != nil {
+					return err
+				}
+				expected = t
+			}
+			rhsType, err := InferType(rhs, env, ctx.Child(WithAssignment(), WithExpectedType(expected)))
+			if err != nil {
+				return err
+			}
+			if isNoValue(rhsType) {
+				return fmt.Errorf("assignment to %s: %w", types.ExprString(stmt.Lhs[i]), ErrNoValueUsed)
+			}
+			if tuple, ok := rhsType.(*TupleType); ok {
+				return fmt.Errorf("assignment mismatch: multiple-value %s (%d values) in single-value context", types.ExprString(rhs), len(tuple.Types))
+			}
+			_, param := expected.(*TypeVariable)
+			if val := constantOf(rhs, env); expected != nil && !param && val.Kind() != constant.Unknown {
+				// untyped constants only need to be representable by the target type,
+				// a type parameter is bound by the unification below
+				t, err := convertUntyped(&operand{expr: rhs, typ: rhsType, val: val}, expected, env)
+				if err != nil {
+					return fmt.Errorf("assignment type mismatch for %s: %w", types.ExprString(stmt.Lhs[i]), err)
+				}
+				rhsType = t
+			}
+			rhsTypes = append(rhsTypes, rhsType)
 		}
 	}
-	return t
-}
 
-func substituteTypeVar(t Type, tv *TypeVariable, replacement Type) Type {
-	switch t := t.(type) {
-	case *TypeVariable:
-		if t.Name == tv.Name {
-			return replacement
+	define := stmt.Tok == token.DEFINE
+	newVars := make(map[string]Type)
+	for i, lhs := range stmt.Lhs {
+		if define {
+			ident, ok := lhs.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("non-name %s on left side of :=", types.ExprString(lhs))
+			}
+			if _, known := env[ident.Name]; !known && ident.Name != "_" {
+				if isNil(rhsTypes[i]) {
+					return fmt.Errorf("use of untyped nil in assignment")
+				}
+				newVars[ident.Name] = rhsTypes[i]
+				continue
+			}
 		}
-	case *GenericType:
-		newParams := make([]Type, len(t.TypeParams))
-		for i, param := range t.TypeParams {
-			newParams[i] = substituteTypeVar(param, tv, replacement)
+
+		expected, err := inferAssignTarget(lhs, env, ctx)
+		if err != nil {
+			return err
 		}
-		return &GenericType{
-			Name:       t.Name,
-			TypeParams: newParams,
+		if expected == nil {
+			// the blank identifier gives nil no type to take
+			if isNil(rhsTypes[i]) {
+				return fmt.Errorf("use of untyped nil in assignment")
+			}
+			continue
+		}
+		if err := assignable(expected, rhsTypes[i], env, ctx); err != nil {
+			return fmt.Errorf("assignment type mismatch for %s: %v", types.ExprString(lhs), err)
 		}
 	}
-	return t
+
+	if define {
+		if len(newVars) == 0 {
+			return fmt.Errorf("no new variables on left side of :=")
+		}
+		for name, t := range newVars {
+			env[name] = &VarObj{Name: name, Type: t}
+		}
+	}
+	return nil
+}
+
+// isCommaOk reports whether expr can be assigned to two values, the second one reporting
+// whether it succeeded: a receive, like `v, ok := <-ch`, which fails if the channel is
+// closed, a type assertion, like `s, ok := x.(string)`, or a map index, like `v, ok := m[k]`.
+func isCommaOk(expr ast.Expr, env TypeEnv) bool {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.UnaryExpr:
+		return e.Op == token.ARROW
+	case *ast.TypeAssertExpr:
+		return e.Type != nil
+	case *ast.IndexExpr:
+		xt, err := InferType(e.X, env, nil)
+		if err != nil {
+			return false
+		}
+		_, ok := coreType(xt, env).(*MapType)
+		return ok && denotesValue(e.X, env)
+	}
+	return false
+}
+
+// inferAssignTarget infers the type of the left-hand side of an assignment.
+// Only variables, index expressions, field selectors and pointer indirections can be
+// assigned to. It returns nil for the blank identifier, which accepts any value.
+func inferAssignTarget(lhs ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	switch lhs := lhs.(type) {
+	case *ast.Ident:
+		if lhs.Name == "_" {
+			return nil, nil
+		}
+		return InferType(lhs, env, ctx.Child())
+	case *ast.ParenExpr:
+		return inferAssignTarget(lhs.X, env, ctx)
+	case *ast.SelectorExpr:
+		return InferType(lhs, env, ctx.Child())
+	case *ast.StarExpr:
+		xt, err := InferType(lhs.X, env, ctx.Child())
+		if err != nil {
+			return nil, err
+		}
+		pt, ok := xt.(*PointerType)
+		if !ok {
+			return nil, fmt.Errorf("invalid indirect of %s (type %s)", types.ExprString(lhs.X), FormatGo(xt))
+		}
+		return pt.Base, nil
+	case *ast.IndexExpr:
+		xt, err := InferType(lhs.X, env, ctx.Child())
+		if err != nil {
+			return nil, err
+		}
+		if isString(coreType(xt, env)) {
+			return nil, fmt.Errorf("cannot assign to %s (neither addressable nor a map index expression)", types.ExprString(lhs))
+		}
+		return inferIndex(lhs, xt, env, ctx)
+	}
+	return nil, fmt.Errorf("cannot assign to %T", lhs)
+}
+
+// singleCall returns the call that is the only expression of exprs, if it is one.
+func singleCall(exprs []ast.Expr) (*ast.CallExpr, bool) {
+	if len(exprs) != 1 {
+		return nil, false
+	}
+	call, ok := ast.Unparen(exprs[0]).(*ast.CallExpr)
+	return call, ok
+}
+
+// checkReturnTuple checks the results of the call returned by `return f()` against the
+// results of the function.
+func checkReturnTuple(call *ast.CallExpr, results []Type, env TypeEnv, ctx *InferenceContext) error {
+	t, err := InferType(call, env, ctx.Child(WithExpectedType(nil)))
+	if err != nil {
+		return err
+	}
+	tuple, ok := t.(*TupleType)
+	if !ok {
+		return fmt.Errorf("expected %d return values, got 1", len(results))
+	}
+	if len(tuple.Types) != len(results) {
+		return fmt.Errorf("expected %d return values, got %d", len(results), len(tuple.Types))
+	}
+	for i, result := range results {
+		if err := assignable(result, tuple.Types[i], env, ctx); err != nil {
+			return fmt.Errorf("result %d: cannot use %s as %s in return statement: %w", i+1, FormatGo(tuple.Types[i]), FormatGo(result), err)
+		}
+	}
+	return nil
+}
+
+func checkReturnType(result ast.Expr, expectedType Type, env TypeEnv, ctx *InferenceContext) error {
+	if err := checkValueExpr(result, env); err != nil {
+		return err
+	}
+	resultCtx := ctx.Child(
+		WithExpectedType(expectedType),
+		WithReturnValue(),
+	)
+	resultType, err := InferType(result, env, resultCtx)
+	if err != nil {
+		return err
+	}
+	if val := constantOf(result, env); val.Kind() != constant.Unknown && representable(val, expectedType, env) {
+		return nil
+	}
+	if err := assignable(expectedType, resultType, env, ctx); err != nil {
+		return mismatchError(result, expectedType, resultType, fmt.Errorf("cannot use %s as %s in return statement: %w", FormatGo(resultType), FormatGo(expectedType), err))
+	}
+	return nil
+}
+
+func inferGenericMethod(method GenericMethod, typeArgs []Type, args []ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if len(typeArgs) != len(method.TypeParams) {
+		params := make(TypeParamList, len(method.TypeParams))
+		for i, tp := range method.TypeParams {
+			if tv, ok := tp.(*TypeVariable); ok {
+				params[i].Name = tv.Name
+			}
+		}
+		args := make([]string, len(typeArgs))
+		for i, arg := range typeArgs {
+			args[i] = FormatGo(arg)
+		}
+		return nil, typeArgCountError(method.Name, token.NoPos, params, args, ctx)
+	}
+
+	// the type parameters are renamed first, so that binding them does not shadow
+	// the type parameters of the same name in env, like the T of the calling function
+	renamed, typeParams := typeVars.Rename(method.Method, method.TypeParams)
+	method.Method, method.TypeParams = renamed.(Method), typeParams
+
+	// Create a new environment with type parameters bound to concrete types
+	newEnv := make(TypeEnv)
+	for k, v := range env {
+		newEnv[k] = v
+	}
+	for i, param := range method.TypeParams {
+		newEnv[param.(*TypeVariable).Name] = typeArgs[i]
+	}
+
+	// Substitute type parameters in the method signature
+	substitutedMethod := substituteTypeParams(method.Method, method.TypeParams, typeArgs, NewTypeVisitor()).(Method)
+
+	// Check argument types
+	expanded, err := inferTupleArg(substitutedMethod.Params, args, env, newEnv, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if expanded {
+		args = nil
+	} else if len(args) != len(substitutedMethod.Params) {
+		return nil, &gerrors.ArityError{Want: len(substitutedMethod.Params), Got: len(args)}
+	}
+	if err := inferCallArgs(substitutedMethod.Params, args, env, newEnv, ctx); err != nil {
+		return nil, err
+	}
+
+	// Substitute type parameters in the result type
+	if len(substitutedMethod.Results) == 0 {
+		return noValueResult(ctx)
+	}
+	resultType := resultsType(substituteTypeParamsInSlice(substitutedMethod.Results, method.TypeParams, typeArgs, NewTypeVisitor()))
+	if err := checkExpectedResult(resultType, newEnv, ctx); err != nil {
+		return nil, err
+	}
+	return resultType, nil
+}
+
+// substituteTypeParams substitutes type parameters in a type with concrete types.
+// It uses a TypeVisitor to detect and handle circular references in the type structure.
+// The declared types are substituted once per visitor, so that those shared by many
+// others, like the structs of a package referring to each other, are not walked again
+// for every path reaching them.
+func substituteTypeParams(t Type, from, to []Type, visitor *TypeVisitor) Type {
+	if done, ok := visitor.substituted(t); ok {
+		return done
+	}
+	// circular reference check
+	if visitor.Visit(t) {
+		return t
+	}
+	defer visitor.Leave(t)
+
+	result := substituteType(t, from, to, visitor)
+	visitor.remember(t, result)
+	return result
+}
+
+func substituteType(t Type, from, to []Type, visitor *TypeVisitor) Type {
+	switch t := t.(type) {
+	case *TypeVariable:
+		for i, param := range from {
+			if TypesEqual(t, param) {
+				return to[i]
+			}
+		}
+	case *GenericType:
+		newParams := make([]Type, len(t.TypeParams))
+		for i, param := range t.TypeParams {
+			newParams[i] = substituteTypeParams(param, from, to, visitor)
+		}
+		var newFld map[string]Type
+		if t.Fields != nil {
+			newFld = make(map[string]Type, len(t.Fields))
+			for name, typ := range t.Fields {
+				newFld[name] = substituteTypeParams(typ, from, to, visitor)
+			}
+		}
+		var newMethods MethodSet
+		if t.Methods != nil {
+			newMethods = make(MethodSet, len(t.Methods))
+			for name, method := range t.Methods {
+				newMethods[name] = substituteMethod(method, from, to, visitor)
+			}
+		}
+		var newConstraints map[string]TypeConstraint
+		if t.Constraints != nil {
+			newConstraints = make(map[string]TypeConstraint, len(t.Constraints))
+			for name, constraint := range t.Constraints {
+				constraint.Types = substituteTypeParamsInSlice(constraint.Types, from, to, visitor)
+				newConstraints[name] = constraint
+			}
+		}
+		return visitor.arena.generic(GenericType{
+			Name:        t.Name,
+			TypeParams:  newParams,
+			Constraints: newConstraints,
+			Fields:      newFld,
+			Methods:     newMethods,
+			Params:      t.Params,
+			Signature:   substituteSignature(t.Signature, from, to, visitor),
+			IsInterface: t.IsInterface,
+			Pos:         t.Pos,
+			FieldOrder:  t.FieldOrder,
+			TypeSet:     substituteTypeSet(t.TypeSet, from, to, visitor),
+			origin:      substituteOrigin(t.origin, from, to, visitor),
+
+			UnderlyingType: substituteTypeParams(t.UnderlyingType, from, to, visitor),
+		})
+	case *SliceType:
+		return visitor.arena.slice(SliceType{
+			ElementType: substituteTypeParams(t.ElementType, from, to, visitor),
+		})
+	case *MapType:
+		return visitor.arena.mapType(MapType{
+			KeyType:   substituteTypeParams(t.KeyType, from, to, visitor),
+			ValueType: substituteTypeParams(t.ValueType, from, to, visitor),
+		})
+	case *FunctionType:
+		newParams := make([]Type, len(t.ParamTypes))
+		for i, param := range t.ParamTypes {
+			newParams[i] = substituteTypeParams(param, from, to, visitor)
+		}
+		newReturn := substituteTypeParams(t.ReturnType, from, to, visitor)
+		return visitor.arena.function(FunctionType{
+			ParamTypes: newParams,
+			ReturnType: newReturn,
+			IsVariadic: t.IsVariadic,
+			Effects:    t.Effects,
+		})
+	case *TupleType:
+		return visitor.arena.tuple(TupleType{Types: substituteTypeParamsInSlice(t.Types, from, to, visitor)})
+	case *PointerType:
+		return visitor.arena.pointer(PointerType{Base: substituteTypeParams(t.Base, from, to, visitor)})
+	case *ArrayType:
+		return visitor.arena.array(ArrayType{
+			ElementType: substituteTypeParams(t.ElementType, from, to, visitor),
+			Len:         t.Len,
+		})
+	case *ChanType:
+		return &ChanType{Dir: t.Dir, ElementType: substituteTypeParams(t.ElementType, from, to, visitor)}
+	case *ApproxType:
+		return &ApproxType{Base: substituteTypeParams(t.Base, from, to, visitor)}
+	case *TypeAlias:
+		return &TypeAlias{Name: t.Name, AliasedTo: substituteTypeParams(t.AliasedTo, from, to, visitor)}
+	case *VarObj:
+		return &VarObj{Name: t.Name, Type: substituteTypeParams(t.Type, from, to, visitor)}
+	case *ConstObj:
+		substituted := *t
+		substituted.Type = substituteTypeParams(t.Type, from, to, visitor)
+		return &substituted
+	case *TypeObj:
+		return &TypeObj{Name: t.Name, Type: substituteTypeParams(t.Type, from, to, visitor)}
+	case *FuncObj:
+		return &FuncObj{Name: t.Name, Type: substituteTypeParams(t.Type, from, to, visitor)}
+	case Method:
+		return substituteMethod(t, from, to, visitor)
+	case *GenericMethod:
+		// the method's own type parameters shadow the substituted ones, so the types
+		// substituted without them are not those of the enclosing substitution
+		from, to = withoutShadowed(from, to, t.TypeParams)
+		inner := &TypeVisitor{visited: visitor.visited, arena: visitor.arena}
+		return &GenericMethod{
+			Name:       t.Name,
+			TypeParams: t.TypeParams,
+			Method:     substituteMethod(t.Method, from, to, inner),
+		}
+	case *Interface:
+		return &Interface{Name: t.Name, Methods: substituteMethodSet(t.Methods, from, to, visitor)}
+	case *InterfaceType:
+		substituted := *t
+		substituted.Methods = substituteMethodSet(t.Methods, from, to, visitor)
+		if t.Embedded != nil {
+			substituted.Embedded = substituteTypeParamsInSlice(t.Embedded, from, to, visitor)
+		}
+		if t.Variants != nil {
+			substituted.Variants = substituteTypeParamsInSlice(t.Variants, from, to, visitor)
+		}
+		return &substituted
+	case *StructType:
+		substituted := *t
+		if t.Fields != nil {
+			substituted.Fields = make(map[string]Type, len(t.Fields))
+			for name, typ := range t.Fields {
+				substituted.Fields[name] = substituteTypeParams(typ, from, to, visitor)
+			}
+		}
+		substituted.Methods = substituteMethodSet(t.Methods, from, to, visitor)
+		return &substituted
+	case *ExtensibleStruct:
+		fields := make(map[string]Type, len(t.Fields))
+		for name, typ := range t.Fields {
+			fields[name] = substituteTypeParams(typ, from, to, visitor)
+		}
+		var rest Type
+		if t.Rest != nil {
+			rest = substituteTypeParams(t.Rest, from, to, visitor)
+		}
+		return withRow(fields, rest)
+	case *TypeConstraint:
+		substituted := *t
+		substituted.Types = substituteTypeParamsInSlice(t.Types, from, to, visitor)
+		if t.Excluded != nil {
+			substituted.Excluded = substituteTypeParamsInSlice(t.Excluded, from, to, visitor)
+		}
+		return &substituted
+	}
+	return t
+}
+
+// withoutShadowed removes the type parameters that are redeclared by params from the substitution.
+func withoutShadowed(from, to []Type, params []Type) ([]Type, []Type) {
+	var newFrom, newTo []Type
+	for i, param := range from {
+		if !containsType(params, param) {
+			newFrom = append(newFrom, param)
+			newTo = append(newTo, to[i])
+		}
+	}
+	return newFrom, newTo
 }
 
 func CalculateMethodSet(t Type) MethodSet {
@@ -672,10 +1265,20 @@ func CalculateMethodSet(t Type) MethodSet {
 	case *StructType:
 		return calculateStructMethodSet(t, false)
 	case *InterfaceType:
-		return t.Methods
+		return interfaceMethods(t)
+	case *NamedType:
+		return namedMethodSet(t, false)
+	case *GenericType:
+		return CalculateMethodSet(t.Underlying())
 	case *PointerType:
-		if st, ok := t.Base.(*StructType); ok {
-			return calculateStructMethodSet(st, true)
+		if gt, ok := t.Base.(*GenericType); ok {
+			return CalculateMethodSet(&PointerType{Base: gt.Underlying()})
+		}
+		switch base := t.Base.(type) {
+		case *StructType:
+			return calculateStructMethodSet(base, true)
+		case *NamedType:
+			return namedMethodSet(base, true)
 		}
 	default:
 		return MethodSet{}
@@ -693,246 +1296,754 @@ func calculateStructMethodSet(s *StructType, isPtr bool) MethodSet {
 		}
 	}
 
-	// methods from embedded fields
-	for _, fld := range s.Fields {
-		if embeddedType, ok := fld.(*StructType); ok {
-			embeddedMethods := calculateStructMethodSet(embeddedType, false)
-			for name, method := range embeddedMethods {
-				if _, exists := ms[name]; !exists {
-					ms[name] = method
-				}
+	// methods from embedded fields, in the order of the field names so that
+	// a method promoted from several fields always resolves to the same one.
+	// The pointer receivers of an embedded field are promoted to a pointer to s, and
+	// to s itself if the field is a pointer, like `*object`.
+	for _, name := range sortedKeys(s.Fields) {
+		for name, method := range embeddedMethods(name, s.Fields[name], isPtr) {
+			if _, exists := ms[name]; !exists {
+				ms[name] = method
 			}
 		}
 	}
 	return ms
 }
 
-func inferFunctionType(ft *ast.FuncType, env TypeEnv, ctx *InferenceContext) (Type, error) {
-	var (
-		paramTypes []Type
-		returnType Type
-	)
+// embeddedMethods returns the methods promoted from the field name of a struct, if it may
+// be embedded: an embedded struct, see embeddedStruct, or a field named after its type,
+// like an interface in `struct{ sort.Interface }` or a defined type like `Celsius`.
+// isPtr tells whether the methods are those of a pointer to the struct.
+func embeddedMethods(name string, field Type, isPtr bool) MethodSet {
+	if st, pointer := embeddedStruct(name, field); st != nil {
+		return calculateStructMethodSet(st, isPtr || pointer)
+	}
+	ptr, pointer := field.(*PointerType)
+	if pointer {
+		field = ptr.Base
+	}
+	switch t := field.(type) {
+	case *InterfaceType:
+		if t.Name == name && !pointer {
+			return interfaceMethods(t)
+		}
+	case *NamedType:
+		if t.Name == name {
+			// the methods of an interface are promoted, unless it is embedded as a pointer
+			_, iface := underlying(t).(*InterfaceType)
+			return namedMethodSet(t, pointer || isPtr && !iface)
+		}
+	case *GenericType:
+		if t.Name == name && t.UnderlyingType != nil {
+			return namedMethodSet(t.named(), isPtr || pointer)
+		}
+	case *TypeConstant:
+		if t.Name == name && !pointer {
+			if iface, ok := interfaceOf(t); ok {
+				return iface.Methods
+			}
+		}
+	}
+	return nil
+}
 
-	paramCtx := NewInferenceContext(WithFunctionArg())
+// embeddedStruct returns the struct type of the field name, if it may be an embedded
+// struct, and whether the field is a pointer. Fields of struct type are taken as embedded,
+// but a pointer field only if it is named after its base type, like `*object`, since
+// pointers may refer back to the struct, like `parent *Node`.
+func embeddedStruct(name string, field Type) (*StructType, bool) {
+	ptr, pointer := field.(*PointerType)
+	if pointer {
+		field = ptr.Base
+	}
+	var st *StructType
+	var typeName string
+	switch t := field.(type) {
+	case *StructType:
+		st, typeName = t, t.Name
+	case *GenericType:
+		st, _ = t.Underlying().(*StructType)
+		typeName = t.Name
+	}
+	if st == nil || pointer && typeName != name {
+		return nil, false
+	}
+	return st, pointer
+}
+
+// namedMethodSet returns the method set of a defined type that is not a struct, like
+// `type Celsius float64`: the methods of its underlying type if it is an interface,
+// and otherwise the methods declared on it, with the pointer receivers only if isPtr.
+// A pointer to an interface has no methods.
+func namedMethodSet(nt *NamedType, isPtr bool) MethodSet {
+	if iface, ok := underlying(nt).(*InterfaceType); ok {
+		if isPtr {
+			return MethodSet{}
+		}
+		return interfaceMethods(iface)
+	}
+	ms := make(MethodSet, len(nt.Methods))
+	for name, method := range nt.Methods {
+		if isPtr || !method.IsPointer {
+			ms[name] = method
+		}
+	}
+	return ms
+}
+
+// signature is the inferred form of an `ast.FuncType`.
+type signature struct {
+	Params     []Type
+	Results    []Type
+	IsVariadic bool
+}
+
+// functionType converts the signature into a FunctionType.
+// The results are collapsed into a single return type, or a tuple if there are several.
+func (sig *signature) functionType() *FunctionType {
+	var returnType Type
+	if len(sig.Results) > 0 {
+		returnType = resultsType(sig.Results)
+	}
+	return &FunctionType{
+		ParamTypes: sig.Params,
+		ReturnType: returnType,
+		IsVariadic: sig.IsVariadic,
+	}
+}
+
+// buildSignature infers the parameter and result types of a function signature.
+// It is shared by function types, function literals and function declarations.
+//
+// If ctx expects a function type, the expected parameter and result types are
+// passed down as the expected type of the corresponding fields.
+func buildSignature(ft *ast.FuncType, env TypeEnv, ctx *InferenceContext) (*signature, error) {
+	var expected *FunctionType
+	if ctx != nil {
+		expected, _ = ctx.ExpectedType.(*FunctionType)
+	}
+
+	sig := &signature{}
 	if ft.Params != nil {
+		var expectedParams []Type
+		if expected != nil {
+			expectedParams = expected.ParamTypes
+		}
+		params, err := inferFieldList(ft.Params, expectedParams, env, ctx.Child(WithFunctionArg()))
+		if err != nil {
+			return nil, fmt.Errorf("error inferring parameter type: %v", err)
+		}
+		sig.Params = params
+
 		for i, fld := range ft.Params.List {
-			var expectedParamType Type
-			if ctx != nil && ctx.ExpectedType != nil {
-				if expectedFt, ok := ctx.ExpectedType.(*FunctionType); ok && i < len(expectedFt.ParamTypes) {
-					expectedParamType = expectedFt.ParamTypes[i]
-				}
+			if _, ok := fld.Type.(*ast.Ellipsis); !ok {
+				continue
 			}
-			paramCtx.ExpectedType = expectedParamType
-			fldt, err := InferType(fld.Type, env, paramCtx)
-			if err != nil {
-				return nil, err
+			if i != len(ft.Params.List)-1 || len(fld.Names) > 1 {
+				return nil, errors.New("can only use ... with final parameter in list")
 			}
-			paramTypes = append(paramTypes, fldt)
+			sig.IsVariadic = true
 		}
 	}
-	returnCtx := NewInferenceContext(WithReturnValue())
+
 	if ft.Results != nil {
-		if len(ft.Results.List) == 1 {
-			if ctx != nil && ctx.ExpectedType != nil {
-				if expectedFt, ok := ctx.ExpectedType.(*FunctionType); ok {
-					returnCtx.ExpectedType = expectedFt.ReturnType
-				}
-			}
-			var err error
-			returnType, err = InferType(ft.Results.List[0].Type, env, returnCtx)
-			if err != nil {
-				return nil, err
-			}
-		} else if len(ft.Results.List) > 1 {
-			tupleTypes := make([]Type, len(ft.Results.List))
-			for i, result := range ft.Results.List {
-				resultType, err := InferType(result.Type, env, returnCtx)
-				if err != nil {
-					return nil, err
-				}
-				tupleTypes[i] = resultType
+		var expectedResults []Type
+		if expected != nil && expected.ReturnType != nil {
+			if tuple, ok := expected.ReturnType.(*TupleType); ok {
+				expectedResults = tuple.Types
+			} else {
+				expectedResults = []Type{expected.ReturnType}
 			}
-			returnType = &TupleType{Types: tupleTypes}
 		}
+		results, err := inferFieldList(ft.Results, expectedResults, env, ctx.Child(WithReturnValue()))
+		if err != nil {
+			return nil, err
+		}
+		sig.Results = results
 	}
-	funcType := &FunctionType{
-		ParamTypes: paramTypes,
-		ReturnType: returnType,
+
+	return sig, nil
+}
+
+// inferFieldList infers the types of a parameter or result list,
+// repeating the type for each name in fields like `(a, b int)`.
+func inferFieldList(fieldList *ast.FieldList, expected []Type, env TypeEnv, ctx *InferenceContext) ([]Type, error) {
+	var types []Type
+	for _, field := range fieldList.List {
+		fieldCtx := ctx.Child()
+		if len(types) < len(expected) {
+			fieldCtx.ExpectedType = expected[len(types)]
+		}
+		if err := checkTypeExpr(field.Type, env); err != nil {
+			return nil, err
+		}
+		fieldType, err := InferType(field.Type, env, fieldCtx)
+		if err != nil {
+			return nil, err
+		}
+		if fieldType == nil {
+			return nil, fmt.Errorf("unknown type for field")
+		}
+		if len(field.Names) == 0 {
+			types = append(types, fieldType)
+			continue
+		}
+		for range field.Names {
+			types = append(types, fieldType)
+		}
 	}
+	return types, nil
+}
 
-	// if context has expected type, check the function type compatibility
+// checkExpectedFunction checks the function type against the function type
+// expected by the context, if any.
+func checkExpectedFunction(funcType *FunctionType, ctx *InferenceContext) (Type, error) {
 	if ctx != nil && ctx.ExpectedType != nil {
-		if expectedFunc, ok := ctx.ExpectedType.(*FunctionType); ok {
-			if err := checkFunctionCompatibility(funcType, expectedFunc); err != nil {
+		if expected, ok := ctx.ExpectedType.(*FunctionType); ok {
+			if err := checkFunctionCompatibility(funcType, expected); err != nil {
 				return nil, fmt.Errorf("function type incompatible with expected type: %v", err)
 			}
 		}
 	}
-
 	return funcType, nil
 }
 
-func inferParams(fieldList *ast.FieldList, env TypeEnv, ctx *InferenceContext) ([]Type, error) {
-	if fieldList == nil {
-		return nil, nil
+// findMethod returns the method methodName of recvType. The methods of a type parameter
+// are those of its constraint in env, like `String` for `X Stringer`.
+func findMethod(recvType Type, methodName string, env TypeEnv) (Method, error) {
+	// the methods of a pointer, like `s.Push` for a *Stack[T], are those of its base type
+	if ptr, ok := recvType.(*PointerType); ok {
+		recvType = unalias(ptr.Base)
 	}
-
-	var params []Type
-	for _, field := range fieldList.List {
-		fieldType, err := InferType(field.Type, env, ctx)
-		if err != nil {
-			return nil, fmt.Errorf("error inferring parameter type: %v", err)
-		}
-		if fieldType == nil {
-			return nil, fmt.Errorf("unknown type for parameter")
+	if gt, ok := recvType.(*GenericType); ok {
+		// an instance made before the methods of its declaration were declared, like the
+		// type of a field `s slab[T]`, takes them from the declaration
+		if _, ok := gt.Methods[methodName]; !ok && gt.origin != nil {
+			if method, ok := gt.origin.Decl.Methods[methodName]; ok {
+				method = substituteMethod(method, gt.origin.Decl.TypeParams, gt.origin.Args, NewTypeVisitor())
+				method.Receiver = gt
+				return method, nil
+			}
 		}
-		// multiple names in a field. like (a, b int)
-		if len(field.Names) == 0 {
-			params = append(params, fieldType)
+		if gt.UnderlyingType != nil {
+			recvType = gt.named()
 		} else {
-			for range field.Names {
-				params = append(params, fieldType)
+			recvType = gt.Underlying()
+		}
+	}
+	switch t := recvType.(type) {
+	case *TypeConstant:
+		// the predeclared error has the method Error
+		if iface, ok := interfaceOf(t); ok {
+			if method, ok := iface.Methods[methodName]; ok {
+				return method, nil
+			}
+		}
+	case *StructType:
+		// the promoted methods of the embedded fields too
+		if method, ok := calculateStructMethodSet(t, true)[methodName]; ok {
+			return method, nil
+		}
+	case *InterfaceType:
+		if method, ok := interfaceMethods(t)[methodName]; ok {
+			return method, nil
+		}
+	case *NamedType:
+		// the pointer receivers too, since the receiver may be addressable
+		if method, ok := namedMethodSet(t, true)[methodName]; ok {
+			return method, nil
+		}
+		if method, ok := namedMethodSet(t, false)[methodName]; ok {
+			return method, nil
+		}
+	case *TypeVariable:
+		if tc, ok := operandConstraint(t, env).(*TypeConstraint); ok {
+			for _, iface := range tc.Interfaces {
+				if method, ok := iface.Methods[methodName]; ok {
+					return method, nil
+				}
 			}
 		}
 	}
-	return params, nil
+	return Method{}, fmt.Errorf("method %s not found in type %s", methodName, FormatGo(recvType))
 }
 
-func inferResult(results *ast.FieldList, env TypeEnv, ctx *InferenceContext) (Type, error) {
-	if results == nil || len(results.List) == 0 {
-		return nil, nil
+// findField returns the type of the field name of t, a struct or a pointer to one,
+// including the fields promoted from its embedded structs, like `s.Name` for a field of an
+// embedded Base. The shallowest field is found first, and the fields at the same depth in
+// the order of their names, like the promoted methods in calculateStructMethodSet.
+func findField(t Type, name string) (Type, bool) {
+	if ptr, ok := t.(*PointerType); ok {
+		t = unalias(ptr.Base)
 	}
-
-	if len(results.List) == 1 && len(results.List[0].Names) == 0 {
-		// single return value
-		return InferType(results.List[0].Type, env, ctx)
+	if gt, ok := t.(*GenericType); ok {
+		t = gt.Underlying()
 	}
-
-	// multiple return values. like tuple type or anonymous struct type
-	var tt []Type
-	for _, fld := range results.List {
-		fldType, err := InferType(fld.Type, env, ctx)
-		if err != nil {
-			if strings.HasPrefix(err.Error(), "unknown identifier") {
-				return nil, fmt.Errorf("unknown type: %s", strings.TrimPrefix(err.Error(), "unknown identifier: "))
+	var st *StructType
+	switch t := t.(type) {
+	case *StructType:
+		st = t
+	case *NamedType:
+		st, _ = underlying(t).(*StructType)
+	case *ExtensibleStruct:
+		fieldType, ok := t.Fields[name]
+		return fieldType, ok
+	}
+	if st == nil {
+		return nil, false
+	}
+	seen := make(map[*StructType]bool)
+	for level := []*StructType{st}; len(level) > 0; {
+		var next []*StructType
+		for _, s := range level {
+			if seen[s] {
+				continue
 			}
-			return nil, err
-		}
-		if len(fld.Names) == 0 {
-			tt = append(tt, fldType)
-		} else {
-			for range fld.Names {
-				tt = append(tt, fldType)
+			seen[s] = true
+			if fieldType, ok := s.Fields[name]; ok {
+				return fieldType, true
+			}
+			for _, field := range sortedKeys(s.Fields) {
+				if embedded, _ := embeddedStruct(field, s.Fields[field]); embedded != nil {
+					next = append(next, embedded)
+				}
 			}
 		}
+		level = next
 	}
+	return nil, false
+}
 
-	if len(tt) == 1 {
-		return tt[0], nil
+// inferSelector infers the type of a selector that is not called directly.
+// It handles method expressions like `T.M` or `(*T).M`, whose receiver becomes
+// the first parameter, and otherwise method values and field selections.
+func inferSelector(sel *ast.SelectorExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if pkg, ok := packageOf(sel.X, env); ok {
+		return inferQualifiedIdent(pkg, sel.Sel)
+	}
+	if recvType, ok := methodExprReceiver(sel.X, env); ok {
+		method, ok := CalculateMethodSet(recvType)[sel.Sel.Name]
+		if !ok {
+			if _, isPtr := recvType.(*PointerType); !isPtr {
+				if _, ok := CalculateMethodSet(&PointerType{Base: recvType})[sel.Sel.Name]; ok {
+					return nil, fmt.Errorf("invalid method expression %s.%s (needs pointer receiver (*%s).%s)", sel.X, sel.Sel.Name, sel.X, sel.Sel.Name)
+				}
+			}
+			return nil, fmt.Errorf("method %s not found in type %s", sel.Sel.Name, FormatGo(recvType))
+		}
+		recv := method.ReceiverType()
+		if recv == nil {
+			recv = recvType
+		}
+		return methodFunctionType(method, append([]Type{recv}, method.Params...)), nil
 	}
 
-	tupleType := &TupleType{Types: tt}
-	// if context has expected type, check the tuple type compatibility
-	if ctx != nil && ctx.ExpectedType != nil {
-		if expected, ok := ctx.ExpectedType.(*TupleType); ok {
-			if err := checkTupleCompatibility(tupleType, expected); err != nil {
-				return nil, fmt.Errorf("tuple type incompatible with expected type: %v", err)
+	xType, err := InferType(sel.X, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	base := xType
+	if ptr, ok := xType.(*PointerType); ok {
+		base = ptr.Base
+	}
+	if gt, ok := base.(*GenericType); ok {
+		base = gt.Underlying()
+	}
+	// with row polymorphism, selecting a field of a value of unknown type constrains it to have that field
+	if ctx.Enabled(ExtRowPolymorphism) {
+		switch resolve(base, env).(type) {
+		case *TypeVariable, *ExtensibleStruct:
+			fieldType := freshTypeVariable("field")
+			if err := hasField(base, sel.Sel.Name, fieldType, env, ctx); err != nil {
+				return nil, err
 			}
+			return resolve(fieldType, env), nil
 		}
 	}
+	if fieldType, ok := findField(base, sel.Sel.Name); ok {
+		return fieldType, nil
+	}
+	method, err := findMethod(base, sel.Sel.Name, env)
+	if err != nil {
+		return nil, err
+	}
+	return methodFunctionType(method, method.Params), nil
+}
 
-	return tupleType, nil
+// packageOf reports whether x is the name of an imported package, and returns the package.
+func packageOf(x ast.Expr, env TypeEnv) (*PackageType, bool) {
+	ident, ok := x.(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	pkg, ok := env[ident.Name].(*PackageType)
+	return pkg, ok
 }
 
-func findMethod(recvType Type, methodName string) (Method, error) {
-	switch t := recvType.(type) {
-	case *StructType:
-		if method, ok := t.Methods[methodName]; ok {
-			return method, nil
+// isQualifiedIdent reports whether sel selects a member of an imported package, like `strings.Cut`.
+func isQualifiedIdent(sel *ast.SelectorExpr, env TypeEnv) bool {
+	_, ok := packageOf(sel.X, env)
+	return ok
+}
+
+// inferQualifiedIdent infers the type of the exported member name of the package pkg,
+// like `ast.Expr` or `slices.Index`. Generic members are instantiated by the caller,
+// like any generic declaration, as in `list.List[int]`.
+func inferQualifiedIdent(pkg *PackageType, name *ast.Ident) (Type, error) {
+	if !name.IsExported() {
+		return nil, fmt.Errorf("name %s not exported by package %s", name.Name, pkg.Name)
+	}
+	member, ok := pkg.Members[name.Name]
+	if !ok {
+		return nil, fmt.Errorf("undefined: %s.%s", pkg.Name, name.Name)
+	}
+	member = unwrapObject(member)
+	if alias, ok := member.(*TypeAlias); ok {
+		return alias.AliasedTo, nil
+	}
+	return member, nil
+}
+
+// methodExprReceiver reports whether x denotes a type in a method expression,
+// and returns that type. Since the environment maps both values and types,
+// an identifier denotes a type only if it is bound to the struct of the same name.
+func methodExprReceiver(x ast.Expr, env TypeEnv) (Type, bool) {
+	if paren, ok := x.(*ast.ParenExpr); ok {
+		star, ok := paren.X.(*ast.StarExpr)
+		if !ok {
+			return nil, false
 		}
-	case *InterfaceType:
-		if method, ok := t.Methods[methodName]; ok {
-			return method, nil
+		base, ok := methodExprReceiver(star.X, env)
+		if !ok {
+			return nil, false
 		}
+		return &PointerType{Base: base}, true
+	}
+	ident, ok := x.(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	st, ok := env[ident.Name].(*StructType)
+	if !ok || st.Name != ident.Name {
+		return nil, false
+	}
+	return st, true
+}
+
+// methodFunctionType converts a method into a function type with the given parameters.
+func methodFunctionType(method Method, params []Type) *FunctionType {
+	var returnType Type
+	if len(method.Results) > 0 {
+		returnType = resultsType(method.Results)
+	}
+	return &FunctionType{
+		ParamTypes: params,
+		ReturnType: returnType,
+		IsVariadic: method.IsVariadic,
 	}
-	return Method{}, fmt.Errorf("method %s not found in type %v", methodName, recvType)
 }
 
 func inferMethodCall(method Method, args []ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
-	if len(args) != len(method.Params) {
-		return nil, fmt.Errorf("expected %d arguments, got %d", len(method.Params), len(args))
+	expanded, err := inferTupleArg(method.Params, args, env, env, ctx)
+	if err != nil {
+		return nil, err
 	}
-	for i, arg := range args {
-		argContext := NewInferenceContext(
-			WithExpectedType(method.Params[i]),
-			WithFunctionArg(),
-		)
-		// copy the previous context into the new context
-		if ctx != nil {
-			argContext.IsAssignment = ctx.IsAssignment
-			argContext.IsReturnValue = ctx.IsReturnValue
-			argContext.IsFunctionArg = ctx.IsFunctionArg
-		}
-		argType, err := InferType(arg, env, argContext)
-		if err != nil {
-			return nil, err
-		}
-		if err := Unify(method.Params[i], argType, env); err != nil {
-			return nil, fmt.Errorf("argument type mismatch for arg %d: %v", i, err)
-		}
+	if expanded {
+		args = nil
+	} else if len(args) != len(method.Params) {
+		return nil, &gerrors.ArityError{Want: len(method.Params), Got: len(args)}
+	}
+	if err := inferCallArgs(method.Params, args, env, env, ctx); err != nil {
+		return nil, err
 	}
 	if len(method.Results) == 0 {
-		return &TypeConstant{Name: "void"}, nil
+		return noValueResult(ctx)
 	}
-	resultType := method.Results[0]
-	if ctx != nil && ctx.ExpectedType != nil {
-		if err := Unify(resultType, ctx.ExpectedType, env); err != nil {
-			return nil, fmt.Errorf("return type mismatch: %v", err)
-		}
+	resultType := resultsType(method.Results)
+	if err := checkExpectedResult(resultType, env, ctx); err != nil {
+		return nil, err
 	}
 	return resultType, nil
 }
 
+// genericFunctionSignature returns the signature of an instantiated generic function.
+// Generic functions can only be called once all their type parameters are instantiated.
+func genericFunctionSignature(gt *GenericType) (*FunctionType, error) {
+	params := gt.TypeParamList()
+	for i := range gt.TypeParams {
+		if gt.isOpen(i) {
+			return nil, fmt.Errorf("cannot call generic function %s without instantiating type parameter %s", gt.Name, params.name(i))
+		}
+	}
+	return gt.Underlying().(*FunctionType), nil
+}
+
+// inferValueCall infers the call of a function value of type funcTyp, like `f(x)` or
+// `s.Less(a, b)` for a field of function type.
+func inferValueCall(funcTyp Type, call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	// a value of a defined function type, like `type Importer func(string) ...`, or of a
+	// type parameter with a function core type, calls its underlying function
+	if ft, ok := coreType(funcTyp, env).(*FunctionType); ok {
+		funcTyp = ft
+	}
+	if ft, ok := funcTyp.(*FunctionType); ok && ft.IsVariadic {
+		expanded := *ft
+		expanded.ParamTypes = variadicParams(ft.ParamTypes, true, call)
+		funcTyp = &expanded
+	}
+	return inferFunctionCall(funcTyp, call.Args, env, ctx)
+}
+
 func inferFunctionCall(funcTyp Type, args []ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
 	ft, ok := funcTyp.(*FunctionType)
 	if !ok {
 		return nil, ErrNotAFunction
 	}
-	if len(args) != len(ft.ParamTypes) {
-		return nil, fmt.Errorf("expected %d arguments, got %d", len(ft.ParamTypes), len(args))
+	expanded, err := inferTupleArg(ft.ParamTypes, args, env, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if expanded {
+		args = nil
+	} else if len(args) != len(ft.ParamTypes) {
+		return nil, &gerrors.ArityError{Want: len(ft.ParamTypes), Got: len(args)}
+	}
+	if err := inferCallArgs(ft.ParamTypes, args, env, env, ctx); err != nil {
+		return nil, err
+	}
+	if ft.ReturnType == nil || isNoValue(ft.ReturnType) {
+		return noValueResult(ctx)
+	}
+	if err := checkExpectedResult(ft.ReturnType, env, ctx); err != nil {
+		return nil, err
+	}
+	return ft.ReturnType, nil
+}
+
+// variadicParams returns the types of the parameters the arguments of call are passed to:
+// the arguments of the variadic parameter of a function, like `xs ...int`, are each of its
+// element type, unless the slice is passed on as is, like `f(xs...)`.
+func variadicParams(params []Type, variadic bool, call *ast.CallExpr) []Type {
+	n := len(params) - 1
+	if !variadic || call.Ellipsis.IsValid() || n < 0 || len(call.Args) < n {
+		return params
+	}
+	slice, ok := params[n].(*SliceType)
+	if !ok {
+		return params
+	}
+	expanded := append([]Type(nil), params[:n]...)
+	for range call.Args[n:] {
+		expanded = append(expanded, slice.ElementType)
+	}
+	return expanded
+}
+
+// checkExpectedResult checks the result type of a call against the type expected by ctx,
+// if any. Several results are left to the context, which reports using them as one value.
+func checkExpectedResult(resultType Type, env TypeEnv, ctx *InferenceContext) error {
+	if ctx == nil || ctx.ExpectedType == nil {
+		return nil
 	}
+	if _, ok := resultType.(*TupleType); ok {
+		return nil
+	}
+	if err := assignable(ctx.ExpectedType, resultType, env, ctx); err != nil {
+		return fmt.Errorf("return type mismatch: %v", err)
+	}
+	return nil
+}
+
+// inferCallArgs infers the types of the arguments of a call, in env, and unifies them with
+// the parameter types all at once in unifyEnv, so that every mismatching argument is reported.
+// The arguments inherit the assignment, return value and function argument flags of ctx.
+// An argument that cannot be inferred stops the call, unless ctx collects errors.
+func inferCallArgs(params []Type, args []ast.Expr, env, unifyEnv TypeEnv, ctx *InferenceContext) error {
+	errs := ctx.errorList()
+	pairs := make([]TypePair, 0, len(args))
 	for i, arg := range args {
-		argContext := NewInferenceContext(
-			WithExpectedType(ft.ParamTypes[i]),
+		argContext := ctx.Child(
+			WithExpectedType(params[i]),
 			WithFunctionArg(),
 		)
-		if ctx != nil {
-			argContext.IsAssignment = ctx.IsAssignment
-			argContext.IsReturnValue = ctx.IsReturnValue
-			argContext.IsFunctionArg = ctx.IsFunctionArg
+		errs.nest(argContext)
+		err := checkValueExpr(arg, env)
+		var argType Type
+		if err == nil {
+			argType, err = InferType(arg, env, argContext)
 		}
-		argType, err := InferType(arg, env, argContext)
 		if err != nil {
-			return nil, err
+			if !errs.add(err) {
+				return errs.err()
+			}
+			continue
+		}
+		// a generic function passed without type arguments, like `Identity` for a
+		// `func(int) int` parameter, is instantiated with those its parameter type infers
+		if gt, ok := argType.(*GenericType); ok && gt.Signature != nil && needsTypeArgs(gt) {
+			if argType, err = inferFuncArgInstance(arg, gt, params[i], env, ctx); err != nil {
+				if !errs.add(err) {
+					return errs.err()
+				}
+				continue
+			}
+		}
+		// an untyped constant, like `1` for a float64 parameter, converts to the parameter type
+		if val := constantOf(arg, env); val.Kind() != constant.Unknown && representable(val, params[i], env) {
+			continue
 		}
-		if err := Unify(ft.ParamTypes[i], argType, env); err != nil {
-			return nil, fmt.Errorf("argument type mismatch for arg %d: %v", i, err)
+		// a value implementing an interface parameter, like any, is converted to it, and
+		// a value of a defined type passed for a type literal, like a MethodSet for a
+		// map[string]Method, by its underlying type
+		if assignable(params[i], argType, unifyEnv, ctx) == nil {
+			continue
 		}
+		pairs = append(pairs, TypePair{Left: params[i], Right: argType, Context: fmt.Sprintf("argument type mismatch for arg %d", i+1)})
+	}
+	s, err := unifyAll(pairs, unifyEnv, ctx)
+	s.bindIn(unifyEnv)
+	if err != nil {
+		errs.add(err)
+	}
+	if len(errs.errs) > 0 {
+		return errs.err()
+	}
+	return nil
+}
+
+// resultsType returns the type of a call producing the given results.
+// Calls with multiple results produce a tuple, like `(int, error)`.
+func resultsType(results []Type) Type {
+	if len(results) == 1 {
+		return results[0]
+	}
+	return &TupleType{Types: results}
+}
+
+// inferTupleArg handles the special form `f(g())` where the multiple results of `g`
+// are passed as the parameters of `f`. It reports whether the argument was expanded.
+// The argument is inferred in env, and its results are unified with params in unifyEnv.
+func inferTupleArg(params []Type, args []ast.Expr, env, unifyEnv TypeEnv, ctx *InferenceContext) (bool, error) {
+	if len(args) != 1 || len(params) < 2 {
+		return false, nil
+	}
+	call, ok := args[0].(*ast.CallExpr)
+	if !ok {
+		return false, nil
+	}
+	argType, err := InferType(call, env, ctx.Child(WithFunctionArg()))
+	if err != nil {
+		return false, err
+	}
+	tuple, ok := argType.(*TupleType)
+	if !ok {
+		return false, nil
+	}
+	if len(tuple.Types) != len(params) {
+		return false, &gerrors.ArityError{Want: len(params), Got: len(tuple.Types)}
 	}
-	resultType := ft.ReturnType
+	pairs := make([]TypePair, len(params))
+	for i, param := range params {
+		pairs[i] = TypePair{Left: param, Right: tuple.Types[i], Context: fmt.Sprintf("argument type mismatch for arg %d", i+1)}
+	}
+	s, err := unifyAll(pairs, unifyEnv, ctx)
+	if err != nil {
+		return false, err
+	}
+	s.bindIn(unifyEnv)
+	return true, nil
+}
+
+// noValueResult returns the type of a call without results.
+// Such a call is only valid as a statement, so it is an error if a value is expected.
+func noValueResult(ctx *InferenceContext) (Type, error) {
 	if ctx != nil && ctx.ExpectedType != nil {
-		if err := Unify(resultType, ctx.ExpectedType, env); err != nil {
-			return nil, fmt.Errorf("return type mismatch: %v", err)
-		}
+		return nil, ErrNoValueUsed
 	}
-	return ft.ReturnType, nil
+	return &NoValueType{}, nil
+}
+
+// typeArgCountError reports an instantiation of the declaration name with the wrong number
+// of type arguments. It names the declared parameters, with the position of the declaration
+// if ctx has a file set, and either the first extra argument or the parameters left without
+// an argument, like:
+//
+//	too many type arguments for Pair[K, V] declared at pair.go:3:6: expected 2, got 3 (extra argument #2 bool)
+func typeArgCountError(name string, pos token.Pos, params TypeParamList, args []string, ctx *InferenceContext) error {
+	decl := fmt.Sprintf("%s[%s]", name, params.names())
+	if declared := at(ctx, pos); declared != "" {
+		decl += " declared" + declared
+	}
+	if len(args) > len(params) {
+		return fmt.Errorf("too many type arguments for %s: expected %d, got %d (extra argument #%d %s)",
+			decl, len(params), len(args), len(params)+1, args[len(params)])
+	}
+	missing := make([]string, 0, len(params)-len(args))
+	for i := len(args); i < len(params); i++ {
+		missing = append(missing, params.name(i))
+	}
+	return fmt.Errorf("not enough type arguments for %s: expected %d, got %d (missing %s)",
+		decl, len(params), len(args), strings.Join(missing, ", "))
+}
+
+// typeArgString formats a type argument given either as an expression or as a type.
+func typeArgString(arg interface{}) string {
+	switch a := arg.(type) {
+	case ast.Expr:
+		return types.ExprString(a)
+	case Type:
+		return FormatGo(a)
+	}
+	return fmt.Sprint(arg)
+}
+
+// instantiateAt instantiates gt like InstantiateGenericType, recording pos
+// as the position of the instantiation in its Origin.
+func instantiateAt(pos token.Pos, gt *GenericType, typeArgs []interface{}, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	t, err := InstantiateGenericType(gt, typeArgs, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if instance, ok := t.(*GenericType); ok && instance.origin != nil {
+		instance.origin.Pos = pos
+	}
+	return t, nil
+}
+
+// substituteOrigin substitutes the type arguments recorded in an instantiation.
+func substituteOrigin(origin *Instantiation, from, to []Type, visitor *TypeVisitor) *Instantiation {
+	if origin == nil {
+		return nil
+	}
+	substituted := *origin
+	substituted.Args = substituteTypeParamsInSlice(origin.Args, from, to, visitor)
+	return &substituted
+}
+
+// instanceParts returns the generic declaration and the type arguments of the
+// instantiation expr, like `Pair` and `K, V` for `Pair[K, V]`.
+func instanceParts(expr ast.Expr) (ast.Expr, []ast.Expr) {
+	if list, ok := expr.(*ast.IndexListExpr); ok {
+		return list.X, list.Indices
+	}
+	index := expr.(*ast.IndexExpr)
+	return index.X, []ast.Expr{index.Index}
 }
 
 // InstantiateGenericType instantiates a generic type with the given type arguments.
 // It can handle both AST expressions and concrete Type instances as type arguments.
 func InstantiateGenericType(gt *GenericType, typeArgs []interface{}, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	params := gt.TypeParamList()
+	explicit := len(typeArgs)
+	if explicit < len(gt.TypeParams) && ctx.Enabled(ExtTypeParamDefaults) && params.hasDefaultsFrom(explicit) {
+		typeArgs = append(typeArgs[:explicit:explicit], params.defaults(explicit)...)
+	}
 	if len(gt.TypeParams) != len(typeArgs) {
-		return nil, fmt.Errorf("expected %d type arguments, got %d", len(gt.TypeParams), len(typeArgs))
+		args := make([]string, len(typeArgs))
+		for i, arg := range typeArgs {
+			args[i] = typeArgString(arg)
+		}
+		return nil, typeArgCountError(gt.Name, gt.Pos, params, args, ctx)
 	}
 
 	resolvedTypeArgs := make([]Type, len(typeArgs))
@@ -942,10 +2053,14 @@ func InstantiateGenericType(gt *GenericType, typeArgs []interface{}, env TypeEnv
 
 		switch a := arg.(type) {
 		case ast.Expr:
-			paramCtx := NewInferenceContext(WithExpectedType(gt.TypeParams[i]))
+			paramCtx := ctx.Child(WithExpectedType(gt.TypeParams[i]))
 			argType, err = InferType(a, env, paramCtx)
 		case Type:
 			argType = a
+			// defaults may refer to the parameters before them, like `V any = K`
+			if i >= explicit {
+				argType = substituteTypeParams(a, gt.TypeParams[:i], resolvedTypeArgs[:i], NewTypeVisitor())
+			}
 		default:
 			return nil, fmt.Errorf("unsupported type argument: %v", arg)
 		}
@@ -954,40 +2069,164 @@ func InstantiateGenericType(gt *GenericType, typeArgs []interface{}, env TypeEnv
 			return nil, err
 		}
 
-		if constraint, ok := gt.Constraints[gt.TypeParams[i].(*TypeVariable).Name]; ok {
-			if !checkConstraint(argType, constraint) {
-				return nil, fmt.Errorf("type argument %v does not satisfy constraint for %s", argType, gt.TypeParams[i].(*TypeVariable).Name)
-			}
+		// parameters of an already instantiated type can only be instantiated again with the same type
+		if !gt.isOpen(i) && !TypesEqual(gt.TypeParams[i], argType) {
+			return nil, fmt.Errorf("type parameter %s of %s is already instantiated with %s, got %s", params.name(i), gt.Name, FormatGo(gt.TypeParams[i]), FormatGo(argType))
 		}
-		// keep going even if there is no constraint
+
 		resolvedTypeArgs[i] = argType
 	}
 
-	instantiated := &GenericType{
-		Name:       gt.Name,
-		TypeParams: resolvedTypeArgs,
-		Fields:     make(map[string]Type),
-		Methods:    make(MethodSet),
+	// a constraint may refer to the other type parameters, like V in `[K comparable, V Set[K]]`,
+	// so the constraints are checked once all the type arguments are known, with theirs substituted
+	vars := params.Vars()
+	for _, i := range params.constraintOrder() {
+		constraint := params[i].Constraint
+		if constraint == nil {
+			continue
+		}
+		if len(params.constraintParams(constraint, -1)) > 0 {
+			substituted := substituteConstraint(*constraint, vars, resolvedTypeArgs, NewTypeVisitor())
+			constraint = &substituted
+		}
+		if !gt.satisfies(i, constraint, resolvedTypeArgs[i]) {
+			return nil, &gerrors.ConstraintError{Param: params.name(i), Arg: FormatGo(resolvedTypeArgs[i]), Constraint: FormatGo(constraint)}
+		}
 	}
 
-	visitor := NewTypeVisitor()
+	arena := ctx.arena()
+	instantiated := arena.generic(GenericType{
+		Name:        gt.Name,
+		TypeParams:  resolvedTypeArgs,
+		Fields:      make(map[string]Type),
+		Methods:     make(MethodSet),
+		Params:      gt.Params,
+		Signature:   substituteSignature(gt.Signature, gt.TypeParams, resolvedTypeArgs, &TypeVisitor{visited: make(map[string]bool), arena: arena}),
+		IsInterface: gt.IsInterface,
+		Pos:         gt.Pos,
+		FieldOrder:  gt.FieldOrder,
+		TypeSet:     substituteTypeSet(gt.TypeSet, vars, resolvedTypeArgs, &TypeVisitor{visited: make(map[string]bool), arena: arena}),
+		origin:      &Instantiation{Decl: gt, Args: append([]Type(nil), resolvedTypeArgs...)},
+
+		UnderlyingType: substituteTypeParams(gt.UnderlyingType, gt.TypeParams, resolvedTypeArgs, &TypeVisitor{visited: make(map[string]bool), arena: arena}),
+	})
+	if gt.origin != nil {
+		instantiated.origin.Decl = gt.origin.Decl
+	}
+
+	visitor := &TypeVisitor{visited: make(map[string]bool), arena: arena}
 	for name, fieldType := range gt.Fields {
 		instantiated.Fields[name] = substituteTypeParams(fieldType, gt.TypeParams, resolvedTypeArgs, visitor)
 	}
 
 	for name, method := range gt.Methods {
-		instantiatedMethod := Method{
-			Name:      method.Name,
-			Params:    substituteTypeParamsInSlice(method.Params, gt.TypeParams, resolvedTypeArgs, visitor),
-			Results:   substituteTypeParamsInSlice(method.Results, gt.TypeParams, resolvedTypeArgs, visitor),
-			IsPointer: method.IsPointer,
+		instantiatedMethod := substituteMethod(method, gt.TypeParams, resolvedTypeArgs, visitor)
+		if instantiatedMethod.Receiver != nil {
+			instantiatedMethod.Receiver = instantiated
 		}
 		instantiated.Methods[name] = instantiatedMethod
 	}
 
+	// recursive types, like `Tree[T]` with a `left Tree[T]` field, refer back to the
+	// instance itself rather than to a fresh copy, so that they are never expanded again.
+	selfVisitor := &TypeVisitor{visited: make(map[string]bool), arena: arena}
+	for name, fieldType := range instantiated.Fields {
+		instantiated.Fields[name] = linkSelfReferences(fieldType, instantiated, selfVisitor)
+	}
+	for name, method := range instantiated.Methods {
+		method.Params = linkSelfReferencesInSlice(method.Params, instantiated, selfVisitor)
+		method.Results = linkSelfReferencesInSlice(method.Results, instantiated, selfVisitor)
+		instantiated.Methods[name] = method
+	}
+
 	return instantiated, nil
 }
 
+// linkSelfReferences replaces references to the instance `self` inside t,
+// i.e. generic types with the same name and type arguments, with `self` itself.
+// The types in t are copied rather than modified, since they may be shared with the declaration.
+func linkSelfReferences(t Type, self *GenericType, visitor *TypeVisitor) Type {
+	if t == Type(self) || visitor.Visit(t) {
+		return t
+	}
+	defer visitor.Leave(t)
+
+	switch t := t.(type) {
+	case *GenericType:
+		if t.Name == self.Name && TypesEqual(t, self) {
+			return self
+		}
+		linked := *t
+		if t.Fields != nil {
+			linked.Fields = make(map[string]Type, len(t.Fields))
+			for name, fieldType := range t.Fields {
+				linked.Fields[name] = linkSelfReferences(fieldType, self, visitor)
+			}
+		}
+		return visitor.arena.generic(linked)
+	case *SliceType:
+		return visitor.arena.slice(SliceType{ElementType: linkSelfReferences(t.ElementType, self, visitor)})
+	case *ArrayType:
+		return visitor.arena.array(ArrayType{ElementType: linkSelfReferences(t.ElementType, self, visitor), Len: t.Len})
+	case *ChanType:
+		return &ChanType{Dir: t.Dir, ElementType: linkSelfReferences(t.ElementType, self, visitor)}
+	case *PointerType:
+		return visitor.arena.pointer(PointerType{Base: linkSelfReferences(t.Base, self, visitor)})
+	case *MapType:
+		return visitor.arena.mapType(MapType{
+			KeyType:   linkSelfReferences(t.KeyType, self, visitor),
+			ValueType: linkSelfReferences(t.ValueType, self, visitor),
+		})
+	case *FunctionType:
+		return visitor.arena.function(FunctionType{
+			ParamTypes: linkSelfReferencesInSlice(t.ParamTypes, self, visitor),
+			ReturnType: linkSelfReferences(t.ReturnType, self, visitor),
+			IsVariadic: t.IsVariadic,
+			Effects:    t.Effects,
+		})
+	case *TupleType:
+		return visitor.arena.tuple(TupleType{Types: linkSelfReferencesInSlice(t.Types, self, visitor)})
+	}
+	return t
+}
+
+func linkSelfReferencesInSlice(types []Type, self *GenericType, visitor *TypeVisitor) []Type {
+	if types == nil {
+		return nil
+	}
+	linked := make([]Type, len(types))
+	for i, t := range types {
+		linked[i] = linkSelfReferences(t, self, visitor)
+	}
+	return linked
+}
+
+// substituteSignature substitutes type parameters in the signature of a generic function.
+func substituteSignature(sig *FunctionType, from, to []Type, visitor *TypeVisitor) *FunctionType {
+	if sig == nil {
+		return nil
+	}
+	return substituteTypeParams(sig, from, to, visitor).(*FunctionType)
+}
+
+// substituteMethod substitutes type parameters in the signature of a method.
+func substituteMethod(method Method, from, to []Type, visitor *TypeVisitor) Method {
+	method.Params = substituteTypeParamsInSlice(method.Params, from, to, visitor)
+	method.Results = substituteTypeParamsInSlice(method.Results, from, to, visitor)
+	return method
+}
+
+func substituteMethodSet(methods MethodSet, from, to []Type, visitor *TypeVisitor) MethodSet {
+	if methods == nil {
+		return nil
+	}
+	substituted := make(MethodSet, len(methods))
+	for name, method := range methods {
+		substituted[name] = substituteMethod(method, from, to, visitor)
+	}
+	return substituted
+}
+
 func substituteTypeParamsInSlice(types []Type, from, to []Type, visitor *TypeVisitor) []Type {
 	result := make([]Type, len(types))
 	for i, t := range types {
@@ -1,12 +1,19 @@
 package generic
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/constant"
+	"go/parser"
 	"go/token"
+	"maps"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	gerrors "github.com/notJoon/generic/errors"
 )
 
 func TestInferType(t *testing.T) {
@@ -67,7 +74,7 @@ func TestInferType(t *testing.T) {
 				"x": &TypeConstant{Name: "string"},
 			},
 			wantType: nil,
-			wantErr:  fmt.Errorf("argument type mismatch for arg 0: type mismatch"),
+			wantErr:  fmt.Errorf("argument type mismatch for arg 1: type mismatch"),
 		},
 		{
 			name: "Infer type of non-function call",
@@ -167,7 +174,7 @@ func TestInferType(t *testing.T) {
 				"bool":   &TypeConstant{Name: "bool"},
 			},
 			wantType: nil,
-			wantErr:  fmt.Errorf("no constraint for type parameter T1"),
+			wantErr:  fmt.Errorf("too many type arguments for Pair[T1, T2]: expected 2, got 3 (extra argument #3 bool)"),
 		},
 		{
 			name: "Infer type of nested generic type",
@@ -296,7 +303,7 @@ func TestInferType(t *testing.T) {
 			name:     "Infer type of rune literal",
 			expr:     &ast.BasicLit{Kind: token.CHAR, Value: "'A'"},
 			env:      TypeEnv{},
-			wantType: &TypeConstant{Name: "rune"},
+			wantType: Rune,
 			wantErr:  nil,
 		},
 		{
@@ -713,7 +720,7 @@ func TestInferType(t *testing.T) {
 				"string": &TypeConstant{Name: "string"},
 			},
 			wantType: nil,
-			wantErr:  fmt.Errorf("type argument TypeConst(string) does not satisfy constraint for T"),
+			wantErr:  fmt.Errorf("type argument string does not satisfy constraint int | float32 | float64 for T"),
 		},
 		{
 			name: "Infer type of non-generic type as generic",
@@ -1101,6 +1108,32 @@ func TestSubstituteTypeParams(t *testing.T) {
 	}
 }
 
+// TestSubstituteSharedDeclarations substitutes a chain of structs each referring twice to
+// the next, which takes exponential time unless each struct is substituted once.
+func TestSubstituteSharedDeclarations(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	next := Type(&StructType{Name: "S40", Fields: map[string]Type{"v": tv}})
+	for i := 39; i >= 0; i-- {
+		next = &StructType{Name: fmt.Sprintf("S%d", i), Fields: map[string]Type{"a": next, "b": &PointerType{Base: next}}}
+	}
+
+	done := make(chan Type)
+	go func() {
+		done <- substituteTypeParams(next, []Type{tv}, []Type{&TypeConstant{Name: "int"}}, NewTypeVisitor())
+	}()
+	select {
+	case got := <-done:
+		for i := 0; i < 40; i++ {
+			got = got.(*StructType).Fields["b"].(*PointerType).Base
+		}
+		if v := got.(*StructType).Fields["v"]; FormatGo(v) != "int" {
+			t.Errorf("substituteTypeParams() field v of S40 = %v, want int", v)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("substituteTypeParams() did not return")
+	}
+}
+
 func TestInferTypeWithImprovedConstraints(t *testing.T) {
 	env := TypeEnv{
 		// Numeric<T> where T: int | float64
@@ -1558,6 +1591,10 @@ func TestInstantiateGenericType(t *testing.T) {
 				t.Errorf("InstantiateGenericType() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			// provenance is covered by TestGenericTypeOrigin
+			if instance, ok := got.(*GenericType); ok {
+				instance.origin = nil
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("InstantiateGenericType() = %v, want %v", got, tt.want)
 			}
@@ -1672,6 +1709,66 @@ func TestCalculateStructMethodSet(t *testing.T) {
 				"Method": Method{Name: "Method", IsPointer: false},
 			},
 		},
+		{
+			name: "Struct with embedded interface",
+			s: &StructType{
+				Name: "reverse",
+				Fields: map[string]Type{
+					"Interface": &NamedType{Name: "Interface", Underlying: &InterfaceType{
+						Name:    "Interface",
+						Methods: MethodSet{"Len": Method{Name: "Len", Results: []Type{Int}}},
+					}},
+				},
+			},
+			isPtr: true,
+			expected: MethodSet{
+				"Len": Method{Name: "Len", Results: []Type{Int}},
+			},
+		},
+		{
+			name: "Struct with embedded defined type",
+			s: &StructType{
+				Name: "Tally",
+				Fields: map[string]Type{
+					"Counter": &NamedType{Name: "Counter", Underlying: Int, Methods: MethodSet{
+						"Get": Method{Name: "Get", IsPointer: false},
+						"Inc": Method{Name: "Inc", IsPointer: true},
+					}},
+				},
+			},
+			isPtr: false,
+			expected: MethodSet{
+				"Get": Method{Name: "Get", IsPointer: false},
+			},
+		},
+		{
+			name: "Struct with embedded defined type, accessed as pointer",
+			s: &StructType{
+				Name: "Tally",
+				Fields: map[string]Type{
+					"Counter": &NamedType{Name: "Counter", Underlying: Int, Methods: MethodSet{
+						"Get": Method{Name: "Get", IsPointer: false},
+						"Inc": Method{Name: "Inc", IsPointer: true},
+					}},
+				},
+			},
+			isPtr: true,
+			expected: MethodSet{
+				"Get": Method{Name: "Get", IsPointer: false},
+				"Inc": Method{Name: "Inc", IsPointer: true},
+			},
+		},
+		{
+			name: "Struct with embedded error",
+			s: &StructType{
+				Name:   "wrapped",
+				Fields: map[string]Type{"error": Error},
+			},
+			isPtr: false,
+			expected: MethodSet{
+				"Error": Method{Name: "Error", Results: []Type{String}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1684,7 +1781,7 @@ func TestCalculateStructMethodSet(t *testing.T) {
 	}
 }
 
-func TestSubstituteTypeVar(t *testing.T) {
+func TestSubstituteTypeVariable(t *testing.T) {
 	tests := []struct {
 		name        string
 		t           Type
@@ -1749,13 +1846,61 @@ func TestSubstituteTypeVar(t *testing.T) {
 			replacement: &TypeConstant{Name: "int"},
 			expected:    &TypeConstant{Name: "string"},
 		},
+		{
+			name:        "replace in slice",
+			t:           &SliceType{ElementType: &TypeVariable{Name: "T"}},
+			tv:          &TypeVariable{Name: "T"},
+			replacement: &TypeConstant{Name: "int"},
+			expected:    &SliceType{ElementType: &TypeConstant{Name: "int"}},
+		},
+		{
+			name:        "replace in array",
+			t:           &ArrayType{ElementType: &TypeVariable{Name: "T"}, Len: 4},
+			tv:          &TypeVariable{Name: "T"},
+			replacement: &TypeConstant{Name: "int"},
+			expected:    &ArrayType{ElementType: &TypeConstant{Name: "int"}, Len: 4},
+		},
+		{
+			name:        "replace in map",
+			t:           &MapType{KeyType: &TypeVariable{Name: "T"}, ValueType: &SliceType{ElementType: &TypeVariable{Name: "T"}}},
+			tv:          &TypeVariable{Name: "T"},
+			replacement: &TypeConstant{Name: "string"},
+			expected:    &MapType{KeyType: &TypeConstant{Name: "string"}, ValueType: &SliceType{ElementType: &TypeConstant{Name: "string"}}},
+		},
+		{
+			name:        "replace in pointer",
+			t:           &PointerType{Base: &TypeVariable{Name: "T"}},
+			tv:          &TypeVariable{Name: "T"},
+			replacement: &TypeConstant{Name: "int"},
+			expected:    &PointerType{Base: &TypeConstant{Name: "int"}},
+		},
+		{
+			name: "replace in function",
+			t: &FunctionType{
+				ParamTypes: []Type{&TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}},
+				ReturnType: &PointerType{Base: &TypeVariable{Name: "T"}},
+			},
+			tv:          &TypeVariable{Name: "T"},
+			replacement: &TypeConstant{Name: "int"},
+			expected: &FunctionType{
+				ParamTypes: []Type{&TypeConstant{Name: "int"}, &TypeVariable{Name: "U"}},
+				ReturnType: &PointerType{Base: &TypeConstant{Name: "int"}},
+			},
+		},
+		{
+			name:        "replace in tuple",
+			t:           &TupleType{Types: []Type{&TypeVariable{Name: "T"}, &TypeConstant{Name: "error"}}},
+			tv:          &TypeVariable{Name: "T"},
+			replacement: &TypeConstant{Name: "int"},
+			expected:    &TupleType{Types: []Type{&TypeConstant{Name: "int"}, &TypeConstant{Name: "error"}}},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := substituteTypeVar(tt.t, tt.tv, tt.replacement)
+			result := substituteTypeParams(tt.t, []Type{tt.tv}, []Type{tt.replacement}, NewTypeVisitor())
 			if !reflect.DeepEqual(result, tt.expected) {
-				t.Errorf("substituteTypeVar() = %v, want %v", result, tt.expected)
+				t.Errorf("substituteTypeParams() = %v, want %v", result, tt.expected)
 			}
 		})
 	}
@@ -1847,123 +1992,6 @@ func diffStrings(a, b string) string {
 	return diff.String()
 }
 
-func TestInferResult(t *testing.T) {
-	tests := []struct {
-		name     string
-		results  *ast.FieldList
-		env      TypeEnv
-		wantType Type
-		wantErr  error
-	}{
-		{
-			name:     "No return value",
-			results:  &ast.FieldList{},
-			env:      TypeEnv{},
-			wantType: nil,
-			wantErr:  nil,
-		},
-		{
-			name: "Single return value",
-			results: &ast.FieldList{
-				List: []*ast.Field{
-					{Type: &ast.Ident{Name: "int"}},
-				},
-			},
-			env:      TypeEnv{"int": &TypeConstant{Name: "int"}},
-			wantType: &TypeConstant{Name: "int"},
-			wantErr:  nil,
-		},
-		{
-			name: "Multiple return values",
-			results: &ast.FieldList{
-				List: []*ast.Field{
-					{Type: &ast.Ident{Name: "int"}},
-					{Type: &ast.Ident{Name: "string"}},
-				},
-			},
-			env: TypeEnv{
-				"int":    &TypeConstant{Name: "int"},
-				"string": &TypeConstant{Name: "string"},
-			},
-			wantType: &TupleType{
-				Types: []Type{
-					&TypeConstant{Name: "int"},
-					&TypeConstant{Name: "string"},
-				},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "Named return values",
-			results: &ast.FieldList{
-				List: []*ast.Field{
-					{Names: []*ast.Ident{{Name: "x"}}, Type: &ast.Ident{Name: "int"}},
-					{Names: []*ast.Ident{{Name: "y"}}, Type: &ast.Ident{Name: "string"}},
-				},
-			},
-			env: TypeEnv{
-				"int":    &TypeConstant{Name: "int"},
-				"string": &TypeConstant{Name: "string"},
-			},
-			wantType: &TupleType{
-				Types: []Type{
-					&TypeConstant{Name: "int"},
-					&TypeConstant{Name: "string"},
-				},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "Mixed named and unnamed return values",
-			results: &ast.FieldList{
-				List: []*ast.Field{
-					{Names: []*ast.Ident{{Name: "x"}}, Type: &ast.Ident{Name: "int"}},
-					{Type: &ast.Ident{Name: "string"}},
-				},
-			},
-			env: TypeEnv{
-				"int":    &TypeConstant{Name: "int"},
-				"string": &TypeConstant{Name: "string"},
-			},
-			wantType: &TupleType{
-				Types: []Type{
-					&TypeConstant{Name: "int"},
-					&TypeConstant{Name: "string"},
-				},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "Unknown return type",
-			results: &ast.FieldList{
-				List: []*ast.Field{
-					{Type: &ast.Ident{Name: "UnknownType"}},
-				},
-			},
-			env:      TypeEnv{},
-			wantType: nil,
-			wantErr:  fmt.Errorf("unknown identifier: UnknownType"),
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := inferResult(tt.results, tt.env, nil)
-			if (err != nil) != (tt.wantErr != nil) {
-				t.Errorf("inferResult() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if err != nil && tt.wantErr != nil && err.Error() != tt.wantErr.Error() {
-				t.Errorf("inferResult() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !TypesEqual(got, tt.wantType) {
-				t.Errorf("inferResult() = %v, want %v", got, tt.wantType)
-			}
-		})
-	}
-}
-
 func TestInferVariadicFunction(t *testing.T) {
 	expr := &ast.FuncType{
 		Params: &ast.FieldList{
@@ -2114,7 +2142,7 @@ func TestInferMethodCall(t *testing.T) {
 			ctx:        NewInferenceContext(),
 			wantType:   nil,
 			wantErr:    true,
-			errMessage: "argument type mismatch for arg 0: type mismatch",
+			errMessage: "argument type mismatch for arg 1: type mismatch",
 		},
 		{
 			name: "Method call with expected return type",
@@ -2183,7 +2211,7 @@ func TestInferFunctionCall(t *testing.T) {
 			ctx:        NewInferenceContext(),
 			wantType:   nil,
 			wantErr:    true,
-			errMessage: "argument type mismatch for arg 0: type mismatch",
+			errMessage: "argument type mismatch for arg 1: type mismatch",
 		},
 		{
 			name: "Function call with expected return type",
@@ -2447,3 +2475,1699 @@ func TestInferTypeGenericCompositeLit(t *testing.T) {
 		})
 	}
 }
+
+func TestInferTypeDuplicateKeys(t *testing.T) {
+	env := TypeEnv{
+		"int":    &TypeConstant{Name: "int"},
+		"string": &TypeConstant{Name: "string"},
+		"Person": &StructType{
+			Name: "Person",
+			Fields: map[string]Type{
+				"Name": &TypeConstant{Name: "string"},
+				"Age":  &TypeConstant{Name: "int"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{
+			name: "Distinct map keys",
+			src:  `map[string]int{"a": 1, "b": 2}`,
+		},
+		{
+			name:    "Duplicate string map keys",
+			src:     `map[string]int{"a": 1, "a": 2}`,
+			wantErr: `duplicate key "a" in map literal`,
+		},
+		{
+			name:    "Duplicate int map keys written differently",
+			src:     `map[int]string{16: "a", 0x10: "b"}`,
+			wantErr: "duplicate key 16 in map literal",
+		},
+		{
+			name:    "Duplicate struct field",
+			src:     `Person{Name: "a", Name: "b"}`,
+			wantErr: "duplicate field name Name in struct literal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			_, err = InferType(expr, env, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("InferType() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInferMapKeyType(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	env := TypeEnv{
+		"int":    intType,
+		"string": &TypeConstant{Name: "string"},
+		"K":      &TypeVariable{Name: "K"},
+		"Point":  &StructType{Name: "Point", Fields: map[string]Type{"X": intType}},
+		"Bag":    &StructType{Name: "Bag", Fields: map[string]Type{"Items": &SliceType{ElementType: intType}}},
+		"ID":     &TypeAlias{Name: "ID", AliasedTo: intType},
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    Type
+		wantErr string
+	}{
+		{name: "basic key", src: `map[string]int{"a": 1}`, want: &MapType{KeyType: env["string"], ValueType: intType}},
+		{name: "struct key", src: `map[Point]int{}`, want: &MapType{KeyType: env["Point"], ValueType: intType}},
+		{name: "array key", src: `map[[2]int]int{}`, want: &MapType{KeyType: &ArrayType{ElementType: intType, Len: 2}, ValueType: intType}},
+		{name: "alias key", src: `map[ID]int{}`, want: &MapType{KeyType: intType, ValueType: intType}},
+		{name: "type parameter key", src: `map[K]int{}`, want: &MapType{KeyType: env["K"], ValueType: intType}},
+		{name: "make", src: `make(map[string][]int, 10)`, want: &MapType{KeyType: env["string"], ValueType: &SliceType{ElementType: intType}}},
+		{name: "slice key", src: `map[[]int]int{}`, wantErr: "invalid map key type []int"},
+		{name: "map key", src: `map[map[int]int]int{}`, wantErr: "invalid map key type map[int]int"},
+		{name: "function key", src: `map[func()]int{}`, wantErr: "invalid map key type func()"},
+		{name: "non-comparable struct key", src: `map[Bag]int{}`, wantErr: "invalid map key type Bag"},
+		{name: "make with slice key", src: `make(map[[]int]string)`, wantErr: "invalid map key type []int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if !TypesEqual(got, tt.want) {
+				t.Errorf("InferType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferTypeArrayIndexKeys(t *testing.T) {
+	env := TypeEnv{
+		"int":    &TypeConstant{Name: "int"},
+		"string": &TypeConstant{Name: "string"},
+		"N":      &ConstObj{Name: "N", Type: Int, Val: constant.MakeInt64(3), Untyped: true},
+		"M":      &ConstObj{Name: "M", Type: Int, Val: constant.MakeInt64(2)},
+	}
+
+	tests := []struct {
+		name     string
+		src      string
+		wantType Type
+		wantErr  string
+	}{
+		{
+			name:     "Sparse array literal",
+			src:      `[5]int{0: 1, 4: 9}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "int"}, Len: 5},
+		},
+		{
+			name:     "Mixed keyed and positional elements",
+			src:      `[4]int{1, 2: 3, 4}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "int"}, Len: 4},
+		},
+		{
+			name:     "Length from maximum index",
+			src:      `[...]string{2: "c", 0: "a"}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "string"}, Len: 3},
+		},
+		{
+			name:     "Length from element count",
+			src:      `[...]int{1, 2, 3, 4}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "int"}, Len: 4},
+		},
+		{
+			name:     "Length from a named constant",
+			src:      `[N]int{1, 2, 3}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "int"}, Len: 3},
+		},
+		{
+			name:     "Length from a typed constant",
+			src:      `[M]string{"a", "b"}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "string"}, Len: 2},
+		},
+		{
+			name:     "Length from a constant expression",
+			src:      `[N + 1]int{N: 1}`,
+			wantType: &ArrayType{ElementType: &TypeConstant{Name: "int"}, Len: 4},
+		},
+		{
+			name:    "Index out of bounds of a named constant length",
+			src:     `[N]int{N: 1}`,
+			wantErr: "index 3 out of bounds [0:3]",
+		},
+		{
+			name:    "Index out of bounds",
+			src:     `[5]int{5: 1}`,
+			wantErr: "index 5 out of bounds [0:5]",
+		},
+		{
+			name:    "Positional element past the last index",
+			src:     `[2]int{1: 1, 2}`,
+			wantErr: "index 2 out of bounds [0:2]",
+		},
+		{
+			name:    "Duplicate index",
+			src:     `[5]int{1: 1, 0x1: 2}`,
+			wantErr: "duplicate index 1 in array literal",
+		},
+		{
+			name:    "Duplicate with positional element",
+			src:     `[5]int{1: 1, 0: 2, 3}`,
+			wantErr: "duplicate index 1 in array literal",
+		},
+		{
+			name:    "Negative index",
+			src:     `[5]int{-1: 1}`,
+			wantErr: "index -1 must be non-negative integer constant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() unexpected error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestInferTypeAddressOfCompositeLit(t *testing.T) {
+	env := TypeEnv{
+		"int":    &TypeConstant{Name: "int"},
+		"string": &TypeConstant{Name: "string"},
+		"Person": &StructType{
+			Name: "Person",
+			Fields: map[string]Type{
+				"Name": &TypeConstant{Name: "string"},
+				"Age":  &TypeConstant{Name: "int"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		src      string
+		wantType Type
+		wantErr  bool
+	}{
+		{
+			name: "Pointer to struct literal",
+			src:  `&Person{Name: "x"}`,
+			wantType: &PointerType{Base: &StructType{
+				Name:   "Person",
+				Fields: map[string]Type{"Name": &TypeConstant{Name: "string"}},
+			}},
+		},
+		{
+			name:     "Pointer to empty struct literal",
+			src:      `&Person{}`,
+			wantType: &PointerType{Base: &StructType{Name: "Person", Fields: map[string]Type{}}},
+		},
+		{
+			name:    "Field type mismatch",
+			src:     `&Person{Name: 1}`,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown field",
+			src:     `&Person{Email: "x"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Address of non-literal",
+			src:     `&x`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InferType() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestInferNoValueCalls(t *testing.T) {
+	env := TypeEnv{
+		"int":  &TypeConstant{Name: "int"},
+		"void": &TypeConstant{Name: "void"},
+		"f": &FunctionType{
+			ParamTypes: []Type{&TypeConstant{Name: "int"}},
+		},
+		"g": &FunctionType{
+			ParamTypes: []Type{&TypeConstant{Name: "int"}},
+			ReturnType: &TypeConstant{Name: "int"},
+		},
+		"s": &StructType{
+			Name: "S",
+			Methods: MethodSet{
+				"Close": {Name: "Close"},
+			},
+		},
+		"v": &TypeConstant{Name: "void"},
+		"x": &TypeConstant{Name: "int"},
+	}
+
+	tests := []struct {
+		name     string
+		node     interface{}
+		ctx      *InferenceContext
+		wantType Type
+		wantErr  error
+	}{
+		{
+			name:     "Function call without results as statement",
+			node:     &ast.CallExpr{Fun: &ast.Ident{Name: "f"}, Args: []ast.Expr{&ast.Ident{Name: "x"}}},
+			wantType: &NoValueType{},
+		},
+		{
+			name:     "Method call without results as statement",
+			node:     &ast.CallExpr{Fun: &ast.SelectorExpr{X: &ast.Ident{Name: "s"}, Sel: &ast.Ident{Name: "Close"}}},
+			wantType: &NoValueType{},
+		},
+		{
+			name:    "Method call without results used as value of user type void",
+			node:    &ast.CallExpr{Fun: &ast.SelectorExpr{X: &ast.Ident{Name: "s"}, Sel: &ast.Ident{Name: "Close"}}},
+			ctx:     NewInferenceContext(WithExpectedType(&TypeConstant{Name: "void"})),
+			wantErr: ErrNoValueUsed,
+		},
+		{
+			name: "Assignment of call without results",
+			node: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: "v"}},
+				Rhs: []ast.Expr{&ast.CallExpr{Fun: &ast.SelectorExpr{X: &ast.Ident{Name: "s"}, Sel: &ast.Ident{Name: "Close"}}}},
+			},
+			wantErr: ErrNoValueUsed,
+		},
+		{
+			name: "Call without results as argument",
+			node: &ast.CallExpr{
+				Fun:  &ast.Ident{Name: "g"},
+				Args: []ast.Expr{&ast.CallExpr{Fun: &ast.Ident{Name: "f"}, Args: []ast.Expr{&ast.Ident{Name: "x"}}}},
+			},
+			wantErr: ErrNoValueUsed,
+		},
+		{
+			name: "Return of call without results",
+			node: &ast.ReturnStmt{
+				Results: []ast.Expr{&ast.CallExpr{Fun: &ast.Ident{Name: "f"}, Args: []ast.Expr{&ast.Ident{Name: "x"}}}},
+			},
+			ctx:     NewInferenceContext(WithExpectedType(&FunctionType{ReturnType: &TypeConstant{Name: "int"}})),
+			wantErr: ErrNoValueUsed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InferType(tt.node, env, tt.ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() unexpected error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestInferMultiResultCalls(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	errType := &TypeConstant{Name: "error"}
+	env := TypeEnv{
+		"n":   intType,
+		"err": errType,
+		"s":   &TypeConstant{Name: "string"},
+		"r": &StructType{
+			Name: "Reader",
+			Methods: MethodSet{
+				"Read": {Name: "Read", Results: []Type{intType, errType}},
+			},
+			GenericMethods: map[string]GenericMethod{
+				"Pair": {
+					Name:       "Pair",
+					TypeParams: []Type{&TypeVariable{Name: "T"}},
+					Method: Method{
+						Name:    "Pair",
+						Results: []Type{&TypeVariable{Name: "T"}, errType},
+					},
+				},
+			},
+		},
+		"check": &FunctionType{
+			ParamTypes: []Type{intType, errType},
+			ReturnType: &TypeConstant{Name: "bool"},
+		},
+	}
+	readCall := &ast.CallExpr{Fun: &ast.SelectorExpr{X: &ast.Ident{Name: "r"}, Sel: &ast.Ident{Name: "Read"}}}
+
+	tests := []struct {
+		name     string
+		node     interface{}
+		wantType Type
+		wantErr  string
+	}{
+		{
+			name:     "Method call with multiple results",
+			node:     readCall,
+			wantType: &TupleType{Types: []Type{intType, errType}},
+		},
+		{
+			name: "Generic method call with multiple results",
+			node: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: &ast.Ident{Name: "r"}, Sel: &ast.Ident{Name: "Pair"}},
+				Args: []ast.Expr{&ast.CompositeLit{Elts: []ast.Expr{&ast.Ident{Name: "s"}}}},
+			},
+			wantType: &TupleType{Types: []Type{&TypeConstant{Name: "string"}, errType}},
+		},
+		{
+			name: "Multi-value assignment",
+			node: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: "n"}, &ast.Ident{Name: "err"}},
+				Rhs: []ast.Expr{readCall},
+			},
+		},
+		{
+			name: "Multi-value assignment type mismatch",
+			node: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: "s"}, &ast.Ident{Name: "err"}},
+				Rhs: []ast.Expr{readCall},
+			},
+			wantErr: "assignment type mismatch for s",
+		},
+		{
+			name: "Multi-value assignment count mismatch",
+			node: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: "n"}, &ast.Ident{Name: "err"}, &ast.Ident{Name: "s"}},
+				Rhs: []ast.Expr{readCall},
+			},
+			wantErr: "assignment mismatch: 3 variables but 2 values",
+		},
+		{
+			name:     "Multiple results passed as arguments",
+			node:     &ast.CallExpr{Fun: &ast.Ident{Name: "check"}, Args: []ast.Expr{readCall}},
+			wantType: &TypeConstant{Name: "bool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InferType(tt.node, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() unexpected error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestBuildSignature(t *testing.T) {
+	env := TypeEnv{
+		"int":    &TypeConstant{Name: "int"},
+		"string": &TypeConstant{Name: "string"},
+		"error":  &TypeConstant{Name: "error"},
+	}
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+
+	tests := []struct {
+		name     string
+		src      string
+		wantType Type
+		wantErr  string
+	}{
+		{
+			name:     "Non-variadic function type",
+			src:      `func(a, b int) string`,
+			wantType: &FunctionType{ParamTypes: []Type{intType, intType}, ReturnType: strType},
+		},
+		{
+			name:     "Function type without parameters and results",
+			src:      `func()`,
+			wantType: &FunctionType{},
+		},
+		{
+			name: "Variadic function literal",
+			src:  `func(format string, args ...int) (n int, err error) { return }`,
+			wantType: &FunctionType{
+				ParamTypes: []Type{strType, &SliceType{ElementType: intType}},
+				ReturnType: &TupleType{Types: []Type{intType, &TypeConstant{Name: "error"}}},
+				IsVariadic: true,
+			},
+		},
+		{
+			name:    "Unknown parameter type",
+			src:     `func(x Unknown)`,
+			wantErr: "error inferring parameter type: unknown identifier: Unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() unexpected error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestBuildSignatureMisplacedEllipsis(t *testing.T) {
+	// go/parser rejects this form, but hand-built ASTs may still contain it
+	ft := &ast.FuncType{
+		Params: &ast.FieldList{
+			List: []*ast.Field{
+				{Names: []*ast.Ident{{Name: "args"}}, Type: &ast.Ellipsis{Elt: &ast.Ident{Name: "int"}}},
+				{Names: []*ast.Ident{{Name: "s"}}, Type: &ast.Ident{Name: "string"}},
+			},
+		},
+	}
+	env := TypeEnv{
+		"int":    &TypeConstant{Name: "int"},
+		"string": &TypeConstant{Name: "string"},
+	}
+
+	_, err := buildSignature(ft, env, nil)
+	if err == nil || err.Error() != "can only use ... with final parameter in list" {
+		t.Errorf("buildSignature() error = %v", err)
+	}
+}
+
+func TestInferTypeFuncDecl(t *testing.T) {
+	file, err := Parser(`package main
+
+func Index(s string, x string) (int, bool) { return 0, false }
+`)
+	if err != nil {
+		t.Fatalf("Parser() error = %v", err)
+	}
+	env := TypeEnv{
+		"string": &TypeConstant{Name: "string"},
+		"int":    &TypeConstant{Name: "int"},
+		"bool":   &TypeConstant{Name: "bool"},
+	}
+
+	got, err := InferType(file.Decls[0], env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	want := &FunctionType{
+		ParamTypes: []Type{&TypeConstant{Name: "string"}, &TypeConstant{Name: "string"}},
+		ReturnType: &TupleType{Types: []Type{&TypeConstant{Name: "int"}, &TypeConstant{Name: "bool"}}},
+	}
+	if !TypesEqual(got, want) {
+		t.Errorf("InferType() = %v, want %v", got, want)
+	}
+}
+
+func TestInferInterfaceMethodSignatures(t *testing.T) {
+	env := TypeEnv{
+		"int":    &TypeConstant{Name: "int"},
+		"byte":   &TypeConstant{Name: "byte"},
+		"string": &TypeConstant{Name: "string"},
+		"error":  &TypeConstant{Name: "error"},
+	}
+	intType := &TypeConstant{Name: "int"}
+	errType := &TypeConstant{Name: "error"}
+
+	expr, err := parser.ParseExpr(`interface {
+		Write(p ...byte) (n int, err error)
+		Pair() (a, b string)
+		Len() int
+	}`)
+	if err != nil {
+		t.Fatalf("ParseExpr() error = %v", err)
+	}
+	got, err := InferType(expr, env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	iface, ok := got.(*InterfaceType)
+	if !ok {
+		t.Fatalf("InferType() = %T, want *InterfaceType", got)
+	}
+
+	want := MethodSet{
+		"Write": {
+			Name:       "Write",
+			Params:     []Type{&SliceType{ElementType: &TypeConstant{Name: "byte"}}},
+			Results:    []Type{intType, errType},
+			IsVariadic: true,
+		},
+		"Pair": {
+			Name:    "Pair",
+			Results: []Type{&TypeConstant{Name: "string"}, &TypeConstant{Name: "string"}},
+		},
+		"Len": {
+			Name:    "Len",
+			Results: []Type{intType},
+		},
+	}
+	for name, wantMethod := range want {
+		gotMethod, ok := iface.Methods[name]
+		if !ok {
+			t.Errorf("method %s not found", name)
+			continue
+		}
+		if !MethodsEqual(gotMethod, wantMethod) {
+			t.Errorf("method %s = %v, want %v", name, gotMethod, wantMethod)
+		}
+	}
+
+	write := &ast.CallExpr{
+		Fun:      &ast.SelectorExpr{X: &ast.Ident{Name: "w"}, Sel: &ast.Ident{Name: "Write"}},
+		Args:     []ast.Expr{&ast.Ident{Name: "p"}},
+		Ellipsis: 1, // w.Write(p...)
+	}
+	callEnv := TypeEnv{"w": iface, "p": &SliceType{ElementType: &TypeConstant{Name: "byte"}}}
+	result, err := InferType(write, callEnv, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	if !TypesEqual(result, &TupleType{Types: []Type{intType, errType}}) {
+		t.Errorf("InferType() = %v, want (int, error)", result)
+	}
+}
+
+func TestInferSelector(t *testing.T) {
+	strType := &TypeConstant{Name: "string"}
+	person := &StructType{
+		Name:   "Person",
+		Fields: map[string]Type{"Name": strType},
+	}
+	person.Methods = MethodSet{
+		"Greet":   {Name: "Greet", Receiver: person, Params: []Type{strType}, Results: []Type{strType}},
+		"SetName": {Name: "SetName", Receiver: person, Params: []Type{strType}, IsPointer: true},
+	}
+	env := TypeEnv{
+		"Person": person,
+		"p":      person,
+		"pp":     &PointerType{Base: person},
+	}
+
+	tests := []struct {
+		name     string
+		src      string
+		wantType Type
+		wantErr  string
+	}{
+		{
+			name:     "Method expression with value receiver",
+			src:      `Person.Greet`,
+			wantType: &FunctionType{ParamTypes: []Type{person, strType}, ReturnType: strType},
+		},
+		{
+			name:     "Method expression with pointer receiver",
+			src:      `(*Person).SetName`,
+			wantType: &FunctionType{ParamTypes: []Type{&PointerType{Base: person}, strType}},
+		},
+		{
+			name:     "Method expression of value method through pointer",
+			src:      `(*Person).Greet`,
+			wantType: &FunctionType{ParamTypes: []Type{person, strType}, ReturnType: strType},
+		},
+		{
+			name:    "Method expression needs pointer receiver",
+			src:     `Person.SetName`,
+			wantErr: "invalid method expression Person.SetName (needs pointer receiver (*Person).SetName)",
+		},
+		{
+			name:     "Method value",
+			src:      `p.Greet`,
+			wantType: &FunctionType{ParamTypes: []Type{strType}, ReturnType: strType},
+		},
+		{
+			name:     "Field selection",
+			src:      `p.Name`,
+			wantType: strType,
+		},
+		{
+			name:     "Field selection through pointer",
+			src:      `pp.Name`,
+			wantType: strType,
+		},
+		{
+			name:    "Unknown selector",
+			src:     `p.Age`,
+			wantErr: "method Age not found in type Person",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() unexpected error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestInstantiateAlreadyInstantiatedGeneric(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	numeric := &TypeConstraint{Types: []Type{intType, &TypeConstant{Name: "float64"}}, Union: true}
+	pair := NewGenericType("Pair", TypeParamList{
+		{Name: "K", Constraint: numeric},
+		{Name: "V"},
+	}, map[string]Type{
+		"key":   &TypeVariable{Name: "K"},
+		"value": &TypeVariable{Name: "V"},
+	}, nil)
+	env := TypeEnv{"int": intType, "string": strType, "Pair": pair}
+
+	full, err := InstantiateGenericType(pair, []interface{}{intType, strType}, env, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+	instance := full.(*GenericType)
+
+	// instantiating again with the same arguments is a no-op
+	again, err := InstantiateGenericType(instance, []interface{}{intType, strType}, env, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() on instance error = %v", err)
+	}
+	if !TypesEqual(again, instance) {
+		t.Errorf("InstantiateGenericType() on instance = %v, want %v", again, instance)
+	}
+
+	// but it can't change the bound arguments
+	_, err = InstantiateGenericType(instance, []interface{}{intType, intType}, env, nil)
+	if err == nil || err.Error() != "type parameter V of Pair is already instantiated with string, got int" {
+		t.Errorf("InstantiateGenericType() error = %v", err)
+	}
+
+	// a partially instantiated type keeps checking the constraints of the remaining parameters
+	partial := &GenericType{
+		Name:       "Pair",
+		TypeParams: []Type{&TypeVariable{Name: "K"}, strType},
+		Fields:     map[string]Type{"key": &TypeVariable{Name: "K"}, "value": strType},
+		Params:     pair.Params,
+	}
+	if _, err := InstantiateGenericType(partial, []interface{}{strType, strType}, env, nil); err == nil {
+		t.Errorf("InstantiateGenericType() expected constraint error for K")
+	}
+	got, err := InstantiateGenericType(partial, []interface{}{intType, strType}, env, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() on partial instance error = %v", err)
+	}
+	if key := got.(*GenericType).Fields["key"]; !TypesEqual(key, intType) {
+		t.Errorf("field key = %v, want %v", key, intType)
+	}
+
+	// instances without a declared parameter list don't panic on constraint lookups
+	legacy := &GenericType{Name: "Box", TypeParams: []Type{intType}}
+	if _, err := InstantiateGenericType(legacy, []interface{}{strType}, env, nil); err == nil ||
+		err.Error() != "type parameter #1 of Box is already instantiated with int, got string" {
+		t.Errorf("InstantiateGenericType() error = %v", err)
+	}
+	if _, err := inferPartialTypeParams(legacy, []ast.Expr{&ast.Ident{Name: "int"}}, env, nil); err != nil {
+		t.Errorf("inferPartialTypeParams() error = %v", err)
+	}
+
+	litEnv := TypeEnv{"int": intType, "Box": legacy}
+	lit := &ast.CompositeLit{Type: &ast.IndexExpr{X: &ast.Ident{Name: "Box"}, Index: &ast.Ident{Name: "int"}}}
+	if _, err := InferType(lit, litEnv, nil); err != nil {
+		t.Errorf("InferType() on instance literal error = %v", err)
+	}
+}
+
+func TestTypeArgCountError(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	pair := NewGenericType("Pair", TypeParamList{{Name: "T1"}, {Name: "T2"}}, map[string]Type{
+		"first":  &TypeVariable{Name: "T1"},
+		"second": &TypeVariable{Name: "T2"},
+	}, nil)
+	pair.Pos = 42
+	triple := NewGenericType("Triple", TypeParamList{{Name: "A"}, {Name: "B"}, {Name: "C"}}, nil, nil)
+	env := TypeEnv{"int": intType, "string": strType, "Pair": pair}
+
+	tests := []struct {
+		name    string
+		gt      *GenericType
+		args    []interface{}
+		wantErr string
+	}{
+		{
+			name:    "extra type argument",
+			gt:      pair,
+			args:    []interface{}{intType, strType, &ast.Ident{Name: "bool"}},
+			wantErr: "too many type arguments for Pair[T1, T2] declared at pair.go:3:6: expected 2, got 3 (extra argument #3 bool)",
+		},
+		{
+			name:    "missing type argument",
+			gt:      pair,
+			args:    []interface{}{intType},
+			wantErr: "not enough type arguments for Pair[T1, T2] declared at pair.go:3:6: expected 2, got 1 (missing T2)",
+		},
+		{
+			name:    "several missing type arguments without position",
+			gt:      triple,
+			args:    []interface{}{&ast.Ident{Name: "int"}},
+			wantErr: "not enough type arguments for Triple[A, B, C]: expected 3, got 1 (missing B, C)",
+		},
+	}
+
+	// the declaration is resolved in the file set of the context
+	fset := token.NewFileSet()
+	file := fset.AddFile("pair.go", -1, 100)
+	file.SetLinesForContent([]byte("package p\n\ntype Pair[T1, T2 any] struct{}\n"))
+	pair.Pos = file.Pos(16)
+	ctx := NewInferenceContext(WithFileSet(fset))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := InstantiateGenericType(tt.gt, tt.args, env, ctx)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("InstantiateGenericType() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	// without one, the raw offset is left out
+	_, err := InstantiateGenericType(pair, []interface{}{intType}, env, nil)
+	if want := "not enough type arguments for Pair[T1, T2]: expected 2, got 1 (missing T2)"; err == nil || err.Error() != want {
+		t.Errorf("InstantiateGenericType() error = %v, want %q", err, want)
+	}
+
+	// instances keep the position of their declaration
+	instance, err := InstantiateGenericType(pair, []interface{}{intType, strType}, env, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+	if pos := instance.(*GenericType).Pos; pos != pair.Pos {
+		t.Errorf("instance Pos = %v, want %v", pos, pair.Pos)
+	}
+}
+
+func TestGenericTypeOrigin(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	stack := NewGenericType("Stack", TypeParamList{{Name: "T"}}, map[string]Type{
+		"items": &SliceType{ElementType: &TypeVariable{Name: "T"}},
+	}, nil)
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}}, map[string]Type{
+		"key":   &TypeVariable{Name: "K"},
+		"value": &TypeVariable{Name: "V"},
+	}, nil)
+	env := TypeEnv{"int": intType, "string": strType, "Stack": stack, "Pair": pair}
+
+	if stack.Origin() != nil {
+		t.Errorf("declaration Origin() = %v, want nil", stack.Origin())
+	}
+
+	src := "package foo\n\nvar s Stack[int]\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "foo.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	stack.Pos = file.Package
+	index := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type
+
+	got, err := InferType(index, env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	origin := got.(*GenericType).Origin()
+	if origin == nil {
+		t.Fatalf("instance Origin() = nil")
+	}
+	if origin.Decl != stack {
+		t.Errorf("Origin().Decl = %v, want the Stack declaration", origin.Decl)
+	}
+	if origin.Pos != index.Pos() {
+		t.Errorf("Origin().Pos = %v, want %v", origin.Pos, index.Pos())
+	}
+	if arg := origin.Mapping()["T"]; !TypesEqual(arg, intType) {
+		t.Errorf("Origin().Mapping()[T] = %v, want %v", arg, intType)
+	}
+	want := "Stack[TypeConst(int)] instantiated at foo.go:3:7 from Stack[T] declared at foo.go:1:1"
+	if desc := origin.Format(fset); desc != want {
+		t.Errorf("Origin().Format() = %q, want %q", desc, want)
+	}
+	if desc := origin.Format(nil); desc != "Stack[TypeConst(int)] instantiated from Stack[T]" {
+		t.Errorf("Origin().Format(nil) = %q", desc)
+	}
+
+	// instantiating a partial instance again still refers to the declaration
+	partial, err := InstantiateGenericType(pair, []interface{}{intType, &TypeVariable{Name: "V"}}, env, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+	partial.(*GenericType).origin.open = []bool{false, true} // V left out, see instantiatePartial
+	full, err := InstantiateGenericType(partial.(*GenericType), []interface{}{intType, strType}, env, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() on partial instance error = %v", err)
+	}
+	origin = full.(*GenericType).Origin()
+	if origin.Decl != pair {
+		t.Errorf("Origin().Decl = %v, want the Pair declaration", origin.Decl)
+	}
+	if mapping := origin.Mapping(); !TypesEqual(mapping["K"], intType) || !TypesEqual(mapping["V"], strType) {
+		t.Errorf("Origin().Mapping() = %v", mapping)
+	}
+
+	// substituting the remaining type parameters updates the recorded arguments
+	substituted := substituteTypeParams(partial, []Type{&TypeVariable{Name: "V"}}, []Type{strType}, NewTypeVisitor())
+	if arg := substituted.(*GenericType).Origin().Mapping()["V"]; !TypesEqual(arg, strType) {
+		t.Errorf("substituted Origin().Mapping()[V] = %v, want %v", arg, strType)
+	}
+}
+
+func TestTypeParamDefaults(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	k := &TypeVariable{Name: "K"}
+	v := &TypeVariable{Name: "V"}
+	dict := NewGenericType("Dict", TypeParamList{
+		{Name: "K", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}},
+		{Name: "V", Default: k},
+	}, map[string]Type{"entries": &MapType{KeyType: k, ValueType: v}}, nil)
+	cache := NewGenericType("Cache", TypeParamList{
+		{Name: "K"},
+		{Name: "V", Default: strType},
+		{Name: "S", Default: intType},
+	}, map[string]Type{"key": k, "value": v, "size": &TypeVariable{Name: "S"}}, nil)
+	opt := NewGenericType("Opt", TypeParamList{
+		{Name: "T", Default: &TypeConstant{Name: "func"}, Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}},
+	}, nil, nil)
+	env := TypeEnv{"int": intType, "string": strType, "Dict": dict, "Cache": cache, "Opt": opt}
+	extCtx := NewInferenceContext(WithExtensions(ExtTypeParamDefaults))
+
+	tests := []struct {
+		name       string
+		src        string
+		ctx        *InferenceContext
+		wantFields map[string]Type
+	}{
+		{
+			name:       "default refers to earlier parameter",
+			src:        "Dict[string]",
+			ctx:        extCtx,
+			wantFields: map[string]Type{"entries": &MapType{KeyType: strType, ValueType: strType}},
+		},
+		{
+			name:       "explicit argument overrides default",
+			src:        "Dict[string, int]",
+			ctx:        extCtx,
+			wantFields: map[string]Type{"entries": &MapType{KeyType: strType, ValueType: intType}},
+		},
+		{
+			name:       "several defaults",
+			src:        "Cache[int]",
+			ctx:        extCtx,
+			wantFields: map[string]Type{"key": intType, "value": strType, "size": intType},
+		},
+		{
+			name:       "some trailing defaults",
+			src:        "Cache[int, int]",
+			ctx:        extCtx,
+			wantFields: map[string]Type{"key": intType, "value": intType, "size": intType},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, tt.ctx)
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			fields := got.(*GenericType).Fields
+			for name, want := range tt.wantFields {
+				if !TypesEqual(unrenamed(fields[name]), want) {
+					t.Errorf("field %s = %v, want %v", name, fields[name], want)
+				}
+			}
+		})
+	}
+
+	// defaults are type checked against the constraints like explicit arguments
+	var ce *gerrors.ConstraintError
+	if _, err := InstantiateGenericType(opt, nil, env, extCtx); !errors.As(err, &ce) || ce.Param != "T" || ce.Arg != "func" {
+		t.Errorf("InstantiateGenericType() error = %v, want constraint error for T", err)
+	}
+	// omitting an argument without default is still an error
+	if _, err := InstantiateGenericType(dict, nil, env, extCtx); err == nil ||
+		err.Error() != "not enough type arguments for Dict[K, V]: expected 2, got 0 (missing K, V)" {
+		t.Errorf("InstantiateGenericType() error = %v", err)
+	}
+	// without the extension, the arguments with defaults must be given, like in Go
+	if _, err := InferType(mustParseExpr(t, "Cache[int, int]"), env, nil); err == nil ||
+		err.Error() != "not enough type arguments for Cache[K, V, S]: expected 3, got 2 (missing S)" {
+		t.Errorf("InferType() error = %v", err)
+	}
+}
+
+func TestInstantiateNestedGenericInstances(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	tv := &TypeVariable{Name: "T"}
+
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}}, map[string]Type{
+		"key":   &TypeVariable{Name: "K"},
+		"value": &TypeVariable{Name: "V"},
+	}, nil)
+	vector := NewGenericType("Vector", TypeParamList{{Name: "T"}}, map[string]Type{
+		"data":  &SliceType{ElementType: tv},
+		"first": tv,
+	}, MethodSet{
+		"Get": {Name: "Get", Params: []Type{intType}, Results: []Type{tv}},
+	})
+	box := NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{
+		// Vector[T] as seen from inside Box
+		"inner": &GenericType{
+			Name:       "Vector",
+			TypeParams: []Type{tv},
+			Fields: map[string]Type{
+				"data":  &SliceType{ElementType: tv},
+				"first": tv,
+			},
+			Methods: MethodSet{
+				"Get": {Name: "Get", Params: []Type{intType}, Results: []Type{tv}},
+			},
+		},
+	}, nil)
+	pairIntString := &GenericType{Name: "Pair", TypeParams: []Type{intType, strType}}
+	holder := NewGenericType("Holder", TypeParamList{
+		{Name: "T", Constraint: &TypeConstraint{Types: []Type{pairIntString}}},
+	}, map[string]Type{"item": tv}, nil)
+
+	env := TypeEnv{
+		"int":    intType,
+		"string": strType,
+		"Pair":   pair,
+		"Vector": vector,
+		"Box":    box,
+		"Holder": holder,
+	}
+
+	pairOf := func(k, v string) ast.Expr {
+		return &ast.IndexListExpr{X: &ast.Ident{Name: "Pair"}, Indices: []ast.Expr{&ast.Ident{Name: k}, &ast.Ident{Name: v}}}
+	}
+	instantiate := func(name string, arg ast.Expr) (*GenericType, error) {
+		got, err := InferType(&ast.IndexExpr{X: &ast.Ident{Name: name}, Index: arg}, env, nil)
+		if err != nil {
+			return nil, err
+		}
+		return got.(*GenericType), nil
+	}
+	checkPair := func(t *testing.T, typ Type, key, value Type) {
+		t.Helper()
+		p, ok := typ.(*GenericType)
+		if !ok || p.Name != "Pair" {
+			t.Fatalf("got %v, want Pair instance", typ)
+		}
+		if !TypesEqual(p.Fields["key"], key) || !TypesEqual(p.Fields["value"], value) {
+			t.Errorf("Pair fields = %v, %v, want %v, %v", p.Fields["key"], p.Fields["value"], key, value)
+		}
+	}
+
+	t.Run("Vector of Pair", func(t *testing.T) {
+		v, err := instantiate("Vector", pairOf("int", "string"))
+		if err != nil {
+			t.Fatalf("InferType() error = %v", err)
+		}
+		checkPair(t, v.TypeParams[0], intType, strType)
+		checkPair(t, v.Fields["data"].(*SliceType).ElementType, intType, strType)
+		checkPair(t, v.Fields["first"], intType, strType)
+		checkPair(t, v.Methods["Get"].Results[0], intType, strType)
+	})
+
+	t.Run("Box of Pair through nested Vector field", func(t *testing.T) {
+		b, err := instantiate("Box", pairOf("string", "int"))
+		if err != nil {
+			t.Fatalf("InferType() error = %v", err)
+		}
+		inner, ok := b.Fields["inner"].(*GenericType)
+		if !ok {
+			t.Fatalf("inner = %v, want Vector instance", b.Fields["inner"])
+		}
+		checkPair(t, inner.TypeParams[0], strType, intType)
+		checkPair(t, inner.Fields["data"].(*SliceType).ElementType, strType, intType)
+		checkPair(t, inner.Fields["first"], strType, intType)
+		checkPair(t, inner.Methods["Get"].Results[0], strType, intType)
+	})
+
+	t.Run("Vector of Vector of Pair", func(t *testing.T) {
+		nested := &ast.IndexExpr{X: &ast.Ident{Name: "Vector"}, Index: pairOf("int", "int")}
+		v, err := instantiate("Vector", nested)
+		if err != nil {
+			t.Fatalf("InferType() error = %v", err)
+		}
+		inner, ok := v.Fields["first"].(*GenericType)
+		if !ok || inner.Name != "Vector" {
+			t.Fatalf("first = %v, want Vector instance", v.Fields["first"])
+		}
+		checkPair(t, inner.Fields["first"], intType, intType)
+	})
+
+	t.Run("Constraint satisfied by inner instance", func(t *testing.T) {
+		h, err := instantiate("Holder", pairOf("int", "string"))
+		if err != nil {
+			t.Fatalf("InferType() error = %v", err)
+		}
+		checkPair(t, h.Fields["item"], intType, strType)
+	})
+
+	t.Run("Constraint not satisfied by inner instance", func(t *testing.T) {
+		if _, err := instantiate("Holder", pairOf("string", "int")); err == nil {
+			t.Errorf("InferType() expected constraint error")
+		}
+	})
+}
+
+func TestInstantiateSelfReferentialGeneric(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	tv := &TypeVariable{Name: "T"}
+
+	// Tree[T] as seen from inside its own declaration
+	treeOfT := &GenericType{Name: "Tree", TypeParams: []Type{tv}}
+	tree := NewGenericType("Tree", TypeParamList{{Name: "T"}}, map[string]Type{
+		"value":    tv,
+		"left":     treeOfT,
+		"children": &SliceType{ElementType: treeOfT},
+	}, MethodSet{
+		"Left": {Name: "Left", Results: []Type{treeOfT}},
+	})
+	treeOfT.Fields = tree.Fields
+
+	env := TypeEnv{"int": intType, "Tree": tree}
+	got, err := InferType(&ast.IndexExpr{X: &ast.Ident{Name: "Tree"}, Index: &ast.Ident{Name: "int"}}, env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	instance := got.(*GenericType)
+
+	if !TypesEqual(instance.Fields["value"], intType) {
+		t.Errorf("value = %v, want %v", instance.Fields["value"], intType)
+	}
+	if instance.Fields["left"] != Type(instance) {
+		t.Errorf("left = %v, want the instance itself", instance.Fields["left"])
+	}
+	if elem := instance.Fields["children"].(*SliceType).ElementType; elem != Type(instance) {
+		t.Errorf("children element = %v, want the instance itself", elem)
+	}
+	if result := instance.Methods["Left"].Results[0]; result != Type(instance) {
+		t.Errorf("Left() result = %v, want the instance itself", result)
+	}
+
+	// the declaration is left untouched
+	if tree.Fields["left"] != Type(treeOfT) || !TypesEqual(tree.Fields["value"], tv) {
+		t.Errorf("declaration fields modified: %v", tree.Fields)
+	}
+
+	// operations on the cyclic instance terminate
+	if s := instance.String(); s != "Generic(Tree, [TypeConst(int)])" {
+		t.Errorf("String() = %q", s)
+	}
+	if !TypesEqual(instance, instance.Fields["left"]) {
+		t.Errorf("TypesEqual() = false for self reference")
+	}
+	if _, err := Unify(instance, instance.Fields["left"], make(TypeEnv)); err != nil {
+		t.Errorf("Unify() error = %v", err)
+	}
+}
+
+func TestInferTypeReturnErrors(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	env := TypeEnv{
+		"x": intType,
+		"y": strType,
+	}
+	single := &FunctionType{ReturnType: intType}
+	pair := &FunctionType{ReturnType: &TupleType{Types: []Type{intType, strType}}}
+
+	tests := []struct {
+		name       string
+		src        string
+		expected   Type
+		noFileSet  bool
+		errMessage string
+		errIs      error
+	}{
+		{
+			name:       "undefined identifier",
+			src:        "undefined",
+			expected:   single,
+			errMessage: "result 1 at r.go:1:3: unknown identifier: undefined",
+		},
+		{
+			name:       "undefined identifier in second result",
+			src:        "x, undefined",
+			expected:   pair,
+			errMessage: "result 2 at r.go:1:6: unknown identifier: undefined",
+		},
+		{
+			name:       "mismatched result",
+			src:        "y",
+			expected:   single,
+			errMessage: "result 1 at r.go:1:3: cannot use string as int in return statement: type mismatch",
+			errIs:      ErrTypeMismatch,
+		},
+		{
+			name:       "mismatched second result",
+			src:        "x, x",
+			expected:   pair,
+			errMessage: "result 2 at r.go:1:6: cannot use int as string in return statement: type mismatch",
+			errIs:      ErrTypeMismatch,
+		},
+		{
+			name:       "without file set",
+			src:        "x, x",
+			expected:   pair,
+			noFileSet:  true,
+			errMessage: "result 2: cannot use int as string in return statement: type mismatch",
+			errIs:      ErrTypeMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// parse the results as the elements of a composite literal to get positions
+			fset := token.NewFileSet()
+			lit, err := parser.ParseExprFrom(fset, "r.go", "T{"+tt.src+"}", 0)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			elts := lit.(*ast.CompositeLit).Elts
+
+			ctx := NewInferenceContext(WithExpectedType(tt.expected), WithFileSet(fset))
+			if tt.noFileSet {
+				ctx = NewInferenceContext(WithExpectedType(tt.expected))
+			}
+			_, err = InferType(&ast.ReturnStmt{Results: elts}, env, ctx)
+			if err == nil {
+				t.Fatalf("InferType() expected error %q", tt.errMessage)
+			}
+			if err.Error() != tt.errMessage {
+				t.Errorf("InferType() error = %q, want %q", err, tt.errMessage)
+			}
+			if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+				t.Errorf("InferType() error = %v, want %v", err, tt.errIs)
+			}
+		})
+	}
+}
+
+// parseStmt parses a single statement inside a function body.
+func parseStmt(t *testing.T, src string) ast.Stmt {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+src+"\n}", 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	return file.Decls[0].(*ast.FuncDecl).Body.List[0]
+}
+
+func TestInferAssignStmt(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	errType := &TypeConstant{Name: "error"}
+	newEnv := func() TypeEnv {
+		return TypeEnv{
+			"n":    intType,
+			"s":    strType,
+			"p":    &PointerType{Base: intType},
+			"xs":   &SliceType{ElementType: intType},
+			"arr":  &PointerType{Base: &ArrayType{ElementType: strType, Len: 3}},
+			"m":    &MapType{KeyType: strType, ValueType: intType},
+			"pt":   &StructType{Name: "Point", Fields: map[string]Type{"X": intType}},
+			"read": &FunctionType{ReturnType: &TupleType{Types: []Type{intType, errType}}},
+			"f":    &FunctionType{ParamTypes: []Type{intType}},
+			"i32":  &TypeConstant{Name: "int32"},
+			"fl":   &TypeConstant{Name: "float64"},
+			"a":    &InterfaceType{Name: ConstraintAny, IsEmpty: true},
+			"e":    errType,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+		defined map[string]Type
+	}{
+		{name: "variable", src: "n = 1"},
+		{name: "index of slice", src: "xs[0] = n"},
+		{name: "index of pointer to array", src: `arr[1] = s`},
+		{name: "index of map", src: `m["a"] = 1`},
+		{name: "field selector", src: "pt.X = 2"},
+		{name: "pointer indirection", src: "*p = n"},
+		{name: "blank identifier", src: "_ = s"},
+		{name: "tuple to targets", src: "n, _ = read()"},
+		{name: "define new variables", src: "k, err := read()", defined: map[string]Type{"k": intType, "err": errType}},
+		{name: "define with known variable", src: "n, v := 1, s", defined: map[string]Type{"v": strType}},
+		{name: "nil to function", src: "f = nil"},
+		{name: "untyped constant to float", src: "fl = 1 << 2"},
+		{name: "shifted constant", src: "i32 = 1 << 20"},
+		{name: "untyped constant to any", src: `a = "s"`},
+		{name: "untyped float to any", src: "a = 1.5"},
+		{name: "untyped constant to error", src: "e = 3", wantErr: "assignment type mismatch for e: cannot use 3 (untyped int constant) as error value"},
+		{name: "constant of other kind", src: `fl = "a"`, wantErr: `assignment type mismatch for fl: cannot convert "a" (untyped string constant) to type float64`},
+		{name: "unknown variable", src: "undefined = 1", wantErr: "unknown identifier: undefined"},
+		{name: "unknown variable in index", src: "zs[0] = 1", wantErr: "unknown identifier: zs"},
+		{name: "unknown field", src: "pt.Y = 1", wantErr: "Y"},
+		{name: "indirection of non-pointer", src: "*n = 1", wantErr: "invalid indirect of n (type int)"},
+		{name: "non-integer slice index", src: `xs["a"] = 1`, wantErr: `invalid argument: index "a" (type string) must be integer`},
+		{name: "wrong map key", src: "m[1] = 1", wantErr: "cannot use 1 (type int) as string in map index"},
+		{name: "index of non-indexable", src: "n[0] = 1", wantErr: "cannot index n (type int)"},
+		{name: "mismatched element", src: `xs[0] = s`, wantErr: "assignment type mismatch for xs[0]"},
+		{name: "too many values", src: "n = 1, 2", wantErr: "assignment mismatch: 1 variables but 2 values"},
+		{name: "too few values", src: "n, s = 1", wantErr: "assignment mismatch: 2 variables but 1 value"},
+		{name: "tuple in single-value context", src: "n, s = read(), 1", wantErr: "assignment mismatch: multiple-value read() (2 values) in single-value context"},
+		{name: "tuple to wrong number of targets", src: "a, b, c := read()", wantErr: "assignment mismatch: 3 variables but 2 values"},
+		{name: "no new variables", src: "n := 1", wantErr: "no new variables on left side of :="},
+		{name: "define non-name", src: "xs[0] := 1", wantErr: "non-name xs[0] on left side of :="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newEnv()
+			_, err := InferType(parseStmt(t, tt.src), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			for name, want := range tt.defined {
+				if got := unwrapObject(env[name]); !TypesEqual(got, want) {
+					t.Errorf("env[%s] = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInferFloatLiteral(t *testing.T) {
+	float32Type := &TypeConstant{Name: "float32"}
+	complexType := &TypeConstant{Name: "complex128"}
+
+	tests := []struct {
+		value    string
+		expected Type
+		want     string
+	}{
+		{"3.14", nil, "float64"},
+		{"1e9", nil, "float64"},
+		{"0x1p-2", nil, "float64"},
+		{".5", nil, "float64"},
+		{"1e9", float32Type, "float32"},
+		{"2.5", complexType, "complex128"},
+		// an expected type that cannot hold a float leaves the default, and unification reports it
+		{"2.5", &TypeConstant{Name: "string"}, "float64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s as %v", tt.value, tt.expected), func(t *testing.T) {
+			got, err := InferType(&ast.BasicLit{Kind: token.FLOAT, Value: tt.value}, TypeEnv{}, NewInferenceContext(WithExpectedType(tt.expected)))
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if !TypesEqual(got, &TypeConstant{Name: tt.want}) {
+				t.Errorf("InferType() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferGenericFunctionCall(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	tv, uv := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}
+
+	// func Map[T, U any](xs []T, f func(T) U) []U
+	mapFunc := NewGenericFunction("Map", TypeParamList{{Name: "T"}, {Name: "U"}}, &FunctionType{
+		ParamTypes: []Type{&SliceType{ElementType: tv}, &FunctionType{ParamTypes: []Type{tv}, ReturnType: uv}},
+		ReturnType: &SliceType{ElementType: uv},
+	})
+	// func Identity[T comparable](x T) T
+	identity := NewGenericFunction("Identity", TypeParamList{
+		{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}},
+	}, &FunctionType{ParamTypes: []Type{tv}, ReturnType: tv})
+
+	// func Sum[T ~int](xs []T) T
+	sum := NewGenericFunction("Sum", TypeParamList{
+		{Name: "T", Constraint: &TypeConstraint{Types: []Type{&ApproxType{Base: intType}}}},
+	}, &FunctionType{ParamTypes: []Type{&SliceType{ElementType: tv}}, ReturnType: tv})
+	age := &NamedType{Name: "Age", Underlying: intType}
+
+	env := TypeEnv{
+		"int":      intType,
+		"string":   strType,
+		"Map":      mapFunc,
+		"Identity": identity,
+		"Sum":      sum,
+		"Age":      age,
+		"ages":     &SliceType{ElementType: age},
+		"xs":       &SliceType{ElementType: intType},
+		"itoa":     &FunctionType{ParamTypes: []Type{intType}, ReturnType: strType},
+		"n":        intType,
+		"fn":       &FunctionType{ParamTypes: []Type{intType}},
+	}
+
+	tests := []struct {
+		name     string
+		src      string
+		wantType Type
+		wantErr  string
+	}{
+		{name: "all type arguments", src: "Map[int, string](xs, itoa)", wantType: &SliceType{ElementType: strType}},
+		{name: "single type argument", src: "Identity[int](n)", wantType: intType},
+		{name: "defined type for approximation", src: "Sum[Age](ages)", wantType: age},
+		{name: "defined type argument mismatch", src: "Sum[Age](xs)", wantErr: "argument type mismatch for arg 1"},
+		{name: "approximation not satisfied", src: "Sum[string](xs)", wantErr: "does not satisfy constraint"},
+		{name: "argument mismatch", src: "Map[int, string](xs, xs)", wantErr: "argument type mismatch for arg 2"},
+		{name: "type argument mismatch", src: "Identity[string](n)", wantErr: "argument type mismatch for arg 1"},
+		{name: "constraint not satisfied", src: "Identity[fn](n)", wantErr: "does not satisfy constraint"},
+		{name: "partial instantiation", src: "Map[int](xs, itoa)", wantType: &SliceType{ElementType: strType}},
+		{name: "no instantiation", src: "Identity(n)", wantType: intType},
+		{name: "too many type arguments", src: "Identity[int, int](n)", wantErr: "too many type arguments for Identity[T]: expected 1, got 2 (extra argument #2 int)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if !TypesEqual(got, tt.wantType) {
+				t.Errorf("InferType() = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+
+	// the declaration is left untouched by instantiation
+	if !TypesEqual(mapFunc.Signature.ReturnType, &SliceType{ElementType: uv}) {
+		t.Errorf("Map signature modified: %v", mapFunc.Signature)
+	}
+}
+
+func TestGenericTypeUnderlying(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	tv := &TypeVariable{Name: "T"}
+
+	list := NewGenericType("List", TypeParamList{{Name: "T"}}, map[string]Type{
+		"items": &SliceType{ElementType: tv},
+	}, MethodSet{
+		"Len":  {Name: "Len", Results: []Type{intType}},
+		"Push": {Name: "Push", Params: []Type{tv}, IsPointer: true},
+	})
+	container := &GenericType{
+		Name:        "Container",
+		TypeParams:  []Type{tv},
+		Methods:     MethodSet{"Get": {Name: "Get", Results: []Type{tv}}},
+		IsInterface: true,
+	}
+	apply := NewGenericFunction("Apply", TypeParamList{{Name: "T"}}, &FunctionType{ParamTypes: []Type{tv}, ReturnType: tv})
+
+	env := TypeEnv{
+		"int":       intType,
+		"List":      list,
+		"Container": container,
+		"Apply":     apply,
+		"l":         mustInstantiate(t, list, intType),
+		"pl":        &PointerType{Base: mustInstantiate(t, list, intType)},
+		"call":      &FunctionType{ParamTypes: []Type{&FunctionType{ParamTypes: []Type{intType}, ReturnType: intType}}, ReturnType: intType},
+	}
+
+	t.Run("forms", func(t *testing.T) {
+		st, ok := env["l"].(*GenericType).Underlying().(*StructType)
+		if !ok || st.Name != "List[TypeConst(int)]" {
+			t.Fatalf("Underlying() = %v, want struct", env["l"].(*GenericType).Underlying())
+		}
+		if !TypesEqual(st.Fields["items"], &SliceType{ElementType: intType}) {
+			t.Errorf("Underlying() items = %v", st.Fields["items"])
+		}
+		iface, ok := mustInstantiate(t, container, intType).Underlying().(*InterfaceType)
+		if !ok || !TypesEqual(iface.Methods["Get"].Results[0], intType) {
+			t.Errorf("Underlying() of generic interface = %v", iface)
+		}
+		if sig, ok := mustInstantiate(t, apply, intType).Underlying().(*FunctionType); !ok || !TypesEqual(sig.ReturnType, intType) {
+			t.Errorf("Underlying() of generic function = %v", sig)
+		}
+	})
+
+	t.Run("field access", func(t *testing.T) {
+		for _, src := range []string{"l.items", "pl.items"} {
+			got, err := InferType(mustParseExpr(t, src), env, nil)
+			if err != nil {
+				t.Fatalf("InferType(%s) error = %v", src, err)
+			}
+			if !TypesEqual(got, &SliceType{ElementType: intType}) {
+				t.Errorf("InferType(%s) = %v", src, got)
+			}
+		}
+	})
+
+	t.Run("method call", func(t *testing.T) {
+		got, err := InferType(mustParseExpr(t, "l.Len()"), env, nil)
+		if err != nil {
+			t.Fatalf("InferType() error = %v", err)
+		}
+		if !TypesEqual(got, intType) {
+			t.Errorf("InferType() = %v, want %v", got, intType)
+		}
+	})
+
+	t.Run("method sets", func(t *testing.T) {
+		if ms := CalculateMethodSet(env["l"]); len(ms) != 1 {
+			t.Errorf("CalculateMethodSet(List[int]) = %v, want only Len", ms)
+		}
+		if ms := CalculateMethodSet(env["pl"]); len(ms) != 2 {
+			t.Errorf("CalculateMethodSet(*List[int]) = %v, want Len and Push", ms)
+		}
+		pusher := Interface{Name: "Pusher", Methods: MethodSet{"Push": {Name: "Push", Params: []Type{intType}}}}
+		if implInterface(env["l"], pusher) {
+			t.Errorf("implInterface(List[int], Pusher) = true, want false")
+		}
+		if !implInterface(env["pl"], pusher) {
+			t.Errorf("implInterface(*List[int], Pusher) = false, want true")
+		}
+	})
+
+	t.Run("assignability", func(t *testing.T) {
+		got, err := InferType(mustParseExpr(t, "call(Apply[int])"), env, nil)
+		if err != nil {
+			t.Fatalf("InferType() error = %v", err)
+		}
+		if !TypesEqual(got, intType) {
+			t.Errorf("InferType() = %v, want %v", got, intType)
+		}
+	})
+}
+
+func mustInstantiate(t *testing.T, gt *GenericType, args ...Type) *GenericType {
+	t.Helper()
+	typeArgs := make([]interface{}, len(args))
+	for i, arg := range args {
+		typeArgs[i] = arg
+	}
+	instance, err := InstantiateGenericType(gt, typeArgs, TypeEnv{}, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+	return instance.(*GenericType)
+}
+
+func mustParseExpr(t *testing.T, src string) ast.Expr {
+	t.Helper()
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		t.Fatalf("ParseExpr() error = %v", err)
+	}
+	return expr
+}
+
+func TestInferNil(t *testing.T) {
+	env := mustBuildEnv(t, `package p
+
+type Point struct{ X, Y int }
+
+type Bytes []byte
+
+func Equal[T comparable](a, b T) bool { return true }
+func Identity[T any](x T) T { return x }
+func use(p *Point, xs []int, f func()) {}
+
+var (
+	p   *Point = nil
+	xs  []int
+	m   map[string]int
+	err error
+	n   int
+	b   Bytes
+)
+`)
+	results := NewInferenceContext(WithExpectedType(&FunctionType{
+		ReturnType: &TupleType{Types: []Type{&PointerType{Base: env["Point"]}, Error}},
+	}))
+
+	tests := []struct {
+		src     string
+		want    string // the type of an expression, empty for a statement
+		wantErr bool
+	}{
+		{src: "nil", want: "untyped nil"},
+		{src: "p == nil", want: "bool"},
+		{src: "nil != err", want: "bool"},
+		{src: "xs == nil", want: "bool"},
+		{src: "b == nil", want: "bool"},
+		{src: "(*Point)(nil)", want: "*Point"},
+		{src: "[]int(nil)", want: "[]int"},
+		{src: "use(nil, nil, nil)", want: ""},
+		{src: "Equal(p, nil)", want: "bool"},
+		{src: "Equal(nil, m == nil)", wantErr: true},
+		{src: "Identity(nil)", wantErr: true},
+		{src: "n == nil", wantErr: true},
+		{src: "nil == nil", wantErr: true},
+		{src: "p + nil", wantErr: true},
+		{src: "int(nil)", wantErr: true},
+		{src: "return nil, nil"},
+		{src: "return nil, err"},
+		{src: "return p, nil"},
+		{src: "return n, nil", wantErr: true},
+		{src: "x := nil", wantErr: true},
+		{src: "_ = nil", wantErr: true},
+		{src: "p = nil"},
+		{src: "m = nil"},
+		{src: "n = nil", wantErr: true},
+		{src: "var q *Point = nil"},
+		{src: "var q = nil", wantErr: true},
+		{src: "var q int = nil", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			file, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\nfunc _() {\n"+tt.src+"\n}", 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			var node interface{} = file.Decls[0].(*ast.FuncDecl).Body.List[0]
+			var ctx *InferenceContext
+			switch stmt := node.(type) {
+			case *ast.ExprStmt:
+				node = stmt.X
+			case *ast.ReturnStmt:
+				ctx = results
+			}
+			got, err := InferType(node, maps.Clone(env), ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("InferType() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if tt.want != "" && FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
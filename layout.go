@@ -0,0 +1,191 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownSize = errors.New("unknown size")
+
+// Sizes estimates the memory layout of types the way the gc compiler lays them out,
+// for a target with the given word size and maximum alignment, both in bytes.
+type Sizes struct {
+	WordSize int64
+	MaxAlign int64
+}
+
+// DefaultSizes are the sizes of 64-bit targets, like amd64 and arm64.
+var DefaultSizes = Sizes{WordSize: 8, MaxAlign: 8}
+
+// basicSizes holds the sizes of the predeclared types that do not depend on the word size.
+var basicSizes = map[string]int64{
+	TypeBool: 1, TypeInt8: 1, TypeUint8: 1, TypeByte: 1,
+	TypeInt16: 2, TypeUint16: 2,
+	TypeInt32: 4, TypeUint32: 4, TypeRune: 4, TypeFloat32: 4,
+	TypeInt64: 8, TypeUint64: 8, TypeFloat64: 8, TypeComplex64: 8,
+	TypeComplex128: 16,
+}
+
+// FieldLayout is the position of a struct field.
+type FieldLayout struct {
+	Name    string
+	Offset  int64
+	Size    int64
+	Align   int64
+	Padding int64 // bytes of padding inserted before the field to align it
+}
+
+// StructLayout is the layout of a struct, with its fields in declaration order.
+type StructLayout struct {
+	Fields          []FieldLayout
+	Size            int64
+	Align           int64
+	TrailingPadding int64 // bytes of padding after the last field, to round the size up to the alignment
+}
+
+// Padding returns the total number of padding bytes in the struct.
+func (l *StructLayout) Padding() int64 {
+	padding := l.TrailingPadding
+	for _, f := range l.Fields {
+		padding += f.Padding
+	}
+	return padding
+}
+
+// PaddedFields returns the fields preceded by padding, which are the fields
+// whose alignment the previous fields leave unmet.
+func (l *StructLayout) PaddedFields() []FieldLayout {
+	var padded []FieldLayout
+	for _, f := range l.Fields {
+		if f.Padding > 0 {
+			padded = append(padded, f)
+		}
+	}
+	return padded
+}
+
+// Sizeof returns the size of a value of type t in bytes.
+// Instances of generic structs are laid out with their type arguments.
+// It fails with ErrUnknownSize for types whose size depends on something the
+// type does not describe, like type parameters or named types of unknown kind.
+func (s Sizes) Sizeof(t Type) (int64, error) {
+	size, _, err := s.layout(t, NewTypeVisitor())
+	return size, err
+}
+
+// Alignof returns the alignment of a value of type t in bytes, see Sizeof.
+func (s Sizes) Alignof(t Type) (int64, error) {
+	_, align, err := s.layout(t, NewTypeVisitor())
+	return align, err
+}
+
+// Layout returns the layout of the struct type t, a struct or an instance of a generic struct.
+func (s Sizes) Layout(t Type) (*StructLayout, error) {
+	fields, order, ok := structFields(t)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct", FormatGo(t))
+	}
+	return s.structLayout(fields, order, NewTypeVisitor())
+}
+
+// structFields returns the fields of a struct type in declaration order.
+func structFields(t Type) (map[string]Type, []string, bool) {
+	switch t := unalias(t).(type) {
+	case *StructType:
+		return t.Fields, t.FieldNames(), true
+	case *GenericType:
+		if t.isStruct() {
+			return t.Fields, fieldNames(t.Fields, t.FieldOrder), true
+		}
+	}
+	return nil, nil, false
+}
+
+func (s Sizes) layout(t Type, visitor *TypeVisitor) (size, align int64, err error) {
+	word := s.WordSize
+	switch t := t.(type) {
+	case *TypeConstant:
+		switch t.Name {
+		case TypeInt, TypeUint, TypeUintptr:
+			return word, word, nil
+		case TypeString:
+			return 2 * word, word, nil
+		case "error":
+			return 2 * word, word, nil
+		}
+		if size, ok := basicSizes[t.Name]; ok {
+			align := size
+			if t.Name == TypeComplex64 || t.Name == TypeComplex128 {
+				align = size / 2
+			}
+			return size, min(align, s.MaxAlign), nil
+		}
+	case *TypeAlias:
+		return s.layout(t.AliasedTo, visitor)
+	case *NamedType:
+		// a defined type can only contain itself through a pointer, slice, map or the like
+		if visitor.Visit(t) {
+			return 0, 0, fmt.Errorf("invalid recursive type %s", t.Name)
+		}
+		defer visitor.Leave(t)
+		return s.layout(t.Underlying, visitor)
+	case *PointerType, *MapType, *ChanType, *FunctionType:
+		return word, word, nil
+	case *SliceType:
+		return 3 * word, word, nil
+	case *InterfaceType, *Interface:
+		return 2 * word, word, nil
+	case *ArrayType:
+		size, align, err := s.layout(t.ElementType, visitor)
+		if err != nil {
+			return 0, 0, err
+		}
+		return size * int64(t.Len), align, nil
+	case *GenericType:
+		switch {
+		case t.Signature != nil:
+			return word, word, nil
+		case t.IsInterface:
+			return 2 * word, word, nil
+		case t.UnderlyingType != nil:
+			return s.layout(t.UnderlyingType, visitor)
+		}
+	}
+
+	fields, order, ok := structFields(t)
+	if !ok {
+		return 0, 0, fmt.Errorf("%w of %s", ErrUnknownSize, FormatGo(t))
+	}
+	// a struct can only contain itself through a pointer, slice, map or the like
+	if visitor.Visit(t) {
+		return 0, 0, fmt.Errorf("invalid recursive type %s", FormatGo(t))
+	}
+	defer visitor.Leave(t)
+	l, err := s.structLayout(fields, order, visitor)
+	if err != nil {
+		return 0, 0, err
+	}
+	return l.Size, l.Align, nil
+}
+
+func (s Sizes) structLayout(fields map[string]Type, order []string, visitor *TypeVisitor) (*StructLayout, error) {
+	l := &StructLayout{Align: 1}
+	var offset int64
+	for _, name := range order {
+		size, align, err := s.layout(fields[name], visitor)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		aligned := alignUp(offset, align)
+		l.Fields = append(l.Fields, FieldLayout{Name: name, Offset: aligned, Size: size, Align: align, Padding: aligned - offset})
+		offset = aligned + size
+		l.Align = max(l.Align, align)
+	}
+	l.Size = alignUp(offset, l.Align)
+	l.TrailingPadding = l.Size - offset
+	return l, nil
+}
+
+func alignUp(x, align int64) int64 {
+	return (x + align - 1) / align * align
+}
@@ -0,0 +1,138 @@
+package generic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSizeof(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	boolType := &TypeConstant{Name: "bool"}
+	int64Type := &TypeConstant{Name: "int64"}
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}},
+		map[string]Type{"Key": &TypeVariable{Name: "K"}, "Value": &TypeVariable{Name: "V"}}, nil)
+	pair.FieldOrder = []string{"Key", "Value"}
+	sizes32 := Sizes{WordSize: 4, MaxAlign: 4}
+	list := &NamedType{Name: "List"}
+	list.Underlying = &SliceType{ElementType: list}
+
+	tests := []struct {
+		name      string
+		sizes     Sizes
+		typ       Type
+		wantSize  int64
+		wantAlign int64
+	}{
+		{name: "bool", sizes: DefaultSizes, typ: boolType, wantSize: 1, wantAlign: 1},
+		{name: "int", sizes: DefaultSizes, typ: intType, wantSize: 8, wantAlign: 8},
+		{name: "int on 32-bit", sizes: sizes32, typ: intType, wantSize: 4, wantAlign: 4},
+		{name: "int64 on 32-bit", sizes: sizes32, typ: int64Type, wantSize: 8, wantAlign: 4},
+		{name: "complex64", sizes: DefaultSizes, typ: &TypeConstant{Name: "complex64"}, wantSize: 8, wantAlign: 4},
+		{name: "string", sizes: DefaultSizes, typ: &TypeConstant{Name: "string"}, wantSize: 16, wantAlign: 8},
+		{name: "slice", sizes: DefaultSizes, typ: &SliceType{ElementType: boolType}, wantSize: 24, wantAlign: 8},
+		{name: "map", sizes: DefaultSizes, typ: &MapType{KeyType: intType, ValueType: intType}, wantSize: 8, wantAlign: 8},
+		{name: "interface", sizes: DefaultSizes, typ: &InterfaceType{IsEmpty: true}, wantSize: 16, wantAlign: 8},
+		{name: "array", sizes: DefaultSizes, typ: &ArrayType{ElementType: &TypeConstant{Name: "int16"}, Len: 3}, wantSize: 6, wantAlign: 2},
+		{
+			name:      "struct",
+			sizes:     DefaultSizes,
+			typ:       &StructType{Name: "S", Fields: map[string]Type{"A": boolType, "B": int64Type, "C": boolType}, FieldOrder: []string{"A", "B", "C"}},
+			wantSize:  24,
+			wantAlign: 8,
+		},
+		{name: "empty struct", sizes: DefaultSizes, typ: &StructType{Fields: map[string]Type{}}, wantSize: 0, wantAlign: 1},
+		{name: "generic instance", sizes: DefaultSizes, typ: mustInstantiate(t, pair, boolType, int64Type), wantSize: 16, wantAlign: 8},
+		{name: "generic instance of small types", sizes: DefaultSizes, typ: mustInstantiate(t, pair, boolType, boolType), wantSize: 2, wantAlign: 1},
+		{name: "defined type", sizes: DefaultSizes, typ: &NamedType{Name: "Age", Underlying: &TypeConstant{Name: "int32"}}, wantSize: 4, wantAlign: 4},
+		{name: "slice of defined type", sizes: DefaultSizes, typ: list, wantSize: 24, wantAlign: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := tt.sizes.Sizeof(tt.typ)
+			if err != nil {
+				t.Fatalf("Sizeof() error = %v", err)
+			}
+			align, err := tt.sizes.Alignof(tt.typ)
+			if err != nil {
+				t.Fatalf("Alignof() error = %v", err)
+			}
+			if size != tt.wantSize || align != tt.wantAlign {
+				t.Errorf("Sizeof(), Alignof() = %d, %d, want %d, %d", size, align, tt.wantSize, tt.wantAlign)
+			}
+		})
+	}
+}
+
+func TestSizeofErrors(t *testing.T) {
+	node := &StructType{Name: "Node", Fields: map[string]Type{}}
+	node.Fields["Next"] = node
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}}, map[string]Type{"Key": &TypeVariable{Name: "K"}}, nil)
+	array := &NamedType{Name: "A"}
+	array.Underlying = &ArrayType{ElementType: array, Len: 2}
+
+	tests := []struct {
+		name    string
+		typ     Type
+		wantErr error
+		wantMsg string
+	}{
+		{name: "type parameter", typ: &TypeVariable{Name: "T"}, wantErr: ErrUnknownSize, wantMsg: "unknown size of T"},
+		{name: "uninstantiated generic", typ: pair, wantErr: ErrUnknownSize, wantMsg: "field Key: unknown size of K"},
+		{name: "recursive struct", typ: node, wantMsg: "field Next: invalid recursive type Node"},
+		{name: "recursive defined type", typ: array, wantMsg: "invalid recursive type A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DefaultSizes.Sizeof(tt.typ)
+			if err == nil {
+				t.Fatal("Sizeof() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Sizeof() error = %v, want %v", err, tt.wantErr)
+			}
+			if err.Error() != tt.wantMsg {
+				t.Errorf("Sizeof() error = %q, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestLayout(t *testing.T) {
+	boolType := &TypeConstant{Name: "bool"}
+	st := &StructType{
+		Name:       "S",
+		Fields:     map[string]Type{"A": boolType, "B": &TypeConstant{Name: "int32"}, "C": boolType},
+		FieldOrder: []string{"A", "B", "C"},
+	}
+
+	l, err := DefaultSizes.Layout(st)
+	if err != nil {
+		t.Fatalf("Layout() error = %v", err)
+	}
+	want := &StructLayout{
+		Fields: []FieldLayout{
+			{Name: "A", Offset: 0, Size: 1, Align: 1},
+			{Name: "B", Offset: 4, Size: 4, Align: 4, Padding: 3},
+			{Name: "C", Offset: 8, Size: 1, Align: 1},
+		},
+		Size:            12,
+		Align:           4,
+		TrailingPadding: 3,
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("Layout() = %+v, want %+v", l, want)
+	}
+	if got := l.Padding(); got != 6 {
+		t.Errorf("Padding() = %d, want 6", got)
+	}
+	if got := l.PaddedFields(); !reflect.DeepEqual(got, want.Fields[1:2]) {
+		t.Errorf("PaddedFields() = %+v, want %+v", got, want.Fields[1:2])
+	}
+
+	if _, err := DefaultSizes.Layout(boolType); err == nil || err.Error() != "bool is not a struct" {
+		t.Errorf("Layout(bool) error = %v", err)
+	}
+}
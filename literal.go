@@ -0,0 +1,129 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+)
+
+// inferLiteralOf infers the composite literal lit of type t, given by a defined type other
+// than a struct, like `MethodSet{...}`, a qualified one, like `ast.Ident{...}`, or left out,
+// like the elements of `[]Pair{{1, 2}}`, which take the element type. An element of
+// pointer type left out stands for the address of a literal, like `{1, 2}` for `&Pair{1, 2}`.
+func inferLiteralOf(lit *ast.CompositeLit, t Type, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	if ptr, ok := unalias(t).(*PointerType); ok && lit.Type == nil {
+		if _, err := inferLiteralOf(lit, ptr.Base, env, ctx); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	switch u := coreType(t, env).(type) {
+	case *StructType:
+		if err := checkStructLit(lit, u, env, ctx); err != nil {
+			return nil, err
+		}
+	case *MapType:
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, fmt.Errorf("missing key in map literal")
+			}
+			if err := checkElement(kv.Key, u.KeyType, "map key", env, ctx); err != nil {
+				return nil, err
+			}
+			if err := checkElement(kv.Value, u.ValueType, "map value", env, ctx); err != nil {
+				return nil, err
+			}
+		}
+	case *SliceType:
+		if err := checkElements(lit, u.ElementType, "slice element", env, ctx); err != nil {
+			return nil, err
+		}
+	case *ArrayType:
+		if err := checkElements(lit, u.ElementType, "array element", env, ctx); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid composite literal type %s", FormatGo(t))
+	}
+	return t, nil
+}
+
+// checkStructLit checks the fields of the struct literal lit, either all keyed by their
+// names, or all given in the order of their declaration.
+func checkStructLit(lit *ast.CompositeLit, st *StructType, env TypeEnv, ctx *InferenceContext) error {
+	if len(lit.Elts) == 0 {
+		return nil
+	}
+	if _, keyed := lit.Elts[0].(*ast.KeyValueExpr); keyed {
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return fmt.Errorf("mixture of field:value and value elements in struct literal")
+			}
+			key, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("invalid field name %s in struct literal", types.ExprString(kv.Key))
+			}
+			ft, ok := st.Fields[key.Name]
+			if !ok {
+				return fmt.Errorf("unknown field %s in struct literal of type %s", key.Name, FormatGo(st))
+			}
+			if err := checkElement(kv.Value, ft, "field "+key.Name, env, ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(lit.Elts) != len(st.FieldOrder) {
+		return fmt.Errorf("too few or too many values in struct literal of type %s", FormatGo(st))
+	}
+	for i, elt := range lit.Elts {
+		if _, ok := elt.(*ast.KeyValueExpr); ok {
+			return fmt.Errorf("mixture of field:value and value elements in struct literal")
+		}
+		name := st.FieldOrder[i]
+		if err := checkElement(elt, st.Fields[name], "field "+name, env, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkElements checks the elements of the slice or array literal lit, which may be keyed
+// by their index, like `[]string{2: "c"}`.
+func checkElements(lit *ast.CompositeLit, et Type, what string, env TypeEnv, ctx *InferenceContext) error {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if _, err := constantIndex(kv.Key, env); err != nil {
+				return err
+			}
+			elt = kv.Value
+		}
+		if err := checkElement(elt, et, what, env, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkElement checks that the element value of a composite literal, what, like "map key",
+// can be assigned to its type t.
+func checkElement(value ast.Expr, t Type, what string, env TypeEnv, ctx *InferenceContext) error {
+	if err := checkValueExpr(value, env); err != nil {
+		return err
+	}
+	vt, err := InferType(value, env, ctx.Child(WithExpectedType(t)))
+	if err != nil {
+		return err
+	}
+	if val := constantOf(value, env); val.Kind() != constant.Unknown && representable(val, t, env) {
+		return nil
+	}
+	if err := assignable(t, vt, env, ctx); err != nil {
+		return fmt.Errorf("cannot use %s (type %s) as %s in %s: %w", types.ExprString(value), FormatGo(vt), FormatGo(t), what, err)
+	}
+	return nil
+}
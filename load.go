@@ -0,0 +1,129 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrCgoFile is reported for the files of a package that import "C", which cannot be
+// checked without running cgo. They are skipped, like the files excluded by build constraints.
+var ErrCgoFile = errors.New("cgo file skipped")
+
+// ParsePackageDir parses the files of the package in dir, except the tests, that are
+// selected by their build constraints for opts.GOOS, opts.GOARCH and opts.BuildTags,
+// like `//go:build linux` or the name `file_windows.go`.
+//
+// A file that cannot be parsed, or imports "C", is left out with a diagnostic rather than
+// failing the whole package; the files are returned in the order of their names, along with
+// the diagnostics, which can be split with opts.Report. Skipped cgo files are warnings.
+func ParsePackageDir(fset *token.FileSet, dir string, opts Options) ([]*ast.File, []error) {
+	files, _, diags := parseDir(fset, dir, opts, false)
+	return files, diags
+}
+
+// ParseTestPackageDir is like ParsePackageDir, but also parses the tests: the files of
+// the package include its _test.go files, and the files of the external test package,
+// like `package foo_test`, are returned as xtests. See BuildTestEnv.
+func ParseTestPackageDir(fset *token.FileSet, dir string, opts Options) (files, xtests []*ast.File, diags []error) {
+	return parseDir(fset, dir, opts, true)
+}
+
+func parseDir(fset *token.FileSet, dir string, opts Options, tests bool) (files, xtests []*ast.File, diags []error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+	ctxt := opts.buildContext()
+
+	var names, testNames []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			if tests {
+				testNames = append(testNames, name)
+			}
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(testNames)
+
+	// the tests last, so that the package name is that of the package rather than that
+	// of its external tests
+	pkgName := ""
+	for i, name := range append(names, testNames...) {
+		path := filepath.Join(dir, name)
+		match, err := ctxt.MatchFile(dir, name)
+		if err != nil {
+			diags = append(diags, err)
+			continue
+		}
+		if !match {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			diags = append(diags, err)
+			continue
+		}
+		if importsC(file) {
+			diags = append(diags, fmt.Errorf("%s: %w", path, ErrCgoFile))
+			continue
+		}
+		isTest := i >= len(names)
+		switch {
+		case isTest && strings.HasSuffix(file.Name.Name, "_test") &&
+			(pkgName == "" || file.Name.Name == pkgName+"_test"):
+			if pkgName == "" {
+				pkgName = strings.TrimSuffix(file.Name.Name, "_test")
+			}
+			xtests = append(xtests, file)
+		case pkgName == "":
+			pkgName = file.Name.Name
+			files = append(files, file)
+		case file.Name.Name != pkgName:
+			diags = append(diags, fmt.Errorf("%s: found package %s, want %s", path, file.Name.Name, pkgName))
+		default:
+			files = append(files, file)
+		}
+	}
+	return files, xtests, diags
+}
+
+// buildContext returns the build context selecting files for o, which defaults to the
+// host's, like the go command. Cgo is disabled, since cgo files are skipped anyway.
+func (o Options) buildContext() build.Context {
+	ctxt := build.Default
+	if o.GOOS != "" {
+		ctxt.GOOS = o.GOOS
+	}
+	if o.GOARCH != "" {
+		ctxt.GOARCH = o.GOARCH
+	}
+	ctxt.BuildTags = o.BuildTags
+	ctxt.CgoEnabled = false
+	return ctxt
+}
+
+// importsC reports whether file uses cgo.
+func importsC(file *ast.File) bool {
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == "C" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePackageDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"a.go":           "package p\n\nvar A = 1\n",
+		"a_test.go":      "package p\n\nvar T = 1\n",
+		"b_windows.go":   "package p\n\nvar B = 1\n",
+		"c_linux_arm.go": "package p\n\nvar C = 1\n",
+		"ignored.go":     "//go:build ignore\n\npackage main\n",
+		"tagged.go":      "//go:build integration\n\npackage p\n\nvar Tagged = 1\n",
+		"cgo.go":         "package p\n\n// #include <stdio.h>\nimport \"C\"\n",
+		"broken.go":      "package p\n\nvar = 1\n",
+		"other.go":       "package q\n",
+		"README.md":      "not go\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want string // the files parsed
+	}{
+		{name: "linux", opts: Options{GOOS: "linux", GOARCH: "amd64"}, want: "a.go"},
+		{name: "windows", opts: Options{GOOS: "windows", GOARCH: "amd64"}, want: "a.go b_windows.go"},
+		{name: "linux arm", opts: Options{GOOS: "linux", GOARCH: "arm"}, want: "a.go c_linux_arm.go"},
+		{name: "build tags", opts: Options{GOOS: "linux", GOARCH: "amd64", BuildTags: []string{"integration"}}, want: "a.go tagged.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			files, diags := ParsePackageDir(fset, dir, tt.opts)
+			var names []string
+			for _, file := range files {
+				names = append(names, filepath.Base(fset.File(file.Pos()).Name()))
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("ParsePackageDir() files = %s, want %s", got, tt.want)
+			}
+
+			errs, warns := tt.opts.Report(diags)
+			if len(warns) != 1 || !errors.Is(warns[0], ErrCgoFile) || !strings.Contains(warns[0].Error(), "cgo.go") {
+				t.Errorf("ParsePackageDir() warnings = %v, want cgo.go skipped", warns)
+			}
+			var got []string
+			for _, err := range errs {
+				got = append(got, filepath.Base(strings.SplitN(err.Error(), ":", 2)[0]))
+			}
+			if strings.Join(got, " ") != "broken.go other.go" {
+				t.Errorf("ParsePackageDir() errors = %v, want broken.go and other.go", errs)
+			}
+		})
+	}
+
+	if _, diags := ParsePackageDir(token.NewFileSet(), filepath.Join(dir, "missing"), Options{}); len(diags) != 1 {
+		t.Errorf("ParsePackageDir(missing) = %v, want an error", diags)
+	}
+}
+
+func TestParseTestPackageDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"a.go":         "package p\n\nvar A = 1\n",
+		"a_test.go":    "package p\n\nvar T = A\n",
+		"x_test.go":    "package p_test\n\nimport \"example.com/p\"\n\nvar X = p.A\n",
+		"b_windows.go": "package p\n\nvar B = 1\n",
+		"c_test.go":    "package q_test\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fset := token.NewFileSet()
+	files, xtests, diags := ParseTestPackageDir(fset, dir, Options{GOOS: "linux", GOARCH: "amd64"})
+	base := func(files []*ast.File) string {
+		var names []string
+		for _, file := range files {
+			names = append(names, filepath.Base(fset.File(file.Pos()).Name()))
+		}
+		return strings.Join(names, " ")
+	}
+	if got := base(files); got != "a.go a_test.go" {
+		t.Errorf("ParseTestPackageDir() files = %s, want a.go a_test.go", got)
+	}
+	if got := base(xtests); got != "x_test.go" {
+		t.Errorf("ParseTestPackageDir() xtests = %s, want x_test.go", got)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Error(), "c_test.go: found package q_test, want p") {
+		t.Errorf("ParseTestPackageDir() diagnostics = %v, want c_test.go mismatched", diags)
+	}
+
+	// the tests are left out by ParsePackageDir
+	if files, _ := ParsePackageDir(fset, dir, Options{GOOS: "linux", GOARCH: "amd64"}); base(files) != "a.go" {
+		t.Errorf("ParsePackageDir() files = %s, want a.go", base(files))
+	}
+}
@@ -0,0 +1,98 @@
+package generic
+
+// predeclaredIndex numbers the predeclared types, so that a set of them fits in a bitset.
+var predeclaredIndex = func() map[string]uint {
+	index := make(map[string]uint, len(predeclared))
+	for _, name := range sortedKeys(predeclared) {
+		index[name] = uint(len(index))
+	}
+	return index
+}()
+
+// constraintMatcher is a type constraint compiled for the repeated checks of instantiation.
+//
+// Whether each predeclared type satisfies the constraint is decided once, so checking them,
+// the most common type arguments, is a bit test rather than a walk over the interfaces and
+// the type list of the constraint. Other types are checked against the constraint itself.
+type constraintMatcher struct {
+	source   *TypeConstraint // the constraint the matcher was compiled from
+	snapshot TypeConstraint  // a copy of *source as it was compiled, see upToDate
+	basic    uint64          // the predeclared types satisfying the constraint, by predeclaredIndex
+}
+
+// compileConstraint compiles tc into a matcher.
+func compileConstraint(tc *TypeConstraint) *constraintMatcher {
+	snapshot := *tc
+	snapshot.Interfaces = append([]Interface(nil), tc.Interfaces...)
+	snapshot.Types = append([]Type(nil), tc.Types...)
+	snapshot.Excluded = append([]Type(nil), tc.Excluded...)
+	m := &constraintMatcher{source: tc, snapshot: snapshot}
+	for name, i := range predeclaredIndex {
+		if checkConstraint(predeclared[name], *tc) {
+			m.basic |= 1 << i
+		}
+	}
+	return m
+}
+
+// upToDate reports whether c is the constraint the matcher was compiled from, as it was
+// compiled, so that a constraint modified in place is not checked with a stale matcher.
+// Its flags and the elements of its lists are compared, the terms by identity since types
+// are not modified once built. Of its interfaces, only the names are compared: the
+// predeclared types have no methods, so they only depend on the names, see implInterface.
+func (m *constraintMatcher) upToDate(c *TypeConstraint) bool {
+	s := &m.snapshot
+	if c != m.source || c.Union != s.Union || c.IsComparable != s.IsComparable || c.IsUnderlying != s.IsUnderlying ||
+		c.BuiltinConstraint != s.BuiltinConstraint || len(c.Interfaces) != len(s.Interfaces) ||
+		!sameTerms(c.Types, s.Types) || !sameTerms(c.Excluded, s.Excluded) {
+		return false
+	}
+	for i, iface := range c.Interfaces {
+		if iface.Name != s.Interfaces[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTerms reports whether the lists of types a and b hold the same types.
+func sameTerms(a, b []Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether t satisfies the constraint, like checkConstraint.
+func (m *constraintMatcher) match(t Type) bool {
+	if c, ok := t.(*TypeConstant); ok {
+		if i, ok := predeclaredIndex[c.Name]; ok {
+			return m.basic&(1<<i) != 0
+		}
+	}
+	return checkConstraint(t, *m.source)
+}
+
+// compileConstraints compiles the constraints of the declared type parameters of gt.
+func (gt *GenericType) compileConstraints() {
+	gt.matchers = make([]*constraintMatcher, len(gt.Params))
+	for i, p := range gt.Params {
+		if p.Constraint != nil {
+			gt.matchers[i] = compileConstraint(p.Constraint)
+		}
+	}
+}
+
+// satisfies reports whether t satisfies the constraint c of the i-th type parameter of gt,
+// using the matcher compiled when gt was declared if it is still up to date.
+func (gt *GenericType) satisfies(i int, c *TypeConstraint, t Type) bool {
+	if i < len(gt.matchers) && gt.matchers[i] != nil && gt.matchers[i].upToDate(c) {
+		return gt.matchers[i].match(t)
+	}
+	return checkConstraint(t, *c)
+}
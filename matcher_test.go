@@ -0,0 +1,66 @@
+package generic
+
+import "testing"
+
+func TestConstraintMatcher(t *testing.T) {
+	age := &NamedType{Name: "Age", Underlying: Int}
+	stringer := Interface{Name: "Stringer", Methods: MethodSet{"String": Method{Name: "String", Results: []Type{String}}}}
+	point := &StructType{Name: "Point", Fields: map[string]Type{"X": Int}}
+
+	constraints := map[string]*TypeConstraint{
+		"any":        {BuiltinConstraint: ConstraintAny},
+		"comparable": {BuiltinConstraint: ConstraintComparable},
+		"ordered":    {BuiltinConstraint: ConstraintOrdered},
+		"integer":    {BuiltinConstraint: ConstraintInteger},
+		"union":      {Types: []Type{Int, String}, Union: true},
+		"approx":     {Types: []Type{&ApproxType{Base: Int}, &ApproxType{Base: Float64}}, Union: true},
+		"stringer":   {Interfaces: []Interface{stringer}},
+		"except":     {BuiltinConstraint: ConstraintComparable, Excluded: []Type{String}},
+	}
+	candidates := append([]Type{age, point, &PointerType{Base: Int}, &SliceType{ElementType: Int}, &TypeVariable{Name: "T"}, Byte, Rune, Error}, predeclaredTypes...)
+
+	for name, tc := range constraints {
+		t.Run(name, func(t *testing.T) {
+			m := compileConstraint(tc)
+			for _, c := range candidates {
+				if got, want := m.match(c), checkConstraint(c, *tc); got != want {
+					t.Errorf("match(%v) = %v, want %v", c, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGenericTypeSatisfies(t *testing.T) {
+	gt := NewGenericType("Set", TypeParamList{{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintInteger}}}, nil, nil)
+	if !gt.satisfies(0, gt.Params[0].Constraint, Int) {
+		t.Errorf("satisfies(int) = false, want true")
+	}
+	if gt.satisfies(0, gt.Params[0].Constraint, String) {
+		t.Errorf("satisfies(string) = true, want false")
+	}
+
+	// a replaced constraint is checked directly rather than with the stale matcher
+	gt.Params = TypeParamList{{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintAny}}}
+	if !gt.satisfies(0, gt.Params[0].Constraint, String) {
+		t.Errorf("satisfies(string) after replacing the constraint = false, want true")
+	}
+
+	// so is a constraint modified in place
+	tc := gt.Params[0].Constraint
+	gt.compileConstraints()
+	for _, modify := range []func(){
+		func() { tc.BuiltinConstraint = ConstraintInteger },
+		func() { tc.Excluded = []Type{Int} },
+		func() { tc.Excluded[0] = String },
+		func() { tc.Types = append(tc.Types, Float64) },
+		func() { tc.Interfaces = append(tc.Interfaces, Interface{Name: "Stringer"}) },
+	} {
+		modify()
+		for _, arg := range []Type{Int, String, Float64} {
+			if got, want := gt.satisfies(0, tc, arg), checkConstraint(arg, *tc); got != want {
+				t.Errorf("satisfies(%v) after modifying the constraint to %v = %v, want %v", arg, tc, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package generic
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// EmitMock returns the Go source of a mock implementing iface, named after it like `MockReader`.
+// For each method M, the mock has
+//
+//   - a field MFunc, which the method calls if set, so tests can configure what it returns;
+//   - a field MCalls, recording the arguments of every call, in order.
+//
+// Without MFunc, a method returns the zero values of its results. The methods of
+// embedded interfaces are mocked too. The source is formatted with gofmt.
+func EmitMock(iface InterfaceType) string {
+	name := "Mock" + iface.Name
+	methods := interfaceMethods(&iface)
+
+	var fields, funcs strings.Builder
+	for _, mname := range sortedKeys(methods) {
+		m := methods[mname]
+		var params, args, record, callArgs []string
+		for i, p := range m.Params {
+			arg := fmt.Sprintf("p%d", i)
+			param, callArg := arg+" "+FormatGo(p), arg
+			if slice, ok := p.(*SliceType); ok && m.IsVariadic && i == len(m.Params)-1 {
+				param, callArg = arg+" ..."+FormatGo(slice.ElementType), arg+"..."
+			}
+			params = append(params, param)
+			args = append(args, arg)
+			callArgs = append(callArgs, callArg)
+			record = append(record, fmt.Sprintf("P%d %s", i, FormatGo(p)))
+		}
+		callType := "struct{}"
+		if len(record) > 0 {
+			callType = "struct{ " + strings.Join(record, "; ") + " }"
+		}
+		results := formatResults(m.Results)
+
+		fmt.Fprintf(&fields, "\t%sFunc %s\n", mname, "func"+formatSignature(m.Params, m.Results, m.IsVariadic))
+		fmt.Fprintf(&fields, "\t%sCalls []%s\n", mname, callType)
+
+		fmt.Fprintf(&funcs, "\nfunc (m *%s) %s(%s)%s {\n", name, mname, strings.Join(params, ", "), results)
+		fmt.Fprintf(&funcs, "\tm.%sCalls = append(m.%sCalls, %s{%s})\n", mname, mname, callType, strings.Join(args, ", "))
+		call := fmt.Sprintf("m.%sFunc(%s)", mname, strings.Join(callArgs, ", "))
+		if len(m.Results) == 0 {
+			fmt.Fprintf(&funcs, "\tif m.%sFunc != nil {\n\t\t%s\n\t}\n}\n", mname, call)
+			continue
+		}
+		zeros := make([]string, len(m.Results))
+		for i, r := range m.Results {
+			zeros[i] = GenerateZeroValue(r)
+		}
+		fmt.Fprintf(&funcs, "\tif m.%sFunc != nil {\n\t\treturn %s\n\t}\n\treturn %s\n}\n", mname, call, strings.Join(zeros, ", "))
+	}
+
+	src := fmt.Sprintf("// %s is a mock of %s.\ntype %s struct {\n%s}\n%s", name, iface.Name, name, fields.String(), funcs.String())
+	if formatted, err := format.Source([]byte(src)); err == nil {
+		return string(formatted)
+	}
+	return src
+}
+
+// interfaceMethods returns the methods of iface, including those of its embedded interfaces.
+func interfaceMethods(iface *InterfaceType) MethodSet {
+	methods := make(MethodSet, len(iface.Methods))
+	for _, embedded := range iface.Embedded {
+		var inner MethodSet
+		switch e := underlying(embedded).(type) {
+		case *InterfaceType:
+			inner = interfaceMethods(e)
+		case *Interface:
+			inner = e.Methods
+		}
+		for name, m := range inner {
+			methods[name] = m
+		}
+	}
+	for name, m := range iface.Methods {
+		methods[name] = m
+	}
+	return methods
+}
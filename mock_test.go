@@ -0,0 +1,62 @@
+package generic
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestEmitMock(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	errorType := &TypeConstant{Name: "error"}
+	closer := &InterfaceType{Name: "Closer", Methods: MethodSet{"Close": {Name: "Close", Results: []Type{errorType}}}}
+	store := InterfaceType{
+		Name: "Store",
+		Methods: MethodSet{
+			"Get": {Name: "Get", Params: []Type{&TypeConstant{Name: "string"}}, Results: []Type{intType, errorType}},
+			"Put": {Name: "Put", Params: []Type{&SliceType{ElementType: intType}}, IsVariadic: true},
+		},
+		Embedded: []Type{closer},
+	}
+
+	want := `// MockStore is a mock of Store.
+type MockStore struct {
+	CloseFunc  func() error
+	CloseCalls []struct{}
+	GetFunc    func(string) (int, error)
+	GetCalls   []struct{ P0 string }
+	PutFunc    func(...int)
+	PutCalls   []struct{ P0 []int }
+}
+
+func (m *MockStore) Close() error {
+	m.CloseCalls = append(m.CloseCalls, struct{}{})
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	return nil
+}
+
+func (m *MockStore) Get(p0 string) (int, error) {
+	m.GetCalls = append(m.GetCalls, struct{ P0 string }{p0})
+	if m.GetFunc != nil {
+		return m.GetFunc(p0)
+	}
+	return 0, nil
+}
+
+func (m *MockStore) Put(p0 ...int) {
+	m.PutCalls = append(m.PutCalls, struct{ P0 []int }{p0})
+	if m.PutFunc != nil {
+		m.PutFunc(p0...)
+	}
+}
+`
+	got := EmitMock(store)
+	if got != want {
+		t.Errorf("EmitMock() =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "mock.go", "package p\n"+got, 0); err != nil {
+		t.Errorf("EmitMock() is not valid Go: %v", err)
+	}
+}
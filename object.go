@@ -0,0 +1,282 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+var (
+	ErrNotAnExpression = gerrors.ErrNotAnExpression
+	ErrNotAType        = gerrors.ErrNotAType
+)
+
+// Object is what a name of the environment denotes: a variable, a constant, a type,
+// a function or an imported package. The type of an entity alone does not tell them
+// apart, like the variable `x` of type Point and the type Point itself, so declarations
+// bind their names to objects, which the checker unwraps to the type wherever the
+// name is used as a value.
+//
+// Objects are Types so that they can be bound in a TypeEnv along with bare types.
+// LookupObject classifies both.
+type Object interface {
+	Type
+	objectType() Type
+}
+
+// VarObj is a variable, like `x` in `var x int`.
+type VarObj struct {
+	Name string
+	Type Type
+}
+
+// ConstObj is a constant, like `k` in `const k = 10`. An untyped constant has the
+// default type of its kind, and its value is converted to the type it is used with,
+// like a literal: `var f float64 = k`.
+type ConstObj struct {
+	Name    string
+	Type    Type
+	Val     constant.Value // unknown if not computed, like `const n = len(s)`
+	Untyped bool
+}
+
+// TypeObj is a declared type, like Point in `type Point struct{ X, Y int }`.
+type TypeObj struct {
+	Name string
+	Type Type
+}
+
+// FuncObj is a declared function, like `Max` in `func Max[T Ordered](a, b T) T`.
+type FuncObj struct {
+	Name string
+	Type Type // a FunctionType, or a GenericType with a Signature
+}
+
+// PackageType is the object of an imported package: the package is its own type.
+var _ Object = (*PackageType)(nil)
+
+func (o *VarObj) objectType() Type       { return o.Type }
+func (o *ConstObj) objectType() Type     { return o.Type }
+func (o *TypeObj) objectType() Type      { return o.Type }
+func (o *FuncObj) objectType() Type      { return o.Type }
+func (pt *PackageType) objectType() Type { return pt }
+
+func (o *VarObj) String() string {
+	if o == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Var(%s %s)", o.Name, typeString(o.Type))
+}
+
+func (o *ConstObj) String() string {
+	if o == nil {
+		return nilTypeString
+	}
+	if o.Val == nil || o.Val.Kind() == constant.Unknown {
+		return fmt.Sprintf("Const(%s %s)", o.Name, typeString(o.Type))
+	}
+	return fmt.Sprintf("Const(%s %s = %s)", o.Name, typeString(o.Type), o.Val.ExactString())
+}
+
+func (o *TypeObj) String() string {
+	if o == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("TypeName(%s = %s)", o.Name, typeString(o.Type))
+}
+
+func (o *FuncObj) String() string {
+	if o == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Func(%s %s)", o.Name, typeString(o.Type))
+}
+
+// LookupObject returns the object name denotes in env. Names bound to bare types are
+// classified by convention: a type bound to its own name, like `"Point": Point`, or a
+// generic type is a type, a signature or generic function is a function, and anything
+// else is a variable. Constraint interfaces have no name of their own, so BuildEnv binds
+// them to their TypeObj.
+func LookupObject(env TypeEnv, name string) (Object, bool) {
+	t, ok := env[name]
+	if !ok {
+		return nil, false
+	}
+	if obj, ok := t.(Object); ok {
+		return obj, true
+	}
+	switch t := t.(type) {
+	case *FunctionType:
+		return &FuncObj{Name: name, Type: t}, true
+	case *GenericType:
+		if t.Signature != nil {
+			return &FuncObj{Name: name, Type: t}, true
+		}
+		return &TypeObj{Name: name, Type: t}, true
+	}
+	if isTypeName(name, t) {
+		return &TypeObj{Name: name, Type: t}, true
+	}
+	return &VarObj{Name: name, Type: t}, true
+}
+
+// isTypeName reports whether t is the type declared as name, rather than the type of a value.
+func isTypeName(name string, t Type) bool {
+	switch t := t.(type) {
+	case *TypeConstant:
+		return t.Name == name
+	case *NamedType:
+		return t.Name == name
+	case *StructType:
+		return t.Name == name
+	case *InterfaceType:
+		return t.Name == name
+	case *TypeAlias:
+		return t.Name == name
+	case *TypeVariable:
+		// a type parameter in scope, see typeParamScope
+		return t.Name == name
+	}
+	return false
+}
+
+// unwrapObject returns the type of t if it is an object, and t otherwise.
+func unwrapObject(t Type) Type {
+	if obj, ok := t.(Object); ok {
+		return obj.objectType()
+	}
+	return t
+}
+
+// checkValueExpr checks that expr denotes a value, rather than a type or a package,
+// where a value is required, like the operands, arguments and initializers.
+func checkValueExpr(expr ast.Expr, env TypeEnv) error {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		obj, ok := LookupObject(env, e.Name)
+		if !ok {
+			if isPredeclaredType(e.Name) {
+				return fmt.Errorf("%s (type) %w", e.Name, ErrNotAnExpression)
+			}
+			return nil
+		}
+		switch obj := obj.(type) {
+		case *TypeObj:
+			return fmt.Errorf("%s (type) %w", e.Name, ErrNotAnExpression)
+		case *PackageType:
+			return fmt.Errorf("use of package %s without selector", obj.Name)
+		}
+	case *ast.SelectorExpr:
+		pkg, ok := packageOf(e.X, env)
+		if !ok {
+			return nil
+		}
+		if obj, ok := LookupObject(pkg.Members, e.Sel.Name); ok {
+			if _, ok := obj.(*TypeObj); ok {
+				return fmt.Errorf("%s (type) %w", types.ExprString(e), ErrNotAnExpression)
+			}
+		}
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+		return fmt.Errorf("%s (type) %w", types.ExprString(e), ErrNotAnExpression)
+	}
+	return nil
+}
+
+// denotesValue reports whether expr is known to denote a value rather than a type, like
+// the operand of `*p`, which is an indirection if p is a pointer value, and a pointer
+// type otherwise.
+func denotesValue(expr ast.Expr, env TypeEnv) bool {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		obj, ok := LookupObject(env, e.Name)
+		if !ok {
+			return false
+		}
+		switch obj.(type) {
+		case *VarObj, *ConstObj, *FuncObj:
+			return true
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := packageOf(e.X, env); ok {
+			obj, ok := LookupObject(pkg.Members, e.Sel.Name)
+			_, isType := obj.(*TypeObj)
+			return ok && !isType
+		}
+		return denotesValue(e.X, env) // a field or method
+	case *ast.IndexExpr:
+		return denotesValue(e.X, env)
+	case *ast.StarExpr:
+		return denotesValue(e.X, env)
+	case *ast.BasicLit, *ast.CompositeLit, *ast.FuncLit, *ast.CallExpr, *ast.UnaryExpr, *ast.BinaryExpr,
+		*ast.SliceExpr, *ast.TypeAssertExpr:
+		return true
+	}
+	return false
+}
+
+// checkTypeExpr checks that expr, and the element types it is made of, denote types
+// rather than values, where a type is required, like the type of a declaration or a field.
+func checkTypeExpr(expr ast.Expr, env TypeEnv) error {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		// only the declared objects are known to be values: bare types may be
+		// bound to other names, like type parameters
+		var desc string
+		switch obj := env[e.Name].(type) {
+		case *VarObj:
+			desc = "variable of type " + FormatGo(obj.Type)
+		case *ConstObj:
+			desc = describeConst(obj)
+		case *FunctionType, *FuncObj:
+			desc = "value of type " + FormatGo(obj)
+		case *GenericType:
+			if obj.Signature == nil {
+				return nil
+			}
+			desc = "value of type " + FormatGo(obj)
+		default:
+			return nil
+		}
+		return fmt.Errorf("%s (%s) %w", e.Name, desc, ErrNotAType)
+	case *ast.SelectorExpr:
+		pkg, ok := packageOf(e.X, env)
+		if !ok {
+			return nil
+		}
+		if obj, ok := LookupObject(pkg.Members, e.Sel.Name); ok {
+			if _, ok := obj.(*TypeObj); !ok {
+				return fmt.Errorf("%s (value of type %s) %w", types.ExprString(e), FormatGo(obj), ErrNotAType)
+			}
+		}
+	case *ast.ParenExpr:
+		return checkTypeExpr(e.X, env)
+	case *ast.StarExpr:
+		return checkTypeExpr(e.X, env)
+	case *ast.Ellipsis:
+		return checkTypeExpr(e.Elt, env)
+	case *ast.ArrayType:
+		return checkTypeExpr(e.Elt, env)
+	case *ast.ChanType:
+		return checkTypeExpr(e.Value, env)
+	case *ast.MapType:
+		if err := checkTypeExpr(e.Key, env); err != nil {
+			return err
+		}
+		return checkTypeExpr(e.Value, env)
+	}
+	return nil
+}
+
+// describeConst describes a constant like go/types, like "untyped int constant 10".
+func describeConst(c *ConstObj) string {
+	if c.Val == nil || c.Val.Kind() == constant.Unknown {
+		return "constant of type " + FormatGo(c.Type)
+	}
+	if c.Untyped {
+		return fmt.Sprintf("%s constant %s", (&operand{typ: c.Type, val: c.Val}).kind(), c.Val.ExactString())
+	}
+	return fmt.Sprintf("constant %s of type %s", c.Val.ExactString(), FormatGo(c.Type))
+}
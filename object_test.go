@@ -0,0 +1,202 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestLookupObject(t *testing.T) {
+	const src = `package p
+
+type Point struct{ X, Y int }
+
+type Number interface{ ~int | ~float64 }
+
+func Max[T Number](a, b T) T
+
+func double(x int) int
+
+var origin Point
+
+const (
+	limit       = 10
+	scale int64 = 2
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	env["strings"] = NewPackageType("strings", TypeEnv{})
+
+	tests := []struct {
+		name string
+		want string // the kind of object
+		typ  string
+	}{
+		{"Point", "*generic.TypeObj", "Point"},
+		{"Number", "*generic.TypeObj", "~int | ~float64"},
+		{"int", "*generic.TypeObj", "int"},
+		{"any", "*generic.TypeObj", "any"},
+		{"Max", "*generic.FuncObj", "Max[T]"},
+		{"double", "*generic.FuncObj", "func(int) int"},
+		{"origin", "*generic.VarObj", "Point"},
+		{"limit", "*generic.ConstObj", "int"},
+		{"scale", "*generic.ConstObj", "int64"},
+		{"true", "*generic.ConstObj", "bool"},
+		{"strings", "*generic.PackageType", "package strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, ok := LookupObject(env, tt.name)
+			if !ok {
+				t.Fatalf("LookupObject(%s) not found", tt.name)
+			}
+			if got := fmt.Sprintf("%T", obj); got != tt.want {
+				t.Errorf("LookupObject(%s) = %s, want %s", tt.name, got, tt.want)
+			}
+			if got := FormatGo(obj); got != tt.typ {
+				t.Errorf("FormatGo(%s) = %s, want %s", tt.name, got, tt.typ)
+			}
+		})
+	}
+
+	if _, ok := LookupObject(env, "missing"); ok {
+		t.Error("LookupObject(missing) found")
+	}
+	limit := env["limit"].(*ConstObj)
+	if !limit.Untyped || limit.Val.String() != "10" {
+		t.Errorf("limit = %v, want untyped constant 10", limit)
+	}
+	if scale := env["scale"].(*ConstObj); scale.Untyped {
+		t.Errorf("scale = %v, want typed constant", scale)
+	}
+}
+
+func TestUntypedConstantObjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{name: "converted to float", src: "const k = 10\nvar x float64 = k", want: "float64"},
+		{name: "constant expression", src: "const k = 10\nvar x float32 = k * 2", want: "float32"},
+		{name: "operand of a typed value", src: "const k = 2\nvar f float64 = 1.5\nvar x = f * k", want: "float64"},
+		{name: "default type", src: "const k = 10\nvar x = k", want: "int"},
+		{name: "repeated with iota", src: "const (\n\ta = iota * 1.5\n\tb\n)\nvar x float32 = b", want: "float32"},
+		{name: "typed constant", src: "const k int = 10\nvar x float64 = k", wantErr: "declaration of x: cannot use k (value of type int) as float64 value: type mismatch"},
+		{name: "not representable", src: "const k = 1.5\nvar x int = k", wantErr: "declaration of x: cannot convert k (untyped float constant) to type int"},
+		{name: "variable", src: "var k = 10\nvar x float64 = k", wantErr: "declaration of x: cannot use k (value of type int) as float64 value: type mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+tt.src+"\n}", 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			env := universe()
+			for _, stmt := range file.Decls[0].(*ast.FuncDecl).Body.List {
+				if _, err = InferType(stmt, env, nil); err != nil {
+					break
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if got := FormatGo(env["x"]); got != tt.want {
+				t.Errorf("x = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueAndTypeExprs(t *testing.T) {
+	const src = `package p
+
+type Point struct{ X, Y int }
+
+func double(x int) int
+
+var origin Point
+
+const limit = 10
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	pkgEnv, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	pkgEnv["strings"] = NewPackageType("strings", TypeEnv{
+		"Builder": &StructType{Name: "Builder"},
+		"Count":   &FunctionType{ParamTypes: []Type{String, String}, ReturnType: Int},
+	})
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{name: "type as initializer", src: "var x = Point", wantErr: "declaration of x: Point (type) is not an expression"},
+		{name: "predeclared type as value", src: "x := int", wantErr: "int (type) is not an expression"},
+		{name: "type as operand", src: "x := 1 + Point", wantErr: "Point (type) is not an expression"},
+		{name: "type as argument", src: "x := double(int)", wantErr: "int (type) is not an expression"},
+		{name: "type literal as value", src: "x := []int", wantErr: "[]int (type) is not an expression"},
+		{name: "qualified type as value", src: "x := strings.Builder", wantErr: "strings.Builder (type) is not an expression"},
+		{name: "package as value", src: "x := strings", wantErr: "use of package strings without selector"},
+		{name: "variable as type", src: "var p origin", wantErr: "declaration of p: origin (variable of type Point) is not a type"},
+		{name: "constant as type", src: "var p []limit", wantErr: "declaration of p: limit (untyped int constant 10) is not a type"},
+		{name: "function as type", src: "var p map[string]double", wantErr: "declaration of p: double (value of type func(int) int) is not a type"},
+		{name: "qualified function as type", src: "var p strings.Count", wantErr: "declaration of p: strings.Count (value of type func(string, string) int) is not a type"},
+		{name: "variable as field type", src: "type S struct{ f origin }", wantErr: "type S: field origin: origin (variable of type Point) is not a type"},
+		{name: "local variable as type", src: "n := 1\nvar p n", wantErr: "declaration of p: n (variable of type int) is not a type"},
+
+		{name: "value", src: "x := double(limit) + origin.X"},
+		{name: "conversion", src: "x := float64(limit)"},
+		{name: "types", src: "var p []*Point\nvar b strings.Builder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+tt.src+"\n}", 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			env := make(TypeEnv, len(pkgEnv))
+			for name, t := range pkgEnv {
+				env[name] = t
+			}
+			for _, stmt := range file.Decls[0].(*ast.FuncDecl).Body.List {
+				if _, err = InferType(stmt, env, nil); err != nil {
+					break
+				}
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("InferType() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+)
+
+var ErrTooManyErrors = errors.New("too many errors")
+
+// warnings are the diagnostics that do not make code invalid, like the suggestions of
+// `go vet` style checks. They are only reported as errors with Options.WError.
+var warnings = []error{ErrStringIntConversion, ErrFieldOrder, ErrCgoFile, ErrUnreachableCode}
+
+// IsWarning reports whether the diagnostic err is a warning rather than an error.
+func IsWarning(err error) bool {
+	for _, w := range warnings {
+		if errors.Is(err, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Options tune how diagnostics are reported, so that CI can choose what fails a check.
+type Options struct {
+	// MaxErrors stops the check after that many errors, reporting ErrTooManyErrors.
+	// Zero means no limit.
+	MaxErrors int
+
+	// WError reports warnings as errors, counting towards MaxErrors.
+	WError bool
+
+	// Parallel infers the files of a package concurrently. The results and diagnostics
+	// are the same as inferring the files in turn, and in the same order.
+	Parallel bool
+
+	// GOOS and GOARCH select the files of a package by their build constraints, see
+	// ParsePackageDir. Empty means the host's, like the go command.
+	GOOS, GOARCH string
+
+	// BuildTags are the additional build tags satisfied, like "integration".
+	BuildTags []string
+
+	// GoVersion is the language version the bodies of the functions are checked for,
+	// see InferenceContext.GoVersion. Empty means the latest.
+	GoVersion string
+
+	// Profile, if set, records the cost of inferring each top-level declaration and
+	// instantiation. The files are inferred in turn even with Parallel, so that the
+	// unifications are counted for the right declaration.
+	Profile *Profile
+
+	// Fset, if set, is the file set of the files checked, used to resolve the positions of
+	// the diagnostics, see TypeError.Position.
+	Fset *token.FileSet
+}
+
+// Report splits the diagnostics of a check into errors and warnings according to o.
+// The errors are cut off after MaxErrors, followed by ErrTooManyErrors.
+func (o Options) Report(diags []error) (errs, warns []error) {
+	r := o.reporter()
+	for _, d := range diags {
+		if !r.add(d) {
+			break
+		}
+	}
+	return r.errs, r.warns
+}
+
+func (o Options) reporter() *reporter {
+	return &reporter{opts: o}
+}
+
+// reporter collects diagnostics as they are found, so a check can stop once
+// MaxErrors is reached.
+type reporter struct {
+	opts  Options
+	errs  []error
+	warns []error
+}
+
+// add records the diagnostic err, reporting false once no more errors are accepted.
+func (r *reporter) add(err error) bool {
+	if r.full() {
+		return false
+	}
+	if followsInvalid(err) {
+		return true
+	}
+	if IsWarning(err) && !r.opts.WError {
+		r.warns = append(r.warns, err)
+		return true
+	}
+	r.errs = append(r.errs, err)
+	if r.full() {
+		r.errs = append(r.errs, fmt.Errorf("%w: stopped after %d", ErrTooManyErrors, r.opts.MaxErrors))
+		return false
+	}
+	return true
+}
+
+func (r *reporter) full() bool {
+	return r.opts.MaxErrors > 0 && len(r.errs) >= r.opts.MaxErrors
+}
@@ -0,0 +1,78 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestOptionsReport(t *testing.T) {
+	errA := errors.New("a")
+	errB := errors.New("b")
+	warn := fmt.Errorf("%w: S is 24 bytes", ErrFieldOrder)
+	diags := []error{errA, warn, errB}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantErrs  []error
+		wantWarns []error
+	}{
+		{name: "defaults", opts: Options{}, wantErrs: []error{errA, errB}, wantWarns: []error{warn}},
+		{name: "warnings as errors", opts: Options{WError: true}, wantErrs: []error{errA, warn, errB}},
+		{name: "max errors", opts: Options{MaxErrors: 1}, wantErrs: []error{errA, ErrTooManyErrors}},
+		{name: "warnings do not count", opts: Options{MaxErrors: 2}, wantErrs: []error{errA, errB, ErrTooManyErrors}, wantWarns: []error{warn}},
+		{name: "warnings as errors count", opts: Options{MaxErrors: 2, WError: true}, wantErrs: []error{errA, warn, ErrTooManyErrors}},
+		{name: "limit not reached", opts: Options{MaxErrors: 5}, wantErrs: []error{errA, errB}, wantWarns: []error{warn}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, warns := tt.opts.Report(diags)
+			if len(errs) != len(tt.wantErrs) || len(warns) != len(tt.wantWarns) {
+				t.Fatalf("Report() = %v, %v, want %v, %v", errs, warns, tt.wantErrs, tt.wantWarns)
+			}
+			for i, err := range errs {
+				if !errors.Is(err, tt.wantErrs[i]) {
+					t.Errorf("error %d = %v, want %v", i, err, tt.wantErrs[i])
+				}
+			}
+			for i, w := range warns {
+				if !errors.Is(w, tt.wantWarns[i]) {
+					t.Errorf("warning %d = %v, want %v", i, w, tt.wantWarns[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInferPackageWithOptions(t *testing.T) {
+	const src = `package p
+
+var a = Box[x]{}
+var b = Box[y]{}
+var c = Box[z]{}
+`
+	env := TypeEnv{"Box": NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"value": &TypeVariable{Name: "T"}}, nil)}
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	_, err = InferPackageWithOptions([]*ast.File{file}, env, Options{MaxErrors: 2})
+	if !errors.Is(err, ErrTooManyErrors) {
+		t.Fatalf("InferPackageWithOptions() error = %v, want %v", err, ErrTooManyErrors)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "unknown identifier: y") || strings.Contains(msg, "unknown identifier: z") {
+		t.Errorf("InferPackageWithOptions() error = %v, want the first 2 errors", err)
+	}
+
+	_, err = InferPackage([]*ast.File{file}, env)
+	if err == nil || !strings.Contains(err.Error(), "unknown identifier: z") {
+		t.Errorf("InferPackage() error = %v, want all errors", err)
+	}
+}
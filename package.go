@@ -0,0 +1,538 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+)
+
+// Info holds the results of inferring the files of a package, so that tools can
+// query them without walking the AST again. See InferPackage.
+type Info struct {
+	// Uses maps the identifiers that refer to an entry of the environment to that entry,
+	// like the `Pair` in `Pair[int, string]` to the Pair declaration.
+	Uses map[*ast.Ident]Type
+
+	// Instances maps the expressions instantiating a generic declaration, like
+	// `Pair[int, string]`, to the instance. Its Origin holds the type arguments.
+	Instances map[ast.Expr]*GenericType
+
+	// CallGraph holds the calls between the functions of the package.
+	CallGraph *CallGraph
+
+	// Warnings holds the diagnostics reported as warnings, see Options.
+	Warnings []error
+
+	profile  *Profile
+	typeArgs map[*ast.CallExpr][]Type // the type arguments of the calls in function bodies
+}
+
+// Instance is an instantiation of a generic declaration in the source.
+type Instance struct {
+	Expr ast.Expr
+	Type *GenericType
+}
+
+// InferPackage infers the files of a package in the environment env, which holds the
+// package-level declarations, and records the results in an Info.
+// Errors do not stop the inference; they are all returned, joined, with the partial Info.
+func InferPackage(files []*ast.File, env TypeEnv) (*Info, error) {
+	return InferPackageWithOptions(files, env, Options{})
+}
+
+// InferPackageWithOptions is like InferPackage, reporting the diagnostics according to opts:
+// the inference stops after opts.MaxErrors errors, and warnings are recorded in Info.Warnings
+// unless opts.WError reports them as errors. The bodies of the functions are checked for
+// opts.GoVersion, and the positions of the diagnostics resolved in opts.Fset.
+func InferPackageWithOptions(files []*ast.File, env TypeEnv, opts Options) (*Info, error) {
+	info := newInfo()
+	r := opts.reporter()
+	switch {
+	case opts.Profile != nil:
+		info.profile = opts.Profile
+		info.inferProfiled(files, env, r)
+	case opts.Parallel:
+		info.inferParallel(files, env, r)
+	default:
+		for _, file := range files {
+			if !info.infer(file, env, r) {
+				break
+			}
+		}
+	}
+	info.CallGraph = buildCallGraph(files, info)
+	info.Warnings = r.warns
+	return info, errors.Join(r.errs...)
+}
+
+// InferFile infers the top-level declarations of file in env, in order, like InferType:
+// the variables, constants and types are bound in env, and the signatures and bodies of
+// the functions are checked, see CheckFuncBody. The file set and error limit of ctx apply, so that with
+// WithErrorLimit, all the errors of the file are returned at once, joined.
+func InferFile(file *ast.File, env TypeEnv, ctx *InferenceContext) error {
+	errs := ctx.errorList()
+	for _, decl := range file.Decls {
+		// the type parameters of a generic function are in scope in its signature
+		scope := typeParamScope(declTypeParams(decl), env)
+		declCtx := ctx.Child()
+		errs.nest(declCtx)
+		var err error
+		declCtx.Profile.measure(decl, declDesc(decl), func() {
+			_, err = InferType(decl, scope, declCtx)
+			if fn, ok := decl.(*ast.FuncDecl); ok && err == nil {
+				err = CheckFuncBody(fn, env, declCtx)
+			}
+		})
+		if err != nil && !followsInvalid(err) {
+			if !errs.add(err) {
+				break
+			}
+		}
+	}
+	if len(errs.errs) > 0 {
+		return errs.err()
+	}
+	return nil
+}
+
+// DeclResult is the result of inferring a single top-level declaration, see CheckDecls.
+// Its Info holds the uses and instantiations in the declaration, but no call graph.
+type DeclResult struct {
+	Decl ast.Decl
+	Info *Info
+	Err  error
+}
+
+// CheckDecls returns an iterator inferring the top-level declarations of files one at a time,
+// like InferPackage, and yielding the result of each as soon as it completes. Hosts can render
+// progressive results, and since nothing is kept between declarations, memory stays bounded
+// on very large files. Iteration stops when yield returns false.
+//
+// The iterator has the shape of iter.Seq[DeclResult].
+func CheckDecls(files []*ast.File, env TypeEnv) func(yield func(DeclResult) bool) {
+	return func(yield func(DeclResult) bool) {
+		for _, file := range files {
+			for _, decl := range file.Decls {
+				info := newInfo()
+				r := Options{}.reporter()
+				info.infer(decl, env, r)
+				info.Warnings = r.warns
+				if !yield(DeclResult{Decl: decl, Info: info, Err: errors.Join(r.errs...)}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// inferParallel infers each of files in its own goroutine and copy of env, since unification
+// may bind type variables in the environment. The results are merged in the order of the
+// files, and the diagnostics of each file reported to r in the order they were found, so
+// that they are the same as inferring the files in turn.
+//
+// With MaxErrors, the check stops at the same diagnostic, but the Info holds the results
+// of the whole file it stopped in.
+func (info *Info) inferParallel(files []*ast.File, env TypeEnv, r *reporter) {
+	infos := make([]*Info, len(files))
+	diags := make([]*reporter, len(files))
+	// collect every diagnostic in order; r decides which are errors
+	opts := r.opts
+	opts.MaxErrors, opts.WError = 0, true
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file *ast.File) {
+			defer wg.Done()
+			fileEnv := make(TypeEnv, len(env))
+			for name, t := range env {
+				fileEnv[name] = t
+			}
+			infos[i], diags[i] = newInfo(), opts.reporter()
+			infos[i].infer(file, fileEnv, diags[i])
+		}(i, file)
+	}
+	wg.Wait()
+
+	for i := range files {
+		for ident, t := range infos[i].Uses {
+			info.Uses[ident] = t
+		}
+		for expr, t := range infos[i].Instances {
+			info.Instances[expr] = t
+		}
+		for call, args := range infos[i].typeArgs {
+			info.typeArgs[call] = args
+		}
+		for _, err := range diags[i].errs {
+			if !r.add(err) {
+				return
+			}
+		}
+	}
+}
+
+// inferProfiled infers the declarations of files in turn, like infer, recording the cost
+// of each in info.profile.
+func (info *Info) inferProfiled(files []*ast.File, env TypeEnv, r *reporter) {
+	for _, file := range files {
+		if !info.infer(file.Name, env, r) {
+			return
+		}
+		for _, decl := range file.Decls {
+			ok := true
+			info.profile.measure(decl, declDesc(decl), func() {
+				ok = info.infer(decl, env, r)
+			})
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func newInfo() *Info {
+	return &Info{
+		Uses:      make(map[*ast.Ident]Type),
+		Instances: make(map[ast.Expr]*GenericType),
+		typeArgs:  make(map[*ast.CallExpr][]Type),
+	}
+}
+
+// infer records the uses and instantiations in root, reporting the errors to r.
+// It reports false if r accepts no more errors.
+func (info *Info) infer(root ast.Node, env TypeEnv, r *reporter) bool {
+	// the type parameters of a generic declaration are in scope in all of it
+	env = typeParamScope(declTypeParams(root), env)
+	locals := localIdents(root)
+	stopped := false
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if stopped {
+			return false
+		}
+		if ident, ok := n.(*ast.Ident); ok && locals[ident] {
+			return false // a parameter or local shadowing an entry of env
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			// the selected name is a field or method, not an entry of the environment
+			ast.Inspect(sel.X, visit)
+			return false
+		}
+		if n != root && len(declTypeParams(n)) > 0 {
+			if !info.infer(n, env, r) {
+				stopped = true
+			}
+			return false
+		}
+		for _, err := range info.record(n, env, r.opts) {
+			if !r.add(err) {
+				stopped = true
+				return false
+			}
+		}
+		return true
+	}
+	ast.Inspect(root, visit)
+	return !stopped
+}
+
+// declTypeParams returns the type parameters declared by a generic type or function
+// declaration, or by the receiver of a method of a generic type.
+func declTypeParams(n ast.Node) []*ast.Ident {
+	switch n := n.(type) {
+	case *ast.TypeSpec:
+		return typeParamNames(n.TypeParams)
+	case *ast.FuncDecl:
+		names := typeParamNames(n.Type.TypeParams)
+		if n.Recv != nil && len(n.Recv.List) == 1 {
+			recv := n.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			_, recvNames := receiverBase(recv)
+			names = append(names, recvNames...)
+		}
+		return names
+	}
+	return nil
+}
+
+// record records the use or instantiation n, if it is one, and checks the body of the
+// function n, if it is one, for opts.GoVersion.
+func (info *Info) record(n ast.Node, env TypeEnv, opts Options) []error {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		// the errors of the signature are those of the declaration, reported by BuildEnv
+		if _, err := buildSignature(n.Type, funcScope(n, env), NewInferenceContext()); err != nil {
+			return nil
+		}
+		ctx := NewInferenceContext(WithErrorLimit(0), WithGoVersion(opts.GoVersion), WithProfile(info.profile), WithFileSet(opts.Fset))
+		ctx.typeArgs = info.typeArgs
+		return unjoin(CheckFuncBody(n, env, ctx))
+	case *ast.Ident:
+		if t, ok := env[n.Name]; ok {
+			info.Uses[n] = t
+		}
+	case *ast.IndexExpr:
+		return info.recordInstance(n, n.X, env, opts)
+	case *ast.IndexListExpr:
+		return info.recordInstance(n, n.X, env, opts)
+	}
+	return nil
+}
+
+func (info *Info) recordInstance(expr, x ast.Expr, env TypeEnv, opts Options) []error {
+	var decl Type
+	switch x := x.(type) {
+	case *ast.Ident:
+		decl = env[x.Name]
+	case *ast.SelectorExpr:
+		// a generic declaration of an imported package, like `list.List[int]`
+		if pkg, ok := packageOf(x.X, env); ok {
+			decl = pkg.Members[x.Sel.Name]
+		}
+	}
+	if _, ok := decl.(*GenericType); !ok {
+		return nil // indexing a slice or map
+	}
+	var t Type
+	var err error
+	info.profile.measure(expr, types.ExprString(expr), func() {
+		t, err = InferType(expr, env, NewInferenceContext(WithProfile(info.profile), WithFileSet(opts.Fset)))
+	})
+	if err != nil {
+		return []error{err}
+	}
+	if instance, ok := t.(*GenericType); ok {
+		info.Instances[expr] = instance
+	}
+	return nil
+}
+
+// Usages returns the identifiers referring to the declaration decl, the entry of the
+// environment a name is bound to, like env["Pair"], in source order. The parameters and
+// local declarations of the functions are resolved through their scopes, so that those
+// shadowing decl are not usages of it. Declarations are told apart by identity, so the
+// variables and constants bound to their VarObj and ConstObj, as BuildEnv does, are
+// distinct declarations even if they have the same type.
+func (info *Info) Usages(decl Type) []*ast.Ident {
+	var idents []*ast.Ident
+	for ident, t := range info.Uses {
+		if t == decl {
+			idents = append(idents, ident)
+		}
+	}
+	sort.Slice(idents, func(i, j int) bool { return idents[i].Pos() < idents[j].Pos() })
+	return idents
+}
+
+// Instantiations returns the instantiations of the generic declaration decl, in source order,
+// answering queries like "where is Pair[K, V] instantiated, and with which arguments".
+func (info *Info) Instantiations(decl *GenericType) []Instance {
+	var instances []Instance
+	for expr, t := range info.Instances {
+		if origin := t.Origin(); origin != nil && origin.Decl == decl {
+			instances = append(instances, Instance{Expr: expr, Type: t})
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Expr.Pos() < instances[j].Expr.Pos() })
+	return instances
+}
+
+// localIdents returns the identifiers of root that declare or refer to a name declared
+// in a function: its receiver, parameters and results, and its local declarations, which
+// shadow the package-level declarations of the same name, like a parameter `count` for a
+// package variable count. The type parameters are not local, since they are in the
+// environment root is inferred in.
+func localIdents(root ast.Node) map[*ast.Ident]bool {
+	r := &localResolver{locals: make(map[*ast.Ident]bool)}
+	r.walk(root)
+	return r.locals
+}
+
+// localResolver resolves the identifiers of a declaration through the scopes of its
+// functions, innermost last.
+type localResolver struct {
+	scopes []map[string]bool
+	locals map[*ast.Ident]bool
+}
+
+func (r *localResolver) open()  { r.scopes = append(r.scopes, make(map[string]bool)) }
+func (r *localResolver) close() { r.scopes = r.scopes[:len(r.scopes)-1] }
+
+// declare declares ident in the innermost scope. Outside functions, names are
+// package-level and are not recorded.
+func (r *localResolver) declare(ident *ast.Ident) {
+	if len(r.scopes) == 0 || ident.Name == "_" {
+		return
+	}
+	r.scopes[len(r.scopes)-1][ident.Name] = true
+	r.locals[ident] = true
+}
+
+func (r *localResolver) isLocal(name string) bool {
+	for _, scope := range r.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// declareExprs declares the identifiers among exprs, the left-hand side of a short
+// variable declaration or range clause.
+func (r *localResolver) declareExprs(exprs ...ast.Expr) {
+	for _, x := range exprs {
+		if ident, ok := x.(*ast.Ident); ok {
+			r.declare(ident)
+		}
+	}
+}
+
+// function resolves a function declaration or literal: the types of its signature are
+// resolved in the enclosing scope, and its body in the scope of its parameters.
+func (r *localResolver) function(recv *ast.FieldList, ft *ast.FuncType, body *ast.BlockStmt) {
+	lists := []*ast.FieldList{recv, ft.TypeParams, ft.Params, ft.Results}
+	for _, list := range lists {
+		if list != nil {
+			for _, field := range list.List {
+				r.walk(field.Type)
+			}
+		}
+	}
+	r.open()
+	defer r.close()
+	for _, list := range []*ast.FieldList{recv, ft.Params, ft.Results} {
+		if list != nil {
+			for _, field := range list.List {
+				for _, name := range field.Names {
+					r.declare(name)
+				}
+			}
+		}
+	}
+	if body != nil {
+		r.stmts(body.List)
+	}
+}
+
+func (r *localResolver) stmts(list []ast.Stmt) {
+	for _, stmt := range list {
+		r.walk(stmt)
+	}
+}
+
+func (r *localResolver) walk(n ast.Node) {
+	switch n := n.(type) {
+	case nil:
+	case *ast.Ident:
+		if r.isLocal(n.Name) {
+			r.locals[n] = true
+		}
+	case *ast.SelectorExpr:
+		// the selected name is a field or method
+		r.walk(n.X)
+	case *ast.FuncDecl:
+		r.function(n.Recv, n.Type, n.Body)
+	case *ast.FuncLit:
+		r.function(nil, n.Type, n.Body)
+	case *ast.BlockStmt:
+		r.open()
+		r.stmts(n.List)
+		r.close()
+	case *ast.IfStmt:
+		r.open()
+		r.walk(n.Init)
+		r.walk(n.Cond)
+		r.walk(n.Body)
+		r.walk(n.Else)
+		r.close()
+	case *ast.ForStmt:
+		r.open()
+		r.walk(n.Init)
+		r.walk(n.Cond)
+		r.walk(n.Post)
+		r.walk(n.Body)
+		r.close()
+	case *ast.RangeStmt:
+		r.walk(n.X)
+		r.open()
+		if n.Tok == token.DEFINE {
+			r.declareExprs(n.Key, n.Value)
+		} else {
+			r.walk(n.Key)
+			r.walk(n.Value)
+		}
+		r.walk(n.Body)
+		r.close()
+	case *ast.SwitchStmt:
+		r.open()
+		r.walk(n.Init)
+		r.walk(n.Tag)
+		r.walk(n.Body)
+		r.close()
+	case *ast.TypeSwitchStmt:
+		r.open()
+		r.walk(n.Init)
+		if assign, ok := n.Assign.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+			r.walk(assign.Rhs[0])
+			r.declareExprs(assign.Lhs...)
+		} else {
+			r.walk(n.Assign)
+		}
+		r.walk(n.Body)
+		r.close()
+	case *ast.CaseClause:
+		r.open()
+		for _, x := range n.List {
+			r.walk(x)
+		}
+		r.stmts(n.Body)
+		r.close()
+	case *ast.CommClause:
+		r.open()
+		r.walk(n.Comm)
+		r.stmts(n.Body)
+		r.close()
+	case *ast.AssignStmt:
+		for _, x := range n.Rhs {
+			r.walk(x)
+		}
+		if n.Tok == token.DEFINE {
+			r.declareExprs(n.Lhs...)
+		} else {
+			for _, x := range n.Lhs {
+				r.walk(x)
+			}
+		}
+	case *ast.TypeSpec:
+		r.declare(n.Name)
+		if n.TypeParams != nil {
+			r.walk(n.TypeParams)
+		}
+		r.walk(n.Type)
+	case *ast.ValueSpec:
+		r.walk(n.Type)
+		for _, x := range n.Values {
+			r.walk(x)
+		}
+		for _, name := range n.Names {
+			r.declare(name)
+		}
+	case *ast.LabeledStmt:
+		// labels are in a space of their own
+		r.walk(n.Stmt)
+	case *ast.BranchStmt:
+	default:
+		ast.Inspect(n, func(c ast.Node) bool {
+			if c == n {
+				return true
+			}
+			r.walk(c)
+			return false
+		})
+	}
+}
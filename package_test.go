@@ -0,0 +1,296 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const packageSrc = `package p
+
+var a = Pair[int, string]{}
+
+func f() {
+	b := Pair[string, int]{}
+	_ = b.key
+	_ = Pair[int, string]{}
+	_ = Box[int]{}
+	_ = xs[0]
+	_ = Box[undefined]{}
+}
+`
+
+func TestUsagesShadowing(t *testing.T) {
+	const src = `package p
+
+var count int
+
+func itoa(n int) string { return "" }
+
+func f(count int) string {
+	itoa := func(n int) string { return "" }
+	return itoa(count)
+}
+
+func g() string {
+	for count := range 3 {
+		_ = count
+	}
+	if s := itoa(count); s != "" {
+		return s
+	}
+	return itoa(count)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	info, err := InferPackage([]*ast.File{file}, env)
+	if err != nil {
+		t.Fatalf("InferPackage() error = %v", err)
+	}
+
+	lines := func(idents []*ast.Ident) []int {
+		var lines []int
+		for _, ident := range idents {
+			lines = append(lines, fset.Position(ident.Pos()).Line)
+		}
+		return lines
+	}
+	if got, want := lines(info.Usages(env["count"])), []int{3, 16, 19}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Usages(count) lines = %v, want %v", got, want)
+	}
+	if got, want := lines(info.Usages(env["itoa"])), []int{5, 16, 19}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Usages(itoa) lines = %v, want %v", got, want)
+	}
+}
+
+func TestInferPackageQueries(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}}, map[string]Type{
+		"key":   &TypeVariable{Name: "K"},
+		"value": &TypeVariable{Name: "V"},
+	}, nil)
+	box := NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"value": &TypeVariable{Name: "T"}}, nil)
+	env := TypeEnv{
+		"int":    intType,
+		"string": strType,
+		"Pair":   pair,
+		"Box":    box,
+		"xs":     &SliceType{ElementType: intType},
+		"key":    &VarObj{Name: "key", Type: strType}, // not used by the selector `b.key`
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", packageSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	info, err := InferPackage([]*ast.File{file}, env)
+	if err == nil || !strings.Contains(err.Error(), "unknown identifier: undefined") {
+		t.Errorf("InferPackage() error = %v, want unknown identifier", err)
+	}
+
+	usages := info.Usages(pair)
+	if len(usages) != 3 {
+		t.Fatalf("Usages(Pair) = %d identifiers, want 3", len(usages))
+	}
+	if line := fset.Position(usages[0].Pos()).Line; line != 3 {
+		t.Errorf("first usage at line %d, want 3", line)
+	}
+	if got := info.Usages(env["key"]); len(got) != 0 {
+		t.Errorf("Usages(key) = %v, want none", got)
+	}
+
+	instances := info.Instantiations(pair)
+	wantArgs := [][]Type{{intType, strType}, {strType, intType}, {intType, strType}}
+	if len(instances) != len(wantArgs) {
+		t.Fatalf("Instantiations(Pair) = %d instances, want %d", len(instances), len(wantArgs))
+	}
+	for i, instance := range instances {
+		if args := instance.Type.Origin().Args; !typeListsEqual(args, wantArgs[i]) {
+			t.Errorf("instance %d arguments = %v, want %v", i, args, wantArgs[i])
+		}
+		if instance.Type.Origin().Pos != instance.Expr.Pos() {
+			t.Errorf("instance %d position = %v, want %v", i, instance.Type.Origin().Pos, instance.Expr.Pos())
+		}
+	}
+	if got := info.Instantiations(box); len(got) != 1 {
+		t.Errorf("Instantiations(Box) = %v, want one instance", got)
+	}
+}
+
+func TestCheckDecls(t *testing.T) {
+	const src = `package p
+
+var a = Box[int]{}
+
+var b = Box[undefined]{}
+
+func f() {
+	_ = Box[string]{}
+	_ = Box[bool]{}
+}
+`
+	intType := &TypeConstant{Name: "int"}
+	env := TypeEnv{
+		"int":    intType,
+		"string": &TypeConstant{Name: "string"},
+		"bool":   &TypeConstant{Name: "bool"},
+		"Box":    NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"value": &TypeVariable{Name: "T"}}, nil),
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	var instances []int
+	var errs []string
+	CheckDecls([]*ast.File{file}, env)(func(r DeclResult) bool {
+		instances = append(instances, len(r.Info.Instances))
+		if r.Err != nil {
+			errs = append(errs, r.Err.Error())
+		}
+		return true
+	})
+	if want := []int{1, 0, 2}; !reflect.DeepEqual(instances, want) {
+		t.Errorf("instances per declaration = %v, want %v", instances, want)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], "unknown identifier: undefined") {
+		t.Errorf("errors = %v, want the error of the second declaration", errs)
+	}
+
+	// stopping early
+	n := 0
+	CheckDecls([]*ast.File{file}, env)(func(r DeclResult) bool {
+		n++
+		return r.Err == nil
+	})
+	if n != 2 {
+		t.Errorf("yielded %d declarations, want 2", n)
+	}
+}
+
+func TestInferFileErrorLimit(t *testing.T) {
+	const src = `package p
+
+func double(x int) int
+
+var a = missing
+
+var (
+	b int = "b"
+	c     = double("c", 1)
+	d     = double(x)
+)
+
+func f(p undefined) int
+`
+	tests := []struct {
+		name    string
+		collect bool
+		limit   int
+		want    []string
+	}{
+		{name: "first error", want: []string{"declaration of a: unknown identifier: missing"}},
+		{
+			name:    "all errors",
+			collect: true,
+			want: []string{
+				"declaration of a: unknown identifier: missing",
+				`declaration of b: cannot convert "b" (untyped string constant) to type int`,
+				"declaration of c: expected 1 arguments, got 2",
+				"declaration of d: unknown identifier: x",
+				"function f: error inferring parameter type: unknown identifier: undefined",
+			},
+		},
+		{
+			name:    "limited",
+			collect: true,
+			limit:   2,
+			want: []string{
+				"declaration of a: unknown identifier: missing",
+				`declaration of b: cannot convert "b" (untyped string constant) to type int`,
+				"too many errors: stopped after 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", src, 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			env := universe()
+			env["double"] = &FunctionType{ParamTypes: []Type{Int}, ReturnType: Int}
+			ctx := NewInferenceContext(WithFileSet(fset))
+			if tt.collect {
+				ctx = ctx.Child(WithErrorLimit(tt.limit))
+			}
+			err = InferFile(file, env, ctx)
+			var got []string
+			for _, err := range unjoin(err) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("InferFile() errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestErrorLimitCallArgs(t *testing.T) {
+	env := universe()
+	env["add"] = &FunctionType{ParamTypes: []Type{Int, Int, Int}, ReturnType: Int}
+	env["s"] = &VarObj{Name: "s", Type: String}
+
+	_, err := InferType(mustParseExpr(t, "add(x, s, y)"), env, NewInferenceContext(WithErrorLimit(0)))
+	want := "unknown identifier: x\nunknown identifier: y\nargument type mismatch for arg 2: type mismatch"
+	if err == nil || err.Error() != want {
+		t.Errorf("InferType() error = %v, want %q", err, want)
+	}
+
+	_, err = InferType(mustParseExpr(t, "add(x, s, y)"), env, nil)
+	if err == nil || err.Error() != "unknown identifier: x" {
+		t.Errorf("InferType() error = %v, want the first error only", err)
+	}
+}
+
+func TestInferPackageFileSet(t *testing.T) {
+	const src = `package p
+
+func f() int {
+	return missing
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	_, err = InferPackageWithOptions([]*ast.File{file}, env, Options{Fset: fset})
+	var te *TypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("InferPackageWithOptions() error = %v, want a TypeError", err)
+	}
+	if got := te.Position.String(); got != "p.go:4:9" {
+		t.Errorf("TypeError.Position = %s, want p.go:4:9", got)
+	}
+}
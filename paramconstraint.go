@@ -0,0 +1,178 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// constraintOrder returns the indices of the parameters of l in the order their constraints
+// can be checked: a parameter whose constraint refers to other parameters, like V in
+// `[V Set[K], K comparable]`, comes after them. Parameters on a cycle of references, like
+// `[A interface{ ~[]B }, B interface{ ~[]A }]`, keep their declaration order, and so do
+// the parameters that do not depend on each other.
+func (l TypeParamList) constraintOrder() []int {
+	deps := make([][]int, len(l))
+	for i, p := range l {
+		deps[i] = l.constraintParams(p.Constraint, i)
+	}
+	order := make([]int, 0, len(l))
+	placed := make([]bool, len(l))
+	for len(order) < len(l) {
+		progress := false
+		for i := range l {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				ready = ready && placed[j]
+			}
+			if ready {
+				order, placed[i], progress = append(order, i), true, true
+			}
+		}
+		if progress {
+			continue
+		}
+		// a cycle: place its first parameter, which the others then follow
+		for i := range l {
+			if !placed[i] {
+				order, placed[i] = append(order, i), true
+				break
+			}
+		}
+	}
+	return order
+}
+
+// constraintParams returns the indices of the parameters of l, other than self, that the
+// constraint c refers to.
+func (l TypeParamList) constraintParams(c *TypeConstraint, self int) []int {
+	if c == nil {
+		return nil
+	}
+	var deps []int
+	for j, p := range l {
+		if j != self && constraintMentions(c, &TypeVariable{Name: p.Name}) {
+			deps = append(deps, j)
+		}
+	}
+	return deps
+}
+
+// constraintMentions reports whether the type variable tv occurs in the constraint c, in
+// its types, excluded types or interface methods.
+func constraintMentions(c *TypeConstraint, tv *TypeVariable) bool {
+	mentions := func(t Type) bool {
+		return len(Inspect(t, func(t Type) bool {
+			v, ok := t.(*TypeVariable)
+			return ok && v.Name == tv.Name
+		})) > 0
+	}
+	for _, t := range append(c.Types[:len(c.Types):len(c.Types)], c.Excluded...) {
+		if mentions(t) {
+			return true
+		}
+	}
+	for _, iface := range c.Interfaces {
+		for _, m := range iface.Methods {
+			for _, t := range append(m.Params[:len(m.Params):len(m.Params)], m.Results...) {
+				if mentions(t) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// substituteConstraint returns a copy of the constraint c in which the type parameters
+// from are replaced by the types to, like `~map[string]struct{}` for `~map[K]struct{}`
+// with K = string.
+func substituteConstraint(c TypeConstraint, from, to []Type, visitor *TypeVisitor) TypeConstraint {
+	c.Types = substituteTypeParamsInSlice(c.Types, from, to, visitor)
+	c.Excluded = substituteTypeParamsInSlice(c.Excluded, from, to, visitor)
+	if c.Interfaces != nil {
+		ifaces := make([]Interface, len(c.Interfaces))
+		for i, iface := range c.Interfaces {
+			ifaces[i] = Interface{Name: iface.Name, Methods: substituteMethodSet(iface.Methods, from, to, visitor)}
+		}
+		c.Interfaces = ifaces
+	}
+	return c
+}
+
+// substituteTypeSet is substituteConstraint for the TypeSet of a generic constraint, if any.
+func substituteTypeSet(c *TypeConstraint, from, to []Type, visitor *TypeVisitor) *TypeConstraint {
+	if c == nil {
+		return nil
+	}
+	substituted := substituteConstraint(*c, from, to, visitor)
+	return &substituted
+}
+
+// coreTerm returns the single type term of the constraint c, like `[]E` for `~[]E`, and
+// reports whether it is approximate, that is whether the type arguments only need to have
+// it as underlying type. Constraints without types or with several have none.
+func coreTerm(c *TypeConstraint) (t Type, approx bool, ok bool) {
+	if c == nil || len(c.Types) != 1 {
+		return nil, false, false
+	}
+	if a, ok := c.Types[0].(*ApproxType); ok {
+		return a.Base, true, true
+	}
+	return c.Types[0], false, true
+}
+
+// declareConstraint declares the constraint interface of spec, which has type elements
+// like `~int | ~float64`. A generic one, like `type Set[K comparable] interface{ ~map[K]struct{} }`,
+// is a GenericType whose instances, like `Set[K]` in `[K comparable, V Set[K]]`, hold the
+// constraint in TypeSet. An empty type set is reported along with the constraint, see
+// interfaceConstraint.
+func declareConstraint(spec *ast.TypeSpec, env TypeEnv) (Type, error) {
+	iface := spec.Type.(*ast.InterfaceType)
+	if spec.TypeParams == nil {
+		tc, err := interfaceConstraint(iface, env)
+		if tc == nil {
+			return nil, err
+		}
+		return &TypeObj{Name: spec.Name.Name, Type: tc}, err
+	}
+	params, err := typeParamList(spec.TypeParams, env)
+	if err != nil {
+		return nil, err
+	}
+	tc, err := interfaceConstraint(iface, typeParamScope(typeParamNames(spec.TypeParams), env))
+	if tc == nil {
+		return nil, err
+	}
+	gt := NewGenericType(spec.Name.Name, params, nil, nil)
+	gt.IsInterface = true
+	gt.TypeSet = tc
+	gt.Pos = spec.Pos()
+	return gt, err
+}
+
+// instanceConstraint converts an instantiated constraint, like `Set[K]`, into the
+// constraint it denotes: the type set of a generic constraint, or the methods of a
+// generic interface. The type arguments may be the type parameters of the enclosing list,
+// which satisfy any constraint until they are bound, see checkConstraint.
+func instanceConstraint(expr ast.Expr, env TypeEnv) (*TypeConstraint, error) {
+	t, err := InferType(expr, env, nil)
+	if err != nil {
+		return nil, err
+	}
+	gt, ok := t.(*GenericType)
+	if !ok || !gt.IsInterface {
+		return nil, fmt.Errorf("%s is not a constraint", types.ExprString(expr))
+	}
+	tc := &TypeConstraint{}
+	if gt.TypeSet != nil {
+		*tc = *gt.TypeSet
+	}
+	if len(gt.Methods) > 0 {
+		tc.Interfaces = append(tc.Interfaces[:len(tc.Interfaces):len(tc.Interfaces)], Interface{Name: FormatGo(gt), Methods: gt.Methods})
+	}
+	return tc, nil
+}
@@ -0,0 +1,123 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParamConstraints(t *testing.T) {
+	const src = `package p
+
+type Set[K comparable] interface{ ~map[K]struct{} }
+
+type Named interface{ ~int | ~string }
+
+type IntSet map[int]struct{}
+
+type Ints []int
+
+func Insert[K comparable, S Set[K]](s S, k K) S { return s }
+
+func Reversed[S Set[K], K comparable](s S) []K { return nil }
+
+func First[S ~[]E, E any](s S) E { var e E; return e }
+
+func Keys[M ~map[K]V, K comparable, V any](m M) []K { return nil }
+
+func Pick[E Named, S ~[]E](s S) E { var e E; return e }
+
+var (
+	ints  IntSet
+	names map[string]int
+	xs    Ints
+	strs  []string
+	fs    []float64
+)
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string // a substring of the error
+	}{
+		{expr: "Insert[int, IntSet]", want: "Insert[int, IntSet]"},
+		{expr: "Insert[int, map[int]struct{}]", want: "Insert[int, map[int]struct{}]"},
+		{expr: "Insert[int, map[int]bool]", wantErr: "does not satisfy"},
+		{expr: "Insert[string, IntSet]", wantErr: "does not satisfy"},
+		{expr: "Reversed[IntSet, int]", want: "Reversed[IntSet, int]"},
+		{expr: "Reversed[IntSet, string]", wantErr: "does not satisfy"},
+		{expr: "Insert(ints, 1)", want: "IntSet"},
+		{expr: "First(xs)", want: "int"},
+		{expr: "First(strs)", want: "string"},
+		{expr: "Keys(names)", want: "[]string"},
+		{expr: "Pick(strs)", want: "string"},
+		{expr: "Pick(fs)", wantErr: "does not satisfy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			got, err := InferType(expr, env, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraintOrder(t *testing.T) {
+	dependent := func(name string) *TypeConstraint {
+		return &TypeConstraint{Types: []Type{&ApproxType{Base: &SliceType{ElementType: &TypeVariable{Name: name}}}}}
+	}
+	tests := []struct {
+		name   string
+		params TypeParamList
+		want   []int
+	}{
+		{name: "independent", params: TypeParamList{{Name: "A"}, {Name: "B"}}, want: []int{0, 1}},
+		{name: "forward reference", params: TypeParamList{{Name: "S", Constraint: dependent("E")}, {Name: "E"}}, want: []int{1, 0}},
+		{name: "backward reference", params: TypeParamList{{Name: "E"}, {Name: "S", Constraint: dependent("E")}}, want: []int{0, 1}},
+		{
+			name:   "chain",
+			params: TypeParamList{{Name: "A", Constraint: dependent("B")}, {Name: "B", Constraint: dependent("C")}, {Name: "C"}},
+			want:   []int{2, 1, 0},
+		},
+		{
+			name:   "cycle",
+			params: TypeParamList{{Name: "A", Constraint: dependent("B")}, {Name: "B", Constraint: dependent("A")}, {Name: "C"}},
+			want:   []int{2, 0, 1},
+		},
+		{name: "self reference", params: TypeParamList{{Name: "A", Constraint: dependent("A")}}, want: []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.params.constraintOrder(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("constraintOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,34 +1,68 @@
 package generic
 
 import (
-	"fmt"
 	"go/ast"
+	"go/types"
+
+	gerrors "github.com/notJoon/generic/errors"
 )
 
+// instantiatePartial instantiates gt with the type arguments indices of expr, like `Map[int]`.
+// The type parameters of a generic function left out, like T, are left uninstantiated in the
+// instance, to be inferred from the arguments of its call.
+func instantiatePartial(expr ast.Expr, gt *GenericType, indices []ast.Expr, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	typeArgs, err := inferPartialTypeParams(gt, indices, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := instantiateAt(expr.Pos(), gt, typeArgs, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if instance, ok := t.(*GenericType); ok && len(indices) < len(typeArgs) {
+		instance.origin.open = make([]bool, len(typeArgs))
+		for i := len(indices); i < len(typeArgs); i++ {
+			instance.origin.open[i] = true
+		}
+	}
+	return t, nil
+}
+
 // inferPartialTypeParams infers type parameters for a generic type,
 // handling partial specification.
 func inferPartialTypeParams(gt *GenericType, indices []ast.Expr, env TypeEnv, ctx *InferenceContext) ([]interface{}, error) {
 	inferParams := make([]interface{}, len(gt.TypeParams))
-	for i := range gt.TypeParams {
-		inferParams[i] = gt.TypeParams[i] // start with the original type parameter
+	for i, tp := range gt.TypeParams {
+		// the parameters left uninstantiated are renamed, so that unifying the instance
+		// does not bind the parameters of the same name of the enclosing declaration
+		if tv, ok := tp.(*TypeVariable); ok {
+			tp = typeVars.Fresh(tv.Name)
+		}
+		inferParams[i] = tp
 	}
 
-	for i, index := range indices {
-		if i >= len(gt.TypeParams) {
-			return nil, fmt.Errorf("too many type parameters specified for %s", gt.Name)
+	params := gt.TypeParamList()
+	if len(indices) > len(gt.TypeParams) {
+		args := make([]string, len(indices))
+		for i, index := range indices {
+			args[i] = types.ExprString(index)
 		}
-
+		return nil, typeArgCountError(gt.Name, gt.Pos, params, args, ctx)
+	}
+	// with defaults enabled, the omitted arguments are left to InstantiateGenericType
+	if ctx.Enabled(ExtTypeParamDefaults) && params.hasDefaultsFrom(len(indices)) {
+		inferParams = inferParams[:len(indices)]
+	}
+	for i, index := range indices {
 		pType, err := InferType(index, env, ctx)
 		if err != nil {
 			return nil, err
 		}
 
-		constraint, ok := gt.Constraints[gt.TypeParams[i].(*TypeVariable).Name]
-		if !ok {
-			return nil, fmt.Errorf("no constraint for type parameter %s", gt.TypeParams[i].(*TypeVariable).Name)
-		}
-		if !checkConstraint(pType, constraint) {
-			return nil, fmt.Errorf("type argument %v does not satisfy constraint for %v", pType, constraint)
+		// unconstrained parameters accept any type argument, and the constraints referring
+		// to the other parameters are checked by InstantiateGenericType once they are known
+		if constraint := params[i].Constraint; constraint != nil && len(params.constraintParams(constraint, -1)) == 0 && !gt.satisfies(i, constraint, pType) {
+			return nil, &gerrors.ConstraintError{Param: params.name(i), Arg: FormatGo(pType), Constraint: FormatGo(constraint)}
 		}
 
 		inferParams[i] = pType
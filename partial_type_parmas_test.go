@@ -2,6 +2,7 @@ package generic
 
 import (
 	"go/ast"
+	"strings"
 	"testing"
 )
 
@@ -19,6 +20,7 @@ func TestPartialTypeParameterInference(t *testing.T) {
 				"T": {Types: []Type{&TypeConstant{Name: "int"}, &TypeConstant{Name: "float64"}}},
 				"U": {Types: []Type{&TypeConstant{Name: "string"}, &TypeConstant{Name: "bool"}}},
 			},
+			Signature: &FunctionType{ParamTypes: []Type{&TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}}},
 		},
 	}
 
@@ -103,7 +105,7 @@ func TestPartialTypeParameterInference(t *testing.T) {
 			}
 
 			for i, param := range genericType.TypeParams {
-				if !TypesEqual(param, tt.expectedParams[i]) {
+				if !TypesEqual(unrenamed(param), tt.expectedParams[i]) {
 					t.Errorf("Type parameter %d: expected %v, got %v", i, tt.expectedParams[i], param)
 				}
 			}
@@ -119,3 +121,46 @@ func TestPartialTypeParameterInference(t *testing.T) {
 		})
 	}
 }
+
+func TestPartialTypeInstantiation(t *testing.T) {
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K", Constraint: Any}, {Name: "V", Constraint: Any}}, map[string]Type{
+		"key":   &TypeVariable{Name: "K"},
+		"value": &TypeVariable{Name: "V"},
+	}, nil)
+	box := NewGenericType("Box", TypeParamList{{Name: "T", Constraint: Any}}, map[string]Type{"v": &TypeVariable{Name: "T"}}, nil)
+	env := TypeEnv{"int": Int, "string": String, "Pair": pair, "Box": box}
+
+	tests := []struct {
+		src     string
+		want    string
+		wantErr string
+	}{
+		{src: "Pair[int, string]", want: "Pair[int, string]"},
+		{src: "Box[int]", want: "Box[int]"},
+		{src: "Pair[int]", wantErr: "not enough type arguments for Pair[K, V]: expected 2, got 1 (missing V)"},
+		{src: "Pair[int]{}", wantErr: "not enough type arguments for Pair[K, V]: expected 2, got 1 (missing V)"},
+		{src: "[]Pair[string]", wantErr: "not enough type arguments for Pair[K, V]: expected 2, got 1 (missing V)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+
+	res := CheckSource("package p\n\ntype Pair[K, V any] struct{}\n\nvar q Pair[int]\n", nil)
+	if len(res.Diagnostics) != 1 || !strings.Contains(res.Diagnostics[0].Message, "not enough type arguments for Pair[K, V]") {
+		t.Errorf("CheckSource() diagnostics = %v, want not enough type arguments", res.Diagnostics)
+	}
+}
@@ -0,0 +1,53 @@
+package generic
+
+// The predeclared types. They are shared by the inference of literals, conversions and
+// constraint tables rather than allocated on every use, so comparing two of them is a
+// pointer comparison. They must never be modified.
+var (
+	Bool       = &TypeConstant{Name: TypeBool}
+	String     = &TypeConstant{Name: TypeString}
+	Int        = &TypeConstant{Name: TypeInt}
+	Int8       = &TypeConstant{Name: TypeInt8}
+	Int16      = &TypeConstant{Name: TypeInt16}
+	Int32      = &TypeConstant{Name: TypeInt32}
+	Int64      = &TypeConstant{Name: TypeInt64}
+	Uint       = &TypeConstant{Name: TypeUint}
+	Uint8      = &TypeConstant{Name: TypeUint8}
+	Uint16     = &TypeConstant{Name: TypeUint16}
+	Uint32     = &TypeConstant{Name: TypeUint32}
+	Uint64     = &TypeConstant{Name: TypeUint64}
+	Uintptr    = &TypeConstant{Name: TypeUintptr}
+	Float32    = &TypeConstant{Name: TypeFloat32}
+	Float64    = &TypeConstant{Name: TypeFloat64}
+	Complex64  = &TypeConstant{Name: TypeComplex64}
+	Complex128 = &TypeConstant{Name: TypeComplex128}
+	Byte       = Uint8 // byte is an alias for uint8
+	Rune       = Int32 // rune is an alias for int32
+	Error      = &TypeConstant{Name: "error"}
+
+	// Any is the `any` constraint.
+	Any = &TypeConstraint{BuiltinConstraint: ConstraintAny}
+
+	// Nil is the type of the predeclared nil.
+	Nil = &NilType{}
+)
+
+// predeclared maps the names of the predeclared types to their shared values.
+var predeclared = map[string]*TypeConstant{
+	TypeBool: Bool, TypeString: String,
+	TypeInt: Int, TypeInt8: Int8, TypeInt16: Int16, TypeInt32: Int32, TypeInt64: Int64,
+	TypeUint: Uint, TypeUint8: Uint8, TypeUint16: Uint16, TypeUint32: Uint32, TypeUint64: Uint64, TypeUintptr: Uintptr,
+	TypeFloat32: Float32, TypeFloat64: Float64,
+	TypeComplex64: Complex64, TypeComplex128: Complex128,
+	TypeByte: Byte, TypeRune: Rune,
+	"error": Error,
+}
+
+// typeConstant returns the shared value of the predeclared type name,
+// or a new TypeConstant if name is not predeclared.
+func typeConstant(name string) *TypeConstant {
+	if t, ok := predeclared[name]; ok {
+		return t
+	}
+	return &TypeConstant{Name: name}
+}
@@ -0,0 +1,38 @@
+package generic
+
+import "testing"
+
+func TestPredeclaredTypesShared(t *testing.T) {
+	tests := []struct {
+		src  string
+		want *TypeConstant
+	}{
+		{"42", Int},
+		{"4.2", Float64},
+		{`"hello"`, String},
+		{"'x'", Rune},
+		{"int64(1)", Int64},
+		{"byte(1)", Byte},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), TypeEnv{}, nil)
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if got != Type(tt.want) {
+				t.Errorf("InferType() = %p (%v), want the shared %v", got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypeConstant(t *testing.T) {
+	if got := typeConstant(TypeString); got != String {
+		t.Errorf("typeConstant(%q) = %p, want the shared String", TypeString, got)
+	}
+	if got := typeConstant("Point"); got.Name != "Point" {
+		t.Errorf("typeConstant(%q) = %v, want Point", "Point", got)
+	}
+}
@@ -0,0 +1,112 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ProfileEntry is the cost of inferring a top-level declaration or an instantiation.
+// The cost of a declaration includes that of the instantiations in it.
+type ProfileEntry struct {
+	Node         ast.Node
+	Desc         string // like "func Map" or "Pair[Box[int], string]"
+	Duration     time.Duration
+	Unifications uint64
+}
+
+// Profile records the cost of inferring each top-level declaration and instantiation of a
+// package, to find the pathological generic code, like deeply nested instantiations.
+// Set Options.Profile, or the Profile of the context of InferFile, to record one.
+//
+// The unifications are those of the inference the profile is set for, including the
+// recursive ones, so that other checks running concurrently are not counted.
+type Profile struct {
+	Entries []ProfileEntry
+
+	unifications atomic.Uint64 // so far, see unifier
+}
+
+// measure records the cost of calling f, which infers n, if p is not nil.
+func (p *Profile) measure(n ast.Node, desc string, f func()) {
+	if p == nil {
+		f()
+		return
+	}
+	start, unified := time.Now(), p.unifications.Load()
+	f()
+	p.Entries = append(p.Entries, ProfileEntry{
+		Node:         n,
+		Desc:         desc,
+		Duration:     time.Since(start),
+		Unifications: p.unifications.Load() - unified,
+	})
+}
+
+// TopByTime returns the n entries that took the longest to infer, slowest first.
+func (p *Profile) TopByTime(n int) []ProfileEntry {
+	return p.top(n, func(a, b ProfileEntry) bool { return a.Duration > b.Duration })
+}
+
+// TopByUnifications returns the n entries that needed the most unifications, most first.
+func (p *Profile) TopByUnifications(n int) []ProfileEntry {
+	return p.top(n, func(a, b ProfileEntry) bool { return a.Unifications > b.Unifications })
+}
+
+func (p *Profile) top(n int, less func(a, b ProfileEntry) bool) []ProfileEntry {
+	entries := append([]ProfileEntry(nil), p.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	if n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Report formats the top n entries by inference time and by unification count, one per
+// line with its position in fset, like
+//
+//	p.go:12:1: func Map: 1.2ms, 340 unifications
+func (p *Profile) Report(fset *token.FileSet, n int) string {
+	var b strings.Builder
+	section := func(title string, entries []ProfileEntry) {
+		b.WriteString(title + ":\n")
+		for _, e := range entries {
+			fmt.Fprintf(&b, "\t%s: %s: %v, %d unifications\n", fset.Position(e.Node.Pos()), e.Desc, e.Duration, e.Unifications)
+		}
+	}
+	section("slowest", p.TopByTime(n))
+	section("most unifications", p.TopByUnifications(n))
+	return b.String()
+}
+
+// declDesc describes a top-level declaration for profiles, like "func Map" or "var x, y".
+func declDesc(decl ast.Decl) string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv != nil && len(decl.Recv.List) == 1 {
+			return fmt.Sprintf("func (%s) %s", types.ExprString(decl.Recv.List[0].Type), decl.Name.Name)
+		}
+		return "func " + decl.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names = append(names, name.Name)
+				}
+			case *ast.ImportSpec:
+				names = append(names, spec.Path.Value)
+			}
+		}
+		return decl.Tok.String() + " " + strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%T", decl)
+}
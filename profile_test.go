@@ -0,0 +1,119 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	const src = `package p
+
+type Box[T any] struct{ v T }
+
+type Pair[K comparable, V any] struct {
+	key K
+	val V
+}
+
+func double(x int) int
+
+var small = double(1)
+
+var nested Pair[string, Box[Box[Box[int]]]]
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	files := []*ast.File{file}
+	env, err := BuildEnv(files)
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+
+	profile := &Profile{}
+	info, err := InferPackageWithOptions(files, env, Options{Profile: profile, Parallel: true})
+	if err != nil {
+		t.Fatalf("InferPackageWithOptions() error = %v", err)
+	}
+	if len(info.Instances) != 4 {
+		t.Errorf("Instances = %d, want 4", len(info.Instances))
+	}
+
+	var descs []string
+	for _, e := range profile.Entries {
+		descs = append(descs, e.Desc)
+	}
+	want := "type Box, type Pair, func double, var small, Pair[string, Box[Box[Box[int]]]], Box[Box[Box[int]]], Box[Box[int]], Box[int], var nested"
+	if got := strings.Join(descs, ", "); got != want {
+		t.Errorf("Profile.Entries = %s, want %s", got, want)
+	}
+
+	if got := profile.TopByTime(100); len(got) != len(profile.Entries) {
+		t.Errorf("TopByTime(100) = %d entries, want %d", len(got), len(profile.Entries))
+	}
+
+}
+
+func TestProfileInferFile(t *testing.T) {
+	const src = `package p
+
+func double(x int) int
+
+func sum(a, b, c, d int) int
+
+var x = 1
+
+var one = double(x)
+
+var many = sum(x, x, double(x), double(double(x)))
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	profile := &Profile{}
+	if err := InferFile(file, env, NewInferenceContext(WithProfile(profile))); err != nil {
+		t.Fatalf("InferFile() error = %v", err)
+	}
+	if len(profile.Entries) != 5 {
+		t.Fatalf("Profile.Entries = %d, want 5", len(profile.Entries))
+	}
+
+	top := profile.TopByUnifications(2)
+	if len(top) != 2 || top[0].Desc != "var many" || top[1].Desc != "var one" {
+		t.Errorf("TopByUnifications(2) = %v, want var many, var one", top)
+	}
+	if top[1].Unifications == 0 || top[0].Unifications <= top[1].Unifications {
+		t.Errorf("Unifications = %d, %d, want more for many", top[0].Unifications, top[1].Unifications)
+	}
+
+	// the unifications of checks without the profile are not counted in it
+	profile.measure(file, "other", func() {
+		if err := InferFile(file, env, NewInferenceContext(WithProfile(&Profile{}))); err != nil {
+			t.Fatalf("InferFile() error = %v", err)
+		}
+		if _, err := Unify(&SliceType{ElementType: &TypeVariable{Name: "T"}}, &SliceType{ElementType: Int}, env); err != nil {
+			t.Fatalf("Unify() error = %v", err)
+		}
+	})
+	if got := profile.Entries[len(profile.Entries)-1].Unifications; got != 0 {
+		t.Errorf("Unifications of other checks = %d, want 0", got)
+	}
+
+	report := profile.Report(fset, 1)
+	for _, want := range []string{"slowest:\n\tp.go:", "most unifications:\n\tp.go:11:1: var many: "} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report() =\n%s\nwant it to contain %q", report, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package generic
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestInferQualifiedIdent(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	list := NewGenericType("List", TypeParamList{{Name: "T", Constraint: Any}}, map[string]Type{"items": &SliceType{ElementType: tv}}, nil)
+	element := &StructType{Name: "Element", Fields: map[string]Type{"Value": &InterfaceType{Name: "any", IsEmpty: true}}}
+	pkg := NewPackageType("example.com/container/list", TypeEnv{
+		"List":    list,
+		"Element": element,
+		"Len":     &FunctionType{ReturnType: Int},
+		"Max":     NewGenericFunction("Max", TypeParamList{{Name: "T", Constraint: Any}}, &FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: tv}),
+		"Node":    &TypeAlias{Name: "Node", AliasedTo: element},
+		"helper":  &FunctionType{ReturnType: Int},
+		"MaxLen":  &ConstObj{Name: "MaxLen", Type: Int, Val: constant.MakeInt64(4), Untyped: true},
+		"Ratio":   &ConstObj{Name: "Ratio", Type: Float64, Val: constant.MakeFloat64(1.5), Untyped: true},
+		"Limit":   &ConstObj{Name: "Limit", Type: Int, Val: constant.MakeInt64(8)},
+	})
+	if pkg.Name != "list" {
+		t.Fatalf("NewPackageType() name = %s, want list", pkg.Name)
+	}
+	env := TypeEnv{"list": pkg, "int": Int, "string": String, "byte": Byte, "r": Rune, "f": Float32}
+
+	tests := []struct {
+		src     string
+		want    string
+		wantErr string
+	}{
+		{src: "list.Len", want: "func() int"},
+		{src: "list.Len()", want: "int"},
+		{src: "list.Element", want: "Element"},
+		{src: "list.Node", want: "Element"},
+		{src: "list.List[string]", want: "List[string]"},
+		{src: "list.Max[int]", want: "Max[int]"},
+		{src: "list.Max[int](1, 2)", want: "int"},
+		{src: "list.List[int]{}", want: "List[int]"},
+		{src: "&list.List[string]{}", want: "*List[string]"},
+		{src: "(list.List)[int]{}", wantErr: "invalid composite literal type (list.List)[int]"},
+		{src: "r < list.MaxLen", want: "bool"},
+		{src: "f * list.Ratio", want: "float32"},
+		{src: "[list.MaxLen]byte{}", want: "[4]uint8"},
+		{src: "[list.Limit]byte{}", want: "[8]uint8"},
+		{src: "r < list.Limit", wantErr: "invalid operation: r < list.Limit (mismatched types int32 and int)"},
+		{src: "list", want: "package list"},
+		{src: "list.helper", wantErr: "name helper not exported by package list"},
+		{src: "list.Missing", wantErr: "undefined: list.Missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("InferType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+
+	t.Run("instances", func(t *testing.T) {
+		file, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\n\nvar a = list.List[int]{}\nvar b = list.List[string]{}\n", 0)
+		if err != nil {
+			t.Fatalf("ParseFile() error = %v", err)
+		}
+		info, err := InferPackage([]*ast.File{file}, env)
+		if err != nil {
+			t.Fatalf("InferPackage() error = %v", err)
+		}
+		instances := info.Instantiations(list)
+		if len(instances) != 2 || !TypesEqual(instances[1].Type.Origin().Args[0], String) {
+			t.Errorf("Instantiations(List) = %v, want List[int] and List[string]", instances)
+		}
+		if uses := info.Usages(pkg); len(uses) != 2 {
+			t.Errorf("Usages(list) = %v, want 2", uses)
+		}
+	})
+}
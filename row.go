@@ -0,0 +1,183 @@
+package generic
+
+import (
+	"fmt"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+var ErrMissingField = gerrors.ErrMissingField
+
+// withRow returns the struct type with the given fields followed by the fields of row.
+// The row is either a type variable, which leaves the struct open, a closed anonymous
+// struct, or an open ExtensibleStruct whose fields are merged in. Any other row,
+// including nil, closes the struct.
+func withRow(fields map[string]Type, row Type) Type {
+	merged := make(map[string]Type, len(fields))
+	for name, t := range fields {
+		merged[name] = t
+	}
+	switch row := row.(type) {
+	case nil:
+		return &StructType{Fields: merged}
+	case *TypeVariable:
+		return &ExtensibleStruct{Fields: merged, Rest: row}
+	case *StructType:
+		for name, t := range row.Fields {
+			merged[name] = t
+		}
+		return &StructType{Fields: merged}
+	case *ExtensibleStruct:
+		for name, t := range row.Fields {
+			merged[name] = t
+		}
+		return &ExtensibleStruct{Fields: merged, Rest: row.Rest}
+	}
+	// other types have no fields to add
+	return &StructType{Fields: merged}
+}
+
+// flattenRow merges the fields bound to the row variable of es in env into es,
+// so that the remaining row variable, if any, is unbound.
+func flattenRow(es *ExtensibleStruct, env bindings) Type {
+	if es.Rest == nil {
+		return withRow(es.Fields, nil)
+	}
+	rest := resolve(es.Rest, env)
+	if rest == es.Rest {
+		return es
+	}
+	t := withRow(es.Fields, rest)
+	if open, ok := t.(*ExtensibleStruct); ok {
+		return flattenRow(open, env)
+	}
+	return t
+}
+
+// HasField solves the constraint "t has a field name of type fieldType", updating env.
+//
+// Fields of structs must exist and unify with fieldType. A struct with at least some
+// fields gains the field through its row variable, and an unbound type variable is
+// bound to a struct with at least that field, like `ExtensibleStruct(name T | R)`.
+func HasField(t Type, name string, fieldType Type, env TypeEnv) error {
+	return hasField(t, name, fieldType, env, nil)
+}
+
+// hasField is HasField, counting the unifications in the profile of ctx, if any.
+func hasField(t Type, name string, fieldType Type, env TypeEnv, ctx *InferenceContext) error {
+	u := newUnifier(env, ctx)
+	if err := u.hasField(t, name, fieldType); err != nil {
+		return err
+	}
+	u.subst.bindIn(env)
+	return nil
+}
+
+func (u *unifier) hasField(t Type, name string, fieldType Type) error {
+	t = resolve(t, u)
+	switch t := t.(type) {
+	case *TypeVariable:
+		return u.unifyVar(t, &ExtensibleStruct{
+			Fields: map[string]Type{name: fieldType},
+			Rest:   freshTypeVariable("row"),
+		})
+	case *ExtensibleStruct:
+		flat := flattenRow(t, u)
+		es, ok := flat.(*ExtensibleStruct)
+		if !ok {
+			return u.hasField(flat, name, fieldType)
+		}
+		if fld, ok := es.Fields[name]; ok {
+			return u.unify(fld, fieldType)
+		}
+		return u.hasField(es.Rest, name, fieldType)
+	case *StructType:
+		fld, ok := t.Fields[name]
+		if !ok {
+			return fmt.Errorf("%w %s in %s", ErrMissingField, name, FormatGo(t))
+		}
+		return u.unify(fld, fieldType)
+	case *PointerType:
+		return u.hasField(t.Base, name, fieldType)
+	case *GenericType:
+		if t.isStruct() {
+			return u.hasField(t.Underlying(), name, fieldType)
+		}
+	}
+	return fmt.Errorf("%w %s in %s", ErrMissingField, name, FormatGo(t))
+}
+
+// unifyRows unifies a struct with at least the fields of es with t.
+// The fields both sides have must unify, and each row variable takes the fields
+// only the other side has. Two open rows share a fresh row variable for the rest.
+func (u *unifier) unifyRows(es *ExtensibleStruct, t Type) error {
+	flat := flattenRow(es, u)
+	es, ok := flat.(*ExtensibleStruct)
+	if !ok {
+		return u.unify(flat, t)
+	}
+
+	var fields map[string]Type
+	var rest *TypeVariable
+	switch t := t.(type) {
+	case *TypeVariable:
+		return u.unifyVar(t, es)
+	case *StructType:
+		fields = t.Fields
+	case *ExtensibleStruct:
+		flat := flattenRow(t, u)
+		other, ok := flat.(*ExtensibleStruct)
+		if !ok {
+			return u.unifyRows(es, flat)
+		}
+		fields, rest = other.Fields, other.Rest
+	case *GenericType:
+		if !t.isStruct() {
+			return ErrTypeMismatch
+		}
+		return u.unifyRows(es, t.Underlying())
+	default:
+		return ErrTypeMismatch
+	}
+
+	onlyOther := make(map[string]Type)
+	for name, fld := range fields {
+		if _, ok := es.Fields[name]; !ok {
+			onlyOther[name] = fld
+		}
+	}
+	onlyOwn := make(map[string]Type)
+	for _, name := range sortedKeys(es.Fields) {
+		fld1 := es.Fields[name]
+		fld2, ok := fields[name]
+		if !ok {
+			onlyOwn[name] = fld1
+			continue
+		}
+		if err := u.unify(fld1, fld2); err != nil {
+			return err
+		}
+	}
+
+	if rest == nil {
+		// a closed struct must have all the fields, the row takes the ones left over
+		if len(onlyOwn) > 0 {
+			return fmt.Errorf("%w %s in %s", ErrMissingField, sortedKeys(onlyOwn)[0], FormatGo(t))
+		}
+		return u.unify(es.Rest, withRow(onlyOther, nil))
+	}
+	if es.Rest.Name == rest.Name {
+		if len(onlyOwn) > 0 || len(onlyOther) > 0 {
+			return ErrTypeMismatch
+		}
+		return nil
+	}
+	if len(onlyOwn) == 0 && len(onlyOther) == 0 {
+		return u.unify(es.Rest, rest)
+	}
+	shared := freshTypeVariable("row")
+	if err := u.unify(es.Rest, withRow(onlyOther, shared)); err != nil {
+		return err
+	}
+	return u.unify(rest, withRow(onlyOwn, shared))
+}
@@ -0,0 +1,220 @@
+package generic
+
+import (
+	"errors"
+	"go/parser"
+	"testing"
+)
+
+func TestHasField(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	person := &StructType{Name: "Person", Fields: map[string]Type{"name": strType, "age": intType}}
+
+	tests := []struct {
+		name      string
+		t         Type
+		field     string
+		fieldType Type
+		wantErr   error
+	}{
+		{name: "struct field", t: person, field: "name", fieldType: strType},
+		{name: "struct field through pointer", t: &PointerType{Base: person}, field: "age", fieldType: intType},
+		{name: "struct field of other type", t: person, field: "name", fieldType: intType, wantErr: ErrTypeMismatch},
+		{name: "missing struct field", t: person, field: "email", fieldType: strType, wantErr: ErrMissingField},
+		{name: "type variable", t: &TypeVariable{Name: "P"}, field: "name", fieldType: strType},
+		{
+			name:      "known field of open struct",
+			t:         &ExtensibleStruct{Fields: map[string]Type{"name": strType}, Rest: &TypeVariable{Name: "R"}},
+			field:     "name",
+			fieldType: strType,
+		},
+		{
+			name:      "new field of open struct",
+			t:         &ExtensibleStruct{Fields: map[string]Type{"name": strType}, Rest: &TypeVariable{Name: "R"}},
+			field:     "age",
+			fieldType: intType,
+		},
+		{
+			name:      "int has no fields",
+			t:         intType,
+			field:     "name",
+			fieldType: strType,
+			wantErr:   ErrMissingField,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := make(TypeEnv)
+			err := HasField(tt.t, tt.field, tt.fieldType, env)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("HasField() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HasField() error = %v", err)
+			}
+			// the constraint holds afterwards without further bindings
+			if err := HasField(tt.t, tt.field, tt.fieldType, env); err != nil {
+				t.Errorf("HasField() again error = %v", err)
+			}
+		})
+	}
+}
+
+func TestHasFieldAccumulates(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	p := &TypeVariable{Name: "P"}
+	env := make(TypeEnv)
+
+	if err := HasField(p, "name", strType, env); err != nil {
+		t.Fatalf("HasField(name) error = %v", err)
+	}
+	if err := HasField(p, "age", intType, env); err != nil {
+		t.Fatalf("HasField(age) error = %v", err)
+	}
+	if err := HasField(p, "name", intType, env); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("HasField(name) with other type error = %v, want %v", err, ErrTypeMismatch)
+	}
+
+	// P is now any struct with at least a name and an age
+	person := &StructType{Name: "Person", Fields: map[string]Type{"name": strType, "age": intType, "email": strType}}
+	if _, err := Unify(p, person, env); err != nil {
+		t.Fatalf("Unify() error = %v", err)
+	}
+	if _, err := Unify(p, &StructType{Name: "Pet", Fields: map[string]Type{"name": strType}}, env); err == nil {
+		t.Errorf("Unify() with struct without age succeeded")
+	}
+}
+
+func TestUnifyRows(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	open := func(rest string, fields map[string]Type) *ExtensibleStruct {
+		return &ExtensibleStruct{Fields: fields, Rest: &TypeVariable{Name: rest}}
+	}
+
+	tests := []struct {
+		name    string
+		t1, t2  Type
+		wantErr bool
+		// wantRow is the struct bound to the row variable R, if any
+		wantRow Type
+	}{
+		{
+			name:    "struct with extra fields",
+			t1:      open("R", map[string]Type{"name": strType}),
+			t2:      &StructType{Name: "Person", Fields: map[string]Type{"name": strType, "age": intType}},
+			wantRow: &StructType{Fields: map[string]Type{"age": intType}},
+		},
+		{
+			name:    "struct on the left",
+			t1:      &StructType{Name: "Person", Fields: map[string]Type{"name": strType, "age": intType}},
+			t2:      open("R", map[string]Type{"age": intType}),
+			wantRow: &StructType{Fields: map[string]Type{"name": strType}},
+		},
+		{
+			name:    "struct missing a field",
+			t1:      open("R", map[string]Type{"name": strType, "email": strType}),
+			t2:      &StructType{Name: "Person", Fields: map[string]Type{"name": strType}},
+			wantErr: true,
+		},
+		{
+			name:    "field of other type",
+			t1:      open("R", map[string]Type{"name": strType}),
+			t2:      &StructType{Fields: map[string]Type{"name": intType}},
+			wantErr: true,
+		},
+		{
+			name: "open structs with different fields",
+			t1:   open("R", map[string]Type{"name": strType}),
+			t2:   open("S", map[string]Type{"age": intType}),
+		},
+		{
+			name:    "same row variable with different fields",
+			t1:      open("R", map[string]Type{"name": strType}),
+			t2:      open("R", map[string]Type{"age": intType}),
+			wantErr: true,
+		},
+		{
+			name:    "not a struct",
+			t1:      open("R", map[string]Type{"name": strType}),
+			t2:      intType,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Unify(tt.t1, tt.t2, make(TypeEnv))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantRow != nil && !TypesEqual(s["R"], tt.wantRow) {
+				t.Errorf("row R = %v, want %v", s["R"], tt.wantRow)
+			}
+		})
+	}
+
+	// both open structs end up with all the fields
+	t1 := open("R", map[string]Type{"name": strType})
+	t2 := open("S", map[string]Type{"age": intType})
+	s, err := Unify(t1, t2, make(TypeEnv))
+	if err != nil {
+		t.Fatalf("Unify() error = %v", err)
+	}
+	env := TypeEnv(s)
+	for _, es := range []*ExtensibleStruct{t1, t2} {
+		flat, ok := flattenRow(es, env).(*ExtensibleStruct)
+		if !ok || len(flat.Fields) != 2 || flat.Rest.Name != flattenRow(t1, env).(*ExtensibleStruct).Rest.Name {
+			t.Errorf("flattenRow(%v) = %v, want name and age with a shared row", es, flattenRow(es, env))
+		}
+	}
+}
+
+func TestInferSelectorRowPolymorphism(t *testing.T) {
+	strType := &TypeConstant{Name: "string"}
+	env := TypeEnv{
+		"p":   &TypeVariable{Name: "P"},
+		"rec": &ExtensibleStruct{Fields: map[string]Type{"name": strType}, Rest: &TypeVariable{Name: "R"}},
+	}
+	ctx := NewInferenceContext(WithExtensions(ExtRowPolymorphism))
+
+	expr, err := parser.ParseExpr("p.name")
+	if err != nil {
+		t.Fatalf("ParseExpr() error = %v", err)
+	}
+	if _, err := InferType(expr, env, nil); err == nil {
+		t.Errorf("InferType() without the extension expected error")
+	}
+	field, err := InferType(expr, env, ctx)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	if _, ok := field.(*TypeVariable); !ok {
+		t.Errorf("InferType() = %v, want a type variable for the unknown field type", field)
+	}
+	// P is constrained to a struct with a name field
+	s, err := Unify(env["p"], &StructType{Fields: map[string]Type{"name": strType}}, env)
+	if err != nil {
+		t.Errorf("Unify() error = %v", err)
+	}
+	s.bindIn(env)
+	if !TypesEqual(resolve(field, env), strType) {
+		t.Errorf("field type = %v, want %v", resolve(field, env), strType)
+	}
+
+	// known fields of open structs need no extension
+	expr, err = parser.ParseExpr("rec.name")
+	if err != nil {
+		t.Fatalf("ParseExpr() error = %v", err)
+	}
+	got, err := InferType(expr, env, nil)
+	if err != nil || !TypesEqual(got, strType) {
+		t.Errorf("InferType() = %v, %v, want %v", got, err, strType)
+	}
+}
@@ -0,0 +1,166 @@
+package generic
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// maxSampleDepth bounds the nesting of generated samples, so that samples of
+// recursive types, like a linked list node, are finite. Deeper values are zero.
+const maxSampleDepth = 3
+
+// GenerateZeroValue returns a Go expression for the zero value of t, like `0`, `""`,
+// `nil`, `Age(0)` or `Pair[string, int]{}`. Types whose zero value has no literal form,
+// like type parameters and named types of unknown kind, are written as `*new(T)`.
+// It returns an empty string for types that are not the type of a value, like tuples.
+func GenerateZeroValue(t Type) string {
+	switch t := t.(type) {
+	case *TypeConstant:
+		switch {
+		case t.Name == TypeBool:
+			return "false"
+		case t.Name == TypeString:
+			return `""`
+		case isNumeric(t):
+			return "0"
+		case t.Name == "error":
+			return "nil"
+		}
+	case *TypeAlias:
+		return GenerateZeroValue(t.AliasedTo)
+	case *PointerType, *SliceType, *MapType, *ChanType, *FunctionType, *InterfaceType, *Interface, *NilType:
+		return "nil"
+	case *ArrayType, *StructType, *ExtensibleStruct:
+		return FormatGo(t) + "{}"
+	case *GenericType:
+		switch {
+		case t.Signature != nil || t.IsInterface:
+			return "nil"
+		case t.UnderlyingType != nil:
+			return definedZeroValue(t, underlying(t))
+		}
+		return FormatGo(t) + "{}"
+	case *NamedType:
+		if t.Underlying != nil {
+			return definedZeroValue(t, t.Underlying)
+		}
+	case *TupleType, *NoValueType, nil:
+		return ""
+	}
+	return "*new(" + FormatGo(t) + ")"
+}
+
+// definedZeroValue returns the zero value of the defined type t of underlying type u,
+// like `nil` for a slice, `Grid{}` for an array or `ID[int](0)` for an integer.
+func definedZeroValue(t, u Type) string {
+	switch u.(type) {
+	case *ArrayType, *StructType:
+		return FormatGo(t) + "{}"
+	}
+	zero := GenerateZeroValue(u)
+	if zero == "nil" || zero == "" {
+		return zero
+	}
+	return FormatGo(t) + "(" + zero + ")"
+}
+
+// GenerateSample returns a Go expression for a random value of t, drawn from r,
+// like `[]int{7, 42}` or `Point{X: 3, Y: 12}`. Instances of generic types are filled
+// in with their instantiated fields. Functions and interfaces are sampled as nil,
+// and the types GenerateZeroValue writes as `*new(T)` are sampled as their zero value.
+func GenerateSample(t Type, r *rand.Rand) string {
+	return sample(t, r, 0)
+}
+
+func sample(t Type, r *rand.Rand, depth int) string {
+	if depth > maxSampleDepth {
+		return GenerateZeroValue(t)
+	}
+	switch t := t.(type) {
+	case *TypeConstant:
+		switch {
+		case t.Name == TypeBool:
+			return strconv.FormatBool(r.Intn(2) == 1)
+		case t.Name == TypeString:
+			return strconv.Quote(sampleString(r))
+		case isSigned(t):
+			return strconv.Itoa(r.Intn(200) - 100)
+		case isInteger(t):
+			return strconv.Itoa(r.Intn(100))
+		case isFloat(t):
+			return strconv.FormatFloat(float64(r.Intn(10000))/100, 'f', -1, 64)
+		case isComplex(t):
+			return fmt.Sprintf("complex(%d, %d)", r.Intn(100), r.Intn(100))
+		}
+	case *TypeAlias:
+		return sample(t.AliasedTo, r, depth)
+	case *PointerType:
+		switch t.Base.(type) {
+		case *StructType, *ArrayType, *SliceType, *MapType, *GenericType:
+			if v := sample(t.Base, r, depth+1); v != "nil" {
+				return "&" + v
+			}
+		}
+		return "new(" + FormatGo(t.Base) + ")"
+	case *SliceType:
+		elems := make([]string, 1+r.Intn(3))
+		for i := range elems {
+			elems[i] = sample(t.ElementType, r, depth+1)
+		}
+		return FormatGo(t) + "{" + strings.Join(elems, ", ") + "}"
+	case *ArrayType:
+		elems := make([]string, t.Len)
+		for i := range elems {
+			elems[i] = sample(t.ElementType, r, depth+1)
+		}
+		return FormatGo(t) + "{" + strings.Join(elems, ", ") + "}"
+	case *ChanType:
+		return "make(" + FormatGo(t) + ")"
+	case *MapType:
+		// a single entry, since random keys may collide
+		return fmt.Sprintf("%s{%s: %s}", FormatGo(t),
+			sample(t.KeyType, r, depth+1), sample(t.ValueType, r, depth+1))
+	case *StructType:
+		return FormatGo(t) + sampleFields(t.Fields, r, depth)
+	case *ExtensibleStruct:
+		return FormatGo(t) + sampleFields(t.Fields, r, depth)
+	case *GenericType:
+		switch {
+		case t.Signature != nil || t.IsInterface:
+			return "nil"
+		case t.UnderlyingType != nil:
+			return FormatGo(t) + "(" + sample(t.UnderlyingType, r, depth+1) + ")"
+		}
+		return FormatGo(t) + sampleFields(t.Fields, r, depth)
+	case *NamedType:
+		if t.Underlying == nil {
+			break
+		}
+		// a conversion of a sample of the underlying type, like `Age(42)`
+		if v := sample(t.Underlying, r, depth); v != "nil" {
+			return FormatGo(t) + "(" + v + ")"
+		}
+		return "nil"
+	}
+	return GenerateZeroValue(t)
+}
+
+// sampleFields returns a keyed composite literal body for fields, like `{X: 1, Y: 2}`.
+func sampleFields(fields map[string]Type, r *rand.Rand, depth int) string {
+	elems := make([]string, 0, len(fields))
+	for _, name := range sortedKeys(fields) {
+		elems = append(elems, name+": "+sample(fields[name], r, depth+1))
+	}
+	return "{" + strings.Join(elems, ", ") + "}"
+}
+
+func sampleString(r *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 1+r.Intn(8))
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
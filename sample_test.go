@@ -0,0 +1,91 @@
+package generic
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGenerateZeroValue(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringType := &TypeConstant{Name: "string"}
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}},
+		map[string]Type{"Key": &TypeVariable{Name: "K"}, "Value": &TypeVariable{Name: "V"}}, nil)
+
+	tests := []struct {
+		name string
+		typ  Type
+		want string
+	}{
+		{name: "bool", typ: &TypeConstant{Name: "bool"}, want: "false"},
+		{name: "string", typ: stringType, want: `""`},
+		{name: "float", typ: &TypeConstant{Name: "float64"}, want: "0"},
+		{name: "error", typ: &TypeConstant{Name: "error"}, want: "nil"},
+		{name: "named basic type", typ: &TypeConstant{Name: "Celsius"}, want: "*new(Celsius)"},
+		{name: "alias", typ: &TypeAlias{Name: "ID", AliasedTo: intType}, want: "0"},
+		{name: "slice", typ: &SliceType{ElementType: intType}, want: "nil"},
+		{name: "map", typ: &MapType{KeyType: stringType, ValueType: intType}, want: "nil"},
+		{name: "array", typ: &ArrayType{ElementType: intType, Len: 2}, want: "[2]int{}"},
+		{name: "struct", typ: &StructType{Name: "Point", Fields: map[string]Type{"X": intType}}, want: "Point{}"},
+		{name: "generic instance", typ: mustInstantiate(t, pair, stringType, intType), want: "Pair[string, int]{}"},
+		{name: "type parameter", typ: &TypeVariable{Name: "T"}, want: "*new(T)"},
+		{name: "defined integer", typ: &NamedType{Name: "Age", Underlying: intType}, want: "Age(0)"},
+		{name: "defined string", typ: &NamedType{Name: "Label", Underlying: stringType}, want: `Label("")`},
+		{name: "defined slice", typ: &NamedType{Name: "Names", Underlying: &SliceType{ElementType: stringType}}, want: "nil"},
+		{name: "defined array", typ: &NamedType{Name: "Grid", Underlying: &ArrayType{ElementType: intType, Len: 2}}, want: "Grid{}"},
+		{name: "tuple", typ: &TupleType{Types: []Type{intType, intType}}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenerateZeroValue(tt.typ); got != tt.want {
+				t.Errorf("GenerateZeroValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSample(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringType := &TypeConstant{Name: "string"}
+	pair := NewGenericType("Pair", TypeParamList{{Name: "K"}, {Name: "V"}},
+		map[string]Type{"Key": &TypeVariable{Name: "K"}, "Value": &TypeVariable{Name: "V"}}, nil)
+	node := &StructType{Name: "Node", Fields: map[string]Type{"Value": intType}}
+	node.Fields["Next"] = &PointerType{Base: node}
+
+	tests := []struct {
+		name   string
+		typ    Type
+		prefix string
+	}{
+		{name: "bool", typ: &TypeConstant{Name: "bool"}},
+		{name: "string", typ: stringType, prefix: `"`},
+		{name: "unsigned", typ: &TypeConstant{Name: "uint8"}},
+		{name: "float", typ: &TypeConstant{Name: "float32"}},
+		{name: "complex", typ: &TypeConstant{Name: "complex128"}, prefix: "complex("},
+		{name: "slice", typ: &SliceType{ElementType: stringType}, prefix: "[]string{"},
+		{name: "array", typ: &ArrayType{ElementType: intType, Len: 3}, prefix: "[3]int{"},
+		{name: "map", typ: &MapType{KeyType: stringType, ValueType: &SliceType{ElementType: intType}}, prefix: "map[string][]int{"},
+		{name: "pointer to basic type", typ: &PointerType{Base: intType}, prefix: "new(int)"},
+		{name: "pointer to struct", typ: &PointerType{Base: node}, prefix: "&Node{Next: &Node{"},
+		{name: "anonymous struct", typ: &StructType{Fields: map[string]Type{"A": intType}}, prefix: "struct{ A int }{A: "},
+		{name: "generic instance", typ: mustInstantiate(t, pair, stringType, intType), prefix: `Pair[string, int]{Key: "`},
+		{name: "function", typ: &FunctionType{}, prefix: "nil"},
+		{name: "defined integer", typ: &NamedType{Name: "Age", Underlying: intType}, prefix: "Age("},
+		{name: "defined slice", typ: &NamedType{Name: "Names", Underlying: &SliceType{ElementType: stringType}}, prefix: `Names([]string{"`},
+		{name: "defined function", typ: &NamedType{Name: "Handler", Underlying: &FunctionType{}}, prefix: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateSample(tt.typ, rand.New(rand.NewSource(1)))
+			mustParseExpr(t, got)
+			if !strings.HasPrefix(got, tt.prefix) {
+				t.Errorf("GenerateSample() = %q, want prefix %q", got, tt.prefix)
+			}
+			if again := GenerateSample(tt.typ, rand.New(rand.NewSource(1))); again != got {
+				t.Errorf("GenerateSample() = %q with the same seed, want %q", again, got)
+			}
+		})
+	}
+}
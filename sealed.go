@@ -0,0 +1,80 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+var ErrNotSealed = errors.New("interface is not sealed")
+
+// IsSealed reports whether iface is sealed: it has an unexported method,
+// so only the types of the package declaring it can implement it.
+func IsSealed(iface *InterfaceType) bool {
+	for name := range iface.Methods {
+		if !token.IsExported(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SealedVariants computes the closed set of types implementing the sealed interface iface.
+// The environment stands for the package declaring iface, see Implementations.
+func SealedVariants(iface *InterfaceType, env TypeEnv) ([]Type, error) {
+	if !IsSealed(iface) {
+		return nil, fmt.Errorf("%w: %s has no unexported method", ErrNotSealed, iface.Name)
+	}
+	return Implementations(iface, env), nil
+}
+
+// Seal returns a copy of the sealed interface iface as a sum type of its implementations
+// in env, see SealedVariants, so that type switches over it can be checked for exhaustiveness.
+func Seal(iface *InterfaceType, env TypeEnv) (*InterfaceType, error) {
+	variants, err := SealedVariants(iface, env)
+	if err != nil {
+		return nil, err
+	}
+	sealed := *iface
+	sealed.Variants = variants
+	return &sealed, nil
+}
+
+// CheckSealedSwitches reports every type switch in node over a sum type or a sealed
+// interface that misses one of its variants, in source order. It is meant to be run
+// over the files of the package env describes, like an analyzer pass.
+func CheckSealedSwitches(node ast.Node, env TypeEnv) []error {
+	var errs []error
+	ast.Inspect(node, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.TypeSwitchStmt)
+		if !ok {
+			return true
+		}
+		if err := checkSealedSwitch(stmt, env); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	return errs
+}
+
+func checkSealedSwitch(stmt *ast.TypeSwitchStmt, env TypeEnv) error {
+	subject, err := typeSwitchSubject(stmt, env)
+	if err != nil || subject == nil {
+		return err
+	}
+	iface, ok := unalias(subject).(*InterfaceType)
+	if !ok {
+		return nil
+	}
+	if iface.Variants == nil {
+		if !IsSealed(iface) {
+			return nil
+		}
+		if iface, err = Seal(iface, env); err != nil {
+			return err
+		}
+	}
+	return checkTypeSwitchCases(stmt, iface, env)
+}
@@ -0,0 +1,111 @@
+package generic
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func sealedEnv() (TypeEnv, *InterfaceType) {
+	floatType := &TypeConstant{Name: "float64"}
+	area := Method{Name: "area", Results: []Type{floatType}}
+	shape := &InterfaceType{Name: "Shape", Methods: MethodSet{"area": area}}
+
+	circle := &StructType{Name: "Circle", Fields: map[string]Type{"r": floatType}}
+	circle.Methods = MethodSet{"area": {Name: "area", Receiver: circle, Results: []Type{floatType}}}
+	square := &StructType{Name: "Square", Fields: map[string]Type{"side": floatType}}
+	square.Methods = MethodSet{"area": {Name: "area", Receiver: square, Results: []Type{floatType}, IsPointer: true}}
+	point := &StructType{Name: "Point", Fields: map[string]Type{"x": floatType}}
+
+	env := TypeEnv{
+		"Shape":  shape,
+		"Circle": circle,
+		"Square": square,
+		"Point":  point,
+		"s":      shape,
+		"c":      circle, // a value, not a type declaration
+	}
+	return env, shape
+}
+
+func TestSealedVariants(t *testing.T) {
+	env, shape := sealedEnv()
+
+	if !IsSealed(shape) {
+		t.Fatalf("IsSealed() = false for interface with unexported method")
+	}
+	variants, err := SealedVariants(shape, env)
+	if err != nil {
+		t.Fatalf("SealedVariants() error = %v", err)
+	}
+	want := []Type{env["Circle"], &PointerType{Base: env["Square"]}}
+	if !typeListsEqual(variants, want) {
+		t.Errorf("SealedVariants() = %v, want %v", variants, want)
+	}
+
+	open := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": {Name: "String"}}}
+	if IsSealed(open) {
+		t.Errorf("IsSealed() = true for interface with exported methods only")
+	}
+	if _, err := SealedVariants(open, env); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("SealedVariants() error = %v, want %v", err, ErrNotSealed)
+	}
+
+	sealed, err := Seal(shape, env)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if shape.Variants != nil || len(sealed.Variants) != 2 {
+		t.Errorf("Seal() = %v with variants %v, original variants %v", sealed, sealed.Variants, shape.Variants)
+	}
+}
+
+const sealedSrc = `package p
+
+func _() {
+	switch s.(type) {
+	case Circle, *Square:
+	}
+
+	switch v := s.(type) {
+	case Circle:
+		_ = v
+	}
+
+	switch s.(type) {
+	case Circle:
+	default:
+	}
+
+	switch s.(type) {
+	case Circle, *Square, Point:
+	}
+}
+`
+
+func TestCheckSealedSwitches(t *testing.T) {
+	env, _ := sealedEnv()
+	file, err := parser.ParseFile(token.NewFileSet(), "", sealedSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	errs := CheckSealedSwitches(file, env)
+	want := []string{
+		"non-exhaustive switch of type Shape: missing cases *Square",
+		"Point (type Point) is not a variant of Shape",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("CheckSealedSwitches() = %v, want %d errors", errs, len(want))
+	}
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), want[i]) {
+			t.Errorf("CheckSealedSwitches()[%d] = %v, want %q", i, err, want[i])
+		}
+		if te := (*TypeError)(nil); !errors.As(err, &te) || !te.Pos.IsValid() {
+			t.Errorf("CheckSealedSwitches()[%d] = %#v, want a located TypeError", i, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package generic
+
+import (
+	"go/importer"
+	"go/token"
+	"testing"
+)
+
+// TestCheckOwnSource checks the source of this package with BuildEnvWithImporter and
+// InferPackage, function bodies included, so that the checker keeps up with the Go the
+// package itself is written in. The imports are resolved with go/types.
+func TestCheckOwnSource(t *testing.T) {
+	files, diags := ParsePackageDir(token.NewFileSet(), ".", Options{})
+	if len(diags) > 0 {
+		t.Fatalf("ParsePackageDir() diagnostics = %v", diags)
+	}
+
+	env, err := BuildEnvWithImporter(files, GoImporter(importer.ForCompiler(token.NewFileSet(), "source", nil)))
+	for _, err := range unjoin(err) {
+		t.Errorf("BuildEnvWithImporter() error = %v", err)
+	}
+	_, err = InferPackage(files, env)
+	for _, err := range unjoin(err) {
+		t.Errorf("InferPackage() error = %v", err)
+	}
+	for _, name := range []string{"Unify", "TypesEqual", "NewGenericType", "GenericType", "TypeParamList", "Options"} {
+		if env[name] == nil {
+			t.Errorf("env[%s] = nil, want the declaration", name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package generic
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// SignatureReport renders the exported generic declarations of a checked package as a
+// Markdown document, like a SIGNATURES.md teams can review for API clarity: a section per
+// declaration, in the order of their names, with its signature as written by APIReport and
+// a table of its type parameters, their constraints and the type sets they denote.
+func SignatureReport(env TypeEnv) string {
+	var b strings.Builder
+	b.WriteString("# Generic signatures\n")
+	for _, name := range sortedKeys(env) {
+		gt, ok := unwrapObject(env[name]).(*GenericType)
+		if !ok || gt.Name != name || !token.IsExported(name) {
+			continue
+		}
+		b.WriteString("\n## " + name + "\n\n```go\n")
+		b.WriteString(strings.Join(apiDecls(name, gt), "\n"))
+		b.WriteString("\n```\n\n| Type parameter | Constraint | Type set |\n| --- | --- | --- |\n")
+		params := gt.TypeParamList()
+		for i, p := range params {
+			constraint := "any"
+			if p.Constraint != nil {
+				constraint = formatConstraint(p.Constraint)
+			}
+			b.WriteString("| " + params.name(i) + " | " + markdownCell(constraint) + " | " + markdownCell(TypeSetString(p.Constraint)) + " |\n")
+		}
+	}
+	return b.String()
+}
+
+// TypeSetString describes the type set of a constraint, like "~int | ~string" for
+// `interface{ ~string | ~int }`, or "all comparable types". The predeclared constraints,
+// like cmp.Ordered, are expanded to their terms, and the methods the types must have follow.
+func TypeSetString(tc *TypeConstraint) string {
+	if tc == nil {
+		return "all types"
+	}
+	norm := NormalizeConstraint(*tc)
+
+	var parts []string
+	if set := builtinTypeSet(norm.BuiltinConstraint); set != nil {
+		var terms []string
+		for _, t := range predeclaredTypes {
+			if set[FormatGo(t)] {
+				terms = append(terms, "~"+FormatGo(t))
+			}
+		}
+		parts = append(parts, strings.Join(terms, " | "))
+	}
+	if len(norm.Types) > 0 {
+		terms := make([]string, len(norm.Types))
+		for i, t := range norm.Types {
+			terms[i] = FormatGo(t)
+		}
+		parts = append(parts, strings.Join(terms, " | "))
+	}
+
+	s := strings.Join(parts, " ∩ ")
+	if s == "" {
+		s = "all types"
+		if norm.IsComparable || norm.BuiltinConstraint == ConstraintComparable {
+			s = "all comparable types"
+		}
+	} else if norm.IsComparable {
+		s += ", comparable"
+	}
+
+	var methods []string
+	for _, iface := range norm.Interfaces {
+		for _, name := range sortedKeys(iface.Methods) {
+			methods = append(methods, FormatGo(iface.Methods[name]))
+		}
+	}
+	if len(methods) > 0 {
+		sort.Strings(methods)
+		s += " with methods " + strings.Join(methods, ", ")
+	}
+	if len(norm.Excluded) > 0 {
+		s += " except " + formatTypes(norm.Excluded)
+	}
+	return s
+}
+
+// markdownCell escapes the pipes of s, which would end a cell of a Markdown table.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,78 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestSignatureReport(t *testing.T) {
+	const src = `package p
+
+type Number interface{ ~int | ~float64 }
+
+type Stringer interface{ String() string }
+
+type Set[K comparable] struct{ m map[K]bool }
+
+func (s Set[K]) Len() int
+
+func Sum[T Number](xs []T) T
+
+func Join[S Stringer](xs []S, sep string) string
+
+func helper[T any](x T) T
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	tv := &TypeVariable{Name: "T"}
+	env["Max"] = NewGenericFunction("Max", TypeParamList{{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintOrdered}}},
+		&FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: tv})
+
+	want := "# Generic signatures\n" +
+		"\n## Join\n\n```go\nfunc Join[S Stringer]([]S, string) string\n```\n\n" +
+		"| Type parameter | Constraint | Type set |\n| --- | --- | --- |\n" +
+		"| S | Stringer | all types with methods String() string |\n" +
+		"\n## Max\n\n```go\nfunc Max[T cmp.Ordered](T, T) T\n```\n\n" +
+		"| Type parameter | Constraint | Type set |\n| --- | --- | --- |\n" +
+		"| T | cmp.Ordered | ~string \\| ~int \\| ~int8 \\| ~int16 \\| ~int32 \\| ~int64 \\| ~uint \\| ~uint8 \\| ~uint16 \\| ~uint32 \\| ~uint64 \\| ~uintptr \\| ~float32 \\| ~float64 |\n" +
+		"\n## Set\n\n```go\ntype Set[K comparable] struct{}\nfunc (Set[K]) Len() int\n```\n\n" +
+		"| Type parameter | Constraint | Type set |\n| --- | --- | --- |\n" +
+		"| K | comparable | all comparable types |\n" +
+		"\n## Sum\n\n```go\nfunc Sum[T ~int | ~float64]([]T) T\n```\n\n" +
+		"| Type parameter | Constraint | Type set |\n| --- | --- | --- |\n" +
+		"| T | ~int \\| ~float64 | ~float64 \\| ~int |\n"
+	if got := SignatureReport(env); got != want {
+		t.Errorf("SignatureReport() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTypeSetString(t *testing.T) {
+	tests := []struct {
+		name string
+		tc   *TypeConstraint
+		want string
+	}{
+		{name: "none", tc: nil, want: "all types"},
+		{name: "any", tc: &TypeConstraint{BuiltinConstraint: ConstraintAny}, want: "all types"},
+		{name: "comparable", tc: &TypeConstraint{BuiltinConstraint: ConstraintComparable}, want: "all comparable types"},
+		{name: "signed", tc: &TypeConstraint{BuiltinConstraint: ConstraintSigned}, want: "~int | ~int8 | ~int16 | ~int32 | ~int64"},
+		{name: "union", tc: &TypeConstraint{Types: []Type{String, Int}, Union: true}, want: "int | string"},
+		{name: "comparable union", tc: &TypeConstraint{Types: []Type{Int}, IsComparable: true}, want: "int, comparable"},
+		{name: "excluded", tc: &TypeConstraint{BuiltinConstraint: ConstraintComparable, Excluded: []Type{String}}, want: "all comparable types except string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TypeSetString(tt.tc); got != tt.want {
+				t.Errorf("TypeSetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// SourceResult is the result of checking a source file with CheckSource, or the files
+// of a package with CheckSources.
+// It is tagged for JSON, so that hosts like a web playground can render it directly.
+type SourceResult struct {
+	Diagnostics []SourceDiagnostic `json:"diagnostics"`
+	Types       []SourceType       `json:"types"`
+}
+
+// SourceDiagnostic is a diagnostic of CheckSource. Line and Column are 1-based,
+// and zero if the diagnostic has no position. File is only set by CheckSources.
+type SourceDiagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+// SourceType is the type of an identifier or instantiation in the source, in Go syntax.
+type SourceType struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Expr   string `json:"expr"`
+	Type   string `json:"type"`
+}
+
+// CheckSource parses and infers the Go source file src, without touching the file system.
+// The environment env holds the declarations the file may use besides its own functions,
+// whose signatures are added to a copy of env; the predeclared types need not be in env.
+// The warnings, like those of InferPackage, are reported as diagnostics marked Warning.
+func CheckSource(src string, env TypeEnv) *SourceResult {
+	return checkSources([]sourceFile{{src: src}}, env)
+}
+
+// CheckSources is like CheckSource for the files of a package, keyed by file name, which
+// may use the functions of each other. The diagnostics and types are those of all files,
+// in the order of the file names, and carry the name of their file.
+func CheckSources(srcs map[string]string, env TypeEnv) *SourceResult {
+	files := make([]sourceFile, 0, len(srcs))
+	for _, name := range sortedKeys(srcs) {
+		files = append(files, sourceFile{name: name, src: srcs[name]})
+	}
+	return checkSources(files, env)
+}
+
+// sourceFile is a file checked by checkSources. The file of CheckSource has no name,
+// so that its positions carry none.
+type sourceFile struct {
+	name, src string
+}
+
+func checkSources(srcs []sourceFile, env TypeEnv) *SourceResult {
+	result := &SourceResult{}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, src := range srcs {
+		file, err := parser.ParseFile(fset, src.name, src.src, 0)
+		if err != nil {
+			var list scanner.ErrorList
+			if !errors.As(err, &list) {
+				result.Diagnostics = append(result.Diagnostics, SourceDiagnostic{File: src.name, Message: err.Error()})
+				continue
+			}
+			for _, e := range list {
+				result.Diagnostics = append(result.Diagnostics, SourceDiagnostic{File: e.Pos.Filename, Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg})
+			}
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(result.Diagnostics) > 0 {
+		return result
+	}
+
+	scope := universe()
+	for name, t := range env {
+		scope[name] = t
+	}
+	// the bodies of the functions may use any package-level declaration, so all of them
+	// are declared before the bodies are checked
+	errs := declareFiles(files, scope, fset)
+	info, err := InferPackageWithOptions(files, scope, Options{Fset: fset})
+	// the initializers of the variables are inferred by both, so their errors are
+	// reported once
+	for _, e := range dedupErrors(append(errs, unjoin(err)...)) {
+		result.Diagnostics = append(result.Diagnostics, sourceDiagnostic(fset, token.NoPos, e))
+	}
+	for _, w := range info.Warnings {
+		result.Diagnostics = append(result.Diagnostics, sourceDiagnostic(fset, token.NoPos, w))
+	}
+	for ident, t := range info.Uses {
+		result.Types = append(result.Types, sourceType(fset, ident, t))
+	}
+	for expr, t := range info.Instances {
+		result.Types = append(result.Types, sourceType(fset, expr, t))
+	}
+	sort.Slice(result.Types, func(i, j int) bool {
+		a, b := result.Types[i], result.Types[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Expr < b.Expr
+	})
+	return result
+}
+
+func sourceDiagnostic(fset *token.FileSet, pos token.Pos, err error) SourceDiagnostic {
+	d := SourceDiagnostic{Message: err.Error(), Warning: IsWarning(err)}
+	var te *TypeError
+	if errors.As(err, &te) && te.Pos.IsValid() {
+		pos = te.Pos
+	}
+	if pos.IsValid() {
+		p := fset.Position(pos)
+		d.File, d.Line, d.Column = p.Filename, p.Line, p.Column
+	}
+	return d
+}
+
+func sourceType(fset *token.FileSet, expr ast.Expr, t Type) SourceType {
+	p := fset.Position(expr.Pos())
+	return SourceType{File: p.Filename, Line: p.Line, Column: p.Column, Expr: types.ExprString(expr), Type: FormatGo(t)}
+}
+
+// dedupErrors returns errs without the errors reported at the same position as an
+// earlier one for the same cause, which may be wrapped in different contexts.
+func dedupErrors(errs []error) []error {
+	type key struct {
+		pos token.Pos
+		msg string
+	}
+	seen := make(map[key]bool)
+	var deduped []error
+	for _, err := range errs {
+		var te *TypeError
+		if errors.As(err, &te) && te.Pos.IsValid() {
+			k := key{te.Pos, te.Error()}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		deduped = append(deduped, err)
+	}
+	return deduped
+}
+
+// unjoin returns the errors joined in err by errors.Join, or err itself.
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
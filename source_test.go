@@ -0,0 +1,148 @@
+package generic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckSource(t *testing.T) {
+	box := NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{"Value": &TypeVariable{Name: "T"}}, nil)
+	env := TypeEnv{"Box": box}
+
+	tests := []struct {
+		name      string
+		src       string
+		wantDiags []SourceDiagnostic
+		wantTypes []SourceType
+	}{
+		{
+			name: "types",
+			src:  "package p\n\nfunc double(x int) int\n\nvar b = Box[string]{}\n",
+			wantTypes: []SourceType{
+				{Line: 3, Column: 6, Expr: "double", Type: "func(int) int"},
+				{Line: 3, Column: 15, Expr: "int", Type: "int"},
+				{Line: 3, Column: 20, Expr: "int", Type: "int"},
+				{Line: 5, Column: 5, Expr: "b", Type: "Box[string]"},
+				{Line: 5, Column: 9, Expr: "Box", Type: "Box[T]"},
+				{Line: 5, Column: 9, Expr: "Box[string]", Type: "Box[string]"},
+				{Line: 5, Column: 13, Expr: "string", Type: "string"},
+			},
+		},
+		{
+			name:      "syntax error",
+			src:       "package p\n\nfunc (\n",
+			wantDiags: []SourceDiagnostic{{Line: 3, Column: 8, Message: "expected ')', found 'EOF'"}},
+		},
+		{
+			name:      "inference error",
+			src:       "package p\n\nvar b = Box[undefined]{}\n",
+			wantDiags: []SourceDiagnostic{{Line: 3, Column: 13, Message: "declaration of b: unknown identifier: undefined"}},
+			wantTypes: []SourceType{{Line: 3, Column: 5, Expr: "b", Type: "invalid type"}, {Line: 3, Column: 9, Expr: "Box", Type: "Box[T]"}},
+		},
+		{
+			name:      "assignment in body",
+			src:       "package p\n\nfunc f() {\n\tvar x float32\n\tx = \"hello\"\n}\n",
+			wantDiags: []SourceDiagnostic{{Line: 5, Column: 2, Message: `assignment type mismatch for x: cannot convert "hello" (untyped string constant) to type float32`}},
+			wantTypes: []SourceType{{Line: 3, Column: 6, Expr: "f", Type: "func()"}, {Line: 4, Column: 8, Expr: "float32", Type: "float32"}},
+		},
+		{
+			name:      "undefined in body",
+			src:       "package p\n\nfunc f() int {\n\treturn undefinedIdent\n}\n",
+			wantDiags: []SourceDiagnostic{{Line: 4, Column: 9, Message: "result 1 at 4:9: unknown identifier: undefinedIdent"}},
+			wantTypes: []SourceType{{Line: 3, Column: 6, Expr: "f", Type: "func() int"}, {Line: 3, Column: 10, Expr: "int", Type: "int"}},
+		},
+		{
+			name:      "warning",
+			src:       "package p\n\nfunc f(i int) string {\n\treturn string(i)\n}\n",
+			wantDiags: []SourceDiagnostic{{Line: 4, Column: 9, Message: "result 1 at 4:9: i: conversion from integer to string yields a string of one rune, not a string of digits (did you mean fmt.Sprint(x)?)", Warning: true}},
+			wantTypes: []SourceType{
+				{Line: 3, Column: 6, Expr: "f", Type: "func(int) string"},
+				{Line: 3, Column: 10, Expr: "int", Type: "int"},
+				{Line: 3, Column: 15, Expr: "string", Type: "string"},
+				{Line: 4, Column: 9, Expr: "string", Type: "string"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckSource(tt.src, env)
+			if !reflect.DeepEqual(got.Diagnostics, tt.wantDiags) {
+				t.Errorf("CheckSource() diagnostics = %+v, want %+v", got.Diagnostics, tt.wantDiags)
+			}
+			if !reflect.DeepEqual(got.Types, tt.wantTypes) {
+				t.Errorf("CheckSource() types = %+v, want %+v", got.Types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestCheckSourceDeclarations(t *testing.T) {
+	src := `package p
+
+type Person struct{ Name string }
+
+func (p Person) Greet() string { return "hello " + p.Name }
+
+func Sum[T ~int | ~float64](xs []T) T {
+	var s T
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func greet(p Person) string { return p.Greet() }
+
+var total = Sum([]int{1, 2})
+
+func bad(x Undefined) {}
+`
+	got := CheckSource(src, nil)
+	wantDiags := []SourceDiagnostic{{Line: 19, Column: 1, Message: "bad: function bad: error inferring parameter type: unknown identifier: Undefined"}}
+	if !reflect.DeepEqual(got.Diagnostics, wantDiags) {
+		t.Errorf("CheckSource() diagnostics = %+v, want %+v", got.Diagnostics, wantDiags)
+	}
+	var total []SourceType
+	for _, st := range got.Types {
+		if st.Expr == "total" {
+			total = append(total, st)
+		}
+	}
+	wantTotal := []SourceType{{Line: 17, Column: 5, Expr: "total", Type: "int"}}
+	if !reflect.DeepEqual(total, wantTotal) {
+		t.Errorf("CheckSource() types of total = %+v, want %+v", total, wantTotal)
+	}
+}
+
+func TestCheckSources(t *testing.T) {
+	srcs := map[string]string{
+		"b.go": "package p\n\nvar n = double(1)\n\nvar s = Box[undefined]{}\n",
+		"a.go": "package p\n\nfunc double(x int) int\n",
+	}
+	got := CheckSources(srcs, TypeEnv{"Box": NewGenericType("Box", TypeParamList{{Name: "T"}}, map[string]Type{}, nil)})
+
+	wantDiags := []SourceDiagnostic{{File: "b.go", Line: 5, Column: 13, Message: "declaration of s: unknown identifier: undefined"}}
+	if !reflect.DeepEqual(got.Diagnostics, wantDiags) {
+		t.Errorf("CheckSources() diagnostics = %+v, want %+v", got.Diagnostics, wantDiags)
+	}
+	var calls []SourceType
+	for _, st := range got.Types {
+		if st.Expr == "double" {
+			calls = append(calls, st)
+		}
+	}
+	wantCalls := []SourceType{
+		{File: "a.go", Line: 3, Column: 6, Expr: "double", Type: "func(int) int"},
+		{File: "b.go", Line: 3, Column: 9, Expr: "double", Type: "func(int) int"},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("CheckSources() types of double = %+v, want %+v", calls, wantCalls)
+	}
+
+	got = CheckSources(map[string]string{"a.go": "package p\n", "b.go": "package p\n\nfunc (\n"}, nil)
+	wantDiags = []SourceDiagnostic{{File: "b.go", Line: 3, Column: 8, Message: "expected ')', found 'EOF'"}}
+	if !reflect.DeepEqual(got.Diagnostics, wantDiags) {
+		t.Errorf("CheckSources() diagnostics = %+v, want %+v", got.Diagnostics, wantDiags)
+	}
+}
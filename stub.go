@@ -0,0 +1,105 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrMethodMismatch = errors.New("method signature mismatch")
+
+// MissingMethods returns the methods of iface that recv does not declare, sorted by name.
+// recv is a named struct type or a generic type declaration; methods declared on either
+// receiver count, since stubs can be added to the pointer. iface is an interface type, or
+// an instance of a generic interface, like `Container[int]`. A method that recv declares
+// with a different signature is not missing, and is reported as ErrMethodMismatch.
+func MissingMethods(recv Type, iface Type) ([]Method, error) {
+	st, _, err := stubReceiver(recv)
+	if err != nil {
+		return nil, err
+	}
+	want, ok := stubMethods(iface)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an interface", FormatGo(iface))
+	}
+
+	have := calculateStructMethodSet(st, true)
+	var missing []Method
+	var errs []error
+	for _, name := range sortedKeys(want) {
+		m := want[name]
+		got, ok := have[name]
+		if !ok {
+			missing = append(missing, m)
+			continue
+		}
+		got.IsPointer = m.IsPointer
+		if !MethodsEqual(got, m) {
+			errs = append(errs, fmt.Errorf("%w: %s.%s is %s, %s requires %s",
+				ErrMethodMismatch, st.Name, name, FormatGo(got), FormatGo(iface), FormatGo(m)))
+		}
+	}
+	return missing, errors.Join(errs...)
+}
+
+// GenerateStubs returns the Go source of method stubs for the methods of iface
+// that recv is missing, see MissingMethods, so that recv implements iface.
+// The stubs panic, like
+//
+//	func (l *List[T]) Len() int {
+//		panic("not implemented")
+//	}
+//
+// The receiver of a generic type is written with its type parameters. The stubs use
+// a pointer receiver if recv already has pointer receiver methods, a value receiver otherwise.
+func GenerateStubs(recv Type, iface Type) (string, error) {
+	missing, err := MissingMethods(recv, iface)
+	if err != nil {
+		return "", err
+	}
+	st, recvType, _ := stubReceiver(recv)
+	for _, m := range st.Methods {
+		if m.IsPointer {
+			recvType = "*" + recvType
+			break
+		}
+	}
+	recvName := string(unicode.ToLower([]rune(st.Name)[0]))
+
+	var b strings.Builder
+	for i, m := range missing {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "func (%s %s) %s {\n\tpanic(\"not implemented\")\n}\n", recvName, recvType, FormatGo(m))
+	}
+	return b.String(), nil
+}
+
+// stubMethods returns the methods of the interface iface, which may be defined, like
+// `io.Reader`, or an instance of a generic interface, like `Container[int]`.
+func stubMethods(iface Type) (MethodSet, bool) {
+	if gt, ok := iface.(*GenericType); ok && gt.IsInterface {
+		return gt.Methods, true
+	}
+	i, ok := interfaceOf(iface)
+	return i.Methods, ok
+}
+
+// stubReceiver returns the struct holding the methods of recv and the receiver type
+// methods are declared on, like `List[T]` for a generic type.
+func stubReceiver(recv Type) (*StructType, string, error) {
+	switch t := recv.(type) {
+	case *StructType:
+		if t.Name != "" {
+			return t, t.Name, nil
+		}
+	case *GenericType:
+		if t.isStruct() {
+			st := &StructType{Name: t.Name, Fields: t.Fields, Methods: t.Methods}
+			return st, t.Name + "[" + t.TypeParamList().names() + "]", nil
+		}
+	}
+	return nil, "", fmt.Errorf("cannot declare methods on %s", FormatGo(recv))
+}
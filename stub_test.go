@@ -0,0 +1,120 @@
+package generic
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerateStubs(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	stringType := &TypeConstant{Name: "string"}
+	errorType := &TypeConstant{Name: "error"}
+	tv := &TypeVariable{Name: "T"}
+
+	buffer := &StructType{Name: "Buffer", Fields: map[string]Type{}}
+	buffer.Methods = MethodSet{"Len": {Name: "Len", Receiver: buffer, Results: []Type{intType}, IsPointer: true}}
+	point := &StructType{Name: "Point", Fields: map[string]Type{}}
+	list := NewGenericType("List", TypeParamList{{Name: "T"}}, map[string]Type{"items": &SliceType{ElementType: tv}}, nil)
+
+	writer := &InterfaceType{Name: "Writer", Methods: MethodSet{
+		"Len":   {Name: "Len", Results: []Type{intType}},
+		"Write": {Name: "Write", Params: []Type{&SliceType{ElementType: &TypeConstant{Name: "byte"}}}, Results: []Type{intType, errorType}},
+		"Close": {Name: "Close", Results: []Type{errorType}},
+	}}
+	stringer := &InterfaceType{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{stringType}}}}
+	container := &InterfaceType{Name: "Container", Methods: MethodSet{
+		"Push": {Name: "Push", Params: []Type{&SliceType{ElementType: tv}}, IsVariadic: true},
+	}}
+
+	tests := []struct {
+		name  string
+		recv  Type
+		iface Type
+		want  string
+	}{
+		{
+			name:  "pointer receiver",
+			recv:  buffer,
+			iface: writer,
+			want: "func (b *Buffer) Close() error {\n\tpanic(\"not implemented\")\n}\n\n" +
+				"func (b *Buffer) Write([]byte) (int, error) {\n\tpanic(\"not implemented\")\n}\n",
+		},
+		{
+			name:  "value receiver",
+			recv:  point,
+			iface: stringer,
+			want:  "func (p Point) String() string {\n\tpanic(\"not implemented\")\n}\n",
+		},
+		{
+			name:  "generic receiver",
+			recv:  list,
+			iface: container,
+			want:  "func (l List[T]) Push(...T) {\n\tpanic(\"not implemented\")\n}\n",
+		},
+		{
+			name:  "defined interface",
+			recv:  point,
+			iface: &NamedType{Name: "Lener", Underlying: &InterfaceType{Methods: MethodSet{"Len": writer.Methods["Len"]}}},
+			want:  "func (p Point) Len() int {\n\tpanic(\"not implemented\")\n}\n",
+		},
+		{
+			name:  "generic interface instance",
+			recv:  point,
+			iface: &GenericType{Name: "Sink", IsInterface: true, Methods: MethodSet{"Put": {Name: "Put", Params: []Type{stringType}}}},
+			want:  "func (p Point) Put(string) {\n\tpanic(\"not implemented\")\n}\n",
+		},
+		{name: "nothing missing", recv: buffer, iface: &InterfaceType{Name: "Lener", Methods: MethodSet{"Len": writer.Methods["Len"]}}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateStubs(tt.recv, tt.iface)
+			if err != nil {
+				t.Fatalf("GenerateStubs() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateStubs() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingMethodsErrors(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	buffer := &StructType{Name: "Buffer", Fields: map[string]Type{}}
+	buffer.Methods = MethodSet{"Len": {Name: "Len", Receiver: buffer, Results: []Type{&TypeConstant{Name: "uint"}}}}
+	lener := &InterfaceType{Name: "Lener", Methods: MethodSet{"Len": {Name: "Len", Results: []Type{intType}}}}
+
+	tests := []struct {
+		name    string
+		recv    Type
+		iface   Type
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:    "signature mismatch",
+			recv:    buffer,
+			iface:   lener,
+			wantErr: ErrMethodMismatch,
+			wantMsg: "method signature mismatch: Buffer.Len is Len() uint, Lener requires Len() int",
+		},
+		{name: "not an interface", recv: buffer, iface: intType, wantMsg: "int is not an interface"},
+		{name: "unnamed receiver", recv: &StructType{}, iface: lener, wantMsg: "cannot declare methods on struct{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MissingMethods(tt.recv, tt.iface)
+			if err == nil {
+				t.Fatal("MissingMethods() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("MissingMethods() error = %v, want %v", err, tt.wantErr)
+			}
+			if err.Error() != tt.wantMsg {
+				t.Errorf("MissingMethods() error = %q, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package generic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Substitution maps type variables, by name, to the types unification solved them to,
+// like `T = int`. Unlike the bindings of an environment, it holds only what a single
+// unification solved, see Unify, so it can be inspected, composed and applied to types.
+type Substitution map[string]Type
+
+// bindIn binds the type variables of s in env, for the types inferred in env to see them.
+func (s Substitution) bindIn(env TypeEnv) {
+	for name, t := range s {
+		env[name] = t
+	}
+}
+
+// ApplySubst returns a copy of t in which the type variables bound by s are replaced by
+// their types, themselves substituted, like `[]int` for `[]T` with `T = U, U = int`.
+// Type variables s does not bind are kept as is.
+func ApplySubst(t Type, s Substitution) Type {
+	return applySubst(t, s, make(map[string]bool))
+}
+
+// applySubst is ApplySubst, where active holds the type variables being substituted,
+// so that a cyclic substitution, like `T = []T`, terminates.
+func applySubst(t Type, s Substitution, active map[string]bool) Type {
+	return Map(t, func(t Type) Type {
+		tv, ok := t.(*TypeVariable)
+		if !ok || active[tv.Name] {
+			return t
+		}
+		bound, ok := s[tv.Name]
+		if !ok {
+			return t
+		}
+		active[tv.Name] = true
+		defer delete(active, tv.Name)
+		return applySubst(bound, s, active)
+	})
+}
+
+// Compose returns the substitution applying other, then s, like solving the equations
+// of other before those of s: the types of other are substituted by s, and the type
+// variables only s binds keep their types.
+func (s Substitution) Compose(other Substitution) Substitution {
+	composed := make(Substitution, len(s)+len(other))
+	for name, t := range other {
+		composed[name] = ApplySubst(t, s)
+	}
+	for name, t := range s {
+		if _, ok := composed[name]; !ok {
+			composed[name] = t
+		}
+	}
+	return composed
+}
+
+// String formats the substitution in the order of the names, like `T = int, U = string`.
+func (s Substitution) String() string {
+	bindings := make([]string, 0, len(s))
+	for _, name := range sortedKeys(s) {
+		bindings = append(bindings, fmt.Sprintf("%s = %s", name, FormatGo(s[name])))
+	}
+	return strings.Join(bindings, ", ")
+}
@@ -0,0 +1,111 @@
+package generic
+
+import (
+	"testing"
+)
+
+func TestUnifySubstitution(t *testing.T) {
+	tv, u := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}
+	tests := []struct {
+		name    string
+		t1, t2  Type
+		env     TypeEnv
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "function",
+			t1:   &FunctionType{ParamTypes: []Type{tv, u}, ReturnType: tv},
+			t2:   &FunctionType{ParamTypes: []Type{Int, String}, ReturnType: Int},
+			want: "T = int, U = string",
+		},
+		{
+			name: "bound in env",
+			t1:   &SliceType{ElementType: tv},
+			t2:   &SliceType{ElementType: u},
+			env:  TypeEnv{"U": Int},
+			want: "T = int",
+		},
+		{
+			name: "variable to variable",
+			t1:   &MapType{KeyType: tv, ValueType: Int},
+			t2:   &MapType{KeyType: u, ValueType: Int},
+			want: "T = U",
+		},
+		{name: "nothing to solve", t1: Int, t2: Int, want: ""},
+		{
+			name:    "mismatch",
+			t1:      &FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: tv},
+			t2:      &FunctionType{ParamTypes: []Type{Int, String}, ReturnType: Int},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := TypeEnv{}
+			for name, t := range tt.env {
+				env[name] = t
+			}
+			s, err := Unify(tt.t1, tt.t2, env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(env) != len(tt.env) {
+				t.Errorf("Unify() changed env to %v", env)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := s.String(); got != tt.want {
+				t.Errorf("Unify() = %s, want %s", got, tt.want)
+			}
+			if !TypesEqual(ApplySubst(ApplySubst(tt.t1, s), Substitution(env)), ApplySubst(ApplySubst(tt.t2, s), Substitution(env))) {
+				t.Errorf("ApplySubst() = %v and %v, want identical types", ApplySubst(tt.t1, s), ApplySubst(tt.t2, s))
+			}
+		})
+	}
+}
+
+func TestApplySubst(t *testing.T) {
+	tv, u, v := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}, &TypeVariable{Name: "V"}
+	tests := []struct {
+		name string
+		t    Type
+		s    Substitution
+		want string
+	}{
+		{name: "variable", t: tv, s: Substitution{"T": Int}, want: "int"},
+		{name: "nested", t: &MapType{KeyType: tv, ValueType: &SliceType{ElementType: u}}, s: Substitution{"T": String, "U": Bool}, want: "map[string][]bool"},
+		{name: "chain", t: &SliceType{ElementType: tv}, s: Substitution{"T": &PointerType{Base: u}, "U": Int}, want: "[]*int"},
+		{name: "unbound", t: &SliceType{ElementType: v}, s: Substitution{"T": Int}, want: "[]V"},
+		{name: "cycle", t: tv, s: Substitution{"T": &SliceType{ElementType: tv}}, want: "[]T"},
+		{name: "empty", t: &ChanType{Dir: SendRecv, ElementType: tv}, s: nil, want: "chan T"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatGo(ApplySubst(tt.t, tt.s)); got != tt.want {
+				t.Errorf("ApplySubst() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstitutionCompose(t *testing.T) {
+	tv, u := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}
+	first := Substitution{"T": &SliceType{ElementType: u}}
+	second := Substitution{"U": Int, "V": String}
+
+	composed := second.Compose(first)
+	if got, want := composed.String(), "T = []int, U = int, V = string"; got != want {
+		t.Errorf("Compose() = %s, want %s", got, want)
+	}
+	// applying the composition is applying first, then second
+	typ := &MapType{KeyType: tv, ValueType: u}
+	if got, want := ApplySubst(typ, composed), ApplySubst(ApplySubst(typ, first), second); !TypesEqual(got, want) {
+		t.Errorf("ApplySubst(Compose()) = %v, want %v", got, want)
+	}
+	if first.String() != "T = []U" {
+		t.Errorf("Compose() modified the substitution: %s", first)
+	}
+}
@@ -0,0 +1,56 @@
+package generic
+
+import "fmt"
+
+// NewSumType returns a sum type: an interface whose values hold exactly one of the variants.
+//
+// Go has no sum types, so they are modeled the usual way, as an interface implemented
+// by a closed set of types. Only the variants are assignable to the sum type, and type
+// switches over it can be checked for exhaustiveness with CheckTypeSwitchExhaustive.
+func NewSumType(name string, variants ...Type) *InterfaceType {
+	return &InterfaceType{Name: name, Methods: MethodSet{}, Variants: variants}
+}
+
+// OptionType returns the sum type `Option[T]` of an optional value of type t.
+// Its variants are `Some[T]`, holding the value in its Value field, and the empty `None[T]`.
+// The names include the type argument, like `Option[TypeConst(int)]`.
+func OptionType(t Type) *InterfaceType {
+	args := fmt.Sprintf("[%s]", typeString(t))
+	return NewSumType("Option"+args,
+		&StructType{Name: "Some" + args, Fields: map[string]Type{"Value": t}},
+		&StructType{Name: "None" + args, Fields: map[string]Type{}},
+	)
+}
+
+// ResultType returns the sum type `Result[T, E]` of a computation that either succeeds
+// with a value of type t, held by the Value field of `Ok[T, E]`, or fails with an error
+// of type e, held by the Err field of `Err[T, E]`.
+func ResultType(t, e Type) *InterfaceType {
+	args := fmt.Sprintf("[%s]", typeListString([]Type{t, e}))
+	return NewSumType("Result"+args,
+		&StructType{Name: "Ok" + args, Fields: map[string]Type{"Value": t}},
+		&StructType{Name: "Err" + args, Fields: map[string]Type{"Err": e}},
+	)
+}
+
+// IsVariant reports whether t is one of the variants of the sum type.
+func IsVariant(sum *InterfaceType, t Type) bool {
+	return variantIndex(sum, t) >= 0
+}
+
+// variantIndex returns the index of t in the variants of the sum type, or -1.
+func variantIndex(sum *InterfaceType, t Type) int {
+	t = unalias(t)
+	for i, variant := range sum.Variants {
+		if TypesEqual(unalias(variant), t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SumConstraint returns the union constraint satisfied by the variants of the sum type,
+// like `Some[T] | None[T]`, to restrict a type parameter to one of them.
+func SumConstraint(sum *InterfaceType) TypeConstraint {
+	return TypeConstraint{Types: sum.Variants, Union: true}
+}
@@ -0,0 +1,72 @@
+package generic
+
+import (
+	"go/parser"
+	"testing"
+)
+
+func TestSumTypeAssignability(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	strType := &TypeConstant{Name: "string"}
+	errType := &TypeConstant{Name: "error"}
+	opt := OptionType(intType)
+	some, none := opt.Variants[0], opt.Variants[1]
+	res := ResultType(intType, errType)
+
+	if opt.Name != "Option[TypeConst(int)]" || typeString(some) != "Struct(Some[TypeConst(int)])" {
+		t.Errorf("OptionType() = %v with variants %v", opt, opt.Variants)
+	}
+	if ok := res.Variants[0].(*StructType); !TypesEqual(ok.Fields["Value"], intType) {
+		t.Errorf("Ok field Value = %v, want %v", ok.Fields["Value"], intType)
+	}
+
+	tests := []struct {
+		name    string
+		to      Type
+		from    Type
+		wantErr bool
+	}{
+		{name: "variant to sum", to: opt, from: some},
+		{name: "other variant to sum", to: opt, from: none},
+		{name: "alias of variant", to: opt, from: &TypeAlias{Name: "S", AliasedTo: some}},
+		{name: "variant of other sum", to: opt, from: OptionType(strType).Variants[0], wantErr: true},
+		{name: "payload is not a variant", to: opt, from: intType, wantErr: true},
+		{name: "identical sums", to: opt, from: OptionType(intType)},
+		{name: "sums over other types", to: opt, from: OptionType(strType), wantErr: true},
+		{name: "sum is not a variant", to: some, from: opt, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unify(tt.to, tt.from, make(TypeEnv))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if convertible(tt.from, tt.to) == tt.wantErr {
+				t.Errorf("convertible() = %v, want %v", !tt.wantErr, !tt.wantErr)
+			}
+		})
+	}
+
+	// variants satisfy the union constraint of their sum type
+	constraint := SumConstraint(opt)
+	if !checkConstraint(some, constraint) || checkConstraint(intType, constraint) {
+		t.Errorf("SumConstraint() = %v does not accept exactly the variants", &constraint)
+	}
+
+	// passing a variant where the sum type is expected
+	callEnv := TypeEnv{
+		"get":  &FunctionType{ParamTypes: []Type{opt}, ReturnType: intType},
+		"some": some,
+		"n":    intType,
+	}
+	for src, wantErr := range map[string]bool{"get(some)": false, "get(n)": true} {
+		expr, err := parser.ParseExpr(src)
+		if err != nil {
+			t.Fatalf("ParseExpr() error = %v", err)
+		}
+		if _, err := InferType(expr, callEnv, nil); (err != nil) != wantErr {
+			t.Errorf("InferType(%s) error = %v, wantErr %v", src, err, wantErr)
+		}
+	}
+}
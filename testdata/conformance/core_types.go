@@ -0,0 +1,29 @@
+// Examples of the "Core types" section of the Go specification.
+package coretypes
+
+type Bytes []byte
+
+type Celsius float64
+
+type Slice[E any] interface {
+	~[]E
+}
+
+func First[S ~[]E, E any](s S) E { var e E; return e }
+
+func Len[S ~string | ~[]byte](s S) int { return len(s) }
+
+func Send[C ~chan<- E, E any](c C, e E) {}
+
+func Recv[E any](c <-chan E) E { return <-c }
+
+var (
+	firstInt   = First([]int{1})               // want int
+	firstBytes = First(Bytes{1})               // want uint8
+	lenString  = Len("abc")                    // want int
+	lenBytes   = Len(Bytes{})                  // want int
+	lenInt     = Len(1)                        // error does not satisfy
+	recvInt    = Recv(make(chan int))          // want int
+	recvNamed  = Recv[Celsius]                 // want func(<-chan Celsius) Celsius
+	sendNamed  = Send[chan<- Celsius, Celsius] // want func(chan<- Celsius, Celsius)
+)
@@ -0,0 +1,33 @@
+// Examples of the "Type inference" section of the Go specification
+// and of the type parameters proposal.
+package inference
+
+type List[T any] []T
+
+func Map[F, T any](s []F, f func(F) T) []T { return nil }
+
+func Identity[T any](x T) T { return x }
+
+func Apply[S ~[]E, E any](s S, f func(E) E) S { return s }
+
+func Pair[A, B any](a A, b B) (A, B) { return a, b }
+
+func itoa(x int) string { return "" }
+
+func double(x int) int { return 2 * x }
+
+var (
+	explicit   = Map[int, string]([]int{1}, itoa)   // want []string
+	partial    = Map[int]([]int{1}, itoa)           // want []string
+	inferred   = Map([]int{1}, itoa)                // want []string
+	untyped    = Identity(1)                        // want int
+	untypedF   = Identity(2.5)                      // want float64
+	typed      = Identity[int64](1)                 // want int64
+	funcArg    = Identity(double)                   // want func(int) int
+	instance   = Identity[string]                   // want func(string) string
+	coreType   = Apply([]int{1}, double)            // want []int
+	namedSlice = Apply(List[int]{1}, double)        // want List[int]
+	tooMany    = Map[int, string, bool]             // error type arguments
+	mismatch   = Map[int, string]([]string{}, itoa) // error mismatch
+	noInfer    = Identity[int]("a")                 // error cannot
+)
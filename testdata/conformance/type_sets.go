@@ -0,0 +1,48 @@
+// Examples of the "Interface types", "Type constraints" and "Satisfying a type constraint"
+// sections of the Go specification.
+package typesets
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+type Float interface {
+	~float32 | ~float64
+}
+
+type Stringer interface {
+	String() string
+}
+
+type MyInt int
+
+type Celsius float64
+
+type Name struct{ first, last string }
+
+func (n Name) String() string { return n.first }
+
+func Sum[T Number](xs []T) T { var s T; return s }
+
+func Keys[K comparable, V any](m map[K]V) []K { return nil }
+
+func Abs[T Float](x T) T { return x }
+
+func Join[T Stringer](xs []T) string { return "" }
+
+var (
+	sumInt     = Sum[int]          // want func([]int) int
+	sumNamed   = Sum[MyInt]        // want func([]MyInt) MyInt
+	sumFloat   = Sum[float64]      // want func([]float64) float64
+	sumString  = Sum[string]       // error does not satisfy
+	sumInt32   = Sum[int32]        // error does not satisfy
+	absCelsius = Abs[Celsius]      // want func(Celsius) Celsius
+	absInt     = Abs[int]          // error does not satisfy
+	keysString = Keys[string, int] // want func(map[string]int) []string
+	keysStruct = Keys[Name, int]   // want func(map[Name]int) []Name
+	keysSlice  = Keys[[]int, int]  // error does not satisfy
+	keysFunc   = Keys[func(), int] // error does not satisfy
+	joinNames  = Join[Name]        // want func([]Name) string
+	joinInts   = Join[int]         // error does not satisfy
+	anyValue   = Keys[any, int]    // want func(map[any]int) []any
+)
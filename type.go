@@ -2,6 +2,7 @@ package generic
 
 import (
 	"fmt"
+	"go/token"
 	"strings"
 )
 
@@ -34,9 +35,31 @@ const (
 	TypeFloat64    = "float64"
 	TypeComplex64  = "complex64"
 	TypeComplex128 = "complex128"
+	TypeByte       = "byte" // alias for uint8
+	TypeRune       = "rune" // alias for int32
 )
 
-// TODO: print type more go-like
+// nilTypeString is printed in place of missing types. Error paths often
+// print partially-built types, so String methods must never panic on nil.
+const nilTypeString = "<nil>"
+
+// typeString returns the string representation of t, or nilTypeString if t is nil
+// (including typed nil pointers).
+func typeString(t Type) string {
+	if t == nil {
+		return nilTypeString
+	}
+	return t.String()
+}
+
+// typeListString joins the string representations of the given types with commas.
+func typeListString(types []Type) string {
+	ts := make([]string, len(types))
+	for i, t := range types {
+		ts[i] = typeString(t)
+	}
+	return strings.Join(ts, ", ")
+}
 
 // Type represents any type in the type system.
 // It serves as the base interface for all types in the generic type system.
@@ -44,6 +67,39 @@ type Type interface {
 	String() string
 }
 
+// typeKinds registers every kind of Type defined in this package.
+// A new kind must be added here; the tests then check that Unify, TypesEqual,
+// substitution, Walk and String all handle it, instead of silently falling through.
+var typeKinds = []Type{
+	(*TypeVariable)(nil),
+	(*TypeConstant)(nil),
+	(*NamedType)(nil),
+	(*FunctionType)(nil),
+	(*TupleType)(nil),
+	(*NoValueType)(nil),
+	(*NilType)(nil),
+	(*Interface)(nil),
+	(*InterfaceType)(nil),
+	Method{},
+	(*PointerType)(nil),
+	(*StructType)(nil),
+	(*ExtensibleStruct)(nil),
+	(*SliceType)(nil),
+	(*ArrayType)(nil),
+	(*MapType)(nil),
+	(*ChanType)(nil),
+	(*TypeConstraint)(nil),
+	(*ApproxType)(nil),
+	(*GenericType)(nil),
+	(*GenericMethod)(nil),
+	(*TypeAlias)(nil),
+	(*PackageType)(nil),
+	(*VarObj)(nil),
+	(*ConstObj)(nil),
+	(*TypeObj)(nil),
+	(*FuncObj)(nil),
+}
+
 // TypeVariable represents a type variable with a name.
 // In generic programming, type variables are placeholders for types
 // that will be specified later, allowing for polymorphic code.
@@ -52,6 +108,9 @@ type TypeVariable struct {
 }
 
 func (tv *TypeVariable) String() string {
+	if tv == nil {
+		return nilTypeString
+	}
 	return fmt.Sprintf("TypeVar(%s)", tv.Name)
 }
 
@@ -62,21 +121,61 @@ type TypeConstant struct {
 }
 
 func (tc *TypeConstant) String() string {
+	if tc == nil {
+		return nilTypeString
+	}
 	return fmt.Sprintf("TypeConst(%s)", tc.Name)
 }
 
+// NamedType is a defined type with a basic or composite underlying type, like `type Age int`.
+// Like any defined type, it is identical only to itself, but its values support
+// the operations of its underlying type, so it satisfies constraints like `~int`
+// and `constraints.Integer`. Defined struct and interface types are StructType and InterfaceType.
+type NamedType struct {
+	Name       string
+	Underlying Type
+	Methods    MethodSet
+}
+
+func (nt *NamedType) String() string {
+	if nt == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Named(%s = %s)", nt.Name, typeString(nt.Underlying))
+}
+
+// underlying returns the underlying type of t, looking through aliases and defined types,
+// including the generic types defined by another type, like `type List[T any] []T`.
+func underlying(t Type) Type {
+	for {
+		switch u := t.(type) {
+		case *TypeAlias:
+			t = u.AliasedTo
+		case *NamedType:
+			t = u.Underlying
+		case *GenericType:
+			if u.UnderlyingType == nil {
+				return t
+			}
+			t = u.UnderlyingType
+		default:
+			return t
+		}
+	}
+}
+
 // FunctionType represents a function type with parameter types and return type.
 // It describes the signature of a function in the type system.
 type FunctionType struct {
 	ParamTypes []Type
 	ReturnType Type
 	IsVariadic bool
+	Effects    Effects // zero if the function is not annotated
 }
 
 func (ft *FunctionType) String() string {
-	params := make([]string, len(ft.ParamTypes))
-	for i, param := range ft.ParamTypes {
-		params[i] = param.String()
+	if ft == nil {
+		return nilTypeString
 	}
 
 	var variadic string
@@ -84,7 +183,15 @@ func (ft *FunctionType) String() string {
 		variadic = "..."
 	}
 
-	return fmt.Sprintf("func(%s%s) %s", strings.Join(params, ", "), variadic, ft.ReturnType.String())
+	// functions without results are printed without a return type, like `func(int)`
+	sig := fmt.Sprintf("func(%s%s)", typeListString(ft.ParamTypes), variadic)
+	if ft.ReturnType != nil {
+		sig = fmt.Sprintf("%s %s", sig, ft.ReturnType.String())
+	}
+	if ft.Effects.Known() {
+		sig = fmt.Sprintf("%s effects(%v)", sig, ft.Effects)
+	}
+	return sig
 }
 
 type TupleType struct {
@@ -92,11 +199,55 @@ type TupleType struct {
 }
 
 func (tt *TupleType) String() string {
-	ts := make([]string, len(tt.Types))
-	for i, t := range tt.Types {
-		ts[i] = t.String()
+	if tt == nil {
+		return nilTypeString
 	}
-	return fmt.Sprintf("(%s)", strings.Join(ts, ", "))
+	return fmt.Sprintf("(%s)", typeListString(tt.Types))
+}
+
+// NoValueType is the type of a call to a function or method without results.
+// Unlike the other types, it has no values, so it can only appear in statement
+// context and never be assigned, passed as an argument or returned.
+type NoValueType struct{}
+
+func (nv *NoValueType) String() string {
+	return "()"
+}
+
+// isNoValue reports whether t is the result type of a call without results.
+func isNoValue(t Type) bool {
+	_, ok := t.(*NoValueType)
+	return ok
+}
+
+// NilType is the type of the predeclared `nil`, the untyped zero value of the pointer,
+// slice, map, channel, function and interface types. Like an untyped constant, it takes
+// the type it is used as, so it unifies with those types but not with value types, and
+// a variable cannot be declared from it alone, like `x := nil`.
+type NilType struct{}
+
+func (n *NilType) String() string {
+	return "nil"
+}
+
+// isNil reports whether t is the type of the predeclared nil.
+func isNil(t Type) bool {
+	_, ok := t.(*NilType)
+	return ok
+}
+
+// isNilable reports whether nil is a value of type t: a pointer, slice, map, channel,
+// function or interface type, or a type defined as one of them.
+func isNilable(t Type) bool {
+	switch u := underlying(unwrapObject(t)).(type) {
+	case *PointerType, *SliceType, *MapType, *ChanType, *FunctionType, *InterfaceType, *Interface, *NilType:
+		return true
+	case *TypeConstant:
+		return u.Name == Error.Name || u.Name == "unsafe.Pointer"
+	case *GenericType:
+		return u.Signature != nil || u.IsInterface
+	}
+	return false
 }
 
 type Interface struct {
@@ -105,6 +256,9 @@ type Interface struct {
 }
 
 func (it *Interface) String() string {
+	if it == nil {
+		return nilTypeString
+	}
 	return fmt.Sprintf("Interface(%s)", it.Name)
 }
 
@@ -115,40 +269,67 @@ type InterfaceType struct {
 	GenericMethods map[string]GenericMethod
 	Embedded       []Type
 	IsEmpty        bool // true for interface{}
+
+	// Variants is the closed set of types the values of a sum type can hold,
+	// or nil for interfaces that any type with the methods implements. See NewSumType.
+	Variants []Type
 }
 
 func (it *InterfaceType) String() string {
+	if it == nil {
+		return nilTypeString
+	}
 	if it.IsEmpty {
 		return "interface{}"
 	}
 	return fmt.Sprintf("InterfaceType(%s)", it.Name)
 }
 
+// Method represents a method signature.
+// Receiver is the base type the method is declared on, and IsPointer reports whether
+// it is declared on the pointer to that type. Interface methods have no receiver.
 type Method struct {
-	Name      string
-	Params    []Type
-	Results   []Type
-	IsPointer bool
+	Name       string
+	Receiver   Type
+	Params     []Type
+	Results    []Type
+	IsPointer  bool
+	IsVariadic bool // true if the last parameter is variadic, like `...T`
+}
+
+// ReceiverType returns the full receiver type of the method, like `*T` for pointer receivers.
+// It returns nil if the method has no receiver.
+func (m Method) ReceiverType() Type {
+	if m.Receiver == nil {
+		return nil
+	}
+	if m.IsPointer {
+		return &PointerType{Base: m.Receiver}
+	}
+	return m.Receiver
 }
 
-// TODO
 func (m Method) String() string {
-	params := make([]string, len(m.Params))
-	for i, param := range m.Params {
-		params[i] = param.String()
+	var variadic string
+	if m.IsVariadic {
+		variadic = "..."
 	}
 
-	results := make([]string, len(m.Results))
-	for i, result := range m.Results {
-		results[i] = result.String()
+	sig := fmt.Sprintf("%s(%s%s)", m.Name, typeListString(m.Params), variadic)
+	if len(m.Results) == 1 {
+		sig = fmt.Sprintf("%s %s", sig, typeString(m.Results[0]))
+	} else if len(m.Results) > 1 {
+		sig = fmt.Sprintf("%s (%s)", sig, typeListString(m.Results))
 	}
 
-	var pointer string
+	// methods with a known receiver are printed like a method declaration
+	if m.Receiver != nil {
+		return fmt.Sprintf("func (%s) %s", typeString(m.ReceiverType()), sig)
+	}
 	if m.IsPointer {
-		pointer = "*"
+		return "*" + sig
 	}
-
-	return fmt.Sprintf("%s%s(%s) %s", pointer, m.Name, strings.Join(params, ", "), strings.Join(results, ", "))
+	return sig
 }
 
 type MethodSet map[string]Method
@@ -158,7 +339,10 @@ type PointerType struct {
 }
 
 func (pt *PointerType) String() string {
-	return fmt.Sprintf("*%s", pt.Base.String())
+	if pt == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("*%s", typeString(pt.Base))
 }
 
 var _ Type = (*PointerType)(nil)
@@ -169,19 +353,68 @@ type StructType struct {
 	Fields         map[string]Type
 	Methods        MethodSet
 	GenericMethods map[string]GenericMethod
+
+	// FieldOrder lists the names of Fields in declaration order, which determines the layout.
+	// Without it, the fields are taken in the order of their names.
+	FieldOrder []string
+}
+
+// FieldNames returns the names of the fields of st in declaration order, see FieldOrder.
+func (st *StructType) FieldNames() []string {
+	return fieldNames(st.Fields, st.FieldOrder)
+}
+
+// fieldNames returns order if it lists exactly the fields, and their sorted names otherwise.
+func fieldNames(fields map[string]Type, order []string) []string {
+	if len(order) != len(fields) {
+		return sortedKeys(fields)
+	}
+	for _, name := range order {
+		if _, ok := fields[name]; !ok {
+			return sortedKeys(fields)
+		}
+	}
+	return order
 }
 
 func (st *StructType) String() string {
+	if st == nil {
+		return nilTypeString
+	}
 	return fmt.Sprintf("Struct(%s)", st.Name)
 }
 
+// ExtensibleStruct is an anonymous struct type with at least the given fields.
+// The row variable Rest stands for the fields not listed, so that inference can
+// describe "any struct with a name field" as `ExtensibleStruct(name T | R)`.
+//
+// It is part of the experimental ExtRowPolymorphism extension, and has no Go equivalent.
+type ExtensibleStruct struct {
+	Fields map[string]Type
+	Rest   *TypeVariable
+}
+
+func (es *ExtensibleStruct) String() string {
+	if es == nil {
+		return nilTypeString
+	}
+	fields := make([]string, 0, len(es.Fields))
+	for _, name := range sortedKeys(es.Fields) {
+		fields = append(fields, fmt.Sprintf("%s %s", name, typeString(es.Fields[name])))
+	}
+	return fmt.Sprintf("ExtensibleStruct(%s | %s)", strings.Join(fields, ", "), typeString(es.Rest))
+}
+
 // SliceType represents a slice type
 type SliceType struct {
 	ElementType Type
 }
 
 func (st *SliceType) String() string {
-	return fmt.Sprintf("Slice(%s)", st.ElementType.String())
+	if st == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Slice(%s)", typeString(st.ElementType))
 }
 
 type ArrayType struct {
@@ -190,7 +423,10 @@ type ArrayType struct {
 }
 
 func (at *ArrayType) String() string {
-	return fmt.Sprintf("Arr[%d]%s", at.Len, at.ElementType.String())
+	if at == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Arr[%d]%s", at.Len, typeString(at.ElementType))
 }
 
 // MapType represents a map type
@@ -200,7 +436,38 @@ type MapType struct {
 }
 
 func (mt *MapType) String() string {
-	return fmt.Sprintf("Map[%v]%v", mt.KeyType, mt.ValueType)
+	if mt == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Map[%s]%s", typeString(mt.KeyType), typeString(mt.ValueType))
+}
+
+// ChanDir is the direction of a channel type.
+type ChanDir int
+
+const (
+	SendRecv ChanDir = iota // chan T
+	SendOnly                // chan<- T
+	RecvOnly                // <-chan T
+)
+
+// ChanType represents a channel type, like `chan int` or `<-chan T`.
+type ChanType struct {
+	Dir         ChanDir
+	ElementType Type
+}
+
+func (ct *ChanType) String() string {
+	if ct == nil {
+		return nilTypeString
+	}
+	switch ct.Dir {
+	case SendOnly:
+		return fmt.Sprintf("SendChan(%s)", typeString(ct.ElementType))
+	case RecvOnly:
+		return fmt.Sprintf("RecvChan(%s)", typeString(ct.ElementType))
+	}
+	return fmt.Sprintf("Chan(%s)", typeString(ct.ElementType))
 }
 
 type TypeConstraint struct {
@@ -210,9 +477,17 @@ type TypeConstraint struct {
 	IsComparable      bool   // true if the constraint requires comparable types
 	IsUnderlying      bool   // true if constraint is on the underlying type (e.g., ~int)
 	BuiltinConstraint string // for builtin constraints like "any", "comparable", etc.
+
+	// Excluded lists the types that never satisfy the constraint, even if they
+	// satisfy everything else, like "comparable except string".
+	// This is an experimental extension, there is no Go syntax for it.
+	Excluded []Type
 }
 
 func (tc *TypeConstraint) String() string {
+	if tc == nil {
+		return nilTypeString
+	}
 	var separator string
 	if tc.Union {
 		separator = " | "
@@ -227,37 +502,319 @@ func (tc *TypeConstraint) String() string {
 
 	var types []string
 	for _, t := range tc.Types {
-		types = append(types, t.String())
+		types = append(types, typeString(t))
 	}
 
 	interfacesStr := strings.Join(interfaces, separator)
 	typesStr := strings.Join(types, separator)
 
+	var excluded string
+	if len(tc.Excluded) > 0 {
+		excluded = fmt.Sprintf(" except [%s]", typeListString(tc.Excluded))
+	}
+
 	if len(interfaces) > 0 {
 		if tc.Union {
-			return fmt.Sprintf("Union([%s], [%s])", interfacesStr, typesStr)
+			return fmt.Sprintf("Union([%s], [%s])%s", interfacesStr, typesStr, excluded)
 		}
-		return fmt.Sprintf("Constraint([%s], [%s])", interfacesStr, typesStr)
+		return fmt.Sprintf("Constraint([%s], [%s])%s", interfacesStr, typesStr, excluded)
 	}
 	if tc.Union {
-		return fmt.Sprintf("Union([], [%s])", typesStr)
+		return fmt.Sprintf("Union([], [%s])%s", typesStr, excluded)
+	}
+	return fmt.Sprintf("Constraint([], [%s])%s", typesStr, excluded)
+}
+
+// ApproxType represents an approximation term `~T` in a constraint's type list.
+// It is satisfied by every type whose underlying type is T.
+type ApproxType struct {
+	Base Type
+}
+
+func (at *ApproxType) String() string {
+	if at == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("~%s", typeString(at.Base))
+}
+
+// TypeParam is a single type parameter of a generic declaration.
+type TypeParam struct {
+	Name       string
+	Constraint *TypeConstraint // nil if the parameter is unconstrained
+	Default    Type            // nil if the parameter has no default
+}
+
+// TypeParamList is the ordered type parameter list of a generic declaration,
+// like `[K comparable, V any]`.
+type TypeParamList []TypeParam
+
+// Vars returns the type variables of the parameters, in declaration order.
+func (l TypeParamList) Vars() []Type {
+	vars := make([]Type, len(l))
+	for i, p := range l {
+		vars[i] = &TypeVariable{Name: p.Name}
+	}
+	return vars
+}
+
+// Lookup returns the index of the parameter with the given name.
+func (l TypeParamList) Lookup(name string) (int, bool) {
+	for i, p := range l {
+		if p.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// name returns the name of the i-th parameter for diagnostics.
+// Parameters whose name is unknown are referred to by their position.
+func (l TypeParamList) name(i int) string {
+	if l[i].Name == "" {
+		return fmt.Sprintf("#%d", i+1)
+	}
+	return l[i].Name
+}
+
+// names returns the comma-separated names of the parameters, like "K, V".
+func (l TypeParamList) names() string {
+	names := make([]string, len(l))
+	for i := range l {
+		names[i] = l.name(i)
+	}
+	return strings.Join(names, ", ")
+}
+
+// hasDefaultsFrom reports whether every parameter from the i-th on has a default.
+func (l TypeParamList) hasDefaultsFrom(i int) bool {
+	for ; i < len(l); i++ {
+		if l[i].Default == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// defaults returns the defaults of the parameters from the i-th on, as type arguments.
+func (l TypeParamList) defaults(i int) []interface{} {
+	args := make([]interface{}, 0, len(l)-i)
+	for ; i < len(l); i++ {
+		args = append(args, l[i].Default)
 	}
-	return fmt.Sprintf("Constraint([], [%s])", typesStr)
+	return args
+}
+
+// Validate checks the declared type parameters, reporting constraints that no type can
+// satisfy, like `interface{ integer; string }`. Such declarations can never be instantiated,
+// so they are rejected where they are declared: BuildEnv validates the type parameter lists
+// it declares, and the callers of NewGenericType should too.
+func (l TypeParamList) Validate() error {
+	for i, p := range l {
+		// defaults only apply to omitted trailing arguments
+		if p.Default == nil && i > 0 && l[i-1].Default != nil {
+			return fmt.Errorf("type parameter %s without default follows type parameter %s with default", l.name(i), l.name(i-1))
+		}
+		// a constraint referring to the other parameters, like `~[]E`, has a type set
+		// only once they are bound
+		if p.Constraint == nil || len(l.constraintParams(p.Constraint, -1)) > 0 {
+			continue
+		}
+		if err := checkSatisfiable(*p.Constraint); err != nil {
+			return fmt.Errorf("type parameter %s: %w", l.name(i), err)
+		}
+	}
+	return nil
+}
+
+func (l TypeParamList) String() string {
+	params := make([]string, len(l))
+	for i, p := range l {
+		params[i] = p.Name
+		if p.Constraint != nil {
+			params[i] += " " + p.Constraint.String()
+		}
+		if p.Default != nil {
+			params[i] += " = " + p.Default.String()
+		}
+	}
+	return fmt.Sprintf("[%s]", strings.Join(params, ", "))
 }
 
 // GenericType represents a generic type with type parameters.
+//
+// Params is the declaration of the type parameters: their names, constraints and
+// defaults. It is the same for a declaration and all its instances, and should be
+// accessed through TypeParamList. TypeParams holds what the parameters are bound to:
+// the type variables of Params for a declaration, or the type arguments of an instance.
+//
+// Generic functions, like `func Map[T, U any](xs []T, f func(T) U) []U`, are generic
+// types with a Signature and no fields. They can be called once all their type
+// parameters are instantiated.
 type GenericType struct {
-	Name        string
-	TypeParams  []Type
+	Name       string
+	TypeParams []Type
+
+	// Constraints maps the names of the type parameters to their constraints.
+	//
+	// Deprecated: the constraints are those of Params, see TypeParamList. Constraints
+	// is only read to derive the parameters of generic types built without Params.
 	Constraints map[string]TypeConstraint
+
 	Fields      map[string]Type
 	Methods     MethodSet
+	Params      TypeParamList
+	Signature   *FunctionType
+	IsInterface bool      // true for generic interfaces, whose Methods are the interface methods
+	Pos         token.Pos // position of the declaration, if known
+	FieldOrder  []string  // names of Fields in declaration order, see StructType
+
+	// TypeSet is the constraint denoted by the instances of a generic constraint, like
+	// `~map[K]struct{}` for `type Set[K comparable] interface{ ~map[K]struct{} }`.
+	TypeSet *TypeConstraint
+
+	// UnderlyingType is the underlying type of the generic types that are neither structs,
+	// interfaces nor functions, like `[]T` for `type List[T any] []T`.
+	UnderlyingType Type
+
+	origin   *Instantiation       // nil for declarations
+	matchers []*constraintMatcher // compiled constraints of Params, see compileConstraints
+}
+
+// NewGenericType creates a generic type declaration with the given parameter list.
+func NewGenericType(name string, params TypeParamList, fields map[string]Type, methods MethodSet) *GenericType {
+	gt := &GenericType{
+		Name:       name,
+		TypeParams: params.Vars(),
+		Fields:     fields,
+		Methods:    methods,
+		Params:     params,
+	}
+	gt.compileConstraints()
+	return gt
+}
+
+// NewGenericFunction creates a generic function declaration with the given parameter list and signature.
+func NewGenericFunction(name string, params TypeParamList, signature *FunctionType) *GenericType {
+	gt := NewGenericType(name, params, nil, nil)
+	gt.Signature = signature
+	return gt
+}
+
+// TypeParamList returns the declared type parameters of the generic type.
+// For types built without Params, the list is derived from TypeParams and Constraints;
+// parameters that are no longer type variables are left unnamed and unconstrained.
+func (gt *GenericType) TypeParamList() TypeParamList {
+	if gt.Params != nil {
+		return gt.Params
+	}
+	params := make(TypeParamList, len(gt.TypeParams))
+	for i, tp := range gt.TypeParams {
+		tv, ok := tp.(*TypeVariable)
+		if !ok {
+			continue
+		}
+		params[i].Name = tv.Name
+		if c, ok := gt.Constraints[tv.Name]; ok {
+			params[i].Constraint = &c
+		}
+	}
+	return params
+}
+
+// Underlying returns the concrete form of the generic type with its current type arguments:
+// the signature of a generic function, an InterfaceType for generic interfaces,
+// the UnderlyingType if any, and a StructType holding the fields and methods otherwise.
+// The interface or struct is named after the instance, like `List[TypeConst(int)]`.
+func (gt *GenericType) Underlying() Type {
+	if gt.Signature != nil {
+		return gt.Signature
+	}
+	if gt.UnderlyingType != nil {
+		return gt.UnderlyingType
+	}
+	name := fmt.Sprintf("%s[%s]", gt.Name, typeListString(gt.TypeParams))
+	if gt.IsInterface {
+		return &InterfaceType{Name: name, Methods: gt.Methods}
+	}
+	return &StructType{Name: name, Fields: gt.Fields, Methods: gt.Methods, FieldOrder: gt.FieldOrder}
+}
+
+// isStruct reports whether gt is a generic struct type, rather than a generic function,
+// interface or type with another UnderlyingType.
+func (gt *GenericType) isStruct() bool {
+	return gt.Signature == nil && !gt.IsInterface && gt.UnderlyingType == nil
+}
+
+// named returns the generic type defined by another type as a defined type named after
+// the instance, like `List[int]` for `type List[T any] []T`, with the methods of gt.
+func (gt *GenericType) named() *NamedType {
+	return &NamedType{Name: FormatGo(gt), Underlying: gt.UnderlyingType, Methods: gt.Methods}
+}
+
+// Origin returns how the generic type was instantiated, or nil if it is a declaration.
+// Instances of instances, like a partially instantiated type that is instantiated again,
+// refer to the original declaration.
+func (gt *GenericType) Origin() *Instantiation {
+	return gt.origin
+}
+
+// isOpen reports whether the i-th type parameter of gt is left uninstantiated: the type
+// parameters of a declaration are, and so are those left out of a partial instantiation,
+// like T in `Map[int]` for `func Map[F, T any](s []F, f func(F) T) []T`. The type arguments
+// of an instance may themselves be type parameters, like U in `Id[U]` in the body of a
+// generic function; they are instantiated all the same.
+func (gt *GenericType) isOpen(i int) bool {
+	if gt.origin == nil {
+		// declarations built by hand may have some parameters already instantiated
+		_, ok := gt.TypeParams[i].(*TypeVariable)
+		return ok
+	}
+	return gt.origin.open != nil && gt.origin.open[i]
+}
+
+// Instantiation records the provenance of an instantiated generic type.
+type Instantiation struct {
+	Decl *GenericType // the generic declaration, like `Stack[T any]`
+	Args []Type       // the type arguments, in the order of the declared parameters
+	Pos  token.Pos    // position of the instantiation, if known
+
+	open []bool // the parameters left out of a partial instantiation, like T in `Map[int]`
+}
+
+// Mapping returns the type argument of each declared type parameter, like `T → int`.
+func (in *Instantiation) Mapping() map[string]Type {
+	params := in.Decl.TypeParamList()
+	mapping := make(map[string]Type, len(in.Args))
+	for i, arg := range in.Args {
+		mapping[params.name(i)] = arg
+	}
+	return mapping
+}
+
+// Format describes the instantiation for display, like
+// "Stack[TypeConst(int)] instantiated at foo.go:10 from Stack[T]".
+// Positions are resolved with fset, and left out if fset is nil or they are unknown.
+func (in *Instantiation) Format(fset *token.FileSet) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s[%s] instantiated", in.Decl.Name, typeListString(in.Args))
+	if fset != nil && in.Pos.IsValid() {
+		fmt.Fprintf(&sb, " at %v", fset.Position(in.Pos))
+	}
+	fmt.Fprintf(&sb, " from %s%v", in.Decl.Name, in.Decl.TypeParamList())
+	if fset != nil && in.Decl.Pos.IsValid() {
+		fmt.Fprintf(&sb, " declared at %v", fset.Position(in.Decl.Pos))
+	}
+	return sb.String()
 }
 
 func (gt *GenericType) String() string {
+	if gt == nil {
+		return nilTypeString
+	}
 	params := make([]string, len(gt.TypeParams))
 	for i, param := range gt.TypeParams {
-		params[i] = param.String()
+		params[i] = typeString(param)
 	}
 	return fmt.Sprintf("Generic(%s, %v)", gt.Name, params)
 }
@@ -271,16 +828,25 @@ type GenericMethod struct {
 
 // TODO
 func (gm *GenericMethod) String() string {
+	if gm == nil {
+		return nilTypeString
+	}
 	params := make([]string, len(gm.TypeParams))
 	for i, param := range gm.TypeParams {
-		params[i] = param.String()
+		params[i] = typeString(param)
 	}
 	return fmt.Sprintf("GenericMethod(%s, %v)", gm.Name, params)
 }
 
 // TypeVisitor is a visitor that tracks visited types to prevent infinite recursion.
+// Types are tracked by their canonical identity (see typeKey), so that a freshly
+// constructed copy of a type on the current path is also reported as visited.
 type TypeVisitor struct {
 	visited map[string]bool
+	arena   *Arena // allocates the types built while visiting, if set
+
+	// done holds the results of the declared types already substituted, by identity.
+	done map[Type]Type
 }
 
 func NewTypeVisitor() *TypeVisitor {
@@ -289,7 +855,7 @@ func NewTypeVisitor() *TypeVisitor {
 
 // Visit marks the given type as visited and returns true if it has been visited before.
 func (v *TypeVisitor) Visit(t Type) bool {
-	key := fmt.Sprintf("%p", t)
+	key := typeKey(t)
 	if v.visited[key] {
 		return true
 	}
@@ -297,6 +863,74 @@ func (v *TypeVisitor) Visit(t Type) bool {
 	return false
 }
 
+// Leave unmarks the given type once it has been fully traversed,
+// so that only types on the current path are reported as visited.
+// This lets the same type appear in several places without being mistaken for a cycle.
+func (v *TypeVisitor) Leave(t Type) {
+	delete(v.visited, typeKey(t))
+}
+
+// substituted returns the substitution of t if it was already made with this visitor.
+func (v *TypeVisitor) substituted(t Type) (Type, bool) {
+	if !isDeclared(t) {
+		return nil, false
+	}
+	done, ok := v.done[t]
+	return done, ok
+}
+
+// remember records result as the substitution of t.
+func (v *TypeVisitor) remember(t, result Type) {
+	if !isDeclared(t) {
+		return
+	}
+	if v.done == nil {
+		v.done = make(map[Type]Type)
+	}
+	v.done[t] = result
+}
+
+// isDeclared reports whether t is a declared type, shared by the types referring to it.
+// Those are the types worth substituting once; the others are cheap to rebuild.
+func isDeclared(t Type) bool {
+	switch t := t.(type) {
+	case *StructType:
+		return t != nil && t.Name != ""
+	case *InterfaceType:
+		return t != nil && t.Name != ""
+	case *NamedType, *GenericType:
+		return true
+	}
+	return false
+}
+
+// typeKey returns the canonical identity of a type.
+// Named types are identified by their kind and name (plus type arguments for generics),
+// and composite types by their structure, which always ends in named types.
+// Anonymous structs and interfaces have no name to go by, so they fall back to the pointer.
+func typeKey(t Type) string {
+	switch t := t.(type) {
+	case *StructType:
+		if t != nil && t.Name == "" {
+			return fmt.Sprintf("%T@%p", t, t)
+		}
+	case *InterfaceType:
+		if t != nil && t.Name == "" && !t.IsEmpty {
+			return fmt.Sprintf("%T@%p", t, t)
+		}
+	case *ExtensibleStruct:
+		if t != nil {
+			return fmt.Sprintf("%T@%p", t, t)
+		}
+	case *NamedType:
+		// its string holds the underlying type, which may refer to it, like `type L []L`
+		if t != nil {
+			return fmt.Sprintf("%T:%s", t, t.Name)
+		}
+	}
+	return fmt.Sprintf("%T:%s", t, typeString(t))
+}
+
 // TypeAlias provides a new name for an existing type.
 type TypeAlias struct {
 	Name      string
@@ -304,7 +938,32 @@ type TypeAlias struct {
 }
 
 func (ta *TypeAlias) String() string {
-	return fmt.Sprintf("TypeAlias(%s = %s)", ta.Name, ta.AliasedTo.String())
+	if ta == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("TypeAlias(%s = %s)", ta.Name, typeString(ta.AliasedTo))
+}
+
+// PackageType is an imported package, bound to its name in the environment of the importing
+// file, like `ast` for "go/ast". Its Members hold the package-level declarations of the
+// package; only the exported ones can be selected, like `ast.Expr`.
+type PackageType struct {
+	Path    string // the import path, like "go/ast"
+	Name    string // the package name, like "ast"
+	Members TypeEnv
+}
+
+// NewPackageType creates the package at path, named after the last element of the path,
+// whose package-level declarations are env, like the result of BuildEnv.
+func NewPackageType(path string, env TypeEnv) *PackageType {
+	return &PackageType{Path: path, Name: path[strings.LastIndex(path, "/")+1:], Members: env}
+}
+
+func (pt *PackageType) String() string {
+	if pt == nil {
+		return nilTypeString
+	}
+	return fmt.Sprintf("Package(%s)", pt.Path)
 }
 
 // TypeEnv store and manage type variables and their types.
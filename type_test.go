@@ -77,3 +77,222 @@ func TestStringMethods(t *testing.T) {
 		})
 	}
 }
+
+func TestStringMethodsIncompleteTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      fmt.Stringer
+		expected string
+	}{
+		{"function without results", &FunctionType{ParamTypes: []Type{&TypeConstant{Name: "int"}}}, "func(TypeConst(int))"},
+		{"function with nil param", &FunctionType{ParamTypes: []Type{nil}}, "func(<nil>)"},
+		{"method without results", Method{Name: "Close"}, "Close()"},
+		{"pointer without base", &PointerType{}, "*<nil>"},
+		{"slice without element", &SliceType{}, "Slice(<nil>)"},
+		{"array without element", &ArrayType{Len: 3}, "Arr[3]<nil>"},
+		{"map without key and value", &MapType{}, "Map[<nil>]<nil>"},
+		{"tuple with nil element", &TupleType{Types: []Type{&TypeConstant{Name: "int"}, nil}}, "(TypeConst(int), <nil>)"},
+		{"alias without target", &TypeAlias{Name: "A"}, "TypeAlias(A = <nil>)"},
+		{"nil function", (*FunctionType)(nil), "<nil>"},
+		{"nil struct", (*StructType)(nil), "<nil>"},
+		{"pointer to nil struct", &PointerType{Base: (*StructType)(nil)}, "*<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.typ.String()
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMethodStringWithReceiver(t *testing.T) {
+	st := &StructType{Name: "Person"}
+	tests := []struct {
+		method   Method
+		expected string
+	}{
+		{Method{Name: "Name", Receiver: st, Results: []Type{&TypeConstant{Name: "string"}}}, "func (Struct(Person)) Name() TypeConst(string)"},
+		{Method{Name: "SetName", Receiver: st, Params: []Type{&TypeConstant{Name: "string"}}, IsPointer: true}, "func (*Struct(Person)) SetName(TypeConst(string))"},
+		{Method{Name: "Read", Results: []Type{&TypeConstant{Name: "int"}, &TypeConstant{Name: "error"}}}, "Read() (TypeConst(int), TypeConst(error))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method.Name, func(t *testing.T) {
+			if result := tt.method.String(); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTypeParamList(t *testing.T) {
+	comparable := &TypeConstraint{BuiltinConstraint: ConstraintComparable}
+	params := TypeParamList{
+		{Name: "K", Constraint: comparable},
+		{Name: "V", Default: &TypeConstant{Name: "int"}},
+	}
+	gt := NewGenericType("Map", params, nil, nil)
+
+	if got, want := params.String(), "[K Constraint([], []), V = TypeConst(int)]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if i, ok := params.Lookup("V"); !ok || i != 1 {
+		t.Errorf("Lookup(V) = %d, %v, want 1, true", i, ok)
+	}
+	if _, ok := params.Lookup("T"); ok {
+		t.Errorf("Lookup(T) found unknown parameter")
+	}
+	if !TypesEqual(gt, &GenericType{Name: "Map", TypeParams: []Type{&TypeVariable{Name: "K"}, &TypeVariable{Name: "V"}}}) {
+		t.Errorf("NewGenericType() = %v", gt)
+	}
+	// the constraints are those of the declared parameters, which the instances share
+	if gt.Constraints != nil {
+		t.Errorf("NewGenericType() Constraints = %v, want them in Params only", gt.Constraints)
+	}
+	inst, err := InstantiateGenericType(gt, []interface{}{&TypeConstant{Name: "string"}, &TypeConstant{Name: "int"}}, TypeEnv{}, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+	if got := inst.(*GenericType).TypeParamList(); got[0].Constraint != comparable {
+		t.Errorf("TypeParamList() of instance = %v, want the constraint of K", got)
+	}
+
+	// the parameter list is derived for generic types built without one
+	legacy := &GenericType{
+		Name:        "Pair",
+		TypeParams:  []Type{&TypeVariable{Name: "A"}, &TypeConstant{Name: "string"}},
+		Constraints: map[string]TypeConstraint{"A": *comparable},
+	}
+	derived := legacy.TypeParamList()
+	if len(derived) != 2 || derived[0].Name != "A" || derived[0].Constraint == nil {
+		t.Errorf("TypeParamList() = %v", derived)
+	}
+	if derived[1].Name != "" || derived[1].Constraint != nil {
+		t.Errorf("TypeParamList() for substituted parameter = %+v, want empty", derived[1])
+	}
+}
+
+func TestTypeVisitor(t *testing.T) {
+	v := NewTypeVisitor()
+	anon := &StructType{Fields: map[string]Type{"x": &TypeConstant{Name: "int"}}}
+
+	if v.Visit(&SliceType{ElementType: &TypeConstant{Name: "int"}}) {
+		t.Fatalf("first visit reported as visited")
+	}
+	if !v.Visit(&SliceType{ElementType: &TypeConstant{Name: "int"}}) {
+		t.Errorf("identical slice type not reported as visited")
+	}
+	if v.Visit(&SliceType{ElementType: &TypeConstant{Name: "string"}}) {
+		t.Errorf("different slice type reported as visited")
+	}
+	if v.Visit(&TypeConstant{Name: "int"}) || v.Visit(&TypeVariable{Name: "int"}) {
+		t.Errorf("types of different kinds with the same name reported as visited")
+	}
+	v.Visit(&GenericType{Name: "List", TypeParams: []Type{&TypeConstant{Name: "int"}}})
+	if !v.Visit(&GenericType{Name: "List", TypeParams: []Type{&TypeConstant{Name: "int"}}}) {
+		t.Errorf("identical generic instance not reported as visited")
+	}
+	if v.Visit(&GenericType{Name: "List", TypeParams: []Type{&TypeConstant{Name: "string"}}}) {
+		t.Errorf("different generic instance reported as visited")
+	}
+
+	// anonymous structs have no name, so only the same value counts
+	if v.Visit(anon) || v.Visit(&StructType{Fields: map[string]Type{"y": &TypeConstant{Name: "int"}}}) {
+		t.Errorf("anonymous struct reported as visited")
+	}
+	if !v.Visit(anon) {
+		t.Errorf("anonymous struct not reported as visited on second visit")
+	}
+
+	v.Leave(&SliceType{ElementType: &TypeConstant{Name: "int"}})
+	if v.Visit(&SliceType{ElementType: &TypeConstant{Name: "int"}}) {
+		t.Errorf("slice type reported as visited after Leave")
+	}
+}
+
+// TestTypeKindsHandled checks that every registered kind of type is handled by
+// the operations that switch over types. Add a sample here when registering a new kind.
+func TestTypeKindsHandled(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	intType := &TypeConstant{Name: "int"}
+	method := Method{Name: "Get", Params: []Type{intType}, Results: []Type{tv}}
+
+	// samples that contain T
+	samples := []Type{
+		tv,
+		&FunctionType{ParamTypes: []Type{tv}, ReturnType: intType},
+		&TupleType{Types: []Type{intType, tv}},
+		&Interface{Name: "Getter", Methods: MethodSet{"Get": method}},
+		&InterfaceType{Name: "Getter", Methods: MethodSet{"Get": method}},
+		method,
+		&PointerType{Base: tv},
+		&StructType{Fields: map[string]Type{"value": tv}},
+		&ExtensibleStruct{Fields: map[string]Type{"value": tv}, Rest: &TypeVariable{Name: "R"}},
+		&SliceType{ElementType: tv},
+		&ArrayType{ElementType: tv, Len: 3},
+		&MapType{KeyType: intType, ValueType: tv},
+		&ChanType{Dir: RecvOnly, ElementType: tv},
+		&TypeConstraint{Types: []Type{intType, tv}, Union: true},
+		&ApproxType{Base: tv},
+		&GenericType{Name: "List", TypeParams: []Type{tv}, Fields: map[string]Type{"items": &SliceType{ElementType: tv}}},
+		&GenericMethod{Name: "Map", TypeParams: []Type{&TypeVariable{Name: "U"}}, Method: method},
+		&TypeAlias{Name: "Elem", AliasedTo: tv},
+		&VarObj{Name: "x", Type: tv},
+		&ConstObj{Name: "k", Type: tv},
+		&TypeObj{Name: "Elem", Type: tv},
+		&FuncObj{Name: "f", Type: &FunctionType{ParamTypes: []Type{tv}, ReturnType: intType}},
+	}
+	// samples without T
+	leaves := []Type{
+		intType,
+		&NamedType{Name: "Age", Underlying: intType},
+		&NoValueType{},
+		Nil,
+		NewPackageType("go/ast", TypeEnv{"Expr": &InterfaceType{Name: "Expr"}}),
+	}
+
+	covered := make(map[string]bool)
+	for _, s := range append(samples, leaves...) {
+		covered[fmt.Sprintf("%T", s)] = true
+	}
+	for _, kind := range typeKinds {
+		if !covered[fmt.Sprintf("%T", kind)] {
+			t.Errorf("no sample for registered kind %T", kind)
+		}
+	}
+
+	containsT := func(typ Type) bool {
+		return len(Inspect(typ, func(t Type) bool { return TypesEqual(t, tv) })) > 0
+	}
+
+	for i, s := range append(samples, leaves...) {
+		hasT := i < len(samples)
+		t.Run(fmt.Sprintf("%T", s), func(t *testing.T) {
+			if str := s.String(); str == "" || str == nilTypeString {
+				t.Errorf("String() = %q", str)
+			}
+			if !TypesEqual(s, s) {
+				t.Errorf("TypesEqual() = false for identical types")
+			}
+			if _, err := Unify(s, s, make(TypeEnv)); err != nil {
+				t.Errorf("Unify() error = %v", err)
+			}
+			if containsT(s) != hasT {
+				t.Errorf("Walk() found T = %v, want %v", !hasT, hasT)
+			}
+			substituted := substituteTypeParams(s, []Type{tv}, []Type{intType}, NewTypeVisitor())
+			if containsT(substituted) {
+				t.Errorf("substituteTypeParams() = %v, still contains T", substituted)
+			}
+			if containsT(s) != hasT {
+				t.Errorf("substituteTypeParams() modified the original type")
+			}
+			if mapped := Map(s, func(t Type) Type { return t }); !TypesEqual(mapped, s) {
+				t.Errorf("Map() with identity = %v, want %v", mapped, s)
+			}
+		})
+	}
+}
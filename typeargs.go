@@ -0,0 +1,420 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+// needsTypeArgs reports whether some type parameters of the generic function gt are left
+// uninstantiated, like T in `Identity(x)` for `func Identity[T any](x T) T`.
+func needsTypeArgs(gt *GenericType) bool {
+	for i := range gt.TypeParams {
+		if gt.isOpen(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// renameOpen alpha-renames the type parameters of the generic function gt left
+// uninstantiated in its signature, like TypeVarFactory.Rename. It returns the renamed
+// signature, the type arguments of gt with the fresh variables in place of those, and the
+// fresh variables. The type arguments of a partial instantiation are kept as they are,
+// even if they are type parameters of the enclosing declaration, like T in `Map[T]`.
+func renameOpen(gt *GenericType) (*FunctionType, []Type, []*TypeVariable) {
+	args := make([]Type, len(gt.TypeParams))
+	var vars []*TypeVariable
+	for i, tp := range gt.TypeParams {
+		args[i] = tp
+		if tv, ok := tp.(*TypeVariable); ok && gt.isOpen(i) {
+			fresh := typeVars.Fresh(tv.Name)
+			args[i] = fresh
+			vars = append(vars, fresh)
+		}
+	}
+	sig := substituteTypeParams(gt.Underlying(), gt.TypeParams, args, NewTypeVisitor())
+	return sig.(*FunctionType), args, vars
+}
+
+// InferTypeArguments infers the type arguments of the generic function called by call,
+// like `[int, string]` for `Map(xs, func(x int) string { ... })` with `xs []int` and
+// `func Map[F, T any](s []F, f func(F) T) []T`. The type arguments given explicitly, like
+// int in `Map[int](xs, itoa)`, are returned as they are, and the others are inferred from
+// the arguments of the call, see inferTypeArgs.
+func InferTypeArguments(call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) ([]Type, error) {
+	funcTyp, err := InferType(call.Fun, env, ctx.Child())
+	if err != nil {
+		return nil, err
+	}
+	gt, ok := funcTyp.(*GenericType)
+	if !ok || gt.Signature == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotAGenericType, types.ExprString(call.Fun))
+	}
+	if needsTypeArgs(gt) {
+		if gt, err = inferTypeArgs(gt, call, env, ctx); err != nil {
+			return nil, err
+		}
+	}
+	return append([]Type(nil), gt.TypeParams...), nil
+}
+
+// inferTypeArgs infers the type arguments of gt left uninstantiated from the arguments of
+// call, like T = int in `Equal(1, n)` for `func Equal[T comparable](a, b T) bool` with an
+// int n, and returns the instance of gt they instantiate.
+//
+// The type parameters are solved jointly: every argument is unified with its parameter
+// type in a single substitution, so a type parameter pinned by one argument must match
+// the others. The typed arguments are unified first. The untyped constants, like `1`, must
+// then be representable by the types they meet, and only bind the type parameters no typed
+// argument pinned, to the default type of the constant of the latest kind in int, rune,
+// float64, like float64 for `Max(1, 2.5)`. Likewise, nil pins nothing, and must be a value
+// of the type it meets, like *int in `Equal(p, nil)`. Two arguments inferring different
+// types for the same type parameter are reported with an InferenceConflictError naming both.
+//
+// Function arguments drive the inference through their signatures: a function literal,
+// like `func(x int) string { ... }` for `func(F) T`, pins F = int and T = string, and a
+// generic function passed without type arguments, like `Identity` in `Map(xs, Identity)`,
+// has its own type parameters solved along with those of gt.
+func inferTypeArgs(gt *GenericType, call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) (*GenericType, error) {
+	ft, fresh, vars := renameOpen(gt)
+	params, args, err := inferTypeArgOperands(ft, call, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &typeArgSolver{env: env, ctx: ctx, u: newUnifier(env, ctx), vars: vars, params: params, args: args, pinned: make(map[string]int)}
+	for _, x := range args {
+		if fn, ok := x.typ.(*GenericType); ok && fn.Signature != nil && needsTypeArgs(fn) {
+			var argVars []*TypeVariable
+			x.typ, _, argVars = renameOpen(fn)
+			s.vars = append(s.vars, argVars...)
+		}
+	}
+	if err := s.solve(); err != nil {
+		return nil, err
+	}
+	typeParams := gt.TypeParamList()
+	s.inferCore(typeParams, fresh)
+
+	subst := s.substitution()
+	typeArgs := make([]interface{}, len(gt.TypeParams))
+	for i, tp := range fresh {
+		typeArgs[i] = gt.TypeParams[i]
+		if !gt.isOpen(i) {
+			continue
+		}
+		arg := ApplySubst(tp, subst)
+		if s.unsolved(arg) {
+			return nil, fmt.Errorf("in call to %s, cannot infer %s", gt.Name, typeParams.name(i))
+		}
+		typeArgs[i] = arg
+	}
+	instance, err := instantiateAt(call.Pos(), gt, typeArgs, env, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return instance.(*GenericType), nil
+}
+
+// inferTypeArgOperands infers the arguments of call, a call of a generic function of
+// signature ft, expanding the results of a single call argument, like `f(g())`. It returns
+// them with the parameter types they are passed to: the arguments of a variadic parameter,
+// like `xs ...T`, are each passed to its element type T, unless the slice is passed on as
+// is, like `f(xs...)`.
+func inferTypeArgOperands(ft *FunctionType, call *ast.CallExpr, env TypeEnv, ctx *InferenceContext) ([]Type, []*operand, error) {
+	params := ft.ParamTypes
+	operands := make([]*operand, 0, len(call.Args))
+	for _, arg := range call.Args {
+		if err := checkValueExpr(arg, env); err != nil {
+			return nil, nil, err
+		}
+		t, err := InferType(arg, env, ctx.Child(WithFunctionArg()))
+		if err != nil {
+			return nil, nil, err
+		}
+		if isNoValue(t) {
+			return nil, nil, fmt.Errorf("argument %s: %w", types.ExprString(arg), ErrNoValueUsed)
+		}
+		if tuple, ok := t.(*TupleType); ok && len(call.Args) == 1 && (len(params) >= 2 || ft.IsVariadic) {
+			for _, t := range tuple.Types {
+				operands = append(operands, &operand{expr: arg, typ: t, val: constant.MakeUnknown()})
+			}
+			continue
+		}
+		operands = append(operands, &operand{expr: arg, typ: unalias(t), val: constantOf(arg, env)})
+	}
+	if n := len(params) - 1; ft.IsVariadic && !call.Ellipsis.IsValid() && len(operands) >= n {
+		if slice, ok := params[n].(*SliceType); ok {
+			params = append([]Type(nil), params[:n]...)
+			for range operands[n:] {
+				params = append(params, slice.ElementType)
+			}
+		}
+	}
+	if len(operands) != len(params) {
+		return nil, nil, &gerrors.ArityError{Want: len(params), Got: len(operands)}
+	}
+	return params, operands, nil
+}
+
+// typeArgSolver solves the renamed type parameters vars of a generic function call.
+// The substitution is built by u, and pinned records, for each type parameter, the
+// argument that bound it first.
+type typeArgSolver struct {
+	env    TypeEnv
+	ctx    *InferenceContext
+	u      *unifier
+	vars   []*TypeVariable
+	params []Type
+	args   []*operand
+	pinned map[string]int
+}
+
+func (s *typeArgSolver) solve() error {
+	for i, x := range s.args {
+		if !x.untyped() && !isNil(x.typ) {
+			if err := s.unify(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	// untyped constants of a bare type parameter bind it to the default type of the latest
+	// kind among them, if no typed argument pinned it
+	defaults := make(map[string]int)
+	for i, x := range s.args {
+		if !x.untyped() {
+			continue
+		}
+		tv, ok := resolve(s.params[i], s.u).(*TypeVariable)
+		if !ok || !s.isVar(tv) {
+			if s.unsolved(ApplySubst(s.params[i], s.substitution())) {
+				if err := s.unify(i); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		j, ok := defaults[tv.Name]
+		if !ok {
+			defaults[tv.Name] = i
+			continue
+		}
+		if !compatibleUntyped(s.args[j], x) {
+			return s.conflict(tv, j, s.args[j].kind(), i, x.kind())
+		}
+		if untypedRank(x.typ) > untypedRank(s.args[j].typ) {
+			defaults[tv.Name] = i
+		}
+	}
+	for _, name := range sortedKeys(defaults) {
+		i := defaults[name]
+		s.u.bind(name, s.args[i].typ)
+		s.pinned[name] = i
+	}
+
+	// every untyped constant must be representable by the type it meets, and nil must be
+	// one of its values
+	subst := s.substitution()
+	for i, x := range s.args {
+		if !x.untyped() && !isNil(x.typ) {
+			continue
+		}
+		tv, ok := s.params[i].(*TypeVariable)
+		if !ok || !s.isVar(tv) {
+			continue
+		}
+		first, ok := s.pinned[tv.Name]
+		t := ApplySubst(tv, subst)
+		if !ok || s.unsolved(t) {
+			continue
+		}
+		if isNil(x.typ) && !isNilable(t) {
+			return s.conflict(tv, first, FormatGo(t), i, FormatGo(x.typ))
+		}
+		if x.untyped() && !representable(x.val, t, s.env) {
+			return s.conflict(tv, first, FormatGo(t), i, x.kind())
+		}
+	}
+	return nil
+}
+
+// inferCore infers the type parameters left unsolved by the arguments from the core types
+// of the constraints, fresh being the renamed type parameters of params: a solved type
+// parameter solves those of its core type, like K and V from M = map[string]int for
+// `M ~map[K]V`, and an unsolved one takes its core type, like S = []int from E = int for
+// `S ~[]E, E any`. The constraints are taken in constraintOrder until none solves more.
+// Core types that do not match are left to the constraint checks of the instantiation.
+func (s *typeArgSolver) inferCore(params TypeParamList, fresh []Type) {
+	type core struct {
+		tv     *TypeVariable
+		term   Type
+		approx bool
+	}
+	var cores []core
+	visitor := NewTypeVisitor()
+	for _, i := range params.constraintOrder() {
+		tv, ok := fresh[i].(*TypeVariable)
+		if !ok || params[i].Constraint == nil {
+			continue
+		}
+		c := substituteConstraint(*params[i].Constraint, params.Vars(), fresh, visitor)
+		if term, approx, ok := coreTerm(&c); ok && !occurs(tv, term, s.u) {
+			cores = append(cores, core{tv: tv, term: term, approx: approx})
+		}
+	}
+
+	for progress := true; progress; {
+		progress = false
+		for _, c := range cores {
+			bound, ok := s.u.subst[c.tv.Name]
+			if !ok {
+				s.u.bind(c.tv.Name, c.term)
+				progress = true
+				continue
+			}
+			arg := ApplySubst(bound, s.substitution())
+			if c.approx {
+				arg = underlying(arg)
+			}
+			mark := len(s.u.trail)
+			if err := s.u.try(c.term, arg); err == nil && len(s.u.trail) > mark {
+				progress = true
+			}
+		}
+	}
+}
+
+// unify unifies the argument i with its parameter type, recording the type parameters it
+// pins, or reports the argument that pinned one of them to another type.
+func (s *typeArgSolver) unify(i int) error {
+	param, x := s.params[i], s.args[i]
+	err := s.u.try(param, x.typ)
+	// a defined type meets a type literal by its underlying type, like a MethodSet
+	// passed for a map[K]V
+	if u := underlying(x.typ); err != nil && u != x.typ && isNamed(x.typ) && !isNamed(param) {
+		err = s.u.try(param, u)
+	}
+	if err == nil {
+		// the empty interface unifies with anything, so it binds a bare type parameter itself
+		if tv, ok := resolve(param, s.u).(*TypeVariable); ok && s.isVar(tv) && isInterfaceAny(x.typ) {
+			s.u.bind(tv.Name, x.typ)
+		}
+		for _, tv := range s.vars {
+			if _, bound := s.u.subst[tv.Name]; bound {
+				if _, ok := s.pinned[tv.Name]; !ok {
+					s.pinned[tv.Name] = i
+				}
+			}
+		}
+		return nil
+	}
+
+	// the argument on its own infers another type for a type parameter pinned before
+	if alone, solveErr := unify(param, x.typ, s.env, s.ctx); solveErr == nil {
+		subst := s.substitution()
+		for _, tv := range s.vars {
+			first, ok := s.pinned[tv.Name]
+			if !ok {
+				continue
+			}
+			want, got := ApplySubst(tv, subst), ApplySubst(tv, alone)
+			if !s.unsolved(got) && !TypesEqual(want, got) {
+				return s.conflict(tv, first, FormatGo(want), i, FormatGo(got))
+			}
+		}
+	}
+	return fmt.Errorf("argument type mismatch for arg %d: %w", i+1, err)
+}
+
+// conflict reports the arguments i and j inferring the types ti and tj for tv.
+func (s *typeArgSolver) conflict(tv *TypeVariable, i int, ti string, j int, tj string) error {
+	return &gerrors.InferenceConflictError{
+		Param: baseName(tv.Name),
+		Args:  [2]int{i, j},
+		Exprs: [2]string{types.ExprString(s.args[i].expr), types.ExprString(s.args[j].expr)},
+		Types: [2]string{ti, tj},
+	}
+}
+
+// substitution returns the bindings the arguments solved, on top of the environment.
+func (s *typeArgSolver) substitution() Substitution {
+	return s.u.subst
+}
+
+func (s *typeArgSolver) isVar(tv *TypeVariable) bool {
+	for _, v := range s.vars {
+		if v.Name == tv.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// unsolved reports whether t still contains one of the type parameters being solved.
+func (s *typeArgSolver) unsolved(t Type) bool {
+	return len(Inspect(t, func(t Type) bool {
+		tv, ok := t.(*TypeVariable)
+		return ok && s.isVar(tv)
+	})) > 0
+}
+
+// compatibleUntyped reports whether the untyped constants x and y can have the same
+// default type: both are strings, booleans or numbers.
+func compatibleUntyped(x, y *operand) bool {
+	kind := func(x *operand) int {
+		switch x.val.Kind() {
+		case constant.String:
+			return 1
+		case constant.Bool:
+			return 2
+		}
+		return 0
+	}
+	return kind(x) == kind(y)
+}
+
+// inferFuncArgInstance instantiates the generic function gt, passed without type arguments
+// as an argument of parameter type param, like `Identity` for `func(int) int`, with the
+// type arguments param infers.
+func inferFuncArgInstance(arg ast.Expr, gt *GenericType, param Type, env TypeEnv, ctx *InferenceContext) (Type, error) {
+	sig, fresh, vars := renameOpen(gt)
+	subst, err := unify(param, sig, env, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use generic function %s as %s value: %w", types.ExprString(arg), FormatGo(param), err)
+	}
+	params := gt.TypeParamList()
+	typeArgs := make([]interface{}, len(fresh))
+	for i, tp := range fresh {
+		typeArgs[i] = gt.TypeParams[i]
+		if !gt.isOpen(i) {
+			continue
+		}
+		typeArg := ApplySubst(tp, subst)
+		if mentionsTypeVars(typeArg, vars) {
+			return nil, fmt.Errorf("cannot use generic function %s without instantiation: cannot infer %s", gt.Name, params.name(i))
+		}
+		typeArgs[i] = typeArg
+	}
+	return instantiateAt(arg.Pos(), gt, typeArgs, env, ctx)
+}
+
+// mentionsTypeVars reports whether t contains one of the type variables in vars.
+func mentionsTypeVars(t Type, vars []*TypeVariable) bool {
+	return len(Inspect(t, func(t Type) bool {
+		tv, ok := t.(*TypeVariable)
+		if !ok {
+			return false
+		}
+		for _, v := range vars {
+			if v.Name == tv.Name {
+				return true
+			}
+		}
+		return false
+	})) > 0
+}
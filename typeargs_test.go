@@ -0,0 +1,226 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+func TestInferTypeArgs(t *testing.T) {
+	tv, uv := &TypeVariable{Name: "T"}, &TypeVariable{Name: "U"}
+	env := TypeEnv{
+		// func Equal[T comparable](a, b T) bool
+		"Equal": NewGenericFunction("Equal", TypeParamList{{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}}},
+			&FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: Bool}),
+		// func Max[T ~int | ~float64](a, b T) T
+		"Max": NewGenericFunction("Max", TypeParamList{{Name: "T", Constraint: &TypeConstraint{Types: []Type{&ApproxType{Base: Int}, &ApproxType{Base: Float64}}, Union: true}}},
+			&FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: tv}),
+		// func Index[T comparable](xs []T, x T) int
+		"Index": NewGenericFunction("Index", TypeParamList{{Name: "T", Constraint: &TypeConstraint{BuiltinConstraint: ConstraintComparable}}},
+			&FunctionType{ParamTypes: []Type{&SliceType{ElementType: tv}, tv}, ReturnType: Int}),
+		// func Zip[T, U any](ts []T, us []U) map[T]U
+		"Zip": NewGenericFunction("Zip", TypeParamList{{Name: "T", Constraint: Any}, {Name: "U", Constraint: Any}},
+			&FunctionType{ParamTypes: []Type{&SliceType{ElementType: tv}, &SliceType{ElementType: uv}}, ReturnType: &MapType{KeyType: tv, ValueType: uv}}),
+		// func Zero[T any]() T
+		"Zero": NewGenericFunction("Zero", TypeParamList{{Name: "T", Constraint: Any}},
+			&FunctionType{ReturnType: tv}),
+		// func Sum[T ~int | ~float64](xs ...T) T
+		"Sum": NewGenericFunction("Sum", TypeParamList{{Name: "T", Constraint: &TypeConstraint{Types: []Type{&ApproxType{Base: Int}, &ApproxType{Base: Float64}}, Union: true}}},
+			&FunctionType{ParamTypes: []Type{&SliceType{ElementType: tv}}, ReturnType: tv, IsVariadic: true}),
+		// func Tagged[T any](tag string, xs ...T) []T
+		"Tagged": NewGenericFunction("Tagged", TypeParamList{{Name: "T", Constraint: Any}},
+			&FunctionType{ParamTypes: []Type{String, &SliceType{ElementType: tv}}, ReturnType: &SliceType{ElementType: tv}, IsVariadic: true}),
+		"n":  &VarObj{Name: "n", Type: Int},
+		"f":  &VarObj{Name: "f", Type: Float64},
+		"s":  &VarObj{Name: "s", Type: String},
+		"xs": &VarObj{Name: "xs", Type: &SliceType{ElementType: Int}},
+		"ss": &VarObj{Name: "ss", Type: &SliceType{ElementType: String}},
+	}
+
+	tests := []struct {
+		src      string
+		want     string
+		wantErr  error
+		conflict *gerrors.InferenceConflictError
+	}{
+		{src: "Equal(n, n)", want: "bool"},
+		{src: "Equal(1, n)", want: "bool"},
+		{src: "Equal(n, 1)", want: "bool"},
+		{src: "Equal(s, `a`)", want: "bool"},
+		{src: "Max(1, 2)", want: "int"},
+		{src: "Max(1, 2.5)", want: "float64"},
+		{src: "Max(f, 1)", want: "float64"},
+		{src: "Index(xs, 1)", want: "int"},
+		{src: "Zip(xs, ss)", want: "map[int]string"},
+		{src: "Sum(1, 2)", want: "int"},
+		{src: "Sum(1, f)", want: "float64"},
+		{src: "Sum(xs...)", want: "int"},
+		{src: `Tagged("a", 1, 2)`, want: "[]int"},
+		{src: `Tagged("a", ss...)`, want: "[]string"},
+		{
+			src:      "Equal(n, s)",
+			conflict: &gerrors.InferenceConflictError{Param: "T", Args: [2]int{0, 1}, Exprs: [2]string{"n", "s"}, Types: [2]string{"int", "string"}},
+		},
+		{
+			src:      "Index(ss, n)",
+			conflict: &gerrors.InferenceConflictError{Param: "T", Args: [2]int{0, 1}, Exprs: [2]string{"ss", "n"}, Types: [2]string{"string", "int"}},
+		},
+		{
+			src:      "Equal(2.5, n)",
+			conflict: &gerrors.InferenceConflictError{Param: "T", Args: [2]int{1, 0}, Exprs: [2]string{"n", "2.5"}, Types: [2]string{"int", "untyped float"}},
+		},
+		{
+			src:      `Equal(1, "a")`,
+			conflict: &gerrors.InferenceConflictError{Param: "T", Args: [2]int{0, 1}, Exprs: [2]string{"1", `"a"`}, Types: [2]string{"untyped int", "untyped string"}},
+		},
+		{src: "Max(s, s)", wantErr: ErrConstraintNotSatisfied},
+		{src: "Index(n, n)", wantErr: ErrTypeMismatch},
+		{src: "Equal(n)", wantErr: ErrArityMismatch},
+		{src: "Zero()", wantErr: errors.New("in call to Zero, cannot infer T")},
+		{src: `Tagged("a")`, wantErr: errors.New("in call to Tagged, cannot infer T")},
+		{src: "Sum(s)", wantErr: ErrConstraintNotSatisfied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			switch {
+			case tt.conflict != nil:
+				var ce *gerrors.InferenceConflictError
+				if !errors.As(err, &ce) || *ce != *tt.conflict {
+					t.Fatalf("InferType() error = %v, want %v", err, tt.conflict)
+				}
+				return
+			case tt.wantErr != nil:
+				if err == nil || !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error() {
+					t.Fatalf("InferType() = %v, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestInferTypeArgsFromFunctions(t *testing.T) {
+	env := mustBuildEnv(t, `package p
+
+func Map[F, T any](s []F, f func(F) T) []T { return nil }
+func Filter[T any](s []T, keep func(T) bool) []T { return s }
+func Reduce[T, A any](s []T, init A, f func(A, T) A) A { return init }
+func Call[T any](f func() T) T { var t T; return t }
+func Identity[T any](x T) T { return x }
+func Double[T ~int](x T) T { return x }
+func Zero[T any]() T { var t T; return t }
+func itoa(x int) string { return "" }
+
+var (
+	xs []int
+	ss []string
+)
+`)
+
+	tests := []struct {
+		src      string
+		want     string
+		wantErr  error
+		conflict *gerrors.InferenceConflictError
+	}{
+		{src: `Map(xs, func(x int) string { return "" })`, want: "[]string"},
+		{src: "Map(xs, itoa)", want: "[]string"},
+		{src: "Map(xs, Identity)", want: "[]int"},
+		{src: "Map(xs, Double)", want: "[]int"},
+		{src: "Map(xs, Identity[int])", want: "[]int"},
+		{src: "Filter(xs, func(x int) bool { return x > 0 })", want: "[]int"},
+		{src: "Reduce(xs, 0, func(a float64, x int) float64 { return a })", want: "float64"},
+		{src: "Reduce(ss, 0, func(n int, s string) int { return n })", want: "int"},
+		{
+			src: "Filter(xs, func(x string) bool { return true })",
+			conflict: &gerrors.InferenceConflictError{
+				Param: "T", Args: [2]int{0, 1},
+				Exprs: [2]string{"xs", "(func(x string) bool literal)"},
+				Types: [2]string{"int", "string"},
+			},
+		},
+		{src: "Map(xs, func(x int) {})", wantErr: ErrTypeMismatch},
+		{src: "Map(ss, Double)", wantErr: ErrConstraintNotSatisfied},
+		{src: "Call(Zero)", wantErr: errors.New("in call to Call, cannot infer T")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := InferType(mustParseExpr(t, tt.src), env, nil)
+			switch {
+			case tt.conflict != nil:
+				var ce *gerrors.InferenceConflictError
+				if !errors.As(err, &ce) || *ce != *tt.conflict {
+					t.Fatalf("InferType() error = %v, want %v", err, tt.conflict)
+				}
+				return
+			case tt.wantErr != nil:
+				if err == nil || !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error() {
+					t.Fatalf("InferType() = %v, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+			if FormatGo(got) != tt.want {
+				t.Errorf("InferType() = %s, want %s", FormatGo(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestInferTypeArguments(t *testing.T) {
+	env := mustBuildEnv(t, `package p
+
+func Map[F, T any](s []F, f func(F) T) []T { return nil }
+func Sum(xs []int) int { return 0 }
+
+var xs []int
+`)
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: `Map(xs, func(x int) string { return "" })`, want: "int, string"},
+		{src: `Map[int](xs, func(x int) bool { return true })`, want: "int, bool"},
+	}
+	for _, tt := range tests {
+		call := mustParseExpr(t, tt.src).(*ast.CallExpr)
+		got, err := InferTypeArguments(call, env, nil)
+		if err != nil {
+			t.Fatalf("InferTypeArguments(%s) error = %v", tt.src, err)
+		}
+		if s := formatTypes(got); s != tt.want {
+			t.Errorf("InferTypeArguments(%s) = %s, want %s", tt.src, s, tt.want)
+		}
+	}
+
+	if _, err := InferTypeArguments(mustParseExpr(t, "Sum(xs)").(*ast.CallExpr), env, nil); !errors.Is(err, ErrNotAGenericType) {
+		t.Errorf("InferTypeArguments(Sum(xs)) error = %v, want %v", err, ErrNotAGenericType)
+	}
+}
+
+// mustBuildEnv builds the environment of the declarations of the file src.
+func mustBuildEnv(t *testing.T, src string) TypeEnv {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	return env
+}
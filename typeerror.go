@@ -0,0 +1,187 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// ErrorCode classifies a TypeError, so that tools can handle kinds of errors without
+// matching their messages.
+type ErrorCode int
+
+const (
+	CodeUnknown ErrorCode = iota
+	CodeUndeclaredName
+	CodeMismatchedTypes
+	CodeWrongArgCount
+	CodeNotAFunction
+	CodeNotAnExpression
+	CodeNotAType
+	CodeNotAGenericType
+	CodeWrongTypeArgCount
+	CodeUnsatisfiedConstraint
+	CodeInvalidOperation
+	CodeNoValueUsed
+	CodeMissingField
+	CodeCircularReference
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case CodeUndeclaredName:
+		return "UndeclaredName"
+	case CodeMismatchedTypes:
+		return "MismatchedTypes"
+	case CodeWrongArgCount:
+		return "WrongArgCount"
+	case CodeNotAFunction:
+		return "NotAFunction"
+	case CodeNotAnExpression:
+		return "NotAnExpression"
+	case CodeNotAType:
+		return "NotAType"
+	case CodeNotAGenericType:
+		return "NotAGenericType"
+	case CodeWrongTypeArgCount:
+		return "WrongTypeArgCount"
+	case CodeUnsatisfiedConstraint:
+		return "UnsatisfiedConstraint"
+	case CodeInvalidOperation:
+		return "InvalidOperation"
+	case CodeNoValueUsed:
+		return "NoValueUsed"
+	case CodeMissingField:
+		return "MissingField"
+	case CodeCircularReference:
+		return "CircularReference"
+	}
+	return "Unknown"
+}
+
+// codeOf returns the code of the sentinel error err wraps, if any.
+func codeOf(err error) ErrorCode {
+	switch {
+	case errors.Is(err, ErrUnknownIdent):
+		return CodeUndeclaredName
+	case errors.Is(err, ErrTypeMismatch):
+		return CodeMismatchedTypes
+	case errors.Is(err, ErrArityMismatch):
+		return CodeWrongArgCount
+	case errors.Is(err, ErrNotAFunction):
+		return CodeNotAFunction
+	case errors.Is(err, ErrNotAnExpression):
+		return CodeNotAnExpression
+	case errors.Is(err, ErrNotAType):
+		return CodeNotAType
+	case errors.Is(err, ErrNotAGenericType):
+		return CodeNotAGenericType
+	case errors.Is(err, ErrTypeParamsNotMatch):
+		return CodeWrongTypeArgCount
+	case errors.Is(err, ErrConstraintNotSatisfied):
+		return CodeUnsatisfiedConstraint
+	case errors.Is(err, ErrInvalidOperation):
+		return CodeInvalidOperation
+	case errors.Is(err, ErrNoValueUsed):
+		return CodeNoValueUsed
+	case errors.Is(err, ErrMissingField):
+		return CodeMissingField
+	case errors.Is(err, ErrCircularReference):
+		return CodeCircularReference
+	}
+	return CodeUnknown
+}
+
+// TypeError is an error of InferType, located at the innermost expression or statement
+// that could not be inferred. Its message is that of the underlying error Err, so that
+// it reads the same as before; use errors.As to get at the details:
+//
+//	var te *TypeError
+//	if errors.As(err, &te) {
+//		fmt.Printf("%s: %s (%s)\n", te.Position, te.Err, te.Code)
+//	}
+type TypeError struct {
+	Pos      token.Pos
+	Position token.Position // Pos resolved in the file set of the context, if it has one
+	Code     ErrorCode
+	Expr     string // the offending expression, like `x + "a"`, if the node is one
+	Expected Type   // the type expected by the context, if known
+	Actual   Type   // the type of Expr, if known
+	Err      error
+}
+
+func (e *TypeError) Error() string { return e.Err.Error() }
+
+func (e *TypeError) Unwrap() error { return e.Err }
+
+// typeError returns err as a TypeError located at node. An error that already holds a
+// TypeError, from a nested node, is returned as is, but located in the file set of ctx,
+// and each of joined errors is located on its own.
+func typeError(node interface{}, ctx *InferenceContext, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		// collected errors, see InferenceContext.ErrorLimit
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, typeError(node, ctx, e))
+		}
+		return errors.Join(errs...)
+	}
+	fset := ctx.fileSet()
+	var te *TypeError
+	if errors.As(err, &te) {
+		if fset != nil && !te.Position.IsValid() && te.Pos.IsValid() {
+			te.Position = fset.Position(te.Pos)
+		}
+		return err
+	}
+	n, ok := node.(ast.Node)
+	if !ok {
+		return err
+	}
+	te = &TypeError{Pos: n.Pos(), Code: codeOf(err), Err: err}
+	if expr, ok := n.(ast.Expr); ok {
+		te.Expr = types.ExprString(expr)
+	}
+	if ctx != nil {
+		te.Expected = ctx.ExpectedType
+	}
+	if fset != nil && te.Pos.IsValid() {
+		te.Position = fset.Position(te.Pos)
+	}
+	return te
+}
+
+// mismatchError returns a TypeError for a value expr of type actual that cannot be used
+// as a value of type expected, with the message of err.
+func mismatchError(expr ast.Expr, expected, actual Type, err error) *TypeError {
+	return &TypeError{
+		Pos:      expr.Pos(),
+		Code:     CodeMismatchedTypes,
+		Expr:     types.ExprString(expr),
+		Expected: expected,
+		Actual:   actual,
+		Err:      err,
+	}
+}
+
+// at formats the positions pos for a message, like " at p.go:3:2 and p.go:4:2", resolved
+// in the file set of ctx. Without one they are left out, since a token.Pos is only an
+// offset into a file set; the TypeError of the message still locates it, see typeError.
+func at(ctx *InferenceContext, pos ...token.Pos) string {
+	fset := ctx.fileSet()
+	if fset == nil {
+		return ""
+	}
+	positions := make([]string, 0, len(pos))
+	for _, p := range pos {
+		if p.IsValid() {
+			positions = append(positions, fset.Position(p).String())
+		}
+	}
+	if len(positions) == 0 {
+		return ""
+	}
+	return " at " + strings.Join(positions, " and ")
+}
@@ -0,0 +1,116 @@
+package generic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	gerrors "github.com/notJoon/generic/errors"
+)
+
+func TestTypeError(t *testing.T) {
+	tests := []struct {
+		name         string
+		src          string
+		wantCode     ErrorCode
+		wantExpr     string
+		wantLine     int
+		wantColumn   int
+		wantExpected string
+		wantActual   string
+	}{
+		{name: "undeclared name", src: "x := 1 + missing", wantCode: CodeUndeclaredName, wantExpr: "missing", wantLine: 2, wantColumn: 10},
+		{name: "initializer mismatch", src: "s := \"a\"\nvar x int = s + s", wantCode: CodeMismatchedTypes, wantExpr: "s + s", wantLine: 3, wantColumn: 13, wantExpected: "int", wantActual: "string"},
+		{name: "type as value", src: "x := int", wantCode: CodeNotAnExpression, wantLine: 2, wantColumn: 1},
+		{name: "invalid operation", src: "x := 1 + \"a\"", wantCode: CodeInvalidOperation, wantExpr: `1 + "a"`, wantLine: 2, wantColumn: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", "package p; func _() {\n"+tt.src+"\n}", 0)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			env := universe()
+			for _, stmt := range file.Decls[0].(*ast.FuncDecl).Body.List {
+				if _, err = InferType(stmt, env, NewInferenceContext(WithFileSet(fset))); err != nil {
+					break
+				}
+			}
+
+			var te *TypeError
+			if !errors.As(err, &te) {
+				t.Fatalf("InferType() error = %v, want a TypeError", err)
+			}
+			if !strings.HasSuffix(err.Error(), te.Error()) {
+				t.Errorf("TypeError message = %q, want a suffix of %q", te.Error(), err.Error())
+			}
+			if te.Code != tt.wantCode {
+				t.Errorf("Code = %v, want %v", te.Code, tt.wantCode)
+			}
+			if tt.wantExpr != "" && te.Expr != tt.wantExpr {
+				t.Errorf("Expr = %q, want %q", te.Expr, tt.wantExpr)
+			}
+			if te.Position.Filename != "p.go" || te.Position.Line != tt.wantLine || te.Position.Column != tt.wantColumn {
+				t.Errorf("Position = %v, want p.go:%d:%d", te.Position, tt.wantLine, tt.wantColumn)
+			}
+			if tt.wantExpected != "" && FormatGo(te.Expected) != tt.wantExpected {
+				t.Errorf("Expected = %s, want %s", FormatGo(te.Expected), tt.wantExpected)
+			}
+			if tt.wantActual != "" && FormatGo(te.Actual) != tt.wantActual {
+				t.Errorf("Actual = %s, want %s", FormatGo(te.Actual), tt.wantActual)
+			}
+		})
+	}
+}
+
+func TestTypeErrorWithoutFileSet(t *testing.T) {
+	_, err := InferType(mustParseExpr(t, "missing"), universe(), nil)
+	var te *TypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("InferType() error = %v, want a TypeError", err)
+	}
+	if !errors.Is(err, ErrUnknownIdent) {
+		t.Errorf("InferType() error = %v, want ErrUnknownIdent", err)
+	}
+	if te.Position.IsValid() {
+		t.Errorf("Position = %v, want none without a file set", te.Position)
+	}
+	if !te.Pos.IsValid() {
+		t.Error("Pos is not valid")
+	}
+}
+
+func TestErrorCodeString(t *testing.T) {
+	if got := CodeMismatchedTypes.String(); got != "MismatchedTypes" {
+		t.Errorf("String() = %s, want MismatchedTypes", got)
+	}
+	if got := ErrorCode(-1).String(); got != "Unknown" {
+		t.Errorf("String() = %s, want Unknown", got)
+	}
+}
+
+func TestTypedErrorsFromInferType(t *testing.T) {
+	env := universe()
+	env["double"] = &FunctionType{ParamTypes: []Type{Int}, ReturnType: Int}
+
+	_, err := InferType(mustParseExpr(t, "double(1, 2)"), env, nil)
+	var arity *gerrors.ArityError
+	if !errors.As(err, &arity) || arity.Want != 1 || arity.Got != 2 {
+		t.Errorf("InferType() error = %v, want an ArityError", err)
+	}
+
+	_, err = InferType(mustParseExpr(t, "double(missing)"), env, nil)
+	var unknown *gerrors.UnknownIdentError
+	if !errors.As(err, &unknown) || unknown.Name != "missing" {
+		t.Errorf("InferType() error = %v, want an UnknownIdentError", err)
+	}
+	var te *TypeError
+	if !errors.As(err, &te) || te.Code != CodeUndeclaredName {
+		t.Errorf("InferType() error = %v, want a TypeError with code UndeclaredName", err)
+	}
+}
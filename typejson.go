@@ -0,0 +1,862 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"math/big"
+	"strings"
+)
+
+// EncodeType encodes t as JSON, so that it can be cached on disk or passed to another tool
+// and restored with DecodeType.
+//
+// Types are encoded as a table of nodes that refer to each other by index, so that a type
+// used in several places is decoded to a single value, and recursive types, like a struct
+// whose methods have it as their receiver, are preserved. Positions are not encoded, since
+// they are only meaningful with the file set they were recorded in, and the predeclared
+// types are decoded to their shared values, like Int.
+func EncodeType(t Type) ([]byte, error) {
+	var e typeEncoder
+	root, err := e.ref(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodedTypes{Root: root, Nodes: e.nodes})
+}
+
+// DecodeType decodes a type encoded by EncodeType.
+func DecodeType(data []byte) (Type, error) {
+	var enc encodedTypes
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, err
+	}
+	d, err := newTypeDecoder(enc.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	return d.ref(enc.Root)
+}
+
+// EncodeEnv encodes the declarations of env as JSON, like EncodeType.
+// Types shared between declarations are encoded once.
+func EncodeEnv(env TypeEnv) ([]byte, error) {
+	var e typeEncoder
+	names, err := e.env(env)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodedTypes{Names: names, Nodes: e.nodes})
+}
+
+// DecodeEnv decodes an environment encoded by EncodeEnv.
+func DecodeEnv(data []byte) (TypeEnv, error) {
+	var enc encodedTypes
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, err
+	}
+	d, err := newTypeDecoder(enc.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	return d.env(enc.Names)
+}
+
+// encodedTypes is the JSON form of EncodeType and EncodeEnv: a single root, or the
+// names of an environment, and the table of the nodes they refer to.
+type encodedTypes struct {
+	Root  int            `json:"root,omitempty"`
+	Names map[string]int `json:"names,omitempty"`
+	Nodes []typeNode     `json:"nodes"`
+}
+
+// typeNode is the JSON form of a single type. Kind is the name of its Go type, like
+// "StructType", and the other fields are set as needed by the kind. Types are referred
+// to by their index in the node table plus one, so that zero is the nil type.
+type typeNode struct {
+	Kind string `json:"kind"`
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+
+	Elem     int      `json:"elem,omitempty"`
+	Key      int      `json:"key,omitempty"`
+	Receiver int      `json:"receiver,omitempty"`
+	Result   int      `json:"result,omitempty"`
+	Rest     int      `json:"rest,omitempty"`
+	Types    []int    `json:"types,omitempty"`
+	Params   []int    `json:"params,omitempty"`
+	Results  []int    `json:"results,omitempty"`
+	Variants []int    `json:"variants,omitempty"`
+	Excluded []int    `json:"excluded,omitempty"`
+	Embedded []int    `json:"embedded,omitempty"`
+	Fields   *refMap  `json:"fields,omitempty"`
+	Order    []string `json:"order,omitempty"`
+	Methods  *refMap  `json:"methods,omitempty"`
+	Generic  *refMap  `json:"genericMethods,omitempty"`
+	Members  *refMap  `json:"members,omitempty"`
+
+	// Interfaces and Constraints refer to Interface and TypeConstraint nodes,
+	// Method to a Method node and Signature to a FunctionType node.
+	Interfaces  []int   `json:"interfaces,omitempty"`
+	Constraints *refMap `json:"constraints,omitempty"`
+	Method      int     `json:"method,omitempty"`
+	Signature   int     `json:"signature,omitempty"`
+
+	TypeParams []encodedTypeParam `json:"typeParams,omitempty"`
+	Origin     *encodedOrigin     `json:"origin,omitempty"`
+	Value      *encodedValue      `json:"value,omitempty"`
+
+	Len         int    `json:"len,omitempty"`
+	Dir         int    `json:"dir,omitempty"`
+	Effects     uint8  `json:"effects,omitempty"`
+	Builtin     string `json:"builtin,omitempty"`
+	Variadic    bool   `json:"variadic,omitempty"`
+	Pointer     bool   `json:"pointer,omitempty"`
+	Empty       bool   `json:"empty,omitempty"`
+	Union       bool   `json:"union,omitempty"`
+	Comparable  bool   `json:"comparable,omitempty"`
+	Underlying  bool   `json:"underlying,omitempty"`
+	IsInterface bool   `json:"isInterface,omitempty"`
+	Untyped     bool   `json:"untyped,omitempty"`
+}
+
+// refMap refers to the types of a map, like the fields of a struct, by name. Nodes hold
+// a pointer to it, so that nil maps are distinguished from empty ones.
+type refMap map[string]int
+
+func optRefMap(m map[string]int) *refMap {
+	if m == nil {
+		return nil
+	}
+	r := refMap(m)
+	return &r
+}
+
+// encodedTypeParam is the JSON form of a TypeParam. Constraint refers to a TypeConstraint node.
+type encodedTypeParam struct {
+	Name       string `json:"name"`
+	Constraint int    `json:"constraint,omitempty"`
+	Default    int    `json:"default,omitempty"`
+}
+
+// encodedOrigin is the JSON form of the Instantiation of a generic type.
+type encodedOrigin struct {
+	Decl int    `json:"decl"`
+	Args []int  `json:"args"`
+	Open []bool `json:"open,omitempty"` // the parameters left out of a partial instantiation
+}
+
+// encodedValue is the JSON form of the value of a constant. Complex values are encoded
+// as their real and imaginary parts separated by a space.
+type encodedValue struct {
+	Kind  constant.Kind `json:"kind"`
+	Exact string        `json:"exact"`
+}
+
+// typeEncoder builds the node table of EncodeType and EncodeEnv.
+type typeEncoder struct {
+	nodes []typeNode
+	index map[Type]int // the reference to each type encoded so far
+}
+
+// ref returns the reference to the node of t, encoding t if it was not encoded before.
+// Method values are not comparable, so each occurrence is encoded separately.
+func (e *typeEncoder) ref(t Type) (int, error) {
+	if t == nil {
+		return 0, nil
+	}
+	_, isMethod := t.(Method)
+	if !isMethod {
+		if ref, ok := e.index[t]; ok {
+			return ref, nil
+		}
+		if e.index == nil {
+			e.index = make(map[Type]int)
+		}
+	}
+	e.nodes = append(e.nodes, typeNode{})
+	ref := len(e.nodes)
+	if !isMethod {
+		e.index[t] = ref
+	}
+	n, err := e.node(t)
+	if err != nil {
+		return 0, err
+	}
+	e.nodes[ref-1] = n
+	return ref, nil
+}
+
+func (e *typeEncoder) refs(ts []Type) ([]int, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	refs := make([]int, len(ts))
+	for i, t := range ts {
+		ref, err := e.ref(t)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = ref
+	}
+	return refs, nil
+}
+
+func (e *typeEncoder) env(env TypeEnv) (map[string]int, error) {
+	if env == nil {
+		return nil, nil
+	}
+	refs := make(map[string]int, len(env))
+	for _, name := range sortedKeys(env) {
+		ref, err := e.ref(env[name])
+		if err != nil {
+			return nil, err
+		}
+		refs[name] = ref
+	}
+	return refs, nil
+}
+
+func (e *typeEncoder) methods(ms MethodSet) (map[string]int, error) {
+	if ms == nil {
+		return nil, nil
+	}
+	refs := make(map[string]int, len(ms))
+	for _, name := range sortedKeys(ms) {
+		ref, err := e.ref(ms[name])
+		if err != nil {
+			return nil, err
+		}
+		refs[name] = ref
+	}
+	return refs, nil
+}
+
+func (e *typeEncoder) genericMethods(gms map[string]GenericMethod) (map[string]int, error) {
+	if gms == nil {
+		return nil, nil
+	}
+	refs := make(map[string]int, len(gms))
+	for _, name := range sortedKeys(gms) {
+		gm := gms[name]
+		ref, err := e.ref(&gm)
+		if err != nil {
+			return nil, err
+		}
+		refs[name] = ref
+	}
+	return refs, nil
+}
+
+// node encodes the fields of t.
+func (e *typeEncoder) node(t Type) (n typeNode, err error) {
+	// The fields are encoded in order, and the first error is kept.
+	ref := func(t Type) int {
+		if err != nil {
+			return 0
+		}
+		var i int
+		i, err = e.ref(t)
+		return i
+	}
+	refs := func(ts []Type) []int {
+		if err != nil {
+			return nil
+		}
+		var is []int
+		is, err = e.refs(ts)
+		return is
+	}
+	fields := func(fs map[string]Type) *refMap {
+		if err != nil {
+			return nil
+		}
+		var is map[string]int
+		is, err = e.env(fs)
+		return optRefMap(is)
+	}
+	methods := func(ms MethodSet) *refMap {
+		if err != nil {
+			return nil
+		}
+		var is map[string]int
+		is, err = e.methods(ms)
+		return optRefMap(is)
+	}
+	genericMethods := func(gms map[string]GenericMethod) *refMap {
+		if err != nil {
+			return nil
+		}
+		var is map[string]int
+		is, err = e.genericMethods(gms)
+		return optRefMap(is)
+	}
+
+	switch t := t.(type) {
+	case *TypeVariable:
+		n = typeNode{Kind: "TypeVariable", Name: t.Name}
+	case *TypeConstant:
+		n = typeNode{Kind: "TypeConstant", Name: t.Name}
+	case *NamedType:
+		n = typeNode{Kind: "NamedType", Name: t.Name, Elem: ref(t.Underlying), Methods: methods(t.Methods)}
+	case *FunctionType:
+		n = typeNode{Kind: "FunctionType", Params: refs(t.ParamTypes), Result: ref(t.ReturnType), Variadic: t.IsVariadic, Effects: uint8(t.Effects)}
+	case *TupleType:
+		n = typeNode{Kind: "TupleType", Types: refs(t.Types)}
+	case *NoValueType:
+		n = typeNode{Kind: "NoValueType"}
+	case *NilType:
+		n = typeNode{Kind: "NilType"}
+	case *Interface:
+		n = typeNode{Kind: "Interface", Name: t.Name, Methods: methods(t.Methods)}
+	case *InterfaceType:
+		n = typeNode{
+			Kind:     "InterfaceType",
+			Name:     t.Name,
+			Methods:  methods(t.Methods),
+			Generic:  genericMethods(t.GenericMethods),
+			Embedded: refs(t.Embedded),
+			Empty:    t.IsEmpty,
+			Variants: refs(t.Variants),
+		}
+	case Method:
+		n = typeNode{
+			Kind:     "Method",
+			Name:     t.Name,
+			Receiver: ref(t.Receiver),
+			Params:   refs(t.Params),
+			Results:  refs(t.Results),
+			Pointer:  t.IsPointer,
+			Variadic: t.IsVariadic,
+		}
+	case *PointerType:
+		n = typeNode{Kind: "PointerType", Elem: ref(t.Base)}
+	case *StructType:
+		n = typeNode{
+			Kind:    "StructType",
+			Name:    t.Name,
+			Fields:  fields(t.Fields),
+			Order:   t.FieldOrder,
+			Methods: methods(t.Methods),
+			Generic: genericMethods(t.GenericMethods),
+		}
+	case *ExtensibleStruct:
+		n = typeNode{Kind: "ExtensibleStruct", Fields: fields(t.Fields)}
+		if t.Rest != nil {
+			n.Rest = ref(t.Rest)
+		}
+	case *SliceType:
+		n = typeNode{Kind: "SliceType", Elem: ref(t.ElementType)}
+	case *ArrayType:
+		n = typeNode{Kind: "ArrayType", Elem: ref(t.ElementType), Len: t.Len}
+	case *MapType:
+		n = typeNode{Kind: "MapType", Key: ref(t.KeyType), Elem: ref(t.ValueType)}
+	case *ChanType:
+		n = typeNode{Kind: "ChanType", Elem: ref(t.ElementType), Dir: int(t.Dir)}
+	case *TypeConstraint:
+		var interfaces []int
+		for _, it := range t.Interfaces {
+			interfaces = append(interfaces, ref(&it))
+		}
+		n = typeNode{
+			Kind:       "TypeConstraint",
+			Interfaces: interfaces,
+			Types:      refs(t.Types),
+			Union:      t.Union,
+			Comparable: t.IsComparable,
+			Underlying: t.IsUnderlying,
+			Builtin:    t.BuiltinConstraint,
+			Excluded:   refs(t.Excluded),
+		}
+	case *ApproxType:
+		n = typeNode{Kind: "ApproxType", Elem: ref(t.Base)}
+	case *GenericType:
+		n = typeNode{
+			Kind:        "GenericType",
+			Name:        t.Name,
+			Types:       refs(t.TypeParams),
+			Fields:      fields(t.Fields),
+			Order:       t.FieldOrder,
+			Methods:     methods(t.Methods),
+			IsInterface: t.IsInterface,
+			Elem:        ref(t.UnderlyingType),
+		}
+		if t.Signature != nil {
+			n.Signature = ref(t.Signature)
+		}
+		if t.Constraints != nil {
+			constraints := make(map[string]int, len(t.Constraints))
+			for _, name := range sortedKeys(t.Constraints) {
+				c := t.Constraints[name]
+				constraints[name] = ref(&c)
+			}
+			n.Constraints = optRefMap(constraints)
+		}
+		for _, p := range t.Params {
+			param := encodedTypeParam{Name: p.Name, Default: ref(p.Default)}
+			if p.Constraint != nil {
+				param.Constraint = ref(p.Constraint)
+			}
+			n.TypeParams = append(n.TypeParams, param)
+		}
+		if t.origin != nil {
+			n.Origin = &encodedOrigin{Decl: ref(t.origin.Decl), Args: refs(t.origin.Args), Open: t.origin.open}
+		}
+	case *GenericMethod:
+		n = typeNode{Kind: "GenericMethod", Name: t.Name, Types: refs(t.TypeParams), Method: ref(t.Method)}
+	case *TypeAlias:
+		n = typeNode{Kind: "TypeAlias", Name: t.Name, Elem: ref(t.AliasedTo)}
+	case *PackageType:
+		n = typeNode{Kind: "PackageType", Name: t.Name, Path: t.Path, Members: fields(t.Members)}
+	case *VarObj:
+		n = typeNode{Kind: "VarObj", Name: t.Name, Elem: ref(t.Type)}
+	case *ConstObj:
+		n = typeNode{Kind: "ConstObj", Name: t.Name, Elem: ref(t.Type), Value: encodeValue(t.Val), Untyped: t.Untyped}
+	case *TypeObj:
+		n = typeNode{Kind: "TypeObj", Name: t.Name, Elem: ref(t.Type)}
+	case *FuncObj:
+		n = typeNode{Kind: "FuncObj", Name: t.Name, Elem: ref(t.Type)}
+	default:
+		return n, fmt.Errorf("encode type: unsupported type %T", t)
+	}
+	return n, err
+}
+
+func encodeValue(val constant.Value) *encodedValue {
+	if val == nil || val.Kind() == constant.Unknown {
+		return nil
+	}
+	switch val.Kind() {
+	case constant.String:
+		return &encodedValue{Kind: val.Kind(), Exact: constant.StringVal(val)}
+	case constant.Complex:
+		re, im := constant.Real(val), constant.Imag(val)
+		return &encodedValue{Kind: val.Kind(), Exact: re.ExactString() + " " + im.ExactString()}
+	}
+	return &encodedValue{Kind: val.Kind(), Exact: val.ExactString()}
+}
+
+func decodeValue(v *encodedValue) (constant.Value, error) {
+	if v == nil {
+		return constant.MakeUnknown(), nil
+	}
+	switch v.Kind {
+	case constant.Bool:
+		return constant.MakeBool(v.Exact == "true"), nil
+	case constant.String:
+		return constant.MakeString(v.Exact), nil
+	case constant.Int:
+		if val := constant.MakeFromLiteral(v.Exact, token.INT, 0); val.Kind() == constant.Int {
+			return val, nil
+		}
+	case constant.Float:
+		return decodeFloat(v.Exact)
+	case constant.Complex:
+		re, im, ok := strings.Cut(v.Exact, " ")
+		if ok {
+			x, err := decodeFloat(re)
+			if err != nil {
+				return nil, err
+			}
+			y, err := decodeFloat(im)
+			if err != nil {
+				return nil, err
+			}
+			return constant.BinaryOp(x, token.ADD, constant.MakeImag(y)), nil
+		}
+	}
+	return nil, fmt.Errorf("decode type: invalid constant %q of kind %v", v.Exact, v.Kind)
+}
+
+// decodeFloat decodes the exact form of a float constant, a fraction like "3/2" or a
+// decimal like "1e+100". The parts of complex constants may be integers.
+func decodeFloat(s string) (constant.Value, error) {
+	if val := constant.MakeFromLiteral(s, token.INT, 0); val.Kind() == constant.Int {
+		return val, nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("decode type: invalid constant %q", s)
+	}
+	return constant.Make(r), nil
+}
+
+// typeDecoder restores the types of a node table. The types of all nodes but Method
+// nodes are allocated before their fields are decoded, so that nodes can refer to
+// each other in any order, including cycles.
+type typeDecoder struct {
+	nodes  []typeNode
+	types  []Type
+	filled []bool
+}
+
+func newTypeDecoder(nodes []typeNode) (*typeDecoder, error) {
+	d := &typeDecoder{nodes: nodes, types: make([]Type, len(nodes)), filled: make([]bool, len(nodes))}
+	for i, n := range nodes {
+		t, err := allocType(n)
+		if err != nil {
+			return nil, err
+		}
+		d.types[i] = t
+	}
+	for i := range nodes {
+		if err := d.fill(i); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range d.types {
+		if gt, ok := t.(*GenericType); ok && gt.Params != nil && gt.origin == nil {
+			gt.compileConstraints()
+		}
+	}
+	return d, nil
+}
+
+// allocType allocates the type of n, without its fields.
+func allocType(n typeNode) (Type, error) {
+	switch n.Kind {
+	case "TypeVariable":
+		return &TypeVariable{Name: n.Name}, nil
+	case "TypeConstant":
+		if tc, ok := predeclared[n.Name]; ok {
+			return tc, nil
+		}
+		return &TypeConstant{Name: n.Name}, nil
+	case "NamedType":
+		return &NamedType{}, nil
+	case "FunctionType":
+		return &FunctionType{}, nil
+	case "TupleType":
+		return &TupleType{}, nil
+	case "NoValueType":
+		return &NoValueType{}, nil
+	case "NilType":
+		return Nil, nil
+	case "Interface":
+		return &Interface{}, nil
+	case "InterfaceType":
+		return &InterfaceType{}, nil
+	case "Method":
+		return nil, nil // decoded where it is used, see typeDecoder.ref
+	case "PointerType":
+		return &PointerType{}, nil
+	case "StructType":
+		return &StructType{}, nil
+	case "ExtensibleStruct":
+		return &ExtensibleStruct{}, nil
+	case "SliceType":
+		return &SliceType{}, nil
+	case "ArrayType":
+		return &ArrayType{}, nil
+	case "MapType":
+		return &MapType{}, nil
+	case "ChanType":
+		return &ChanType{}, nil
+	case "TypeConstraint":
+		return &TypeConstraint{}, nil
+	case "ApproxType":
+		return &ApproxType{}, nil
+	case "GenericType":
+		return &GenericType{}, nil
+	case "GenericMethod":
+		return &GenericMethod{}, nil
+	case "TypeAlias":
+		return &TypeAlias{}, nil
+	case "PackageType":
+		return &PackageType{}, nil
+	case "VarObj":
+		return &VarObj{}, nil
+	case "ConstObj":
+		return &ConstObj{}, nil
+	case "TypeObj":
+		return &TypeObj{}, nil
+	case "FuncObj":
+		return &FuncObj{}, nil
+	}
+	return nil, fmt.Errorf("decode type: unknown kind %q", n.Kind)
+}
+
+// ref returns the type referred to by ref, see typeNode.
+func (d *typeDecoder) ref(ref int) (Type, error) {
+	if ref == 0 {
+		return nil, nil
+	}
+	if ref < 0 || ref > len(d.nodes) {
+		return nil, fmt.Errorf("decode type: invalid reference %d", ref)
+	}
+	if n := d.nodes[ref-1]; n.Kind == "Method" {
+		return d.method(n)
+	}
+	return d.types[ref-1], nil
+}
+
+// value is like ref, but decodes the fields of the type first. It is used for the types
+// that are stored by value, like the TypeConstraint of a generic type, and so are copied.
+func (d *typeDecoder) value(ref int) (Type, error) {
+	t, err := d.ref(ref)
+	if err != nil || t == nil {
+		return t, err
+	}
+	return t, d.fill(ref - 1)
+}
+
+func (d *typeDecoder) refs(is []int) ([]Type, error) {
+	if is == nil {
+		return nil, nil
+	}
+	ts := make([]Type, len(is))
+	for j, i := range is {
+		t, err := d.ref(i)
+		if err != nil {
+			return nil, err
+		}
+		ts[j] = t
+	}
+	return ts, nil
+}
+
+func (d *typeDecoder) env(refs map[string]int) (TypeEnv, error) {
+	if refs == nil {
+		return nil, nil
+	}
+	env := make(TypeEnv, len(refs))
+	for name, i := range refs {
+		t, err := d.ref(i)
+		if err != nil {
+			return nil, err
+		}
+		env[name] = t
+	}
+	return env, nil
+}
+
+func (d *typeDecoder) method(n typeNode) (Method, error) {
+	m := Method{Name: n.Name, IsPointer: n.Pointer, IsVariadic: n.Variadic}
+	var err error
+	if m.Receiver, err = d.ref(n.Receiver); err != nil {
+		return m, err
+	}
+	if m.Params, err = d.refs(n.Params); err != nil {
+		return m, err
+	}
+	m.Results, err = d.refs(n.Results)
+	return m, err
+}
+
+func (d *typeDecoder) methods(refs map[string]int) (MethodSet, error) {
+	if refs == nil {
+		return nil, nil
+	}
+	ms := make(MethodSet, len(refs))
+	for name, i := range refs {
+		t, err := d.ref(i)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := t.(Method)
+		if !ok {
+			return nil, fmt.Errorf("decode type: method %s is a %T", name, t)
+		}
+		ms[name] = m
+	}
+	return ms, nil
+}
+
+func (d *typeDecoder) genericMethods(refs map[string]int) (map[string]GenericMethod, error) {
+	if refs == nil {
+		return nil, nil
+	}
+	gms := make(map[string]GenericMethod, len(refs))
+	for name, i := range refs {
+		t, err := d.value(i)
+		if err != nil {
+			return nil, err
+		}
+		gm, ok := t.(*GenericMethod)
+		if !ok {
+			return nil, fmt.Errorf("decode type: generic method %s is a %T", name, t)
+		}
+		gms[name] = *gm
+	}
+	return gms, nil
+}
+
+// constraint returns the TypeConstraint referred to by ref, or nil if there is none.
+func (d *typeDecoder) constraint(ref int) (*TypeConstraint, error) {
+	t, err := d.value(ref)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	tc, ok := t.(*TypeConstraint)
+	if !ok {
+		return nil, fmt.Errorf("decode type: constraint is a %T", t)
+	}
+	return tc, nil
+}
+
+// fill decodes the fields of node i into its allocated type.
+func (d *typeDecoder) fill(i int) (err error) {
+	if d.filled[i] {
+		return nil
+	}
+	d.filled[i] = true
+	n := d.nodes[i]
+	// The fields are decoded in order, and the first error is kept.
+	ref := func(i int) Type {
+		if err != nil {
+			return nil
+		}
+		var t Type
+		t, err = d.ref(i)
+		return t
+	}
+	refs := func(is []int) []Type {
+		if err != nil {
+			return nil
+		}
+		var ts []Type
+		ts, err = d.refs(is)
+		return ts
+	}
+	fields := func(refs *refMap) TypeEnv {
+		if err != nil || refs == nil {
+			return nil
+		}
+		var env TypeEnv
+		env, err = d.env(*refs)
+		return env
+	}
+	methods := func(refs *refMap) MethodSet {
+		if err != nil || refs == nil {
+			return nil
+		}
+		var ms MethodSet
+		ms, err = d.methods(*refs)
+		return ms
+	}
+	genericMethods := func(refs *refMap) map[string]GenericMethod {
+		if err != nil || refs == nil {
+			return nil
+		}
+		var gms map[string]GenericMethod
+		gms, err = d.genericMethods(*refs)
+		return gms
+	}
+	constraint := func(i int) *TypeConstraint {
+		if err != nil {
+			return nil
+		}
+		var tc *TypeConstraint
+		tc, err = d.constraint(i)
+		return tc
+	}
+
+	switch t := d.types[i].(type) {
+	case *NamedType:
+		t.Name, t.Underlying, t.Methods = n.Name, ref(n.Elem), methods(n.Methods)
+	case *FunctionType:
+		t.ParamTypes, t.ReturnType = refs(n.Params), ref(n.Result)
+		t.IsVariadic, t.Effects = n.Variadic, Effects(n.Effects)
+	case *TupleType:
+		t.Types = refs(n.Types)
+	case *Interface:
+		t.Name, t.Methods = n.Name, methods(n.Methods)
+	case *InterfaceType:
+		t.Name, t.Methods, t.GenericMethods = n.Name, methods(n.Methods), genericMethods(n.Generic)
+		t.Embedded, t.IsEmpty, t.Variants = refs(n.Embedded), n.Empty, refs(n.Variants)
+	case *PointerType:
+		t.Base = ref(n.Elem)
+	case *StructType:
+		t.Name, t.Fields, t.FieldOrder = n.Name, fields(n.Fields), n.Order
+		t.Methods, t.GenericMethods = methods(n.Methods), genericMethods(n.Generic)
+	case *ExtensibleStruct:
+		t.Fields = fields(n.Fields)
+		if rest := ref(n.Rest); rest != nil {
+			tv, ok := rest.(*TypeVariable)
+			if !ok && err == nil {
+				err = fmt.Errorf("decode type: extensible struct rest is a %T", rest)
+			}
+			t.Rest = tv
+		}
+	case *SliceType:
+		t.ElementType = ref(n.Elem)
+	case *ArrayType:
+		t.ElementType, t.Len = ref(n.Elem), n.Len
+	case *MapType:
+		t.KeyType, t.ValueType = ref(n.Key), ref(n.Elem)
+	case *ChanType:
+		t.ElementType, t.Dir = ref(n.Elem), ChanDir(n.Dir)
+	case *TypeConstraint:
+		for _, j := range n.Interfaces {
+			it, err := d.value(j)
+			if err != nil {
+				return err
+			}
+			iface, ok := it.(*Interface)
+			if !ok {
+				return fmt.Errorf("decode type: constraint interface is a %T", it)
+			}
+			t.Interfaces = append(t.Interfaces, *iface)
+		}
+		t.Types, t.Excluded = refs(n.Types), refs(n.Excluded)
+		t.Union, t.IsComparable, t.IsUnderlying = n.Union, n.Comparable, n.Underlying
+		t.BuiltinConstraint = n.Builtin
+	case *ApproxType:
+		t.Base = ref(n.Elem)
+	case *GenericType:
+		t.Name, t.TypeParams = n.Name, refs(n.Types)
+		t.Fields, t.FieldOrder, t.Methods = fields(n.Fields), n.Order, methods(n.Methods)
+		t.IsInterface, t.UnderlyingType = n.IsInterface, ref(n.Elem)
+		if sig := ref(n.Signature); sig != nil {
+			ft, ok := sig.(*FunctionType)
+			if !ok && err == nil {
+				err = fmt.Errorf("decode type: signature of %s is a %T", n.Name, sig)
+			}
+			t.Signature = ft
+		}
+		if n.Constraints != nil {
+			t.Constraints = make(map[string]TypeConstraint, len(*n.Constraints))
+			for name, i := range *n.Constraints {
+				if tc := constraint(i); tc != nil {
+					t.Constraints[name] = *tc
+				}
+			}
+		}
+		for _, p := range n.TypeParams {
+			t.Params = append(t.Params, TypeParam{Name: p.Name, Constraint: constraint(p.Constraint), Default: ref(p.Default)})
+		}
+		if n.Origin != nil {
+			decl, ok := ref(n.Origin.Decl).(*GenericType)
+			if !ok && err == nil {
+				err = fmt.Errorf("decode type: origin of %s is not a generic type", n.Name)
+			}
+			t.origin = &Instantiation{Decl: decl, Args: refs(n.Origin.Args), open: n.Origin.Open}
+		}
+	case *GenericMethod:
+		t.Name, t.TypeParams = n.Name, refs(n.Types)
+		if m := ref(n.Method); m != nil {
+			method, ok := m.(Method)
+			if !ok && err == nil {
+				err = fmt.Errorf("decode type: generic method %s is a %T", n.Name, m)
+			}
+			t.Method = method
+		}
+	case *TypeAlias:
+		t.Name, t.AliasedTo = n.Name, ref(n.Elem)
+	case *PackageType:
+		t.Name, t.Path, t.Members = n.Name, n.Path, fields(n.Members)
+	case *VarObj:
+		t.Name, t.Type = n.Name, ref(n.Elem)
+	case *ConstObj:
+		t.Name, t.Type, t.Untyped = n.Name, ref(n.Elem), n.Untyped
+		if err == nil {
+			t.Val, err = decodeValue(n.Value)
+		}
+	case *TypeObj:
+		t.Name, t.Type = n.Name, ref(n.Elem)
+	case *FuncObj:
+		t.Name, t.Type = n.Name, ref(n.Elem)
+	}
+	return err
+}
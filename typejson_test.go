@@ -0,0 +1,180 @@
+package generic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeTypeRoundTrip(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	method := Method{Name: "Get", Params: []Type{Int}, Results: []Type{tv}, IsVariadic: true}
+	stringer := Interface{Name: "Stringer", Methods: MethodSet{"String": {Name: "String", Results: []Type{String}}}}
+	ordered := &TypeConstraint{Types: []Type{&ApproxType{Base: Int}, &ApproxType{Base: String}}, Union: true}
+
+	node := &StructType{Name: "Node", FieldOrder: []string{"value", "next"}}
+	node.Fields = map[string]Type{"value": Int, "next": &PointerType{Base: node}}
+	node.Methods = MethodSet{"Next": {Name: "Next", Receiver: node, Results: []Type{&PointerType{Base: node}}, IsPointer: true}}
+
+	list := NewGenericType("List", TypeParamList{{Name: "T", Constraint: ordered, Default: Int}}, map[string]Type{"items": &SliceType{ElementType: tv}}, nil)
+	set := NewGenericType("Set", TypeParamList{{Name: "T", Constraint: ordered}}, nil, nil)
+	set.UnderlyingType = &MapType{KeyType: tv, ValueType: Bool}
+	instance, err := InstantiateGenericType(list, []interface{}{String}, TypeEnv{}, nil)
+	if err != nil {
+		t.Fatalf("InstantiateGenericType() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		typ  Type
+	}{
+		{"type variable", tv},
+		{"user constant", &TypeConstant{Name: "Celsius"}},
+		{"named", &NamedType{Name: "Age", Underlying: Int, Methods: MethodSet{"Years": {Name: "Years", Results: []Type{Int}}}}},
+		{"function", &FunctionType{ParamTypes: []Type{tv, &SliceType{ElementType: Int}}, ReturnType: &NoValueType{}, IsVariadic: true, Effects: EffectIO}},
+		{"tuple", &TupleType{Types: []Type{Int, Error}}},
+		{"interface", &stringer},
+		{"interface type", &InterfaceType{Name: "Getter", Methods: MethodSet{"Get": method}, Embedded: []Type{&stringer}}},
+		{"empty interface", &InterfaceType{IsEmpty: true}},
+		{"sum type", NewSumType("Shape", Int, String)},
+		{"method", method},
+		{"recursive struct", node},
+		{"extensible struct", &ExtensibleStruct{Fields: map[string]Type{"x": Int}, Rest: &TypeVariable{Name: "R"}}},
+		{"pointer", &PointerType{Base: tv}},
+		{"slice", &SliceType{ElementType: tv}},
+		{"array", &ArrayType{ElementType: Float64, Len: 4}},
+		{"map", &MapType{KeyType: String, ValueType: &ChanType{Dir: SendOnly, ElementType: Bool}}},
+		{"no value", &NoValueType{}},
+		{"nil", Nil},
+		{"approximation", &ApproxType{Base: Int}},
+		{"channel", &ChanType{Dir: RecvOnly, ElementType: tv}},
+		{"constraint", &TypeConstraint{Interfaces: []Interface{stringer}, IsComparable: true, Excluded: []Type{String}}},
+		{"builtin constraint", Any},
+		{"generic type", list},
+		{"generic defined type", set},
+		{"instance", instance},
+		{"generic function", NewGenericFunction("Max", TypeParamList{{Name: "T", Constraint: ordered}}, &FunctionType{ParamTypes: []Type{tv, tv}, ReturnType: tv})},
+		{"generic method", &GenericMethod{Name: "Map", TypeParams: []Type{&TypeVariable{Name: "U"}}, Method: method}},
+		{"alias", &TypeAlias{Name: "Elem", AliasedTo: tv}},
+		{"package", NewPackageType("go/ast", TypeEnv{"Expr": &InterfaceType{Name: "Expr"}})},
+		{"var", &VarObj{Name: "x", Type: tv}},
+		{"const", &ConstObj{Name: "k", Type: Float64, Val: constant.MakeFloat64(1.5), Untyped: true}},
+		{"string const", &ConstObj{Name: "s", Type: String, Val: constant.MakeString("a \"b\"")}},
+		{"complex const", &ConstObj{Name: "c", Type: Complex128, Val: constant.MakeImag(constant.MakeInt64(2))}},
+		{"type object", &TypeObj{Name: "Elem", Type: tv}},
+		{"function object", &FuncObj{Name: "f", Type: &FunctionType{ParamTypes: []Type{tv}, ReturnType: Int}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeType(tt.typ)
+			if err != nil {
+				t.Fatalf("EncodeType() error = %v", err)
+			}
+			got, err := DecodeType(data)
+			if err != nil {
+				t.Fatalf("DecodeType() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.typ) {
+				t.Errorf("DecodeType() = %s, want %s", FormatGo(got), FormatGo(tt.typ))
+			}
+		})
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[fmt.Sprintf("%T", tt.typ)] = true
+	}
+	for _, kind := range typeKinds {
+		if !covered[fmt.Sprintf("%T", kind)] {
+			t.Errorf("no round trip test for %T", kind)
+		}
+	}
+}
+
+func TestEncodeTypeSharing(t *testing.T) {
+	node := &StructType{Name: "Node"}
+	node.Fields = map[string]Type{"next": &PointerType{Base: node}}
+	data, err := EncodeType(&TupleType{Types: []Type{node, node, Int}})
+	if err != nil {
+		t.Fatalf("EncodeType() error = %v", err)
+	}
+	got, err := DecodeType(data)
+	if err != nil {
+		t.Fatalf("DecodeType() error = %v", err)
+	}
+
+	types := got.(*TupleType).Types
+	decoded := types[0].(*StructType)
+	if types[1] != Type(decoded) {
+		t.Errorf("shared struct decoded twice")
+	}
+	if next := decoded.Fields["next"].(*PointerType).Base; next != Type(decoded) {
+		t.Errorf("Node.next = %p, want the decoded Node %p", next, decoded)
+	}
+	if types[2] != Type(Int) {
+		t.Errorf("int decoded to %p, want the predeclared Int", types[2])
+	}
+}
+
+func TestEncodeEnv(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", envSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	data, err := EncodeEnv(env)
+	if err != nil {
+		t.Fatalf("EncodeEnv() error = %v", err)
+	}
+	decoded, err := DecodeEnv(data)
+	if err != nil {
+		t.Fatalf("DecodeEnv() error = %v", err)
+	}
+
+	if len(decoded) != len(env) {
+		t.Errorf("DecodeEnv() has %d names, want %d", len(decoded), len(env))
+	}
+	for name, want := range env {
+		if got := decoded[name]; FormatGo(got) != FormatGo(want) || !TypesEqual(got, want) {
+			t.Errorf("decoded[%s] = %s, want %s", name, FormatGo(got), FormatGo(want))
+		}
+	}
+
+	for _, src := range []string{"Max[int](1, 2)", "List[int]{}", "double(3)"} {
+		want, err := InferType(mustParseExpr(t, src), env, nil)
+		if err != nil {
+			t.Fatalf("InferType(%s) error = %v", src, err)
+		}
+		got, err := InferType(mustParseExpr(t, src), decoded, nil)
+		if err != nil || !TypesEqual(got, want) {
+			t.Errorf("InferType(%s) with the decoded env = %v, %v, want %s", src, FormatGo(got), err, FormatGo(want))
+		}
+	}
+}
+
+func TestDecodeTypeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"invalid JSON", `{`},
+		{"unknown kind", `{"root": 1, "nodes": [{"kind": "Unknown"}]}`},
+		{"invalid reference", `{"root": 1, "nodes": [{"kind": "SliceType", "elem": 3}]}`},
+		{"invalid method", `{"root": 1, "nodes": [{"kind": "StructType", "methods": {"M": 1}}]}`},
+		{"invalid constraint", `{"root": 1, "nodes": [{"kind": "GenericType", "typeParams": [{"name": "T", "constraint": 1}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := DecodeType([]byte(tt.data)); err == nil {
+				t.Errorf("DecodeType() = %v, want an error", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package generic
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// TypeVarFactory produces type variables that are distinct from every other one,
+// so that the type parameters of different generic declarations, which are often all
+// named T, never collide in the environment shared by a unification.
+//
+// Fresh variables are named after the variable they stand for, followed by a number,
+// like `T'3`. The names are not valid Go identifiers, so they never clash with declared
+// type parameters, and FormatGo prints them as the original name.
+type TypeVarFactory struct {
+	next atomic.Uint64
+}
+
+// typeVars is the factory of the type variables introduced by the checker itself.
+// It is shared by all checks, so that fresh variables are unique even across goroutines.
+var typeVars TypeVarFactory
+
+// Fresh returns a new type variable standing for the type variable named name.
+func (f *TypeVarFactory) Fresh(name string) *TypeVariable {
+	return &TypeVariable{Name: fmt.Sprintf("%s'%d", baseName(name), f.next.Add(1))}
+}
+
+// Rename alpha-renames the type variables vars in t to fresh ones, returning the renamed
+// type and the fresh variables, in the order of vars. Elements of vars that are not type
+// variables, like the arguments of a partial instantiation, are kept as is.
+func (f *TypeVarFactory) Rename(t Type, vars []Type) (Type, []Type) {
+	fresh := make([]Type, len(vars))
+	for i, v := range vars {
+		if tv, ok := v.(*TypeVariable); ok {
+			fresh[i] = f.Fresh(tv.Name)
+		} else {
+			fresh[i] = v
+		}
+	}
+	return substituteTypeParams(t, vars, fresh, NewTypeVisitor()), fresh
+}
+
+// freshTypeVariable returns a type variable introduced by inference itself, like the
+// rest of an open struct, that is distinct from every other one.
+func freshTypeVariable(prefix string) *TypeVariable {
+	return &TypeVariable{Name: fmt.Sprintf("$%s%d", prefix, typeVars.next.Add(1))}
+}
+
+// baseName returns the name of the type variable a fresh one stands for, like T for `T'3`.
+func baseName(name string) string {
+	if i := strings.IndexByte(name, '\''); i > 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,179 @@
+package generic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+	"testing"
+)
+
+// unrenamed replaces the fresh type variables in t by the type variables they stand for,
+// so that types can be compared with the declared ones.
+func unrenamed(t Type) Type {
+	return Map(t, func(t Type) Type {
+		if tv, ok := t.(*TypeVariable); ok {
+			return &TypeVariable{Name: baseName(tv.Name)}
+		}
+		return t
+	})
+}
+
+func TestTypeVarFactory(t *testing.T) {
+	var f TypeVarFactory
+	a, b := f.Fresh("T"), f.Fresh("T")
+	if a.Name == b.Name || a.Name == "T" {
+		t.Errorf("Fresh(T) = %s, %s, want distinct fresh variables", a.Name, b.Name)
+	}
+	if got := FormatGo(a); got != "T" {
+		t.Errorf("FormatGo(%s) = %s, want T", a.Name, got)
+	}
+	if c := f.Fresh(a.Name); baseName(c.Name) != "T" {
+		t.Errorf("Fresh(%s) = %s, want a variable standing for T", a.Name, c.Name)
+	}
+
+	var wg sync.WaitGroup
+	names := make([][]string, 4)
+	for i := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				names[i] = append(names[i], f.Fresh("T").Name)
+			}
+		}()
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for _, ns := range names {
+		for _, name := range ns {
+			if seen[name] {
+				t.Fatalf("Fresh() returned %s twice", name)
+			}
+			seen[name] = true
+		}
+	}
+}
+
+func TestTypeVarFactoryRename(t *testing.T) {
+	var f TypeVarFactory
+	tv, k := &TypeVariable{Name: "T"}, &TypeVariable{Name: "K"}
+	pair := &FunctionType{ParamTypes: []Type{tv, k, &SliceType{ElementType: tv}}, ReturnType: &MapType{KeyType: k, ValueType: tv}}
+
+	renamed, fresh := f.Rename(pair, []Type{tv, Int})
+	if fresh[1] != Type(Int) {
+		t.Errorf("Rename() fresh[1] = %v, want int kept as is", fresh[1])
+	}
+	got := renamed.(*FunctionType)
+	if !TypesEqual(got.ParamTypes[0], fresh[0]) || !TypesEqual(got.ParamTypes[2], &SliceType{ElementType: fresh[0]}) {
+		t.Errorf("Rename() = %v, want T renamed to %v", renamed, fresh[0])
+	}
+	if !TypesEqual(got.ParamTypes[1], k) {
+		t.Errorf("Rename() = %v, want K kept as is", renamed)
+	}
+	if !TypesEqual(pair.ParamTypes[0], tv) {
+		t.Errorf("Rename() modified the original type: %v", pair)
+	}
+	if !TypesEqual(unrenamed(renamed), pair) {
+		t.Errorf("Rename() = %v, want %v up to renaming", renamed, pair)
+	}
+}
+
+func TestGenericMethodTypeParamsDoNotCollide(t *testing.T) {
+	// `func F[T any](x T) { _ = c.Convert[int](x) }`: the T of the method is not the T of F
+	tv := &TypeVariable{Name: "T"}
+	convert := &GenericMethod{
+		Name:       "Convert",
+		TypeParams: []Type{tv},
+		Method:     Method{Name: "Convert", Params: []Type{tv}, Results: []Type{tv}},
+	}
+	env := TypeEnv{
+		"T": tv,
+		"x": tv,
+		"c": &StructType{Name: "Converter", Methods: MethodSet{}},
+	}
+	_, err := inferGenericMethod(*convert, []Type{Int}, []ast.Expr{ast.NewIdent("x")}, env, nil)
+	if err == nil {
+		t.Fatal("inferGenericMethod() = nil error, want a mismatch between T and int")
+	}
+	if _, ok := env["T'"]; ok || !TypesEqual(env["T"], tv) {
+		t.Errorf("env[T] = %v, want T unchanged", env["T"])
+	}
+
+	got, err := inferGenericMethod(*convert, []Type{tv}, []ast.Expr{ast.NewIdent("x")}, env, nil)
+	if err != nil {
+		t.Fatalf("inferGenericMethod() error = %v", err)
+	}
+	if !TypesEqual(got, tv) {
+		t.Errorf("inferGenericMethod() = %v, want T", got)
+	}
+}
+
+func TestPartialInstanceTypeParamsAreFresh(t *testing.T) {
+	const src = `package p
+
+func Apply[K comparable, V any](k K, v V) V
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	env, err := BuildEnv([]*ast.File{file})
+	if err != nil {
+		t.Fatalf("BuildEnv() error = %v", err)
+	}
+	// an enclosing declaration with its own V
+	env["V"] = &TypeVariable{Name: "V"}
+
+	a, err := InferType(mustParseExpr(t, "Apply[string]"), env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	b, err := InferType(mustParseExpr(t, "Apply[string]"), env, nil)
+	if err != nil {
+		t.Fatalf("InferType() error = %v", err)
+	}
+	va, vb := a.(*GenericType).TypeParams[1], b.(*GenericType).TypeParams[1]
+	if TypesEqual(va, vb) || TypesEqual(va, env["V"]) {
+		t.Errorf("V of the instances = %v, %v, want distinct fresh variables", va, vb)
+	}
+	if _, err := Unify(va, Int, env); err != nil {
+		t.Fatalf("Unify() error = %v", err)
+	}
+	if !TypesEqual(env["V"], &TypeVariable{Name: "V"}) {
+		t.Errorf("env[V] = %v, want the V of the enclosing declaration unbound", env["V"])
+	}
+	if got := FormatGo(va); got != "V" {
+		t.Errorf("FormatGo(%v) = %s, want V", va, got)
+	}
+}
+
+func TestUnifyTypeParamInScope(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	tests := []struct {
+		name    string
+		t1, t2  Type
+		wantErr bool
+	}{
+		{name: "itself", t1: tv, t2: &TypeVariable{Name: "T"}},
+		{name: "other type", t1: tv, t2: Int, wantErr: true},
+		{name: "other type reversed", t1: &SliceType{ElementType: Int}, t2: &SliceType{ElementType: tv}, wantErr: true},
+		{name: "fresh variable", t1: tv, t2: &TypeVariable{Name: "U'1"}},
+		{name: "fresh variable reversed", t1: &TypeVariable{Name: "U'1"}, t2: tv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := TypeEnv{"T": tv}
+			_, err := Unify(tt.t1, tt.t2, env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !TypesEqual(env["T"], tv) {
+				t.Errorf("env[T] = %v, want T in scope", env["T"])
+			}
+			if u, ok := env["U'1"]; ok && !TypesEqual(u, tv) {
+				t.Errorf("env[U'1] = %v, want T", u)
+			}
+		})
+	}
+}
@@ -3,53 +3,172 @@ package generic
 import (
 	"errors"
 	"fmt"
+
+	gerrors "github.com/notJoon/generic/errors"
 )
 
 var (
-	ErrTypeMismatch      = errors.New("type mismatch")
-	ErrArityMismatch     = errors.New("number of parameters do not match")
-	ErrUnknownType       = errors.New("unknown type")
-	ErrCircularReference = errors.New("circular reference detected")
+	ErrTypeMismatch      = gerrors.ErrTypeMismatch
+	ErrArityMismatch     = gerrors.ErrArityMismatch
+	ErrUnknownType       = gerrors.ErrUnknownType
+	ErrCircularReference = gerrors.ErrCircularReference
+	ErrAmbiguous         = gerrors.ErrAmbiguous
 )
 
-// Unify attempts to unify two types t1 and t2, updating the type environment env.
+// Unify attempts to unify two types t1 and t2 in the type environment env.
 // Unification is a key operation in type inference, where it tries to make two types
 // equivalent by finding a substitution that makes them equal.
 //
-// It returns an error if the types cannot be unified.
+// It returns that substitution, or an error if the types cannot be unified. The bindings
+// already in env are used, but env is left unchanged and they are not part of the
+// substitution.
 //
 // ## Process
 //
 // Unify(t1, t2, env) =
-//  1. t1' = resolve(t1, env)
-//  2. t2' = resolve(t2, env)
+//  1. t1' = unalias(resolve(t1, env))
+//  2. t2' = unalias(resolve(t2, env))
 //  3. case (t1', t2') of
+//     (_, NilType) → if t1' is a pointer, slice, map, channel, function or interface then ok else error
 //     (TypeVariable v, _) → unifyVar(v, t2', env)
+//     (_, TypeVariable v) → unifyVar(v, t1', env)
 //     (TypeConstant c1, TypeConstant c2) → if c1.Name = c2.Name then ok else error
 //     (FunctionType f1, FunctionType f2) →
 //     if length(f1.ParamTypes) ≠ length(f2.ParamTypes) then error
 //     else forall i. Unify(f1.ParamTypes[i], f2.ParamTypes[i], env) ∧
 //     Unify(f1.ReturnType, f2.ReturnType, env)
 //     (_, _) → error
-func Unify(t1, t2 Type, env TypeEnv) error {
-	// resolve any type variables to their current bindings
-	t1 = resolve(t1, env)
-	t2 = resolve(t2, env)
+func Unify(t1, t2 Type, env TypeEnv) (Substitution, error) {
+	return unify(t1, t2, env, nil)
+}
+
+// unify is Unify, counting the unifications in the profile of ctx, if any.
+func unify(t1, t2 Type, env TypeEnv, ctx *InferenceContext) (Substitution, error) {
+	u := newUnifier(env, ctx)
+	if err := u.unify(t1, t2); err != nil {
+		return nil, err
+	}
+	return u.subst, nil
+}
+
+// unifyInto unifies t1 and t2 like Unify, and binds the type variables it solves in env,
+// for the bindings to hold in what is inferred next, like the result of a call.
+func unifyInto(t1, t2 Type, env TypeEnv, ctx *InferenceContext) error {
+	s, err := unify(t1, t2, env, ctx)
+	if err != nil {
+		return err
+	}
+	s.bindIn(env)
+	return nil
+}
+
+// unifier solves the equations between types in an environment it leaves unchanged.
+// The type variables it binds are recorded in subst, in the order of trail, so that the
+// bindings of an attempt that failed can be undone, see try. The unifications are counted
+// in profile, if set. depth is that of the types being unified, 1 for the types given.
+type unifier struct {
+	env     TypeEnv
+	subst   Substitution
+	trail   []string
+	profile *Profile
+	depth   int
+}
+
+// newUnifier returns a unifier in env, counting the unifications in the profile of ctx.
+func newUnifier(env TypeEnv, ctx *InferenceContext) *unifier {
+	u := &unifier{env: env, subst: make(Substitution)}
+	if ctx != nil {
+		u.profile = ctx.Profile
+	}
+	return u
+}
+
+// lookup returns the type the type variable name is bound to, by the unifier or env.
+func (u *unifier) lookup(name string) (Type, bool) {
+	if t, ok := u.subst[name]; ok {
+		return t, true
+	}
+	t, ok := u.env[name]
+	return t, ok
+}
+
+func (u *unifier) bind(name string, t Type) {
+	u.subst[name] = t
+	u.trail = append(u.trail, name)
+}
+
+// undo removes the bindings made since the trail had length mark.
+func (u *unifier) undo(mark int) {
+	for _, name := range u.trail[mark:] {
+		delete(u.subst, name)
+	}
+	u.trail = u.trail[:mark]
+}
+
+// try unifies t1 and t2, and undoes the bindings it made if they cannot be unified.
+func (u *unifier) try(t1, t2 Type) error {
+	mark := len(u.trail)
+	if err := u.unify(t1, t2); err != nil {
+		u.undo(mark)
+		return err
+	}
+	return nil
+}
+
+func (u *unifier) unify(t1, t2 Type) error {
+	if u.profile != nil {
+		u.profile.unifications.Add(1)
+	}
+	u.depth++
+	defer func() { u.depth-- }()
+	// objects unify as their types, type variables as their current bindings, and aliases
+	// as the types they stand for, while defined types stay distinct, see NamedType
+	t1 = unalias(resolve(unwrapObject(t1), u))
+	t2 = unalias(resolve(unwrapObject(t2), u))
 
 	if isInterfaceAny(t1) || isInterfaceAny(t2) {
 		return nil
 	}
 
+	// nil is a value of the pointer, slice, map, channel, function and interface types only
+	if isNil(t2) {
+		if _, ok := t1.(*TypeVariable); !ok {
+			if !isNilable(t1) {
+				return ErrTypeMismatch
+			}
+			return nil
+		}
+	}
+
+	// a type variable on the right binds like one on the left, as for the type parameters
+	// of a generic function passed as an argument, like `Identity` for `func(F) T`
+	if tv, ok := t2.(*TypeVariable); ok && !isRigid(tv, u) {
+		if _, ok := t1.(*TypeVariable); !ok {
+			return u.unifyVar(tv, t1)
+		}
+	}
+
 	switch t1 := t1.(type) {
 	case *TypeVariable:
-		return unifyVar(t1, t2, env)
+		return u.unifyVar(t1, t2)
 	case *TypeConstant:
 		t2, ok := t2.(*TypeConstant)
 		if !ok || t1.Name != t2.Name {
 			return ErrTypeMismatch
 		}
 		return nil
+	case *NamedType:
+		// defined types are only identical to themselves
+		t2, ok := t2.(*NamedType)
+		if !ok || t1.Name != t2.Name {
+			return ErrTypeMismatch
+		}
+		return nil
 	case *FunctionType:
+		// an instantiated generic function is assignable to its signature
+		if gt, ok := t2.(*GenericType); ok && gt.Signature != nil {
+			t2 = gt.Underlying()
+		}
 		t2Func, ok := t2.(*FunctionType)
 		if !ok {
 			return ErrTypeMismatch
@@ -61,11 +180,24 @@ func Unify(t1, t2 Type, env TypeEnv) error {
 			return ErrArityMismatch
 		}
 		for i := range t1.ParamTypes {
-			if err := Unify(t1.ParamTypes[i], t2Func.ParamTypes[i], env); err != nil {
+			if err := u.unify(t1.ParamTypes[i], t2Func.ParamTypes[i]); err != nil {
 				return err
 			}
 		}
-		return Unify(t1.ReturnType, t2Func.ReturnType, env)
+		// an annotated function type only admits functions with the effects it allows
+		if !t1.Effects.Allows(t2Func.Effects) {
+			return fmt.Errorf("%w: %v where only %v are allowed", ErrEffectNotAllowed, t2Func.Effects, t1.Effects)
+		}
+		// functions without results have no return type, or NoValueType
+		noResults1 := t1.ReturnType == nil || isNoValue(t1.ReturnType)
+		noResults2 := t2Func.ReturnType == nil || isNoValue(t2Func.ReturnType)
+		if noResults1 || noResults2 {
+			if noResults1 != noResults2 {
+				return ErrTypeMismatch
+			}
+			return nil
+		}
+		return u.unify(t1.ReturnType, t2Func.ReturnType)
 	case *TupleType:
 		t2Tuple, ok := t2.(*TupleType)
 		if !ok {
@@ -75,47 +207,77 @@ func Unify(t1, t2 Type, env TypeEnv) error {
 			return ErrArityMismatch
 		}
 		for i := range t1.Types {
-			if err := Unify(t1.Types[i], t2Tuple.Types[i], env); err != nil {
+			if err := u.unify(t1.Types[i], t2Tuple.Types[i]); err != nil {
 				return err
 			}
 		}
 		return nil
 	case *SliceType:
 		if t2Slice, ok := t2.(*SliceType); ok {
-			return Unify(t1.ElementType, t2Slice.ElementType, env)
+			return u.unify(t1.ElementType, t2Slice.ElementType)
 		}
 		return ErrTypeMismatch
 	case *GenericType:
+		if _, ok := t2.(*FunctionType); ok && t1.Signature != nil {
+			return u.unify(t1.Underlying(), t2)
+		}
+		if es, ok := t2.(*ExtensibleStruct); ok {
+			return u.unifyRows(es, t1)
+		}
 		t2Generic, ok := t2.(*GenericType)
 		if !ok || t1.Name != t2Generic.Name || len(t1.TypeParams) != len(t2Generic.TypeParams) {
 			return ErrTypeMismatch
 		}
 		for i := range t1.TypeParams {
-			if err := Unify(t1.TypeParams[i], t2Generic.TypeParams[i], env); err != nil {
+			if err := u.unify(t1.TypeParams[i], t2Generic.TypeParams[i]); err != nil {
 				return err
 			}
 		}
 		return nil
 	case *InterfaceType:
-		if t1.IsEmpty || t2.(*InterfaceType).IsEmpty {
+		t2Interface, ok := t2.(*InterfaceType)
+		if !ok {
+			// a variant can be used where its sum type is expected. A generic variant,
+			// like `Some[T]`, binds its type parameters to those of the value.
+			if t1.Variants == nil {
+				return ErrTypeMismatch
+			}
+			if IsVariant(t1, t2) {
+				return nil
+			}
+			if _, err := u.tryUnify(t2, t1.Variants); err != nil {
+				if errors.Is(err, ErrAmbiguous) {
+					return err
+				}
+				return ErrTypeMismatch
+			}
 			return nil
 		}
-		t2Interface, ok := t2.(*InterfaceType)
-		if !ok || t1.Name != t2Interface.Name {
+		if t1.IsEmpty || t2Interface.IsEmpty {
+			return nil
+		}
+		if t1.Name != t2Interface.Name || len(t1.Variants) != len(t2Interface.Variants) {
 			return ErrTypeMismatch
 		}
-		for name, method1 := range t1.Methods {
+		for i := range t1.Variants {
+			if err := u.unify(t1.Variants[i], t2Interface.Variants[i]); err != nil {
+				return err
+			}
+		}
+		// in the order of the names, so that a failed unification always leaves the same bindings
+		for _, name := range sortedKeys(t1.Methods) {
+			method1 := t1.Methods[name]
 			method2, ok := t2Interface.Methods[name]
 			if !ok {
 				return ErrTypeMismatch
 			}
 			// unify method signatures
-			if err := unifyMethod(method1, method2, env); err != nil {
+			if err := u.unifyMethod(method1, method2); err != nil {
 				return err
 			}
 		}
 		for _, embedded := range t1.Embedded {
-			if err := Unify(embedded, t2, env); err != nil {
+			if err := u.unify(embedded, t2); err != nil {
 				return err
 			}
 		}
@@ -125,24 +287,184 @@ func Unify(t1, t2 Type, env TypeEnv) error {
 		if !ok {
 			return ErrTypeMismatch
 		}
-		if err := Unify(t1.KeyType, t2Map.KeyType, env); err != nil {
+		if err := u.unify(t1.KeyType, t2Map.KeyType); err != nil {
 			return err
 		}
-		return Unify(t1.ValueType, t2Map.ValueType, env)
+		return u.unify(t1.ValueType, t2Map.ValueType)
 	case *PointerType:
 		t2Ptr, ok := t2.(*PointerType)
 		if !ok {
 			return ErrTypeMismatch
 		}
-		return Unify(t1.Base, t2Ptr.Base, env)
+		return u.unify(t1.Base, t2Ptr.Base)
+	case *NoValueType:
+		if _, ok := t2.(*NoValueType); !ok {
+			return ErrTypeMismatch
+		}
+		return nil
+	case *NilType:
+		if !isNilable(t2) {
+			return ErrTypeMismatch
+		}
+		return nil
+	case *PackageType:
+		if !TypesEqual(t1, t2) {
+			return ErrTypeMismatch
+		}
+		return nil
+	case *ArrayType:
+		t2Array, ok := t2.(*ArrayType)
+		if !ok || t1.Len != t2Array.Len {
+			return ErrTypeMismatch
+		}
+		return u.unify(t1.ElementType, t2Array.ElementType)
+	case *ChanType:
+		// a bidirectional channel value can be used as a send-only or receive-only one,
+		// but a directional one never as a bidirectional one. Only the channel itself is
+		// assigned, so the channels within other types, like `[]chan int`, are identical.
+		t2Chan, ok := t2.(*ChanType)
+		if !ok || t1.Dir != t2Chan.Dir && (t2Chan.Dir != SendRecv || u.depth > 1) {
+			return ErrTypeMismatch
+		}
+		return u.unify(t1.ElementType, t2Chan.ElementType)
+	case *ExtensibleStruct:
+		return u.unifyRows(t1, t2)
+	case *StructType:
+		if es, ok := t2.(*ExtensibleStruct); ok {
+			return u.unifyRows(es, t1)
+		}
+		t2Struct, ok := t2.(*StructType)
+		if !ok {
+			return ErrTypeMismatch
+		}
+		return identicalStructs(t1, t2Struct, func(fld1, fld2 Type) error {
+			return u.unify(fld1, fld2)
+		})
+	case *ApproxType:
+		t2Approx, ok := t2.(*ApproxType)
+		if !ok {
+			return ErrTypeMismatch
+		}
+		return u.unify(t1.Base, t2Approx.Base)
+	case *Interface:
+		t2Interface, ok := t2.(*Interface)
+		if !ok || t1.Name != t2Interface.Name || len(t1.Methods) != len(t2Interface.Methods) {
+			return ErrTypeMismatch
+		}
+		for _, name := range sortedKeys(t1.Methods) {
+			method1 := t1.Methods[name]
+			method2, ok := t2Interface.Methods[name]
+			if !ok {
+				return ErrTypeMismatch
+			}
+			if err := u.unifyMethod(method1, method2); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Method:
+		t2Method, ok := t2.(Method)
+		if !ok {
+			return ErrTypeMismatch
+		}
+		return u.unifyMethod(t1, t2Method)
+	case *GenericMethod:
+		t2Method, ok := t2.(*GenericMethod)
+		if !ok || t1.Name != t2Method.Name || !typeListsEqual(t1.TypeParams, t2Method.TypeParams) {
+			return ErrTypeMismatch
+		}
+		return u.unifyMethod(t1.Method, t2Method.Method)
+	case *TypeConstraint:
+		// constraints have no type variables to solve, they are either identical or not
+		if !TypesEqual(t1, t2) {
+			return ErrTypeMismatch
+		}
+		return nil
 	}
 	return ErrUnknownType
 }
 
+// TypePair is an equation between two types, see UnifyAll.
+type TypePair struct {
+	Left, Right Type
+
+	// Context describes where the equation comes from, like "argument type mismatch for arg 0".
+	// It prefixes the error if the types cannot be unified.
+	Context string
+}
+
+// UnifyAll unifies the types of every pair, like Unify, in a single substitution:
+// the type variables bound by a pair are seen by the following pairs.
+//
+// Unlike unifying the pairs one by one, it does not stop at the first mismatch.
+// The errors of all the pairs that cannot be unified are joined into a single error,
+// each prefixed by the context of its pair, or its index if it has none. The substitution
+// holds the bindings of the pairs that unify.
+func UnifyAll(pairs []TypePair, env TypeEnv) (Substitution, error) {
+	return unifyAll(pairs, env, nil)
+}
+
+// unifyAll is UnifyAll, counting the unifications in the profile of ctx, if any.
+func unifyAll(pairs []TypePair, env TypeEnv, ctx *InferenceContext) (Substitution, error) {
+	u := newUnifier(env, ctx)
+	var errs []error
+	for i, p := range pairs {
+		if err := u.try(p.Left, p.Right); err != nil {
+			if p.Context == "" {
+				p.Context = fmt.Sprintf("pair %d", i)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", p.Context, err))
+		}
+	}
+	return u.subst, errors.Join(errs...)
+}
+
+// TryUnify unifies t with each of the candidates, like the members of a union, and
+// returns the index of the only candidate t unifies with, along with the substitution.
+//
+// If t unifies with none of the candidates, the error wraps ErrTypeMismatch. If it unifies
+// with several, the error wraps ErrAmbiguous and names them.
+func TryUnify(t Type, candidates []Type, env TypeEnv) (int, Substitution, error) {
+	u := newUnifier(env, nil)
+	match, err := u.tryUnify(t, candidates)
+	if err != nil {
+		return -1, nil, err
+	}
+	return match, u.subst, nil
+}
+
+// tryUnify is TryUnify, keeping the bindings of the candidate that matches. Each candidate
+// is unified in turn, its bindings undone before the next one, and those of the match made
+// again once it is known to be the only one.
+func (u *unifier) tryUnify(t Type, candidates []Type) (int, error) {
+	match := -1
+	var matches []Type
+	for i, candidate := range candidates {
+		mark := len(u.trail)
+		if err := u.unify(candidate, t); err != nil {
+			u.undo(mark)
+			continue
+		}
+		u.undo(mark)
+		if match < 0 {
+			match = i
+		}
+		matches = append(matches, candidate)
+	}
+
+	switch {
+	case len(matches) == 0:
+		return -1, fmt.Errorf("%w: %s matches none of %s", ErrTypeMismatch, FormatGo(t), formatTypes(candidates))
+	case len(matches) > 1:
+		return -1, fmt.Errorf("%w: %s matches %s", ErrAmbiguous, FormatGo(t), formatTypes(matches))
+	}
+	return match, u.unify(candidates[match], t)
+}
+
 // unifyMethod unifies two method signatures, ensuring that they have the same name,
-// pointer type, and matching parameter and result types.
-func unifyMethod(m1, m2 Method, env TypeEnv) error {
-	if m1.Name != m2.Name || m1.IsPointer != m2.IsPointer {
+// pointer type, variadic-ness, and matching parameter and result types.
+func (u *unifier) unifyMethod(m1, m2 Method) error {
+	if m1.Name != m2.Name || m1.IsPointer != m2.IsPointer || m1.IsVariadic != m2.IsVariadic {
 		return ErrTypeMismatch
 	}
 	if len(m1.Params) != len(m2.Params) || len(m1.Results) != len(m2.Results) {
@@ -150,7 +472,12 @@ func unifyMethod(m1, m2 Method, env TypeEnv) error {
 	}
 
 	for i := range m1.Params {
-		if err := Unify(m1.Params[i], m2.Params[i], env); err != nil {
+		if err := u.unify(m1.Params[i], m2.Params[i]); err != nil {
+			return err
+		}
+	}
+	for i := range m1.Results {
+		if err := u.unify(m1.Results[i], m2.Results[i]); err != nil {
 			return err
 		}
 	}
@@ -158,7 +485,7 @@ func unifyMethod(m1, m2 Method, env TypeEnv) error {
 	return nil
 }
 
-// unifyVar unifies a type variable with another type, updating the type environment env.
+// unifyVar unifies a type variable with another type, recording the binding in u.subst.
 // This is a helper function for the unify operation, specifically handling the case
 // where one of the types a `TypeVariable`.
 //
@@ -175,23 +502,40 @@ func unifyMethod(m1, m2 Method, env TypeEnv) error {
 //	if v = t' then ok
 //	else if occurs(v, t', env) then error
 //	else env[v.Name] ← t'
-func unifyVar(v *TypeVariable, t Type, env TypeEnv) error {
-	t = resolve(t, env)
+func (u *unifier) unifyVar(v *TypeVariable, t Type) error {
+	t = resolve(t, u)
 	if v == t {
 		return nil
 	}
-	if resolved, ok := env[v.Name]; ok {
-		return Unify(resolved, t, env)
+	if t == nil {
+		// the missing result of a function without results, like T in `func(F) T` for `func(int)`
+		return ErrTypeMismatch
+	}
+	if isRigid(v, u) {
+		// a type parameter in scope stands for an unknown type, identical only to itself
+		if tv, ok := t.(*TypeVariable); ok && !isRigid(tv, u) {
+			return u.unifyVar(tv, v)
+		}
+		if tv, ok := t.(*TypeVariable); ok && tv.Name == v.Name {
+			return nil
+		}
+		return ErrTypeMismatch
+	}
+	if resolved, ok := u.lookup(v.Name); ok {
+		return u.unify(resolved, t)
 	}
 	if tv, ok := t.(*TypeVariable); ok {
-		if resolved, ok := env[tv.Name]; ok {
-			return unifyVar(v, resolved, env)
+		if tv.Name == v.Name {
+			return nil
+		}
+		if resolved, ok := u.lookup(tv.Name); ok && !isRigid(tv, u) {
+			return u.unifyVar(v, resolved)
 		}
 	}
-	if occurs(v, t, env) {
+	if occurs(v, t, u) {
 		return ErrCircularReference
 	}
-	env[v.Name] = t
+	u.bind(v.Name, t)
 	return nil
 }
 
@@ -214,14 +558,14 @@ func unifyVar(v *TypeVariable, t Type, env TypeEnv) error {
 //	  TypeVariable v' → v = v'
 //	  FunctionType f → ∃p ∈ f.ParamTypes. occurs(v, p, env) ∨ occurs(v, f.ReturnType, env)
 //	  _ → false
-func occurs(v *TypeVariable, t Type, env TypeEnv) bool {
+func occurs(v *TypeVariable, t Type, env bindings) bool {
 	t = resolve(t, env)
 	switch t := t.(type) {
 	case *TypeVariable:
-		if v == t {
+		if v == t || v.Name == t.Name {
 			return true
 		}
-		if resolved, ok := env[t.Name]; ok {
+		if resolved, ok := env.lookup(t.Name); ok && !isRigid(t, env) {
 			return occurs(v, resolved, env)
 		}
 		return false
@@ -232,6 +576,30 @@ func occurs(v *TypeVariable, t Type, env TypeEnv) bool {
 			}
 		}
 		return occurs(v, t.ReturnType, env)
+	case *ExtensibleStruct:
+		for _, fieldType := range t.Fields {
+			if occurs(v, fieldType, env) {
+				return true
+			}
+		}
+		return t.Rest != nil && occurs(v, t.Rest, env)
+	case *StructType:
+		for _, fieldType := range t.Fields {
+			if occurs(v, fieldType, env) {
+				return true
+			}
+		}
+		return false
+	case *ArrayType:
+		return occurs(v, t.ElementType, env)
+	case *SliceType:
+		return occurs(v, t.ElementType, env)
+	case *PointerType:
+		return occurs(v, t.Base, env)
+	case *MapType:
+		return occurs(v, t.KeyType, env) || occurs(v, t.ValueType, env)
+	case *ChanType:
+		return occurs(v, t.ElementType, env)
 	default:
 		return false
 	}
@@ -249,10 +617,10 @@ func occurs(v *TypeVariable, t Type, env TypeEnv) bool {
 //
 //	TypeVariable v → if v.Name ∈ dom(env) then resolve(env(v.Name), env) else t
 //	_ → t
-func resolve(t Type, env TypeEnv) Type {
+func resolve(t Type, env bindings) Type {
 	for {
-		if tv, ok := t.(*TypeVariable); ok {
-			if resolved, exists := env[tv.Name]; exists {
+		if tv, ok := t.(*TypeVariable); ok && !isRigid(tv, env) {
+			if resolved, exists := env.lookup(tv.Name); exists {
 				t = resolved
 			} else {
 				return t
@@ -276,3 +644,23 @@ func isInterfaceAny(t Type) bool {
 	}
 	return false
 }
+
+// isRigid reports whether v is a type parameter in scope, like T in the body of
+// `func F[T any]()`, which the environment binds to itself, see typeParamScope.
+// Unlike the variables inference solves for, it is never bound to another type.
+func isRigid(v *TypeVariable, env bindings) bool {
+	t, _ := env.lookup(v.Name)
+	bound, ok := t.(*TypeVariable)
+	return ok && bound.Name == v.Name
+}
+
+// bindings looks up the types type variables are bound to, by name, like a TypeEnv or
+// a unifier, which sees its own bindings before those of its environment.
+type bindings interface {
+	lookup(name string) (Type, bool)
+}
+
+func (env TypeEnv) lookup(name string) (Type, bool) {
+	t, ok := env[name]
+	return t, ok
+}
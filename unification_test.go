@@ -1,6 +1,7 @@
 package generic
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -10,7 +11,7 @@ func TestUnifyIdenticalTypeVariables(t *testing.T) {
 	tv1 := &TypeVariable{Name: "T"}
 	tv2 := &TypeVariable{Name: "T"}
 
-	err := Unify(tv1, tv2, env)
+	_, err := Unify(tv1, tv2, env)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -22,12 +23,15 @@ func TestUnifyTypeVariableWithTypeConstant(t *testing.T) {
 	tv := &TypeVariable{Name: "T"}
 	tc := &TypeConstant{Name: "int"}
 
-	err := Unify(tv, tc, env)
+	s, err := Unify(tv, tc, env)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
-	if env["T"] != tc {
-		t.Fatalf("Expected T to be unified with int, got %v", env["T"])
+	if s["T"] != tc {
+		t.Fatalf("Expected T to be unified with int, got %v", s["T"])
+	}
+	if len(env) != 0 {
+		t.Fatalf("Expected env to be unchanged, got %v", env)
 	}
 }
 
@@ -37,7 +41,7 @@ func TestUnifyDifferentTypeConstants(t *testing.T) {
 	tc1 := &TypeConstant{Name: "int"}
 	tc2 := &TypeConstant{Name: "string"}
 
-	err := Unify(tc1, tc2, env)
+	_, err := Unify(tc1, tc2, env)
 	if err == nil {
 		t.Fatalf("Expected error, got nil")
 	}
@@ -55,7 +59,7 @@ func TestUnifyFunctionTypes(t *testing.T) {
 		ReturnType: &TypeConstant{Name: "int"},
 	}
 
-	err := Unify(ft1, ft2, env)
+	_, err := Unify(ft1, ft2, env)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -73,7 +77,7 @@ func TestUnifyFunctionTypesDifferentArity(t *testing.T) {
 		ReturnType: &TypeConstant{Name: "int"},
 	}
 
-	err := Unify(ft1, ft2, env)
+	_, err := Unify(ft1, ft2, env)
 	if err == nil {
 		t.Fatalf("Expected error, got nil")
 	}
@@ -88,7 +92,7 @@ func TestCircularReference(t *testing.T) {
 		ReturnType: tv,
 	}
 
-	err := Unify(tv, ft, env)
+	_, err := Unify(tv, ft, env)
 	if err != ErrCircularReference {
 		t.Fatalf("Expected ErrCircularReference, got %v", err)
 	}
@@ -108,12 +112,13 @@ func TestNestedCircularReference(t *testing.T) {
 		ReturnType: &TypeConstant{Name: "string"},
 	}
 
-	err := Unify(tv1, ft1, env)
+	s, err := Unify(tv1, ft1, env)
 	if err != nil {
 		t.Fatalf("Expected no error in first unification, got %v", err)
 	}
+	s.bindIn(env)
 
-	err = Unify(tv2, ft2, env)
+	_, err = Unify(tv2, ft2, env)
 	if err != ErrCircularReference {
 		t.Fatalf("Expected ErrCircularReference in second unification, got %v", err)
 	}
@@ -193,7 +198,7 @@ func TestUnifyInterface(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			env := TypeEnv{}
-			err := Unify(tt.t1, tt.t2, env)
+			_, err := Unify(tt.t1, tt.t2, env)
 			if (err != nil) != (tt.wantErr != nil) {
 				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -234,7 +239,7 @@ func TestUnifyTupleType(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			env := TypeEnv{}
-			err := Unify(tt.t1, tt.t2, env)
+			_, err := Unify(tt.t1, tt.t2, env)
 			if err != tt.wantErr {
 				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -290,7 +295,7 @@ func TestUnifyGenericType(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			env := TypeEnv{}
-			err := Unify(tt.t1, tt.t2, env)
+			_, err := Unify(tt.t1, tt.t2, env)
 			if err != tt.wantErr {
 				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -328,7 +333,7 @@ func TestUnifyMapType(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			env := TypeEnv{}
-			err := Unify(tt.t1, tt.t2, env)
+			_, err := Unify(tt.t1, tt.t2, env)
 			if err != tt.wantErr {
 				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -366,10 +371,395 @@ func TestUnifyPointerType(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			env := TypeEnv{}
-			err := Unify(tt.t1, tt.t2, env)
+			_, err := Unify(tt.t1, tt.t2, env)
 			if err != tt.wantErr {
 				t.Errorf("Unify() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestUnifyArrayType(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	tests := []struct {
+		name    string
+		t1      Type
+		t2      Type
+		wantErr error
+		want    Type
+	}{
+		{
+			name: "Identical array types",
+			t1:   &ArrayType{ElementType: Int, Len: 4},
+			t2:   &ArrayType{ElementType: Int, Len: 4},
+		},
+		{
+			name: "Element type variable",
+			t1:   &ArrayType{ElementType: tv, Len: 4},
+			t2:   &ArrayType{ElementType: String, Len: 4},
+			want: String,
+		},
+		{
+			name:    "Different lengths",
+			t1:      &ArrayType{ElementType: Int, Len: 4},
+			t2:      &ArrayType{ElementType: Int, Len: 8},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Different element types",
+			t1:      &ArrayType{ElementType: Int, Len: 4},
+			t2:      &ArrayType{ElementType: String, Len: 4},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Array with slice",
+			t1:      &ArrayType{ElementType: Int, Len: 4},
+			t2:      &SliceType{ElementType: Int},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Variable in its own element type",
+			t1:      tv,
+			t2:      &ArrayType{ElementType: tv, Len: 2},
+			wantErr: ErrCircularReference,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Unify(tt.t1, tt.t2, TypeEnv{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !TypesEqual(s["T"], tt.want) {
+				t.Errorf("Unify() bound T to %v, want %v", s["T"], tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifyStructType(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	point := func(fields map[string]Type, order ...string) *StructType {
+		return &StructType{Fields: fields, FieldOrder: order}
+	}
+	tests := []struct {
+		name    string
+		t1      Type
+		t2      Type
+		wantErr error
+		want    Type
+	}{
+		{
+			name: "Same named structs",
+			t1:   &StructType{Name: "Point", Fields: map[string]Type{"X": Int}},
+			t2:   &StructType{Name: "Point", Fields: map[string]Type{"X": Int}, Methods: MethodSet{"String": Method{Name: "String"}}},
+		},
+		{
+			name:    "Different named structs",
+			t1:      &StructType{Name: "Point", Fields: map[string]Type{"X": Int}},
+			t2:      &StructType{Name: "Vec", Fields: map[string]Type{"X": Int}},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Named and anonymous structs",
+			t1:      &StructType{Name: "Point", Fields: map[string]Type{"X": Int}},
+			t2:      point(map[string]Type{"X": Int}),
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "Identical anonymous structs",
+			t1:   point(map[string]Type{"X": Int, "Y": String}, "X", "Y"),
+			t2:   point(map[string]Type{"X": Int, "Y": String}, "X", "Y"),
+		},
+		{
+			name: "Field type variable",
+			t1:   point(map[string]Type{"X": tv, "Y": String}),
+			t2:   point(map[string]Type{"X": Float64, "Y": String}),
+			want: Float64,
+		},
+		{
+			name: "Field order unknown on one side",
+			t1:   point(map[string]Type{"X": Int, "Y": String}, "Y", "X"),
+			t2:   point(map[string]Type{"X": Int, "Y": String}),
+		},
+		{
+			name:    "Different field order",
+			t1:      point(map[string]Type{"X": Int, "Y": String}, "Y", "X"),
+			t2:      point(map[string]Type{"X": Int, "Y": String}, "X", "Y"),
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Different field names",
+			t1:      point(map[string]Type{"X": Int}),
+			t2:      point(map[string]Type{"Y": Int}),
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Different field types",
+			t1:      point(map[string]Type{"X": Int}),
+			t2:      point(map[string]Type{"X": String}),
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "Different field counts",
+			t1:      point(map[string]Type{"X": Int}),
+			t2:      point(map[string]Type{"X": Int, "Y": Int}),
+			wantErr: ErrTypeMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Unify(tt.t1, tt.t2, TypeEnv{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Unify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !TypesEqual(s["T"], tt.want) {
+				t.Errorf("Unify() bound T to %v, want %v", s["T"], tt.want)
+			}
+			// without type variables, unification is identity
+			if tt.want == nil {
+				if got := TypesEqual(tt.t1, tt.t2); got != (tt.wantErr == nil) {
+					t.Errorf("TypesEqual() = %v, want %v", got, tt.wantErr == nil)
+				}
+			}
+		})
+	}
+}
+
+func TestAssignableStructs(t *testing.T) {
+	fields := map[string]Type{"X": Int}
+	point := &StructType{Name: "Point", Fields: fields}
+	tests := []struct {
+		name    string
+		t, v    Type
+		wantErr bool
+	}{
+		{name: "named to anonymous", t: &StructType{Fields: fields}, v: point},
+		{name: "anonymous to named", t: point, v: &StructType{Fields: fields}},
+		{name: "named to other named", t: &StructType{Name: "Vec", Fields: fields}, v: point, wantErr: true},
+		{name: "named to other fields", t: &StructType{Fields: map[string]Type{"Y": Int}}, v: point, wantErr: true},
+		{name: "defined type of a named struct", t: &StructType{Fields: fields}, v: &NamedType{Name: "Origin", Underlying: point}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := assignable(tt.t, tt.v, TypeEnv{}, nil); (err != nil) != tt.wantErr {
+				t.Errorf("assignable(%s, %s) error = %v, wantErr %v", FormatGo(tt.t), FormatGo(tt.v), err, tt.wantErr)
+			}
+		})
+	}
+
+	src := "package p\n\ntype P struct{ X int }\n\nfunc f(p P) struct{ X int } {\n\treturn p\n}\n\nvar q P = struct{ X int }{1}\n"
+	if result := CheckSource(src, nil); len(result.Diagnostics) != 0 {
+		t.Errorf("CheckSource() = %+v, want no diagnostics", result.Diagnostics)
+	}
+}
+
+func TestUnifyAll(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	tests := []struct {
+		name    string
+		pairs   []TypePair
+		wantErr string
+	}{
+		{
+			name:  "shared bindings",
+			pairs: []TypePair{{Left: tv, Right: Int}, {Left: &SliceType{ElementType: tv}, Right: &SliceType{ElementType: Int}}},
+		},
+		{
+			name:    "binding seen by later pairs",
+			pairs:   []TypePair{{Left: tv, Right: Int}, {Left: tv, Right: String, Context: "arg 1"}},
+			wantErr: "arg 1: type mismatch",
+		},
+		{
+			name: "every mismatch reported",
+			pairs: []TypePair{
+				{Left: Int, Right: String, Context: "arg 0"},
+				{Left: Int, Right: Int, Context: "arg 1"},
+				{Left: Bool, Right: Float64, Context: "arg 2"},
+			},
+			wantErr: "arg 0: type mismatch\narg 2: type mismatch",
+		},
+		{
+			name:    "pair index without context",
+			pairs:   []TypePair{{Left: Int, Right: Int}, {Left: Int, Right: String}},
+			wantErr: "pair 1: type mismatch",
+		},
+		{name: "no pairs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnifyAll(tt.pairs, TypeEnv{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("UnifyAll() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("UnifyAll() error = %v, want %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, ErrTypeMismatch) {
+				t.Errorf("UnifyAll() error = %v, want ErrTypeMismatch", err)
+			}
+		})
+	}
+}
+
+func TestInferCallReportsEveryArgument(t *testing.T) {
+	env := TypeEnv{
+		"f": &FunctionType{ParamTypes: []Type{Int, String, Bool}, ReturnType: Int},
+		"s": String,
+	}
+	_, err := InferType(mustParseExpr(t, `f(s, s, 1)`), env, nil)
+	want := "argument type mismatch for arg 1: type mismatch\nargument type mismatch for arg 3: type mismatch"
+	if err == nil || err.Error() != want {
+		t.Errorf("InferType() error = %v, want %q", err, want)
+	}
+}
+
+func TestTryUnify(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	tests := []struct {
+		name       string
+		t          Type
+		candidates []Type
+		want       int
+		wantErr    error
+		wantT      Type // the binding of T in the substitution, if any
+	}{
+		{
+			name:       "single match binds",
+			t:          &SliceType{ElementType: Int},
+			candidates: []Type{&MapType{KeyType: String, ValueType: tv}, &SliceType{ElementType: tv}},
+			want:       1,
+			wantT:      Int,
+		},
+		{
+			name:       "no match",
+			t:          Bool,
+			candidates: []Type{Int, &SliceType{ElementType: tv}},
+			want:       -1,
+			wantErr:    ErrTypeMismatch,
+		},
+		{
+			name:       "several matches are ambiguous",
+			t:          &SliceType{ElementType: Int},
+			candidates: []Type{&SliceType{ElementType: tv}, tv, String},
+			want:       -1,
+			wantErr:    ErrAmbiguous,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := TypeEnv{"x": Int}
+			got, s, err := TryUnify(tt.t, tt.candidates, env)
+			if got != tt.want {
+				t.Errorf("TryUnify() = %d, want %d", got, tt.want)
+			}
+			if len(env) != 1 {
+				t.Errorf("TryUnify() changed env to %v", env)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("TryUnify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TryUnify() error = %v", err)
+			}
+			if !TypesEqual(s["T"], tt.wantT) {
+				t.Errorf("T = %v, want %v", s["T"], tt.wantT)
+			}
+		})
+	}
+}
+
+func TestUnifyGenericVariant(t *testing.T) {
+	tv := &TypeVariable{Name: "T"}
+	some := &GenericType{Name: "Some", TypeParams: []Type{tv}}
+	none := &GenericType{Name: "None", TypeParams: []Type{tv}}
+	opt := NewSumType("Option", some, none)
+
+	s, err := Unify(opt, &GenericType{Name: "Some", TypeParams: []Type{Int}}, TypeEnv{})
+	if err != nil {
+		t.Fatalf("Unify() error = %v", err)
+	}
+	if !TypesEqual(s["T"], Int) {
+		t.Errorf("T = %v, want %v", s["T"], Int)
+	}
+
+	// a variant matching several members of the sum is ambiguous
+	either := NewSumType("Either", &SliceType{ElementType: tv}, &SliceType{ElementType: &TypeVariable{Name: "U"}})
+	if _, err := Unify(either, &SliceType{ElementType: Int}, TypeEnv{}); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("Unify() error = %v, want %v", err, ErrAmbiguous)
+	}
+}
+
+func TestUnifyNil(t *testing.T) {
+	nilable := []Type{
+		&PointerType{Base: Int},
+		&SliceType{ElementType: Int},
+		&MapType{KeyType: String, ValueType: Int},
+		&ChanType{Dir: SendRecv, ElementType: Int},
+		&FunctionType{ParamTypes: []Type{Int}},
+		&InterfaceType{Name: "Reader"},
+		Error,
+		&NamedType{Name: "Bytes", Underlying: &SliceType{ElementType: Byte}},
+	}
+	for _, typ := range nilable {
+		if _, err := Unify(typ, Nil, make(TypeEnv)); err != nil {
+			t.Errorf("Unify(%s, nil) error = %v", FormatGo(typ), err)
+		}
+		if _, err := Unify(Nil, typ, make(TypeEnv)); err != nil {
+			t.Errorf("Unify(nil, %s) error = %v", FormatGo(typ), err)
+		}
+	}
+
+	values := []Type{Int, String, &ArrayType{ElementType: Int, Len: 2}, &StructType{Name: "Point"}, &NamedType{Name: "Age", Underlying: Int}}
+	for _, typ := range values {
+		if _, err := Unify(typ, Nil, make(TypeEnv)); !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("Unify(%s, nil) error = %v, want %v", FormatGo(typ), err, ErrTypeMismatch)
+		}
+	}
+}
+
+func TestUnifyTypeAlias(t *testing.T) {
+	myInt := &TypeAlias{Name: "MyInt", AliasedTo: Int}
+	ints := &TypeAlias{Name: "Ints", AliasedTo: &SliceType{ElementType: myInt}}
+	age := &NamedType{Name: "Age", Underlying: Int}
+	tv := &TypeVariable{Name: "T"}
+
+	tests := []struct {
+		name    string
+		t1, t2  Type
+		wantErr bool
+	}{
+		{name: "alias on the left", t1: myInt, t2: Int},
+		{name: "alias on the right", t1: Int, t2: myInt},
+		{name: "aliases of the same type", t1: myInt, t2: &TypeAlias{Name: "Number", AliasedTo: Int}},
+		{name: "nested alias", t1: &SliceType{ElementType: Int}, t2: ints},
+		{name: "alias in a function", t1: &FunctionType{ParamTypes: []Type{myInt}, ReturnType: Int}, t2: &FunctionType{ParamTypes: []Type{Int}, ReturnType: myInt}},
+		{name: "alias of another type", t1: myInt, t2: String, wantErr: true},
+		{name: "defined type stays distinct", t1: age, t2: myInt, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unify(tt.t1, tt.t2, make(TypeEnv)); (err != nil) != tt.wantErr {
+				t.Errorf("Unify(%s, %s) error = %v, wantErr %v", FormatGo(tt.t1), FormatGo(tt.t2), err, tt.wantErr)
+			}
+		})
+	}
+
+	s, err := Unify(&SliceType{ElementType: tv}, ints, make(TypeEnv))
+	if err != nil {
+		t.Fatalf("Unify([]T, Ints) error = %v", err)
+	}
+	if got := ApplySubst(tv, s); got != Type(Int) {
+		t.Errorf("T = %s, want int", FormatGo(got))
+	}
+}
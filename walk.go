@@ -0,0 +1,271 @@
+package generic
+
+import "sort"
+
+// Walk traverses t in depth-first order, calling fn for t and every type it contains.
+// If fn returns false, the types contained in that type are not visited.
+//
+// A type that contains itself, like a struct with a field of its own type,
+// is visited once per path, so Walk terminates on recursive types.
+func Walk(t Type, fn func(Type) bool) {
+	walk(t, fn, NewTypeVisitor())
+}
+
+func walk(t Type, fn func(Type) bool, visitor *TypeVisitor) {
+	if t == nil || visitor.Visit(t) {
+		return
+	}
+	defer visitor.Leave(t)
+
+	if !fn(t) {
+		return
+	}
+	for _, child := range typeChildren(t) {
+		walk(child, fn, visitor)
+	}
+}
+
+// Inspect returns every type contained in t, t included, for which pred returns true.
+// The types are returned in the order Walk visits them.
+func Inspect(t Type, pred func(Type) bool) []Type {
+	var found []Type
+	Walk(t, func(t Type) bool {
+		if pred(t) {
+			found = append(found, t)
+		}
+		return true
+	})
+	return found
+}
+
+// typeChildren returns the types directly contained in t, in a deterministic order.
+func typeChildren(t Type) []Type {
+	var children []Type
+	switch t := t.(type) {
+	case *FunctionType:
+		children = append(children, t.ParamTypes...)
+		children = append(children, t.ReturnType)
+	case *TupleType:
+		children = append(children, t.Types...)
+	case *PointerType:
+		children = append(children, t.Base)
+	case *SliceType:
+		children = append(children, t.ElementType)
+	case *ArrayType:
+		children = append(children, t.ElementType)
+	case *MapType:
+		children = append(children, t.KeyType, t.ValueType)
+	case *ChanType:
+		children = append(children, t.ElementType)
+	case *ApproxType:
+		children = append(children, t.Base)
+	case *TypeAlias:
+		children = append(children, t.AliasedTo)
+	case *VarObj, *ConstObj, *TypeObj, *FuncObj:
+		children = append(children, unwrapObject(t))
+	case Method:
+		children = append(children, t.Params...)
+		children = append(children, t.Results...)
+	case *GenericMethod:
+		children = append(children, t.TypeParams...)
+		children = append(children, t.Method)
+	case *Interface:
+		children = appendMethods(children, t.Methods)
+	case *InterfaceType:
+		children = appendMethods(children, t.Methods)
+		children = append(children, t.Embedded...)
+		children = append(children, t.Variants...)
+	case *StructType:
+		children = appendFields(children, t.Fields)
+		children = appendMethods(children, t.Methods)
+	case *ExtensibleStruct:
+		children = appendFields(children, t.Fields)
+		if t.Rest != nil {
+			children = append(children, t.Rest)
+		}
+	case *GenericType:
+		children = append(children, t.TypeParams...)
+		children = appendFields(children, t.Fields)
+		children = appendMethods(children, t.Methods)
+		if t.Signature != nil {
+			children = append(children, t.Signature)
+		}
+	case *TypeConstraint:
+		children = append(children, t.Types...)
+		children = append(children, t.Excluded...)
+	}
+	return children
+}
+
+func appendFields(types []Type, fields map[string]Type) []Type {
+	for _, name := range sortedKeys(fields) {
+		types = append(types, fields[name])
+	}
+	return types
+}
+
+func appendMethods(types []Type, methods MethodSet) []Type {
+	for _, name := range sortedKeys(methods) {
+		types = append(types, methods[name])
+	}
+	return types
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Map returns a copy of t in which every contained type has been replaced by the result of fn.
+// The types contained in a type are mapped before the type itself, and fn is then called
+// with a copy that already holds the mapped types. t itself is never modified.
+//
+// A type that contains itself is mapped once per path; the inner occurrence is
+// passed to fn as it is, so Map terminates on recursive types.
+func Map(t Type, fn func(Type) Type) Type {
+	return mapType(t, fn, NewTypeVisitor())
+}
+
+func mapType(t Type, fn func(Type) Type, visitor *TypeVisitor) Type {
+	if t == nil {
+		return nil
+	}
+	if visitor.Visit(t) {
+		return fn(t)
+	}
+	defer visitor.Leave(t)
+
+	return fn(mapChildren(t, fn, visitor))
+}
+
+// mapChildren returns a shallow copy of t holding the mapped types contained in t.
+func mapChildren(t Type, fn func(Type) Type, visitor *TypeVisitor) Type {
+	switch t := t.(type) {
+	case *FunctionType:
+		return &FunctionType{
+			ParamTypes: mapTypes(t.ParamTypes, fn, visitor),
+			ReturnType: mapType(t.ReturnType, fn, visitor),
+			IsVariadic: t.IsVariadic,
+			Effects:    t.Effects,
+		}
+	case *TupleType:
+		return &TupleType{Types: mapTypes(t.Types, fn, visitor)}
+	case *PointerType:
+		return &PointerType{Base: mapType(t.Base, fn, visitor)}
+	case *SliceType:
+		return &SliceType{ElementType: mapType(t.ElementType, fn, visitor)}
+	case *ArrayType:
+		return &ArrayType{ElementType: mapType(t.ElementType, fn, visitor), Len: t.Len}
+	case *ChanType:
+		return &ChanType{Dir: t.Dir, ElementType: mapType(t.ElementType, fn, visitor)}
+	case *MapType:
+		return &MapType{
+			KeyType:   mapType(t.KeyType, fn, visitor),
+			ValueType: mapType(t.ValueType, fn, visitor),
+		}
+	case *ApproxType:
+		return &ApproxType{Base: mapType(t.Base, fn, visitor)}
+	case *TypeAlias:
+		return &TypeAlias{Name: t.Name, AliasedTo: mapType(t.AliasedTo, fn, visitor)}
+	case *VarObj:
+		return &VarObj{Name: t.Name, Type: mapType(t.Type, fn, visitor)}
+	case *ConstObj:
+		mapped := *t
+		mapped.Type = mapType(t.Type, fn, visitor)
+		return &mapped
+	case *TypeObj:
+		return &TypeObj{Name: t.Name, Type: mapType(t.Type, fn, visitor)}
+	case *FuncObj:
+		return &FuncObj{Name: t.Name, Type: mapType(t.Type, fn, visitor)}
+	case Method:
+		t.Params = mapTypes(t.Params, fn, visitor)
+		t.Results = mapTypes(t.Results, fn, visitor)
+		return t
+	case *GenericMethod:
+		mapped := *t
+		mapped.TypeParams = mapTypes(t.TypeParams, fn, visitor)
+		mapped.Method = mapMethod(t.Method, fn, visitor)
+		return &mapped
+	case *Interface:
+		return &Interface{Name: t.Name, Methods: mapMethods(t.Methods, fn, visitor)}
+	case *InterfaceType:
+		mapped := *t
+		mapped.Methods = mapMethods(t.Methods, fn, visitor)
+		mapped.Embedded = mapTypes(t.Embedded, fn, visitor)
+		mapped.Variants = mapTypes(t.Variants, fn, visitor)
+		return &mapped
+	case *StructType:
+		mapped := *t
+		mapped.Fields = mapFields(t.Fields, fn, visitor)
+		mapped.Methods = mapMethods(t.Methods, fn, visitor)
+		return &mapped
+	case *ExtensibleStruct:
+		var rest Type
+		if t.Rest != nil {
+			rest = mapType(t.Rest, fn, visitor)
+		}
+		return withRow(mapFields(t.Fields, fn, visitor), rest)
+	case *GenericType:
+		mapped := *t
+		mapped.TypeParams = mapTypes(t.TypeParams, fn, visitor)
+		mapped.Fields = mapFields(t.Fields, fn, visitor)
+		mapped.Methods = mapMethods(t.Methods, fn, visitor)
+		if t.Signature != nil {
+			if sig, ok := mapType(t.Signature, fn, visitor).(*FunctionType); ok {
+				mapped.Signature = sig
+			}
+		}
+		return &mapped
+	case *TypeConstraint:
+		mapped := *t
+		mapped.Types = mapTypes(t.Types, fn, visitor)
+		mapped.Excluded = mapTypes(t.Excluded, fn, visitor)
+		return &mapped
+	}
+	return t
+}
+
+func mapTypes(types []Type, fn func(Type) Type, visitor *TypeVisitor) []Type {
+	if types == nil {
+		return nil
+	}
+	mapped := make([]Type, len(types))
+	for i, t := range types {
+		mapped[i] = mapType(t, fn, visitor)
+	}
+	return mapped
+}
+
+func mapFields(fields map[string]Type, fn func(Type) Type, visitor *TypeVisitor) map[string]Type {
+	if fields == nil {
+		return nil
+	}
+	mapped := make(map[string]Type, len(fields))
+	for name, t := range fields {
+		mapped[name] = mapType(t, fn, visitor)
+	}
+	return mapped
+}
+
+// mapMethod maps a method, keeping the original if fn does not return a method.
+func mapMethod(method Method, fn func(Type) Type, visitor *TypeVisitor) Method {
+	if mapped, ok := mapType(method, fn, visitor).(Method); ok {
+		return mapped
+	}
+	return method
+}
+
+func mapMethods(methods MethodSet, fn func(Type) Type, visitor *TypeVisitor) MethodSet {
+	if methods == nil {
+		return nil
+	}
+	mapped := make(MethodSet, len(methods))
+	for name, method := range methods {
+		mapped[name] = mapMethod(method, fn, visitor)
+	}
+	return mapped
+}
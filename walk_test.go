@@ -0,0 +1,135 @@
+package generic
+
+import (
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	tv := &TypeVariable{Name: "T"}
+
+	tests := []struct {
+		name     string
+		typ      Type
+		expected []string
+	}{
+		{
+			name:     "map of slices",
+			typ:      &MapType{KeyType: &TypeConstant{Name: "string"}, ValueType: &SliceType{ElementType: tv}},
+			expected: []string{"Map[TypeConst(string)]Slice(TypeVar(T))", "TypeConst(string)", "Slice(TypeVar(T))", "TypeVar(T)"},
+		},
+		{
+			name:     "function",
+			typ:      &FunctionType{ParamTypes: []Type{&PointerType{Base: tv}}, ReturnType: intType},
+			expected: []string{"func(*TypeVar(T)) TypeConst(int)", "*TypeVar(T)", "TypeVar(T)", "TypeConst(int)"},
+		},
+		{
+			name:     "struct fields in name order",
+			typ:      &StructType{Name: "Point", Fields: map[string]Type{"y": intType, "x": tv}},
+			expected: []string{"Struct(Point)", "TypeVar(T)", "TypeConst(int)"},
+		},
+		{
+			name:     "constraint terms",
+			typ:      &TypeConstraint{Types: []Type{&ApproxType{Base: intType}}, Union: true},
+			expected: []string{"Union([], [~TypeConst(int)])", "~TypeConst(int)", "TypeConst(int)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []string
+			Walk(tt.typ, func(t Type) bool {
+				visited = append(visited, t.String())
+				return true
+			})
+			if len(visited) != len(tt.expected) {
+				t.Fatalf("Walk() visited %v, want %v", visited, tt.expected)
+			}
+			for i := range visited {
+				if visited[i] != tt.expected[i] {
+					t.Errorf("Walk() visited %v, want %v", visited, tt.expected)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWalkSkipAndCycles(t *testing.T) {
+	node := &StructType{Name: "Node", Fields: map[string]Type{"value": &TypeConstant{Name: "int"}}}
+	node.Fields["next"] = &PointerType{Base: node}
+
+	var count int
+	Walk(node, func(t Type) bool {
+		count++
+		return true
+	})
+	// Node, *Node, int
+	if count != 3 {
+		t.Errorf("Walk() on recursive struct visited %d types, want 3", count)
+	}
+
+	count = 0
+	Walk(node, func(t Type) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("Walk() visited %d types after returning false, want 1", count)
+	}
+
+	vars := Inspect(&TupleType{Types: []Type{&TypeVariable{Name: "A"}, &SliceType{ElementType: &TypeVariable{Name: "B"}}}}, func(t Type) bool {
+		_, ok := t.(*TypeVariable)
+		return ok
+	})
+	if len(vars) != 2 || vars[0].String() != "TypeVar(A)" || vars[1].String() != "TypeVar(B)" {
+		t.Errorf("Inspect() = %v, want [TypeVar(A) TypeVar(B)]", vars)
+	}
+}
+
+func TestMap(t *testing.T) {
+	intType := &TypeConstant{Name: "int"}
+	replaceT := func(t Type) Type {
+		if tv, ok := t.(*TypeVariable); ok && tv.Name == "T" {
+			return intType
+		}
+		return t
+	}
+
+	original := &MapType{
+		KeyType:   &TypeVariable{Name: "T"},
+		ValueType: &FunctionType{ParamTypes: []Type{&SliceType{ElementType: &TypeVariable{Name: "T"}}}, ReturnType: &TypeVariable{Name: "U"}},
+	}
+	mapped := Map(original, replaceT)
+	expected := &MapType{
+		KeyType:   intType,
+		ValueType: &FunctionType{ParamTypes: []Type{&SliceType{ElementType: intType}}, ReturnType: &TypeVariable{Name: "U"}},
+	}
+	if !TypesEqual(mapped, expected) {
+		t.Errorf("Map() = %v, want %v", mapped, expected)
+	}
+	if original.KeyType.String() != "TypeVar(T)" {
+		t.Errorf("Map() modified the original type: %v", original)
+	}
+
+	// types are mapped after their contents
+	var order []string
+	Map(&PointerType{Base: intType}, func(t Type) Type {
+		order = append(order, t.String())
+		return t
+	})
+	if len(order) != 2 || order[0] != "TypeConst(int)" || order[1] != "*TypeConst(int)" {
+		t.Errorf("Map() order = %v, want [TypeConst(int) *TypeConst(int)]", order)
+	}
+
+	// recursive types terminate
+	node := &StructType{Name: "Node", Fields: map[string]Type{"value": &TypeVariable{Name: "T"}}}
+	node.Fields["next"] = &PointerType{Base: node}
+	mappedNode := Map(node, replaceT).(*StructType)
+	if !TypesEqual(mappedNode.Fields["value"], intType) {
+		t.Errorf("Map() value field = %v, want %v", mappedNode.Fields["value"], intType)
+	}
+	if node.Fields["value"].String() != "TypeVar(T)" {
+		t.Errorf("Map() modified the original struct")
+	}
+}